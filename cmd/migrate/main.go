@@ -0,0 +1,121 @@
+// Package main is the migrate command, which copies retained job results from one
+// storage backend to another.
+//
+// Usage:
+//
+//	migrate -from filesystem:/var/lib/pako/audio -to filesystem:/mnt/new/audio \
+//	        -progress migrate.progress
+//
+// -from-keys and -to-keys name the key strategy of each backend, so results can
+// also be moved to another layout, e.g. -to-keys tenant. A Google Cloud Storage
+// bucket is named gcs:<bucket>/<prefix>, and accessed with the credentials of
+// GOOGLE_APPLICATION_CREDENTIALS, gcloud or the metadata server.
+//
+// Stop the server (or point it at the new backend) before the final run, so no
+// result is written to the old backend after it was copied. Rerunning with the
+// same -progress file resumes where an interrupted run stopped.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/storage/filesystem"
+	"github.com/pako-tts/server/internal/storage/gcs"
+	"github.com/pako-tts/server/internal/storage/keys"
+	"github.com/pako-tts/server/internal/storage/migrate"
+)
+
+func main() {
+	from := flag.String("from", "", "source storage backend, e.g. filesystem:./audio_cache or gcs:bucket/prefix")
+	to := flag.String("to", "", "destination storage backend, e.g. filesystem:/mnt/audio")
+	fromKeys := flag.String("from-keys", keys.Default, "key strategy of the source: "+strings.Join(keys.Names(), ", "))
+	toKeys := flag.String("to-keys", keys.Default, "key strategy of the destination")
+	progressPath := flag.String("progress", "migrate.progress", "file recording copied objects, for resuming; empty disables")
+	verify := flag.Bool("verify", true, "read every copied object back and compare checksums")
+	dryRun := flag.Bool("dry-run", false, "list what would be copied without writing")
+	quiet := flag.Bool("quiet", false, "only print the summary")
+	flag.Parse()
+
+	if *from == "" || *to == "" {
+		fmt.Fprintln(os.Stderr, "migrate: -from and -to are required")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *from == *to {
+		fmt.Fprintln(os.Stderr, "migrate: -from and -to name the same backend")
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	src, err := openBackend(ctx, *from, *fromKeys)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: source: %v\n", err)
+		os.Exit(1)
+	}
+	dst, err := openBackend(ctx, *to, *toKeys)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: destination: %v\n", err)
+		os.Exit(1)
+	}
+
+	opts := migrate.Options{ProgressPath: *progressPath, Verify: *verify, DryRun: *dryRun}
+	if !*quiet {
+		opts.OnObject = func(obj domain.ObjectInfo, outcome migrate.Outcome, err error) {
+			if err != nil {
+				fmt.Printf("%-7s %s: %v\n", outcome, obj.Key, err)
+				return
+			}
+			fmt.Printf("%-7s %s (%d bytes)\n", outcome, obj.Key, obj.Size)
+		}
+	}
+
+	report, err := migrate.Run(ctx, src, dst, opts)
+	if report != nil {
+		fmt.Printf("\n%d objects: %d copied (%d bytes), %d already done, %d failed\n",
+			report.Total, report.Copied, report.Bytes, report.Skipped, len(report.Failures))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+		if *progressPath != "" {
+			fmt.Fprintf(os.Stderr, "rerun with -progress %s to resume\n", *progressPath)
+		}
+		os.Exit(1)
+	}
+	if len(report.Failures) > 0 {
+		os.Exit(1)
+	}
+}
+
+// openBackend opens a storage backend from a "<type>:<location>" spec, keeping
+// objects where the key strategy called keyStrategy says. The location of a gcs
+// backend is the bucket, optionally followed by a prefix: gcs:<bucket>/<prefix>.
+func openBackend(ctx context.Context, spec, keyStrategy string) (domain.ObjectStore, error) {
+	kind, location, ok := strings.Cut(spec, ":")
+	if !ok || location == "" {
+		return nil, fmt.Errorf("%q is not a <type>:<location> backend spec", spec)
+	}
+	strategy, err := keys.Parse(keyStrategy)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case "filesystem":
+		return filesystem.NewStorageWithKeys(location, strategy, zap.NewNop())
+	case "gcs":
+		bucket, prefix, _ := strings.Cut(location, "/")
+		return gcs.NewStorage(ctx, gcs.Config{Bucket: bucket, Prefix: prefix}, strategy, zap.NewNop())
+	default:
+		return nil, fmt.Errorf("storage backend %q is not available in this build (supported: filesystem, gcs)", kind)
+	}
+}
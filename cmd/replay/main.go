@@ -0,0 +1,189 @@
+// Package main is the replay command, which re-submits recorded jobs to a
+// server and compares their outcome, latency and audio duration with the
+// recording, e.g. to validate a new provider against a production workload
+// before making it the default.
+//
+// Usage:
+//
+//	curl -H "X-API-Key: $ADMIN_KEY" "https://tts.example.com/api/v1/admin/jobs/export?limit=500" > jobs.jsonl
+//	replay -manifest jobs.jsonl -target http://staging:8080 -provider gemini -voice Kore \
+//	       -concurrency 8 -out replay-audio
+//
+// -speed 1 keeps the time between the recorded submissions, -speed 10 replays
+// ten times faster, and the default, 0, submits as fast as -concurrency allows.
+// The command exits non-zero when a job that completed when recorded didn't
+// complete when replayed.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/replay"
+)
+
+func main() {
+	manifestPath := flag.String("manifest", "", "JSON Lines manifest of recorded jobs, as exported by /api/v1/admin/jobs/export; - reads stdin")
+	target := flag.String("target", "", "base URL of the server to replay against, e.g. http://localhost:8080")
+	apiKey := flag.String("api-key", os.Getenv("PAKO_API_KEY"), "API key for the target (default $PAKO_API_KEY)")
+	provider := flag.String("provider", "", "provider to send every job to, instead of the recorded one")
+	voice := flag.String("voice", "", "voice ID to use for every job, instead of the recorded one")
+	model := flag.String("model", "", "model ID to use for every job, instead of the recorded one")
+	concurrency := flag.Int("concurrency", 4, "jobs in flight at once")
+	speed := flag.Float64("speed", 0, "pace submissions like the recording, this many times faster; 0 submits as fast as -concurrency allows")
+	limit := flag.Int("limit", 0, "replay only the first n recorded jobs; 0 replays all")
+	timeout := flag.Duration("timeout", 10*time.Minute, "how long each job may take before it is cancelled")
+	poll := flag.Duration("poll", 500*time.Millisecond, "how often job status is checked")
+	outDir := flag.String("out", "", "directory to save each result to, as <recorded job ID>.<format>")
+	reportPath := flag.String("report", "", "file to write the result of each job to, as JSON Lines")
+	dryRun := flag.Bool("dry-run", false, "list the jobs that would be replayed without submitting them")
+	quiet := flag.Bool("quiet", false, "only print the summary")
+	flag.Parse()
+
+	if *manifestPath == "" || (*target == "" && !*dryRun) {
+		fmt.Fprintln(os.Stderr, "replay: -manifest and -target are required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	entries, err := readManifest(*manifestPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "replay: %v\n", err)
+		os.Exit(1)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].CreatedAt.Before(entries[j].CreatedAt) })
+	if *limit > 0 && len(entries) > *limit {
+		entries = entries[:*limit]
+	}
+
+	if *dryRun {
+		for _, e := range entries {
+			fmt.Printf("%s %s %s/%s %d chars (recorded %s)\n",
+				e.CreatedAt.Format(time.RFC3339), e.JobID, e.Provider, e.VoiceID, len(e.Text), e.Status)
+		}
+		fmt.Printf("\n%d jobs would be replayed\n", len(entries))
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	opts := replay.Options{
+		Target:       *target,
+		APIKey:       *apiKey,
+		Provider:     *provider,
+		VoiceID:      *voice,
+		ModelID:      *model,
+		Concurrency:  *concurrency,
+		Speed:        *speed,
+		PollInterval: *poll,
+		Timeout:      *timeout,
+		OutputDir:    *outDir,
+	}
+	if !*quiet {
+		opts.OnResult = func(r replay.Result) {
+			if r.Outcome != replay.OutcomeCompleted {
+				fmt.Printf("%-9s %s: %s\n", r.Outcome, r.RecordedJobID, r.Error)
+				return
+			}
+			fmt.Printf("%-9s %s -> %s via %s in %dms (recorded %dms)\n",
+				r.Outcome, r.RecordedJobID, r.JobID, r.Provider, r.LatencyMs, r.RecordedLatencyMs)
+		}
+	}
+
+	report, err := replay.Run(ctx, entries, opts)
+	if report != nil {
+		printSummary(report)
+		if *reportPath != "" {
+			if err := writeReport(*reportPath, report.Results); err != nil {
+				fmt.Fprintf(os.Stderr, "replay: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "replay: %v\n", err)
+		os.Exit(1)
+	}
+	for _, r := range report.Results {
+		recorded := r.RecordedStatus == domain.JobStatusCompleted || r.RecordedStatus == domain.JobStatusExpired
+		if recorded && r.Outcome != replay.OutcomeCompleted {
+			os.Exit(1)
+		}
+	}
+}
+
+func readManifest(path string) ([]replay.Entry, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close() //nolint:errcheck
+		r = file
+	}
+	return replay.ReadManifest(r)
+}
+
+func printSummary(report *replay.Report) {
+	outcomes := []replay.Outcome{replay.OutcomeCompleted, replay.OutcomeFailed, replay.OutcomeRejected, replay.OutcomeTimedOut, replay.OutcomeError}
+	var counts []string
+	for _, outcome := range outcomes {
+		if n := report.Outcomes[outcome]; n > 0 || outcome == replay.OutcomeCompleted {
+			counts = append(counts, fmt.Sprintf("%d %s", n, strings.ReplaceAll(string(outcome), "_", " ")))
+		}
+	}
+	fmt.Printf("\n%d jobs replayed in %s: %s (%d completed when recorded)\n",
+		report.Total, report.Elapsed.Round(time.Second), strings.Join(counts, ", "), report.RecordedCompleted)
+
+	if report.Compared > 0 {
+		fmt.Printf("latency p50/p90/p99: %s / %s / %s, recorded %s / %s / %s (%d jobs completed in both)\n",
+			ms(report.Latency.P50), ms(report.Latency.P90), ms(report.Latency.P99),
+			ms(report.RecordedLatency.P50), ms(report.RecordedLatency.P90), ms(report.RecordedLatency.P99),
+			report.Compared)
+	}
+	if report.DurationRatio > 0 {
+		fmt.Printf("audio duration: median %.2fx of the recorded result\n", report.DurationRatio)
+	}
+	if len(report.Errors) > 0 {
+		keys := make([]string, 0, len(report.Errors))
+		for key := range report.Errors {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return report.Errors[keys[i]] > report.Errors[keys[j]] })
+		fmt.Println("errors:")
+		for _, key := range keys {
+			fmt.Printf("  %4d %s\n", report.Errors[key], key)
+		}
+	}
+}
+
+func ms(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
+
+func writeReport(path string, results []replay.Result) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(file)
+	for _, r := range results {
+		if err := enc.Encode(r); err != nil {
+			file.Close() //nolint:errcheck
+			return err
+		}
+	}
+	return file.Close()
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+
+	"github.com/pako-tts/server/internal/provider/registry"
+	"github.com/pako-tts/server/pkg/config"
+)
+
+// providerProbeTimeout bounds each provider availability check of --check-config.
+const providerProbeTimeout = 10 * time.Second
+
+// checkResult is one line of the --check-config report.
+type checkResult struct {
+	name   string
+	detail string
+	err    error
+}
+
+// runConfigCheck validates cfg, probes storage and providers, and writes the
+// redacted effective configuration and the check results to out. It returns the
+// process exit code: 0 when every check passed, 1 otherwise.
+func runConfigCheck(cfg *config.Config, out io.Writer) int {
+	ctx := context.Background()
+	results := []checkResult{
+		{name: "config", err: cfg.Validate()},
+	}
+
+	_, _, _, err := buildAccessControl(cfg)
+	results = append(results, checkResult{name: "access control", err: err})
+
+	results = append(results, checkStorage(ctx, cfg))
+
+	queueDetail := fmt.Sprintf("in-memory, %d slots, %d workers", cfg.Queue.MaxConcurrentJobs, cfg.Queue.WorkerCount)
+	var queueErr error
+	if cfg.Queue.MaxConcurrentJobs <= 0 {
+		queueErr = fmt.Errorf("queue.max_concurrent_jobs must be positive")
+	} else if cfg.Queue.WorkerCount <= 0 && len(cfg.Queue.WorkerPools) == 0 {
+		queueErr = fmt.Errorf("no workers: set queue.worker_count or queue.worker_pools")
+	} else if cfg.Queue.Backend == config.QueueBackendPostgres {
+		// Connecting also creates the jobs table if it is missing.
+		queueDetail = fmt.Sprintf("postgres, %d workers", cfg.Queue.WorkerCount)
+		var closeQueue func() error
+		if _, closeQueue, queueErr = openQueue(cfg, zap.NewNop()); queueErr == nil {
+			closeQueue() //nolint:errcheck
+		}
+	}
+	results = append(results, checkResult{name: "queue", detail: queueDetail, err: queueErr})
+
+	if cfg.Secrets.Backend != "" {
+		// Load already read the store; a failure there never gets this far.
+		results = append(results, checkResult{name: "secrets", detail: "loaded from " + cfg.Secrets.Backend})
+	}
+
+	if cfg.Consumer.Backend != "" {
+		results = append(results, checkConsumer(ctx, cfg))
+	}
+
+	results = append(results, checkProviders(ctx, cfg)...)
+
+	var report strings.Builder
+	enc := yaml.NewEncoder(&report)
+	enc.SetIndent(2)
+	if err := enc.Encode(cfg.Redacted()); err != nil {
+		report.WriteString(fmt.Sprintf("failed to render configuration: %v\n", err))
+	}
+	fmt.Fprintf(out, "Effective configuration:\n\n%s\nChecks:\n", report.String()) //nolint:errcheck
+
+	failed := 0
+	for _, r := range results {
+		status, detail := "ok  ", r.detail
+		if r.err != nil {
+			status, detail = "FAIL", r.err.Error()
+			failed++
+		}
+		if detail != "" {
+			detail = ": " + detail
+		}
+		fmt.Fprintf(out, "  %s  %s%s\n", status, r.name, detail) //nolint:errcheck
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(out, "\n%d of %d checks failed\n", failed, len(results)) //nolint:errcheck
+		return 1
+	}
+	fmt.Fprintf(out, "\nAll %d checks passed\n", len(results)) //nolint:errcheck
+	return 0
+}
+
+// checkStorage writes and removes a probe file in the audio storage directory or
+// bucket.
+func checkStorage(ctx context.Context, cfg *config.Config) checkResult {
+	result := checkResult{name: "storage", detail: cfg.Storage.AudioStoragePath}
+	storage, path, err := openStorage(ctx, cfg, zap.NewNop())
+	if err != nil {
+		result.err = err
+		return result
+	}
+	result.detail = path
+	const probeID = "check-config-probe"
+	if _, err := storage.Store(ctx, probeID, []byte("probe"), "wav"); err != nil {
+		result.err = fmt.Errorf("%s is not writable: %w", path, err)
+		return result
+	}
+	if result.err = storage.Delete(ctx, probeID); result.err == nil {
+		_, result.err = resultURLs(cfg, storage)
+	}
+	return result
+}
+
+// checkConsumer connects to the message queue job requests are read from.
+func checkConsumer(ctx context.Context, cfg *config.Config) checkResult {
+	result := checkResult{name: "consumer", detail: cfg.Consumer.Backend}
+	if cfg.Consumer.Topic != "" {
+		result.detail += " " + cfg.Consumer.Topic
+	}
+	broker, err := openConsumer(ctx, cfg)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	result.err = broker.Close()
+	return result
+}
+
+// checkProviders builds every configured provider and checks that it is reachable.
+func checkProviders(ctx context.Context, cfg *config.Config) []checkResult {
+	providers, err := registry.NewRegistry(&cfg.Providers)
+	if err != nil {
+		return []checkResult{{name: "providers", err: err}}
+	}
+
+	results := make([]checkResult, 0, len(providers.List()))
+	for _, p := range providers.List() {
+		probeCtx, cancel := context.WithTimeout(ctx, providerProbeTimeout)
+		result := checkResult{name: "provider " + p.Name(), detail: "available"}
+		if !p.IsAvailable(probeCtx) {
+			result.err = fmt.Errorf("not available")
+		}
+		cancel()
+		results = append(results, result)
+	}
+	return results
+}
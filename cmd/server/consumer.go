@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/pako-tts/server/internal/consumer"
+	"github.com/pako-tts/server/pkg/config"
+)
+
+// openConsumer connects to the message queue selected by consumer.backend.
+func openConsumer(ctx context.Context, cfg *config.Config) (consumer.Broker, error) {
+	c := cfg.Consumer
+	switch c.Backend {
+	case config.ConsumerBackendNATS:
+		return consumer.NewNATS(ctx, c.URL, c.Topic, c.Group)
+	case config.ConsumerBackendSQS:
+		return consumer.NewSQS(ctx, c.URL, c.Region)
+	}
+	// Each instance reads as its own member of the group, and takes over what it
+	// read before a restart
+	return consumer.NewRedis(ctx, c.URL, c.Topic, c.Group, consumerName())
+}
+
+// consumerName names this instance to the message queue and to the jobs it owes
+// replies for: its host name, which stays the same across restarts.
+func consumerName() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		name = "pako-tts"
+	}
+	return name
+}
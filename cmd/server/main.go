@@ -4,19 +4,38 @@ package main
 import (
 	"context"
 	_ "embed"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"go.uber.org/zap"
 
+	"github.com/pako-tts/server/internal/abuse"
 	"github.com/pako-tts/server/internal/api"
+	apimiddleware "github.com/pako-tts/server/internal/api/middleware"
+	"github.com/pako-tts/server/internal/audio/transcode"
+	"github.com/pako-tts/server/internal/bulk"
+	"github.com/pako-tts/server/internal/consumer"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/llm"
+	"github.com/pako-tts/server/internal/metrics"
+	"github.com/pako-tts/server/internal/pipeline"
 	"github.com/pako-tts/server/internal/provider/registry"
+	"github.com/pako-tts/server/internal/queue/dedup"
 	"github.com/pako-tts/server/internal/queue/memory"
-	"github.com/pako-tts/server/internal/storage/filesystem"
+	"github.com/pako-tts/server/internal/rewrite"
+	"github.com/pako-tts/server/internal/scheduler"
+	"github.com/pako-tts/server/internal/speechcache"
+	"github.com/pako-tts/server/internal/storage/cleanup"
+	"github.com/pako-tts/server/internal/textinfo"
+	"github.com/pako-tts/server/internal/textsource"
+	"github.com/pako-tts/server/internal/version"
+	"github.com/pako-tts/server/internal/webhook"
 	"github.com/pako-tts/server/pkg/config"
 )
 
@@ -24,6 +43,12 @@ import (
 var openAPISpec []byte
 
 func main() {
+	checkConfig := flag.Bool("check-config", false,
+		"validate the configuration, probe storage and providers, print the effective config and exit")
+	role := flag.String("role", "",
+		"run mode: all (API and workers), api or worker; overrides server.role")
+	flag.Parse()
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
@@ -31,6 +56,22 @@ func main() {
 		os.Exit(1)
 	}
 
+	if *role != "" {
+		cfg.Server.Role = *role
+	}
+
+	if *checkConfig {
+		os.Exit(runConfigCheck(cfg, os.Stdout))
+	}
+
+	if err := cfg.ValidateRole(); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid role: %v\n", err)
+		os.Exit(1)
+	}
+	// API nodes leave jobs to worker nodes, which don't serve the API
+	servesAPI := cfg.Server.Role != config.RoleWorker
+	runsWorkers := cfg.Server.Role != config.RoleAPI
+
 	// Initialize logger
 	logger, err := config.NewLogger(&cfg.Logging)
 	if err != nil {
@@ -40,7 +81,9 @@ func main() {
 	defer logger.Sync() //nolint:errcheck
 
 	logger.Info("Starting Pako TTS server",
+		zap.String("version", version.Version),
 		zap.Int("port", cfg.Server.Port),
+		zap.String("role", cfg.Server.Role),
 		zap.String("log_level", cfg.Logging.Level),
 	)
 
@@ -53,45 +96,358 @@ func main() {
 		zap.Int("providers", len(providerRegistry.List())),
 		zap.String("default", providerRegistry.DefaultName()),
 	)
+	providerRegistry.OnKeyFailover(func(provider string, cause error) {
+		logger.Error("Provider rejected its primary API key; switched to the secondary key",
+			zap.Bool("alert", true),
+			zap.String("provider", provider),
+			zap.Error(cause),
+		)
+	})
+	providerRegistry.OnDegradation(func(provider string, degraded bool, reason string) {
+		if degraded {
+			logger.Warn("Provider rate limited; entering degraded mode",
+				zap.Bool("alert", true),
+				zap.String("provider", provider),
+				zap.String("reason", reason),
+			)
+			return
+		}
+		logger.Info("Provider recovered from degraded mode", zap.String("provider", provider))
+	})
 
 	// Initialize storage
-	storage, err := filesystem.NewStorage(cfg.Storage.AudioStoragePath, logger)
+	storage, storagePath, err := openStorage(context.Background(), cfg, logger)
 	if err != nil {
 		logger.Fatal("Failed to initialize storage", zap.Error(err))
 	}
 	logger.Info("Storage initialized",
-		zap.String("path", cfg.Storage.AudioStoragePath),
+		zap.String("backend", cfg.Storage.Backend),
+		zap.String("path", storagePath),
+		zap.String("key_strategy", cfg.Storage.KeyStrategy),
 	)
+	signedURLs, err := resultURLs(cfg, storage)
+	if err != nil {
+		logger.Warn("Result redirects disabled; results are served through the server", zap.Error(err))
+	}
 
 	// Initialize queue
-	queue := memory.NewQueue(cfg.Queue.MaxConcurrentJobs)
+	queue, closeQueue, err := openQueue(cfg, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize queue", zap.Error(err))
+	}
 	logger.Info("Queue initialized",
+		zap.String("backend", cfg.Queue.Backend),
 		zap.Int("max_concurrent", cfg.Queue.MaxConcurrentJobs),
+		zap.Duration("enqueue_wait", cfg.Queue.EnqueueWait),
+		zap.Int("tenant_max_in_flight", cfg.Queue.TenantMaxInFlight),
+		zap.Int("max_chars_in_flight", cfg.Queue.MaxCharsInFlight),
+		zap.String("dedup_mode", cfg.Queue.DedupMode),
+		zap.String("dequeue", cfg.Queue.Dequeue),
+		zap.Duration("visibility_timeout", cfg.Queue.VisibilityTimeout),
+		zap.Int("max_deliveries", cfg.Queue.MaxDeliveries),
 	)
+	restoreJobs(cfg, queue, logger)
+
+	textSources := textsource.New(textsource.Options{
+		MaxBytes:     cfg.TextSources.MaxBytes,
+		FetchTimeout: cfg.TextSources.FetchTimeout,
+		AllowedHosts: cfg.TextSources.AllowedHosts,
+		Jobs:         queue,
+	})
+
+	// Language model behind the summarize pipeline stage
+	if cfg.LLM.Endpoint != "" {
+		pipeline.RegisterSummarize(llm.NewClient(cfg.LLM.Endpoint, cfg.LLM.APIKey, cfg.LLM.Model, cfg.LLM.Timeout))
+		logger.Info("Summarize stage enabled", zap.String("model", cfg.LLM.Model))
+	}
+
+	// Hooks behind the rewrite pipeline stage
+	for _, h := range cfg.RewriteHooks {
+		var hook pipeline.Hook
+		switch h.Type {
+		case config.RewriteHookLLM:
+			model := h.Model
+			if model == "" {
+				model = cfg.LLM.Model
+			}
+			hook = pipeline.CompleterHook(llm.NewClient(cfg.LLM.Endpoint, cfg.LLM.APIKey, model, h.Timeout), h.Instructions)
+		case config.RewriteHookHTTP:
+			hook = rewrite.NewHTTPHook(h.URL, h.Headers, h.Timeout)
+		}
+		pipeline.RegisterHook(h.Name, hook, pipeline.HookOptions{Timeout: h.Timeout, CacheTTL: h.CacheTTL})
+		logger.Info("Rewrite hook registered", zap.String("name", h.Name), zap.String("type", h.Type))
+	}
+	pipeline.OnHookError(func(stage string, err error) {
+		logger.Warn("Pipeline hook failed", zap.String("stage", stage), zap.Error(err))
+	})
+
+	// Speech cache for warmed sync requests
+	var speechCache domain.SpeechCache
+	if cfg.Storage.SpeechCachePath != "" {
+		cache, err := speechcache.New(cfg.Storage.SpeechCachePath)
+		if err != nil {
+			logger.Fatal("Failed to initialize speech cache", zap.Error(err))
+		}
+		speechCache = cache
+		logger.Info("Speech cache enabled", zap.String("path", cfg.Storage.SpeechCachePath))
+	}
+
+	// Result cache for repeated requests
+	var resultCache domain.SpeechCache
+	var resultCacheDir *speechcache.Cache
+	if cfg.Storage.ResultCache {
+		cache, err := speechcache.NewExpiring(cfg.Storage.ResultCachePath, cfg.Storage.ResultCacheTTL)
+		if err != nil {
+			logger.Fatal("Failed to initialize result cache", zap.Error(err))
+		}
+		resultCache, resultCacheDir = cache, cache
+		logger.Info("Result cache enabled",
+			zap.String("path", cfg.Storage.ResultCachePath),
+			zap.Duration("ttl", cfg.Storage.ResultCacheTTL),
+		)
+	}
+
+	// Tenant webhooks, notified of finished jobs and batches and of provider quota use
+	webhooks := webhook.NewMemoryStore()
+	webhookDispatcher := webhook.NewDispatcher(webhooks, queue, logger, cfg.Webhooks.Timeout, cfg.Webhooks.AllowedHosts, cfg.Webhooks.Secret)
+	providerRegistry.OnQuotaWarning(webhookDispatcher.QuotaWarning)
+	// Registered webhooks live in this process's memory, where jobs finished on
+	// another node never look, so /webhooks is only served when one process does both
+	var tenantWebhooks domain.WebhookStore
+	if servesAPI && runsWorkers {
+		tenantWebhooks = webhooks
+	} else {
+		logger.Info("Tenant webhooks disabled: they need server.role all")
+	}
+
+	var metricsRegistry *metrics.Registry
+	var cleanupMetrics *metrics.CleanupMetrics
+	var jobMetrics *metrics.JobMetrics
+	var resultCacheMetrics *metrics.ResultCacheMetrics
+	var abuseMetrics *metrics.AbuseMetrics
+	if cfg.Server.MetricsEnabled {
+		metricsRegistry = metrics.NewRegistry()
+		cleanupMetrics = metrics.NewCleanupMetrics(metricsRegistry)
+		jobMetrics = metrics.NewJobMetrics(metricsRegistry)
+		resultCacheMetrics = metrics.NewResultCacheMetrics(metricsRegistry)
+		abuseMetrics = metrics.NewAbuseMetrics(metricsRegistry)
+	}
 
 	// Start worker pool
-	worker := memory.NewWorker(queue, providerRegistry, storage, logger, cfg.Storage.JobRetentionHours)
+	worker := memory.NewWorker(queue, providerRegistry, storage, logger, cfg.Storage.JobRetentionHours, cfg.Storage.PreviewSeconds, textSources, speechCache, memory.RetryPolicy{
+		MaxAttempts: cfg.Queue.MaxAttempts,
+		BaseDelay:   cfg.Queue.RetryBaseDelay,
+		MaxDelay:    cfg.Queue.RetryMaxDelay,
+	})
+	dequeue, err := dequeueStrategy(cfg, queue)
+	if err != nil {
+		logger.Fatal("Invalid queue configuration", zap.Error(err))
+	}
+	worker.DequeueWith(dequeue)
+	worker.DetectSilence(cfg.Queue.SilenceThresholdDB)
+	worker.RetainArtifacts(artifactRetention(cfg))
+	worker.Heartbeat(cfg.Queue.HeartbeatInterval)
+	worker.DegradeRateLimited(cfg.Providers.Degradation.Concurrency)
+	if cfg.Queue.LimitProviderConcurrency {
+		worker.LimitProviderConcurrency()
+	}
+	if resultCache != nil {
+		worker.CacheResults(resultCache, resultCacheMetrics)
+	}
+	worker.OnFinished(func(ctx context.Context, job *domain.Job) {
+		jobMetrics.Finished(job.Status)
+		webhookDispatcher.JobFinished(ctx, job)
+	})
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	worker.Start(ctx, cfg.Queue.WorkerCount)
+	var workerPools domain.WorkerPools
+	var drainer domain.Drainer
+	if runsWorkers {
+		pools := make([]memory.Pool, 0, len(cfg.Queue.WorkerPools))
+		for _, p := range cfg.Queue.WorkerPools {
+			pools = append(pools, memory.Pool{Name: p.Name, Workers: p.Workers, Providers: p.Providers})
+		}
+		worker.Start(ctx, cfg.Queue.WorkerCount, pools...)
+		workerPools = worker
+		drainer = worker
+	}
+
+	// Re-read secrets periodically so rotated provider keys apply without a restart
+	if cfg.Secrets.Backend != "" {
+		logger.Info("Secrets loaded",
+			zap.String("backend", cfg.Secrets.Backend),
+			zap.Duration("refresh_interval", cfg.Secrets.RefreshInterval),
+		)
+		if cfg.Secrets.RefreshInterval > 0 {
+			go refreshSecrets(ctx, cfg, providerRegistry, logger)
+		}
+	}
+
+	// Recurring tasks, scheduled in the job store when it is shared so that runs
+	// survive restarts and only one instance takes each
+	var schedules domain.ScheduleStore = scheduler.NewMemoryStore()
+	if store, ok := queue.(domain.ScheduleStore); ok {
+		schedules = store
+	}
+	tasks := scheduler.New(schedules, logger)
+
+	// Cleanup (run every hour) on the nodes that write results
+	if runsWorkers {
+		cleaner := cleanup.NewCleaner(queue, storage, cfg.Storage.JobRetentionHours, cleanupMetrics, logger)
+		cleaner.RetainArtifacts(artifactRetention(cfg))
+		cleaner.OnExpired(func(ctx context.Context, job *domain.Job) {
+			jobMetrics.Finished(job.Status)
+		})
+		if archive, ok := queue.(domain.JobArchive); ok && cfg.Storage.ArchiveAfterHours > 0 {
+			cleaner.ArchiveAfter(archive, time.Duration(cfg.Storage.ArchiveAfterHours)*time.Hour)
+		}
+		tasks.Every("cleanup", 1*time.Hour, cleaner.Run)
+	}
+	tasks.Start(ctx)
+
+	// The result cache directory may be this instance's own, so every instance
+	// removes its expired entries
+	if resultCacheDir != nil {
+		local := scheduler.New(scheduler.NewMemoryStore(), logger)
+		local.Every("result-cache", 1*time.Hour, resultCacheDir.RemoveExpired)
+		local.Start(ctx)
+	}
+
+	// Access control
+	apiKeys, ipRules, proxies, err := buildAccessControl(cfg)
+	if err != nil {
+		logger.Fatal("Invalid access control configuration", zap.Error(err))
+	}
+	if len(apiKeys) > 0 {
+		logger.Info("API key authentication enabled", zap.Int("keys", len(apiKeys)))
+	}
+
+	// Keys whose usage turns unusual are flagged, alerted on and held back until
+	// an admin releases them
+	var abuseDetector *abuse.Detector
+	if cfg.Abuse.Enabled {
+		abuseDetector = abuse.NewDetector(abuse.Config{
+			Window:               cfg.Abuse.Window,
+			BaselineWindows:      cfg.Abuse.BaselineWindows,
+			VolumeFactor:         cfg.Abuse.VolumeFactor,
+			MinVolumeChars:       cfg.Abuse.MinVolumeChars,
+			MaxIdenticalRequests: cfg.Abuse.MaxIdenticalRequests,
+			MaxVoices:            cfg.Abuse.MaxVoices,
+			Action:               cfg.Abuse.Action,
+			ThrottlePerMinute:    cfg.Abuse.ThrottlePerMinute,
+		})
+		abuseDetector.OnFlag(func(flag abuse.Flag) {
+			logger.Warn("API key flagged for unusual usage",
+				zap.Bool("alert", true),
+				zap.String("api_key", flag.Key),
+				zap.String("reason", flag.Reason),
+				zap.String("detail", flag.Detail),
+				zap.String("action", flag.Action),
+			)
+			abuseMetrics.Flagged(flag.Reason)
+			webhookDispatcher.KeyFlagged(flag.Key, flag.Reason, flag.Detail, flag.Action)
+		})
+		if len(apiKeys) == 0 {
+			logger.Warn("Abuse detection is enabled but has no API keys to watch")
+		}
+	}
+
+	// Jobs may name a text source only with the feature on; workers still resolve
+	// the sources of jobs already queued
+	var jobTextSources domain.TextSourceResolver
+	if cfg.Features.TextSources {
+		jobTextSources = textSources
+	}
+	logger.Info("API features",
+		zap.Bool("sync_tts", cfg.Features.SyncTTS),
+		zap.Bool("async_jobs", cfg.Features.AsyncJobs),
+		zap.Bool("admin", cfg.Features.Admin),
+		zap.Bool("text_sources", cfg.Features.TextSources),
+		zap.Bool("ui", cfg.Features.UI),
+		zap.Bool("job_search", cfg.Features.JobSearch),
+	)
 
-	// Start cleanup scheduler (run every hour)
-	storage.StartCleanupScheduler(ctx, cfg.Storage.JobRetentionHours, 1*time.Hour)
+	// Job search, from queues that index their jobs
+	var jobSearch domain.JobSearch
+	if search, ok := queue.(domain.JobSearch); ok && cfg.Features.JobSearch {
+		jobSearch = search
+	}
 
 	// Setup router
-	router := api.NewRouter(&api.RouterDeps{
-		Logger:           logger,
-		ProviderRegistry: providerRegistry,
-		Queue:            queue,
-		Storage:          storage,
-		SyncTimeout:      cfg.TTS.SyncTimeout,
-		MaxSyncTextLen:   cfg.TTS.MaxSyncTextLength,
-		DefaultVoiceID:   cfg.TTS.DefaultVoiceID,
-		RetentionHours:   cfg.Storage.JobRetentionHours,
-		OpenAPISpec:      openAPISpec,
-	})
+	routerDeps := &api.RouterDeps{
+		Logger:             logger,
+		ProviderRegistry:   providerRegistry,
+		Queue:              queue,
+		Storage:            storage,
+		SyncTimeout:        cfg.TTS.SyncTimeout,
+		AsyncFallback:      cfg.TTS.AsyncFallback,
+		MaxSyncTextLen:     cfg.TTS.MaxSyncTextLength,
+		DefaultVoiceID:     cfg.TTS.DefaultVoiceID,
+		RetentionHours:     cfg.Storage.JobRetentionHours,
+		ArtifactRetention:  artifactRetention(cfg),
+		ResultURLs:         signedURLs,
+		SignedURLTTL:       cfg.Storage.SignedURLTTL,
+		OpenAPISpec:        openAPISpec,
+		VoicesCacheTTL:     cfg.TTS.VoicesCacheTTL,
+		APIKeys:            apiKeys,
+		IPRules:            ipRules,
+		TrustedProxies:     proxies,
+		AdminKey:           cfg.Auth.AdminKey,
+		KeyManager:         providerRegistry,
+		Dedup:              dedup.New(cfg.Queue.DedupMode, cfg.Queue.DedupWindow),
+		RegenerateGrace:    time.Duration(cfg.Storage.RegenerateGraceHours) * time.Hour,
+		ClampVoiceSettings: cfg.TTS.OutOfRangeSettings == config.SettingsClamp,
+		Metrics:            metricsRegistry,
+		TextSources:        jobTextSources,
+		WorkerPools:        workerPools,
+		EffectiveConfig:    cfg.Redacted(),
+		SpeechCache:        speechCache,
+		ResultCache:        resultCache,
+		ResultCacheMetrics: resultCacheMetrics,
+		Webhooks:           tenantWebhooks,
+		WebhookDispatcher:  webhookDispatcher,
+		Drainer:            drainer,
+		JobSearch:          jobSearch,
+		Bulk:               bulk.NewRunner(ctx, queue, storage, providerRegistry, logger),
+		Abuse:              abuseDetector,
+		AbuseMetrics:       abuseMetrics,
+		Features: &api.Features{
+			SyncTTS:   cfg.Features.SyncTTS,
+			AsyncJobs: cfg.Features.AsyncJobs,
+			Admin:     cfg.Features.Admin,
+			UI:        cfg.Features.UI,
+		},
+	}
+	var router http.Handler
+	if servesAPI {
+		router = api.NewRouter(routerDeps)
+	} else {
+		router = api.NewWorkerRouter(routerDeps)
+	}
+
+	// Job requests read off a message queue are submitted through the API, so
+	// only the nodes serving it consume them
+	if servesAPI && cfg.Consumer.Backend != "" {
+		broker, err := openConsumer(ctx, cfg)
+		if err != nil {
+			logger.Fatal("Failed to connect to the message queue", zap.Error(err))
+		}
+		consumer.New(broker, router, queue, logger, consumer.Options{
+			APIKey:       cfg.Consumer.APIKey,
+			ReplyTopic:   cfg.Consumer.ReplyTopic,
+			PublicURL:    cfg.Consumer.PublicURL,
+			PollInterval: cfg.Consumer.PollInterval,
+			ResultURLs:   signedURLs,
+			SignedURLTTL: cfg.Storage.SignedURLTTL,
+			Name:         consumerName(),
+		}).Start(ctx)
+		logger.Info("Message queue consumer started",
+			zap.String("backend", cfg.Consumer.Backend),
+			zap.String("topic", cfg.Consumer.Topic),
+		)
+	}
 
 	// Setup HTTP server
 	server := &http.Server{
@@ -118,6 +474,15 @@ func main() {
 
 	logger.Info("Shutting down server...")
 
+	// Stop workers once they finished the jobs in progress; those still running
+	// at queue.drain_timeout are queued again for another instance. Meanwhile
+	// the API answers new jobs with 503 DRAINING, and clients can still poll and
+	// download the jobs finishing.
+	if drainer != nil {
+		drainer.Drain(domain.DrainShutdown, cfg.Queue.DrainTimeout) //nolint:errcheck
+		<-drainer.Drained()
+	}
+
 	// Graceful shutdown with timeout
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer shutdownCancel()
@@ -126,13 +491,77 @@ func main() {
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		logger.Error("Server shutdown error", zap.Error(err))
 	}
-
-	// Stop workers
 	cancel()
 	worker.Stop()
 
-	// Close queue
-	queue.Close() //nolint:errcheck
+	// Keep the jobs still queued for the next start, then close the queue
+	persistJobs(cfg, queue, logger)
+	closeQueue() //nolint:errcheck
 
 	logger.Info("Server stopped")
 }
+
+// buildAccessControl converts the auth and ip_filter config into middleware settings.
+func buildAccessControl(cfg *config.Config) ([]apimiddleware.APIKey, *apimiddleware.IPRules, *apimiddleware.TrustedProxies, error) {
+	ipRules, err := apimiddleware.ParseIPRules(cfg.IPFilter.AllowCIDRs, cfg.IPFilter.DenyCIDRs)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("ip_filter: %w", err)
+	}
+	proxies, err := apimiddleware.ParseTrustedProxies(cfg.IPFilter.TrustedProxies)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("ip_filter.trusted_proxies: %w", err)
+	}
+
+	apiKeys := make([]apimiddleware.APIKey, 0, len(cfg.Auth.APIKeys))
+	for _, k := range cfg.Auth.APIKeys {
+		if k.Key == "" {
+			return nil, nil, nil, fmt.Errorf("auth.api_keys: key %q is empty", k.Name)
+		}
+		rules, err := apimiddleware.ParseIPRules(k.AllowCIDRs, k.DenyCIDRs)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("auth.api_keys %q: %w", k.Name, err)
+		}
+		if k.OutputFormat != "" && !transcode.IsOutputFormat(k.OutputFormat) {
+			return nil, nil, nil, fmt.Errorf("auth.api_keys %q: output_format must be one of %s", k.Name, strings.Join(transcode.OutputFormats, ", "))
+		}
+		textRules := textinfo.Rules{
+			Scripts:      k.TextRules.AllowedScripts,
+			AllowedChars: k.TextRules.AllowedChars,
+			DeniedChars:  k.TextRules.DeniedChars,
+			MaxRepeat:    k.TextRules.MaxRepeat,
+		}
+		if err := textRules.Validate(); err != nil {
+			return nil, nil, nil, fmt.Errorf("auth.api_keys %q: text_rules: %w", k.Name, err)
+		}
+		apiKeys = append(apiKeys, apimiddleware.APIKey{Name: k.Name, Key: k.Key, Rules: rules, OutputFormat: k.OutputFormat, TextRules: textRules})
+	}
+
+	return apiKeys, ipRules, proxies, nil
+}
+
+// refreshSecrets re-reads the secret store every RefreshInterval and pushes changed
+// provider keys into the running providers.
+func refreshSecrets(ctx context.Context, cfg *config.Config, providers *registry.Registry, logger *zap.Logger) {
+	ticker := time.NewTicker(cfg.Secrets.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := cfg.RefreshSecrets(ctx)
+			if err != nil {
+				logger.Warn("Failed to refresh secrets; keeping current keys", zap.Error(err))
+				continue
+			}
+			for name, key := range changed {
+				if err := providers.SetAPIKey(name, key); err != nil {
+					logger.Warn("Failed to apply refreshed API key", zap.String("provider", name), zap.Error(err))
+					continue
+				}
+				logger.Info("Provider API key rotated", zap.String("provider", name))
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	// Registers the "pgx" database/sql driver used by the postgres backend
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/queue/memory"
+	"github.com/pako-tts/server/internal/queue/postgres"
+	"github.com/pako-tts/server/pkg/config"
+)
+
+// openQueue creates the job queue selected by queue.backend. The returned close
+// function releases the queue and anything it opened.
+func openQueue(cfg *config.Config, logger *zap.Logger) (memory.JobSource, func() error, error) {
+	if cfg.Queue.Backend != config.QueueBackendPostgres {
+		queue := memory.NewQueueWithOptions(cfg.Queue.MaxConcurrentJobs, memory.Options{
+			EnqueueWait:       cfg.Queue.EnqueueWait,
+			TenantMaxInFlight: cfg.Queue.TenantMaxInFlight,
+			MaxCharsInFlight:  cfg.Queue.MaxCharsInFlight,
+			VisibilityTimeout: cfg.Queue.VisibilityTimeout,
+			MaxDeliveries:     cfg.Queue.MaxDeliveries,
+			Search:            cfg.Features.JobSearch,
+		})
+		return queue, queue.Close, nil
+	}
+
+	pg := cfg.Queue.Postgres
+	if pg.DSN == "" {
+		return nil, nil, fmt.Errorf("queue.postgres.dsn is required for the postgres queue backend")
+	}
+	if !slices.Contains(sql.Drivers(), pg.Driver) {
+		return nil, nil, fmt.Errorf("database driver %q is not linked into this build (available: %v); "+
+			"add a blank import of a Postgres driver, e.g. github.com/jackc/pgx/v5/stdlib", pg.Driver, sql.Drivers())
+	}
+	db, err := sql.Open(pg.Driver, pg.DSN)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open postgres: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	queue := postgres.New(db, postgres.Options{
+		Capacity:          cfg.Queue.MaxConcurrentJobs,
+		VisibilityTimeout: cfg.Queue.VisibilityTimeout,
+		MaxDeliveries:     cfg.Queue.MaxDeliveries,
+		PollInterval:      pg.PollInterval,
+		SearchIndex:       cfg.Features.JobSearch,
+	})
+	if err := queue.Migrate(ctx); err != nil {
+		db.Close() //nolint:errcheck
+		return nil, nil, err
+	}
+	if cfg.Queue.TenantMaxInFlight > 0 || cfg.Queue.MaxCharsInFlight > 0 {
+		logger.Warn("queue.tenant_max_in_flight and queue.max_chars_in_flight are not applied by the postgres queue backend")
+	}
+
+	closeQueue := func() error {
+		queue.Close() //nolint:errcheck
+		return db.Close()
+	}
+	return queue, closeQueue, nil
+}
+
+// dequeueStrategy returns how workers wait for jobs from queue, as selected by
+// queue.dequeue.
+func dequeueStrategy(cfg *config.Config, queue memory.JobSource) (memory.DequeueStrategy, error) {
+	backoff := memory.Backoff{Min: cfg.Queue.Postgres.PollInterval, Max: cfg.Queue.Postgres.MaxPollInterval}
+	switch cfg.Queue.Dequeue {
+	case config.DequeuePolling:
+		if poller, ok := queue.(memory.Poller); ok {
+			return memory.Polling(poller, backoff), nil
+		}
+	case config.DequeueBatch:
+		if poller, ok := queue.(memory.BatchPoller); ok {
+			return memory.Batch(poller, cfg.Queue.Postgres.BatchSize, backoff), nil
+		}
+	default:
+		return memory.Blocking(queue), nil
+	}
+	return nil, fmt.Errorf("the %s queue backend doesn't support queue.dequeue %q", cfg.Queue.Backend, cfg.Queue.Dequeue)
+}
+
+// restoreJobs queues the jobs the memory backend persisted to queue.persist_path
+// on its last shutdown again.
+func restoreJobs(cfg *config.Config, queue memory.JobSource, logger *zap.Logger) {
+	if cfg.Queue.PersistPath == "" {
+		return
+	}
+	mq, ok := queue.(*memory.Queue)
+	if !ok {
+		logger.Warn("queue.persist_path is not applied by the postgres queue backend, which keeps its jobs itself")
+		return
+	}
+	restored, err := mq.Restore(context.Background(), cfg.Queue.PersistPath)
+	if err != nil {
+		logger.Error("Failed to restore persisted jobs", zap.String("path", cfg.Queue.PersistPath), zap.Error(err))
+	}
+	if restored > 0 {
+		logger.Info("Persisted jobs queued again", zap.Int("jobs", restored), zap.String("path", cfg.Queue.PersistPath))
+	}
+}
+
+// persistJobs writes the memory backend's unfinished jobs to queue.persist_path
+// once the workers stopped.
+func persistJobs(cfg *config.Config, queue memory.JobSource, logger *zap.Logger) {
+	mq, ok := queue.(*memory.Queue)
+	if cfg.Queue.PersistPath == "" || !ok {
+		return
+	}
+	persisted, err := mq.Persist(cfg.Queue.PersistPath)
+	if err != nil {
+		logger.Error("Failed to persist unfinished jobs", zap.String("path", cfg.Queue.PersistPath), zap.Error(err))
+		return
+	}
+	if persisted > 0 {
+		logger.Info("Unfinished jobs persisted", zap.Int("jobs", persisted), zap.String("path", cfg.Queue.PersistPath))
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/storage/cleanup"
+	"github.com/pako-tts/server/internal/storage/filesystem"
+	"github.com/pako-tts/server/internal/storage/gcs"
+	"github.com/pako-tts/server/internal/storage/keys"
+	"github.com/pako-tts/server/pkg/config"
+)
+
+// resultStorage is what the server needs of a storage backend: serving results,
+// and deleting them on retention cleanup.
+type resultStorage interface {
+	domain.AudioStorage
+	cleanup.Storage
+}
+
+// openStorage creates the result storage selected by storage.backend, and
+// returns it with where it keeps results.
+func openStorage(ctx context.Context, cfg *config.Config, logger *zap.Logger) (resultStorage, string, error) {
+	keyStrategy, err := keys.Parse(cfg.Storage.KeyStrategy)
+	if err != nil {
+		return nil, "", err
+	}
+	if cfg.Storage.Backend != config.StorageBackendGCS {
+		storage, err := filesystem.NewStorageWithKeys(cfg.Storage.AudioStoragePath, keyStrategy, logger)
+		return storage, cfg.Storage.AudioStoragePath, err
+	}
+
+	storage, err := gcs.NewStorage(ctx, gcs.Config{
+		Bucket:          cfg.Storage.GCS.Bucket,
+		Prefix:          cfg.Storage.GCS.Prefix,
+		CredentialsFile: cfg.Storage.GCS.CredentialsFile,
+		Endpoint:        cfg.Storage.GCS.Endpoint,
+	}, keyStrategy, logger)
+	if err != nil {
+		return nil, "", err
+	}
+	return storage, storage.URL(), nil
+}
+
+// artifactRetention returns storage.artifact_retention by artifact kind,
+// leaving out the kinds kept as long as the result.
+func artifactRetention(cfg *config.Config) map[string]time.Duration {
+	retention := make(map[string]time.Duration)
+	for kind, hours := range map[string]int{
+		domain.ArtifactKindPreview:  cfg.Storage.ArtifactRetention.PreviewHours,
+		domain.ArtifactKindWaveform: cfg.Storage.ArtifactRetention.WaveformHours,
+		domain.ArtifactKindVariant:  cfg.Storage.ArtifactRetention.VariantHours,
+	} {
+		if hours > 0 {
+			retention[kind] = time.Duration(hours) * time.Hour
+		}
+	}
+	return retention
+}
+
+// resultURLs returns what signs result download URLs when
+// storage.redirect_results is on, or an error when storage can't sign them.
+func resultURLs(cfg *config.Config, storage domain.AudioStorage) (domain.SignedURLs, error) {
+	if !cfg.Storage.RedirectResults {
+		return nil, nil
+	}
+	urls, ok := storage.(domain.SignedURLs)
+	if !ok || !urls.CanSignURLs() {
+		return nil, errors.New("storage.redirect_results needs a service account key to sign URLs with")
+	}
+	return urls, nil
+}
@@ -0,0 +1,265 @@
+// Package abuse flags API keys whose usage departs from their usual pattern,
+// such as a leaked key spending provider quota, and holds them back until an
+// admin has reviewed them.
+package abuse
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Reasons a key is flagged for.
+const (
+	// ReasonVolumeSpike is a window's characters far above the key's baseline.
+	ReasonVolumeSpike = "volume_spike"
+	// ReasonIdenticalRequests is the same text and voice sent over and over.
+	ReasonIdenticalRequests = "identical_requests"
+	// ReasonVoiceChurn is an unusual number of distinct voices in a window.
+	ReasonVoiceChurn = "voice_churn"
+)
+
+// Actions taken on a flagged key.
+const (
+	// ActionLog only raises the alert.
+	ActionLog = "log"
+	// ActionThrottle limits the key's submissions to ThrottlePerMinute.
+	ActionThrottle = "throttle"
+	// ActionQuarantine rejects every request of the key.
+	ActionQuarantine = "quarantine"
+)
+
+// maxTrackedTexts bounds the distinct texts and voices counted per key and window.
+const maxTrackedTexts = 10000
+
+// Config sets what counts as unusual and what happens to a flagged key.
+type Config struct {
+	// Window is the period usage is counted over.
+	Window time.Duration
+	// BaselineWindows is how many earlier windows a key's usual volume is
+	// averaged over.
+	BaselineWindows int
+	// VolumeFactor flags a key whose characters in a window exceed its baseline
+	// this many times; 0 disables the check.
+	VolumeFactor float64
+	// MinVolumeChars is the window volume below which no spike is flagged.
+	MinVolumeChars int64
+	// MaxIdenticalRequests flags a key sending one text with one voice more often
+	// in a window; 0 disables the check.
+	MaxIdenticalRequests int
+	// MaxVoices flags a key using more distinct voices in a window; 0 disables
+	// the check.
+	MaxVoices int
+	// Action is ActionLog, ActionThrottle or ActionQuarantine.
+	Action string
+	// ThrottlePerMinute is how many submissions a throttled key may send a minute.
+	ThrottlePerMinute int
+}
+
+// Flag records why and since when a key is held back.
+type Flag struct {
+	Key       string    `json:"key"`
+	Reason    string    `json:"reason"`
+	Detail    string    `json:"detail"`
+	Action    string    `json:"action"`
+	FlaggedAt time.Time `json:"flagged_at"`
+}
+
+// Detector tracks the usage of each API key over fixed windows.
+type Detector struct {
+	cfg    Config
+	now    func() time.Time
+	onFlag func(Flag)
+
+	mu    sync.Mutex
+	usage map[string]*usage
+	flags map[string]*flagState
+}
+
+// usage is one key's counts in the current window and its earlier volume.
+type usage struct {
+	start   time.Time
+	chars   int64
+	history []int64 // characters of earlier windows, oldest first
+	texts   map[uint64]int
+	voices  map[string]bool
+}
+
+type flagState struct {
+	Flag
+	// nextAdmit is when a throttled key may submit again.
+	nextAdmit time.Time
+}
+
+// NewDetector creates a detector.
+func NewDetector(cfg Config) *Detector {
+	return &Detector{
+		cfg:   cfg,
+		now:   time.Now,
+		usage: make(map[string]*usage),
+		flags: make(map[string]*flagState),
+	}
+}
+
+// OnFlag registers fn to be called when a key is flagged. It must be set
+// before the detector is used.
+func (d *Detector) OnFlag(fn func(Flag)) {
+	d.onFlag = fn
+}
+
+// Observe records a request of key to synthesize text with voiceID, and flags
+// the key when its usage has become unusual.
+func (d *Detector) Observe(key, voiceID, text string) {
+	d.mu.Lock()
+	now := d.now()
+	u := d.usage[key]
+	if u == nil {
+		u = &usage{start: now}
+		d.usage[key] = u
+	}
+	u.roll(now, d.cfg.Window, d.cfg.BaselineWindows)
+	repeats := u.record(voiceID, text)
+
+	var flag *Flag
+	if _, flagged := d.flags[key]; !flagged {
+		if reason, detail := d.check(u, repeats); reason != "" {
+			f := &flagState{Flag: Flag{Key: key, Reason: reason, Detail: detail, Action: d.cfg.Action, FlaggedAt: now.UTC()}}
+			d.flags[key] = f
+			flag = &f.Flag
+		}
+	}
+	d.mu.Unlock()
+
+	if flag != nil && d.onFlag != nil {
+		d.onFlag(*flag)
+	}
+}
+
+// check returns the first rule u breaks, with a description for admins.
+// repeats is how often the latest request's text was sent in the window.
+func (d *Detector) check(u *usage, repeats int) (reason, detail string) {
+	if d.cfg.MaxIdenticalRequests > 0 && repeats > d.cfg.MaxIdenticalRequests {
+		return ReasonIdenticalRequests, fmt.Sprintf("the same text was sent %d times in %s", repeats, d.cfg.Window)
+	}
+	if d.cfg.MaxVoices > 0 && len(u.voices) > d.cfg.MaxVoices {
+		return ReasonVoiceChurn, fmt.Sprintf("%d distinct voices were used in %s", len(u.voices), d.cfg.Window)
+	}
+	if d.cfg.VolumeFactor > 0 && len(u.history) > 0 && u.chars > d.cfg.MinVolumeChars {
+		var sum int64
+		for _, n := range u.history {
+			sum += n
+		}
+		baseline := float64(sum) / float64(len(u.history))
+		if float64(u.chars) > d.cfg.VolumeFactor*baseline {
+			return ReasonVolumeSpike, fmt.Sprintf("%d characters in %s against a baseline of %.0f", u.chars, d.cfg.Window, baseline)
+		}
+	}
+	return "", ""
+}
+
+// Admit reports whether a request of key may proceed, with the key's flag when
+// it has one. Quarantined keys are refused; throttled keys are refused
+// submissions beyond their rate, with how long until the next one is admitted,
+// while their other requests pass.
+func (d *Detector) Admit(key string, submission bool) (*Flag, time.Duration, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	f, ok := d.flags[key]
+	if !ok {
+		return nil, 0, true
+	}
+	flag := f.Flag
+	switch f.Action {
+	case ActionQuarantine:
+		return &flag, 0, false
+	case ActionThrottle:
+		if !submission {
+			return &flag, 0, true
+		}
+		now := d.now()
+		if now.Before(f.nextAdmit) {
+			return &flag, f.nextAdmit.Sub(now), false
+		}
+		f.nextAdmit = now.Add(time.Minute / time.Duration(max(d.cfg.ThrottlePerMinute, 1)))
+	}
+	return &flag, 0, true
+}
+
+// Flags returns the flagged keys, oldest flag first.
+func (d *Detector) Flags() []Flag {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	flags := make([]Flag, 0, len(d.flags))
+	for _, f := range d.flags {
+		flags = append(flags, f.Flag)
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i].FlaggedAt.Before(flags[j].FlaggedAt) })
+	return flags
+}
+
+// Release lifts the flag of key after review, reporting whether it had one. The
+// key's counts for the current window start over, so the usage that flagged it
+// doesn't flag it again at once.
+func (d *Detector) Release(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.flags[key]; !ok {
+		return false
+	}
+	delete(d.flags, key)
+	if u := d.usage[key]; u != nil {
+		u.chars, u.texts, u.voices = 0, nil, nil
+	}
+	return true
+}
+
+// roll moves u to the window now falls in, keeping the volume of at most keep
+// earlier windows. Windows without requests count as empty.
+func (u *usage) roll(now time.Time, window time.Duration, keep int) {
+	elapsed := int(now.Sub(u.start) / window)
+	if elapsed <= 0 {
+		return
+	}
+	u.history = append(u.history, u.chars)
+	for i := 1; i < min(elapsed, keep+1); i++ {
+		u.history = append(u.history, 0)
+	}
+	if len(u.history) > keep {
+		u.history = u.history[len(u.history)-keep:]
+	}
+	u.start = u.start.Add(time.Duration(elapsed) * window)
+	u.chars, u.texts, u.voices = 0, nil, nil
+}
+
+// record counts a request, returning how often its text and voice were sent in
+// the window. Past maxTrackedTexts distinct texts or voices, new ones aren't told
+// apart any more.
+func (u *usage) record(voiceID, text string) int {
+	u.chars += int64(utf8.RuneCountInString(text))
+
+	if u.voices == nil {
+		u.voices = make(map[string]bool)
+	}
+	if len(u.voices) < maxTrackedTexts {
+		u.voices[voiceID] = true
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(voiceID)) //nolint:errcheck
+	h.Write([]byte{0})       //nolint:errcheck
+	h.Write([]byte(text))    //nolint:errcheck
+	sum := h.Sum64()
+	if u.texts == nil {
+		u.texts = make(map[uint64]int)
+	}
+	if _, ok := u.texts[sum]; ok || len(u.texts) < maxTrackedTexts {
+		u.texts[sum]++
+	}
+	return u.texts[sum]
+}
@@ -0,0 +1,122 @@
+package abuse
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestDetector(cfg Config) (*Detector, *time.Time, *[]Flag) {
+	if cfg.Window == 0 {
+		cfg.Window = time.Hour
+	}
+	d := NewDetector(cfg)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	d.now = func() time.Time { return now }
+	var flagged []Flag
+	d.OnFlag(func(f Flag) { flagged = append(flagged, f) })
+	return d, &now, &flagged
+}
+
+func TestDetector_FlagsVolumeSpike(t *testing.T) {
+	d, now, flagged := newTestDetector(Config{BaselineWindows: 3, VolumeFactor: 100, MinVolumeChars: 5000, Action: ActionLog})
+
+	// A usual hour: 100 characters
+	for i := range 10 {
+		d.Observe("acme", "voice", fmt.Sprintf("text %5d", i))
+	}
+	*now = now.Add(time.Hour)
+	d.Observe("acme", "voice", strings.Repeat("a", 5000))
+	if len(*flagged) != 0 {
+		t.Fatalf("expected no flag within 100x, got %+v", *flagged)
+	}
+	d.Observe("acme", "voice", strings.Repeat("b", 6000))
+	if len(*flagged) != 1 || (*flagged)[0].Reason != ReasonVolumeSpike || (*flagged)[0].Key != "acme" {
+		t.Fatalf("expected a volume spike, got %+v", *flagged)
+	}
+
+	// A key without history isn't judged on volume
+	d.Observe("new", "voice", strings.Repeat("c", 100000))
+	if len(*flagged) != 1 {
+		t.Errorf("expected a new key not flagged, got %+v", *flagged)
+	}
+}
+
+func TestDetector_FlagsIdenticalRequestsAndVoiceChurn(t *testing.T) {
+	d, now, flagged := newTestDetector(Config{MaxIdenticalRequests: 3, MaxVoices: 2, Action: ActionLog})
+
+	for range 3 {
+		d.Observe("a", "voice", "Hello")
+	}
+	if len(*flagged) != 0 {
+		t.Fatalf("expected no flag at the limit, got %+v", *flagged)
+	}
+	d.Observe("a", "voice", "Hello")
+	if len(*flagged) != 1 || (*flagged)[0].Reason != ReasonIdenticalRequests {
+		t.Fatalf("expected identical requests flagged, got %+v", *flagged)
+	}
+
+	// Counts start over in the next window
+	*now = now.Add(time.Hour)
+	for _, voice := range []string{"v1", "v2", "v1"} {
+		d.Observe("b", voice, "Hello "+voice)
+	}
+	*now = now.Add(time.Hour)
+	for _, voice := range []string{"v1", "v2", "v3"} {
+		d.Observe("b", voice, "Hello "+voice)
+	}
+	if len(*flagged) != 2 || (*flagged)[1].Reason != ReasonVoiceChurn || (*flagged)[1].Key != "b" {
+		t.Fatalf("expected voice churn flagged, got %+v", *flagged)
+	}
+}
+
+func TestDetector_AdmitAndRelease(t *testing.T) {
+	for _, tt := range []struct {
+		action                    string
+		submission, status, after bool
+	}{
+		{ActionLog, true, true, true},
+		{ActionQuarantine, false, false, false},
+		{ActionThrottle, true, true, false},
+	} {
+		t.Run(tt.action, func(t *testing.T) {
+			d, now, _ := newTestDetector(Config{MaxIdenticalRequests: 1, Action: tt.action, ThrottlePerMinute: 2})
+			if _, _, ok := d.Admit("a", true); !ok {
+				t.Fatal("expected an unflagged key admitted")
+			}
+			d.Observe("a", "voice", "spam")
+			d.Observe("a", "voice", "spam")
+
+			if flag, _, ok := d.Admit("a", true); flag == nil || ok != tt.submission {
+				t.Errorf("expected submission admitted=%v, got %v (%+v)", tt.submission, ok, flag)
+			}
+			if _, _, ok := d.Admit("a", false); ok != tt.status {
+				t.Errorf("expected other requests admitted=%v, got %v", tt.status, ok)
+			}
+			if _, wait, ok := d.Admit("a", true); ok != tt.after || (tt.action == ActionThrottle && wait != 30*time.Second) {
+				t.Errorf("expected the next submission admitted=%v, got %v (wait %s)", tt.after, ok, wait)
+			}
+			if tt.action == ActionThrottle {
+				*now = now.Add(30 * time.Second)
+				if _, _, ok := d.Admit("a", true); !ok {
+					t.Error("expected a throttled key admitted after its interval")
+				}
+			}
+
+			if flags := d.Flags(); len(flags) != 1 || flags[0].Action != tt.action {
+				t.Errorf("expected the flag listed, got %+v", flags)
+			}
+			if !d.Release("a") || d.Release("a") {
+				t.Error("expected the flag released once")
+			}
+			if flag, _, ok := d.Admit("a", true); flag != nil || !ok {
+				t.Errorf("expected a released key admitted, got %+v", flag)
+			}
+			d.Observe("a", "voice", "spam")
+			if len(d.Flags()) != 0 {
+				t.Error("expected the counts to start over on release")
+			}
+		})
+	}
+}
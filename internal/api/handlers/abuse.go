@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/abuse"
+	"github.com/pako-tts/server/internal/api/middleware"
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// AbuseHandler lets admins review the API keys flagged for unusual usage.
+type AbuseHandler struct {
+	detector *abuse.Detector
+	logger   *zap.Logger
+}
+
+// NewAbuseHandler creates a new abuse handler.
+func NewAbuseHandler(detector *abuse.Detector, logger *zap.Logger) *AbuseHandler {
+	return &AbuseHandler{detector: detector, logger: logger}
+}
+
+// AbuseFlagListResponse lists the flagged API keys.
+type AbuseFlagListResponse struct {
+	Flags []abuse.Flag `json:"flags"`
+}
+
+// ListFlags handles GET /api/v1/admin/abuse/flags.
+func (h *AbuseHandler) ListFlags(w http.ResponseWriter, r *http.Request) {
+	middleware.WriteJSON(w, http.StatusOK, AbuseFlagListResponse{Flags: h.detector.Flags()})
+}
+
+// ReleaseFlag handles DELETE /api/v1/admin/abuse/flags/{key}, lifting the flag
+// of an API key, by name, after review.
+func (h *AbuseHandler) ReleaseFlag(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "key")
+	if !h.detector.Release(key) {
+		middleware.WriteError(w, domain.ErrFlagNotFound)
+		return
+	}
+	h.logger.Info("Abuse flag released", zap.String("api_key", key))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/pako-tts/server/internal/abuse"
+)
+
+func TestAbuseHandler_ListAndRelease(t *testing.T) {
+	detector := abuse.NewDetector(abuse.Config{Window: time.Hour, MaxVoices: 1, Action: abuse.ActionQuarantine})
+	detector.Observe("acme", "v1", "Hello")
+	detector.Observe("acme", "v2", "Hello")
+	h := NewAbuseHandler(detector, testLogger())
+
+	rec := httptest.NewRecorder()
+	h.ListFlags(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/abuse/flags", nil))
+	var list AbuseFlagListResponse
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(list.Flags) != 1 || list.Flags[0].Key != "acme" || list.Flags[0].Reason != abuse.ReasonVoiceChurn {
+		t.Fatalf("expected the key listed, got %+v", list.Flags)
+	}
+
+	release := func(key string) int {
+		req := httptest.NewRequest(http.MethodDelete, "/api/v1/admin/abuse/flags/"+key, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("key", key)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rec := httptest.NewRecorder()
+		h.ReleaseFlag(rec, req)
+		return rec.Code
+	}
+	if code := release("acme"); code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", code)
+	}
+	if code := release("acme"); code != http.StatusNotFound {
+		t.Errorf("expected 404 once released, got %d", code)
+	}
+	if _, _, ok := detector.Admit("acme", true); !ok {
+		t.Error("expected the released key admitted")
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// formatMediaTypes lists the media types an Accept header can ask for each audio
+// format by, the canonical one first.
+var formatMediaTypes = map[string][]string{
+	"mp3":      {"audio/mpeg", "audio/mp3"},
+	"wav":      {"audio/wav", "audio/x-wav", "audio/wave"},
+	"ogg":      {"audio/ogg"},
+	"ogg_opus": {"audio/ogg", "audio/opus"},
+	"flac":     {"audio/flac", "audio/x-flac"},
+	"mulaw":    {"audio/basic", "audio/x-mulaw"},
+}
+
+// negotiateFormat picks the audio format of a response from the request's Accept
+// header: the format among preferred and formats with the highest quality value,
+// preferred winning ties and wildcards. Without an Accept header it returns
+// preferred. When Accept rules out every format, it returns ErrNotAcceptable
+// listing the media types that can be served.
+func negotiateFormat(r *http.Request, preferred string, formats ...string) (string, *domain.APIError) {
+	candidates := []string{preferred}
+	for _, format := range formats {
+		if format != preferred {
+			candidates = append(candidates, format)
+		}
+	}
+
+	accept := r.Header.Values("Accept")
+	if len(accept) == 0 {
+		return preferred, nil
+	}
+	ranges := parseAccept(strings.Join(accept, ","))
+
+	best, bestQ := "", 0.0
+	for _, format := range candidates {
+		if q := formatQuality(ranges, format); q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+	if best == "" {
+		var supported []string
+		for _, format := range candidates {
+			if mediaType := formatMediaTypes[format][0]; !slices.Contains(supported, mediaType) {
+				supported = append(supported, mediaType)
+			}
+		}
+		return "", domain.ErrNotAcceptable.WithDetails(map[string]any{"supported": supported})
+	}
+	return best, nil
+}
+
+// mediaRange is one entry of an Accept header.
+type mediaRange struct {
+	mediaType string
+	q         float64
+}
+
+// parseAccept parses an Accept header, skipping malformed entries.
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for _, entry := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(entry))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		ranges = append(ranges, mediaRange{mediaType: mediaType, q: q})
+	}
+	return ranges
+}
+
+// formatQuality returns the quality value the most specific matching range of
+// ranges gives format; 0 when none matches.
+func formatQuality(ranges []mediaRange, format string) float64 {
+	q, specificity := 0.0, 0
+	for _, rng := range ranges {
+		s := 0
+		switch {
+		case rng.mediaType == "*/*":
+			s = 1
+		case rng.mediaType == "audio/*":
+			s = 2
+		default:
+			for _, mediaType := range formatMediaTypes[format] {
+				if rng.mediaType == mediaType {
+					s = 3
+				}
+			}
+		}
+		if s > specificity {
+			q, specificity = rng.q, s
+		}
+	}
+	return q
+}
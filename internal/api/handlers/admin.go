@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/api/middleware"
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// AdminHandler handles operator-only requests.
+type AdminHandler struct {
+	keys       domain.ProviderKeyManager
+	queue      domain.JobQueue
+	pools      domain.WorkerPools
+	config     map[string]any
+	syncSwitch *middleware.SyncSwitch
+	logger     *zap.Logger
+}
+
+// NewAdminHandler creates a new admin handler. pools may be nil when the workers
+// aren't reported. config is the redacted effective configuration. syncSwitch
+// backs the sync endpoint toggle.
+func NewAdminHandler(
+	keys domain.ProviderKeyManager,
+	queue domain.JobQueue,
+	pools domain.WorkerPools,
+	config map[string]any,
+	syncSwitch *middleware.SyncSwitch,
+	logger *zap.Logger,
+) *AdminHandler {
+	return &AdminHandler{
+		keys:       keys,
+		queue:      queue,
+		pools:      pools,
+		config:     config,
+		syncSwitch: syncSwitch,
+		logger:     logger,
+	}
+}
+
+// EffectiveConfig handles GET /api/v1/admin/config. Secrets are redacted.
+func (h *AdminHandler) EffectiveConfig(w http.ResponseWriter, r *http.Request) {
+	middleware.WriteJSON(w, http.StatusOK, h.config)
+}
+
+// QueueStats handles GET /api/v1/admin/queue.
+func (h *AdminHandler) QueueStats(w http.ResponseWriter, r *http.Request) {
+	stats := h.queue.Stats()
+	if h.pools != nil {
+		stats.Pools = h.pools.PoolStats()
+		stats.Workers = domain.NewWorkerUtilization(stats.Pools)
+	}
+	middleware.WriteJSON(w, http.StatusOK, stats)
+}
+
+// SyncSwitchRequest turns the synchronous TTS endpoint on or off.
+type SyncSwitchRequest struct {
+	Enabled *bool  `json:"enabled"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// SyncStatus handles GET /api/v1/admin/sync.
+func (h *AdminHandler) SyncStatus(w http.ResponseWriter, r *http.Request) {
+	middleware.WriteJSON(w, http.StatusOK, h.syncSwitch.Status())
+}
+
+// SetSync handles PUT /api/v1/admin/sync. Disabling it makes POST /api/v1/tts
+// return 503 SYNC_DISABLED; queued and new async jobs keep being processed.
+func (h *AdminHandler) SetSync(w http.ResponseWriter, r *http.Request) {
+	var req SyncSwitchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		middleware.WriteError(w, domain.ErrValidation.WithMessage("Invalid JSON body"))
+		return
+	}
+	if req.Enabled == nil {
+		middleware.WriteError(w, domain.ErrValidation.WithDetails(map[string]any{
+			"field":   "enabled",
+			"message": "enabled is required",
+		}))
+		return
+	}
+
+	status := h.syncSwitch.Set(*req.Enabled, req.Reason)
+	h.logger.Info("Synchronous TTS endpoint switched via admin API",
+		zap.Bool("enabled", status.Enabled),
+		zap.String("reason", status.Reason),
+	)
+	middleware.WriteJSON(w, http.StatusOK, status)
+}
+
+// ProviderKeysListResponse represents the provider key status list.
+type ProviderKeysListResponse struct {
+	Providers []domain.ProviderKeyStatus `json:"providers"`
+}
+
+// ProviderKeysRequest replaces a provider's upstream API keys.
+type ProviderKeysRequest struct {
+	APIKey          string `json:"api_key"`
+	SecondaryAPIKey string `json:"secondary_api_key,omitempty"`
+}
+
+// ListProviderKeys handles GET /api/v1/admin/providers/keys.
+func (h *AdminHandler) ListProviderKeys(w http.ResponseWriter, r *http.Request) {
+	middleware.WriteJSON(w, http.StatusOK, ProviderKeysListResponse{
+		Providers: h.keys.KeyStatus(),
+	})
+}
+
+// SetProviderKeys handles PUT /api/v1/admin/providers/{name}/keys.
+func (h *AdminHandler) SetProviderKeys(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	var req ProviderKeysRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		middleware.WriteError(w, domain.ErrValidation.WithMessage("Invalid JSON body"))
+		return
+	}
+	if req.APIKey == "" {
+		middleware.WriteError(w, domain.ErrValidation.WithDetails(map[string]any{
+			"field":   "api_key",
+			"message": "api_key is required",
+		}))
+		return
+	}
+
+	if err := h.keys.SetAPIKeys(name, req.APIKey, req.SecondaryAPIKey); err != nil {
+		if apiErr, ok := err.(*domain.APIError); ok {
+			middleware.WriteError(w, apiErr)
+		} else {
+			middleware.WriteError(w, domain.ErrInternalServer)
+		}
+		return
+	}
+
+	h.logger.Info("Provider API keys replaced via admin API",
+		zap.String("provider", name),
+		zap.Bool("has_secondary", req.SecondaryAPIKey != ""),
+	)
+
+	for _, status := range h.keys.KeyStatus() {
+		if status.Provider == name {
+			middleware.WriteJSON(w, http.StatusOK, status)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
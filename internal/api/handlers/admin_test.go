@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/pako-tts/server/internal/api/handlers/mocks"
+	"github.com/pako-tts/server/internal/api/middleware"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/queue/memory"
+)
+
+func TestAdminHandler_SetProviderKeys(t *testing.T) {
+	tests := []struct {
+		name          string
+		providerName  string
+		body          string
+		wantStatus    int
+		wantErrorCode string
+	}{
+		{
+			name:         "replaces both keys",
+			providerName: "elevenlabs",
+			body:         `{"api_key":"new-primary","secondary_api_key":"new-secondary"}`,
+			wantStatus:   http.StatusOK,
+		},
+		{
+			name:          "requires api_key",
+			providerName:  "elevenlabs",
+			body:          `{"secondary_api_key":"new-secondary"}`,
+			wantStatus:    http.StatusUnprocessableEntity,
+			wantErrorCode: "VALIDATION_ERROR",
+		},
+		{
+			name:          "unknown provider",
+			providerName:  "missing",
+			body:          `{"api_key":"new-primary"}`,
+			wantStatus:    http.StatusNotFound,
+			wantErrorCode: "PROVIDER_NOT_FOUND",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := mocks.NewMockKeyManager(domain.ProviderKeyStatus{
+				Provider:  "elevenlabs",
+				ActiveKey: domain.KeySecondary,
+			})
+			h := NewAdminHandler(keys, memory.NewQueue(10), nil, nil, nil, testLogger())
+
+			req := httptest.NewRequest(http.MethodPut, "/api/v1/admin/providers/"+tt.providerName+"/keys", strings.NewReader(tt.body))
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("name", tt.providerName)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			rec := httptest.NewRecorder()
+
+			h.SetProviderKeys(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+
+			if tt.wantErrorCode != "" {
+				var resp domain.ErrorResponse
+				if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+					t.Fatalf("decode error: %v", err)
+				}
+				if resp.Error.Code != tt.wantErrorCode {
+					t.Errorf("expected error code %s, got %s", tt.wantErrorCode, resp.Error.Code)
+				}
+				return
+			}
+
+			var status domain.ProviderKeyStatus
+			if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+				t.Fatalf("decode status: %v", err)
+			}
+			if status.ActiveKey != domain.KeyPrimary || !status.HasSecondary {
+				t.Errorf("unexpected status %+v", status)
+			}
+			if got := keys.Keys["elevenlabs"]; got != [2]string{"new-primary", "new-secondary"} {
+				t.Errorf("unexpected keys %v", got)
+			}
+		})
+	}
+}
+
+func TestAdminHandler_QueueStats(t *testing.T) {
+	queue := memory.NewQueue(10)
+	for _, tenant := range []string{"a", "a", "b"} {
+		job := domain.NewJob("Hello", "voice", "", "", "elevenlabs", "mp3", nil)
+		job.TenantID = tenant
+		if err := queue.Enqueue(context.Background(), job); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+	pools := stubPools{
+		{Name: "default", Workers: 3, Busy: 1},
+		{Name: "gemini", Workers: 1, Busy: 1, Providers: []string{"gemini"}},
+	}
+	h := NewAdminHandler(mocks.NewMockKeyManager(), queue, pools, nil, nil, testLogger())
+
+	rec := httptest.NewRecorder()
+	h.QueueStats(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/queue", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var stats domain.QueueStats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatalf("decode stats: %v", err)
+	}
+	if stats.QueuedJobs != 3 || len(stats.Tenants) != 2 || stats.Tenants[0].Tenant != "a" || stats.Tenants[0].Queued != 2 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if stats.OldestQueuedSeconds <= 0 {
+		t.Errorf("expected the age of the oldest queued job, got %v", stats.OldestQueuedSeconds)
+	}
+	if w := stats.Workers; w == nil || w.Total != 4 || w.Busy != 2 || w.Utilization != 0.5 || len(stats.Pools) != 2 {
+		t.Errorf("expected 2 of 4 workers busy over 2 pools, got %+v %+v", w, stats.Pools)
+	}
+}
+
+type stubPools []domain.WorkerPoolStats
+
+func (p stubPools) PoolStats() []domain.WorkerPoolStats { return p }
+
+func TestAdminHandler_SetSync(t *testing.T) {
+	syncSwitch := middleware.NewSyncSwitch()
+	h := NewAdminHandler(mocks.NewMockKeyManager(), memory.NewQueue(10), nil, nil, syncSwitch, testLogger())
+	tts := syncSwitch.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	h.SetSync(rec, httptest.NewRequest(http.MethodPut, "/api/v1/admin/sync", strings.NewReader(`{"reason":"deploy"}`)))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected a missing enabled field to be rejected, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.SetSync(rec, httptest.NewRequest(http.MethodPut, "/api/v1/admin/sync", strings.NewReader(`{"enabled":false,"reason":"deploy"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	tts.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/tts", nil))
+	var resp domain.ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if rec.Code != http.StatusServiceUnavailable || resp.Error.Code != "SYNC_DISABLED" {
+		t.Errorf("expected 503 SYNC_DISABLED while disabled, got %d %s", rec.Code, resp.Error.Code)
+	}
+
+	syncSwitch.Set(true, "")
+	rec = httptest.NewRecorder()
+	tts.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/tts", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected the endpoint to be back after enabling, got %d", rec.Code)
+	}
+}
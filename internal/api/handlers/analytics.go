@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/api/middleware"
+	"github.com/pako-tts/server/internal/domain"
+)
+
+const (
+	// defaultAnalyticsDays is the range reported when ?from= is omitted.
+	defaultAnalyticsDays = 30
+	// maxAnalyticsDays caps the range of one analytics request.
+	maxAnalyticsDays = 366
+)
+
+// AnalyticsHandler reports aggregates over finished jobs.
+type AnalyticsHandler struct {
+	analytics domain.JobAnalytics
+	scoped    bool
+	logger    *zap.Logger
+}
+
+// NewAnalyticsHandler creates a new analytics handler. A scoped handler limits a
+// caller authenticated with an API key to its own tenant; otherwise ?tenant=
+// selects any tenant.
+func NewAnalyticsHandler(analytics domain.JobAnalytics, scoped bool, logger *zap.Logger) *AnalyticsHandler {
+	return &AnalyticsHandler{
+		analytics: analytics,
+		scoped:    scoped,
+		logger:    logger,
+	}
+}
+
+// GetAnalytics handles GET /api/v1/analytics. ?from= and ?to= are inclusive UTC
+// dates (YYYY-MM-DD); by default the last 30 days up to today are reported.
+func (h *AnalyticsHandler) GetAnalytics(w http.ResponseWriter, r *http.Request) {
+	query, apiErr := h.parseQuery(r)
+	if apiErr != nil {
+		middleware.WriteError(w, apiErr)
+		return
+	}
+
+	analytics, err := h.analytics.Analytics(r.Context(), query)
+	if err != nil {
+		h.logger.Error("Failed to compute analytics", zap.Error(err))
+		middleware.WriteError(w, domain.ErrInternalServer)
+		return
+	}
+	middleware.WriteJSON(w, http.StatusOK, analytics)
+}
+
+func (h *AnalyticsHandler) parseQuery(r *http.Request) (domain.AnalyticsQuery, *domain.APIError) {
+	params := r.URL.Query()
+
+	now := time.Now().UTC()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if v := params.Get("to"); v != "" {
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			return domain.AnalyticsQuery{}, invalidDate("to")
+		}
+		to = t
+	}
+	from := to.AddDate(0, 0, -(defaultAnalyticsDays - 1))
+	if v := params.Get("from"); v != "" {
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			return domain.AnalyticsQuery{}, invalidDate("from")
+		}
+		from = t
+	}
+
+	// to is inclusive, so the range ends at the start of the next day
+	end := to.AddDate(0, 0, 1)
+	if !from.Before(end) {
+		return domain.AnalyticsQuery{}, domain.ErrValidation.WithDetails(map[string]any{
+			"field":   "from",
+			"message": "from must not be after to",
+		})
+	}
+	if end.Sub(from) > maxAnalyticsDays*24*time.Hour {
+		return domain.AnalyticsQuery{}, domain.ErrValidation.WithDetails(map[string]any{
+			"field":   "from",
+			"message": "the range must not exceed 366 days",
+		})
+	}
+
+	tenant := params.Get("tenant")
+	if h.scoped {
+		tenant = tenantFilter(r)
+	}
+	return domain.AnalyticsQuery{From: from, To: end, Tenant: tenant}, nil
+}
+
+func invalidDate(field string) *domain.APIError {
+	return domain.ErrValidation.WithDetails(map[string]any{
+		"field":   field,
+		"message": field + " must be a date in YYYY-MM-DD format",
+	})
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pako-tts/server/internal/api/middleware"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/queue/memory"
+)
+
+func TestAnalyticsHandler_GetAnalytics(t *testing.T) {
+	queue := memory.NewQueue(10)
+	ctx := context.Background()
+	for _, tenant := range []string{"acme", "acme", "other"} {
+		job := domain.NewJob("Hello", "voice", "", "", "elevenlabs", "mp3", nil)
+		job.TenantID = tenant
+		queue.Enqueue(ctx, job) //nolint:errcheck
+		job.SetCompleted("/storage/"+job.ID+".mp3", 1)
+		queue.UpdateJob(ctx, job) //nolint:errcheck
+	}
+
+	// A scoped handler limits a key to its own tenant, whatever ?tenant= says
+	auth := middleware.NewAPIKeyAuth([]middleware.APIKey{{Name: "acme", Key: "secret"}})
+	scoped := auth(http.HandlerFunc(NewAnalyticsHandler(queue, true, testLogger()).GetAnalytics))
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/analytics?tenant=other", nil)
+	req.Header.Set("X-API-Key", "secret")
+	rec := httptest.NewRecorder()
+	scoped.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var analytics domain.Analytics
+	if err := json.NewDecoder(rec.Body).Decode(&analytics); err != nil {
+		t.Fatalf("decode analytics: %v", err)
+	}
+	today := time.Now().UTC().Format(time.DateOnly)
+	if analytics.Tenant != "acme" || analytics.Totals.Completed != 2 || len(analytics.Days) != 30 || analytics.To != today {
+		t.Errorf("unexpected analytics %+v", analytics)
+	}
+
+	// Unscoped, every tenant is reported
+	h := NewAnalyticsHandler(queue, false, testLogger())
+	rec = httptest.NewRecorder()
+	h.GetAnalytics(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/analytics?from="+today, nil))
+	if err := json.NewDecoder(rec.Body).Decode(&analytics); err != nil {
+		t.Fatalf("decode analytics: %v", err)
+	}
+	if analytics.Totals.Completed != 3 || len(analytics.Days) != 1 {
+		t.Errorf("unexpected analytics %+v", analytics)
+	}
+
+	for _, query := range []string{"?from=yesterday", "?from=2026-03-10&to=2026-03-01", "?from=2024-01-01&to=2026-01-01"} {
+		rec = httptest.NewRecorder()
+		h.GetAnalytics(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/analytics"+query, nil))
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected status 422, got %d", query, rec.Code)
+		}
+	}
+}
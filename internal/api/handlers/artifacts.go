@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/api/middleware"
+	"github.com/pako-tts/server/internal/audio/transcode"
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// Artifact types listed by GET /api/v1/jobs/{jobID}/artifacts.
+const (
+	ArtifactTypeAudio        = "audio"
+	ArtifactTypeAudioVariant = domain.ArtifactKindVariant
+	ArtifactTypePreview      = domain.ArtifactKindPreview
+	ArtifactTypeWaveform     = domain.ArtifactKindWaveform
+)
+
+// JobArtifactsResponse lists every file a completed job produced.
+type JobArtifactsResponse struct {
+	JobID     string        `json:"job_id"`
+	ExpiresAt *string       `json:"expires_at,omitempty"`
+	Artifacts []JobArtifact `json:"artifacts"`
+}
+
+// JobArtifact describes one downloadable file of a job.
+type JobArtifact struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	ContentType string `json:"content_type"`
+	URL         string `json:"url"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+	// ExpiresAt is when the file is removed; artifacts may be kept for a
+	// different period than the result.
+	ExpiresAt *string `json:"expires_at,omitempty"`
+}
+
+// GetJobArtifacts handles GET /api/v1/jobs/{jobID}/artifacts.
+func (h *JobsHandler) GetJobArtifacts(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.completedJob(w, r, "")
+	if !ok {
+		return
+	}
+
+	artifacts, apiErr := h.listArtifacts(r.Context(), job)
+	if apiErr != nil {
+		middleware.WriteError(w, apiErr)
+		return
+	}
+	middleware.WriteJSON(w, http.StatusOK, JobArtifactsResponse{
+		JobID:     job.ID,
+		ExpiresAt: formatExpiry(job.ExpiresAt),
+		Artifacts: artifacts,
+	})
+}
+
+// listArtifacts describes the files of the completed job, its result first.
+func (h *JobsHandler) listArtifacts(ctx context.Context, job *domain.Job) ([]JobArtifact, *domain.APIError) {
+	base := "/api/v1/jobs/" + job.ID
+
+	reader, contentType, err := h.storage.Retrieve(ctx, job.ID)
+	if err != nil {
+		h.logger.Error("Failed to retrieve audio", zap.Error(err), zap.String("job_id", job.ID))
+		return nil, h.expiredError(job)
+	}
+	audio := JobArtifact{
+		Name:        job.ID + "." + job.OutputFormat,
+		Type:        ArtifactTypeAudio,
+		ContentType: contentType,
+		URL:         base + "/result",
+		ExpiresAt:   formatExpiry(job.ExpiresAt),
+	}
+	audio.Size, audio.SHA256, err = digest(reader)
+	if err != nil {
+		h.logger.Error("Failed to read audio", zap.Error(err), zap.String("job_id", job.ID))
+		return nil, domain.ErrInternalServer
+	}
+
+	artifacts := []JobArtifact{audio}
+	for _, name := range job.Artifacts {
+		artifact, ok := describeArtifact(base, name)
+		if !ok || !job.ArtifactAvailable(name) {
+			continue
+		}
+		artifact.ExpiresAt = formatExpiry(job.ArtifactExpiry(name))
+		reader, err := h.storage.RetrieveArtifact(ctx, job.ID, name)
+		if err != nil {
+			// A missing derived file is left out rather than failing the listing.
+			h.logger.Warn("Failed to retrieve artifact", zap.Error(err), zap.String("job_id", job.ID), zap.String("artifact", name))
+			continue
+		}
+		artifact.Size, artifact.SHA256, err = digest(reader)
+		if err != nil {
+			h.logger.Warn("Failed to read artifact", zap.Error(err), zap.String("job_id", job.ID), zap.String("artifact", name))
+			continue
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	return artifacts, nil
+}
+
+// describeArtifact maps a stored artifact name to its type, content type and URL.
+// Unknown names are not listed.
+func describeArtifact(base, name string) (JobArtifact, bool) {
+	artifact := JobArtifact{Name: name}
+	switch {
+	case name == domain.ArtifactPreview:
+		artifact.Type, artifact.ContentType, artifact.URL = ArtifactTypePreview, "audio/mpeg", base+"/preview"
+	case name == domain.ArtifactWaveform:
+		artifact.Type, artifact.ContentType, artifact.URL = ArtifactTypeWaveform, "application/json", base+"/waveform"
+	case strings.HasPrefix(name, domain.ResultVariant("")):
+		format := strings.TrimPrefix(name, domain.ResultVariant(""))
+		if !transcode.CanConvertTo(format) {
+			return artifact, false
+		}
+		artifact.Type, artifact.ContentType, artifact.URL = ArtifactTypeAudioVariant, transcode.ContentType(format), base+"/result?format="+format
+	default:
+		return artifact, false
+	}
+	return artifact, true
+}
+
+// formatExpiry formats an expiry time for a response; nil stays nil.
+func formatExpiry(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format("2006-01-02T15:04:05Z")
+	return &s
+}
+
+// digest reads and closes rc, returning its size and hex SHA-256.
+func digest(rc io.ReadCloser) (int64, string, error) {
+	defer rc.Close() //nolint:errcheck
+	hash := sha256.New()
+	n, err := io.Copy(hash, rc)
+	if err != nil {
+		return 0, "", err
+	}
+	return n, hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/api/middleware"
+	"github.com/pako-tts/server/internal/bulk"
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// BulkHandler lets admins act on every job matching a filter at once.
+type BulkHandler struct {
+	runner *bulk.Runner
+	logger *zap.Logger
+}
+
+// NewBulkHandler creates a new bulk operations handler.
+func NewBulkHandler(runner *bulk.Runner, logger *zap.Logger) *BulkHandler {
+	return &BulkHandler{runner: runner, logger: logger}
+}
+
+// BulkOperationRequest starts a bulk operation.
+type BulkOperationRequest struct {
+	// Action is bulk.ActionCancel, bulk.ActionRetry or bulk.ActionPurge.
+	Action string      `json:"action"`
+	Filter bulk.Filter `json:"filter"`
+	// DryRun only counts the jobs the action would be taken on.
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// BulkOperationListResponse lists the bulk operations kept, newest first.
+type BulkOperationListResponse struct {
+	Operations []bulk.Operation `json:"operations"`
+}
+
+// StartOperation handles POST /api/v1/admin/operations. The operation runs in
+// the background; poll GET /api/v1/admin/operations/{operationID} for progress.
+func (h *BulkHandler) StartOperation(w http.ResponseWriter, r *http.Request) {
+	var req BulkOperationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		middleware.WriteError(w, domain.ErrValidation.WithMessage("Invalid JSON body"))
+		return
+	}
+	statuses := bulk.Statuses(req.Action)
+	if statuses == nil {
+		middleware.WriteError(w, domain.ErrValidation.WithDetails(map[string]any{
+			"field":   "action",
+			"message": "action must be cancel, retry or purge",
+		}))
+		return
+	}
+
+	op, err := h.runner.Start(req.Action, req.Filter, req.DryRun)
+	if err != nil {
+		names := make([]string, len(statuses))
+		for i, s := range statuses {
+			names[i] = string(s)
+		}
+		middleware.WriteError(w, domain.ErrValidation.WithDetails(map[string]any{
+			"field":   "filter.status",
+			"message": req.Action + " applies to " + strings.Join(names, ", ") + " jobs only",
+		}))
+		return
+	}
+	h.logger.Info("Bulk operation started",
+		zap.String("operation_id", op.ID),
+		zap.String("action", op.Action),
+		zap.Bool("dry_run", op.DryRun),
+	)
+	middleware.WriteJSON(w, http.StatusAccepted, op)
+}
+
+// ListOperations handles GET /api/v1/admin/operations.
+func (h *BulkHandler) ListOperations(w http.ResponseWriter, r *http.Request) {
+	middleware.WriteJSON(w, http.StatusOK, BulkOperationListResponse{Operations: h.runner.List()})
+}
+
+// GetOperation handles GET /api/v1/admin/operations/{operationID}.
+func (h *BulkHandler) GetOperation(w http.ResponseWriter, r *http.Request) {
+	op, ok := h.runner.Get(chi.URLParam(r, "operationID"))
+	if !ok {
+		middleware.WriteError(w, domain.ErrOperationNotFound)
+		return
+	}
+	middleware.WriteJSON(w, http.StatusOK, op)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/pako-tts/server/internal/api/handlers/mocks"
+	"github.com/pako-tts/server/internal/bulk"
+	"github.com/pako-tts/server/internal/queue/memory"
+)
+
+func TestBulkHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	registry := mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"})
+	runner := bulk.NewRunner(ctx, memory.NewQueue(10), mocks.NewMockStorage(), registry, testLogger())
+	h := NewBulkHandler(runner, testLogger())
+
+	start := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.StartOperation(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/operations", strings.NewReader(body)))
+		return rec
+	}
+	for _, body := range []string{
+		`{"action": "delete"}`,
+		`{"action": "retry", "filter": {"status": "queued"}}`,
+		`not json`,
+	} {
+		if rec := start(body); rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected 422, got %d", body, rec.Code)
+		}
+	}
+
+	rec := start(`{"action": "cancel", "filter": {"tenant": "acme"}, "dry_run": true}`)
+	var op bulk.Operation
+	if err := json.NewDecoder(rec.Body).Decode(&op); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if rec.Code != http.StatusAccepted || op.ID == "" || op.Action != bulk.ActionCancel || op.Filter.Tenant != "acme" {
+		t.Fatalf("expected the operation started, got %d %+v", rec.Code, op)
+	}
+
+	get := func(id string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/operations/"+id, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("operationID", id)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rec := httptest.NewRecorder()
+		h.GetOperation(rec, req)
+		return rec.Code
+	}
+	if code := get(op.ID); code != http.StatusOK {
+		t.Errorf("expected the operation found, got %d", code)
+	}
+	if code := get("missing"); code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown operation, got %d", code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ListOperations(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/operations", nil))
+	var list BulkOperationListResponse
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil || len(list.Operations) != 1 {
+		t.Errorf("expected one operation listed, got %+v (%v)", list, err)
+	}
+}
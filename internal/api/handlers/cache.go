@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/api/middleware"
+	"github.com/pako-tts/server/internal/audio/transcode"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/speechcache"
+)
+
+// maxWarmItems caps the phrases of one cache-warm request.
+const maxWarmItems = 1000
+
+// CacheHandler warms the speech cache that the sync TTS endpoint serves from.
+type CacheHandler struct {
+	registry       domain.ProviderRegistry
+	queue          domain.JobQueue
+	speechCache    domain.SpeechCache
+	logger         *zap.Logger
+	maxTextLen     int
+	defaultVoiceID string
+	clampSettings  bool
+}
+
+// NewCacheHandler creates a new cache handler. maxTextLen is the sync endpoint's
+// limit, since longer phrases could never be served from the cache.
+func NewCacheHandler(
+	registry domain.ProviderRegistry,
+	queue domain.JobQueue,
+	speechCache domain.SpeechCache,
+	logger *zap.Logger,
+	maxTextLen int,
+	defaultVoiceID string,
+	clampSettings bool,
+) *CacheHandler {
+	return &CacheHandler{
+		registry:       registry,
+		queue:          queue,
+		speechCache:    speechCache,
+		logger:         logger,
+		maxTextLen:     maxTextLen,
+		defaultVoiceID: defaultVoiceID,
+		clampSettings:  clampSettings,
+	}
+}
+
+// CacheWarmRequest lists the phrases to synthesize into the cache. Each item takes
+// the fields of a sync TTS request; an item is served from the cache when a later
+// TTS request matches it exactly.
+type CacheWarmRequest struct {
+	Items []TTSRequest `json:"items"`
+	// Refresh synthesizes items again even when they are already cached.
+	Refresh bool `json:"refresh,omitempty"`
+}
+
+// CacheWarmResponse describes the batch of jobs a warm request queued.
+type CacheWarmResponse struct {
+	BatchID   string `json:"batch_id,omitempty"`
+	Total     int    `json:"total"`
+	Queued    int    `json:"queued"`
+	Cached    int    `json:"cached"`
+	StatusURL string `json:"status_url,omitempty"`
+}
+
+// CacheWarmStatusResponse reports the progress of a warm batch.
+type CacheWarmStatusResponse struct {
+	BatchID            string           `json:"batch_id"`
+	Status             string           `json:"status"`
+	Total              int              `json:"total"`
+	Queued             int              `json:"queued"`
+	Processing         int              `json:"processing"`
+	Completed          int              `json:"completed"`
+	Failed             int              `json:"failed"`
+	Cancelled          int              `json:"cancelled"`
+	ProgressPercentage float64          `json:"progress_percentage"`
+	Jobs               []CacheWarmEntry `json:"jobs"`
+}
+
+// CacheWarmEntry is one phrase of a warm batch.
+type CacheWarmEntry struct {
+	JobID        string `json:"job_id"`
+	Status       string `json:"status"`
+	VoiceID      string `json:"voice_id"`
+	Text         string `json:"text"`
+	ErrorMessage string `json:"error_message,omitempty"`
+}
+
+// Warm handles POST /api/v1/cache/warm. Every phrase not yet cached becomes an
+// async job of one batch; as the jobs complete, their audio is cached.
+func (h *CacheHandler) Warm(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req CacheWarmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		middleware.WriteError(w, domain.ErrValidation.WithMessage("Invalid JSON body"))
+		return
+	}
+	if len(req.Items) == 0 || len(req.Items) > maxWarmItems {
+		middleware.WriteError(w, domain.ErrValidation.WithDetails(map[string]any{
+			"field":   "items",
+			"message": "items must list between 1 and 1000 phrases",
+		}))
+		return
+	}
+
+	jobs := make([]*domain.Job, 0, len(req.Items))
+	seen := make(map[string]bool)
+	resp := CacheWarmResponse{Total: len(req.Items)}
+	for i, item := range req.Items {
+		job, apiErr := h.warmJob(r, item)
+		if apiErr != nil {
+			middleware.WriteError(w, withItemIndex(apiErr, i))
+			return
+		}
+		if seen[job.CacheKey] || (!req.Refresh && h.speechCache.Has(ctx, job.CacheKey)) {
+			resp.Cached++
+			continue
+		}
+		seen[job.CacheKey] = true
+		jobs = append(jobs, job)
+	}
+
+	if len(jobs) == 0 {
+		middleware.WriteJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	resp.BatchID = uuid.New().String()
+	for i, job := range jobs {
+		job.BatchID = resp.BatchID
+		if err := h.queue.Enqueue(ctx, job); err != nil {
+			// Take back the part of the batch already queued, so a retry starts clean
+			for _, queued := range jobs[:i] {
+				h.queue.Cancel(ctx, queued.ID) //nolint:errcheck
+			}
+			if errors.Is(err, domain.ErrQueueBusy) {
+				w.Header().Set("Retry-After", "1")
+				middleware.WriteError(w, domain.ErrQueueBusy)
+				return
+			}
+			h.logger.Error("Failed to enqueue cache-warm job", zap.Error(err))
+			middleware.WriteError(w, domain.ErrInternalServer)
+			return
+		}
+	}
+	resp.Queued = len(jobs)
+	resp.StatusURL = "/api/v1/cache/warm/" + resp.BatchID
+
+	h.logger.Info("Cache warm batch queued",
+		zap.String("batch_id", resp.BatchID),
+		zap.Int("queued", resp.Queued),
+		zap.Int("cached", resp.Cached),
+	)
+	middleware.WriteJSON(w, http.StatusAccepted, resp)
+}
+
+// warmJob validates one warm item like a sync TTS request and builds its job.
+func (h *CacheHandler) warmJob(r *http.Request, item TTSRequest) (*domain.Job, *domain.APIError) {
+	if item.Text == "" {
+		return nil, domain.ErrValidation.WithDetails(map[string]any{
+			"field":   "text",
+			"message": "Text is required",
+		})
+	}
+	if apiErr := checkTextRules(r, "text", item.Text); apiErr != nil {
+		return nil, apiErr
+	}
+	if len(item.Text) > h.maxTextLen {
+		return nil, domain.ErrTextTooLong.WithDetails(map[string]any{
+			"max_length":    h.maxTextLen,
+			"actual_length": len(item.Text),
+		})
+	}
+
+	voiceID := item.VoiceID
+	if voiceID == "" {
+		voiceID = h.defaultVoiceID
+	}
+	outputFormat := item.OutputFormat
+	if outputFormat == "" {
+		outputFormat = defaultOutputFormat(r)
+	}
+	if !transcode.IsOutputFormat(outputFormat) {
+		return nil, domain.ErrInvalidFormat
+	}
+	if apiErr := validatePadding(item.Padding); apiErr != nil {
+		return nil, apiErr
+	}
+	if _, apiErr := validatePipeline(item.Pipeline); apiErr != nil {
+		return nil, apiErr
+	}
+
+	// Resolve the provider the sync endpoint would use, so the cache key matches
+	providerName := item.Provider
+	if providerName == "" {
+		providerName = h.registry.Route(r.Context(), len(item.Text))
+	}
+	provider, err := h.registry.Get(providerName)
+	if err != nil {
+		return nil, domain.ErrProviderNotFound.WithMessage("Provider '" + providerName + "' not found")
+	}
+	voiceSettings, _, apiErr := checkVoiceSettings(provider, item.VoiceSettings, h.clampSettings)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	job := domain.NewJob(item.Text, voiceID, item.ModelID, item.LanguageCode, providerName, outputFormat, voiceSettings)
+	job.Padding = item.Padding
+	job.Pipeline = item.Pipeline
+	job.TenantID = middleware.TenantFromRequest(r)
+	job.CacheKey = speechcache.RequestForJob(job).Key()
+	return job, nil
+}
+
+// withItemIndex adds the position of the offending item to a validation error.
+func withItemIndex(apiErr *domain.APIError, index int) *domain.APIError {
+	details := map[string]any{"index": index}
+	for k, v := range apiErr.Details {
+		details[k] = v
+	}
+	return apiErr.WithDetails(details)
+}
+
+// WarmStatus handles GET /api/v1/cache/warm/{batchID}. The batch is complete once
+// none of its jobs is queued or processing.
+func (h *CacheHandler) WarmStatus(w http.ResponseWriter, r *http.Request) {
+	batchID := chi.URLParam(r, "batchID")
+
+	page, err := h.queue.ListJobs(r.Context(), domain.JobFilter{
+		BatchID:   batchID,
+		Tenant:    tenantFilter(r),
+		Ascending: true,
+	})
+	if err != nil {
+		h.logger.Error("Failed to list batch jobs", zap.Error(err), zap.String("batch_id", batchID))
+		middleware.WriteError(w, domain.ErrInternalServer)
+		return
+	}
+	if len(page.Jobs) == 0 {
+		middleware.WriteError(w, domain.ErrBatchNotFound)
+		return
+	}
+
+	resp := CacheWarmStatusResponse{
+		BatchID: batchID,
+		Total:   len(page.Jobs),
+		Jobs:    make([]CacheWarmEntry, 0, len(page.Jobs)),
+	}
+	for _, job := range page.Jobs {
+		switch job.Status {
+		case domain.JobStatusQueued:
+			resp.Queued++
+		case domain.JobStatusProcessing:
+			resp.Processing++
+		case domain.JobStatusCompleted, domain.JobStatusExpired:
+			resp.Completed++
+		case domain.JobStatusFailed:
+			resp.Failed++
+		case domain.JobStatusCancelled:
+			resp.Cancelled++
+		}
+		resp.Jobs = append(resp.Jobs, CacheWarmEntry{
+			JobID:        job.ID,
+			Status:       string(job.Status),
+			VoiceID:      job.VoiceID,
+			Text:         job.Text,
+			ErrorMessage: job.ErrorMessage,
+		})
+	}
+
+	finished := resp.Completed + resp.Failed + resp.Cancelled
+	resp.ProgressPercentage = float64(finished) / float64(resp.Total) * 100
+	resp.Status = string(domain.JobStatusProcessing)
+	if finished == resp.Total {
+		resp.Status = string(domain.JobStatusCompleted)
+	}
+	middleware.WriteJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/pako-tts/server/internal/api/handlers/mocks"
+	"github.com/pako-tts/server/internal/api/middleware"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/queue/memory"
+	"github.com/pako-tts/server/internal/speechcache"
+)
+
+func TestCacheHandler_Warm(t *testing.T) {
+	cache, err := speechcache.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("speechcache.New: %v", err)
+	}
+	ctx := context.Background()
+	cachedKey := speechcache.Request{Tenant: domain.DefaultTenant, Provider: "test-provider", Text: "Cached", VoiceID: "default-voice", Format: "mp3"}.Key()
+	cache.Put(ctx, cachedKey, []byte("audio")) //nolint:errcheck
+
+	queue := memory.NewQueue(10)
+	registry := mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider", AvailableValue: true})
+	h := NewCacheHandler(registry, queue, cache, testLogger(), 5000, "default-voice", false)
+
+	// Duplicates and already-cached phrases aren't queued
+	body := `{"items":[{"text":"Hello"},{"text":"Hello"},{"text":"Cached"}]}`
+	rec := httptest.NewRecorder()
+	h.Warm(rec, httptest.NewRequest(http.MethodPost, "/api/v1/cache/warm", bytes.NewBufferString(body)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var warm CacheWarmResponse
+	if err := json.NewDecoder(rec.Body).Decode(&warm); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if warm.Total != 3 || warm.Queued != 1 || warm.Cached != 2 || warm.BatchID == "" {
+		t.Fatalf("unexpected response %+v", warm)
+	}
+
+	// The batch is reported until its job finishes
+	status := func() CacheWarmStatusResponse {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, warm.StatusURL, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("batchID", warm.BatchID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rec := httptest.NewRecorder()
+		h.WarmStatus(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp CacheWarmStatusResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode status: %v", err)
+		}
+		return resp
+	}
+	if s := status(); s.Status != "processing" || s.Queued != 1 || len(s.Jobs) != 1 {
+		t.Fatalf("unexpected status %+v", s)
+	}
+	job, _ := queue.GetJob(ctx, status().Jobs[0].JobID)
+	if job.CacheKey == "" {
+		t.Fatal("expected the warm job to carry its cache key")
+	}
+	job.SetCompleted("/storage/"+job.ID+".mp3", 1)
+	queue.UpdateJob(ctx, job) //nolint:errcheck
+	if s := status(); s.Status != "completed" || s.ProgressPercentage != 100 {
+		t.Errorf("unexpected status %+v", s)
+	}
+
+	// An invalid item rejects the whole request and names its index
+	rec = httptest.NewRecorder()
+	h.Warm(rec, httptest.NewRequest(http.MethodPost, "/api/v1/cache/warm", bytes.NewBufferString(`{"items":[{"text":"Hi"},{"text":""}]}`)))
+	var errResp domain.ErrorResponse
+	json.NewDecoder(rec.Body).Decode(&errResp) //nolint:errcheck
+	if rec.Code != http.StatusUnprocessableEntity || errResp.Error == nil || errResp.Error.Details["index"] != float64(1) {
+		t.Errorf("expected a 422 naming item 1, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestSynthesizeTTS_ServesWarmedRequestFromCache(t *testing.T) {
+	cache, err := speechcache.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("speechcache.New: %v", err)
+	}
+	key := speechcache.Request{Tenant: domain.DefaultTenant, Provider: "test-provider", Text: "Hello", VoiceID: "default-voice", Format: "mp3"}.Key()
+	cache.Put(context.Background(), key, []byte("cached audio")) //nolint:errcheck
+
+	// The provider is down, so only a cache hit can answer
+	registry := mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"})
+	handler := NewTTSHandler(registry, testLogger(), 30*time.Second, 5000, "default-voice", false, nil, cache, nil, nil, nil, nil)
+
+	rec := httptest.NewRecorder()
+	handler.SynthesizeTTS(rec, httptest.NewRequest(http.MethodPost, "/api/v1/tts", bytes.NewBufferString(`{"text":"Hello"}`)))
+	if rec.Code != http.StatusOK || rec.Header().Get(CacheHeader) != "HIT" || rec.Body.String() != "cached audio" {
+		t.Errorf("expected a cache hit, got %d %s: %q", rec.Code, rec.Header().Get(CacheHeader), rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.SynthesizeTTS(rec, httptest.NewRequest(http.MethodPost, "/api/v1/tts", bytes.NewBufferString(`{"text":"Goodbye"}`)))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get(CacheHeader) != "MISS" {
+		t.Errorf("expected a cache miss, got %d %s", rec.Code, rec.Header().Get(CacheHeader))
+	}
+
+	// Other tenants aren't answered from the entry, nor told it exists
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tts", bytes.NewBufferString(`{"text":"Hello"}`))
+	req.Header.Set(middleware.TenantHeader, "acme")
+	handler.SynthesizeTTS(rec, req)
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get(CacheHeader) != "MISS" {
+		t.Errorf("expected a miss for another tenant, got %d %s", rec.Code, rec.Header().Get(CacheHeader))
+	}
+}
+
+func TestSynthesizeTTS_ServesRepeatedRequestFromResultCache(t *testing.T) {
+	cache, err := speechcache.NewExpiring(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatalf("speechcache.NewExpiring: %v", err)
+	}
+	calls := 0
+	provider := &mocks.MockProvider{NameValue: "test-provider", AvailableValue: true,
+		SynthesizeFunc: func(ctx context.Context, req *domain.SynthesisRequest) (*domain.SynthesisResult, error) {
+			calls++
+			return &domain.SynthesisResult{Audio: bytes.NewReader([]byte("audio")), ContentType: "audio/mpeg"}, nil
+		},
+	}
+	handler := NewTTSHandler(mocks.NewMockProviderRegistry(provider), testLogger(), 30*time.Second, 5000, "default-voice", false, nil, nil, nil, cache, nil, nil)
+
+	for i, want := range []string{"MISS", "HIT"} {
+		rec := httptest.NewRecorder()
+		handler.SynthesizeTTS(rec, httptest.NewRequest(http.MethodPost, "/api/v1/tts", bytes.NewBufferString(`{"text":"Your code is 1234"}`)))
+		if rec.Code != http.StatusOK || rec.Header().Get(CacheHeader) != want || rec.Body.String() != "audio" {
+			t.Errorf("request %d: expected %s, got %d %s: %q", i, want, rec.Code, rec.Header().Get(CacheHeader), rec.Body.String())
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected the provider to be called once, got %d", calls)
+	}
+}
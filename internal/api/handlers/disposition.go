@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/pako-tts/server/internal/audio/transcode"
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// Result dispositions selected with ?disposition=.
+const (
+	DispositionAttachment = "attachment"
+	DispositionInline     = "inline"
+)
+
+// requestedDisposition reads ?disposition= from a download request. Without it
+// results are offered as attachments; inline lets browsers play them in place.
+func requestedDisposition(r *http.Request) (string, *domain.APIError) {
+	switch d := r.URL.Query().Get("disposition"); d {
+	case "", DispositionAttachment:
+		return DispositionAttachment, nil
+	case DispositionInline:
+		return DispositionInline, nil
+	default:
+		return "", domain.ErrValidation.WithDetails(map[string]any{
+			"field":   "disposition",
+			"message": "disposition must be 'attachment' or 'inline'",
+		})
+	}
+}
+
+// resultFilename names a job's audio after its voice, e.g. "Rachel-<job id>.mp3".
+func resultFilename(job *domain.Job, format string) string {
+	voice := strings.Map(func(r rune) rune {
+		switch {
+		case r == '/' || r == '\\' || unicode.IsControl(r):
+			return -1
+		case unicode.IsSpace(r):
+			return '_'
+		}
+		return r
+	}, job.VoiceID)
+	if voice == "" {
+		return job.ID + "." + transcode.Extension(format)
+	}
+	return voice + "-" + job.ID + "." + transcode.Extension(format)
+}
+
+// contentDisposition formats a Content-Disposition header value. Non-ASCII
+// filenames are sent RFC 5987-encoded in filename*, with an ASCII fallback in
+// filename for clients that don't read it.
+func contentDisposition(disposition, filename string) string {
+	var ascii strings.Builder
+	nonASCII := false
+	for _, r := range filename {
+		switch {
+		case r > unicode.MaxASCII:
+			nonASCII = true
+			ascii.WriteByte('_')
+		case r == '"' || r == '\\':
+			ascii.WriteByte('\\')
+			ascii.WriteRune(r)
+		default:
+			ascii.WriteRune(r)
+		}
+	}
+
+	value := disposition + `; filename="` + ascii.String() + `"`
+	if nonASCII {
+		value += "; filename*=UTF-8''" + encodeExtValue(filename)
+	}
+	return value
+}
+
+// encodeExtValue percent-encodes every byte of s outside RFC 5987's attr-char set.
+func encodeExtValue(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < unicode.MaxASCII && (unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)) || strings.IndexByte("!#$&+-.^_`|~", c) >= 0) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0xf])
+	}
+	return b.String()
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/api/middleware"
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// DrainHandler lets admins stop an instance's workers before it goes away.
+type DrainHandler struct {
+	drainer domain.Drainer
+	logger  *zap.Logger
+}
+
+// NewDrainHandler creates a new drain handler.
+func NewDrainHandler(drainer domain.Drainer, logger *zap.Logger) *DrainHandler {
+	return &DrainHandler{drainer: drainer, logger: logger}
+}
+
+// DrainRequest starts a drain.
+type DrainRequest struct {
+	// Strategy is domain.DrainScaleDown or domain.DrainShutdown.
+	Strategy string `json:"strategy"`
+	// Timeout is how long the drain may take, e.g. "10m", before the jobs still
+	// in progress are checkpointed; empty waits for them.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// DrainStatus handles GET /api/v1/admin/drain.
+func (h *DrainHandler) DrainStatus(w http.ResponseWriter, r *http.Request) {
+	middleware.WriteJSON(w, http.StatusOK, h.drainer.DrainStatus())
+}
+
+// Drain handles POST /api/v1/admin/drain. The workers stop taking jobs at once;
+// the instance refuses new jobs from then on.
+func (h *DrainHandler) Drain(w http.ResponseWriter, r *http.Request) {
+	var req DrainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		middleware.WriteError(w, domain.ErrValidation.WithMessage("Invalid JSON body"))
+		return
+	}
+	if req.Strategy != domain.DrainScaleDown && req.Strategy != domain.DrainShutdown {
+		middleware.WriteError(w, domain.ErrValidation.WithDetails(map[string]any{
+			"field":   "strategy",
+			"message": "strategy must be scale_down or shutdown",
+		}))
+		return
+	}
+	var timeout time.Duration
+	if req.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(req.Timeout); err != nil || timeout <= 0 {
+			middleware.WriteError(w, domain.ErrValidation.WithDetails(map[string]any{
+				"field":   "timeout",
+				"message": "timeout must be a positive duration, e.g. 10m",
+			}))
+			return
+		}
+	}
+
+	status, err := h.drainer.Drain(req.Strategy, timeout)
+	if err != nil {
+		middleware.WriteError(w, domain.ErrDrainInProgress.WithDetails(map[string]any{
+			"strategy": h.drainer.DrainStatus().Strategy,
+		}))
+		return
+	}
+	h.logger.Info("Drain requested", zap.String("strategy", req.Strategy), zap.Duration("timeout", timeout))
+	middleware.WriteJSON(w, http.StatusAccepted, status)
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/pako-tts/server/internal/api/middleware"
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// ErrorCatalogEntry describes one error code the API can answer with.
+type ErrorCatalogEntry struct {
+	Code       string `json:"code"`
+	HTTPStatus int    `json:"http_status"`
+	Message    string `json:"message"`
+	Retryable  bool   `json:"retryable"`
+	Hint       string `json:"hint"`
+}
+
+// ErrorCatalogResponse lists the error codes of the API.
+type ErrorCatalogResponse struct {
+	Errors []ErrorCatalogEntry `json:"errors"`
+}
+
+// ListErrors handles GET /api/v1/errors.
+func ListErrors(w http.ResponseWriter, r *http.Request) {
+	resp := ErrorCatalogResponse{Errors: []ErrorCatalogEntry{}}
+	for _, e := range domain.ErrorCatalog() {
+		resp.Errors = append(resp.Errors, ErrorCatalogEntry{
+			Code:       e.Code,
+			HTTPStatus: e.StatusCode,
+			Message:    e.Message,
+			Retryable:  e.Retryable,
+			Hint:       e.Hint,
+		})
+	}
+	middleware.WriteJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/api/middleware"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/replay"
+)
+
+const (
+	defaultExportLimit = 1000
+	maxExportLimit     = 100000
+	exportPageSize     = 500
+)
+
+// ExportHandler lets admins export finished jobs as a replay manifest.
+type ExportHandler struct {
+	queue  domain.JobQueue
+	logger *zap.Logger
+}
+
+// NewExportHandler creates a new job export handler.
+func NewExportHandler(queue domain.JobQueue, logger *zap.Logger) *ExportHandler {
+	return &ExportHandler{queue: queue, logger: logger}
+}
+
+// ExportJobs handles GET /api/v1/admin/jobs/export. It streams the finished
+// jobs matching the query, oldest first, as a JSON Lines manifest of
+// replay.Entry, for cmd/replay. Jobs whose text is no longer kept are left out,
+// so there may be fewer entries than limit.
+func (h *ExportHandler) ExportJobs(w http.ResponseWriter, r *http.Request) {
+	filter, limit, apiErr := parseExportQuery(r)
+	if apiErr != nil {
+		middleware.WriteError(w, apiErr)
+		return
+	}
+
+	// The first page is fetched before answering, so a failing store is still a 500
+	page, err := h.queue.ListJobs(r.Context(), filter)
+	if err != nil {
+		h.logger.Error("Failed to list jobs", zap.Error(err))
+		middleware.WriteError(w, domain.ErrInternalServer)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", contentDisposition(DispositionAttachment, "jobs.jsonl"))
+	w.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(w)
+	exported := 0
+	for {
+		for _, job := range page.Jobs {
+			entry, ok := replay.NewEntry(job)
+			if !ok {
+				continue
+			}
+			if err := enc.Encode(entry); err != nil {
+				return
+			}
+			if exported++; exported == limit {
+				break
+			}
+		}
+		if exported == limit || page.Next == nil {
+			break
+		}
+		filter.After = page.Next
+		if page, err = h.queue.ListJobs(r.Context(), filter); err != nil {
+			h.logger.Error("Job export interrupted", zap.Int("exported", exported), zap.Error(err))
+			return
+		}
+	}
+	h.logger.Info("Jobs exported", zap.Int("jobs", exported))
+}
+
+func parseExportQuery(r *http.Request) (domain.JobFilter, int, *domain.APIError) {
+	params := r.URL.Query()
+	filter := domain.JobFilter{
+		Status:       domain.JobStatusCompleted,
+		Tenant:       params.Get("tenant"),
+		Provider:     params.Get("provider_name"),
+		VoiceID:      params.Get("voice_id"),
+		OutputFormat: params.Get("output_format"),
+		Ascending:    true,
+		Limit:        exportPageSize,
+	}
+
+	switch status := domain.JobStatus(params.Get("status")); status {
+	case "":
+	case domain.JobStatusCompleted, domain.JobStatusFailed, domain.JobStatusExpired:
+		filter.Status = status
+	default:
+		return filter, 0, domain.ErrValidation.WithDetails(map[string]any{
+			"field":   "status",
+			"message": "status must be one of completed, failed, expired",
+		})
+	}
+
+	for _, bound := range []struct {
+		field string
+		t     *time.Time
+	}{
+		{"created_after", &filter.CreatedAfter},
+		{"created_before", &filter.CreatedBefore},
+	} {
+		field := bound.field
+		v := params.Get(field)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, 0, domain.ErrValidation.WithDetails(map[string]any{
+				"field":   field,
+				"message": field + " must be an RFC 3339 timestamp",
+			})
+		}
+		*bound.t = t
+	}
+
+	limit := defaultExportLimit
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxExportLimit {
+			return filter, 0, domain.ErrValidation.WithDetails(map[string]any{
+				"field":   "limit",
+				"message": "limit must be between 1 and 100000",
+			})
+		}
+		limit = n
+	}
+	return filter, limit, nil
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/queue/memory"
+	"github.com/pako-tts/server/internal/replay"
+)
+
+func TestExportHandler_ExportJobs(t *testing.T) {
+	queue := memory.NewQueue(10)
+	ctx := context.Background()
+	finish := func(text string, status domain.JobStatus, age time.Duration) *domain.Job {
+		job := domain.NewJob(text, "voice", "", "", "elevenlabs", "mp3", nil)
+		job.CreatedAt = time.Now().Add(-age)
+		completedAt := job.CreatedAt.Add(2 * time.Second)
+		job.Status = status
+		job.CompletedAt = &completedAt
+		queue.Enqueue(ctx, job)   //nolint:errcheck
+		queue.UpdateJob(ctx, job) //nolint:errcheck
+		return job
+	}
+	oldest := finish("first", domain.JobStatusCompleted, 3*time.Hour)
+	finish("", domain.JobStatusCompleted, 2*time.Hour)
+	newest := finish("second", domain.JobStatusCompleted, time.Hour)
+	failed := finish("broken", domain.JobStatusFailed, time.Hour)
+
+	export := func(query string) (*httptest.ResponseRecorder, []replay.Entry) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/jobs/export"+query, nil)
+		rec := httptest.NewRecorder()
+		NewExportHandler(queue, testLogger()).ExportJobs(rec, req)
+		if rec.Code != http.StatusOK {
+			return rec, nil
+		}
+		entries, err := replay.ReadManifest(rec.Body)
+		if err != nil {
+			t.Fatalf("read manifest: %v", err)
+		}
+		return rec, entries
+	}
+
+	// Completed jobs by default, oldest first, leaving out those without text
+	rec, entries := export("")
+	if rec.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Errorf("content type = %q", rec.Header().Get("Content-Type"))
+	}
+	if len(entries) != 2 || entries[0].JobID != oldest.ID || entries[1].JobID != newest.ID {
+		t.Fatalf("expected the two completed jobs with text, got %+v", entries)
+	}
+	if entries[0].LatencyMs != 2000 || entries[0].Provider != "elevenlabs" || entries[0].Status != domain.JobStatusCompleted {
+		t.Errorf("entry = %+v", entries[0])
+	}
+
+	if _, entries := export("?limit=1"); len(entries) != 1 || entries[0].JobID != oldest.ID {
+		t.Errorf("limit=1 exported %+v", entries)
+	}
+	if _, entries := export("?status=failed"); len(entries) != 1 || entries[0].JobID != failed.ID {
+		t.Errorf("status=failed exported %+v", entries)
+	}
+	after := time.Now().Add(-90 * time.Minute).UTC().Format(time.RFC3339)
+	if _, entries := export("?created_after=" + after); len(entries) != 1 || entries[0].JobID != newest.ID {
+		t.Errorf("created_after exported %+v", entries)
+	}
+
+	for _, query := range []string{"?status=queued", "?limit=0", "?created_before=yesterday"} {
+		if rec, _ := export(query); rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected status 422, got %d", query, rec.Code)
+		}
+	}
+}
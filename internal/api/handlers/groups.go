@@ -0,0 +1,404 @@
+package handlers
+
+import (
+	"archive/zip"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/api/middleware"
+	"github.com/pako-tts/server/internal/audio/transcode"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/metrics"
+)
+
+// maxGroupSegments caps the segments of one group.
+const maxGroupSegments = 500
+
+// Group result packagings selected with ?packaging=.
+const (
+	PackagingConcat = "concat"
+	PackagingZip    = "zip"
+)
+
+// GroupCreateRequest lists the segments of a group in the order their audio is
+// joined. Each segment takes the fields of a job request; the group's
+// voice_id, model_id, language_code and provider apply to segments that leave
+// them out. Every segment shares the group's output_format.
+type GroupCreateRequest struct {
+	Segments     []JobCreateRequest `json:"segments"`
+	VoiceID      string             `json:"voice_id,omitempty"`
+	ModelID      string             `json:"model_id,omitempty"`
+	LanguageCode string             `json:"language_code,omitempty"`
+	Provider     string             `json:"provider,omitempty"`
+	OutputFormat string             `json:"output_format,omitempty"`
+}
+
+// GroupCreateResponse describes the jobs a group request queued.
+type GroupCreateResponse struct {
+	GroupID   string         `json:"group_id"`
+	Status    string         `json:"status"`
+	Total     int            `json:"total"`
+	Segments  []GroupSegment `json:"segments"`
+	StatusURL string         `json:"status_url"`
+	ResultURL string         `json:"result_url"`
+}
+
+// GroupStatusResponse reports the progress of a group.
+type GroupStatusResponse struct {
+	GroupID            string         `json:"group_id"`
+	Status             string         `json:"status"`
+	OutputFormat       string         `json:"output_format"`
+	Total              int            `json:"total"`
+	Queued             int            `json:"queued"`
+	Processing         int            `json:"processing"`
+	Completed          int            `json:"completed"`
+	Failed             int            `json:"failed"`
+	Cancelled          int            `json:"cancelled"`
+	Expired            int            `json:"expired"`
+	ProgressPercentage float64        `json:"progress_percentage"`
+	DurationSeconds    *float64       `json:"duration_seconds,omitempty"`
+	ResultURL          *string        `json:"result_url,omitempty"`
+	Segments           []GroupSegment `json:"segments"`
+}
+
+// GroupSegment is one segment of a group.
+type GroupSegment struct {
+	Segment         int              `json:"segment"`
+	JobID           string           `json:"job_id"`
+	Status          string           `json:"status"`
+	DurationSeconds *float64         `json:"duration_seconds,omitempty"`
+	ErrorMessage    string           `json:"error_message,omitempty"`
+	Warnings        []domain.Warning `json:"warnings,omitempty"`
+}
+
+// SubmitGroup handles POST /api/v1/groups. Every segment becomes a job of one
+// batch, numbered in order; the request is rejected as a whole when a segment
+// is invalid.
+func (h *JobsHandler) SubmitGroup(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req GroupCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		middleware.WriteError(w, domain.ErrValidation.WithMessage("Invalid JSON body"))
+		return
+	}
+	if len(req.Segments) == 0 || len(req.Segments) > maxGroupSegments {
+		middleware.WriteError(w, domain.ErrValidation.WithDetails(map[string]any{
+			"field":   "segments",
+			"message": "segments must list between 1 and 500 segments",
+		}))
+		return
+	}
+	if req.OutputFormat == "" {
+		req.OutputFormat = defaultOutputFormat(r)
+	}
+
+	groupID := uuid.New().String()
+	jobs := make([]*domain.Job, 0, len(req.Segments))
+	resp := GroupCreateResponse{
+		GroupID:   groupID,
+		Status:    string(domain.JobStatusQueued),
+		Total:     len(req.Segments),
+		Segments:  make([]GroupSegment, 0, len(req.Segments)),
+		StatusURL: "/api/v1/groups/" + groupID,
+		ResultURL: "/api/v1/groups/" + groupID + "/result",
+	}
+	for i := range req.Segments {
+		segment := &req.Segments[i]
+		if segment.OutputFormat != "" && segment.OutputFormat != req.OutputFormat {
+			middleware.WriteError(w, withItemIndex(domain.ErrValidation.WithDetails(map[string]any{
+				"field":   "output_format",
+				"message": "segments share the group's output_format",
+			}), i))
+			return
+		}
+		segment.OutputFormat = req.OutputFormat
+		segment.VoiceID = cmp.Or(segment.VoiceID, req.VoiceID)
+		segment.ModelID = cmp.Or(segment.ModelID, req.ModelID)
+		segment.LanguageCode = cmp.Or(segment.LanguageCode, req.LanguageCode)
+		segment.Provider = cmp.Or(segment.Provider, req.Provider)
+
+		job, warnings, apiErr := h.newJob(r, segment)
+		if apiErr != nil {
+			middleware.WriteError(w, withItemIndex(apiErr, i))
+			return
+		}
+		job.BatchID = groupID
+		job.Segment = i + 1
+		jobs = append(jobs, job)
+		resp.Segments = append(resp.Segments, GroupSegment{
+			Segment:  job.Segment,
+			JobID:    job.ID,
+			Status:   string(job.Status),
+			Warnings: warnings,
+		})
+	}
+
+	for i, job := range jobs {
+		if err := h.queue.Enqueue(ctx, job); err != nil {
+			// Take back the part of the group already queued, so a retry starts clean
+			for _, queued := range jobs[:i] {
+				h.queue.Cancel(ctx, queued.ID) //nolint:errcheck
+			}
+			h.writeEnqueueError(w, job, err)
+			return
+		}
+		if job.Source == nil {
+			h.textMetrics.Observe(metrics.SourceAsync, job.Text, job.LanguageCode)
+		}
+	}
+
+	h.logger.Info("Job group created",
+		zap.String("group_id", groupID),
+		zap.Int("segments", len(jobs)),
+	)
+	middleware.WriteJSON(w, http.StatusCreated, resp)
+}
+
+// GetGroup handles GET /api/v1/groups/{groupID}. A group is completed once
+// every segment has completed, and failed once a segment failed, was cancelled
+// or expired.
+func (h *JobsHandler) GetGroup(w http.ResponseWriter, r *http.Request) {
+	jobs, apiErr := h.groupJobs(r)
+	if apiErr != nil {
+		middleware.WriteError(w, apiErr)
+		return
+	}
+
+	groupID := chi.URLParam(r, "groupID")
+	resp := GroupStatusResponse{
+		GroupID:      groupID,
+		OutputFormat: jobs[0].OutputFormat,
+		Total:        len(jobs),
+		Segments:     make([]GroupSegment, 0, len(jobs)),
+	}
+	var duration float64
+	for _, job := range jobs {
+		segment := GroupSegment{
+			Segment:      job.Segment,
+			JobID:        job.ID,
+			Status:       string(job.Status),
+			ErrorMessage: job.ErrorMessage,
+		}
+		switch job.Status {
+		case domain.JobStatusQueued:
+			resp.Queued++
+		case domain.JobStatusProcessing:
+			resp.Processing++
+		case domain.JobStatusCompleted:
+			resp.Completed++
+			if job.AudioSeconds > 0 {
+				seconds := job.AudioSeconds
+				segment.DurationSeconds = &seconds
+				duration += seconds
+			}
+		case domain.JobStatusFailed:
+			resp.Failed++
+		case domain.JobStatusCancelled:
+			resp.Cancelled++
+		case domain.JobStatusExpired:
+			resp.Expired++
+		}
+		resp.Segments = append(resp.Segments, segment)
+	}
+
+	finished := resp.Completed + resp.Failed + resp.Cancelled + resp.Expired
+	resp.ProgressPercentage = float64(finished) / float64(resp.Total) * 100
+	switch {
+	case resp.Completed == resp.Total:
+		resp.Status = string(domain.JobStatusCompleted)
+		resultURL := "/api/v1/groups/" + groupID + "/result"
+		resp.ResultURL = &resultURL
+		if duration > 0 {
+			resp.DurationSeconds = &duration
+		}
+	case finished > resp.Completed:
+		resp.Status = string(domain.JobStatusFailed)
+	case resp.Queued == resp.Total:
+		resp.Status = string(domain.JobStatusQueued)
+	default:
+		resp.Status = string(domain.JobStatusProcessing)
+	}
+	middleware.WriteJSON(w, http.StatusOK, resp)
+}
+
+// GetGroupResult handles GET /api/v1/groups/{groupID}/result. The segments'
+// results are joined in order into one file (?packaging=concat, the default)
+// or packed into a zip of one file per segment (?packaging=zip).
+func (h *JobsHandler) GetGroupResult(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	groupID := chi.URLParam(r, "groupID")
+
+	packaging := r.URL.Query().Get("packaging")
+	switch packaging {
+	case "":
+		packaging = PackagingConcat
+	case PackagingConcat, PackagingZip:
+	default:
+		middleware.WriteError(w, domain.ErrValidation.WithDetails(map[string]any{
+			"field":   "packaging",
+			"message": "packaging must be 'concat' or 'zip'",
+		}))
+		return
+	}
+	disposition, apiErr := requestedDisposition(r)
+	if apiErr != nil {
+		middleware.WriteError(w, apiErr)
+		return
+	}
+
+	jobs, apiErr := h.groupJobs(r)
+	if apiErr != nil {
+		middleware.WriteError(w, apiErr)
+		return
+	}
+	if apiErr := groupResultError(jobs); apiErr != nil {
+		middleware.WriteError(w, apiErr)
+		return
+	}
+
+	format := jobs[0].OutputFormat
+	if packaging == PackagingZip {
+		h.serveGroupZip(w, r, groupID, jobs)
+		return
+	}
+
+	parts := make([][]byte, 0, len(jobs))
+	for _, job := range jobs {
+		audio, err := h.readResult(r, job)
+		if err != nil {
+			h.logger.Error("Failed to read group segment", zap.Error(err), zap.String("job_id", job.ID))
+			middleware.WriteError(w, domain.ErrGroupFailed.WithDetails(map[string]any{
+				"segments": []map[string]any{{"segment": job.Segment, "job_id": job.ID, "status": string(domain.JobStatusExpired)}},
+			}))
+			return
+		}
+		parts = append(parts, audio)
+	}
+	audio, err := transcode.JoinAny(ctx, parts, format)
+	if err != nil {
+		h.logger.Error("Failed to join group segments", zap.Error(err), zap.String("group_id", groupID))
+		middleware.WriteError(w, domain.ErrInternalServer)
+		return
+	}
+
+	w.Header().Set("Content-Type", transcode.ContentType(format))
+	w.Header().Set("Content-Disposition", contentDisposition(disposition, "group-"+groupID+"."+transcode.Extension(format)))
+	w.Header().Set("Content-Length", strconv.Itoa(len(audio)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	w.Write(audio) //nolint:errcheck
+}
+
+// serveGroupZip streams a zip of the segments' results, named by their
+// position, e.g. "01.mp3". Audio is already compressed, so it is stored as is.
+func (h *JobsHandler) serveGroupZip(w http.ResponseWriter, r *http.Request, groupID string, jobs []*domain.Job) {
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", contentDisposition(DispositionAttachment, "group-"+groupID+".zip"))
+	w.WriteHeader(http.StatusOK)
+
+	width := len(strconv.Itoa(len(jobs)))
+	zw := zip.NewWriter(w)
+	for _, job := range jobs {
+		header := &zip.FileHeader{
+			Name:   fmt.Sprintf("%0*d.%s", max(width, 2), job.Segment, transcode.Extension(job.OutputFormat)),
+			Method: zip.Store,
+		}
+		if job.CompletedAt != nil {
+			header.Modified = *job.CompletedAt
+		}
+		if err := h.copyResult(r, job, zw, header); err != nil {
+			// The status is sent already; a truncated zip tells the client
+			h.logger.Error("Failed to write group zip", zap.Error(err), zap.String("group_id", groupID), zap.String("job_id", job.ID))
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		h.logger.Error("Failed to write group zip", zap.Error(err), zap.String("group_id", groupID))
+	}
+}
+
+func (h *JobsHandler) copyResult(r *http.Request, job *domain.Job, zw *zip.Writer, header *zip.FileHeader) error {
+	reader, _, err := h.storage.Retrieve(r.Context(), job.ID)
+	if err != nil {
+		return err
+	}
+	defer reader.Close() //nolint:errcheck
+
+	entry, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, reader)
+	return err
+}
+
+func (h *JobsHandler) readResult(r *http.Request, job *domain.Job) ([]byte, error) {
+	reader, _, err := h.storage.Retrieve(r.Context(), job.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close() //nolint:errcheck
+	return io.ReadAll(reader)
+}
+
+// groupJobs returns the segments of the group named in the URL, in order.
+func (h *JobsHandler) groupJobs(r *http.Request) ([]*domain.Job, *domain.APIError) {
+	groupID := chi.URLParam(r, "groupID")
+	page, err := h.queue.ListJobs(r.Context(), domain.JobFilter{
+		BatchID: groupID,
+		Tenant:  tenantFilter(r),
+	})
+	if err != nil {
+		h.logger.Error("Failed to list group jobs", zap.Error(err), zap.String("group_id", groupID))
+		return nil, domain.ErrInternalServer
+	}
+
+	var jobs []*domain.Job
+	for _, job := range page.Jobs {
+		// Batches of cache-warm jobs aren't groups
+		if job.Segment > 0 {
+			jobs = append(jobs, job)
+		}
+	}
+	if len(jobs) == 0 {
+		return nil, domain.ErrGroupNotFound
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Segment < jobs[j].Segment })
+	return jobs, nil
+}
+
+// groupResultError returns why a group has no result yet, or nil when every
+// segment has completed.
+func groupResultError(jobs []*domain.Job) *domain.APIError {
+	var missing []map[string]any
+	pending := 0
+	for _, job := range jobs {
+		switch job.Status {
+		case domain.JobStatusCompleted:
+		case domain.JobStatusQueued, domain.JobStatusProcessing:
+			pending++
+		default:
+			missing = append(missing, map[string]any{"segment": job.Segment, "job_id": job.ID, "status": string(job.Status)})
+		}
+	}
+	switch {
+	case len(missing) > 0:
+		return domain.ErrGroupFailed.WithDetails(map[string]any{"segments": missing})
+	case pending > 0:
+		return domain.ErrGroupNotComplete.WithDetails(map[string]any{
+			"completed": len(jobs) - pending,
+			"total":     len(jobs),
+		})
+	}
+	return nil
+}
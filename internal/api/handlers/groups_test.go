@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/pako-tts/server/internal/api/handlers/mocks"
+	"github.com/pako-tts/server/internal/audio/transcode"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/queue/memory"
+)
+
+func TestJobsHandler_Groups(t *testing.T) {
+	registry := mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"})
+	queue := memory.NewQueue(10)
+	storage := mocks.NewMockStorage()
+	h := NewJobsHandler(registry, queue, storage, testLogger(), "default-voice", 24, false, 0, nil, nil, nil, nil)
+	router := chi.NewRouter()
+	router.Post("/api/v1/groups", h.SubmitGroup)
+	router.Get("/api/v1/groups/{groupID}", h.GetGroup)
+	router.Get("/api/v1/groups/{groupID}/result", h.GetGroupResult)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	w := do(http.MethodPost, "/api/v1/groups", `{"voice_id": "narrator", "output_format": "wav", "segments": [
+		{"text": "Chapter one"},
+		{"text": "Chapter two", "voice_id": "guest"},
+		{"text": "Chapter three", "output_format": "wav"}
+	]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created GroupCreateResponse
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("decode group: %v", err)
+	}
+	if created.Total != 3 || len(created.Segments) != 3 || created.ResultURL != "/api/v1/groups/"+created.GroupID+"/result" {
+		t.Fatalf("unexpected group %+v", created)
+	}
+
+	var jobs []*domain.Job
+	for i, segment := range created.Segments {
+		job, err := queue.GetJob(context.Background(), segment.JobID)
+		if err != nil {
+			t.Fatalf("segment %d: %v", i, err)
+		}
+		if job.Segment != i+1 || job.BatchID != created.GroupID || job.OutputFormat != "wav" {
+			t.Errorf("segment %d: unexpected job %+v", i, job)
+		}
+		jobs = append(jobs, job)
+	}
+	if jobs[0].VoiceID != "narrator" || jobs[1].VoiceID != "guest" {
+		t.Errorf("expected the group's voice by default, got %s and %s", jobs[0].VoiceID, jobs[1].VoiceID)
+	}
+
+	// Nothing to download before every segment completed
+	if w := do(http.MethodGet, created.ResultURL, ""); w.Code != http.StatusTooEarly {
+		t.Errorf("expected 425 while queued, got %d", w.Code)
+	}
+	for i, job := range jobs {
+		storage.StoredFiles[job.ID] = transcode.PCMToWAV(bytes.Repeat([]byte{byte(i + 1)}, 100), 16000, 1, 16)
+		job.SetCompleted("/storage/"+job.ID+".wav", 24)
+		job.AudioSeconds = 1.5
+	}
+
+	w = do(http.MethodGet, created.StatusURL, "")
+	var status GroupStatusResponse
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+		t.Fatalf("decode group status: %v", err)
+	}
+	if status.Status != "completed" || status.Completed != 3 || status.ResultURL == nil || *status.DurationSeconds != 4.5 {
+		t.Errorf("unexpected group status %+v", status)
+	}
+
+	w = do(http.MethodGet, created.ResultURL, "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "audio/wav" {
+		t.Fatalf("expected the joined WAV, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	pcm, _, _, _, ok := transcode.ParseWAV(w.Body.Bytes())
+	if !ok || len(pcm) != 300 || pcm[0] != 1 || pcm[299] != 3 {
+		t.Errorf("expected the segments' PCM joined in order, got %d bytes", len(pcm))
+	}
+
+	w = do(http.MethodGet, created.ResultURL+"?packaging=zip", "")
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("read zip: %v", err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	if strings.Join(names, ",") != "01.wav,02.wav,03.wav" {
+		t.Errorf("unexpected zip entries %v", names)
+	}
+	rc, _ := zr.File[1].Open()
+	data, _ := io.ReadAll(rc)
+	if !bytes.Equal(data, storage.StoredFiles[jobs[1].ID]) {
+		t.Error("expected the second segment's result in 02.wav")
+	}
+
+	jobs[2].SetFailed("provider error")
+	w = do(http.MethodGet, created.ResultURL, "")
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), jobs[2].ID) {
+		t.Errorf("expected 409 naming the failed segment, got %d: %s", w.Code, w.Body.String())
+	}
+
+	for path, want := range map[string]int{
+		"/api/v1/groups/unknown":                     http.StatusNotFound,
+		created.ResultURL + "?packaging=tar":         http.StatusUnprocessableEntity,
+		created.ResultURL + "?disposition=somewhere": http.StatusUnprocessableEntity,
+	} {
+		if w := do(http.MethodGet, path, ""); w.Code != want {
+			t.Errorf("GET %s: expected %d, got %d", path, want, w.Code)
+		}
+	}
+}
+
+func TestJobsHandler_SubmitGroup_Invalid(t *testing.T) {
+	registry := mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"})
+	queue := memory.NewQueue(10)
+	h := NewJobsHandler(registry, queue, mocks.NewMockStorage(), testLogger(), "default-voice", 24, false, 0, nil, nil, nil, nil)
+
+	for name, tt := range map[string]struct {
+		body  string
+		field string
+		index float64
+	}{
+		"no segments":    {body: `{"segments": []}`, field: "segments", index: -1},
+		"other format":   {body: `{"output_format": "mp3", "segments": [{"text": "a"}, {"text": "b", "output_format": "wav"}]}`, field: "output_format", index: 1},
+		"missing text":   {body: `{"segments": [{"text": "a"}, {"text": "b"}, {}]}`, field: "text", index: 2},
+		"bad voice spec": {body: `{"segments": [{"text": "a", "voice_settings": {"stability": 7}}]}`, field: "", index: 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.SubmitGroup(w, httptest.NewRequest(http.MethodPost, "/api/v1/groups", strings.NewReader(tt.body)))
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("expected status 422, got %d: %s", w.Code, w.Body.String())
+			}
+			var resp struct {
+				Error domain.APIError `json:"error"`
+			}
+			json.NewDecoder(w.Body).Decode(&resp) //nolint:errcheck
+			if tt.field != "" && resp.Error.Details["field"] != tt.field {
+				t.Errorf("expected field %s, got %v", tt.field, resp.Error.Details)
+			}
+			if tt.index >= 0 && resp.Error.Details["index"] != tt.index {
+				t.Errorf("expected index %v, got %v", tt.index, resp.Error.Details)
+			}
+		})
+	}
+	if stats := queue.Stats(); stats.QueuedJobs != 0 {
+		t.Errorf("expected nothing queued from rejected groups, got %d", stats.QueuedJobs)
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/pako-tts/server/internal/api/middleware"
 	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/version"
 )
 
 // HealthHandler handles health check requests.
@@ -29,6 +30,8 @@ type HealthResponse struct {
 	Status    string                  `json:"status"`
 	Version   string                  `json:"version"`
 	Providers []domain.ProviderStatus `json:"providers"`
+	// Degraded lists the providers in degraded mode, with the reason.
+	Degraded []domain.ProviderDegradation `json:"degraded,omitempty"`
 }
 
 // HealthCheck handles GET /api/v1/health.
@@ -41,7 +44,8 @@ func (h *HealthHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 		providers = append(providers, p.Status(ctx))
 	}
 
-	// Determine overall status - healthy if at least one provider is available
+	// Determine overall status - healthy if at least one provider is available,
+	// degraded while the upstream keeps rate limiting one
 	status := "unhealthy"
 	for _, p := range providers {
 		if p.Available {
@@ -49,11 +53,19 @@ func (h *HealthHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 			break
 		}
 	}
+	var degraded []domain.ProviderDegradation
+	if degradations, ok := h.registry.(domain.ProviderDegradations); ok {
+		degraded = degradations.Degraded()
+	}
+	if status == "healthy" && len(degraded) > 0 {
+		status = "degraded"
+	}
 
 	response := HealthResponse{
 		Status:    status,
-		Version:   "0.0.1",
+		Version:   version.Version,
 		Providers: providers,
+		Degraded:  degraded,
 	}
 
 	middleware.WriteJSON(w, http.StatusOK, response)
@@ -5,10 +5,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/pako-tts/server/internal/api/handlers/mocks"
+	"github.com/pako-tts/server/internal/domain"
 )
 
 func testLogger() *zap.Logger {
@@ -50,3 +52,48 @@ func TestHealthCheck(t *testing.T) {
 		t.Error("Expected version to be set")
 	}
 }
+
+// degradedRegistry is a mock registry with providers in degraded mode.
+type degradedRegistry struct {
+	*mocks.MockProviderRegistry
+	degraded []domain.ProviderDegradation
+}
+
+func (r *degradedRegistry) Degraded() []domain.ProviderDegradation { return r.degraded }
+
+func (r *degradedRegistry) IsDegraded(name string) bool {
+	for _, d := range r.degraded {
+		if d.Provider == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestHealthCheck_Degraded(t *testing.T) {
+	mockProvider := &mocks.MockProvider{
+		NameValue:      "mock-provider",
+		AvailableValue: true,
+	}
+	registry := &degradedRegistry{
+		MockProviderRegistry: mocks.NewMockProviderRegistry(mockProvider),
+		degraded: []domain.ProviderDegradation{
+			{Provider: "mock-provider", Reason: "rate limited 5 times within 1m0s", Since: time.Now()},
+		},
+	}
+	handler := NewHealthHandler(registry, testLogger())
+
+	w := httptest.NewRecorder()
+	handler.HealthCheck(w, httptest.NewRequest(http.MethodGet, "/api/v1/health", nil))
+
+	var healthResp HealthResponse
+	if err := json.NewDecoder(w.Result().Body).Decode(&healthResp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if healthResp.Status != "degraded" {
+		t.Errorf("Expected status 'degraded', got %s", healthResp.Status)
+	}
+	if len(healthResp.Degraded) != 1 || healthResp.Degraded[0].Reason != registry.degraded[0].Reason {
+		t.Errorf("Expected the degraded provider with its reason, got %+v", healthResp.Degraded)
+	}
+}
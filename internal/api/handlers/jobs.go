@@ -1,15 +1,25 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 
 	"github.com/pako-tts/server/internal/api/middleware"
+	"github.com/pako-tts/server/internal/audio/transcode"
+	"github.com/pako-tts/server/internal/deadline"
 	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/metrics"
+	"github.com/pako-tts/server/internal/queue/dedup"
+	"github.com/pako-tts/server/internal/webhook"
 )
 
 // JobsHandler handles job-related requests.
@@ -20,9 +30,31 @@ type JobsHandler struct {
 	logger         *zap.Logger
 	defaultVoiceID string
 	retentionHours int
+	// artifactTTL keeps kinds of artifacts for their own period instead of as
+	// long as the result.
+	artifactTTL map[string]time.Duration
+	// resultURLs, when set, redirects result downloads to URLs signed for
+	// signedURLTTL.
+	resultURLs   domain.SignedURLs
+	signedURLTTL time.Duration
+	// clampSettings pulls out-of-range voice settings into range instead of rejecting them.
+	clampSettings bool
+	// regenerateGrace is how long after its result expires a job's text is kept
+	// for POST /jobs/{jobID}/regenerate.
+	regenerateGrace time.Duration
+	dedup           *dedup.Index
+	textMetrics     *metrics.TextMetrics
+	sources         domain.TextSourceResolver
+	// callbacks validates callback URLs; nil rejects jobs that set one.
+	callbacks *webhook.Dispatcher
+	// variantLocks keeps concurrent requests for the same result variant from
+	// transcoding it twice.
+	variantLocks keyLocks
 }
 
-// NewJobsHandler creates a new jobs handler.
+// NewJobsHandler creates a new jobs handler. A nil dedup index disables
+// duplicate-submission detection; nil textMetrics records nothing; nil sources
+// rejects jobs that reference a text source instead of carrying text.
 func NewJobsHandler(
 	registry domain.ProviderRegistry,
 	queue domain.JobQueue,
@@ -30,26 +62,63 @@ func NewJobsHandler(
 	logger *zap.Logger,
 	defaultVoiceID string,
 	retentionHours int,
+	clampSettings bool,
+	regenerateGrace time.Duration,
+	dedup *dedup.Index,
+	textMetrics *metrics.TextMetrics,
+	sources domain.TextSourceResolver,
+	callbacks *webhook.Dispatcher,
 ) *JobsHandler {
 	return &JobsHandler{
-		registry:       registry,
-		queue:          queue,
-		storage:        storage,
-		logger:         logger,
-		defaultVoiceID: defaultVoiceID,
-		retentionHours: retentionHours,
+		registry:        registry,
+		queue:           queue,
+		storage:         storage,
+		logger:          logger,
+		defaultVoiceID:  defaultVoiceID,
+		retentionHours:  retentionHours,
+		clampSettings:   clampSettings,
+		regenerateGrace: regenerateGrace,
+		dedup:           dedup,
+		textMetrics:     textMetrics,
+		sources:         sources,
+		callbacks:       callbacks,
 	}
 }
 
+// RetainArtifacts keeps each kind of artifact in retention for its own period
+// instead of as long as the result; it applies to result variants cached on
+// request.
+func (h *JobsHandler) RetainArtifacts(retention map[string]time.Duration) {
+	h.artifactTTL = retention
+}
+
+// RedirectResults answers result downloads with a 307 redirect to a URL the
+// storage backend signs for ttl, so the audio doesn't pass through the server.
+// Clients can opt out with ?redirect=false.
+func (h *JobsHandler) RedirectResults(urls domain.SignedURLs, ttl time.Duration) {
+	h.resultURLs = urls
+	h.signedURLTTL = ttl
+}
+
 // JobCreateRequest represents a job creation request.
 type JobCreateRequest struct {
-	Text          string                `json:"text"`
-	VoiceID       string                `json:"voice_id,omitempty"`
-	ModelID       string                `json:"model_id,omitempty"`
-	LanguageCode  string                `json:"language_code,omitempty"`
-	Provider      string                `json:"provider,omitempty"`
-	OutputFormat  string                `json:"output_format,omitempty"`
-	VoiceSettings *domain.VoiceSettings `json:"voice_settings,omitempty"`
+	Text          string                 `json:"text"`
+	VoiceID       string                 `json:"voice_id,omitempty"`
+	ModelID       string                 `json:"model_id,omitempty"`
+	LanguageCode  string                 `json:"language_code,omitempty"`
+	Provider      string                 `json:"provider,omitempty"`
+	OutputFormat  string                 `json:"output_format,omitempty"`
+	VoiceSettings *domain.VoiceSettings  `json:"voice_settings,omitempty"`
+	Padding       *domain.PaddingOptions `json:"padding,omitempty"`
+	// Source is where the worker fetches the text from, instead of Text.
+	Source *domain.TextSource `json:"source,omitempty"`
+	// Pipeline lists the text and audio stages run around synthesis, in order.
+	Pipeline []domain.PipelineStage `json:"pipeline,omitempty"`
+	// CallbackURL is notified when the job completes or fails.
+	CallbackURL string `json:"callback_url,omitempty"`
+	// Deadline is when the result stops being useful; the X-Deadline header when
+	// unset.
+	Deadline *time.Time `json:"deadline,omitempty"`
 }
 
 // JobCreateResponse represents a job creation response.
@@ -57,19 +126,49 @@ type JobCreateResponse struct {
 	JobID     string `json:"job_id"`
 	Status    string `json:"status"`
 	CreatedAt string `json:"created_at"`
+	// DuplicateOf is the earlier identical job this submission repeats.
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+	// Coalesced means no new job was created; JobID is the earlier job.
+	Coalesced bool `json:"coalesced,omitempty"`
+	// Warnings lists non-fatal issues with the request.
+	Warnings []domain.Warning `json:"warnings,omitempty"`
 }
 
 // JobStatusResponse represents a job status response.
 type JobStatusResponse struct {
-	JobID                 string  `json:"job_id"`
-	Status                string  `json:"status"`
-	ProviderName          string  `json:"provider_name"`
-	CreatedAt             string  `json:"created_at"`
-	StartedAt             *string `json:"started_at,omitempty"`
-	CompletedAt           *string `json:"completed_at,omitempty"`
-	ProgressPercentage    float64 `json:"progress_percentage"`
-	EstimatedCompletionAt *string `json:"estimated_completion_at,omitempty"`
-	ErrorMessage          *string `json:"error_message,omitempty"`
+	JobID                 string             `json:"job_id"`
+	Status                string             `json:"status"`
+	ProviderName          string             `json:"provider_name"`
+	ResultProvider        *string            `json:"result_provider,omitempty"`
+	CreatedAt             string             `json:"created_at"`
+	StartedAt             *string            `json:"started_at,omitempty"`
+	CompletedAt           *string            `json:"completed_at,omitempty"`
+	ProgressPercentage    float64            `json:"progress_percentage"`
+	EstimatedCompletionAt *string            `json:"estimated_completion_at,omitempty"`
+	Attempts              int                `json:"attempts,omitempty"`
+	MaxAttempts           int                `json:"max_attempts,omitempty"`
+	NextAttemptAt         *string            `json:"next_attempt_at,omitempty"`
+	Deadline              *string            `json:"deadline,omitempty"`
+	SpokenText            *string            `json:"spoken_text,omitempty"`
+	ErrorMessage          *string            `json:"error_message,omitempty"`
+	ErrorCode             *string            `json:"error_code,omitempty"`
+	ResultURL             *string            `json:"result_url,omitempty"`
+	ResultSizeBytes       *int64             `json:"result_size_bytes,omitempty"`
+	DurationSeconds       *float64           `json:"duration_seconds,omitempty"`
+	PreviewURL            *string            `json:"preview_url,omitempty"`
+	WaveformURL           *string            `json:"waveform_url,omitempty"`
+	ArtifactsURL          *string            `json:"artifacts_url,omitempty"`
+	DuplicateOf           *string            `json:"duplicate_of,omitempty"`
+	Events                []JobEventResponse `json:"events,omitempty"`
+	Manifest              *JobManifest       `json:"manifest,omitempty"`
+	Artifacts             []JobArtifact      `json:"artifacts,omitempty"`
+}
+
+// JobEventResponse is an entry in a job's history.
+type JobEventResponse struct {
+	At      string `json:"at"`
+	Type    string `json:"type"`
+	Message string `json:"message"`
 }
 
 // SubmitJob handles POST /api/v1/jobs.
@@ -82,14 +181,82 @@ func (h *JobsHandler) SubmitJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate text
-	if req.Text == "" {
-		middleware.WriteError(w, domain.ErrValidation.WithDetails(map[string]any{
-			"field":   "text",
-			"message": "Text is required",
-		}))
+	job, warnings, apiErr := h.newJob(r, &req)
+	if apiErr != nil {
+		middleware.WriteError(w, apiErr)
 		return
 	}
+	text, source := job.Text, job.Source
+
+	// Detect repeats of a recent identical submission
+	var dedupKey string
+	if h.dedup != nil {
+		dedupKey = dedup.Fingerprint(job)
+		if original := h.claimDuplicate(r, dedupKey, job.ID); original != nil {
+			if h.dedup.Mode() == dedup.ModeCoalesce {
+				h.logger.Info("Duplicate job coalesced",
+					zap.String("job_id", original.ID),
+					zap.Int("text_length", len(text)),
+				)
+				middleware.WriteJSON(w, http.StatusOK, JobCreateResponse{
+					JobID:       original.ID,
+					Status:      string(original.Status),
+					CreatedAt:   original.CreatedAt.Format("2006-01-02T15:04:05Z"),
+					DuplicateOf: original.ID,
+					Coalesced:   true,
+					Warnings:    warnings,
+				})
+				return
+			}
+			job.DuplicateOf = original.ID
+			job.AddEvent(domain.JobEventDuplicate, "same request as job "+original.ID)
+		}
+	}
+
+	// Enqueue job
+	if err := h.queue.Enqueue(ctx, job); err != nil {
+		if dedupKey != "" {
+			h.dedup.Release(dedupKey, job.ID)
+		}
+		h.writeEnqueueError(w, job, err)
+		return
+	}
+	if source == nil {
+		h.textMetrics.Observe(metrics.SourceAsync, job.Text, job.LanguageCode)
+	}
+
+	h.logger.Info("Job created",
+		zap.String("job_id", job.ID),
+		zap.Int("text_length", len(text)),
+		zap.String("duplicate_of", job.DuplicateOf),
+	)
+
+	response := JobCreateResponse{
+		JobID:       job.ID,
+		Status:      string(job.Status),
+		CreatedAt:   job.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		DuplicateOf: job.DuplicateOf,
+		Warnings:    warnings,
+	}
+
+	middleware.WriteJSON(w, http.StatusCreated, response)
+}
+
+// newJob validates a job request and builds its job, with the warnings the
+// request deserves.
+func (h *JobsHandler) newJob(r *http.Request, req *JobCreateRequest) (*domain.Job, []domain.Warning, *domain.APIError) {
+	// Validate text
+	text, source, apiErr := h.jobText(req)
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+	field := "text"
+	if req.Source != nil {
+		field = "source.text"
+	}
+	if apiErr := checkTextRules(r, field, text); apiErr != nil {
+		return nil, nil, apiErr
+	}
 
 	// Set defaults
 	voiceID := req.VoiceID
@@ -99,71 +266,378 @@ func (h *JobsHandler) SubmitJob(w http.ResponseWriter, r *http.Request) {
 
 	outputFormat := req.OutputFormat
 	if outputFormat == "" {
-		outputFormat = "mp3"
+		outputFormat = defaultOutputFormat(r)
 	}
 
 	// Validate output format
-	if outputFormat != "mp3" && outputFormat != "wav" {
-		middleware.WriteError(w, domain.ErrInvalidFormat)
-		return
+	if !transcode.IsOutputFormat(outputFormat) {
+		return nil, nil, domain.ErrInvalidFormat
+	}
+
+	if apiErr := validatePadding(req.Padding); apiErr != nil {
+		return nil, nil, apiErr
+	}
+	if _, apiErr := validatePipeline(req.Pipeline); apiErr != nil {
+		return nil, nil, apiErr
+	}
+	if apiErr := h.validateCallbackURL(req.CallbackURL); apiErr != nil {
+		return nil, nil, apiErr
+	}
+	jobDeadline, apiErr := requestDeadline(r, req.Deadline)
+	if apiErr != nil {
+		return nil, nil, apiErr
 	}
 
 	providerName := req.Provider
 	if providerName == "" {
-		providerName = h.registry.DefaultName()
+		providerName = h.registry.Route(r.Context(), len(text))
 	}
 
 	// Validate provider exists
-	if _, err := h.registry.Get(providerName); err != nil {
-		middleware.WriteError(w, domain.ErrProviderNotFound.WithMessage("Provider '"+providerName+"' not found"))
-		return
+	provider, err := h.registry.Get(providerName)
+	if err != nil {
+		return nil, nil, domain.ErrProviderNotFound.WithMessage("Provider '" + providerName + "' not found")
 	}
 
+	voiceSettings, clamped, apiErr := checkVoiceSettings(provider, req.VoiceSettings, h.clampSettings)
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+	if len(clamped) > 0 {
+		h.logger.Info("Voice settings clamped", zap.String("provider", providerName), zap.Strings("fields", clamped))
+	}
+	warnings := requestWarnings(text, req.LanguageCode, clamped)
+
 	// Create job
-	job := domain.NewJob(req.Text, voiceID, req.ModelID, req.LanguageCode, providerName, outputFormat, req.VoiceSettings)
+	job := domain.NewJob(text, voiceID, req.ModelID, req.LanguageCode, providerName, outputFormat, voiceSettings)
+	job.Padding = req.Padding
+	job.Pipeline = req.Pipeline
+	job.TenantID = middleware.TenantFromRequest(r)
+	job.Source = source
+	job.CallbackURL = req.CallbackURL
+	job.Deadline = jobDeadline
+	job.Reply = domain.JobReplyFromContext(r.Context())
+	return job, warnings, nil
+}
 
-	// Enqueue job
-	if err := h.queue.Enqueue(ctx, job); err != nil {
-		h.logger.Error("Failed to enqueue job", zap.Error(err))
+// jobText returns the text of a job request, or the source the worker fetches it
+// from. Inline sources are unwrapped so the job carries its text directly.
+func (h *JobsHandler) jobText(req *JobCreateRequest) (string, *domain.TextSource, *domain.APIError) {
+	switch {
+	case req.Source == nil && req.Text == "":
+		return "", nil, domain.ErrValidation.WithDetails(map[string]any{
+			"field":   "text",
+			"message": "Text is required",
+		})
+	case req.Source == nil:
+		return req.Text, nil, nil
+	case req.Text != "":
+		return "", nil, domain.ErrValidation.WithDetails(map[string]any{
+			"field":   "source",
+			"message": "Provide either text or source, not both",
+		})
+	}
+
+	var err error = domain.NewTextSourceError(domain.SourceErrUnsupported, "text sources are not enabled", nil)
+	if h.sources != nil {
+		err = h.sources.Validate(req.Source)
+	}
+	if err != nil {
+		details := map[string]any{"field": "source", "message": err.Error()}
+		if serr, ok := domain.AsTextSourceError(err); ok {
+			details["source_error"] = serr.Code
+			details["message"] = serr.Message
+		}
+		return "", nil, domain.ErrInvalidTextSource.WithDetails(details)
+	}
+
+	if req.Source.Type == domain.TextSourceInline {
+		return req.Source.Text, nil, nil
+	}
+	return "", req.Source, nil
+}
+
+// writeEnqueueError answers a failed Enqueue: 503 with Retry-After when the queue
+// is busy, 500 otherwise.
+func (h *JobsHandler) writeEnqueueError(w http.ResponseWriter, job *domain.Job, err error) {
+	if errors.Is(err, domain.ErrQueueBusy) {
+		h.logger.Warn("Job queue busy, rejecting job", zap.Int("text_length", len(job.Text)))
+		w.Header().Set("Retry-After", "1")
+		middleware.WriteError(w, domain.ErrQueueBusy)
+		return
+	}
+	h.logger.Error("Failed to enqueue job", zap.Error(err))
+	middleware.WriteError(w, domain.ErrInternalServer)
+}
+
+// claimDuplicate claims key for jobID and returns the earlier job holding it, if
+// any. An earlier job that failed, was cancelled, expired or is gone gives up its
+// claim to jobID.
+func (h *JobsHandler) claimDuplicate(r *http.Request, key, jobID string) *domain.Job {
+	originalID, dup := h.dedup.Claim(key, jobID)
+	if !dup {
+		return nil
+	}
+	original, err := h.queue.GetJob(r.Context(), originalID)
+	if err == nil {
+		switch original.Status {
+		case domain.JobStatusFailed, domain.JobStatusCancelled, domain.JobStatusExpired:
+		default:
+			return original
+		}
+	}
+	h.dedup.Release(key, originalID)
+	h.dedup.Claim(key, jobID)
+	return nil
+}
+
+// GetJobStatus handles GET /api/v1/jobs/{jobID}. ?fields= keeps only the listed
+// fields and ?include= adds the events, manifest or artifacts expansions; the
+// events are included by default when neither is set.
+func (h *JobsHandler) GetJobStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	shape, apiErr := parseResponseShape(r)
+	if apiErr != nil {
+		middleware.WriteError(w, apiErr)
+		return
+	}
+
+	job, ok := h.ownJob(w, r)
+	if !ok {
+		return
+	}
+
+	middleware.WriteJSON(w, http.StatusOK, h.jobStatus(ctx, job, shape))
+}
+
+const (
+	defaultJobListLimit = 20
+	maxJobListLimit     = 100
+)
+
+// JobListResponse is a page of jobs. NextCursor is set when more jobs follow.
+type JobListResponse struct {
+	Jobs       []JobStatusResponse `json:"jobs"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+// shapedJobListResponse is a JobListResponse whose jobs were shaped with
+// ?fields= or ?include=.
+type shapedJobListResponse struct {
+	Jobs       []any  `json:"jobs"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ListJobs handles GET /api/v1/jobs. Jobs are listed newest first (?order=asc for
+// oldest first) and can be narrowed with ?status=, ?provider_name=, ?voice_id=
+// and ?output_format=. ?limit= sets the page size (default 20, at most 100);
+// ?cursor= continues from a previous page's next_cursor. ?fields= and ?include=
+// shape each job as for GetJobStatus.
+func (h *JobsHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	filter, apiErr := parseJobFilter(r)
+	if apiErr != nil {
+		middleware.WriteError(w, apiErr)
+		return
+	}
+	shape, apiErr := parseResponseShape(r)
+	if apiErr != nil {
+		middleware.WriteError(w, apiErr)
+		return
+	}
+
+	page, err := h.queue.ListJobs(r.Context(), filter)
+	if err != nil {
+		h.logger.Error("Failed to list jobs", zap.Error(err))
 		middleware.WriteError(w, domain.ErrInternalServer)
 		return
 	}
 
-	h.logger.Info("Job created",
-		zap.String("job_id", job.ID),
-		zap.Int("text_length", len(req.Text)),
-	)
+	var nextCursor string
+	if page.Next != nil {
+		nextCursor = page.Next.String()
+	}
+	if shape.shaped {
+		resp := shapedJobListResponse{Jobs: make([]any, 0, len(page.Jobs)), NextCursor: nextCursor}
+		for _, job := range page.Jobs {
+			resp.Jobs = append(resp.Jobs, h.jobStatus(r.Context(), job, shape))
+		}
+		middleware.WriteJSON(w, http.StatusOK, resp)
+		return
+	}
 
-	response := JobCreateResponse{
-		JobID:     job.ID,
-		Status:    string(job.Status),
-		CreatedAt: job.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	resp := JobListResponse{Jobs: make([]JobStatusResponse, 0, len(page.Jobs)), NextCursor: nextCursor}
+	for _, job := range page.Jobs {
+		resp.Jobs = append(resp.Jobs, newJobStatusResponse(job))
 	}
+	middleware.WriteJSON(w, http.StatusOK, resp)
+}
 
-	middleware.WriteJSON(w, http.StatusCreated, response)
+func parseJobFilter(r *http.Request) (domain.JobFilter, *domain.APIError) {
+	params := r.URL.Query()
+	filter := domain.JobFilter{
+		Tenant:       tenantFilter(r),
+		Provider:     params.Get("provider_name"),
+		VoiceID:      params.Get("voice_id"),
+		OutputFormat: params.Get("output_format"),
+		Limit:        defaultJobListLimit,
+	}
+
+	switch status := domain.JobStatus(params.Get("status")); status {
+	case "", domain.JobStatusQueued, domain.JobStatusProcessing, domain.JobStatusCompleted,
+		domain.JobStatusFailed, domain.JobStatusCancelled, domain.JobStatusExpired:
+		filter.Status = status
+	default:
+		return filter, domain.ErrValidation.WithDetails(map[string]any{
+			"field":   "status",
+			"message": "status must be one of queued, processing, completed, failed, cancelled, expired",
+		})
+	}
+
+	switch params.Get("order") {
+	case "", "desc":
+	case "asc":
+		filter.Ascending = true
+	default:
+		return filter, domain.ErrValidation.WithDetails(map[string]any{
+			"field":   "order",
+			"message": "order must be 'asc' or 'desc'",
+		})
+	}
+
+	if v := params.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxJobListLimit {
+			return filter, domain.ErrValidation.WithDetails(map[string]any{
+				"field":   "limit",
+				"message": "limit must be between 1 and 100",
+			})
+		}
+		filter.Limit = limit
+	}
+
+	if v := params.Get("cursor"); v != "" {
+		cursor, err := domain.ParseJobCursor(v)
+		if err != nil {
+			return filter, domain.ErrValidation.WithDetails(map[string]any{
+				"field":   "cursor",
+				"message": "cursor must be a next_cursor returned by this endpoint",
+			})
+		}
+		filter.After = cursor
+	}
+	return filter, nil
 }
 
-// GetJobStatus handles GET /api/v1/jobs/{jobID}.
-func (h *JobsHandler) GetJobStatus(w http.ResponseWriter, r *http.Request) {
+// validateCallbackURL checks a job's callback URL like a webhook URL. An empty
+// URL is valid.
+func (h *JobsHandler) validateCallbackURL(rawURL string) *domain.APIError {
+	if rawURL == "" {
+		return nil
+	}
+	err := errors.New("callbacks are not enabled")
+	if h.callbacks != nil {
+		err = h.callbacks.ValidateURL(rawURL)
+	}
+	if err != nil {
+		return domain.ErrValidation.WithDetails(map[string]any{
+			"field":   "callback_url",
+			"message": err.Error(),
+		})
+	}
+	return nil
+}
+
+// requestDeadline returns a job's deadline: requested when set, else the
+// request's X-Deadline header. A deadline that already passed is rejected, so
+// the job never reaches a provider.
+func requestDeadline(r *http.Request, requested *time.Time) (*time.Time, *domain.APIError) {
+	if requested == nil {
+		t, ok, err := deadline.FromRequest(r)
+		if err != nil {
+			return nil, domain.ErrValidation.WithDetails(map[string]any{
+				"field":   deadline.Header,
+				"message": "must be an RFC 3339 timestamp",
+			})
+		}
+		if !ok {
+			return nil, nil
+		}
+		requested = &t
+	}
+	if !requested.After(time.Now()) {
+		return nil, domain.ErrDeadlineExceeded
+	}
+	t := requested.UTC()
+	return &t, nil
+}
+
+// defaultOutputFormat returns the output format of a request that names none:
+// the one set on the API key that authenticated it, or mp3.
+func defaultOutputFormat(r *http.Request) string {
+	if key := middleware.APIKeyFromContext(r.Context()); key != nil && key.OutputFormat != "" {
+		return key.OutputFormat
+	}
+	return "mp3"
+}
+
+// tenantFilter returns the tenant a listing is limited to. Callers authenticated
+// with an API key only see their own tenant's jobs; without authentication
+// ?tenant= picks one, and an empty result means every tenant.
+func tenantFilter(r *http.Request) string {
+	if key := middleware.APIKeyFromContext(r.Context()); key != nil && key.Name != "" {
+		return key.Name
+	}
+	return r.URL.Query().Get("tenant")
+}
+
+// CancelJob handles DELETE /api/v1/jobs/{jobID}. A queued job is cancelled at once
+// (200); for a job being processed cancellation is requested and its worker aborts
+// it shortly (202). Finished jobs can't be cancelled.
+func (h *JobsHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	jobID := chi.URLParam(r, "jobID")
+	if _, ok := h.ownJob(w, r); !ok {
+		return
+	}
 
-	job, err := h.queue.GetJob(ctx, jobID)
+	job, err := h.queue.Cancel(ctx, jobID)
+	if errors.Is(err, domain.ErrJobNotCancellable) {
+		details := map[string]any{}
+		if current, err := h.queue.GetJob(ctx, jobID); err == nil {
+			details["current_status"] = string(current.Status)
+		}
+		middleware.WriteError(w, domain.ErrJobNotCancellable.WithDetails(details))
+		return
+	}
 	if err != nil {
 		if apiErr, ok := err.(*domain.APIError); ok {
 			middleware.WriteError(w, apiErr)
 		} else {
-			middleware.WriteError(w, domain.ErrJobNotFound)
+			h.logger.Error("Failed to cancel job", zap.Error(err), zap.String("job_id", jobID))
+			middleware.WriteError(w, domain.ErrInternalServer)
 		}
 		return
 	}
 
+	status := http.StatusOK
+	if job.Status != domain.JobStatusCancelled {
+		status = http.StatusAccepted
+	}
+	h.logger.Info("Job cancellation requested", zap.String("job_id", job.ID), zap.String("status", string(job.Status)))
+	middleware.WriteJSON(w, status, newJobStatusResponse(job))
+}
+
+// newJobStatusResponse describes a job for the status and cancel endpoints.
+func newJobStatusResponse(job *domain.Job) JobStatusResponse {
 	response := JobStatusResponse{
 		JobID:              job.ID,
 		Status:             string(job.Status),
 		ProviderName:       job.ProviderName,
 		CreatedAt:          job.CreatedAt.Format("2006-01-02T15:04:05Z"),
 		ProgressPercentage: job.ProgressPercentage,
+		Attempts:           job.Attempts,
+		MaxAttempts:        job.MaxAttempts,
 	}
 
 	if job.StartedAt != nil {
@@ -181,11 +655,67 @@ func (h *JobsHandler) GetJobStatus(w http.ResponseWriter, r *http.Request) {
 		response.EstimatedCompletionAt = &estimatedAt
 	}
 
+	if job.NextAttemptAt != nil {
+		nextAttemptAt := job.NextAttemptAt.Format("2006-01-02T15:04:05Z")
+		response.NextAttemptAt = &nextAttemptAt
+	}
+
+	if job.Deadline != nil {
+		jobDeadline := deadline.Format(*job.Deadline)
+		response.Deadline = &jobDeadline
+	}
+
 	if job.ErrorMessage != "" {
 		response.ErrorMessage = &job.ErrorMessage
 	}
+	if job.ErrorCode != "" {
+		response.ErrorCode = &job.ErrorCode
+	}
+
+	for _, event := range job.Events {
+		response.Events = append(response.Events, JobEventResponse{
+			At:      event.At.Format("2006-01-02T15:04:05.000Z"),
+			Type:    event.Type,
+			Message: event.Message,
+		})
+	}
+
+	if job.DuplicateOf != "" {
+		response.DuplicateOf = &job.DuplicateOf
+	}
+
+	if job.ResultProvider != "" {
+		response.ResultProvider = &job.ResultProvider
+	}
+
+	if job.SpokenText != "" {
+		response.SpokenText = &job.SpokenText
+	}
+
+	if job.Status == domain.JobStatusCompleted {
+		resultURL := "/api/v1/jobs/" + job.ID + "/result"
+		response.ResultURL = &resultURL
+		if job.ResultSizeBytes > 0 {
+			response.ResultSizeBytes = &job.ResultSizeBytes
+		}
+		if job.AudioSeconds > 0 {
+			response.DurationSeconds = &job.AudioSeconds
+		}
+		artifactsURL := "/api/v1/jobs/" + job.ID + "/artifacts"
+		response.ArtifactsURL = &artifactsURL
+	}
+
+	if job.ArtifactAvailable(domain.ArtifactPreview) {
+		previewURL := "/api/v1/jobs/" + job.ID + "/preview"
+		response.PreviewURL = &previewURL
+	}
+
+	if job.ArtifactAvailable(domain.ArtifactWaveform) {
+		waveformURL := "/api/v1/jobs/" + job.ID + "/waveform"
+		response.WaveformURL = &waveformURL
+	}
 
-	middleware.WriteJSON(w, http.StatusOK, response)
+	return response
 }
 
 // GetJobResult handles GET /api/v1/jobs/{jobID}/result.
@@ -193,45 +723,483 @@ func (h *JobsHandler) GetJobResult(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	jobID := chi.URLParam(r, "jobID")
 
-	job, err := h.queue.GetJob(ctx, jobID)
-	if err != nil {
-		if apiErr, ok := err.(*domain.APIError); ok {
+	job, ok := h.completedJob(w, r, "")
+	if !ok {
+		return
+	}
+
+	disposition, apiErr := requestedDisposition(r)
+	if apiErr != nil {
+		middleware.WriteError(w, apiErr)
+		return
+	}
+
+	// ?format= wins over the Accept header
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		w.Header().Add("Vary", "Accept")
+		if format, apiErr = negotiateFormat(r, job.OutputFormat, "mp3", "wav", "ogg", "ogg_opus", "flac", "mulaw"); apiErr != nil {
 			middleware.WriteError(w, apiErr)
-		} else {
-			middleware.WriteError(w, domain.ErrJobNotFound)
+			return
 		}
+	}
+	if format != job.OutputFormat {
+		h.serveResultVariant(w, r, job, format, disposition)
 		return
 	}
 
-	// Check if job is complete
-	if job.Status != domain.JobStatusCompleted {
+	redirect := h.resultURLs != nil
+	if v := r.URL.Query().Get("redirect"); v != "" {
+		allowed, err := strconv.ParseBool(v)
+		if err != nil {
+			middleware.WriteError(w, domain.ErrValidation.WithDetails(map[string]any{
+				"field": "redirect", "message": "must be true or false",
+			}))
+			return
+		}
+		redirect = redirect && allowed
+	}
+	if redirect {
+		signed, err := h.resultURLs.SignedURL(ctx, jobID, h.signedURLTTL, contentDisposition(disposition, resultFilename(job, job.OutputFormat)))
+		if err == nil {
+			w.Header().Set("Cache-Control", "no-store")
+			http.Redirect(w, r, signed, http.StatusTemporaryRedirect)
+			return
+		}
+		// Serving the result ourselves is slower, not wrong
+		h.logger.Warn("Failed to sign result URL", zap.Error(err), zap.String("job_id", jobID))
+	}
+
+	// Retrieve audio
+	reader, contentType, err := h.storage.Retrieve(ctx, jobID)
+	if err != nil {
+		h.logger.Error("Failed to retrieve audio", zap.Error(err), zap.String("job_id", jobID))
+		middleware.WriteError(w, h.expiredError(job))
+		return
+	}
+	defer reader.Close() //nolint:errcheck
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", contentDisposition(disposition, resultFilename(job, job.OutputFormat)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	h.serveContent(w, r, job, domain.ResultVariant(job.OutputFormat), reader)
+}
+
+// DeleteJobResult handles DELETE /api/v1/jobs/{jobID}/result. It removes the
+// result with its variants and artifacts before its retention period ends, and
+// marks the job expired. With ?delete_job=true the job is archived as well, so
+// only its record in analytics remains.
+func (h *JobsHandler) DeleteJobResult(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	jobID := chi.URLParam(r, "jobID")
+
+	deleteJob := false
+	if v := r.URL.Query().Get("delete_job"); v != "" {
+		var err error
+		if deleteJob, err = strconv.ParseBool(v); err != nil {
+			middleware.WriteError(w, domain.ErrValidation.WithDetails(map[string]any{
+				"field": "delete_job", "message": "must be true or false",
+			}))
+			return
+		}
+	}
+	archive, canArchive := h.queue.(domain.JobArchive)
+	if deleteJob && !canArchive {
+		middleware.WriteError(w, domain.ErrValidation.WithDetails(map[string]any{
+			"field": "delete_job", "message": "the job store can't delete jobs",
+		}))
+		return
+	}
+
+	job, ok := h.ownJob(w, r)
+	if !ok {
+		return
+	}
+	if job.Status != domain.JobStatusCompleted && job.Status != domain.JobStatusExpired {
 		middleware.WriteError(w, domain.ErrJobNotComplete.WithDetails(map[string]any{
 			"current_status": string(job.Status),
 		}))
 		return
 	}
 
-	// Check if result has expired
-	if job.IsExpired() {
-		middleware.WriteError(w, domain.ErrResultExpired)
+	// An expired job's files are already gone
+	if job.Status == domain.JobStatusCompleted {
+		if err := h.storage.Delete(ctx, jobID); err != nil {
+			h.logger.Error("Failed to delete result", zap.Error(err), zap.String("job_id", jobID))
+			middleware.WriteError(w, domain.ErrInternalServer)
+			return
+		}
+		if err := job.SetResultDeleted(); err == nil {
+			if err := h.queue.UpdateJob(ctx, job); err != nil {
+				h.logger.Error("Failed to mark job expired", zap.Error(err), zap.String("job_id", jobID))
+				middleware.WriteError(w, domain.ErrInternalServer)
+				return
+			}
+		}
+	}
+
+	if deleteJob {
+		if err := archive.ArchiveJob(ctx, job); err != nil {
+			h.logger.Error("Failed to archive job", zap.Error(err), zap.String("job_id", jobID))
+			middleware.WriteError(w, domain.ErrInternalServer)
+			return
+		}
+	}
+
+	h.logger.Info("Job result deleted", zap.String("job_id", jobID), zap.Bool("job_deleted", deleteJob))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveResultVariant streams the job's result transcoded to format. The first
+// request transcodes the stored result and keeps the variant as an artifact, so
+// later requests for the same format are served from storage.
+func (h *JobsHandler) serveResultVariant(w http.ResponseWriter, r *http.Request, job *domain.Job, format, disposition string) {
+	if !transcode.CanConvertTo(format) {
+		middleware.WriteError(w, domain.ErrInvalidFormat.WithMessage("Invalid format. Must be 'mp3', 'wav', 'ogg', 'ogg_opus', 'flac' or 'mulaw'."))
 		return
 	}
 
-	// Retrieve audio
-	reader, contentType, err := h.storage.Retrieve(ctx, jobID)
+	data, apiErr := h.resultVariant(r.Context(), job, format)
+	if apiErr != nil {
+		middleware.WriteError(w, apiErr)
+		return
+	}
+
+	w.Header().Set("Content-Type", transcode.ContentType(format))
+	w.Header().Set("Content-Disposition", contentDisposition(disposition, resultFilename(job, format)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	h.serveContent(w, r, job, domain.ResultVariant(format), bytes.NewReader(data))
+}
+
+// serveContent writes a file of a completed job with http.ServeContent, so
+// players can seek with Range requests and clients revalidate downloads with
+// If-None-Match or If-Modified-Since. Stored files never change, so the job ID
+// and file name make a strong ETag. Content that can't seek is read into memory.
+func (h *JobsHandler) serveContent(w http.ResponseWriter, r *http.Request, job *domain.Job, name string, content io.Reader) {
+	seeker, ok := content.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(content)
+		if err != nil {
+			h.logger.Error("Failed to read stored file", zap.Error(err), zap.String("job_id", job.ID), zap.String("name", name))
+			middleware.WriteError(w, domain.ErrInternalServer)
+			return
+		}
+		seeker = bytes.NewReader(data)
+	}
+
+	var modified time.Time
+	if job.CompletedAt != nil {
+		modified = *job.CompletedAt
+	}
+	w.Header().Set("ETag", `"`+job.ID+"/"+name+`"`)
+	http.ServeContent(w, r, "", modified, seeker)
+}
+
+// resultVariant returns the cached variant of the job's result in format,
+// transcoding and caching it if needed.
+func (h *JobsHandler) resultVariant(ctx context.Context, job *domain.Job, format string) ([]byte, *domain.APIError) {
+	name := domain.ResultVariant(format)
+	logger := h.logger.With(zap.String("job_id", job.ID), zap.String("format", format))
+
+	unlock := h.variantLocks.lock(job.ID + "/" + name)
+	defer unlock()
+
+	if job.ArtifactAvailable(name) {
+		if data, err := h.readArtifact(ctx, job.ID, name); err == nil {
+			return data, nil
+		}
+		logger.Warn("Cached result variant unreadable; transcoding again")
+	}
+
+	reader, _, err := h.storage.Retrieve(ctx, job.ID)
 	if err != nil {
-		h.logger.Error("Failed to retrieve audio", zap.Error(err), zap.String("job_id", jobID))
-		middleware.WriteError(w, domain.ErrResultExpired)
+		logger.Error("Failed to retrieve audio", zap.Error(err))
+		return nil, h.expiredError(job)
+	}
+	master, err := io.ReadAll(reader)
+	reader.Close() //nolint:errcheck
+	if err != nil {
+		logger.Error("Failed to read audio", zap.Error(err))
+		return nil, domain.ErrInternalServer
+	}
+
+	start := time.Now()
+	data, err := transcode.Convert(ctx, transcode.Decodable(master, job.OutputFormat), format)
+	if err != nil {
+		logger.Error("Failed to transcode result", zap.Error(err))
+		return nil, domain.ErrInternalServer.WithMessage("Failed to transcode result to " + format)
+	}
+	logger.Info("Result transcoded", zap.Duration("duration", time.Since(start)), zap.Int("size", len(data)))
+
+	// A variant that can't be cached is still served; the next request transcodes again.
+	if err := h.storage.StoreArtifact(ctx, job.ID, name, data); err != nil {
+		logger.Warn("Failed to cache result variant", zap.Error(err))
+		return data, nil
+	}
+	job.AddArtifact(name)
+	job.RetainArtifact(name, domain.RetentionPolicy{
+		Result:    time.Duration(h.retentionHours) * time.Hour,
+		Artifacts: h.artifactTTL,
+	})
+	if err := h.queue.UpdateJob(ctx, job); err != nil {
+		logger.Warn("Failed to record result variant", zap.Error(err))
+	}
+	return data, nil
+}
+
+func (h *JobsHandler) readArtifact(ctx context.Context, jobID, name string) ([]byte, error) {
+	reader, err := h.storage.RetrieveArtifact(ctx, jobID, name)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close() //nolint:errcheck
+	return io.ReadAll(reader)
+}
+
+// GetJobPreview handles GET /api/v1/jobs/{jobID}/preview.
+func (h *JobsHandler) GetJobPreview(w http.ResponseWriter, r *http.Request) {
+	h.serveArtifact(w, r, domain.ArtifactPreview, "audio/mpeg")
+}
+
+// GetJobWaveform handles GET /api/v1/jobs/{jobID}/waveform.
+func (h *JobsHandler) GetJobWaveform(w http.ResponseWriter, r *http.Request) {
+	h.serveArtifact(w, r, domain.ArtifactWaveform, "application/json")
+}
+
+// serveArtifact streams a derived file of a completed job, or of an expired one
+// while the file is kept longer than the result.
+func (h *JobsHandler) serveArtifact(w http.ResponseWriter, r *http.Request, name, contentType string) {
+	job, ok := h.completedJob(w, r, name)
+	if !ok {
+		return
+	}
+
+	if !job.ArtifactAvailable(name) {
+		middleware.WriteError(w, domain.ErrArtifactNotFound)
+		return
+	}
+
+	reader, err := h.storage.RetrieveArtifact(r.Context(), job.ID, name)
+	if err != nil {
+		h.logger.Error("Failed to retrieve artifact", zap.Error(err), zap.String("job_id", job.ID), zap.String("artifact", name))
+		middleware.WriteError(w, domain.ErrArtifactNotFound)
 		return
 	}
 	defer reader.Close() //nolint:errcheck
 
-	// Stream audio response
 	w.Header().Set("Content-Type", contentType)
-	w.Header().Set("Content-Disposition", "attachment; filename=\""+jobID+"."+job.OutputFormat+"\"")
-	w.WriteHeader(http.StatusOK)
+	h.serveContent(w, r, job, name, reader)
+}
 
-	if _, err := io.Copy(w, reader); err != nil {
-		h.logger.Error("Failed to write audio response", zap.Error(err))
+// completedJob loads the job named in the URL and checks that its result is
+// available, writing the matching error response when it isn't. With artifact
+// set, a job whose result expired passes while that artifact is kept.
+func (h *JobsHandler) completedJob(w http.ResponseWriter, r *http.Request, artifact string) (*domain.Job, bool) {
+	job, ok := h.ownJob(w, r)
+	if !ok {
+		return nil, false
 	}
+
+	// Check if job is complete
+	if job.Status != domain.JobStatusCompleted && job.Status != domain.JobStatusExpired {
+		middleware.WriteError(w, domain.ErrJobNotComplete.WithDetails(map[string]any{
+			"current_status": string(job.Status),
+		}))
+		return nil, false
+	}
+
+	// Check if result has expired
+	if job.IsExpired() && (artifact == "" || !job.ArtifactAvailable(artifact)) {
+		middleware.WriteError(w, h.expiredError(job))
+		return nil, false
+	}
+
+	return job, true
+}
+
+// ownJob loads the job named in the URL, writing the error response when it
+// can't. A job of another tenant than the caller's is not found, so callers can
+// neither reach other tenants' jobs nor tell that they exist.
+func (h *JobsHandler) ownJob(w http.ResponseWriter, r *http.Request) (*domain.Job, bool) {
+	job, err := h.queue.GetJob(r.Context(), chi.URLParam(r, "jobID"))
+	if err == nil && job.Tenant() != middleware.TenantFromRequest(r) {
+		err = domain.ErrJobNotFound
+	}
+	if err != nil {
+		if apiErr, ok := err.(*domain.APIError); ok {
+			middleware.WriteError(w, apiErr)
+		} else {
+			middleware.WriteError(w, domain.ErrJobNotFound)
+		}
+		return nil, false
+	}
+	return job, true
+}
+
+// textRetained reports whether the text of a completed job is still kept for
+// regeneration: always while the result is available, then for the grace period.
+func (h *JobsHandler) textRetained(job *domain.Job) bool {
+	return job.ExpiresAt == nil || time.Now().Before(job.ExpiresAt.Add(h.regenerateGrace))
+}
+
+// expiredError describes an expired result with the original request parameters,
+// so a client can recover without having stored its own request.
+func (h *JobsHandler) expiredError(job *domain.Job) *domain.APIError {
+	original := map[string]any{
+		"voice_id":      job.VoiceID,
+		"provider":      job.ProviderName,
+		"output_format": job.OutputFormat,
+	}
+	if job.ModelID != "" {
+		original["model_id"] = job.ModelID
+	}
+	if job.LanguageCode != "" {
+		original["language_code"] = job.LanguageCode
+	}
+	if job.VoiceSettings != nil {
+		original["voice_settings"] = job.VoiceSettings
+	}
+	if job.Padding != nil {
+		original["padding"] = job.Padding
+	}
+	if job.Pipeline != nil {
+		original["pipeline"] = job.Pipeline
+	}
+
+	retained := h.textRetained(job)
+	if retained {
+		original["text"] = job.Text
+	}
+
+	details := map[string]any{
+		"original_request": original,
+		"text_retained":    retained,
+		"regenerate_url":   "/api/v1/jobs/" + job.ID + "/regenerate",
+	}
+	if job.ExpiresAt != nil {
+		details["expired_at"] = job.ExpiresAt.Format("2006-01-02T15:04:05Z")
+	}
+	return domain.ErrResultExpired.WithDetails(details)
+}
+
+// JobRegenerateRequest optionally supplies the text of a job whose text is no
+// longer retained.
+type JobRegenerateRequest struct {
+	Text string `json:"text,omitempty"`
+}
+
+// RegenerateJob handles POST /api/v1/jobs/{jobID}/regenerate. It submits a new job
+// with the original job's parameters.
+func (h *JobsHandler) RegenerateJob(w http.ResponseWriter, r *http.Request) {
+	original, ok := h.ownJob(w, r)
+	if !ok {
+		return
+	}
+	if original.Status != domain.JobStatusCompleted && original.Status != domain.JobStatusExpired {
+		middleware.WriteError(w, domain.ErrJobNotComplete.WithDetails(map[string]any{
+			"current_status": string(original.Status),
+		}))
+		return
+	}
+
+	var req JobRegenerateRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			middleware.WriteError(w, domain.ErrValidation.WithMessage("Invalid JSON body"))
+			return
+		}
+	}
+
+	text := req.Text
+	if text == "" {
+		if !h.textRetained(original) {
+			middleware.WriteError(w, domain.ErrValidation.WithDetails(map[string]any{
+				"field":   "text",
+				"message": "The original text is no longer retained; send it in the request body",
+			}))
+			return
+		}
+		text = original.Text
+	}
+	if apiErr := checkTextRules(r, "text", text); apiErr != nil {
+		middleware.WriteError(w, apiErr)
+		return
+	}
+
+	if _, err := h.registry.Get(original.ProviderName); err != nil {
+		middleware.WriteError(w, domain.ErrProviderNotFound.WithMessage("Provider '"+original.ProviderName+"' not found"))
+		return
+	}
+
+	job := domain.NewJob(text, original.VoiceID, original.ModelID, original.LanguageCode,
+		original.ProviderName, original.OutputFormat, original.VoiceSettings)
+	job.Padding = original.Padding
+	job.Pipeline = original.Pipeline
+	job.CallbackURL = original.CallbackURL
+	job.TenantID = middleware.TenantFromRequest(r)
+	job.AddEvent(domain.JobEventRegenerated, "regenerated from job "+original.ID)
+
+	if err := h.queue.Enqueue(r.Context(), job); err != nil {
+		h.writeEnqueueError(w, job, err)
+		return
+	}
+	h.textMetrics.Observe(metrics.SourceAsync, job.Text, job.LanguageCode)
+
+	h.logger.Info("Job regenerated",
+		zap.String("job_id", job.ID),
+		zap.String("original_job_id", original.ID),
+	)
+
+	middleware.WriteJSON(w, http.StatusCreated, JobCreateResponse{
+		JobID:     job.ID,
+		Status:    string(job.Status),
+		CreatedAt: job.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	})
+}
+
+// RetryJob handles POST /api/v1/jobs/{jobID}/retry. It queues a new job with a
+// failed job's parameters (see domain.NewRetryJob), e.g. once the provider that
+// failed it is back. The failed job is left as it is.
+func (h *JobsHandler) RetryJob(w http.ResponseWriter, r *http.Request) {
+	original, ok := h.ownJob(w, r)
+	if !ok {
+		return
+	}
+	if original.Status != domain.JobStatusFailed {
+		middleware.WriteError(w, domain.ErrJobNotRetryable.WithDetails(map[string]any{
+			"current_status": string(original.Status),
+		}))
+		return
+	}
+	if original.Source == nil {
+		if apiErr := checkTextRules(r, "text", original.Text); apiErr != nil {
+			middleware.WriteError(w, apiErr)
+			return
+		}
+	}
+	if _, err := h.registry.Get(original.ProviderName); err != nil {
+		middleware.WriteError(w, domain.ErrProviderNotFound.WithMessage("Provider '"+original.ProviderName+"' not found"))
+		return
+	}
+
+	job := domain.NewRetryJob(original)
+	if err := h.queue.Enqueue(r.Context(), job); err != nil {
+		h.writeEnqueueError(w, job, err)
+		return
+	}
+	if job.Source == nil {
+		h.textMetrics.Observe(metrics.SourceAsync, job.Text, job.LanguageCode)
+	}
+
+	h.logger.Info("Job retried",
+		zap.String("job_id", job.ID),
+		zap.String("original_job_id", original.ID),
+		zap.String("original_error", original.ErrorMessage),
+	)
+
+	middleware.WriteJSON(w, http.StatusCreated, JobCreateResponse{
+		JobID:     job.ID,
+		Status:    string(job.Status),
+		CreatedAt: job.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	})
 }
@@ -4,16 +4,26 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 
 	"github.com/pako-tts/server/internal/api/handlers/mocks"
+	"github.com/pako-tts/server/internal/api/middleware"
+	"github.com/pako-tts/server/internal/deadline"
 	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/queue/dedup"
 	"github.com/pako-tts/server/internal/queue/memory"
+	"github.com/pako-tts/server/internal/textinfo"
+	"github.com/pako-tts/server/internal/textsource"
+	"github.com/pako-tts/server/internal/webhook"
 )
 
 func TestJobsHandler_SubmitJob(t *testing.T) {
@@ -23,7 +33,7 @@ func TestJobsHandler_SubmitJob(t *testing.T) {
 	queue := memory.NewQueue(10)
 	mockStorage := mocks.NewMockStorage()
 
-	handler := NewJobsHandler(mockRegistry, queue, mockStorage, logger, "default-voice", 24)
+	handler := NewJobsHandler(mockRegistry, queue, mockStorage, logger, "default-voice", 24, false, 0, nil, nil, nil, nil)
 
 	reqBody := JobCreateRequest{
 		Text:         "Hello, world!",
@@ -58,6 +68,177 @@ func TestJobsHandler_SubmitJob(t *testing.T) {
 	}
 }
 
+func TestJobsHandler_SubmitJob_QueueBusy(t *testing.T) {
+	mockRegistry := mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"})
+	queue := memory.NewQueueWithOptions(1, memory.Options{})
+	queue.Enqueue(context.Background(), domain.NewJob("fill", "v", "", "", "test-provider", "mp3", nil)) //nolint:errcheck
+
+	handler := NewJobsHandler(mockRegistry, queue, mocks.NewMockStorage(), testLogger(), "default-voice", 24, false, 0, nil, nil, nil, nil)
+
+	body, _ := json.Marshal(JobCreateRequest{Text: "Hello, world!"})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/jobs", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handler.SubmitJob(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header")
+	}
+	var errResp domain.ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&errResp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if errResp.Error.Code != "QUEUE_BUSY" {
+		t.Errorf("Expected QUEUE_BUSY, got %s", errResp.Error.Code)
+	}
+}
+
+func TestJobsHandler_SubmitJob_Duplicates(t *testing.T) {
+	submit := func(h *JobsHandler, text string) (int, JobCreateResponse) {
+		body, _ := json.Marshal(JobCreateRequest{Text: text, VoiceID: "voice123"})
+		w := httptest.NewRecorder()
+		h.SubmitJob(w, httptest.NewRequest(http.MethodPost, "/api/v1/jobs", bytes.NewReader(body)))
+		var resp JobCreateResponse
+		json.NewDecoder(w.Body).Decode(&resp) //nolint:errcheck
+		return w.Code, resp
+	}
+	newHandler := func(mode string) (*JobsHandler, *memory.Queue) {
+		queue := memory.NewQueue(10)
+		registry := mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"})
+		return NewJobsHandler(registry, queue, mocks.NewMockStorage(), testLogger(), "default-voice", 24, false, 0,
+			dedup.New(mode, time.Minute), nil, nil, nil), queue
+	}
+
+	t.Run("coalesce returns the earlier job", func(t *testing.T) {
+		h, queue := newHandler(dedup.ModeCoalesce)
+		_, first := submit(h, "Hello")
+		code, second := submit(h, "Hello")
+		if code != http.StatusOK || !second.Coalesced || second.JobID != first.JobID {
+			t.Fatalf("expected coalesced 200 for %s, got %d %+v", first.JobID, code, second)
+		}
+		if _, other := submit(h, "Hello again"); other.JobID == first.JobID {
+			t.Error("different text must not be coalesced")
+		}
+		if got := queue.Stats().TotalJobs; got != 2 {
+			t.Errorf("expected 2 jobs, got %d", got)
+		}
+	})
+
+	t.Run("detect links a new job", func(t *testing.T) {
+		h, queue := newHandler(dedup.ModeDetect)
+		_, first := submit(h, "Hello")
+		code, second := submit(h, "Hello")
+		if code != http.StatusCreated || second.JobID == first.JobID || second.DuplicateOf != first.JobID {
+			t.Fatalf("expected new job linked to %s, got %d %+v", first.JobID, code, second)
+		}
+		job, _ := queue.GetJob(context.Background(), second.JobID)
+		if job.DuplicateOf != first.JobID || job.Events[0].Type != domain.JobEventDuplicate {
+			t.Errorf("expected duplicate link and event, got %q %+v", job.DuplicateOf, job.Events)
+		}
+	})
+
+	t.Run("failed original is not reused", func(t *testing.T) {
+		h, queue := newHandler(dedup.ModeCoalesce)
+		_, first := submit(h, "Hello")
+		job, _ := queue.GetJob(context.Background(), first.JobID)
+		job.SetFailed("boom")
+		code, second := submit(h, "Hello")
+		if code != http.StatusCreated || second.Coalesced || second.JobID == first.JobID {
+			t.Fatalf("expected a fresh job, got %d %+v", code, second)
+		}
+		if _, third := submit(h, "Hello"); third.JobID != second.JobID {
+			t.Errorf("expected later duplicates to coalesce into %s, got %s", second.JobID, third.JobID)
+		}
+	})
+}
+
+func TestJobsHandler_SubmitJob_Warnings(t *testing.T) {
+	registry := mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"})
+	handler := NewJobsHandler(registry, memory.NewQueue(10), mocks.NewMockStorage(), testLogger(), "default-voice", 24, true, 0, nil, nil, nil, nil)
+
+	body, _ := json.Marshal(map[string]any{
+		"text":           "Hello, world!",
+		"voice_settings": map[string]any{"stability": 1.4},
+	})
+	w := httptest.NewRecorder()
+	handler.SubmitJob(w, httptest.NewRequest(http.MethodPost, "/api/v1/jobs", bytes.NewReader(body)))
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", w.Code)
+	}
+	var resp JobCreateResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Warnings) != 1 || resp.Warnings[0].Code != domain.WarningSettingsClamped || resp.Warnings[0].Field != "voice_settings.stability" {
+		t.Errorf("Expected a clamped-settings warning, got %+v", resp.Warnings)
+	}
+}
+
+func TestJobsHandler_SubmitJob_TextSource(t *testing.T) {
+	queue := memory.NewQueue(10)
+	registry := mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"})
+	handler := NewJobsHandler(registry, queue, mocks.NewMockStorage(), testLogger(), "default-voice", 24, false, 0, nil, nil,
+		textsource.New(textsource.Options{Jobs: queue}), nil)
+
+	submit := func(body map[string]any) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		handler.SubmitJob(w, httptest.NewRequest(http.MethodPost, "/api/v1/jobs", bytes.NewReader(b)))
+		return w
+	}
+
+	w := submit(map[string]any{"source": map[string]any{"type": "url", "url": "https://example.com/chapter.txt"}})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 for url source, got %d: %s", w.Code, w.Body.String())
+	}
+	var created JobCreateResponse
+	json.Unmarshal(w.Body.Bytes(), &created) //nolint:errcheck
+	job, _ := queue.GetJob(context.Background(), created.JobID)
+	if job.Text != "" || job.Source == nil || job.Source.URL != "https://example.com/chapter.txt" {
+		t.Errorf("expected the job to carry the source for the worker, got text %q source %+v", job.Text, job.Source)
+	}
+
+	w = submit(map[string]any{"source": map[string]any{"type": "inline", "text": "Inline text"}})
+	json.Unmarshal(w.Body.Bytes(), &created) //nolint:errcheck
+	if job, _ := queue.GetJob(context.Background(), created.JobID); job == nil || job.Text != "Inline text" || job.Source != nil {
+		t.Errorf("expected inline source to be unwrapped, got %+v", job)
+	}
+
+	tests := []struct {
+		name       string
+		body       map[string]any
+		wantCode   string
+		wantSource string
+	}{
+		{"text and source", map[string]any{"text": "hi", "source": map[string]any{"type": "inline", "text": "hi"}}, "VALIDATION_ERROR", ""},
+		{"unknown type", map[string]any{"source": map[string]any{"type": "ftp"}}, "INVALID_TEXT_SOURCE", domain.SourceErrUnsupported},
+		{"bad url", map[string]any{"source": map[string]any{"type": "url", "url": "file:///etc/passwd"}}, "INVALID_TEXT_SOURCE", domain.SourceErrInvalid},
+		{"bad template", map[string]any{"source": map[string]any{"type": "template", "template": "{{"}}, "INVALID_TEXT_SOURCE", domain.SourceErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := submit(tt.body)
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("expected 422, got %d", w.Code)
+			}
+			var resp struct {
+				Error domain.APIError `json:"error"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &resp) //nolint:errcheck
+			if resp.Error.Code != tt.wantCode {
+				t.Errorf("expected code %s, got %s", tt.wantCode, resp.Error.Code)
+			}
+			if tt.wantSource != "" && resp.Error.Details["source_error"] != tt.wantSource {
+				t.Errorf("expected source_error %s, got %v", tt.wantSource, resp.Error.Details["source_error"])
+			}
+		})
+	}
+}
+
 func TestJobsHandler_SubmitJob_PassesModelID(t *testing.T) {
 	logger := testLogger()
 	mockProvider := &mocks.MockProvider{NameValue: "test-provider"}
@@ -65,7 +246,7 @@ func TestJobsHandler_SubmitJob_PassesModelID(t *testing.T) {
 	queue := memory.NewQueue(10)
 	mockStorage := mocks.NewMockStorage()
 
-	handler := NewJobsHandler(mockRegistry, queue, mockStorage, logger, "default-voice", 24)
+	handler := NewJobsHandler(mockRegistry, queue, mockStorage, logger, "default-voice", 24, false, 0, nil, nil, nil, nil)
 
 	reqBody := JobCreateRequest{
 		Text:    "Hello",
@@ -101,6 +282,30 @@ func TestJobsHandler_SubmitJob_PassesModelID(t *testing.T) {
 	}
 }
 
+func TestJobsHandler_SubmitJob_RecordsReply(t *testing.T) {
+	queue := memory.NewQueue(10)
+	handler := NewJobsHandler(mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"}), queue, mocks.NewMockStorage(), testLogger(), "default-voice", 24, false, 0, nil, nil, nil, nil)
+
+	reply := &domain.JobReply{Consumer: "node-1", Topic: "replies", CorrelationID: "c-1", MessageID: "m-1"}
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/jobs", strings.NewReader(`{"text":"Hello"}`))
+	req = req.WithContext(domain.WithJobReply(req.Context(), reply))
+	w := httptest.NewRecorder()
+	handler.SubmitJob(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var jobResp JobCreateResponse
+	json.NewDecoder(w.Body).Decode(&jobResp) //nolint:errcheck
+	stored, err := queue.GetJob(context.Background(), jobResp.JobID)
+	if err != nil {
+		t.Fatalf("failed to get stored job: %v", err)
+	}
+	if stored.Reply == nil || *stored.Reply != *reply {
+		t.Errorf("expected stored job.Reply %+v, got %+v", reply, stored.Reply)
+	}
+}
+
 func TestJobsHandler_SubmitJob_PassesLanguageCode(t *testing.T) {
 	logger := testLogger()
 	mockProvider := &mocks.MockProvider{NameValue: "test-provider"}
@@ -108,7 +313,7 @@ func TestJobsHandler_SubmitJob_PassesLanguageCode(t *testing.T) {
 	queue := memory.NewQueue(10)
 	mockStorage := mocks.NewMockStorage()
 
-	handler := NewJobsHandler(mockRegistry, queue, mockStorage, logger, "default-voice", 24)
+	handler := NewJobsHandler(mockRegistry, queue, mockStorage, logger, "default-voice", 24, false, 0, nil, nil, nil, nil)
 
 	reqBody := JobCreateRequest{
 		Text:         "Hello",
@@ -151,7 +356,7 @@ func TestJobsHandler_SubmitJob_PassesStyleInstructions(t *testing.T) {
 	queue := memory.NewQueue(10)
 	mockStorage := mocks.NewMockStorage()
 
-	handler := NewJobsHandler(mockRegistry, queue, mockStorage, logger, "default-voice", 24)
+	handler := NewJobsHandler(mockRegistry, queue, mockStorage, logger, "default-voice", 24, false, 0, nil, nil, nil, nil)
 
 	reqBody := JobCreateRequest{
 		Text:    "Hello",
@@ -199,7 +404,7 @@ func TestJobsHandler_SubmitJob_InvalidJSON(t *testing.T) {
 	queue := memory.NewQueue(10)
 	mockStorage := mocks.NewMockStorage()
 
-	handler := NewJobsHandler(mockRegistry, queue, mockStorage, logger, "default-voice", 24)
+	handler := NewJobsHandler(mockRegistry, queue, mockStorage, logger, "default-voice", 24, false, 0, nil, nil, nil, nil)
 
 	req := httptest.NewRequest(http.MethodPost, "/api/v1/jobs", bytes.NewReader([]byte("invalid json")))
 	req.Header.Set("Content-Type", "application/json")
@@ -222,7 +427,7 @@ func TestJobsHandler_SubmitJob_EmptyText(t *testing.T) {
 	queue := memory.NewQueue(10)
 	mockStorage := mocks.NewMockStorage()
 
-	handler := NewJobsHandler(mockRegistry, queue, mockStorage, logger, "default-voice", 24)
+	handler := NewJobsHandler(mockRegistry, queue, mockStorage, logger, "default-voice", 24, false, 0, nil, nil, nil, nil)
 
 	reqBody := JobCreateRequest{
 		Text:    "",
@@ -251,7 +456,7 @@ func TestJobsHandler_SubmitJob_InvalidFormat(t *testing.T) {
 	queue := memory.NewQueue(10)
 	mockStorage := mocks.NewMockStorage()
 
-	handler := NewJobsHandler(mockRegistry, queue, mockStorage, logger, "default-voice", 24)
+	handler := NewJobsHandler(mockRegistry, queue, mockStorage, logger, "default-voice", 24, false, 0, nil, nil, nil, nil)
 
 	reqBody := JobCreateRequest{
 		Text:         "Hello",
@@ -281,7 +486,7 @@ func TestJobsHandler_GetJobStatus(t *testing.T) {
 	queue := memory.NewQueue(10)
 	mockStorage := mocks.NewMockStorage()
 
-	handler := NewJobsHandler(mockRegistry, queue, mockStorage, logger, "default-voice", 24)
+	handler := NewJobsHandler(mockRegistry, queue, mockStorage, logger, "default-voice", 24, false, 0, nil, nil, nil, nil)
 
 	// Create a job first
 	ctx := context.Background()
@@ -316,6 +521,49 @@ func TestJobsHandler_GetJobStatus(t *testing.T) {
 	if statusResp.Status != string(domain.JobStatusQueued) {
 		t.Errorf("Expected status 'queued', got %s", statusResp.Status)
 	}
+	if len(statusResp.Events) != 1 || statusResp.Events[0].Type != domain.JobEventQueued {
+		t.Errorf("Expected a queued event, got %+v", statusResp.Events)
+	}
+}
+
+func TestJobsHandler_GetJobStatus_Completed(t *testing.T) {
+	logger := testLogger()
+	mockProvider := &mocks.MockProvider{NameValue: "test-provider"}
+	mockRegistry := mocks.NewMockProviderRegistry(mockProvider)
+	queue := memory.NewQueue(10)
+	mockStorage := mocks.NewMockStorage()
+
+	handler := NewJobsHandler(mockRegistry, queue, mockStorage, logger, "default-voice", 24, false, 0, nil, nil, nil, nil)
+
+	ctx := context.Background()
+	job := domain.NewJob("test text", "voice123", "", "", "test-provider", "mp3", nil)
+	queue.Enqueue(ctx, job) //nolint:errcheck
+	job.AudioSeconds = 1.5
+	job.ResultSizeBytes = 24000
+	job.SetCompleted("/tmp/"+job.ID+".mp3", 24)
+	queue.UpdateJob(ctx, job) //nolint:errcheck
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+job.ID, nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("jobID", job.ID)
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	w := httptest.NewRecorder()
+
+	handler.GetJobStatus(w, req)
+
+	var statusResp JobStatusResponse
+	if err := json.NewDecoder(w.Body).Decode(&statusResp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if statusResp.ResultURL == nil || *statusResp.ResultURL != "/api/v1/jobs/"+job.ID+"/result" {
+		t.Errorf("Expected the result URL, got %v", statusResp.ResultURL)
+	}
+	if statusResp.ResultSizeBytes == nil || *statusResp.ResultSizeBytes != 24000 {
+		t.Errorf("Expected a result size of 24000 bytes, got %v", statusResp.ResultSizeBytes)
+	}
+	if statusResp.DurationSeconds == nil || *statusResp.DurationSeconds != 1.5 {
+		t.Errorf("Expected a duration of 1.5s, got %v", statusResp.DurationSeconds)
+	}
 }
 
 func TestJobsHandler_GetJobStatus_NotFound(t *testing.T) {
@@ -325,7 +573,7 @@ func TestJobsHandler_GetJobStatus_NotFound(t *testing.T) {
 	queue := memory.NewQueue(10)
 	mockStorage := mocks.NewMockStorage()
 
-	handler := NewJobsHandler(mockRegistry, queue, mockStorage, logger, "default-voice", 24)
+	handler := NewJobsHandler(mockRegistry, queue, mockStorage, logger, "default-voice", 24, false, 0, nil, nil, nil, nil)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/v1/jobs/non-existent", nil)
 	rctx := chi.NewRouteContext()
@@ -351,7 +599,7 @@ func TestJobsHandler_GetJobResult_NotComplete(t *testing.T) {
 	queue := memory.NewQueue(10)
 	mockStorage := mocks.NewMockStorage()
 
-	handler := NewJobsHandler(mockRegistry, queue, mockStorage, logger, "default-voice", 24)
+	handler := NewJobsHandler(mockRegistry, queue, mockStorage, logger, "default-voice", 24, false, 0, nil, nil, nil, nil)
 
 	// Create a job (still queued, not completed)
 	ctx := context.Background()
@@ -382,7 +630,7 @@ func TestJobsHandler_GetJobResult_Success(t *testing.T) {
 	queue := memory.NewQueue(10)
 	mockStorage := mocks.NewMockStorage()
 
-	handler := NewJobsHandler(mockRegistry, queue, mockStorage, logger, "default-voice", 24)
+	handler := NewJobsHandler(mockRegistry, queue, mockStorage, logger, "default-voice", 24, false, 0, nil, nil, nil, nil)
 
 	// Create and complete a job
 	ctx := context.Background()
@@ -415,3 +663,1071 @@ func TestJobsHandler_GetJobResult_Success(t *testing.T) {
 		t.Errorf("Expected Content-Type audio/mpeg, got %s", contentType)
 	}
 }
+
+func TestJobsHandler_GetJobResult_RangeAndConditional(t *testing.T) {
+	queue := memory.NewQueue(10)
+	mockStorage := mocks.NewMockStorage()
+	handler := NewJobsHandler(mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"}), queue, mockStorage, testLogger(), "default-voice", 24, false, 0, nil, nil, nil, nil)
+
+	ctx := context.Background()
+	job := domain.NewJob("test text", "voice123", "", "", "test-provider", "mp3", nil)
+	queue.Enqueue(ctx, job) //nolint:errcheck
+	job.SetCompleted("/storage/"+job.ID+".mp3", 24)
+	queue.UpdateJob(ctx, job) //nolint:errcheck
+	mockStorage.StoredFiles[job.ID] = []byte("fake audio content")
+
+	get := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+job.ID+"/result", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("jobID", job.ID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		handler.GetJobResult(w, req)
+		return w
+	}
+
+	w := get("", "")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" || w.Header().Get("Last-Modified") == "" || w.Header().Get("Accept-Ranges") != "bytes" {
+		t.Fatalf("expected 200 with ETag, Last-Modified and Accept-Ranges, got %d %v", w.Code, w.Header())
+	}
+
+	w = get("Range", "bytes=5-9")
+	if w.Code != http.StatusPartialContent || w.Body.String() != "audio" || w.Header().Get("Content-Range") != "bytes 5-9/18" {
+		t.Errorf("expected 206 with bytes 5-9, got %d %q %q", w.Code, w.Body.String(), w.Header().Get("Content-Range"))
+	}
+
+	w = get("If-None-Match", etag)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("expected 304 for a matching ETag, got %d", w.Code)
+	}
+
+	w = get("If-None-Match", `"other"`)
+	if w.Code != http.StatusOK || w.Body.String() != "fake audio content" {
+		t.Errorf("expected 200 for another ETag, got %d", w.Code)
+	}
+}
+
+func TestJobsHandler_DeleteJobResult(t *testing.T) {
+	queue := memory.NewQueue(10)
+	mockStorage := mocks.NewMockStorage()
+	handler := NewJobsHandler(mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"}), queue, mockStorage, testLogger(), "default-voice", 24, false, 0, nil, nil, nil, nil)
+
+	ctx := context.Background()
+	newCompleted := func() *domain.Job {
+		job := domain.NewJob("test text", "voice123", "", "", "test-provider", "mp3", nil)
+		queue.Enqueue(ctx, job) //nolint:errcheck
+		job.SetCompleted("/storage/"+job.ID+".mp3", 24)
+		queue.UpdateJob(ctx, job) //nolint:errcheck
+		mockStorage.StoredFiles[job.ID] = []byte("fake audio content")
+		return job
+	}
+	del := func(jobID, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, "/api/v1/jobs/"+jobID+"/result"+query, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("jobID", jobID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		handler.DeleteJobResult(w, req)
+		return w
+	}
+
+	job := newCompleted()
+	if w := del(job.ID, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, ok := mockStorage.StoredFiles[job.ID]; ok {
+		t.Error("expected the result removed from storage")
+	}
+	if got, _ := queue.GetJob(ctx, job.ID); got.Status != domain.JobStatusExpired {
+		t.Errorf("expected the job expired, got %s", got.Status)
+	}
+	if w := del(job.ID, ""); w.Code != http.StatusNoContent {
+		t.Errorf("expected deleting again to succeed, got %d", w.Code)
+	}
+
+	// ?delete_job=true removes the job record as well
+	job = newCompleted()
+	if w := del(job.ID, "?delete_job=true"); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := queue.GetJob(ctx, job.ID); err == nil {
+		t.Error("expected the job deleted")
+	}
+
+	queued := domain.NewJob("test text", "voice123", "", "", "test-provider", "mp3", nil)
+	queue.Enqueue(ctx, queued) //nolint:errcheck
+	if w := del(queued.ID, ""); w.Code != http.StatusTooEarly {
+		t.Errorf("expected 425 for a queued job, got %d", w.Code)
+	}
+	if w := del("missing", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown job, got %d", w.Code)
+	}
+	if w := del(newCompleted().ID, "?delete_job=maybe"); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for an invalid delete_job, got %d", w.Code)
+	}
+}
+
+func TestJobsHandler_GetJobResult_Format(t *testing.T) {
+	queue := memory.NewQueue(10)
+	mockStorage := mocks.NewMockStorage()
+	handler := NewJobsHandler(mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"}), queue, mockStorage,
+		testLogger(), "default-voice", 24, false, 0, nil, nil, nil, nil)
+
+	ctx := context.Background()
+	job := domain.NewJob("test text", "voice123", "", "", "test-provider", "mp3", nil)
+	queue.Enqueue(ctx, job) //nolint:errcheck
+	job.SetCompleted("/storage/"+job.ID+".mp3", 24)
+	queue.UpdateJob(ctx, job) //nolint:errcheck
+	mockStorage.StoredFiles[job.ID] = []byte("fake mp3")
+
+	// A variant transcoded by an earlier request is served from storage
+	mockStorage.StoreArtifact(ctx, job.ID, domain.ResultVariant("ogg"), []byte("cached ogg")) //nolint:errcheck
+	job.AddArtifact(domain.ResultVariant("ogg"))
+
+	get := func(format string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+job.ID+"/result?format="+format, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("jobID", job.ID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		handler.GetJobResult(w, req)
+		return w
+	}
+
+	w := get("ogg")
+	if w.Code != http.StatusOK || w.Body.String() != "cached ogg" {
+		t.Fatalf("expected cached ogg variant, got %d %q", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "audio/ogg" {
+		t.Errorf("expected Content-Type audio/ogg, got %s", ct)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, job.ID+".ogg") {
+		t.Errorf("expected .ogg filename, got %s", cd)
+	}
+
+	// The stored format is served as is
+	if w := get("mp3"); w.Code != http.StatusOK || w.Body.String() != "fake mp3" {
+		t.Errorf("expected stored mp3, got %d %q", w.Code, w.Body.String())
+	}
+
+	if w := get("aac"); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for unsupported format, got %d", w.Code)
+	}
+}
+
+func TestJobsHandler_GetJobResult_Accept(t *testing.T) {
+	queue := memory.NewQueue(10)
+	mockStorage := mocks.NewMockStorage()
+	handler := NewJobsHandler(mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"}), queue, mockStorage,
+		testLogger(), "default-voice", 24, false, 0, nil, nil, nil, nil)
+
+	ctx := context.Background()
+	job := domain.NewJob("test text", "voice123", "", "", "test-provider", "mp3", nil)
+	queue.Enqueue(ctx, job) //nolint:errcheck
+	job.SetCompleted("/storage/"+job.ID+".mp3", 24)
+	queue.UpdateJob(ctx, job) //nolint:errcheck
+	mockStorage.StoredFiles[job.ID] = []byte("fake mp3")
+	mockStorage.StoreArtifact(ctx, job.ID, domain.ResultVariant("ogg"), []byte("cached ogg")) //nolint:errcheck
+	job.AddArtifact(domain.ResultVariant("ogg"))
+
+	get := func(query, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+job.ID+"/result"+query, nil)
+		req.Header.Set("Accept", accept)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("jobID", job.ID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		handler.GetJobResult(w, req)
+		return w
+	}
+
+	for _, tt := range []struct {
+		query, accept, want string
+	}{
+		{"", "audio/ogg", "cached ogg"},
+		{"", "audio/ogg;q=0.5, audio/mpeg", "fake mp3"},
+		{"", "audio/*", "fake mp3"},
+		{"", "*/*", "fake mp3"},
+		{"?format=mp3", "audio/ogg", "fake mp3"},
+	} {
+		w := get(tt.query, tt.accept)
+		if w.Code != http.StatusOK || w.Body.String() != tt.want {
+			t.Errorf("%s Accept %q: expected %q, got %d %q", tt.query, tt.accept, tt.want, w.Code, w.Body.String())
+		}
+	}
+
+	w := get("", "application/json")
+	var errResp domain.ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &errResp) //nolint:errcheck
+	if w.Code != http.StatusNotAcceptable || errResp.Error.Code != "NOT_ACCEPTABLE" {
+		t.Fatalf("expected 406 NOT_ACCEPTABLE, got %d: %s", w.Code, w.Body.String())
+	}
+	if supported, _ := errResp.Error.Details["supported"].([]any); len(supported) != 5 || supported[0] != "audio/mpeg" {
+		t.Errorf("expected the supported types with the stored format first, got %v", errResp.Error.Details)
+	}
+}
+
+func TestJobsHandler_GetJobResult_Expired(t *testing.T) {
+	tests := []struct {
+		name         string
+		expiredAgo   time.Duration
+		wantRetained bool
+		markExpired  bool
+	}{
+		{"within grace period", time.Hour, true, false},
+		{"after grace period", 3 * time.Hour, false, false},
+		{"marked expired by cleanup", time.Hour, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := memory.NewQueue(10)
+			registry := mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"})
+			handler := NewJobsHandler(registry, queue, mocks.NewMockStorage(), testLogger(), "default-voice", 24, false, 2*time.Hour, nil, nil, nil, nil)
+
+			ctx := context.Background()
+			job := domain.NewJob("secret text", "voice123", "eleven_v3", "", "test-provider", "wav", nil)
+			queue.Enqueue(ctx, job) //nolint:errcheck
+			job.SetCompleted("/storage/"+job.ID+".wav", 24)
+			expiredAt := time.Now().Add(-tt.expiredAgo)
+			job.ExpiresAt = &expiredAt
+			if tt.markExpired {
+				job.SetExpired() //nolint:errcheck
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+job.ID+"/result", nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("jobID", job.ID)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			handler.GetJobResult(w, req)
+
+			if w.Code != http.StatusGone {
+				t.Fatalf("Expected status 410, got %d", w.Code)
+			}
+			var errResp domain.ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&errResp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			details := errResp.Error.Details
+			if details["regenerate_url"] != "/api/v1/jobs/"+job.ID+"/regenerate" || details["text_retained"] != tt.wantRetained {
+				t.Errorf("Unexpected details %v", details)
+			}
+			original, _ := details["original_request"].(map[string]any)
+			if original["model_id"] != "eleven_v3" || original["output_format"] != "wav" {
+				t.Errorf("Unexpected original request %v", original)
+			}
+			if _, hasText := original["text"]; hasText != tt.wantRetained {
+				t.Errorf("Expected text present = %v, got %v", tt.wantRetained, original)
+			}
+
+			// Regenerating without text only works while the text is retained.
+			w = httptest.NewRecorder()
+			handler.RegenerateJob(w, req)
+			if tt.wantRetained {
+				if w.Code != http.StatusCreated {
+					t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+				}
+				var resp JobCreateResponse
+				json.NewDecoder(w.Body).Decode(&resp) //nolint:errcheck
+				regenerated, _ := queue.GetJob(ctx, resp.JobID)
+				if regenerated.Text != "secret text" || regenerated.ModelID != "eleven_v3" || regenerated.OutputFormat != "wav" {
+					t.Errorf("Unexpected regenerated job %+v", regenerated)
+				}
+				return
+			}
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("Expected status 422, got %d", w.Code)
+			}
+
+			body := bytes.NewReader([]byte(`{"text":"secret text"}`))
+			req = httptest.NewRequest(http.MethodPost, "/api/v1/jobs/"+job.ID+"/regenerate", body)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w = httptest.NewRecorder()
+			handler.RegenerateJob(w, req)
+			if w.Code != http.StatusCreated {
+				t.Errorf("Expected status 201 with text supplied, got %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestSubmitJob_Padding(t *testing.T) {
+	tests := []struct {
+		name       string
+		padding    map[string]any
+		wantStatus int
+	}{
+		{"valid padding is stored on the job", map[string]any{"lead_in_ms": 300, "fade_out_ms": 50}, http.StatusCreated},
+		{"lead-in too long", map[string]any{"lead_in_ms": 60000}, http.StatusUnprocessableEntity},
+		{"negative fade", map[string]any{"fade_in_ms": -1}, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockProvider := &mocks.MockProvider{NameValue: "test-provider", AvailableValue: true}
+			registry := mocks.NewMockProviderRegistry(mockProvider)
+			queue := memory.NewQueue(10)
+			handler := NewJobsHandler(registry, queue, mocks.NewMockStorage(), testLogger(), "default-voice", 24, false, 0, nil, nil, nil, nil)
+
+			body, _ := json.Marshal(map[string]any{"text": "hello", "padding": tt.padding})
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/jobs", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			handler.SubmitJob(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+			var created JobCreateResponse
+			if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			job, _ := queue.GetJob(context.Background(), created.JobID)
+			if job == nil || job.Padding == nil || job.Padding.LeadInMs != 300 || job.Padding.FadeOutMs != 50 {
+				t.Errorf("expected padding to be stored on the job, got %+v", job)
+			}
+		})
+	}
+}
+
+func TestJobsHandler_GetJobPreview(t *testing.T) {
+	tests := []struct {
+		name        string
+		withPreview bool
+		wantStatus  int
+	}{
+		{"preview stored", true, http.StatusOK},
+		{"no preview for job", false, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRegistry := mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"})
+			queue := memory.NewQueue(10)
+			mockStorage := mocks.NewMockStorage()
+			handler := NewJobsHandler(mockRegistry, queue, mockStorage, testLogger(), "default-voice", 24, false, 0, nil, nil, nil, nil)
+
+			ctx := context.Background()
+			job := domain.NewJob("test text", "voice123", "", "", "test-provider", "mp3", nil)
+			queue.Enqueue(ctx, job) //nolint:errcheck
+			if tt.withPreview {
+				job.AddArtifact(domain.ArtifactPreview)
+				mockStorage.Artifacts[job.ID+"/"+domain.ArtifactPreview] = []byte("preview")
+			}
+			job.SetCompleted("/storage/"+job.ID+".mp3", 24)
+			queue.UpdateJob(ctx, job) //nolint:errcheck
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+job.ID+"/preview", nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("jobID", job.ID)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			handler.GetJobPreview(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.withPreview {
+				if got := w.Header().Get("Content-Type"); got != "audio/mpeg" {
+					t.Errorf("expected Content-Type audio/mpeg, got %q", got)
+				}
+				if w.Body.String() != "preview" {
+					t.Errorf("expected preview body, got %q", w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestJobsHandler_GetJobPreview_Retention(t *testing.T) {
+	retention := domain.RetentionPolicy{
+		Result:    24 * time.Hour,
+		Artifacts: map[string]time.Duration{domain.ArtifactKindPreview: 30 * 24 * time.Hour},
+	}
+	tests := []struct {
+		name       string
+		policy     domain.RetentionPolicy
+		expire     bool
+		wantStatus int
+	}{
+		{"kept past the result", retention, true, http.StatusOK},
+		{"expired with the result", domain.RetentionPolicy{Result: 24 * time.Hour}, true, http.StatusGone},
+		{"expired before the result", retention, false, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := memory.NewQueue(10)
+			mockStorage := mocks.NewMockStorage()
+			handler := NewJobsHandler(mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"}), queue, mockStorage,
+				testLogger(), "default-voice", 24, false, 0, nil, nil, nil, nil)
+
+			ctx := context.Background()
+			job := domain.NewJob("test text", "voice123", "", "", "test-provider", "mp3", nil)
+			queue.Enqueue(ctx, job) //nolint:errcheck
+			job.SetCompleted("/storage/"+job.ID+".mp3", 24)
+			job.AddArtifact(domain.ArtifactPreview)
+			job.RetainArtifact(domain.ArtifactPreview, tt.policy)
+			mockStorage.Artifacts[job.ID+"/"+domain.ArtifactPreview] = []byte("preview")
+			if tt.expire {
+				job.SetExpired() //nolint:errcheck
+			} else {
+				job.ArtifactExpiresAt[domain.ArtifactPreview] = time.Now().Add(-time.Minute)
+			}
+			queue.UpdateJob(ctx, job) //nolint:errcheck
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+job.ID+"/preview", nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("jobID", job.ID)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			handler.GetJobPreview(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			resp := newJobStatusResponse(job)
+			if wantURL := tt.wantStatus == http.StatusOK; (resp.PreviewURL != nil) != wantURL {
+				t.Errorf("expected a preview_url %v, got %v", wantURL, resp.PreviewURL)
+			}
+		})
+	}
+}
+
+func TestJobsHandler_GetJobWaveform(t *testing.T) {
+	mockRegistry := mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"})
+	queue := memory.NewQueue(10)
+	mockStorage := mocks.NewMockStorage()
+	handler := NewJobsHandler(mockRegistry, queue, mockStorage, testLogger(), "default-voice", 24, false, 0, nil, nil, nil, nil)
+
+	ctx := context.Background()
+	job := domain.NewJob("test text", "voice123", "", "", "test-provider", "wav", nil)
+	queue.Enqueue(ctx, job) //nolint:errcheck
+	job.AddArtifact(domain.ArtifactWaveform)
+	mockStorage.Artifacts[job.ID+"/"+domain.ArtifactWaveform] = []byte(`{"version":2}`)
+	job.SetCompleted("/storage/"+job.ID+".wav", 24)
+	queue.UpdateJob(ctx, job) //nolint:errcheck
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+job.ID+"/waveform", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("jobID", job.ID)
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	w := httptest.NewRecorder()
+
+	handler.GetJobWaveform(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("expected Content-Type application/json, got %q", got)
+	}
+	if w.Body.String() != `{"version":2}` {
+		t.Errorf("unexpected body %q", w.Body.String())
+	}
+}
+
+func TestJobsHandler_GetJobArtifacts(t *testing.T) {
+	queue := memory.NewQueue(10)
+	mockStorage := mocks.NewMockStorage()
+	handler := NewJobsHandler(mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"}), queue, mockStorage,
+		testLogger(), "default-voice", 24, false, 0, nil, nil, nil, nil)
+
+	ctx := context.Background()
+	job := domain.NewJob("test text", "voice123", "", "", "test-provider", "mp3", nil)
+	queue.Enqueue(ctx, job) //nolint:errcheck
+	mockStorage.StoredFiles[job.ID] = []byte("audio")
+	for name, data := range map[string]string{
+		domain.ArtifactPreview:      "preview",
+		domain.ArtifactWaveform:     `{"version":2}`,
+		domain.ResultVariant("ogg"): "ogg",
+		"unknown.bin":               "ignored",
+	} {
+		mockStorage.Artifacts[job.ID+"/"+name] = []byte(data)
+		job.AddArtifact(name)
+	}
+	job.SetCompleted("/storage/"+job.ID+".mp3", 24)
+	queue.UpdateJob(ctx, job) //nolint:errcheck
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+job.ID+"/artifacts", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("jobID", job.ID)
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	w := httptest.NewRecorder()
+
+	handler.GetJobArtifacts(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp JobArtifactsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Artifacts) != 4 {
+		t.Fatalf("expected 4 artifacts, got %+v", resp.Artifacts)
+	}
+
+	byType := make(map[string]JobArtifact)
+	for _, a := range resp.Artifacts {
+		byType[a.Type] = a
+	}
+	audio := byType[ArtifactTypeAudio]
+	if audio.URL != "/api/v1/jobs/"+job.ID+"/result" || audio.Size != 5 ||
+		audio.SHA256 != "6ed8919ce20490a5e3ad8630a4fab69475297abd07db73918dd5f36fcfaeb11b" {
+		t.Errorf("unexpected audio artifact %+v", audio)
+	}
+	if v := byType[ArtifactTypeAudioVariant]; v.URL != "/api/v1/jobs/"+job.ID+"/result?format=ogg" || v.ContentType != "audio/ogg" {
+		t.Errorf("unexpected variant artifact %+v", v)
+	}
+	if p := byType[ArtifactTypePreview]; p.Size != int64(len("preview")) || len(p.SHA256) != 64 {
+		t.Errorf("unexpected preview artifact %+v", p)
+	}
+	if p := byType[ArtifactTypePreview]; p.ExpiresAt == nil || resp.ExpiresAt == nil || *p.ExpiresAt != *resp.ExpiresAt {
+		t.Errorf("expected the preview to expire with the result, got %v", p.ExpiresAt)
+	}
+}
+
+func TestJobsHandler_GetJobResult_Disposition(t *testing.T) {
+	queue := memory.NewQueue(10)
+	mockStorage := mocks.NewMockStorage()
+	handler := NewJobsHandler(mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"}), queue, mockStorage,
+		testLogger(), "default-voice", 24, false, 0, nil, nil, nil, nil)
+
+	ctx := context.Background()
+	job := domain.NewJob("test text", "Zoë \"bright\"", "", "", "test-provider", "mp3", nil)
+	queue.Enqueue(ctx, job) //nolint:errcheck
+	job.SetCompleted("/storage/"+job.ID+".mp3", 24)
+	queue.UpdateJob(ctx, job) //nolint:errcheck
+	mockStorage.StoredFiles[job.ID] = []byte("fake mp3")
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+job.ID+"/result"+query, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("jobID", job.ID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		handler.GetJobResult(w, req)
+		return w
+	}
+
+	want := `attachment; filename="Zo__\"bright\"-` + job.ID + `.mp3"; filename*=UTF-8''Zo%C3%AB_%22bright%22-` + job.ID + `.mp3`
+	if cd := get("").Header().Get("Content-Disposition"); cd != want {
+		t.Errorf("Content-Disposition = %s, want %s", cd, want)
+	}
+	if cd := get("?disposition=inline").Header().Get("Content-Disposition"); !strings.HasPrefix(cd, "inline; ") {
+		t.Errorf("expected an inline disposition, got %s", cd)
+	}
+	if w := get("?disposition=download"); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected an unknown disposition to be rejected, got %d", w.Code)
+	}
+}
+
+// signingStorage signs URLs to https://bucket.example, or fails to when failing is set.
+type signingStorage struct {
+	*mocks.MockStorage
+	failing bool
+}
+
+func (s *signingStorage) CanSignURLs() bool { return true }
+
+func (s *signingStorage) SignedURL(_ context.Context, jobID string, ttl time.Duration, disposition string) (string, error) {
+	if s.failing {
+		return "", errors.New("signing failed")
+	}
+	return "https://bucket.example/" + jobID + "?ttl=" + ttl.String() + "&disposition=" + url.QueryEscape(disposition), nil
+}
+
+func TestJobsHandler_GetJobResult_Redirect(t *testing.T) {
+	queue := memory.NewQueue(10)
+	storage := &signingStorage{MockStorage: mocks.NewMockStorage()}
+	handler := NewJobsHandler(mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"}), queue, storage,
+		testLogger(), "default-voice", 24, false, 0, nil, nil, nil, nil)
+	handler.RedirectResults(storage, 15*time.Minute)
+
+	ctx := context.Background()
+	job := domain.NewJob("test text", "voice123", "", "", "test-provider", "mp3", nil)
+	queue.Enqueue(ctx, job) //nolint:errcheck
+	job.SetCompleted("/storage/"+job.ID+".mp3", 24)
+	queue.UpdateJob(ctx, job) //nolint:errcheck
+	storage.StoredFiles[job.ID] = []byte("fake mp3")
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+job.ID+"/result"+query, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("jobID", job.ID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		handler.GetJobResult(w, req)
+		return w
+	}
+
+	w := get("?disposition=inline")
+	if w.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("expected a redirect, got %d: %s", w.Code, w.Body.String())
+	}
+	location := w.Header().Get("Location")
+	if !strings.HasPrefix(location, "https://bucket.example/"+job.ID+"?ttl=15m0s&disposition=inline%3B") {
+		t.Errorf("unexpected redirect to %s", location)
+	}
+	if w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("expected the redirect not to be cached, got %q", w.Header().Get("Cache-Control"))
+	}
+
+	// Opting out, or failing to sign, serves the result instead
+	if w := get("?redirect=false"); w.Code != http.StatusOK || w.Body.String() != "fake mp3" {
+		t.Errorf("expected the result with redirect=false, got %d", w.Code)
+	}
+	if w := get("?redirect=maybe"); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected an invalid redirect to be rejected, got %d", w.Code)
+	}
+	storage.failing = true
+	if w := get(""); w.Code != http.StatusOK || w.Body.String() != "fake mp3" {
+		t.Errorf("expected the result when signing fails, got %d", w.Code)
+	}
+}
+
+func TestJobsHandler_CancelJob(t *testing.T) {
+	queue := memory.NewQueue(10)
+	handler := NewJobsHandler(mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"}), queue, mocks.NewMockStorage(),
+		testLogger(), "default-voice", 24, false, 0, nil, nil, nil, nil)
+
+	ctx := context.Background()
+	queued := domain.NewJob("queued", "voice", "", "", "test-provider", "mp3", nil)
+	done := domain.NewJob("done", "voice", "", "", "test-provider", "mp3", nil)
+	queue.Enqueue(ctx, done)   //nolint:errcheck
+	queue.Enqueue(ctx, queued) //nolint:errcheck
+	done.SetCompleted("/storage/"+done.ID+".mp3", 1)
+	queue.UpdateJob(ctx, done) //nolint:errcheck
+
+	cancel := func(jobID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, "/api/v1/jobs/"+jobID, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("jobID", jobID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		handler.CancelJob(w, req)
+		return w
+	}
+
+	w := cancel(queued.ID)
+	var resp JobStatusResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if w.Code != http.StatusOK || resp.Status != string(domain.JobStatusCancelled) {
+		t.Errorf("expected 200 cancelled, got %d %s", w.Code, resp.Status)
+	}
+
+	w = cancel(done.ID)
+	var errResp domain.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &errResp); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if w.Code != http.StatusConflict || errResp.Error.Code != "JOB_NOT_CANCELLABLE" || errResp.Error.Details["current_status"] != "completed" {
+		t.Errorf("expected 409 JOB_NOT_CANCELLABLE, got %d %+v", w.Code, errResp.Error)
+	}
+
+	if w := cancel("missing"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown job, got %d", w.Code)
+	}
+}
+
+func TestJobsHandler_SubmitJob_KeyDefaultOutputFormat(t *testing.T) {
+	queue := memory.NewQueue(10)
+	handler := NewJobsHandler(mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"}), queue, mocks.NewMockStorage(),
+		testLogger(), "default-voice", 24, false, 0, nil, nil, nil, nil)
+	auth := middleware.NewAPIKeyAuth([]middleware.APIKey{
+		{Name: "telephony", Key: "tel-secret", OutputFormat: "wav"},
+		{Name: "web", Key: "web-secret"},
+	})
+	submit := auth(http.HandlerFunc(handler.SubmitJob))
+
+	for _, tt := range []struct {
+		key, body, want string
+	}{
+		{"tel-secret", `{"text":"Hello"}`, "wav"},
+		{"tel-secret", `{"text":"Hello","output_format":"mp3"}`, "mp3"},
+		{"web-secret", `{"text":"Hello"}`, "mp3"},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/jobs", strings.NewReader(tt.body))
+		req.Header.Set("X-API-Key", tt.key)
+		w := httptest.NewRecorder()
+		submit.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+		}
+
+		var resp JobCreateResponse
+		json.Unmarshal(w.Body.Bytes(), &resp) //nolint:errcheck
+		job, err := queue.GetJob(context.Background(), resp.JobID)
+		if err != nil || job.OutputFormat != tt.want {
+			t.Errorf("%s %s: expected output format %s, got %+v", tt.key, tt.body, tt.want, job)
+		}
+	}
+}
+
+func TestJobsHandler_SubmitJob_KeyTextRules(t *testing.T) {
+	queue := memory.NewQueue(10)
+	handler := NewJobsHandler(mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"}), queue, mocks.NewMockStorage(),
+		testLogger(), "default-voice", 24, false, 0, nil, nil, nil, nil)
+	auth := middleware.NewAPIKeyAuth([]middleware.APIKey{
+		{Name: "strict", Key: "strict-secret", TextRules: textinfo.Rules{Scripts: []string{textinfo.ScriptLatin}, AllowedChars: "!", MaxRepeat: 3}},
+		{Name: "open", Key: "open-secret"},
+	})
+	submit := auth(http.HandlerFunc(handler.SubmitJob))
+
+	for _, tt := range []struct {
+		key, body string
+		want      int
+		rule      string
+	}{
+		{"strict-secret", `{"text":"Hello there!"}`, http.StatusCreated, ""},
+		{"strict-secret", `{"text":"Hello Мир"}`, http.StatusUnprocessableEntity, textinfo.RuleScripts},
+		{"strict-secret", `{"text":"Nooooooo"}`, http.StatusUnprocessableEntity, textinfo.RuleMaxRepeat},
+		{"open-secret", `{"text":"Hello Мир"}`, http.StatusCreated, ""},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/jobs", strings.NewReader(tt.body))
+		req.Header.Set("X-API-Key", tt.key)
+		w := httptest.NewRecorder()
+		submit.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Fatalf("%s %s: expected status %d, got %d: %s", tt.key, tt.body, tt.want, w.Code, w.Body.String())
+		}
+		if tt.rule == "" {
+			continue
+		}
+
+		var resp domain.ErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &resp) //nolint:errcheck
+		if resp.Error == nil || resp.Error.Details["rule"] != tt.rule || resp.Error.Details["field"] != "text" {
+			t.Errorf("%s: expected the %s rule in details, got %+v", tt.body, tt.rule, resp.Error)
+		}
+	}
+}
+
+func TestJobsHandler_SubmitJob_CallbackURL(t *testing.T) {
+	queue := memory.NewQueue(10)
+	registry := mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"})
+	callbacks := webhook.NewDispatcher(webhook.NewMemoryStore(), queue, testLogger(), time.Second, []string{"hooks.example.com"}, "")
+
+	for _, tt := range []struct {
+		name      string
+		callbacks *webhook.Dispatcher
+		url       string
+		want      int
+	}{
+		{"allowed host", callbacks, "https://hooks.example.com/done", http.StatusCreated},
+		{"other host", callbacks, "https://evil.example.com/done", http.StatusUnprocessableEntity},
+		{"callbacks disabled", nil, "https://hooks.example.com/done", http.StatusUnprocessableEntity},
+	} {
+		handler := NewJobsHandler(registry, queue, mocks.NewMockStorage(), testLogger(), "default-voice", 24, false, 0, nil, nil, nil, tt.callbacks)
+		body, _ := json.Marshal(JobCreateRequest{Text: "Hello", CallbackURL: tt.url})
+		w := httptest.NewRecorder()
+		handler.SubmitJob(w, httptest.NewRequest(http.MethodPost, "/api/v1/jobs", bytes.NewReader(body)))
+		if w.Code != tt.want {
+			t.Fatalf("%s: expected status %d, got %d: %s", tt.name, tt.want, w.Code, w.Body.String())
+		}
+		if w.Code != http.StatusCreated {
+			continue
+		}
+
+		var resp JobCreateResponse
+		json.Unmarshal(w.Body.Bytes(), &resp) //nolint:errcheck
+		job, err := queue.GetJob(context.Background(), resp.JobID)
+		if err != nil || job.CallbackURL != tt.url {
+			t.Errorf("%s: expected callback URL %s, got %+v", tt.name, tt.url, job)
+		}
+	}
+}
+
+func TestJobsHandler_SubmitJob_Deadline(t *testing.T) {
+	queue := memory.NewQueue(10)
+	handler := NewJobsHandler(mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"}), queue, mocks.NewMockStorage(),
+		testLogger(), "default-voice", 24, false, 0, nil, nil, nil, nil)
+
+	future := time.Now().Add(time.Minute).Truncate(time.Millisecond).UTC()
+	passed := time.Now().Add(-time.Second)
+	for _, tt := range []struct {
+		name   string
+		body   *time.Time
+		header string
+		want   int
+	}{
+		{"body deadline", &future, "", http.StatusCreated},
+		{"header deadline", nil, deadline.Format(future), http.StatusCreated},
+		{"passed deadline", &passed, "", http.StatusGatewayTimeout},
+		{"malformed header", nil, "soon", http.StatusUnprocessableEntity},
+	} {
+		body, _ := json.Marshal(JobCreateRequest{Text: "Hello", Deadline: tt.body})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/jobs", bytes.NewReader(body))
+		if tt.header != "" {
+			req.Header.Set(deadline.Header, tt.header)
+		}
+		w := httptest.NewRecorder()
+		handler.SubmitJob(w, req)
+		if w.Code != tt.want {
+			t.Fatalf("%s: expected status %d, got %d: %s", tt.name, tt.want, w.Code, w.Body.String())
+		}
+		if w.Code != http.StatusCreated {
+			continue
+		}
+
+		var resp JobCreateResponse
+		json.Unmarshal(w.Body.Bytes(), &resp) //nolint:errcheck
+		job, err := queue.GetJob(context.Background(), resp.JobID)
+		if err != nil || job.Deadline == nil || !job.Deadline.Equal(future) {
+			t.Errorf("%s: expected deadline %v, got %+v", tt.name, future, job)
+		}
+	}
+}
+
+func TestJobsHandler_ListJobs(t *testing.T) {
+	queue := memory.NewQueue(10)
+	handler := NewJobsHandler(mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"}), queue, mocks.NewMockStorage(),
+		testLogger(), "default-voice", 24, false, 0, nil, nil, nil, nil)
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		job := domain.NewJob("text", "voice", "", "", "test-provider", "mp3", nil)
+		job.CreatedAt = job.CreatedAt.Add(time.Duration(i) * time.Second)
+		queue.Enqueue(ctx, job) //nolint:errcheck
+		if i == 0 {
+			job.SetCompleted("/storage/"+job.ID+".mp3", 1)
+			queue.UpdateJob(ctx, job) //nolint:errcheck
+		}
+	}
+
+	list := func(query string) (*httptest.ResponseRecorder, JobListResponse) {
+		w := httptest.NewRecorder()
+		handler.ListJobs(w, httptest.NewRequest(http.MethodGet, "/api/v1/jobs"+query, nil))
+		var resp JobListResponse
+		json.Unmarshal(w.Body.Bytes(), &resp) //nolint:errcheck
+		return w, resp
+	}
+
+	w, first := list("?limit=2")
+	if w.Code != http.StatusOK || len(first.Jobs) != 2 || first.NextCursor == "" {
+		t.Fatalf("unexpected first page %d %+v", w.Code, first)
+	}
+	_, second := list("?limit=2&cursor=" + first.NextCursor)
+	if len(second.Jobs) != 1 || second.NextCursor != "" || second.Jobs[0].Status != "completed" {
+		t.Errorf("expected the oldest, completed job last, got %+v", second)
+	}
+
+	if _, resp := list("?status=queued&order=asc"); len(resp.Jobs) != 2 || resp.Jobs[0].CreatedAt > resp.Jobs[1].CreatedAt {
+		t.Errorf("unexpected queued jobs %+v", resp.Jobs)
+	}
+
+	for _, query := range []string{"?status=done", "?limit=0", "?limit=101", "?order=up", "?cursor=bogus"} {
+		if w, _ := list(query); w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected status 422, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestJobsHandler_ListJobs_Filters(t *testing.T) {
+	queue := memory.NewQueue(10)
+	handler := NewJobsHandler(mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"}), queue, mocks.NewMockStorage(),
+		testLogger(), "default-voice", 24, false, 0, nil, nil, nil, nil)
+
+	ctx := context.Background()
+	for _, j := range []struct{ provider, voice, format string }{
+		{"elevenlabs", "rachel", "mp3"},
+		{"elevenlabs", "adam", "mp3"},
+		{"elevenlabs", "rachel", "wav"},
+		{"gemini", "rachel", "mp3"},
+	} {
+		job := domain.NewJob("text", j.voice, "", "", j.provider, j.format, nil)
+		queue.Enqueue(ctx, job) //nolint:errcheck
+		job.SetFailed("provider error")
+		queue.UpdateJob(ctx, job) //nolint:errcheck
+	}
+
+	for query, want := range map[string]int{
+		"?provider_name=elevenlabs":                               3,
+		"?provider_name=elevenlabs&voice_id=rachel":               2,
+		"?provider_name=elevenlabs&voice_id=rachel&status=failed": 2,
+		"?voice_id=rachel&output_format=mp3":                      2,
+		"?output_format=wav":                                      1,
+		"?provider_name=elevenlabs&status=completed":              0,
+		"?provider_name=piper":                                    0,
+		"?status=expired":                                         0,
+	} {
+		w := httptest.NewRecorder()
+		handler.ListJobs(w, httptest.NewRequest(http.MethodGet, "/api/v1/jobs"+query, nil))
+		var resp JobListResponse
+		json.Unmarshal(w.Body.Bytes(), &resp) //nolint:errcheck
+		if w.Code != http.StatusOK || len(resp.Jobs) != want {
+			t.Errorf("%s: expected %d jobs, got %d (status %d)", query, want, len(resp.Jobs), w.Code)
+		}
+	}
+}
+
+func TestJobsHandler_SubmitJob_Pipeline(t *testing.T) {
+	queue := memory.NewQueue(10)
+	handler := NewJobsHandler(mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"}), queue, mocks.NewMockStorage(),
+		testLogger(), "default-voice", 24, false, 0, nil, nil, nil, nil)
+
+	submit := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.SubmitJob(w, httptest.NewRequest(http.MethodPost, "/api/v1/jobs", strings.NewReader(body)))
+		return w
+	}
+
+	w := submit(`{"text":"Hello","pipeline":[{"stage":"normalize"},{"stage":"synthesize"},{"stage":"tag","params":{"title":"Intro"}}]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp JobCreateResponse
+	json.Unmarshal(w.Body.Bytes(), &resp) //nolint:errcheck
+	job, err := queue.GetJob(context.Background(), resp.JobID)
+	if err != nil || len(job.Pipeline) != 3 || job.Pipeline[2].Params["title"] != "Intro" {
+		t.Errorf("expected the pipeline on the job, got %+v", job)
+	}
+
+	w = submit(`{"text":"Hello","pipeline":[{"stage":"synthesize"},{"stage":"normalize"}]}`)
+	var errResp domain.ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &errResp) //nolint:errcheck
+	if w.Code != http.StatusUnprocessableEntity || errResp.Error.Code != "INVALID_PIPELINE" || errResp.Error.Details["stage_index"] != float64(1) {
+		t.Errorf("expected 422 INVALID_PIPELINE at stage 1, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestJobsHandler_RetryJob(t *testing.T) {
+	queue := memory.NewQueue(10)
+	handler := NewJobsHandler(mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"}), queue, mocks.NewMockStorage(),
+		testLogger(), "default-voice", 24, false, 0, nil, nil, nil, nil)
+
+	ctx := context.Background()
+	failed := domain.NewJob("hello", "voice", "eleven_v3", "en", "test-provider", "wav", nil)
+	failed.TenantID = "acme"
+	failed.CallbackURL = "https://example.com/done"
+	passed := time.Now().Add(-time.Minute)
+	failed.Deadline = &passed
+	done := domain.NewJob("done", "voice", "", "", "test-provider", "mp3", nil)
+	done.TenantID = "acme"
+	queue.Enqueue(ctx, failed) //nolint:errcheck
+	queue.Enqueue(ctx, done)   //nolint:errcheck
+	failed.SetFailed("provider unavailable")
+	queue.UpdateJob(ctx, failed) //nolint:errcheck
+
+	tenant := "acme"
+	retry := func(jobID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/jobs/"+jobID+"/retry", nil)
+		req.Header.Set(middleware.TenantHeader, tenant)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("jobID", jobID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		handler.RetryJob(w, req)
+		return w
+	}
+
+	w := retry(failed.ID)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp JobCreateResponse
+	json.NewDecoder(w.Body).Decode(&resp) //nolint:errcheck
+	retried, err := queue.GetJob(ctx, resp.JobID)
+	if err != nil {
+		t.Fatalf("Expected the new job queued: %v", err)
+	}
+	if retried.ID == failed.ID || retried.Status != domain.JobStatusQueued {
+		t.Errorf("Expected a new queued job, got %s %s", retried.ID, retried.Status)
+	}
+	if retried.Text != "hello" || retried.ModelID != "eleven_v3" || retried.LanguageCode != "en" || retried.OutputFormat != "wav" ||
+		retried.TenantID != "acme" || retried.CallbackURL != failed.CallbackURL {
+		t.Errorf("Expected the failed job's parameters, got %+v", retried)
+	}
+	if retried.Deadline != nil {
+		t.Errorf("Expected the passed deadline dropped, got %v", retried.Deadline)
+	}
+	if first := retried.Events[0]; first.Type != domain.JobEventRetried || first.Message != "retried from job "+failed.ID {
+		t.Errorf("Expected a retried event, got %+v", retried.Events)
+	}
+	if original, _ := queue.GetJob(ctx, failed.ID); original.Status != domain.JobStatusFailed {
+		t.Errorf("Expected the failed job left failed, got %s", original.Status)
+	}
+
+	w = retry(done.ID)
+	var errResp domain.ErrorResponse
+	json.NewDecoder(w.Body).Decode(&errResp) //nolint:errcheck
+	if w.Code != http.StatusConflict || errResp.Error.Code != "JOB_NOT_RETRYABLE" || errResp.Error.Details["current_status"] != "queued" {
+		t.Errorf("Expected 409 JOB_NOT_RETRYABLE, got %d %+v", w.Code, errResp.Error)
+	}
+
+	if w := retry("missing"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown job, got %d", w.Code)
+	}
+	tenant = "globex"
+	if w := retry(failed.ID); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for another tenant's job, got %d", w.Code)
+	}
+}
+
+func TestJobsHandler_OtherTenantsJobsNotFound(t *testing.T) {
+	queue := memory.NewQueue(10)
+	handler := NewJobsHandler(mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"}), queue, mocks.NewMockStorage(),
+		testLogger(), "default-voice", 24, false, 0, nil, nil, nil, nil)
+
+	ctx := context.Background()
+	completed := domain.NewJob("hello", "voice", "", "", "test-provider", "mp3", nil)
+	completed.TenantID = "acme"
+	queued := domain.NewJob("queued", "voice", "", "", "test-provider", "mp3", nil)
+	queued.TenantID = "acme"
+	queue.Enqueue(ctx, completed) //nolint:errcheck
+	queue.Enqueue(ctx, queued)    //nolint:errcheck
+	completed.SetCompleted("/storage/"+completed.ID+".mp3", 24)
+	queue.UpdateJob(ctx, completed) //nolint:errcheck
+
+	for _, tt := range []struct {
+		name    string
+		method  string
+		handler http.HandlerFunc
+		job     *domain.Job
+	}{
+		{"status", http.MethodGet, handler.GetJobStatus, completed},
+		{"cancel", http.MethodDelete, handler.CancelJob, queued},
+		{"result", http.MethodGet, handler.GetJobResult, completed},
+		{"delete result", http.MethodDelete, handler.DeleteJobResult, completed},
+		{"artifacts", http.MethodGet, handler.GetJobArtifacts, completed},
+		{"preview", http.MethodGet, handler.GetJobPreview, completed},
+		{"waveform", http.MethodGet, handler.GetJobWaveform, completed},
+		{"regenerate", http.MethodPost, handler.RegenerateJob, completed},
+		{"retry", http.MethodPost, handler.RetryJob, completed},
+	} {
+		req := httptest.NewRequest(tt.method, "/api/v1/jobs/"+tt.job.ID, nil)
+		req.Header.Set(middleware.TenantHeader, "globex")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("jobID", tt.job.ID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		tt.handler(w, req)
+
+		var errResp domain.ErrorResponse
+		json.NewDecoder(w.Body).Decode(&errResp) //nolint:errcheck
+		if w.Code != http.StatusNotFound || errResp.Error == nil || errResp.Error.Code != "JOB_NOT_FOUND" {
+			t.Errorf("%s: expected 404 JOB_NOT_FOUND, got %d %+v", tt.name, w.Code, errResp.Error)
+		}
+	}
+
+	if job, _ := queue.GetJob(ctx, queued.ID); job.Status != domain.JobStatusQueued {
+		t.Errorf("expected the job left queued, got %s", job.Status)
+	}
+	if job, _ := queue.GetJob(ctx, completed.ID); job.Status != domain.JobStatusCompleted || queue.Stats().TotalJobs != 2 {
+		t.Errorf("expected the completed job left alone, got %s", job.Status)
+	}
+}
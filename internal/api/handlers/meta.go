@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/pako-tts/server/internal/api/middleware"
+	"github.com/pako-tts/server/internal/audio/transcode"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/version"
+)
+
+// APIVersions are the API versions this server serves, oldest first.
+var APIVersions = []string{"v1"}
+
+// MetaLimits are the configured limits requests must stay within.
+type MetaLimits struct {
+	MaxSyncTextLength  int     `json:"max_sync_text_length"`
+	SyncTimeoutSeconds float64 `json:"sync_timeout_seconds"`
+	// RetentionHours is how long job results are kept.
+	RetentionHours int `json:"retention_hours"`
+	// RegenerateGraceHours is how long after its result expired a job can still
+	// be regenerated without sending its text again.
+	RegenerateGraceHours float64 `json:"regenerate_grace_hours"`
+	MaxGroupSegments     int     `json:"max_group_segments"`
+	MaxWarmItems         int     `json:"max_warm_items"`
+	MaxJobListLimit      int     `json:"max_job_list_limit"`
+}
+
+// MetaResponse describes the server, so clients can adapt to its version,
+// features and limits instead of assuming them.
+type MetaResponse struct {
+	Build       version.Info `json:"build"`
+	APIVersions []string     `json:"api_versions"`
+	// Features says which API surfaces and optional behaviors are on.
+	Features        map[string]bool `json:"features"`
+	Limits          MetaLimits      `json:"limits"`
+	OutputFormats   []string        `json:"output_formats"`
+	Providers       []string        `json:"providers"`
+	DefaultProvider string          `json:"default_provider"`
+}
+
+// MetaHandler serves the server's description.
+type MetaHandler struct {
+	registry domain.ProviderRegistry
+	features map[string]bool
+	limits   MetaLimits
+}
+
+// NewMetaHandler creates a new meta handler. features names the API surfaces
+// and optional behaviors that are on or off.
+func NewMetaHandler(registry domain.ProviderRegistry, features map[string]bool, maxSyncTextLen int, syncTimeout time.Duration, retentionHours int, regenerateGrace time.Duration) *MetaHandler {
+	return &MetaHandler{
+		registry: registry,
+		features: features,
+		limits: MetaLimits{
+			MaxSyncTextLength:    maxSyncTextLen,
+			SyncTimeoutSeconds:   syncTimeout.Seconds(),
+			RetentionHours:       retentionHours,
+			RegenerateGraceHours: regenerateGrace.Hours(),
+			MaxGroupSegments:     maxGroupSegments,
+			MaxWarmItems:         maxWarmItems,
+			MaxJobListLimit:      maxJobListLimit,
+		},
+	}
+}
+
+// GetMeta handles GET /api/v1/meta.
+func (h *MetaHandler) GetMeta(w http.ResponseWriter, r *http.Request) {
+	providers := make([]string, 0)
+	for _, p := range h.registry.List() {
+		providers = append(providers, p.Name())
+	}
+	middleware.WriteJSON(w, http.StatusOK, MetaResponse{
+		Build:           version.Get(),
+		APIVersions:     APIVersions,
+		Features:        h.features,
+		Limits:          h.limits,
+		OutputFormats:   transcode.OutputFormats,
+		Providers:       providers,
+		DefaultProvider: h.registry.DefaultName(),
+	})
+}
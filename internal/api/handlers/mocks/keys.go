@@ -0,0 +1,34 @@
+package mocks
+
+import (
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// MockKeyManager is a mock implementation of domain.ProviderKeyManager for testing.
+type MockKeyManager struct {
+	Status []domain.ProviderKeyStatus
+	Keys   map[string][2]string // provider name -> primary, secondary
+}
+
+func NewMockKeyManager(status ...domain.ProviderKeyStatus) *MockKeyManager {
+	return &MockKeyManager{
+		Status: status,
+		Keys:   make(map[string][2]string),
+	}
+}
+
+func (m *MockKeyManager) SetAPIKeys(name, primary, secondary string) error {
+	for i := range m.Status {
+		if m.Status[i].Provider == name {
+			m.Keys[name] = [2]string{primary, secondary}
+			m.Status[i].ActiveKey = domain.KeyPrimary
+			m.Status[i].HasSecondary = secondary != ""
+			return nil
+		}
+	}
+	return domain.ErrProviderNotFound
+}
+
+func (m *MockKeyManager) KeyStatus() []domain.ProviderKeyStatus {
+	return m.Status
+}
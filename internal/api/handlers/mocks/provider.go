@@ -3,6 +3,7 @@ package mocks
 import (
 	"bytes"
 	"context"
+	"time"
 
 	"github.com/pako-tts/server/internal/domain"
 )
@@ -123,6 +124,8 @@ type MockProviderRegistry struct {
 	Providers       map[string]domain.TTSProvider
 	DefaultProvider domain.TTSProvider
 	DefaultNameVal  string
+	RouteFunc       func(ctx context.Context, textLength int) string
+	Observed        []string
 }
 
 // NewMockProviderRegistry creates a new mock registry with a single default provider.
@@ -172,3 +175,14 @@ func (r *MockProviderRegistry) ListInfo(ctx context.Context) []domain.ProviderIn
 func (r *MockProviderRegistry) DefaultName() string {
 	return r.DefaultNameVal
 }
+
+func (r *MockProviderRegistry) Route(ctx context.Context, textLength int) string {
+	if r.RouteFunc != nil {
+		return r.RouteFunc(ctx, textLength)
+	}
+	return r.DefaultNameVal
+}
+
+func (r *MockProviderRegistry) Observe(name string, textLength int, latency time.Duration, err error) {
+	r.Observed = append(r.Observed, name)
+}
@@ -16,6 +16,7 @@ type MockStorage struct {
 	ExistsFunc   func(ctx context.Context, jobID string) bool
 	GetPathFunc  func(ctx context.Context, jobID string) string
 	StoredFiles  map[string][]byte
+	Artifacts    map[string][]byte // keyed by jobID + "/" + name
 	StoreError   error
 	RetrieveError error
 }
@@ -23,6 +24,7 @@ type MockStorage struct {
 func NewMockStorage() *MockStorage {
 	return &MockStorage{
 		StoredFiles: make(map[string][]byte),
+		Artifacts:   make(map[string][]byte),
 	}
 }
 
@@ -77,3 +79,19 @@ func (m *MockStorage) GetPath(ctx context.Context, jobID string) string {
 	}
 	return ""
 }
+
+func (m *MockStorage) StoreArtifact(ctx context.Context, jobID, name string, data []byte) error {
+	if m.StoreError != nil {
+		return m.StoreError
+	}
+	m.Artifacts[jobID+"/"+name] = data
+	return nil
+}
+
+func (m *MockStorage) RetrieveArtifact(ctx context.Context, jobID, name string) (io.ReadCloser, error) {
+	data, ok := m.Artifacts[jobID+"/"+name]
+	if !ok {
+		return nil, domain.ErrArtifactNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
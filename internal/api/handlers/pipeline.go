@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/pako-tts/server/internal/api/middleware"
+	"github.com/pako-tts/server/internal/pipeline"
+)
+
+// PipelineStagesResponse lists the processors a request's pipeline can name.
+type PipelineStagesResponse struct {
+	Stages []pipeline.Processor `json:"stages"`
+}
+
+// ListPipelineStages handles GET /api/v1/pipeline/stages.
+func ListPipelineStages(w http.ResponseWriter, r *http.Request) {
+	middleware.WriteJSON(w, http.StatusOK, PipelineStagesResponse{Stages: pipeline.Processors()})
+}
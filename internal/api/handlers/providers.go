@@ -1,26 +1,53 @@
 package handlers
 
 import (
+	"context"
+	"io"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 
 	"github.com/pako-tts/server/internal/api/middleware"
+	"github.com/pako-tts/server/internal/deadline"
 	"github.com/pako-tts/server/internal/domain"
 )
 
+// previewTimeout bounds fetching a voice preview from its provider.
+const previewTimeout = 30 * time.Second
+
 // ProvidersHandler handles provider-related requests.
 type ProvidersHandler struct {
 	registry domain.ProviderRegistry
 	logger   *zap.Logger
+
+	// voicesTTL is how long a provider's voice list is reused; 0 disables caching.
+	voicesTTL time.Duration
+	mu        sync.Mutex
+	voices    map[string]cachedVoices
+
+	previewClient *http.Client
+}
+
+type cachedVoices struct {
+	voices  []domain.Voice
+	expires time.Time
 }
 
-// NewProvidersHandler creates a new providers handler.
-func NewProvidersHandler(registry domain.ProviderRegistry, logger *zap.Logger) *ProvidersHandler {
+// NewProvidersHandler creates a new providers handler. Voice lists are cached
+// per provider for voicesTTL.
+func NewProvidersHandler(registry domain.ProviderRegistry, logger *zap.Logger, voicesTTL time.Duration) *ProvidersHandler {
 	return &ProvidersHandler{
-		registry: registry,
-		logger:   logger,
+		registry:  registry,
+		logger:    logger,
+		voicesTTL: voicesTTL,
+		voices:    make(map[string]cachedVoices),
+
+		previewClient: &http.Client{Timeout: previewTimeout},
 	}
 }
 
@@ -58,7 +85,7 @@ func (h *ProvidersHandler) ListVoices(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	voices, err := provider.ListVoices(r.Context())
+	voices, err := h.listVoices(r.Context(), provider)
 	if err != nil {
 		h.logger.Error("ListVoices failed", zap.String("provider", name), zap.Error(err))
 		middleware.WriteError(w, domain.ErrProviderUnavailable.WithMessage(err.Error()))
@@ -72,6 +99,217 @@ func (h *ProvidersHandler) ListVoices(w http.ResponseWriter, r *http.Request) {
 	middleware.WriteJSON(w, http.StatusOK, VoicesListResponse{Provider: name, Voices: voices})
 }
 
+// AllVoicesResponse represents the voices of every provider.
+type AllVoicesResponse struct {
+	Voices []domain.Voice `json:"voices"`
+	// UnavailableProviders names providers whose voices couldn't be listed.
+	UnavailableProviders []string `json:"unavailable_providers,omitempty"`
+}
+
+// ListAllVoices handles GET /api/v1/voices. The language, gender and provider
+// query parameters filter the voices; language matches a prefix, so "en" finds
+// "en-US" voices. A provider that fails is left out and named in the response,
+// unless every provider fails.
+func (h *ProvidersHandler) ListAllVoices(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	language := strings.ToLower(query.Get("language"))
+	gender := query.Get("gender")
+
+	providers := h.registry.List()
+	if name := query.Get("provider"); name != "" {
+		provider, err := h.registry.Get(name)
+		if err != nil {
+			middleware.WriteError(w, domain.ErrProviderNotFound.WithMessage("Provider '"+name+"' not found"))
+			return
+		}
+		providers = []domain.TTSProvider{provider}
+	}
+
+	lists := make([][]domain.Voice, len(providers))
+	errs := make([]error, len(providers))
+	var wg sync.WaitGroup
+	for i, provider := range providers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lists[i], errs[i] = h.listVoices(r.Context(), provider)
+		}()
+	}
+	wg.Wait()
+
+	response := AllVoicesResponse{Voices: []domain.Voice{}}
+	for i, provider := range providers {
+		if errs[i] != nil {
+			h.logger.Warn("ListVoices failed", zap.String("provider", provider.Name()), zap.Error(errs[i]))
+			response.UnavailableProviders = append(response.UnavailableProviders, provider.Name())
+			continue
+		}
+		for _, voice := range lists[i] {
+			if language != "" && !strings.HasPrefix(strings.ToLower(voice.Language), language) {
+				continue
+			}
+			if gender != "" && !strings.EqualFold(voice.Gender, gender) {
+				continue
+			}
+			if voice.Provider == "" {
+				voice.Provider = provider.Name()
+			}
+			response.Voices = append(response.Voices, voice)
+		}
+	}
+	if len(providers) > 0 && len(response.UnavailableProviders) == len(providers) {
+		middleware.WriteError(w, domain.ErrProviderUnavailable.WithMessage("no provider could list its voices"))
+		return
+	}
+
+	middleware.WriteJSON(w, http.StatusOK, response)
+}
+
+// VoiceResponse represents a single voice.
+type VoiceResponse struct {
+	domain.Voice
+	// Preview is the path serving the voice's preview through this server, when
+	// the provider has one.
+	Preview string `json:"preview,omitempty"`
+}
+
+// GetVoice handles GET /api/v1/voices/{voiceID}. The provider query parameter
+// picks the provider when several offer the same voice ID; otherwise the
+// default provider is searched first, then the others in configured order.
+func (h *ProvidersHandler) GetVoice(w http.ResponseWriter, r *http.Request) {
+	voice, apiErr := h.findVoice(r)
+	if apiErr != nil {
+		middleware.WriteError(w, apiErr)
+		return
+	}
+
+	response := VoiceResponse{Voice: voice}
+	if voice.PreviewURL != "" {
+		response.Preview = "/api/v1/voices/" + url.PathEscape(voice.VoiceID) + "/preview?provider=" + url.QueryEscape(voice.Provider)
+	}
+	middleware.WriteJSON(w, http.StatusOK, response)
+}
+
+// GetVoicePreview handles GET /api/v1/voices/{voiceID}/preview. It streams the
+// provider's preview audio, so clients need neither provider credentials nor
+// access to the provider's hosts.
+func (h *ProvidersHandler) GetVoicePreview(w http.ResponseWriter, r *http.Request) {
+	voice, apiErr := h.findVoice(r)
+	if apiErr != nil {
+		middleware.WriteError(w, apiErr)
+		return
+	}
+	u, err := url.Parse(voice.PreviewURL)
+	if voice.PreviewURL == "" || err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		middleware.WriteError(w, domain.ErrPreviewNotFound.WithMessage("Voice '"+voice.VoiceID+"' has no preview"))
+		return
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, u.String(), nil)
+	if err != nil {
+		middleware.WriteError(w, domain.ErrInternalServer)
+		return
+	}
+	deadline.Set(req)
+	resp, err := h.previewClient.Do(req)
+	if err != nil {
+		h.logger.Warn("Voice preview fetch failed", zap.String("voice_id", voice.VoiceID), zap.Error(err))
+		middleware.WriteError(w, domain.ErrProviderUnavailable.WithMessage("preview could not be fetched"))
+		return
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		h.logger.Warn("Voice preview fetch failed", zap.String("voice_id", voice.VoiceID), zap.Int("status", resp.StatusCode))
+		middleware.WriteError(w, domain.ErrProviderUnavailable.WithMessage("preview could not be fetched"))
+		return
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" || !strings.HasPrefix(contentType, "audio/") {
+		contentType = "audio/mpeg"
+	}
+	w.Header().Set("Content-Type", contentType)
+	if length := resp.Header.Get("Content-Length"); length != "" {
+		w.Header().Set("Content-Length", length)
+	}
+	// Previews rarely change; clients may keep them for an hour
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, resp.Body) //nolint:errcheck
+}
+
+// findVoice looks up the voice a voice request names.
+func (h *ProvidersHandler) findVoice(r *http.Request) (domain.Voice, *domain.APIError) {
+	voiceID := chi.URLParam(r, "voiceID")
+
+	var providers []domain.TTSProvider
+	if name := r.URL.Query().Get("provider"); name != "" {
+		provider, err := h.registry.Get(name)
+		if err != nil {
+			return domain.Voice{}, domain.ErrProviderNotFound.WithMessage("Provider '" + name + "' not found")
+		}
+		providers = []domain.TTSProvider{provider}
+	} else {
+		defaultName := h.registry.DefaultName()
+		if provider, err := h.registry.Get(defaultName); err == nil {
+			providers = append(providers, provider)
+		}
+		for _, provider := range h.registry.List() {
+			if provider.Name() != defaultName {
+				providers = append(providers, provider)
+			}
+		}
+	}
+
+	failed := false
+	for _, provider := range providers {
+		voices, err := h.listVoices(r.Context(), provider)
+		if err != nil {
+			h.logger.Warn("ListVoices failed", zap.String("provider", provider.Name()), zap.Error(err))
+			failed = true
+			continue
+		}
+		for _, voice := range voices {
+			if voice.VoiceID == voiceID {
+				if voice.Provider == "" {
+					voice.Provider = provider.Name()
+				}
+				return voice, nil
+			}
+		}
+	}
+	// The voice may belong to a provider that couldn't be asked
+	if failed {
+		return domain.Voice{}, domain.ErrProviderUnavailable.WithMessage("voices could not be listed")
+	}
+	return domain.Voice{}, domain.ErrVoiceNotFound.WithMessage("Voice '" + voiceID + "' not found")
+}
+
+// listVoices returns the voices of provider, from the cache while they are fresh.
+// Failures aren't cached.
+func (h *ProvidersHandler) listVoices(ctx context.Context, provider domain.TTSProvider) ([]domain.Voice, error) {
+	name := provider.Name()
+	if h.voicesTTL > 0 {
+		h.mu.Lock()
+		cached, ok := h.voices[name]
+		h.mu.Unlock()
+		if ok && time.Now().Before(cached.expires) {
+			return cached.voices, nil
+		}
+	}
+
+	voices, err := provider.ListVoices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if h.voicesTTL > 0 {
+		h.mu.Lock()
+		h.voices[name] = cachedVoices{voices: voices, expires: time.Now().Add(h.voicesTTL)}
+		h.mu.Unlock()
+	}
+	return voices, nil
+}
+
 // ModelsListResponse represents the models list response for a provider.
 type ModelsListResponse struct {
 	Provider string         `json:"provider"`
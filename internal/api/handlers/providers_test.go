@@ -9,6 +9,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
@@ -74,7 +75,7 @@ func TestProvidersHandler_ListVoices(t *testing.T) {
 			}
 			registry := mocks.NewMockProviderRegistry(mockProvider)
 
-			handler := NewProvidersHandler(registry, logger)
+			handler := NewProvidersHandler(registry, logger, 0)
 
 			req := httptest.NewRequest(http.MethodGet, "/api/v1/providers/"+tt.providerName+"/voices", nil)
 			rctx := chi.NewRouteContext()
@@ -143,6 +144,145 @@ func TestProvidersHandler_ListVoices(t *testing.T) {
 	}
 }
 
+func TestProvidersHandler_ListAllVoices(t *testing.T) {
+	calls := 0
+	cloud := &mocks.MockProvider{
+		NameValue: "cloud",
+		ListVoicesFunc: func(ctx context.Context) ([]domain.Voice, error) {
+			calls++
+			return []domain.Voice{
+				{VoiceID: "c1", Name: "Ada", Provider: "cloud", Language: "en-US", Gender: "female"},
+				{VoiceID: "c2", Name: "Bruno", Provider: "cloud", Language: "de-DE", Gender: "male"},
+			}, nil
+		},
+	}
+	local := &mocks.MockProvider{
+		NameValue: "local",
+		ListVoicesFunc: func(ctx context.Context) ([]domain.Voice, error) {
+			return []domain.Voice{{VoiceID: "l1", Name: "Amy", Language: "en-GB", Gender: "Female"}}, nil
+		},
+	}
+	broken := &mocks.MockProvider{
+		NameValue: "broken",
+		ListVoicesFunc: func(ctx context.Context) ([]domain.Voice, error) {
+			return nil, errors.New("upstream failure")
+		},
+	}
+	registry := mocks.NewMockProviderRegistry(cloud)
+	registry.Providers["local"] = local
+	registry.Providers["broken"] = broken
+	handler := NewProvidersHandler(registry, testLogger(), time.Minute)
+
+	list := func(query string) (int, AllVoicesResponse) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.ListAllVoices(w, httptest.NewRequest(http.MethodGet, "/api/v1/voices"+query, nil))
+		var body AllVoicesResponse
+		json.NewDecoder(w.Body).Decode(&body) //nolint:errcheck
+		return w.Code, body
+	}
+
+	status, body := list("?language=en&gender=female")
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	ids := make(map[string]string)
+	for _, v := range body.Voices {
+		ids[v.VoiceID] = v.Provider
+	}
+	if len(ids) != 2 || ids["c1"] != "cloud" || ids["l1"] != "local" {
+		t.Errorf("expected the English female voices of both providers, got %+v", body.Voices)
+	}
+	if len(body.UnavailableProviders) != 1 || body.UnavailableProviders[0] != "broken" {
+		t.Errorf("expected the failing provider named, got %v", body.UnavailableProviders)
+	}
+
+	if status, body := list("?provider=cloud"); status != http.StatusOK || len(body.Voices) != 2 || body.UnavailableProviders != nil {
+		t.Errorf("expected the cloud voices only, got %d %+v", status, body)
+	}
+	if calls != 1 {
+		t.Errorf("expected the cloud voices from the cache, got %d calls", calls)
+	}
+
+	if status, _ := list("?provider=missing"); status != http.StatusNotFound {
+		t.Errorf("expected an unknown provider to be 404, got %d", status)
+	}
+	if status, _ := list("?provider=broken"); status != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 when no provider lists its voices, got %d", status)
+	}
+}
+
+func TestProvidersHandler_GetVoice(t *testing.T) {
+	preview := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ada.mp3" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Write([]byte("ID3 preview audio")) //nolint:errcheck
+	}))
+	t.Cleanup(preview.Close)
+
+	cloud := &mocks.MockProvider{
+		NameValue: "cloud",
+		ListVoicesFunc: func(ctx context.Context) ([]domain.Voice, error) {
+			return []domain.Voice{
+				{VoiceID: "ada", Name: "Ada", Provider: "cloud", PreviewURL: preview.URL + "/ada.mp3"},
+				{VoiceID: "gone", Name: "Gone", Provider: "cloud", PreviewURL: preview.URL + "/gone.mp3"},
+			}, nil
+		},
+	}
+	local := &mocks.MockProvider{
+		NameValue: "local",
+		ListVoicesFunc: func(ctx context.Context) ([]domain.Voice, error) {
+			return []domain.Voice{{VoiceID: "amy", Name: "Amy"}}, nil
+		},
+	}
+	registry := mocks.NewMockProviderRegistry(cloud)
+	registry.Providers["local"] = local
+	handler := NewProvidersHandler(registry, testLogger(), time.Minute)
+
+	get := func(handle http.HandlerFunc, voiceID, query string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/voices/"+voiceID+query, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("voiceID", voiceID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		handle(w, req)
+		return w
+	}
+
+	w := get(handler.GetVoice, "ada", "")
+	var voice VoiceResponse
+	json.NewDecoder(w.Body).Decode(&voice) //nolint:errcheck
+	if w.Code != http.StatusOK || voice.Name != "Ada" || voice.Preview != "/api/v1/voices/ada/preview?provider=cloud" {
+		t.Errorf("expected Ada with a preview path, got %d %+v", w.Code, voice)
+	}
+
+	w = get(handler.GetVoice, "amy", "")
+	voice = VoiceResponse{}
+	json.NewDecoder(w.Body).Decode(&voice) //nolint:errcheck
+	if w.Code != http.StatusOK || voice.Provider != "local" || voice.Preview != "" {
+		t.Errorf("expected Amy from the local provider without a preview, got %d %+v", w.Code, voice)
+	}
+
+	if w := get(handler.GetVoice, "amy", "?provider=cloud"); w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "VOICE_NOT_FOUND") {
+		t.Errorf("expected Amy not found at the cloud provider, got %d %s", w.Code, w.Body)
+	}
+
+	w = get(handler.GetVoicePreview, "ada", "")
+	if w.Code != http.StatusOK || w.Body.String() != "ID3 preview audio" || w.Header().Get("Content-Type") != "audio/mpeg" {
+		t.Errorf("expected the proxied preview, got %d %q (%s)", w.Code, w.Body, w.Header().Get("Content-Type"))
+	}
+	if w := get(handler.GetVoicePreview, "amy", ""); w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "PREVIEW_NOT_FOUND") {
+		t.Errorf("expected no preview for Amy, got %d %s", w.Code, w.Body)
+	}
+	if w := get(handler.GetVoicePreview, "gone", ""); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 when the preview can't be fetched, got %d", w.Code)
+	}
+}
+
 func TestProvidersHandler_ListModels(t *testing.T) {
 	knownModels := []domain.Model{
 		{ModelID: "eleven_multilingual_v2", Name: "Multilingual v2", Provider: "test-provider", Languages: []string{"en", "es"}},
@@ -201,7 +341,7 @@ func TestProvidersHandler_ListModels(t *testing.T) {
 			}
 			registry := mocks.NewMockProviderRegistry(mockProvider)
 
-			handler := NewProvidersHandler(registry, logger)
+			handler := NewProvidersHandler(registry, logger, 0)
 
 			req := httptest.NewRequest(http.MethodGet, "/api/v1/providers/"+tt.providerName+"/models", nil)
 			rctx := chi.NewRouteContext()
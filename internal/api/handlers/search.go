@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/api/middleware"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/search"
+)
+
+const (
+	// maxSearchQueryLength caps ?q= in characters.
+	maxSearchQueryLength = 200
+	// snippetWords is how many words a snippet shows on each side of the first
+	// matching one.
+	snippetWords = 8
+)
+
+// SearchHandler finds jobs by the words of their text and tags.
+type SearchHandler struct {
+	search          domain.JobSearch
+	regenerateGrace time.Duration
+	logger          *zap.Logger
+}
+
+// NewSearchHandler creates a new search handler. A job's text stays searchable
+// for regenerateGrace after its result expired, as long as it can still be
+// regenerated.
+func NewSearchHandler(jobSearch domain.JobSearch, regenerateGrace time.Duration, logger *zap.Logger) *SearchHandler {
+	return &SearchHandler{
+		search:          jobSearch,
+		regenerateGrace: regenerateGrace,
+		logger:          logger,
+	}
+}
+
+// JobSearchResult is a job found by a search. Snippet shows the job's text
+// around the first matching word, while the text is retained.
+type JobSearchResult struct {
+	JobStatusResponse
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// JobSearchResponse lists the jobs found, best matches first.
+type JobSearchResponse struct {
+	Query string            `json:"query"`
+	Jobs  []JobSearchResult `json:"jobs"`
+}
+
+// SearchJobs handles GET /api/v1/jobs/search. ?q= holds the words to look for;
+// a job matches when its text or tags contain all of them. ?limit= caps the
+// results (default 20, at most 100). Callers see their own tenant's jobs only.
+func (h *SearchHandler) SearchJobs(w http.ResponseWriter, r *http.Request) {
+	query, apiErr := h.parseQuery(r)
+	if apiErr != nil {
+		middleware.WriteError(w, apiErr)
+		return
+	}
+
+	jobs, err := h.search.SearchJobs(r.Context(), query)
+	if err != nil {
+		h.logger.Error("Failed to search jobs", zap.Error(err))
+		middleware.WriteError(w, domain.ErrInternalServer)
+		return
+	}
+
+	terms := search.Tokenize(query.Query)
+	resp := JobSearchResponse{Query: query.Query, Jobs: make([]JobSearchResult, 0, len(jobs))}
+	for _, job := range jobs {
+		result := JobSearchResult{JobStatusResponse: newJobStatusResponse(job)}
+		if query.TextRetained(job) {
+			result.Snippet = snippet(job.Text, terms)
+		}
+		resp.Jobs = append(resp.Jobs, result)
+	}
+	middleware.WriteJSON(w, http.StatusOK, resp)
+}
+
+func (h *SearchHandler) parseQuery(r *http.Request) (domain.JobSearchQuery, *domain.APIError) {
+	params := r.URL.Query()
+	query := domain.JobSearchQuery{
+		Query:      strings.TrimSpace(params.Get("q")),
+		Tenant:     tenantFilter(r),
+		TextExpiry: time.Now().Add(-h.regenerateGrace),
+		Limit:      defaultJobListLimit,
+	}
+
+	if len(search.Tokenize(query.Query)) == 0 {
+		return query, domain.ErrValidation.WithDetails(map[string]any{
+			"field":   "q",
+			"message": "q must contain at least one word",
+		})
+	}
+	if utf8.RuneCountInString(query.Query) > maxSearchQueryLength {
+		return query, domain.ErrValidation.WithDetails(map[string]any{
+			"field":   "q",
+			"message": "q must be at most 200 characters",
+		})
+	}
+
+	if v := params.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxJobListLimit {
+			return query, domain.ErrValidation.WithDetails(map[string]any{
+				"field":   "limit",
+				"message": "limit must be between 1 and 100",
+			})
+		}
+		query.Limit = limit
+	}
+	return query, nil
+}
+
+// snippet returns the words of text around the first one containing a term,
+// or "" when none does.
+func snippet(text string, terms []string) string {
+	words := strings.Fields(text)
+	for i, word := range words {
+		if !containsTerm(word, terms) {
+			continue
+		}
+		from, to := max(0, i-snippetWords), min(len(words), i+snippetWords+1)
+		s := strings.Join(words[from:to], " ")
+		if from > 0 {
+			s = "…" + s
+		}
+		if to < len(words) {
+			s += "…"
+		}
+		return s
+	}
+	return ""
+}
+
+func containsTerm(word string, terms []string) bool {
+	for _, token := range search.Tokenize(word) {
+		if slices.Contains(terms, token) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pako-tts/server/internal/api/middleware"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/queue/memory"
+)
+
+func TestSearchHandler_SearchJobs(t *testing.T) {
+	queue := memory.NewQueueWithOptions(10, memory.Options{Search: true})
+	ctx := context.Background()
+	submit := func(text, tenant string) *domain.Job {
+		job := domain.NewJob(text, "voice", "", "", "elevenlabs", "mp3", nil)
+		job.TenantID = tenant
+		queue.Enqueue(ctx, job) //nolint:errcheck
+		return job
+	}
+	report := submit("Good morning. "+strings.Repeat("Intro words. ", 10)+"Here is the Q3 report for the board, read by our CFO.", "acme")
+	submit("The Q3 report of another tenant", "other")
+	expired := submit("Last year's Q3 report", "acme")
+	expiredAt := time.Now().Add(-2 * time.Hour)
+	expired.ExpiresAt = &expiredAt
+	queue.UpdateJob(ctx, expired) //nolint:errcheck
+
+	auth := middleware.NewAPIKeyAuth([]middleware.APIKey{{Name: "acme", Key: "secret"}})
+	search := func(grace time.Duration, query string) *httptest.ResponseRecorder {
+		h := auth(http.HandlerFunc(NewSearchHandler(queue, grace, testLogger()).SearchJobs))
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/jobs/search"+query, nil)
+		req.Header.Set("X-API-Key", "secret")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The key sees its own tenant's jobs, and only text still retained matches
+	rec := search(time.Hour, "?q=Q3+report&tenant=other")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp JobSearchResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode search: %v", err)
+	}
+	if len(resp.Jobs) != 1 || resp.Jobs[0].JobID != report.ID {
+		t.Fatalf("expected only the retained acme report, got %+v", resp.Jobs)
+	}
+	want := "…words. Intro words. Intro words. Here is the Q3 report for the board, read by our CFO."
+	if resp.Jobs[0].Snippet != want {
+		t.Errorf("snippet = %q, want %q", resp.Jobs[0].Snippet, want)
+	}
+
+	// Within the regenerate grace an expired job's text still matches
+	if err := json.NewDecoder(search(3*time.Hour, "?q=q3").Body).Decode(&resp); err != nil {
+		t.Fatalf("decode search: %v", err)
+	}
+	if len(resp.Jobs) != 2 {
+		t.Errorf("expected 2 jobs within the grace, got %d", len(resp.Jobs))
+	}
+
+	for _, query := range []string{"", "?q=+.,", "?q=" + strings.Repeat("a", 201), "?q=q3&limit=0", "?q=q3&limit=101"} {
+		if rec := search(time.Hour, query); rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%q: expected status 422, got %d", query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// Expansions of a job's status requested with ?include=.
+const (
+	IncludeEvents    = "events"
+	IncludeManifest  = "manifest"
+	IncludeArtifacts = "artifacts"
+)
+
+var expansions = []string{IncludeEvents, IncludeManifest, IncludeArtifacts}
+
+// jobStatusFields are the names ?fields= can select: the JSON fields of
+// JobStatusResponse other than the expansions.
+var jobStatusFields = func() []string {
+	var names []string
+	t := reflect.TypeFor[JobStatusResponse]()
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if !slices.Contains(expansions, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}()
+
+// JobManifest is what a job was asked to produce, returned with
+// ?include=manifest. The text itself is left out; TextLength gives its size.
+type JobManifest struct {
+	VoiceID       string                 `json:"voice_id"`
+	ModelID       string                 `json:"model_id,omitempty"`
+	LanguageCode  string                 `json:"language_code,omitempty"`
+	OutputFormat  string                 `json:"output_format"`
+	TextLength    int                    `json:"text_length"`
+	VoiceSettings *domain.VoiceSettings  `json:"voice_settings,omitempty"`
+	Padding       *domain.PaddingOptions `json:"padding,omitempty"`
+	Source        *domain.TextSource     `json:"source,omitempty"`
+	Pipeline      []domain.PipelineStage `json:"pipeline,omitempty"`
+	BatchID       string                 `json:"batch_id,omitempty"`
+	Segment       int                    `json:"segment,omitempty"`
+}
+
+func newJobManifest(job *domain.Job) *JobManifest {
+	return &JobManifest{
+		VoiceID:       job.VoiceID,
+		ModelID:       job.ModelID,
+		LanguageCode:  job.LanguageCode,
+		OutputFormat:  job.OutputFormat,
+		TextLength:    len(job.Text),
+		VoiceSettings: job.VoiceSettings,
+		Padding:       job.Padding,
+		Source:        job.Source,
+		Pipeline:      job.Pipeline,
+		BatchID:       job.BatchID,
+		Segment:       job.Segment,
+	}
+}
+
+// responseShape is how a caller asked a job's status to be returned: the
+// fields to keep and the expansions to add. The zero value is the full
+// response, events included, as returned without ?fields= and ?include=.
+type responseShape struct {
+	shaped  bool
+	fields  []string
+	include []string
+}
+
+// parseResponseShape reads ?fields= and ?include=, comma-separated lists.
+func parseResponseShape(r *http.Request) (responseShape, *domain.APIError) {
+	params := r.URL.Query()
+	var shape responseShape
+	for _, name := range splitList(params.Get("fields")) {
+		if !slices.Contains(jobStatusFields, name) {
+			return shape, domain.ErrValidation.WithDetails(map[string]any{
+				"field":   "fields",
+				"message": "unknown field '" + name + "'",
+			})
+		}
+		shape.fields = append(shape.fields, name)
+	}
+	for _, name := range splitList(params.Get("include")) {
+		if !slices.Contains(expansions, name) {
+			return shape, domain.ErrValidation.WithDetails(map[string]any{
+				"field":   "include",
+				"message": "include must list events, manifest or artifacts",
+			})
+		}
+		shape.include = append(shape.include, name)
+	}
+	shape.shaped = params.Has("fields") || params.Has("include")
+	return shape, nil
+}
+
+func (s responseShape) includes(expansion string) bool {
+	return (!s.shaped && expansion == IncludeEvents) || slices.Contains(s.include, expansion)
+}
+
+// jobStatus describes job in the requested shape: a JobStatusResponse, or
+// only its selected fields and expansions when ?fields= is set.
+func (h *JobsHandler) jobStatus(ctx context.Context, job *domain.Job, shape responseShape) any {
+	resp := newJobStatusResponse(job)
+	if !shape.includes(IncludeEvents) {
+		resp.Events = nil
+	}
+	if shape.includes(IncludeManifest) {
+		resp.Manifest = newJobManifest(job)
+	}
+	if shape.includes(IncludeArtifacts) && job.Status == domain.JobStatusCompleted {
+		// A failed listing is logged and left out; the status is still current.
+		resp.Artifacts, _ = h.listArtifacts(ctx, job)
+	}
+	if len(shape.fields) == 0 {
+		return resp
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return resp
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return resp
+	}
+	selected := make(map[string]json.RawMessage, len(shape.fields)+len(shape.include))
+	for _, name := range slices.Concat(shape.fields, shape.include) {
+		if v, ok := all[name]; ok {
+			selected[name] = v
+		}
+	}
+	return selected
+}
+
+func splitList(s string) []string {
+	var items []string
+	for item := range strings.SplitSeq(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
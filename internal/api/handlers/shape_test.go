@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/pako-tts/server/internal/api/handlers/mocks"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/queue/memory"
+)
+
+func TestJobsHandler_ResponseShape(t *testing.T) {
+	registry := mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"})
+	queue := memory.NewQueue(10)
+	storage := mocks.NewMockStorage()
+	h := NewJobsHandler(registry, queue, storage, testLogger(), "default-voice", 24, false, 0, nil, nil, nil, nil)
+	router := chi.NewRouter()
+	router.Get("/api/v1/jobs", h.ListJobs)
+	router.Get("/api/v1/jobs/{jobID}", h.GetJobStatus)
+
+	job := domain.NewJob("Hello there", "narrator", "", "", "test-provider", "mp3", nil)
+	job.AddEvent(domain.JobEventQueued, "queued")
+	if err := queue.Enqueue(context.Background(), job); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	job.SetCompleted("/storage/"+job.ID+".mp3", 24)
+	storage.StoredFiles[job.ID] = []byte("ID3 audio")
+
+	get := func(path string) (int, map[string]json.RawMessage) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var body map[string]json.RawMessage
+		json.NewDecoder(w.Body).Decode(&body) //nolint:errcheck
+		return w.Code, body
+	}
+	keys := func(body map[string]json.RawMessage) []string {
+		var names []string
+		for name := range body {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return names
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"selected fields", "?fields=job_id,status,progress_percentage", []string{"job_id", "progress_percentage", "status"}},
+		{"fields with expansions", "?fields=job_id&include=manifest,artifacts", []string{"artifacts", "job_id", "manifest"}},
+		{"unset field left out", "?fields=job_id,error_message", []string{"job_id"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := get("/api/v1/jobs/" + job.ID + tt.query)
+			if code != http.StatusOK || !slices.Equal(keys(body), tt.want) {
+				t.Errorf("expected 200 with %v, got %d with %v", tt.want, code, keys(body))
+			}
+		})
+	}
+
+	// Without fields and include the status is returned as before, events included
+	_, body := get("/api/v1/jobs/" + job.ID)
+	if _, ok := body["events"]; !ok || body["manifest"] != nil {
+		t.Errorf("expected the default status with events only, got %v", keys(body))
+	}
+	_, body = get("/api/v1/jobs/" + job.ID + "?include=manifest")
+	if _, ok := body["events"]; ok || body["result_url"] == nil {
+		t.Errorf("expected every field and the manifest without events, got %v", keys(body))
+	}
+	var manifest JobManifest
+	json.Unmarshal(body["manifest"], &manifest) //nolint:errcheck
+	if manifest.VoiceID != "narrator" || manifest.TextLength != len("Hello there") {
+		t.Errorf("unexpected manifest %+v", manifest)
+	}
+
+	for _, query := range []string{"?fields=job_id,text", "?include=everything"} {
+		if code, _ := get("/api/v1/jobs/" + job.ID + query); code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected 422, got %d", query, code)
+		}
+	}
+
+	_, body = get("/api/v1/jobs?fields=job_id,status")
+	var jobs []map[string]any
+	json.Unmarshal(body["jobs"], &jobs) //nolint:errcheck
+	if len(jobs) != 1 || len(jobs[0]) != 2 || jobs[0]["job_id"] != job.ID {
+		t.Errorf("expected the listed job with two fields, got %v", jobs)
+	}
+}
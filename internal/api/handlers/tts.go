@@ -1,15 +1,26 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"strconv"
 	"time"
+	"unicode/utf8"
 
 	"go.uber.org/zap"
 
 	"github.com/pako-tts/server/internal/api/middleware"
+	"github.com/pako-tts/server/internal/audio/effects"
+	"github.com/pako-tts/server/internal/audio/transcode"
+	"github.com/pako-tts/server/internal/audio/validate"
 	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/metrics"
+	"github.com/pako-tts/server/internal/pipeline"
+	"github.com/pako-tts/server/internal/speechcache"
 )
 
 // TTSHandler handles synchronous TTS requests.
@@ -19,15 +30,51 @@ type TTSHandler struct {
 	syncTimeout    time.Duration
 	maxTextLen     int
 	defaultVoiceID string
+	// clampSettings pulls out-of-range voice settings into range instead of rejecting them.
+	clampSettings bool
+	textMetrics   *metrics.TextMetrics
+	speechCache   domain.SpeechCache
+	ttfb          *metrics.TTFB
+	resultCache   domain.SpeechCache
+	cacheMetrics  *metrics.ResultCacheMetrics
+	// jobs takes over requests that run out of syncTimeout as async jobs; nil
+	// fails them.
+	jobs *JobsHandler
 }
 
-// NewTTSHandler creates a new TTS handler.
+// CacheHeader reports whether a synchronous TTS response came from the speech or
+// result cache ("HIT") or the provider ("MISS"). It is only set while a cache is
+// enabled.
+const CacheHeader = "X-Cache"
+
+// AudioDurationHeader gives the duration of a synchronous TTS response's audio
+// in milliseconds, when it is known without decoding the audio.
+const AudioDurationHeader = "X-Audio-Duration-Ms"
+
+// CharactersBilledHeader gives the characters a synchronous TTS request sent to
+// its provider: the text after pipeline text stages, or 0 for a response from
+// a cache.
+const CharactersBilledHeader = "X-Characters-Billed"
+
+// NewTTSHandler creates a new TTS handler. A nil textMetrics, ttfb or
+// cacheMetrics records nothing. Warmed requests are answered from speechCache,
+// and repeated ones from resultCache, which keeps every response; with both nil
+// every request goes to the provider. With jobs set, POST /api/v1/tts applies
+// syncTimeout itself and answers a request that runs out of it with 202 and an
+// async job of jobs doing the same synthesis.
 func NewTTSHandler(
 	registry domain.ProviderRegistry,
 	logger *zap.Logger,
 	syncTimeout time.Duration,
 	maxTextLen int,
 	defaultVoiceID string,
+	clampSettings bool,
+	textMetrics *metrics.TextMetrics,
+	speechCache domain.SpeechCache,
+	ttfb *metrics.TTFB,
+	resultCache domain.SpeechCache,
+	cacheMetrics *metrics.ResultCacheMetrics,
+	jobs *JobsHandler,
 ) *TTSHandler {
 	return &TTSHandler{
 		registry:       registry,
@@ -35,28 +82,194 @@ func NewTTSHandler(
 		syncTimeout:    syncTimeout,
 		maxTextLen:     maxTextLen,
 		defaultVoiceID: defaultVoiceID,
+		clampSettings:  clampSettings,
+		textMetrics:    textMetrics,
+		speechCache:    speechCache,
+		ttfb:           ttfb,
+		resultCache:    resultCache,
+		cacheMetrics:   cacheMetrics,
+		jobs:           jobs,
 	}
 }
 
 // TTSRequest represents a synchronous TTS request.
 type TTSRequest struct {
-	Text          string                `json:"text"`
-	VoiceID       string                `json:"voice_id,omitempty"`
-	ModelID       string                `json:"model_id,omitempty"`
-	LanguageCode  string                `json:"language_code,omitempty"`
-	Provider      string                `json:"provider,omitempty"`
-	OutputFormat  string                `json:"output_format,omitempty"`
-	VoiceSettings *domain.VoiceSettings `json:"voice_settings,omitempty"`
+	Text          string                 `json:"text"`
+	VoiceID       string                 `json:"voice_id,omitempty"`
+	ModelID       string                 `json:"model_id,omitempty"`
+	LanguageCode  string                 `json:"language_code,omitempty"`
+	Provider      string                 `json:"provider,omitempty"`
+	OutputFormat  string                 `json:"output_format,omitempty"`
+	VoiceSettings *domain.VoiceSettings  `json:"voice_settings,omitempty"`
+	Padding       *domain.PaddingOptions `json:"padding,omitempty"`
+	// Pipeline lists the text and audio stages run around synthesis, in order.
+	Pipeline []domain.PipelineStage `json:"pipeline,omitempty"`
+	// Stream sends the audio as the provider produces it, as POST
+	// /api/v1/tts/stream does.
+	Stream bool `json:"stream,omitempty"`
+}
+
+// ttsCall is a validated synchronous TTS request, ready to be synthesized.
+type ttsCall struct {
+	// received is when the request arrived, the start of its time to first byte.
+	received     time.Time
+	provider     domain.TTSProvider
+	providerName string
+	// textLength is the length of the submitted text, before pipeline text stages.
+	textLength int
+	// format is the format of the response; the provider is asked for
+	// synthReq.OutputFormat, which is transcoded to it when they differ.
+	format   string
+	synthReq *domain.SynthesisRequest
+	adjust   effects.Options
+	stages   *pipeline.Pipeline
+	warnings []domain.Warning
+	// stream asks for the audio to be forwarded as it is produced.
+	stream bool
+	// cacheKey is the key the response is kept under in the result cache; empty
+	// when it isn't kept.
+	cacheKey string
+	// request and req are the client's request and its body, from which an
+	// async fallback job is created.
+	request *http.Request
+	req     *TTSRequest
 }
 
-// SynthesizeTTS handles POST /api/v1/tts.
+// SynthesizeTTS handles POST /api/v1/tts. With "stream": true in the request it
+// answers like StreamTTS.
 func (h *TTSHandler) SynthesizeTTS(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	if h.jobs != nil {
+		// The router leaves the sync timeout to us, so running out of it can be
+		// told apart from the request's own deadline
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, h.syncTimeout, errSyncTimeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+
+	call, ok := h.prepare(w, r)
+	if !ok {
+		return
+	}
+	if call.stream {
+		h.streamOrSynthesize(ctx, w, call)
+		return
+	}
+	h.synthesize(ctx, w, call)
+}
+
+// StreamTTS handles POST /api/v1/tts/stream. It takes the same request as
+// SynthesizeTTS, but writes audio as the provider produces it when the provider
+// can stream and the audio needs no post-processing. Otherwise the response is
+// the buffered one of SynthesizeTTS.
+func (h *TTSHandler) StreamTTS(w http.ResponseWriter, r *http.Request) {
+	call, ok := h.prepare(w, r)
+	if !ok {
+		return
+	}
+	h.streamOrSynthesize(r.Context(), w, call)
+}
+
+// streamOrSynthesize streams call when its provider can stream and its audio
+// needs no post-processing, and synthesizes it otherwise.
+func (h *TTSHandler) streamOrSynthesize(ctx context.Context, w http.ResponseWriter, call *ttsCall) {
+	streamer, ok := call.provider.(domain.StreamingProvider)
+	if !ok || call.synthReq.OutputFormat != "mp3" || !call.adjust.IsZero() || call.stages.HasAudio() {
+		h.synthesize(ctx, w, call)
+		return
+	}
+	h.stream(ctx, w, call, streamer)
+}
+
+// TTSEstimateResponse is what a synchronous request would get, for clients
+// choosing a provider, voice and endpoint before sending it.
+type TTSEstimateResponse struct {
+	Provider string `json:"provider"`
+	// Characters echoes the characters query parameter; 0 when it wasn't given.
+	Characters int `json:"characters,omitempty"`
+	// FitsSync reports whether text of that length is accepted by POST /api/v1/tts.
+	FitsSync bool `json:"fits_sync"`
+	// Streaming reports whether the provider can stream on POST /api/v1/tts/stream.
+	Streaming bool `json:"streaming"`
+	// TimeToFirstByte is the recent time to first byte per voice and endpoint,
+	// fastest first. Voices without recent requests are not listed.
+	TimeToFirstByte []TTFBEstimate `json:"time_to_first_byte"`
+}
+
+// TTFBEstimate is the recent time to first byte of one voice.
+type TTFBEstimate struct {
+	VoiceID string `json:"voice_id"`
+	// Source is "sync" for POST /api/v1/tts and "stream" for POST /api/v1/tts/stream.
+	Source  string `json:"source"`
+	P50Ms   int64  `json:"p50_ms"`
+	P95Ms   int64  `json:"p95_ms"`
+	Samples int    `json:"samples"`
+}
+
+// EstimateTTS handles GET /api/v1/tts/estimate. It names the provider a request
+// would be routed to and how fast its voices have recently started answering.
+func (h *TTSHandler) EstimateTTS(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	var characters int
+	if v := params.Get("characters"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			middleware.WriteError(w, domain.ErrValidation.WithDetails(map[string]any{
+				"field":   "characters",
+				"message": "characters must be a positive integer",
+			}))
+			return
+		}
+		characters = n
+	}
+
+	providerName := params.Get("provider")
+	if providerName == "" {
+		providerName = h.registry.Route(r.Context(), characters)
+	}
+	provider, err := h.registry.Get(providerName)
+	if err != nil {
+		middleware.WriteError(w, domain.ErrProviderNotFound.WithMessage("Provider '"+providerName+"' not found"))
+		return
+	}
+	_, streaming := provider.(domain.StreamingProvider)
+
+	voiceID := params.Get("voice_id")
+	estimates := make([]TTFBEstimate, 0)
+	for _, s := range h.ttfb.Stats(providerName) {
+		if voiceID != "" && s.VoiceID != voiceID {
+			continue
+		}
+		estimates = append(estimates, TTFBEstimate{
+			VoiceID: s.VoiceID,
+			Source:  s.Source,
+			P50Ms:   s.P50.Milliseconds(),
+			P95Ms:   s.P95.Milliseconds(),
+			Samples: s.Samples,
+		})
+	}
+
+	middleware.WriteJSON(w, http.StatusOK, TTSEstimateResponse{
+		Provider:        providerName,
+		Characters:      characters,
+		FitsSync:        characters <= h.maxTextLen,
+		Streaming:       streaming,
+		TimeToFirstByte: estimates,
+	})
+}
+
+// prepare validates the request and resolves its provider. It answers requests
+// it rejects and those served from a cache, returning false for them.
+func (h *TTSHandler) prepare(w http.ResponseWriter, r *http.Request) (*ttsCall, bool) {
+	ctx := r.Context()
+	received := time.Now()
 
 	var req TTSRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		middleware.WriteError(w, domain.ErrValidation.WithMessage("Invalid JSON body"))
-		return
+		return nil, false
 	}
 
 	// Validate text
@@ -65,7 +278,11 @@ func (h *TTSHandler) SynthesizeTTS(w http.ResponseWriter, r *http.Request) {
 			"field":   "text",
 			"message": "Text is required",
 		}))
-		return
+		return nil, false
+	}
+	if apiErr := checkTextRules(r, "text", req.Text); apiErr != nil {
+		middleware.WriteError(w, apiErr)
+		return nil, false
 	}
 
 	if len(req.Text) > h.maxTextLen {
@@ -73,7 +290,7 @@ func (h *TTSHandler) SynthesizeTTS(w http.ResponseWriter, r *http.Request) {
 			"max_length":    h.maxTextLen,
 			"actual_length": len(req.Text),
 		}))
-		return
+		return nil, false
 	}
 
 	// Set defaults
@@ -82,59 +299,421 @@ func (h *TTSHandler) SynthesizeTTS(w http.ResponseWriter, r *http.Request) {
 		voiceID = h.defaultVoiceID
 	}
 
+	// An output_format in the body wins over the Accept header
 	outputFormat := req.OutputFormat
 	if outputFormat == "" {
-		outputFormat = "mp3"
+		var apiErr *domain.APIError
+		w.Header().Add("Vary", "Accept")
+		if outputFormat, apiErr = negotiateFormat(r, defaultOutputFormat(r), transcode.OutputFormats...); apiErr != nil {
+			middleware.WriteError(w, apiErr)
+			return nil, false
+		}
 	}
 
 	// Validate output format
-	if outputFormat != "mp3" && outputFormat != "wav" {
+	if !transcode.IsOutputFormat(outputFormat) {
 		middleware.WriteError(w, domain.ErrInvalidFormat)
-		return
+		return nil, false
 	}
 
-	// Get provider (use specified or default)
-	var provider domain.TTSProvider
-	if req.Provider != "" {
-		var err error
-		provider, err = h.registry.Get(req.Provider)
-		if err != nil {
-			middleware.WriteError(w, domain.ErrProviderNotFound.WithMessage("Provider '"+req.Provider+"' not found"))
-			return
+	if apiErr := validatePadding(req.Padding); apiErr != nil {
+		middleware.WriteError(w, apiErr)
+		return nil, false
+	}
+	stages, apiErr := validatePipeline(req.Pipeline)
+	if apiErr != nil {
+		middleware.WriteError(w, apiErr)
+		return nil, false
+	}
+
+	// Get provider (use specified or let the registry route)
+	providerName := req.Provider
+	if providerName == "" {
+		providerName = h.registry.Route(ctx, len(req.Text))
+	}
+	provider, err := h.registry.Get(providerName)
+	if err != nil {
+		middleware.WriteError(w, domain.ErrProviderNotFound.WithMessage("Provider '"+providerName+"' not found"))
+		return nil, false
+	}
+
+	voiceSettings, clamped, apiErr := checkVoiceSettings(provider, req.VoiceSettings, h.clampSettings)
+	if apiErr != nil {
+		middleware.WriteError(w, apiErr)
+		return nil, false
+	}
+	if len(clamped) > 0 {
+		h.logger.Info("Voice settings clamped", zap.String("provider", providerName), zap.Strings("fields", clamped))
+	}
+	warnings := requestWarnings(req.Text, req.LanguageCode, clamped)
+	h.textMetrics.Observe(metrics.SourceSync, req.Text, req.LanguageCode)
+
+	// Warmed and repeated requests are answered from the caches, even while the
+	// provider is down
+	var key string
+	if h.speechCache != nil || h.resultCache != nil {
+		key = speechcache.Request{
+			Tenant:       middleware.TenantFromRequest(r),
+			Provider:     providerName,
+			Text:         req.Text,
+			VoiceID:      voiceID,
+			ModelID:      req.ModelID,
+			LanguageCode: req.LanguageCode,
+			Format:       outputFormat,
+			Settings:     voiceSettings,
+			Padding:      req.Padding,
+			Pipeline:     req.Pipeline,
+		}.Key()
+		audio, ok := h.cached(ctx, key, len(req.Text))
+		if ok {
+			w.Header().Set("Content-Type", transcode.ContentType(outputFormat))
+			w.Header().Set(CacheHeader, "HIT")
+			w.Header().Set(CharactersBilledHeader, "0")
+			setAudioHeaders(w, bytes.NewBuffer(audio), outputFormat, 0)
+			setWarningsHeader(w, warnings)
+			w.WriteHeader(http.StatusOK)
+			w.Write(audio) //nolint:errcheck
+			return nil, false
 		}
-	} else {
-		provider = h.registry.Default()
+		w.Header().Set(CacheHeader, "MISS")
 	}
 
 	// Check provider availability
 	if !provider.IsAvailable(ctx) {
 		middleware.WriteError(w, domain.ErrProviderUnavailable)
-		return
+		return nil, false
 	}
 
-	// Build synthesis request
-	synthReq := &domain.SynthesisRequest{
-		Text:         req.Text,
-		VoiceID:      voiceID,
-		ModelID:      req.ModelID,
-		LanguageCode: req.LanguageCode,
-		OutputFormat: outputFormat,
-		Settings:     req.VoiceSettings,
+	// Speed/pitch the provider can't render natively, and padding, are applied after synthesis
+	adjust, settings := effects.Plan(provider, voiceSettings)
+	adjust = adjust.WithPadding(req.Padding)
+
+	call := &ttsCall{
+		received:     received,
+		provider:     provider,
+		providerName: providerName,
+		textLength:   len(req.Text),
+		format:       outputFormat,
+		synthReq: &domain.SynthesisRequest{
+			VoiceID:      voiceID,
+			ModelID:      req.ModelID,
+			LanguageCode: req.LanguageCode,
+			OutputFormat: transcode.SynthesisFormat(outputFormat),
+			Settings:     settings,
+		},
+		adjust:   adjust,
+		stages:   stages,
+		warnings: warnings,
+		stream:   req.Stream,
+		cacheKey: key,
+		request:  r,
+		req:      &req,
 	}
 
-	// Synthesize
-	result, err := provider.Synthesize(ctx, synthReq)
+	// Text stages of the pipeline rewrite what is synthesized
+	text, err := stages.Text(ctx, req.Text)
 	if err != nil {
+		if h.fallBack(ctx, w, call) {
+			return nil, false
+		}
+		h.logger.Error("Pipeline text stage failed", zap.Error(err))
+		middleware.WriteError(w, domain.ErrInternalServer)
+		return nil, false
+	}
+	call.synthReq.Text = text
+	return call, true
+}
+
+// errSyncTimeout ends the context of a POST /api/v1/tts request that ran out of
+// the sync timeout while async fallback is on.
+var errSyncTimeout = errors.New("sync timeout")
+
+// fallBack answers call with 202 and an async job synthesizing the same request
+// when ctx ran out of the sync timeout, rather than the request's own deadline,
+// and async fallback is on. It reports whether it answered; when the job can't
+// be created the caller answers with its error as usual.
+func (h *TTSHandler) fallBack(ctx context.Context, w http.ResponseWriter, call *ttsCall) bool {
+	if h.jobs == nil || !errors.Is(context.Cause(ctx), errSyncTimeout) {
+		return false
+	}
+
+	job, warnings, apiErr := h.jobs.newJob(call.request, &JobCreateRequest{
+		Text:          call.req.Text,
+		VoiceID:       call.synthReq.VoiceID,
+		ModelID:       call.req.ModelID,
+		LanguageCode:  call.req.LanguageCode,
+		Provider:      call.providerName,
+		OutputFormat:  call.format,
+		VoiceSettings: call.req.VoiceSettings,
+		Padding:       call.req.Padding,
+		Pipeline:      call.req.Pipeline,
+	})
+	if apiErr != nil {
+		h.logger.Warn("Async fallback job rejected", zap.String("code", apiErr.Code))
+		return false
+	}
+	job.AddEvent(domain.JobEventSyncFallback, "sync request ran out of its "+h.syncTimeout.String()+" timeout")
+	if err := h.jobs.queue.Enqueue(context.WithoutCancel(ctx), job); err != nil {
+		h.logger.Error("Failed to enqueue async fallback job", zap.Error(err))
+		return false
+	}
+	h.logger.Info("Sync request converted to job",
+		zap.String("job_id", job.ID),
+		zap.String("provider", call.providerName),
+		zap.Int("text_length", call.textLength),
+	)
+
+	w.Header().Set("Location", "/api/v1/jobs/"+job.ID)
+	middleware.WriteJSON(w, http.StatusAccepted, JobCreateResponse{
+		JobID:     job.ID,
+		Status:    string(job.Status),
+		CreatedAt: job.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		Warnings:  warnings,
+	})
+	return true
+}
+
+// cached returns the audio the speech cache, or else the result cache, holds for
+// key. Result cache lookups are counted for a text of chars characters.
+func (h *TTSHandler) cached(ctx context.Context, key string, chars int) ([]byte, bool) {
+	if h.speechCache != nil {
+		if audio, ok := h.speechCache.Get(ctx, key); ok {
+			return audio, true
+		}
+	}
+	if h.resultCache == nil {
+		return nil, false
+	}
+	audio, ok := h.resultCache.Get(ctx, key)
+	h.cacheMetrics.Observe(metrics.SourceSync, ok, chars)
+	return audio, ok
+}
+
+// keep stores the audio of call in the result cache. A failure is logged; the
+// response is served either way.
+func (h *TTSHandler) keep(ctx context.Context, call *ttsCall, audio []byte) {
+	if h.resultCache == nil || call.cacheKey == "" {
+		return
+	}
+	if err := h.resultCache.Put(ctx, call.cacheKey, audio); err != nil {
+		h.logger.Warn("Failed to store result cache entry", zap.Error(err))
+	}
+}
+
+// synthesize answers call with the provider's complete, post-processed audio.
+func (h *TTSHandler) synthesize(ctx context.Context, w http.ResponseWriter, call *ttsCall) {
+	start := time.Now()
+	result, err := call.provider.Synthesize(ctx, call.synthReq)
+	h.registry.Observe(call.providerName, call.textLength, time.Since(start), err)
+	if err != nil {
+		if h.fallBack(ctx, w, call) {
+			return
+		}
 		h.logger.Error("Synthesis failed", zap.Error(err))
 		middleware.WriteError(w, domain.ErrProviderUnavailable.WithMessage(err.Error()))
 		return
 	}
+	// Pooled audio goes back to the pool even when it isn't written out
+	if c, ok := result.Audio.(io.Closer); ok {
+		defer c.Close() //nolint:errcheck
+	}
+
+	// The provider's duration holds until the audio is changed
+	audio, contentType, duration := result.Audio, result.ContentType, result.Duration
+	if !call.adjust.IsZero() || call.stages.HasAudio() {
+		processed, err := h.postProcess(ctx, result.Audio, call.synthReq.OutputFormat, call.adjust, call.stages)
+		if err != nil {
+			if h.fallBack(ctx, w, call) {
+				return
+			}
+			h.logger.Error("Audio post-processing failed", zap.Error(err))
+			middleware.WriteError(w, domain.ErrInternalServer)
+			return
+		}
+		audio, duration = processed, 0
+	}
+	if call.format != call.synthReq.OutputFormat {
+		encoded, err := h.encode(ctx, audio, call.format)
+		if err != nil {
+			if h.fallBack(ctx, w, call) {
+				return
+			}
+			h.logger.Error("Audio transcoding failed", zap.String("format", call.format), zap.Error(err))
+			middleware.WriteError(w, domain.ErrInternalServer)
+			return
+		}
+		audio, contentType, duration = encoded, transcode.ContentType(call.format), 0
+	}
 
 	// Stream audio response
-	w.Header().Set("Content-Type", result.ContentType)
+	w.Header().Set("Content-Type", contentType)
+	setBilledHeader(w, call)
+	setAudioHeaders(w, audio, call.format, duration)
+	setWarningsHeader(w, call.warnings)
 	w.WriteHeader(http.StatusOK)
 
-	if _, err := io.Copy(w, result.Audio); err != nil {
+	out := &firstWriteHook{w: w, fn: func() {
+		h.ttfb.Observe(metrics.SourceSync, call.providerName, call.synthReq.VoiceID, time.Since(call.received))
+	}}
+	var kept bytes.Buffer
+	if h.resultCache != nil {
+		audio = io.TeeReader(audio, &kept)
+	}
+	if _, err := io.Copy(out, audio); err != nil {
 		h.logger.Error("Failed to write audio response", zap.Error(err))
+		return
+	}
+	h.keep(context.WithoutCancel(ctx), call, kept.Bytes())
+}
+
+// stream answers call with audio forwarded as the provider produces it. Errors
+// before the first audio byte are answered as usual; a later error aborts the
+// response, so the client can't mistake truncated audio for complete audio.
+func (h *TTSHandler) stream(ctx context.Context, w http.ResponseWriter, call *ttsCall, streamer domain.StreamingProvider) {
+	start := time.Now()
+	stream, err := streamer.SynthesizeStream(ctx, call.synthReq)
+	var first []byte
+	if err == nil {
+		defer stream.Audio.Close() //nolint:errcheck
+		first, err = readFirst(stream.Audio)
+	}
+	if err != nil {
+		h.registry.Observe(call.providerName, call.textLength, time.Since(start), err)
+		h.logger.Error("Synthesis failed", zap.Error(err))
+		middleware.WriteError(w, domain.ErrProviderUnavailable.WithMessage(err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", stream.ContentType)
+	setBilledHeader(w, call)
+	setWarningsHeader(w, call.warnings)
+	w.WriteHeader(http.StatusOK)
+
+	out := flushWriter{w: w, rc: http.NewResponseController(w)}
+	var audio io.Reader = stream.Audio
+	var kept bytes.Buffer
+	if h.resultCache != nil {
+		kept.Write(first)
+		audio = io.TeeReader(audio, &kept)
+	}
+	_, err = out.Write(first)
+	if err == nil {
+		h.ttfb.Observe(metrics.SourceStream, call.providerName, call.synthReq.VoiceID, time.Since(call.received))
+		_, err = io.Copy(out, audio)
+	}
+	h.registry.Observe(call.providerName, call.textLength, time.Since(start), err)
+	if err != nil {
+		h.logger.Error("Audio stream interrupted", zap.Error(err))
+		panic(http.ErrAbortHandler)
+	}
+	h.keep(context.WithoutCancel(ctx), call, kept.Bytes())
+}
+
+// setBilledHeader sets CharactersBilledHeader to the length of the text call
+// sends to its provider.
+func setBilledHeader(w http.ResponseWriter, call *ttsCall) {
+	w.Header().Set(CharactersBilledHeader, strconv.Itoa(utf8.RuneCountInString(call.synthReq.Text)))
+}
+
+// setAudioHeaders sets Content-Length and AudioDurationHeader for audio of
+// format, as far as they are known before it is written: the length of audio
+// held in memory, and duration, or else the duration read from the headers or
+// frames of buffered MP3 and WAV.
+func setAudioHeaders(w http.ResponseWriter, audio io.Reader, format string, duration time.Duration) {
+	switch a := audio.(type) {
+	case interface{ Bytes() []byte }:
+		data := a.Bytes()
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if duration <= 0 {
+			duration, _ = validate.Check(data, format)
+		}
+	case interface{ Len() int }:
+		w.Header().Set("Content-Length", strconv.Itoa(a.Len()))
+	}
+	if duration > 0 {
+		w.Header().Set(AudioDurationHeader, strconv.FormatInt(duration.Milliseconds(), 10))
+	}
+}
+
+// readFirst waits for the first bytes of a stream. A stream that ends without
+// audio is an error.
+func readFirst(r io.Reader) ([]byte, error) {
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			return buf[:n], nil
+		}
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// flushWriter flushes each write to the client.
+type flushWriter struct {
+	w  io.Writer
+	rc *http.ResponseController
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if err == nil {
+		err = f.rc.Flush()
+	}
+	return n, err
+}
+
+// firstWriteHook calls fn once the first bytes are written.
+type firstWriteHook struct {
+	w    io.Writer
+	fn   func()
+	done bool
+}
+
+func (f *firstWriteHook) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if !f.done && n > 0 {
+		f.done = true
+		f.fn()
+	}
+	return n, err
+}
+
+// encode transcodes audio to format.
+func (h *TTSHandler) encode(ctx context.Context, audio io.Reader, format string) (io.Reader, error) {
+	data, err := io.ReadAll(audio)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := transcode.Convert(ctx, data, format)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewBuffer(encoded), nil
+}
+
+// postProcess applies server-side speed/pitch adjustments and padding, then the
+// pipeline's audio stages. Audio they can't decode (headerless PCM) is returned
+// unchanged.
+func (h *TTSHandler) postProcess(ctx context.Context, audio io.Reader, format string, adjust effects.Options, stages *pipeline.Pipeline) (io.Reader, error) {
+	data, err := io.ReadAll(audio)
+	if err != nil {
+		return nil, err
+	}
+	processed, err := effects.Apply(ctx, data, format, adjust)
+	if err == nil {
+		processed, err = stages.Audio(ctx, processed, format)
+	}
+	if errors.Is(err, effects.ErrUnsupportedInput) {
+		h.logger.Warn("Skipping audio post-processing for unsupported input", zap.String("format", format))
+		return bytes.NewBuffer(data), nil
+	}
+	if err != nil {
+		return nil, err
 	}
+	return bytes.NewBuffer(processed), nil
 }
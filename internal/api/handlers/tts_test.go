@@ -4,13 +4,20 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/pako-tts/server/internal/api/handlers/mocks"
+	"github.com/pako-tts/server/internal/audio/transcode"
 	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/metrics"
+	"github.com/pako-tts/server/internal/queue/memory"
 )
 
 func TestSynthesizeTTS_PassesModelID(t *testing.T) {
@@ -60,7 +67,7 @@ func TestSynthesizeTTS_PassesModelID(t *testing.T) {
 			}
 			registry := mocks.NewMockProviderRegistry(mockProvider)
 
-			handler := NewTTSHandler(registry, logger, 30*time.Second, 5000, "default-voice")
+			handler := NewTTSHandler(registry, logger, 30*time.Second, 5000, "default-voice", false, nil, nil, nil, nil, nil, nil)
 
 			body, _ := json.Marshal(tt.body)
 			req := httptest.NewRequest(http.MethodPost, "/api/v1/tts", bytes.NewReader(body))
@@ -144,7 +151,7 @@ func TestSynthesizeTTS_PassesStyleInstructions(t *testing.T) {
 			}
 			registry := mocks.NewMockProviderRegistry(mockProvider)
 
-			handler := NewTTSHandler(registry, logger, 30*time.Second, 5000, "default-voice")
+			handler := NewTTSHandler(registry, logger, 30*time.Second, 5000, "default-voice", false, nil, nil, nil, nil, nil, nil)
 
 			body, _ := json.Marshal(tt.body)
 			req := httptest.NewRequest(http.MethodPost, "/api/v1/tts", bytes.NewReader(body))
@@ -220,7 +227,7 @@ func TestSynthesizeTTS_PassesLanguageCode(t *testing.T) {
 			}
 			registry := mocks.NewMockProviderRegistry(mockProvider)
 
-			handler := NewTTSHandler(registry, logger, 30*time.Second, 5000, "default-voice")
+			handler := NewTTSHandler(registry, logger, 30*time.Second, 5000, "default-voice", false, nil, nil, nil, nil, nil, nil)
 
 			body, _ := json.Marshal(tt.body)
 			req := httptest.NewRequest(http.MethodPost, "/api/v1/tts", bytes.NewReader(body))
@@ -244,3 +251,400 @@ func TestSynthesizeTTS_PassesLanguageCode(t *testing.T) {
 		})
 	}
 }
+
+func TestSynthesizeTTS_RejectsOutOfRangeAdjustments(t *testing.T) {
+	tests := []struct {
+		name      string
+		settings  map[string]any
+		wantField string
+	}{
+		{"speed too high", map[string]any{"speed": 3.0}, "voice_settings.speed"},
+		{"speed too low", map[string]any{"speed": 0.2}, "voice_settings.speed"},
+		{"pitch too high", map[string]any{"pitch": 13}, "voice_settings.pitch"},
+		{"stability above one", map[string]any{"stability": 1.5}, "voice_settings.stability"},
+		{"negative style", map[string]any{"style": -0.1}, "voice_settings.style"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockProvider := &mocks.MockProvider{NameValue: "test-provider", AvailableValue: true}
+			registry := mocks.NewMockProviderRegistry(mockProvider)
+			handler := NewTTSHandler(registry, testLogger(), 30*time.Second, 5000, "default-voice", false, nil, nil, nil, nil, nil, nil)
+
+			body, _ := json.Marshal(map[string]any{"text": "hello", "voice_settings": tt.settings})
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/tts", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			handler.SynthesizeTTS(w, req)
+
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("expected status 422, got %d", w.Code)
+			}
+			var resp domain.ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode error body: %v", err)
+			}
+			if resp.Error == nil || resp.Error.Details["field"] != tt.wantField {
+				t.Errorf("expected field %q, got %+v", tt.wantField, resp.Error)
+			}
+		})
+	}
+}
+
+func TestSynthesizeTTS_ReportsEveryOutOfRangeSetting(t *testing.T) {
+	mockProvider := &mocks.MockProvider{NameValue: "test-provider", AvailableValue: true}
+	handler := NewTTSHandler(mocks.NewMockProviderRegistry(mockProvider), testLogger(), 30*time.Second, 5000, "default-voice", false, nil, nil, nil, nil, nil, nil)
+
+	body, _ := json.Marshal(map[string]any{
+		"text":           "hello",
+		"voice_settings": map[string]any{"stability": 2, "similarity_boost": 0.5, "speed": 9},
+	})
+	w := httptest.NewRecorder()
+	handler.SynthesizeTTS(w, httptest.NewRequest(http.MethodPost, "/api/v1/tts", bytes.NewReader(body)))
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422, got %d", w.Code)
+	}
+	var resp domain.ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode error body: %v", err)
+	}
+	errs, _ := resp.Error.Details["errors"].([]any)
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 field errors, got %v", resp.Error.Details)
+	}
+	second, _ := errs[1].(map[string]any)
+	if second["field"] != "voice_settings.speed" || second["max"] != 2.0 {
+		t.Errorf("unexpected speed error %v", second)
+	}
+}
+
+func TestSynthesizeTTS_ClampsOutOfRangeSettings(t *testing.T) {
+	var captured *domain.SynthesisRequest
+	mockProvider := &mocks.MockProvider{
+		NameValue:      "test-provider",
+		AvailableValue: true,
+		SynthesizeFunc: func(ctx context.Context, req *domain.SynthesisRequest) (*domain.SynthesisResult, error) {
+			captured = req
+			return &domain.SynthesisResult{Audio: bytes.NewReader([]byte("audio")), ContentType: "audio/mpeg"}, nil
+		},
+	}
+	handler := NewTTSHandler(mocks.NewMockProviderRegistry(mockProvider), testLogger(), 30*time.Second, 5000, "default-voice", true, nil, nil, nil, nil, nil, nil)
+
+	body, _ := json.Marshal(map[string]any{
+		"text":           "hello",
+		"voice_settings": map[string]any{"stability": 1.7, "style": -3, "native_only": true},
+	})
+	w := httptest.NewRecorder()
+	handler.SynthesizeTTS(w, httptest.NewRequest(http.MethodPost, "/api/v1/tts", bytes.NewReader(body)))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if captured == nil || captured.Settings == nil {
+		t.Fatal("expected settings to reach the provider")
+	}
+	if *captured.Settings.Stability != 1 || *captured.Settings.Style != 0 {
+		t.Errorf("expected clamped stability 1 and style 0, got %v and %v", *captured.Settings.Stability, *captured.Settings.Style)
+	}
+}
+
+func TestSynthesizeTTS_WarningsHeader(t *testing.T) {
+	mockProvider := &mocks.MockProvider{NameValue: "test-provider", AvailableValue: true}
+	handler := NewTTSHandler(mocks.NewMockProviderRegistry(mockProvider), testLogger(), 30*time.Second, 5000, "default-voice", false, nil, nil, nil, nil, nil, nil)
+
+	body, _ := json.Marshal(map[string]any{"text": "<p>Привет, мир</p>", "language_code": "en"})
+	w := httptest.NewRecorder()
+	handler.SynthesizeTTS(w, httptest.NewRequest(http.MethodPost, "/api/v1/tts", bytes.NewReader(body)))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var warnings []domain.Warning
+	if err := json.Unmarshal([]byte(w.Header().Get(WarningsHeader)), &warnings); err != nil {
+		t.Fatalf("decode warnings header: %v", err)
+	}
+	if len(warnings) != 2 || warnings[0].Code != domain.WarningTextLooksLikeHTML || warnings[1].Code != domain.WarningLanguageMismatch {
+		t.Errorf("unexpected warnings %+v", warnings)
+	}
+}
+
+func TestSynthesizeTTS_RecordsTextMetrics(t *testing.T) {
+	mockProvider := &mocks.MockProvider{NameValue: "test-provider", AvailableValue: true}
+	reg := metrics.NewRegistry()
+	handler := NewTTSHandler(mocks.NewMockProviderRegistry(mockProvider), testLogger(), 30*time.Second, 5000, "default-voice", false,
+		metrics.NewTextMetrics(reg), nil, nil, nil, nil, nil)
+
+	body, _ := json.Marshal(map[string]any{"text": "Hello there. Bye.", "language_code": "en"})
+	w := httptest.NewRecorder()
+	handler.SynthesizeTTS(w, httptest.NewRequest(http.MethodPost, "/api/v1/tts", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var out strings.Builder
+	if err := reg.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`pako_tts_text_requests_total{source="sync",length="<100",script="latin",markup="none"} 1`,
+		`pako_tts_text_language_total{source="sync",language="en",script_match="true"} 1`,
+		`pako_tts_text_sentences_total{source="sync",sentences="2-5"} 1`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("metrics missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+// streamingProvider is a MockProvider that can also stream.
+type streamingProvider struct {
+	mocks.MockProvider
+	streamAudio string
+	streamError error
+}
+
+func (p *streamingProvider) SynthesizeStream(ctx context.Context, req *domain.SynthesisRequest) (*domain.SynthesisStream, error) {
+	if p.streamError != nil {
+		return nil, p.streamError
+	}
+	return &domain.SynthesisStream{Audio: io.NopCloser(strings.NewReader(p.streamAudio)), ContentType: "audio/mpeg"}, nil
+}
+
+func TestStreamTTS(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		provider domain.TTSProvider
+		want     int
+		wantBody string
+	}{
+		{"streams from a streaming provider", "mp3", &streamingProvider{MockProvider: mocks.MockProvider{NameValue: "test-provider", AvailableValue: true}, streamAudio: "streamed audio"}, http.StatusOK, "streamed audio"},
+		{"buffers wav", "wav", &streamingProvider{MockProvider: mocks.MockProvider{NameValue: "test-provider", AvailableValue: true}, streamAudio: "streamed audio"}, http.StatusOK, "mock audio data"},
+		{"buffers without streaming support", "mp3", &mocks.MockProvider{NameValue: "test-provider", AvailableValue: true}, http.StatusOK, "mock audio data"},
+		{"reports errors before audio", "mp3", &streamingProvider{MockProvider: mocks.MockProvider{NameValue: "test-provider", AvailableValue: true}, streamError: errors.New("upstream down")}, http.StatusServiceUnavailable, ""},
+		{"rejects a stream without audio", "mp3", &streamingProvider{MockProvider: mocks.MockProvider{NameValue: "test-provider", AvailableValue: true}}, http.StatusServiceUnavailable, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewTTSHandler(mocks.NewMockProviderRegistry(tt.provider), testLogger(), 30*time.Second, 5000, "default-voice", false, nil, nil, nil, nil, nil, nil)
+
+			body, _ := json.Marshal(map[string]any{"text": "Hello world", "output_format": tt.format})
+			w := httptest.NewRecorder()
+			handler.StreamTTS(w, httptest.NewRequest(http.MethodPost, "/api/v1/tts/stream", bytes.NewReader(body)))
+
+			if w.Code != tt.want {
+				t.Fatalf("expected status %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("expected body %q, got %q", tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSynthesizeTTS_StreamMode(t *testing.T) {
+	provider := &streamingProvider{MockProvider: mocks.MockProvider{NameValue: "test-provider", AvailableValue: true}, streamAudio: "streamed audio"}
+	handler := NewTTSHandler(mocks.NewMockProviderRegistry(provider), testLogger(), 30*time.Second, 5000, "default-voice", false, nil, nil, nil, nil, nil, nil)
+
+	for _, tt := range []struct {
+		stream   bool
+		wantBody string
+	}{
+		{true, "streamed audio"},
+		{false, "mock audio data"},
+	} {
+		body, _ := json.Marshal(map[string]any{"text": "Hello world", "stream": tt.stream})
+		w := httptest.NewRecorder()
+		handler.SynthesizeTTS(w, httptest.NewRequest(http.MethodPost, "/api/v1/tts", bytes.NewReader(body)))
+
+		if w.Code != http.StatusOK || w.Body.String() != tt.wantBody {
+			t.Errorf("stream=%v: expected 200 %q, got %d %q", tt.stream, tt.wantBody, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestSynthesizeTTS_Accept(t *testing.T) {
+	var format string
+	provider := &mocks.MockProvider{NameValue: "test-provider", AvailableValue: true,
+		SynthesizeFunc: func(ctx context.Context, req *domain.SynthesisRequest) (*domain.SynthesisResult, error) {
+			format = req.OutputFormat
+			return &domain.SynthesisResult{Audio: strings.NewReader("audio"), ContentType: "audio/wav"}, nil
+		}}
+	handler := NewTTSHandler(mocks.NewMockProviderRegistry(provider), testLogger(), 30*time.Second, 5000, "default-voice", false, nil, nil, nil, nil, nil, nil)
+
+	for _, tt := range []struct {
+		body, accept string
+		want         int
+		wantFormat   string
+	}{
+		{`{"text":"Hello"}`, "audio/wav", http.StatusOK, "wav"},
+		{`{"text":"Hello"}`, "audio/x-wav;q=0.9, audio/mpeg;q=0.1", http.StatusOK, "wav"},
+		{`{"text":"Hello"}`, "audio/*", http.StatusOK, "mp3"},
+		{`{"text":"Hello","output_format":"mp3"}`, "audio/wav", http.StatusOK, "mp3"},
+		{`{"text":"Hello"}`, "audio/aac", http.StatusNotAcceptable, ""},
+		{`{"text":"Hello"}`, "audio/mpeg;q=0, audio/wav;q=0", http.StatusNotAcceptable, ""},
+	} {
+		format = ""
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tts", strings.NewReader(tt.body))
+		req.Header.Set("Accept", tt.accept)
+		w := httptest.NewRecorder()
+		handler.SynthesizeTTS(w, req)
+
+		if w.Code != tt.want || format != tt.wantFormat {
+			t.Errorf("%s Accept %q: expected %d with format %q, got %d with %q", tt.body, tt.accept, tt.want, tt.wantFormat, w.Code, format)
+		}
+		if !strings.Contains(w.Header().Get("Vary"), "Accept") && !strings.Contains(tt.body, "output_format") {
+			t.Errorf("%s Accept %q: expected Vary: Accept", tt.body, tt.accept)
+		}
+	}
+}
+
+func TestTTS_RecordsTimeToFirstByte(t *testing.T) {
+	ttfb := metrics.NewTTFB(nil)
+	provider := &streamingProvider{MockProvider: mocks.MockProvider{NameValue: "test-provider", AvailableValue: true}, streamAudio: "streamed audio"}
+	handler := NewTTSHandler(mocks.NewMockProviderRegistry(provider), testLogger(), 30*time.Second, 5000, "default-voice", false, nil, nil, ttfb, nil, nil, nil)
+
+	body, _ := json.Marshal(map[string]any{"text": "Hello world", "voice_id": "voice-1"})
+	handler.SynthesizeTTS(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/tts", bytes.NewReader(body)))
+	handler.StreamTTS(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/tts/stream", bytes.NewReader(body)))
+
+	w := httptest.NewRecorder()
+	handler.EstimateTTS(w, httptest.NewRequest(http.MethodGet, "/api/v1/tts/estimate?characters=6000&voice_id=voice-1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp TTSEstimateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Provider != "test-provider" || resp.Characters != 6000 || resp.FitsSync || !resp.Streaming {
+		t.Errorf("unexpected estimate %+v", resp)
+	}
+	sources := map[string]int{}
+	for _, e := range resp.TimeToFirstByte {
+		if e.VoiceID != "voice-1" {
+			t.Errorf("unexpected voice %q", e.VoiceID)
+		}
+		sources[e.Source] += e.Samples
+	}
+	if sources[metrics.SourceSync] != 1 || sources[metrics.SourceStream] != 1 {
+		t.Errorf("expected one sync and one stream sample, got %v", sources)
+	}
+}
+
+func TestEstimateTTS_Validation(t *testing.T) {
+	handler := NewTTSHandler(mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"}), testLogger(), 30*time.Second, 5000, "default-voice", false, nil, nil, nil, nil, nil, nil)
+
+	for url, want := range map[string]int{
+		"/api/v1/tts/estimate":                  http.StatusOK,
+		"/api/v1/tts/estimate?characters=0":     http.StatusUnprocessableEntity,
+		"/api/v1/tts/estimate?provider=missing": http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		handler.EstimateTTS(w, httptest.NewRequest(http.MethodGet, url, nil))
+		if w.Code != want {
+			t.Errorf("%s: expected status %d, got %d", url, want, w.Code)
+		}
+	}
+}
+
+func TestSynthesizeTTS_AsyncFallback(t *testing.T) {
+	provider := &mocks.MockProvider{
+		NameValue:      "test-provider",
+		AvailableValue: true,
+		SynthesizeFunc: func(ctx context.Context, req *domain.SynthesisRequest) (*domain.SynthesisResult, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+	registry := mocks.NewMockProviderRegistry(provider)
+	queue := memory.NewQueue(10)
+	jobs := NewJobsHandler(registry, queue, mocks.NewMockStorage(), testLogger(), "default-voice", 24, false, 0, nil, nil, nil, nil)
+	body := `{"text": "A long chapter", "voice_id": "narrator", "output_format": "wav"}`
+
+	handler := NewTTSHandler(registry, testLogger(), 20*time.Millisecond, 5000, "default-voice", false, nil, nil, nil, nil, nil, jobs)
+	w := httptest.NewRecorder()
+	handler.SynthesizeTTS(w, httptest.NewRequest(http.MethodPost, "/api/v1/tts", strings.NewReader(body)))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var created JobCreateResponse
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if w.Header().Get("Location") != "/api/v1/jobs/"+created.JobID {
+		t.Errorf("expected the job's location, got %q", w.Header().Get("Location"))
+	}
+	job, err := queue.GetJob(context.Background(), created.JobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.Text != "A long chapter" || job.VoiceID != "narrator" || job.OutputFormat != "wav" || job.ProviderName != "test-provider" {
+		t.Errorf("expected the job to repeat the request, got %+v", job)
+	}
+	if job.Events[0].Type != domain.JobEventSyncFallback {
+		t.Errorf("expected a sync_fallback event, got %+v", job.Events)
+	}
+
+	// A request whose own deadline passed first fails as before
+	handler = NewTTSHandler(registry, testLogger(), time.Minute, 5000, "default-voice", false, nil, nil, nil, nil, nil, jobs)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tts", strings.NewReader(body))
+	ctx, cancel := context.WithTimeout(req.Context(), 20*time.Millisecond)
+	defer cancel()
+	w = httptest.NewRecorder()
+	handler.SynthesizeTTS(w, req.WithContext(ctx))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 past the request's deadline, got %d", w.Code)
+	}
+
+	// Without fallback the timeout fails the request
+	handler = NewTTSHandler(registry, testLogger(), 20*time.Millisecond, 5000, "default-voice", false, nil, nil, nil, nil, nil, nil)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/tts", strings.NewReader(body))
+	ctx, cancel = context.WithTimeout(req.Context(), 20*time.Millisecond)
+	defer cancel()
+	w = httptest.NewRecorder()
+	handler.SynthesizeTTS(w, req.WithContext(ctx))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 without fallback, got %d", w.Code)
+	}
+	if stats := queue.Stats(); stats.TotalJobs != 1 {
+		t.Errorf("expected only the first request queued, got %d jobs", stats.TotalJobs)
+	}
+}
+
+func TestSynthesizeTTS_AudioHeaders(t *testing.T) {
+	wav := transcode.PCMToWAV(make([]byte, 32000), 16000, 1, 16)
+	mockProvider := &mocks.MockProvider{NameValue: "test-provider", AvailableValue: true,
+		SynthesizeFunc: func(ctx context.Context, req *domain.SynthesisRequest) (*domain.SynthesisResult, error) {
+			return &domain.SynthesisResult{Audio: bytes.NewBuffer(wav), ContentType: "audio/wav", SizeBytes: int64(len(wav))}, nil
+		},
+	}
+	handler := NewTTSHandler(mocks.NewMockProviderRegistry(mockProvider), testLogger(), 30*time.Second, 5000, "default-voice", false, nil, nil, nil, nil, nil, nil)
+
+	body, _ := json.Marshal(map[string]any{"text": "Héllo", "output_format": "wav"})
+	w := httptest.NewRecorder()
+	handler.SynthesizeTTS(w, httptest.NewRequest(http.MethodPost, "/api/v1/tts", bytes.NewReader(body)))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	for header, want := range map[string]string{
+		"Content-Length":       strconv.Itoa(len(wav)),
+		AudioDurationHeader:    "1000",
+		CharactersBilledHeader: "5",
+	} {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("expected %s %q, got %q", header, want, got)
+		}
+	}
+
+	// Audio that can't be measured without decoding it has no duration
+	mockProvider.SynthesizeFunc = nil
+	w = httptest.NewRecorder()
+	handler.SynthesizeTTS(w, httptest.NewRequest(http.MethodPost, "/api/v1/tts", strings.NewReader(`{"text": "Hello"}`)))
+	if w.Header().Get("Content-Length") != "15" || w.Header().Get(AudioDurationHeader) != "" {
+		t.Errorf("expected the length only, got %v", w.Header())
+	}
+}
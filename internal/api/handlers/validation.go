@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/pako-tts/server/internal/api/middleware"
+	"github.com/pako-tts/server/internal/audio/effects"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/pipeline"
+)
+
+// defaultSettingRanges are the voice_settings ranges accepted for every provider:
+// the usual 0–1 provider knobs, and what the server-side audio pipeline can render.
+var defaultSettingRanges = map[string]domain.SettingRange{
+	"stability":        {Min: 0, Max: 1},
+	"similarity_boost": {Min: 0, Max: 1},
+	"style":            {Min: 0, Max: 1},
+	"speed":            {Min: effects.MinSpeed, Max: effects.MaxSpeed},
+	"pitch":            {Min: -effects.MaxPitchSemitones, Max: effects.MaxPitchSemitones},
+}
+
+// settingRanger is implemented by providers that accept some voice settings only
+// in narrower ranges than the defaults.
+type settingRanger interface {
+	VoiceSettingRanges() map[string]domain.SettingRange
+}
+
+// checkVoiceSettings validates numeric voice settings against the provider's ranges.
+// Out-of-range values are rejected with one 422 entry per field, or, with clamp,
+// pulled into range; the returned settings are then a copy and clamped lists the
+// adjusted fields.
+func checkVoiceSettings(provider domain.TTSProvider, s *domain.VoiceSettings, clamp bool) (*domain.VoiceSettings, []string, *domain.APIError) {
+	if s == nil {
+		return nil, nil, nil
+	}
+
+	ranges := defaultSettingRanges
+	if sr, ok := provider.(settingRanger); ok {
+		ranges = make(map[string]domain.SettingRange, len(defaultSettingRanges))
+		for name, r := range defaultSettingRanges {
+			ranges[name] = r
+		}
+		for name, r := range sr.VoiceSettingRanges() {
+			ranges[name] = r
+		}
+	}
+
+	checked := *s
+	fields := []struct {
+		name  string
+		value **float64
+	}{
+		{"stability", &checked.Stability},
+		{"similarity_boost", &checked.SimilarityBoost},
+		{"style", &checked.Style},
+		{"speed", &checked.Speed},
+		{"pitch", &checked.Pitch},
+	}
+
+	var violations []map[string]any
+	var clamped []string
+	for _, f := range fields {
+		r, ok := ranges[f.name]
+		if !ok || *f.value == nil {
+			continue
+		}
+		v := **f.value
+		if v >= r.Min && v <= r.Max {
+			continue
+		}
+		field := "voice_settings." + f.name
+		if clamp {
+			v = min(max(v, r.Min), r.Max)
+			*f.value = &v
+			clamped = append(clamped, field)
+			continue
+		}
+		violations = append(violations, map[string]any{
+			"field":   field,
+			"message": fmt.Sprintf("Must be between %s and %s for provider %s", formatFloat(r.Min), formatFloat(r.Max), provider.Name()),
+			"min":     r.Min,
+			"max":     r.Max,
+		})
+	}
+
+	if len(violations) > 0 {
+		return nil, nil, domain.ErrValidation.WithDetails(map[string]any{
+			"field":   violations[0]["field"],
+			"message": violations[0]["message"],
+			"errors":  violations,
+		})
+	}
+	if len(clamped) > 0 {
+		return &checked, clamped, nil
+	}
+	return s, nil, nil
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// validatePadding checks lead-in/lead-out silence and fade durations.
+func validatePadding(p *domain.PaddingOptions) *domain.APIError {
+	if p == nil {
+		return nil
+	}
+	checks := []struct {
+		field string
+		value int
+		max   int
+	}{
+		{"padding.lead_in_ms", p.LeadInMs, effects.MaxPaddingMs},
+		{"padding.lead_out_ms", p.LeadOutMs, effects.MaxPaddingMs},
+		{"padding.fade_in_ms", p.FadeInMs, effects.MaxFadeMs},
+		{"padding.fade_out_ms", p.FadeOutMs, effects.MaxFadeMs},
+	}
+	for _, c := range checks {
+		if c.value < 0 || c.value > c.max {
+			return domain.ErrValidation.WithDetails(map[string]any{
+				"field":   c.field,
+				"message": fmt.Sprintf("Must be between 0 and %d", c.max),
+			})
+		}
+	}
+	return nil
+}
+
+// validatePipeline compiles a request's pipeline; a nil pipeline is valid.
+func validatePipeline(stages []domain.PipelineStage) (*pipeline.Pipeline, *domain.APIError) {
+	p, err := pipeline.Compile(stages)
+	if err != nil {
+		details := map[string]any{"field": "pipeline", "message": err.Error()}
+		if perr, ok := err.(*pipeline.Error); ok {
+			details["message"] = perr.Message
+			if perr.Index >= 0 {
+				details["stage_index"] = perr.Index
+				details["stage"] = perr.Stage
+			}
+		}
+		return nil, domain.ErrInvalidPipeline.WithDetails(details)
+	}
+	return p, nil
+}
+
+// checkTextRules applies the text rules of the API key that authenticated the
+// request to text, which the request sent in field.
+func checkTextRules(r *http.Request, field, text string) *domain.APIError {
+	key := middleware.APIKeyFromContext(r.Context())
+	if key == nil {
+		return nil
+	}
+	v := key.TextRules.Check(text)
+	if v == nil {
+		return nil
+	}
+	return domain.ErrValidation.WithDetails(map[string]any{
+		"field":     field,
+		"message":   v.Message(),
+		"rule":      v.Rule,
+		"character": string(v.Char),
+		"offset":    v.Offset,
+	})
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/textinfo"
+)
+
+// WarningsHeader carries the JSON-encoded warnings of a synchronous TTS response,
+// whose body is the audio.
+const WarningsHeader = "X-Warnings"
+
+// requestWarnings lists non-fatal issues with a synthesis request: text that looks
+// like HTML, text whose script doesn't match language_code, and clamped settings.
+func requestWarnings(text, languageCode string, clamped []string) []domain.Warning {
+	var warnings []domain.Warning
+
+	info := textinfo.Analyze(text)
+	if info.HTML {
+		warnings = append(warnings, domain.Warning{
+			Code:    domain.WarningTextLooksLikeHTML,
+			Message: "Text appears to contain HTML markup, which will be read out or dropped; send plain text",
+			Field:   "text",
+		})
+	}
+	if languageCode != "" && !textinfo.ScriptMatchesLanguage(info.Script, languageCode) {
+		warnings = append(warnings, domain.Warning{
+			Code:    domain.WarningLanguageMismatch,
+			Message: "Text appears to be written in " + info.Script + " script, which doesn't match language_code '" + languageCode + "'",
+			Field:   "language_code",
+		})
+	}
+	for _, field := range clamped {
+		warnings = append(warnings, domain.Warning{
+			Code:    domain.WarningSettingsClamped,
+			Message: "Value was outside the provider's accepted range and was clamped",
+			Field:   field,
+		})
+	}
+	return warnings
+}
+
+// setWarningsHeader adds warnings to a response whose body isn't JSON.
+func setWarningsHeader(w http.ResponseWriter, warnings []domain.Warning) {
+	if len(warnings) == 0 {
+		return
+	}
+	if b, err := json.Marshal(warnings); err == nil {
+		w.Header().Set(WarningsHeader, string(b))
+	}
+}
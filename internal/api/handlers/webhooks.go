@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/api/middleware"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/webhook"
+)
+
+const (
+	// maxWebhookDescriptionLen caps a webhook's description.
+	maxWebhookDescriptionLen = 256
+
+	// defaultDeliveryListLimit and maxDeliveryListLimit bound a delivery log page.
+	defaultDeliveryListLimit = 20
+	maxDeliveryListLimit     = 100
+)
+
+// WebhooksHandler manages the webhooks of the requesting tenant.
+type WebhooksHandler struct {
+	store      domain.WebhookStore
+	dispatcher *webhook.Dispatcher
+	logger     *zap.Logger
+}
+
+// NewWebhooksHandler creates a new webhooks handler.
+func NewWebhooksHandler(store domain.WebhookStore, dispatcher *webhook.Dispatcher, logger *zap.Logger) *WebhooksHandler {
+	return &WebhooksHandler{
+		store:      store,
+		dispatcher: dispatcher,
+		logger:     logger,
+	}
+}
+
+// WebhookCreateRequest registers a webhook.
+type WebhookCreateRequest struct {
+	URL         string   `json:"url"`
+	Events      []string `json:"events"`
+	Description string   `json:"description,omitempty"`
+	// Active defaults to true.
+	Active *bool `json:"active,omitempty"`
+}
+
+// WebhookUpdateRequest changes the fields of a webhook that are set.
+type WebhookUpdateRequest struct {
+	URL         *string   `json:"url,omitempty"`
+	Events      *[]string `json:"events,omitempty"`
+	Description *string   `json:"description,omitempty"`
+	Active      *bool     `json:"active,omitempty"`
+}
+
+// WebhookListResponse lists the tenant's webhooks.
+type WebhookListResponse struct {
+	Webhooks []*domain.Webhook `json:"webhooks"`
+}
+
+// WebhookDeliveryListResponse lists a webhook's latest deliveries, newest first.
+type WebhookDeliveryListResponse struct {
+	Deliveries []*domain.WebhookDelivery `json:"deliveries"`
+}
+
+// CreateWebhook handles POST /api/v1/webhooks.
+func (h *WebhooksHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req WebhookCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		middleware.WriteError(w, domain.ErrValidation.WithMessage("Invalid JSON body"))
+		return
+	}
+
+	now := time.Now().UTC()
+	hook := &domain.Webhook{
+		ID:          uuid.New().String(),
+		Tenant:      middleware.TenantFromRequest(r),
+		URL:         req.URL,
+		Events:      req.Events,
+		Description: req.Description,
+		Active:      req.Active == nil || *req.Active,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if apiErr := h.validate(hook); apiErr != nil {
+		middleware.WriteError(w, apiErr)
+		return
+	}
+
+	if err := h.store.CreateWebhook(r.Context(), hook); err != nil {
+		h.logger.Error("Failed to create webhook", zap.Error(err))
+		middleware.WriteError(w, domain.ErrInternalServer)
+		return
+	}
+	h.logger.Info("Webhook registered",
+		zap.String("webhook_id", hook.ID),
+		zap.String("tenant", hook.Tenant),
+		zap.Strings("events", hook.Events),
+	)
+	middleware.WriteJSON(w, http.StatusCreated, hook)
+}
+
+// ListWebhooks handles GET /api/v1/webhooks.
+func (h *WebhooksHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := h.store.ListWebhooks(r.Context(), middleware.TenantFromRequest(r))
+	if err != nil {
+		h.logger.Error("Failed to list webhooks", zap.Error(err))
+		middleware.WriteError(w, domain.ErrInternalServer)
+		return
+	}
+	middleware.WriteJSON(w, http.StatusOK, WebhookListResponse{Webhooks: hooks})
+}
+
+// GetWebhook handles GET /api/v1/webhooks/{webhookID}.
+func (h *WebhooksHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.tenantWebhook(w, r)
+	if !ok {
+		return
+	}
+	middleware.WriteJSON(w, http.StatusOK, hook)
+}
+
+// UpdateWebhook handles PATCH /api/v1/webhooks/{webhookID}.
+func (h *WebhooksHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.tenantWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	var req WebhookUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		middleware.WriteError(w, domain.ErrValidation.WithMessage("Invalid JSON body"))
+		return
+	}
+	if req.URL != nil {
+		hook.URL = *req.URL
+	}
+	if req.Events != nil {
+		hook.Events = *req.Events
+	}
+	if req.Description != nil {
+		hook.Description = *req.Description
+	}
+	if req.Active != nil {
+		hook.Active = *req.Active
+	}
+	if apiErr := h.validate(hook); apiErr != nil {
+		middleware.WriteError(w, apiErr)
+		return
+	}
+	hook.UpdatedAt = time.Now().UTC()
+
+	if err := h.store.UpdateWebhook(r.Context(), hook); err != nil {
+		h.writeStoreError(w, err, "Failed to update webhook")
+		return
+	}
+	middleware.WriteJSON(w, http.StatusOK, hook)
+}
+
+// DeleteWebhook handles DELETE /api/v1/webhooks/{webhookID}.
+func (h *WebhooksHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.tenantWebhook(w, r)
+	if !ok {
+		return
+	}
+	if err := h.store.DeleteWebhook(r.Context(), hook.ID); err != nil {
+		h.writeStoreError(w, err, "Failed to delete webhook")
+		return
+	}
+	h.logger.Info("Webhook deleted", zap.String("webhook_id", hook.ID), zap.String("tenant", hook.Tenant))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TestWebhook handles POST /api/v1/webhooks/{webhookID}/test. It sends a
+// webhook.test event to the webhook once, whether or not it is active, and returns
+// the delivery.
+func (h *WebhooksHandler) TestWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.tenantWebhook(w, r)
+	if !ok {
+		return
+	}
+	event := webhook.NewEvent(domain.WebhookEventTest, hook.Tenant, map[string]any{
+		"webhook_id": hook.ID,
+	})
+	middleware.WriteJSON(w, http.StatusOK, h.dispatcher.Deliver(r.Context(), hook, event, 1))
+}
+
+// ListDeliveries handles GET /api/v1/webhooks/{webhookID}/deliveries.
+func (h *WebhooksHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.tenantWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	limit := defaultDeliveryListLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxDeliveryListLimit {
+			middleware.WriteError(w, domain.ErrValidation.WithDetails(map[string]any{
+				"field":   "limit",
+				"message": "limit must be between 1 and 100",
+			}))
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := h.store.ListDeliveries(r.Context(), hook.ID, limit)
+	if err != nil {
+		h.writeStoreError(w, err, "Failed to list webhook deliveries")
+		return
+	}
+	middleware.WriteJSON(w, http.StatusOK, WebhookDeliveryListResponse{Deliveries: deliveries})
+}
+
+// tenantWebhook loads the webhook named in the URL. A webhook of another tenant is
+// reported as not found.
+func (h *WebhooksHandler) tenantWebhook(w http.ResponseWriter, r *http.Request) (*domain.Webhook, bool) {
+	hook, err := h.store.GetWebhook(r.Context(), chi.URLParam(r, "webhookID"))
+	if err == nil && hook.Tenant != middleware.TenantFromRequest(r) {
+		err = domain.ErrWebhookNotFound
+	}
+	if err != nil {
+		h.writeStoreError(w, err, "Failed to get webhook")
+		return nil, false
+	}
+	return hook, true
+}
+
+func (h *WebhooksHandler) writeStoreError(w http.ResponseWriter, err error, msg string) {
+	if apiErr, ok := err.(*domain.APIError); ok {
+		middleware.WriteError(w, apiErr)
+		return
+	}
+	h.logger.Error(msg, zap.Error(err))
+	middleware.WriteError(w, domain.ErrInternalServer)
+}
+
+func (h *WebhooksHandler) validate(hook *domain.Webhook) *domain.APIError {
+	if err := h.dispatcher.ValidateURL(hook.URL); err != nil {
+		return domain.ErrValidation.WithDetails(map[string]any{
+			"field":   "url",
+			"message": err.Error(),
+		})
+	}
+	if len(hook.Events) == 0 {
+		return domain.ErrValidation.WithDetails(map[string]any{
+			"field":   "events",
+			"message": "events must list at least one of " + strings.Join(domain.WebhookEventTypes, ", "),
+		})
+	}
+	for _, event := range hook.Events {
+		if !slices.Contains(domain.WebhookEventTypes, event) {
+			return domain.ErrValidation.WithDetails(map[string]any{
+				"field":   "events",
+				"message": "unknown event type " + strconv.Quote(event),
+			})
+		}
+	}
+	if len(hook.Description) > maxWebhookDescriptionLen {
+		return domain.ErrValidation.WithDetails(map[string]any{
+			"field":   "description",
+			"message": "description must be at most 256 characters",
+		})
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/pako-tts/server/internal/api/middleware"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/queue/memory"
+	"github.com/pako-tts/server/internal/webhook"
+)
+
+func TestWebhooksHandler(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	store := webhook.NewMemoryStore()
+	h := NewWebhooksHandler(store, webhook.NewDispatcher(store, memory.NewQueue(10), testLogger(), time.Second, nil, ""), testLogger())
+
+	call := func(handler http.HandlerFunc, method, tenant, webhookID, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, "/api/v1/webhooks", bytes.NewBufferString(body))
+		req.Header.Set(middleware.TenantHeader, tenant)
+		if webhookID != "" {
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("webhookID", webhookID)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	// Registration validates the URL and the event types
+	for _, body := range []string{
+		`{"url":"ftp://example.com","events":["job.completed"]}`,
+		`{"url":"` + receiver.URL + `","events":[]}`,
+		`{"url":"` + receiver.URL + `","events":["job.started"]}`,
+	} {
+		if rec := call(h.CreateWebhook, http.MethodPost, "acme", "", body); rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected status 422, got %d", body, rec.Code)
+		}
+	}
+
+	rec := call(h.CreateWebhook, http.MethodPost, "acme", "", `{"url":"`+receiver.URL+`","events":["job.completed","batch.completed"]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var hook domain.Webhook
+	if err := json.NewDecoder(rec.Body).Decode(&hook); err != nil {
+		t.Fatalf("decode webhook: %v", err)
+	}
+	if hook.ID == "" || hook.Tenant != "acme" || !hook.Active {
+		t.Fatalf("unexpected webhook %+v", hook)
+	}
+
+	// Other tenants neither see nor reach it
+	if rec := call(h.GetWebhook, http.MethodGet, "globex", hook.ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for another tenant, got %d", rec.Code)
+	}
+	var list WebhookListResponse
+	json.NewDecoder(call(h.ListWebhooks, http.MethodGet, "globex", "", "").Body).Decode(&list) //nolint:errcheck
+	if len(list.Webhooks) != 0 {
+		t.Errorf("expected no webhooks for another tenant, got %d", len(list.Webhooks))
+	}
+
+	rec = call(h.UpdateWebhook, http.MethodPatch, "acme", hook.ID, `{"events":["job.failed"],"active":false}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	json.NewDecoder(rec.Body).Decode(&hook) //nolint:errcheck
+	if hook.Active || len(hook.Events) != 1 || hook.Events[0] != domain.WebhookEventJobFailed || hook.URL != receiver.URL {
+		t.Errorf("unexpected updated webhook %+v", hook)
+	}
+
+	// Test deliveries reach inactive webhooks and are logged. The receiver listens
+	// on loopback, which deliveries refuse to connect to.
+	rec = call(h.TestWebhook, http.MethodPost, "acme", hook.ID, "")
+	var delivery domain.WebhookDelivery
+	json.NewDecoder(rec.Body).Decode(&delivery) //nolint:errcheck
+	if rec.Code != http.StatusOK || delivery.Success || !strings.Contains(delivery.Error, "address is not public") ||
+		delivery.EventType != domain.WebhookEventTest {
+		t.Fatalf("unexpected test delivery %d %+v", rec.Code, delivery)
+	}
+	var deliveries WebhookDeliveryListResponse
+	json.NewDecoder(call(h.ListDeliveries, http.MethodGet, "acme", hook.ID, "").Body).Decode(&deliveries) //nolint:errcheck
+	if len(deliveries.Deliveries) != 1 || deliveries.Deliveries[0].ID != delivery.ID {
+		t.Errorf("expected the test delivery in the log, got %+v", deliveries.Deliveries)
+	}
+
+	if rec := call(h.DeleteWebhook, http.MethodDelete, "acme", hook.ID, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", rec.Code)
+	}
+	if rec := call(h.GetWebhook, http.MethodGet, "acme", hook.ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 after delete, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/abuse"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/metrics"
+)
+
+// maxPeekBytes bounds how much of a request body the abuse guard reads; texts
+// of larger bodies aren't observed.
+const maxPeekBytes = 4 << 20
+
+// synthesisBody holds the fields of the synthesis requests (/tts, /jobs,
+// /cache/warm) the abuse detector observes.
+type synthesisBody struct {
+	Text    string `json:"text"`
+	VoiceID string `json:"voice_id"`
+	Source  *struct {
+		Text string `json:"text"`
+	} `json:"source"`
+	Items []struct {
+		Text    string `json:"text"`
+		VoiceID string `json:"voice_id"`
+	} `json:"items"`
+}
+
+// NewAbuseGuard returns middleware that holds back API keys the detector has
+// flagged: 403 KEY_QUARANTINED for quarantined keys, and 429 KEY_THROTTLED for
+// submissions of throttled keys beyond their rate. The texts of admitted POST
+// requests are passed to the detector. A nil detector passes every request. It
+// must run after NewAPIKeyAuth.
+func NewAbuseGuard(detector *abuse.Detector, m *metrics.AbuseMetrics, logger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if detector == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := APIKeyFromContext(r.Context())
+			if key == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			submission := r.Method == http.MethodPost
+			flag, wait, ok := detector.Admit(key.Name, submission)
+			if !ok {
+				logger.Warn("Request of flagged API key rejected",
+					zap.String("api_key", key.Name),
+					zap.String("reason", flag.Reason),
+					zap.String("action", flag.Action),
+				)
+				m.Rejected(flag.Action)
+				if flag.Action == abuse.ActionQuarantine {
+					WriteError(w, domain.ErrKeyQuarantined)
+					return
+				}
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				WriteError(w, domain.ErrKeyThrottled)
+				return
+			}
+
+			if submission {
+				observe(detector, key.Name, r)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// observe passes the texts of r's body to the detector, leaving the body for
+// the handler to read.
+func observe(detector *abuse.Detector, key string, r *http.Request) {
+	peeked, err := io.ReadAll(io.LimitReader(r.Body, maxPeekBytes))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(peeked), r.Body), r.Body}
+	if err != nil {
+		return
+	}
+
+	var body synthesisBody
+	if json.Unmarshal(peeked, &body) != nil {
+		return
+	}
+	if body.Text != "" {
+		detector.Observe(key, body.VoiceID, body.Text)
+	}
+	if body.Source != nil && body.Source.Text != "" {
+		detector.Observe(key, body.VoiceID, body.Source.Text)
+	}
+	for _, item := range body.Items {
+		if item.Text != "" {
+			detector.Observe(key, item.VoiceID, item.Text)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/abuse"
+)
+
+func TestAbuseGuard(t *testing.T) {
+	for _, tt := range []struct {
+		action     string
+		submission int
+		status     int
+	}{
+		{abuse.ActionLog, http.StatusOK, http.StatusOK},
+		{abuse.ActionThrottle, http.StatusTooManyRequests, http.StatusOK},
+		{abuse.ActionQuarantine, http.StatusForbidden, http.StatusForbidden},
+	} {
+		t.Run(tt.action, func(t *testing.T) {
+			detector := abuse.NewDetector(abuse.Config{Window: time.Hour, MaxIdenticalRequests: 1, Action: tt.action, ThrottlePerMinute: 1})
+			var bodies []string
+			handler := NewAPIKeyAuth([]APIKey{{Name: "acme", Key: "secret"}})(NewAbuseGuard(detector, nil, zap.NewNop())(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					body, _ := io.ReadAll(r.Body)
+					bodies = append(bodies, string(body))
+				}),
+			))
+			do := func(method, body string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(method, "/api/v1/jobs", strings.NewReader(body))
+				req.Header.Set("X-API-Key", "secret")
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				return rec
+			}
+
+			const body = `{"text":"spam","voice_id":"v1"}`
+			for range 2 {
+				if rec := do(http.MethodPost, body); rec.Code != http.StatusOK {
+					t.Fatalf("expected 200 before the flag, got %d", rec.Code)
+				}
+			}
+			if len(bodies) != 2 || bodies[1] != body {
+				t.Fatalf("expected the handler to read the whole body, got %q", bodies)
+			}
+			if flags := detector.Flags(); len(flags) != 1 || flags[0].Key != "acme" || flags[0].Reason != abuse.ReasonIdenticalRequests {
+				t.Fatalf("expected the key flagged, got %+v", flags)
+			}
+
+			// A throttled key still gets one submission per interval
+			do(http.MethodPost, body)
+			rec := do(http.MethodPost, body)
+			if rec.Code != tt.submission {
+				t.Errorf("expected submission status %d, got %d", tt.submission, rec.Code)
+			}
+			if tt.action == abuse.ActionThrottle && rec.Header().Get("Retry-After") == "" {
+				t.Error("expected Retry-After on a throttled submission")
+			}
+			if rec := do(http.MethodGet, ""); rec.Code != tt.status {
+				t.Errorf("expected status %d for other requests, got %d", tt.status, rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/textinfo"
+)
+
+// APIKey is a configured client credential.
+type APIKey struct {
+	Name  string
+	Key   string
+	Rules *IPRules // per-key IP rules; nil admits any address
+	// OutputFormat is the output format of the key's requests that name none;
+	// empty means mp3.
+	OutputFormat string
+	// TextRules restrict the characters of the key's request texts.
+	TextRules textinfo.Rules
+}
+
+type apiKeyContextKey struct{}
+
+// APIKeyFromContext returns the API key that authenticated the request, or nil
+// when authentication is disabled.
+func APIKeyFromContext(ctx context.Context) *APIKey {
+	key, _ := ctx.Value(apiKeyContextKey{}).(*APIKey)
+	return key
+}
+
+// NewAPIKeyAuth returns middleware that requires one of keys in the Authorization
+// (Bearer) or X-API-Key header. With no keys configured, every request passes.
+func NewAPIKeyAuth(keys []APIKey) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(keys) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			presented := requestAPIKey(r)
+			if presented == "" {
+				WriteError(w, domain.ErrUnauthorized)
+				return
+			}
+
+			var matched *APIKey
+			for i := range keys {
+				// Compare against every key so timing doesn't reveal which one matched.
+				if subtle.ConstantTimeCompare([]byte(presented), []byte(keys[i].Key)) == 1 {
+					matched = &keys[i]
+				}
+			}
+			if matched == nil {
+				WriteError(w, domain.ErrUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, matched)))
+		})
+	}
+}
+
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return ""
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pako-tts/server/internal/deadline"
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// Deadline bounds a request's context by the deadline its X-Deadline header
+// asks for, so the provider calls made for it pass the deadline on. A request
+// whose deadline already passed is rejected with 504 DEADLINE_EXCEEDED before
+// any work is done.
+func Deadline(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t, ok, err := deadline.FromRequest(r)
+		if err != nil {
+			WriteError(w, domain.ErrValidation.WithDetails(map[string]any{
+				"field":   deadline.Header,
+				"message": "must be an RFC 3339 timestamp",
+			}))
+			return
+		}
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !t.After(time.Now()) {
+			WriteError(w, domain.ErrDeadlineExceeded)
+			return
+		}
+
+		ctx, cancel := context.WithDeadline(r.Context(), t)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pako-tts/server/internal/deadline"
+)
+
+func TestDeadline(t *testing.T) {
+	var got time.Time
+	var bounded bool
+	handler := Deadline(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, bounded = r.Context().Deadline()
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	future := time.Now().Add(time.Minute).Truncate(time.Millisecond)
+	tests := []struct {
+		name        string
+		value       string
+		wantStatus  int
+		wantBounded bool
+	}{
+		{"no header", "", http.StatusOK, false},
+		{"future deadline", deadline.Format(future), http.StatusOK, true},
+		{"passed deadline", deadline.Format(time.Now().Add(-time.Second)), http.StatusGatewayTimeout, false},
+		{"malformed", "in five seconds", http.StatusUnprocessableEntity, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bounded = false
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/tts", nil)
+			if tt.value != "" {
+				req.Header.Set(deadline.Header, tt.value)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if bounded != tt.wantBounded {
+				t.Errorf("expected a bounded context: %v, got %v", tt.wantBounded, bounded)
+			}
+			if tt.wantBounded && !got.Equal(future) {
+				t.Errorf("expected deadline %v, got %v", future, got)
+			}
+		})
+	}
+}
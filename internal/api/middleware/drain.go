@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// NewDrainGuard returns middleware that rejects requests with 503 DRAINING once
+// drainer has started a drain, so an instance going away takes no new jobs. A nil
+// drainer passes every request.
+func NewDrainGuard(drainer domain.Drainer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if drainer == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if drainer.DrainStatus().State != domain.DrainStateRunning {
+				w.Header().Set("Retry-After", "60")
+				WriteError(w, domain.ErrDraining)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// IPRules is a CIDR allow/deny list. Deny entries win; an empty allow list admits
+// every address that isn't denied.
+type IPRules struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// ParseIPRules parses CIDR blocks (or bare addresses) into IPRules.
+func ParseIPRules(allow, deny []string) (*IPRules, error) {
+	rules := &IPRules{}
+	var err error
+	if rules.allow, err = parsePrefixes(allow); err != nil {
+		return nil, err
+	}
+	if rules.deny, err = parsePrefixes(deny); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func parsePrefixes(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid IP address %q: %w", entry, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", entry, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// IsEmpty reports whether the rules admit every address.
+func (r *IPRules) IsEmpty() bool {
+	return r == nil || (len(r.allow) == 0 && len(r.deny) == 0)
+}
+
+// Allows reports whether addr passes the rules.
+func (r *IPRules) Allows(addr netip.Addr) bool {
+	if r.IsEmpty() {
+		return true
+	}
+	addr = addr.Unmap()
+	for _, p := range r.deny {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	if len(r.allow) == 0 {
+		return true
+	}
+	for _, p := range r.allow {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewIPFilter returns middleware that rejects clients outside the global rules, and
+// outside the per-key rules of the authenticated API key, with 403 IP_NOT_ALLOWED.
+// It must run after NewClientAddr and NewAPIKeyAuth.
+func NewIPFilter(global *IPRules, logger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := APIKeyFromContext(r.Context())
+			if global.IsEmpty() && (key == nil || key.Rules.IsEmpty()) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			addr, ok := ClientAddr(r)
+			scope := "global"
+			allowed := ok && global.Allows(addr)
+			if allowed && key != nil {
+				scope = "api_key"
+				allowed = key.Rules.Allows(addr)
+			}
+
+			if !allowed {
+				logger.Warn("Request rejected by IP filter",
+					zap.String("remote_addr", r.RemoteAddr),
+					zap.Stringer("client_addr", addr),
+					zap.String("scope", scope),
+				)
+				WriteError(w, domain.ErrIPNotAllowed)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// TrustedProxies are the reverse proxies whose X-Forwarded-For entries are
+// believed when telling a request's client.
+type TrustedProxies struct {
+	prefixes []netip.Prefix
+}
+
+// ParseTrustedProxies parses CIDR blocks (or bare addresses) of trusted proxies.
+func ParseTrustedProxies(entries []string) (*TrustedProxies, error) {
+	prefixes, err := parsePrefixes(entries)
+	if err != nil {
+		return nil, err
+	}
+	return &TrustedProxies{prefixes: prefixes}, nil
+}
+
+func (p *TrustedProxies) trusts(addr netip.Addr) bool {
+	if p == nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range p.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientAddr returns the address of the client that sent r. It is the socket
+// peer, unless the peer is a trusted proxy: then X-Forwarded-For is read from
+// the right, each trusted proxy's entry skipped, and the first other entry is the
+// client. Entries left of it were set by the client and can't be believed.
+// X-Real-IP is ignored. A malformed entry yields no address.
+func (p *TrustedProxies) ClientAddr(r *http.Request) (netip.Addr, bool) {
+	peer, ok := peerAddr(r)
+	if !ok || !p.trusts(peer) {
+		return peer, ok
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		addr, err := netip.ParseAddr(hop)
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		if !p.trusts(addr) {
+			return addr.Unmap(), true
+		}
+	}
+	return peer, true
+}
+
+type clientAddrKey struct{}
+
+// NewClientAddr returns middleware that resolves the client address of each
+// request with proxies, for ClientAddr. nil proxies take the socket peer.
+func NewClientAddr(proxies *TrustedProxies) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if addr, ok := proxies.ClientAddr(r); ok {
+				r = r.WithContext(context.WithValue(r.Context(), clientAddrKey{}, addr))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClientAddr returns the client address NewClientAddr resolved, or the socket
+// peer when it didn't run.
+func ClientAddr(r *http.Request) (netip.Addr, bool) {
+	if addr, ok := r.Context().Value(clientAddrKey{}).(netip.Addr); ok {
+		return addr, true
+	}
+	return peerAddr(r)
+}
+
+// peerAddr extracts the socket peer's IP from RemoteAddr (host:port, or a bare IP).
+func peerAddr(r *http.Request) (netip.Addr, bool) {
+	host := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	addr, err := netip.ParseAddr(host)
+	return addr.Unmap(), err == nil
+}
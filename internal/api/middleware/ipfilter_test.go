@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestIPRules_Allows(t *testing.T) {
+	rules, err := ParseIPRules([]string{"10.0.0.0/8", "192.168.1.5"}, []string{"10.1.0.0/16"})
+	if err != nil {
+		t.Fatalf("ParseIPRules: %v", err)
+	}
+
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"10.2.3.4", true},
+		{"10.1.2.3", false}, // deny wins over allow
+		{"192.168.1.5", true},
+		{"192.168.1.6", false},
+		{"::ffff:10.2.3.4", true},
+	}
+	for _, tt := range tests {
+		if got := rules.Allows(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("Allows(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestParseIPRules_Invalid(t *testing.T) {
+	if _, err := ParseIPRules([]string{"10.0.0.0/33"}, nil); err == nil {
+		t.Error("expected error for invalid CIDR")
+	}
+	if _, err := ParseIPRules(nil, []string{"not-an-ip"}); err == nil {
+		t.Error("expected error for invalid address")
+	}
+}
+
+func TestAccessControl(t *testing.T) {
+	global, _ := ParseIPRules(nil, []string{"203.0.113.0/24"})
+	keyRules, _ := ParseIPRules([]string{"10.0.0.0/8"}, nil)
+	keys := []APIKey{
+		{Name: "backend", Key: "secret-backend", Rules: keyRules},
+		{Name: "open", Key: "secret-open"},
+	}
+
+	handler := NewAPIKeyAuth(keys)(NewIPFilter(global, zap.NewNop())(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }),
+	))
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     string
+		value      string
+		wantStatus int
+	}{
+		{"missing key", "10.0.0.1:1234", "", "", http.StatusUnauthorized},
+		{"unknown key", "10.0.0.1:1234", "X-API-Key", "nope", http.StatusUnauthorized},
+		{"key within its allowlist", "10.0.0.1:1234", "Authorization", "Bearer secret-backend", http.StatusOK},
+		{"key outside its allowlist", "172.16.0.1:1234", "X-API-Key", "secret-backend", http.StatusForbidden},
+		{"key without rules", "172.16.0.1:1234", "X-API-Key", "secret-open", http.StatusOK},
+		{"globally denied", "203.0.113.7:1234", "X-API-Key", "secret-open", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/providers", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestTrustedProxies_ClientAddr(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/24", "192.168.1.1"})
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies: %v", err)
+	}
+	if _, err := ParseTrustedProxies([]string{"proxy"}); err == nil {
+		t.Error("expected error for invalid address")
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{"direct client", "203.0.113.7:1234", nil, "203.0.113.7"},
+		{"direct client forging the header", "203.0.113.7:1234", []string{"10.1.2.3"}, "203.0.113.7"},
+		{"behind a proxy", "10.0.0.5:1234", []string{"198.51.100.9"}, "198.51.100.9"},
+		{"forged entries left of the client", "10.0.0.5:1234", []string{"10.1.2.3, 198.51.100.9"}, "198.51.100.9"},
+		{"chain of proxies", "10.0.0.5:1234", []string{"198.51.100.9, 192.168.1.1", "10.0.0.7"}, "198.51.100.9"},
+		{"only proxies", "10.0.0.5:1234", []string{"10.0.0.6"}, "10.0.0.5"},
+		{"proxy without header", "10.0.0.5:1234", nil, "10.0.0.5"},
+		{"malformed entry", "10.0.0.5:1234", []string{"198.51.100.9, unknown"}, "invalid IP"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-Real-IP", "10.9.9.9")
+			for _, value := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			got, _ := proxies.ClientAddr(req)
+			if got.String() != tt.want {
+				t.Errorf("ClientAddr = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPFilter_BehindProxy(t *testing.T) {
+	global, _ := ParseIPRules([]string{"198.51.100.0/24"}, nil)
+	proxies, _ := ParseTrustedProxies([]string{"10.0.0.5"})
+	handler := NewClientAddr(proxies)(NewIPFilter(global, zap.NewNop())(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }),
+	))
+
+	for _, tt := range []struct {
+		remoteAddr string
+		forwarded  string
+		want       int
+	}{
+		{"10.0.0.5:1234", "198.51.100.9", http.StatusOK},
+		{"10.0.0.5:1234", "198.51.100.9, 203.0.113.7", http.StatusForbidden},
+		{"203.0.113.7:1234", "198.51.100.9", http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tt.remoteAddr
+		req.Header.Set("X-Forwarded-For", tt.forwarded)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s forwarding %s: expected status %d, got %d", tt.remoteAddr, tt.forwarded, tt.want, w.Code)
+		}
+	}
+}
+
+func TestAPIKeyAuth_DisabledWithoutKeys(t *testing.T) {
+	handler := NewAPIKeyAuth(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+}
@@ -26,6 +26,7 @@ func NewLogging(logger *zap.Logger) func(http.Handler) http.Handler {
 
 			// Log request details
 			duration := time.Since(start)
+			client, _ := ClientAddr(r)
 			fields := []zap.Field{
 				zap.String("request_id", reqID),
 				zap.String("method", r.Method),
@@ -34,6 +35,7 @@ func NewLogging(logger *zap.Logger) func(http.Handler) http.Handler {
 				zap.Int("bytes", ww.BytesWritten()),
 				zap.Duration("duration", duration),
 				zap.String("remote_addr", r.RemoteAddr),
+				zap.Stringer("client_addr", client),
 				zap.String("user_agent", r.UserAgent()),
 			}
 
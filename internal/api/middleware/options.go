@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// routedMethods are the methods Options looks up a path's routes for, in the
+// order of the Allow header.
+var routedMethods = []string{
+	http.MethodGet,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// Options answers OPTIONS requests with 204 and an Allow header listing the
+// methods the path is routed for, including HEAD wherever GET is served. Paths
+// with no route fall through to 404. CORS preflight requests are answered by the
+// CORS handler before they get here. It must be used on the top-level router.
+func Options(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		path := r.URL.RawPath
+		if path == "" {
+			path = r.URL.Path
+		}
+		routes := chi.RouteContext(r.Context()).Routes
+		var allowed []string
+		for _, method := range routedMethods {
+			if routes.Match(chi.NewRouteContext(), method, path) {
+				allowed = append(allowed, method)
+			}
+		}
+		if len(allowed) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if slices.Contains(allowed, http.MethodGet) {
+			allowed = slices.Insert(allowed, 1, http.MethodHead)
+		}
+		allowed = append(allowed, http.MethodOptions)
+
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// SyncSwitch turns the synchronous TTS endpoint off and on at runtime, e.g. while
+// an instance is being drained for a deploy. The job system is not affected.
+type SyncSwitch struct {
+	mu     sync.RWMutex
+	status SyncStatus
+}
+
+// SyncStatus reports whether synchronous synthesis is accepted.
+type SyncStatus struct {
+	Enabled bool `json:"enabled"`
+	// Reason is the operator's note from the last change, if any.
+	Reason    string     `json:"reason,omitempty"`
+	ChangedAt *time.Time `json:"changed_at,omitempty"`
+}
+
+// NewSyncSwitch creates a switch that starts enabled.
+func NewSyncSwitch() *SyncSwitch {
+	return &SyncSwitch{status: SyncStatus{Enabled: true}}
+}
+
+// Set enables or disables synchronous synthesis.
+func (s *SyncSwitch) Set(enabled bool, reason string) SyncStatus {
+	now := time.Now().UTC()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = SyncStatus{Enabled: enabled, Reason: reason, ChangedAt: &now}
+	return s.status
+}
+
+// Status returns the current state.
+func (s *SyncSwitch) Status() SyncStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.status
+}
+
+// Handler rejects requests with 503 SYNC_DISABLED while the switch is off, so
+// clients can fall back to async jobs.
+func (s *SyncSwitch) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.Status().Enabled {
+			w.Header().Set("Retry-After", "60")
+			WriteError(w, domain.ErrSyncDisabled)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
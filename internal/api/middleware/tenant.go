@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// TenantHeader names the tenant of a request when API key auth is disabled.
+const TenantHeader = "X-Tenant-ID"
+
+const maxTenantLen = 64
+
+// TenantFromRequest returns the tenant a request's jobs are scheduled under: the
+// name of the authenticating API key, else a valid X-Tenant-ID header, else
+// domain.DefaultTenant. The header is only read without authentication, so an
+// authenticated caller can't claim another tenant.
+func TenantFromRequest(r *http.Request) string {
+	if key := APIKeyFromContext(r.Context()); key != nil {
+		if key.Name == "" {
+			return domain.DefaultTenant
+		}
+		return key.Name
+	}
+	if tenant := r.Header.Get(TenantHeader); validTenant(tenant) {
+		return tenant
+	}
+	return domain.DefaultTenant
+}
+
+func validTenant(s string) bool {
+	if s == "" || len(s) > maxTenantLen {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '.', c == '_', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+func TestTenantFromRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		key    *APIKey
+		header string
+		want   string
+	}{
+		{"no key or header", nil, "", domain.DefaultTenant},
+		{"header", nil, "acme-1", "acme-1"},
+		{"invalid header", nil, "acme corp", domain.DefaultTenant},
+		{"header too long", nil, strings.Repeat("a", maxTenantLen+1), domain.DefaultTenant},
+		{"api key wins over header", &APIKey{Name: "mobile", Key: "k"}, "acme-1", "mobile"},
+		{"unnamed api key ignores header", &APIKey{Key: "k"}, "acme-1", domain.DefaultTenant},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/jobs", nil)
+			if tt.header != "" {
+				req.Header.Set(TenantHeader, tt.header)
+			}
+			if tt.key != nil {
+				var got string
+				NewAPIKeyAuth([]APIKey{*tt.key})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					got = TenantFromRequest(r)
+				})).ServeHTTP(httptest.NewRecorder(), withKey(req, tt.key.Key))
+				if got != tt.want {
+					t.Errorf("TenantFromRequest() = %q, want %q", got, tt.want)
+				}
+				return
+			}
+			if got := TenantFromRequest(req); got != tt.want {
+				t.Errorf("TenantFromRequest() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func withKey(req *http.Request, key string) *http.Request {
+	req.Header.Set("X-API-Key", key)
+	return req
+}
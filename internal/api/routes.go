@@ -10,12 +10,33 @@ import (
 	"github.com/go-chi/cors"
 	"go.uber.org/zap"
 
+	"github.com/pako-tts/server/internal/abuse"
 	"github.com/pako-tts/server/internal/api/handlers"
 	apimiddleware "github.com/pako-tts/server/internal/api/middleware"
+	"github.com/pako-tts/server/internal/bulk"
 	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/metrics"
+	"github.com/pako-tts/server/internal/queue/dedup"
 	"github.com/pako-tts/server/internal/ui"
+	"github.com/pako-tts/server/internal/webhook"
 )
 
+// Features says which API surfaces the router serves. Routes of a surface that
+// is off aren't mounted, so they answer 404.
+type Features struct {
+	// SyncTTS serves /tts, /tts/stream, /tts/estimate and /cache/warm.
+	SyncTTS bool
+	// AsyncJobs serves /jobs, /groups, /analytics and /webhooks.
+	AsyncJobs bool
+	// Admin serves /admin, which also needs an admin key.
+	Admin bool
+	// UI serves the browser UI at /ui/.
+	UI bool
+}
+
+// AllFeatures serves every API surface.
+var AllFeatures = Features{SyncTTS: true, AsyncJobs: true, Admin: true, UI: true}
+
 // RouterDeps contains dependencies for the router.
 type RouterDeps struct {
 	Logger           *zap.Logger
@@ -27,6 +48,62 @@ type RouterDeps struct {
 	DefaultVoiceID   string
 	RetentionHours   int
 	OpenAPISpec      []byte
+	// ArtifactRetention keeps kinds of artifacts for their own period instead
+	// of RetentionHours.
+	ArtifactRetention map[string]time.Duration
+	// ResultURLs redirects result downloads to URLs signed for SignedURLTTL
+	// when non-nil.
+	ResultURLs   domain.SignedURLs
+	SignedURLTTL time.Duration
+	// VoicesCacheTTL is how long provider voice lists are reused; 0 disables caching.
+	VoicesCacheTTL time.Duration
+	// AsyncFallback turns POST /tts requests that run out of SyncTimeout into
+	// async jobs, while AsyncJobs is on.
+	AsyncFallback bool
+	// APIKeys enables API key authentication when non-empty.
+	APIKeys []apimiddleware.APIKey
+	// IPRules is the global client IP allow/deny list; nil admits everyone.
+	IPRules *apimiddleware.IPRules
+	// TrustedProxies are believed about the client address; nil trusts none.
+	TrustedProxies *apimiddleware.TrustedProxies
+	// AdminKey enables the /api/v1/admin endpoints when set.
+	AdminKey   string
+	KeyManager domain.ProviderKeyManager
+	// Dedup enables duplicate-submission detection for async jobs when non-nil.
+	Dedup *dedup.Index
+	// RegenerateGrace keeps job text this long after the result expires.
+	RegenerateGrace time.Duration
+	// ClampVoiceSettings clamps out-of-range voice settings instead of rejecting them.
+	ClampVoiceSettings bool
+	// Metrics is served at /metrics and receives request metrics when non-nil.
+	Metrics *metrics.Registry
+	// TextSources validates job text sources; nil rejects jobs that reference one.
+	TextSources domain.TextSourceResolver
+	// WorkerPools adds per-pool stats to GET /admin/queue when non-nil.
+	WorkerPools domain.WorkerPools
+	// EffectiveConfig is the redacted configuration served at GET /admin/config.
+	EffectiveConfig map[string]any
+	// SyncSwitch lets admins turn POST /tts off; nil creates one that starts enabled.
+	SyncSwitch *apimiddleware.SyncSwitch
+	// SpeechCache serves warmed sync requests and enables /cache/warm when non-nil.
+	SpeechCache domain.SpeechCache
+	// ResultCache serves sync requests identical to an earlier one when non-nil.
+	ResultCache        domain.SpeechCache
+	ResultCacheMetrics *metrics.ResultCacheMetrics
+	// Webhooks enables /webhooks when non-nil; WebhookDispatcher sends its test deliveries.
+	Webhooks          domain.WebhookStore
+	WebhookDispatcher *webhook.Dispatcher
+	// Abuse holds back flagged API keys and enables /admin/abuse when non-nil.
+	Abuse        *abuse.Detector
+	AbuseMetrics *metrics.AbuseMetrics
+	// Drainer enables /admin/drain and refuses new jobs while it drains, when non-nil.
+	Drainer domain.Drainer
+	// JobSearch enables /jobs/search when non-nil.
+	JobSearch domain.JobSearch
+	// Bulk enables /admin/operations when non-nil.
+	Bulk *bulk.Runner
+	// Features switches API surfaces off; nil serves AllFeatures.
+	Features *Features
 }
 
 // NewRouter creates a new Chi router with all routes and middleware.
@@ -35,21 +112,45 @@ func NewRouter(deps *RouterDeps) *chi.Mux {
 
 	// Global middleware
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(apimiddleware.NewClientAddr(deps.TrustedProxies))
 	r.Use(apimiddleware.NewLogging(deps.Logger))
 	r.Use(middleware.Recoverer)
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-Request-ID"},
-		ExposedHeaders:   []string{"X-Request-ID"},
+		AllowedMethods:   []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-API-Key", "X-Request-ID", "X-Tenant-ID"},
+		ExposedHeaders:   []string{"X-Request-ID", handlers.WarningsHeader, handlers.CacheHeader},
 		AllowCredentials: false,
 		MaxAge:           300,
 	}))
+	// HEAD is served by the GET handlers, without the body, so probes can check
+	// a result or a job's status without downloading it
+	r.Use(middleware.GetHead)
+	r.Use(apimiddleware.Options)
+
+	features := AllFeatures
+	if deps.Features != nil {
+		features = *deps.Features
+	}
+
+	syncSwitch := deps.SyncSwitch
+	if syncSwitch == nil {
+		syncSwitch = apimiddleware.NewSyncSwitch()
+	}
+
+	// A draining instance takes no new jobs
+	drainGuard := apimiddleware.NewDrainGuard(deps.Drainer)
+
+	var textMetrics *metrics.TextMetrics
+	if deps.Metrics != nil {
+		textMetrics = metrics.NewTextMetrics(deps.Metrics)
+	}
+	// Time to first byte is kept for estimates even when /metrics is off
+	ttfb := metrics.NewTTFB(deps.Metrics)
 
 	// Create handlers
 	healthHandler := handlers.NewHealthHandler(deps.ProviderRegistry, deps.Logger)
-	providersHandler := handlers.NewProvidersHandler(deps.ProviderRegistry, deps.Logger)
+	providersHandler := handlers.NewProvidersHandler(deps.ProviderRegistry, deps.Logger, deps.VoicesCacheTTL)
 
 	// OpenAPI handler (if spec provided)
 	var openAPIHandler *handlers.OpenAPIHandler
@@ -60,13 +161,6 @@ func NewRouter(deps *RouterDeps) *chi.Mux {
 			deps.Logger.Warn("Failed to parse OpenAPI spec", zap.Error(err))
 		}
 	}
-	ttsHandler := handlers.NewTTSHandler(
-		deps.ProviderRegistry,
-		deps.Logger,
-		deps.SyncTimeout,
-		deps.MaxSyncTextLen,
-		deps.DefaultVoiceID,
-	)
 	jobsHandler := handlers.NewJobsHandler(
 		deps.ProviderRegistry,
 		deps.Queue,
@@ -74,20 +168,72 @@ func NewRouter(deps *RouterDeps) *chi.Mux {
 		deps.Logger,
 		deps.DefaultVoiceID,
 		deps.RetentionHours,
+		deps.ClampVoiceSettings,
+		deps.RegenerateGrace,
+		deps.Dedup,
+		textMetrics,
+		deps.TextSources,
+		deps.WebhookDispatcher,
+	)
+	jobsHandler.RetainArtifacts(deps.ArtifactRetention)
+	if deps.ResultURLs != nil {
+		jobsHandler.RedirectResults(deps.ResultURLs, deps.SignedURLTTL)
+	}
+	// Sync requests that run out of time become jobs, while jobs are served
+	var fallbackJobs *handlers.JobsHandler
+	if deps.AsyncFallback && features.AsyncJobs {
+		fallbackJobs = jobsHandler
+	}
+	ttsHandler := handlers.NewTTSHandler(
+		deps.ProviderRegistry,
+		deps.Logger,
+		deps.SyncTimeout,
+		deps.MaxSyncTextLen,
+		deps.DefaultVoiceID,
+		deps.ClampVoiceSettings,
+		textMetrics,
+		deps.SpeechCache,
+		ttfb,
+		deps.ResultCache,
+		deps.ResultCacheMetrics,
+		fallbackJobs,
 	)
 
+	metaHandler := handlers.NewMetaHandler(deps.ProviderRegistry, map[string]bool{
+		"sync_tts":       features.SyncTTS,
+		"async_jobs":     features.AsyncJobs,
+		"admin":          features.Admin && deps.AdminKey != "",
+		"ui":             features.UI,
+		"auth":           len(deps.APIKeys) > 0,
+		"text_sources":   features.AsyncJobs && deps.TextSources != nil,
+		"job_search":     features.AsyncJobs && deps.JobSearch != nil,
+		"webhooks":       features.AsyncJobs && deps.Webhooks != nil,
+		"dedup":          features.AsyncJobs && deps.Dedup != nil,
+		"async_fallback": features.SyncTTS && fallbackJobs != nil,
+		"speech_cache":   features.SyncTTS && deps.SpeechCache != nil,
+		"result_cache":   deps.ResultCache != nil,
+		"signed_urls":    features.AsyncJobs && deps.ResultURLs != nil,
+	}, deps.MaxSyncTextLen, deps.SyncTimeout, deps.RetentionHours, deps.RegenerateGrace)
+
 	// OpenAPI spec at root
 	if openAPIHandler != nil {
 		r.Get("/openapi.json", openAPIHandler.ServeSpecJSON)
 		r.Get("/openapi.yaml", openAPIHandler.ServeSpecYAML)
 	}
 
+	// Prometheus metrics
+	if deps.Metrics != nil {
+		r.Handle("/metrics", deps.Metrics.Handler())
+	}
+
 	// Browser UI
-	uiHandler := ui.NewHandler()
-	r.Get("/ui", func(w http.ResponseWriter, req *http.Request) {
-		http.Redirect(w, req, "/ui/", http.StatusMovedPermanently)
-	})
-	r.Get("/ui/", uiHandler.ServeHTTP)
+	if features.UI {
+		uiHandler := ui.NewHandler()
+		r.Get("/ui", func(w http.ResponseWriter, req *http.Request) {
+			http.Redirect(w, req, "/ui/", http.StatusMovedPermanently)
+		})
+		r.Get("/ui/", uiHandler.ServeHTTP)
+	}
 
 	// API routes
 	r.Route("/api/v1", func(r chi.Router) {
@@ -100,19 +246,165 @@ func NewRouter(deps *RouterDeps) *chi.Mux {
 		// Health check
 		r.Get("/health", healthHandler.HealthCheck)
 
-		// Providers
-		r.Get("/providers", providersHandler.ListProviders)
-		r.Get("/providers/{name}/voices", providersHandler.ListVoices)
-		r.Get("/providers/{name}/models", providersHandler.ListModels)
+		// Error codes, for clients to look up before they have a key
+		r.Get("/errors", handlers.ListErrors)
+
+		// Everything below requires an API key (when configured) and passes the IP filter
+		r.Group(func(r chi.Router) {
+			r.Use(apimiddleware.NewAPIKeyAuth(deps.APIKeys))
+			r.Use(apimiddleware.NewIPFilter(deps.IPRules, deps.Logger))
+			r.Use(apimiddleware.NewAbuseGuard(deps.Abuse, deps.AbuseMetrics, deps.Logger))
+
+			// Providers
+			r.Get("/providers", providersHandler.ListProviders)
+			r.Get("/providers/{name}/voices", providersHandler.ListVoices)
+			r.Get("/providers/{name}/models", providersHandler.ListModels)
+			r.Get("/voices", providersHandler.ListAllVoices)
+			r.Get("/voices/{voiceID}", providersHandler.GetVoice)
+			r.Get("/voices/{voiceID}/preview", providersHandler.GetVoicePreview)
+
+			// Pipeline stages requests can compose
+			r.Get("/pipeline/stages", handlers.ListPipelineStages)
+
+			// Version, features and limits, for clients to adapt to
+			r.Get("/meta", metaHandler.GetMeta)
+
+			// Synchronous TTS
+			if features.SyncTTS {
+				// With async fallback the handler applies the sync timeout itself
+				syncTimeout := middleware.Timeout(deps.SyncTimeout)
+				if fallbackJobs != nil {
+					syncTimeout = func(next http.Handler) http.Handler { return next }
+				}
+				r.With(syncSwitch.Handler, apimiddleware.Deadline, syncTimeout).Post("/tts", ttsHandler.SynthesizeTTS)
+				r.With(syncSwitch.Handler, apimiddleware.Deadline, middleware.Timeout(deps.SyncTimeout)).Post("/tts/stream", ttsHandler.StreamTTS)
+				r.Get("/tts/estimate", ttsHandler.EstimateTTS)
+
+				// Speech cache warming
+				if deps.SpeechCache != nil {
+					cacheHandler := handlers.NewCacheHandler(
+						deps.ProviderRegistry,
+						deps.Queue,
+						deps.SpeechCache,
+						deps.Logger,
+						deps.MaxSyncTextLen,
+						deps.DefaultVoiceID,
+						deps.ClampVoiceSettings,
+					)
+					r.With(drainGuard).Post("/cache/warm", cacheHandler.Warm)
+					r.Get("/cache/warm/{batchID}", cacheHandler.WarmStatus)
+				}
+			}
+
+			if !features.AsyncJobs {
+				return
+			}
+
+			// Async Jobs
+			r.With(drainGuard).Post("/jobs", jobsHandler.SubmitJob)
+			r.Get("/jobs", jobsHandler.ListJobs)
+			if deps.JobSearch != nil {
+				r.Get("/jobs/search", handlers.NewSearchHandler(deps.JobSearch, deps.RegenerateGrace, deps.Logger).SearchJobs)
+			}
+			r.Get("/jobs/{jobID}", jobsHandler.GetJobStatus)
+			r.Delete("/jobs/{jobID}", jobsHandler.CancelJob)
+			r.Get("/jobs/{jobID}/result", jobsHandler.GetJobResult)
+			r.Delete("/jobs/{jobID}/result", jobsHandler.DeleteJobResult)
+			r.Get("/jobs/{jobID}/artifacts", jobsHandler.GetJobArtifacts)
+			r.Get("/jobs/{jobID}/preview", jobsHandler.GetJobPreview)
+			r.Get("/jobs/{jobID}/waveform", jobsHandler.GetJobWaveform)
+			r.With(drainGuard).Post("/jobs/{jobID}/regenerate", jobsHandler.RegenerateJob)
+			r.With(drainGuard).Post("/jobs/{jobID}/retry", jobsHandler.RetryJob)
 
-		// Synchronous TTS
-		r.With(middleware.Timeout(deps.SyncTimeout)).Post("/tts", ttsHandler.SynthesizeTTS)
+			// Job groups, joined into one result
+			r.With(drainGuard).Post("/groups", jobsHandler.SubmitGroup)
+			r.Get("/groups/{groupID}", jobsHandler.GetGroup)
+			r.Get("/groups/{groupID}/result", jobsHandler.GetGroupResult)
 
-		// Async Jobs
-		r.Post("/jobs", jobsHandler.SubmitJob)
-		r.Get("/jobs/{jobID}", jobsHandler.GetJobStatus)
-		r.Get("/jobs/{jobID}/result", jobsHandler.GetJobResult)
+			// Job analytics, from queues that keep finished jobs
+			if analytics, ok := deps.Queue.(domain.JobAnalytics); ok {
+				r.Get("/analytics", handlers.NewAnalyticsHandler(analytics, true, deps.Logger).GetAnalytics)
+			}
+
+			// Tenant webhooks
+			if deps.Webhooks != nil {
+				webhooksHandler := handlers.NewWebhooksHandler(deps.Webhooks, deps.WebhookDispatcher, deps.Logger)
+				r.Post("/webhooks", webhooksHandler.CreateWebhook)
+				r.Get("/webhooks", webhooksHandler.ListWebhooks)
+				r.Get("/webhooks/{webhookID}", webhooksHandler.GetWebhook)
+				r.Patch("/webhooks/{webhookID}", webhooksHandler.UpdateWebhook)
+				r.Delete("/webhooks/{webhookID}", webhooksHandler.DeleteWebhook)
+				r.Post("/webhooks/{webhookID}/test", webhooksHandler.TestWebhook)
+				r.Get("/webhooks/{webhookID}/deliveries", webhooksHandler.ListDeliveries)
+			}
+		})
+
+		// Admin endpoints use their own key and are not mounted without one
+		if features.Admin && deps.AdminKey != "" {
+			adminHandler := handlers.NewAdminHandler(deps.KeyManager, deps.Queue, deps.WorkerPools, deps.EffectiveConfig, syncSwitch, deps.Logger)
+			r.Route("/admin", func(r chi.Router) {
+				r.Use(apimiddleware.NewAPIKeyAuth([]apimiddleware.APIKey{{Name: "admin", Key: deps.AdminKey}}))
+				r.Use(apimiddleware.NewIPFilter(deps.IPRules, deps.Logger))
+
+				r.Get("/queue", adminHandler.QueueStats)
+				r.Get("/jobs/export", handlers.NewExportHandler(deps.Queue, deps.Logger).ExportJobs)
+				r.Get("/sync", adminHandler.SyncStatus)
+				r.Put("/sync", adminHandler.SetSync)
+				if analytics, ok := deps.Queue.(domain.JobAnalytics); ok {
+					r.Get("/analytics", handlers.NewAnalyticsHandler(analytics, false, deps.Logger).GetAnalytics)
+				}
+				if deps.EffectiveConfig != nil {
+					r.Get("/config", adminHandler.EffectiveConfig)
+				}
+				if deps.KeyManager != nil {
+					r.Get("/providers/keys", adminHandler.ListProviderKeys)
+					r.Put("/providers/{name}/keys", adminHandler.SetProviderKeys)
+				}
+				if deps.Drainer != nil {
+					drainHandler := handlers.NewDrainHandler(deps.Drainer, deps.Logger)
+					r.Get("/drain", drainHandler.DrainStatus)
+					r.Post("/drain", drainHandler.Drain)
+				}
+				if deps.Abuse != nil {
+					abuseHandler := handlers.NewAbuseHandler(deps.Abuse, deps.Logger)
+					r.Get("/abuse/flags", abuseHandler.ListFlags)
+					r.Delete("/abuse/flags/{key}", abuseHandler.ReleaseFlag)
+				}
+				if deps.Bulk != nil {
+					bulkHandler := handlers.NewBulkHandler(deps.Bulk, deps.Logger)
+					r.Post("/operations", bulkHandler.StartOperation)
+					r.Get("/operations", bulkHandler.ListOperations)
+					r.Get("/operations/{operationID}", bulkHandler.GetOperation)
+				}
+			})
+		}
 	})
 
 	return r
 }
+
+// NewWorkerRouter creates the router of a worker node, which serves only the
+// health check, metrics when enabled, and the drain endpoints with an admin key.
+func NewWorkerRouter(deps *RouterDeps) *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(middleware.Recoverer)
+	r.Use(middleware.GetHead)
+	r.Use(apimiddleware.Options)
+
+	r.Get("/api/v1/health", handlers.NewHealthHandler(deps.ProviderRegistry, deps.Logger).HealthCheck)
+	if deps.Metrics != nil {
+		r.Handle("/metrics", deps.Metrics.Handler())
+	}
+	if deps.AdminKey != "" && deps.Drainer != nil {
+		drainHandler := handlers.NewDrainHandler(deps.Drainer, deps.Logger)
+		r.Route("/api/v1/admin/drain", func(r chi.Router) {
+			r.Use(apimiddleware.NewClientAddr(deps.TrustedProxies))
+			r.Use(apimiddleware.NewAPIKeyAuth([]apimiddleware.APIKey{{Name: "admin", Key: deps.AdminKey}}))
+			r.Use(apimiddleware.NewIPFilter(deps.IPRules, deps.Logger))
+			r.Get("/", drainHandler.DrainStatus)
+			r.Post("/", drainHandler.Drain)
+		})
+	}
+	return r
+}
@@ -0,0 +1,248 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/api/handlers"
+	"github.com/pako-tts/server/internal/api/handlers/mocks"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/queue/memory"
+)
+
+func TestNewRouter_Features(t *testing.T) {
+	newRouter := func(features *Features) http.Handler {
+		return NewRouter(&RouterDeps{
+			Logger:           zap.NewNop(),
+			ProviderRegistry: mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"}),
+			Queue:            memory.NewQueue(10),
+			Storage:          mocks.NewMockStorage(),
+			AdminKey:         "admin-secret",
+			Features:         features,
+		})
+	}
+	status := func(router http.Handler, method, path string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader("{}"))
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	all := newRouter(nil)
+	asyncOnly := newRouter(&Features{AsyncJobs: true})
+	for _, tt := range []struct {
+		method, path string
+	}{
+		{http.MethodPost, "/api/v1/tts"},
+		{http.MethodPost, "/api/v1/tts/stream"},
+		{http.MethodGet, "/api/v1/admin/queue"},
+		{http.MethodGet, "/ui/"},
+	} {
+		if got := status(all, tt.method, tt.path); got == http.StatusNotFound {
+			t.Errorf("%s %s: expected it served with every feature on", tt.method, tt.path)
+		}
+		if got := status(asyncOnly, tt.method, tt.path); got != http.StatusNotFound {
+			t.Errorf("%s %s: expected 404 on an async-only router, got %d", tt.method, tt.path, got)
+		}
+	}
+
+	if got := status(asyncOnly, http.MethodGet, "/api/v1/jobs"); got != http.StatusOK {
+		t.Errorf("expected jobs served on an async-only router, got %d", got)
+	}
+	if got := status(newRouter(&Features{SyncTTS: true}), http.MethodGet, "/api/v1/jobs"); got != http.StatusNotFound {
+		t.Errorf("expected jobs off on a sync-only router, got %d", got)
+	}
+}
+
+func TestNewRouter_HeadAndOptions(t *testing.T) {
+	queue := memory.NewQueue(10)
+	storage := mocks.NewMockStorage()
+	router := NewRouter(&RouterDeps{
+		Logger:           zap.NewNop(),
+		ProviderRegistry: mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"}),
+		Queue:            queue,
+		Storage:          storage,
+	})
+
+	ctx := context.Background()
+	job := domain.NewJob("test text", "voice123", "", "", "test-provider", "mp3", nil)
+	queue.Enqueue(ctx, job) //nolint:errcheck
+	job.SetCompleted("/storage/"+job.ID+".mp3", 24)
+	queue.UpdateJob(ctx, job) //nolint:errcheck
+	storage.StoredFiles[job.ID] = []byte("fake audio content")
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	w := serve(http.MethodHead, "/api/v1/jobs/"+job.ID+"/result")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Length") != "18" || w.Header().Get("ETag") == "" || w.Body.Len() != 0 {
+		t.Errorf("expected HEAD to answer the result's headers without body, got %d %v %q", w.Code, w.Header(), w.Body.String())
+	}
+	if w := serve(http.MethodHead, "/api/v1/jobs/"+job.ID); w.Code != http.StatusOK {
+		t.Errorf("expected HEAD on the job status, got %d", w.Code)
+	}
+
+	for path, want := range map[string]string{
+		"/api/v1/jobs/" + job.ID + "/result": "GET, HEAD, DELETE, OPTIONS",
+		"/api/v1/jobs":                       "GET, HEAD, POST, OPTIONS",
+		"/api/v1/tts":                        "POST, OPTIONS",
+	} {
+		w := serve(http.MethodOptions, path)
+		if w.Code != http.StatusNoContent || w.Header().Get("Allow") != want {
+			t.Errorf("OPTIONS %s: expected 204 with Allow %q, got %d %q", path, want, w.Code, w.Header().Get("Allow"))
+		}
+	}
+	if w := serve(http.MethodOptions, "/api/v1/unknown"); w.Code != http.StatusNotFound {
+		t.Errorf("expected OPTIONS on an unknown path to answer 404, got %d", w.Code)
+	}
+	if w := serve(http.MethodPut, "/api/v1/jobs"); w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") == "" {
+		t.Errorf("expected 405 with Allow, got %d %v", w.Code, w.Header())
+	}
+
+	// CORS preflights are still answered by the CORS handler
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/jobs", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("expected a CORS preflight response, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestNewWorkerRouter(t *testing.T) {
+	router := NewWorkerRouter(&RouterDeps{
+		Logger:           zap.NewNop(),
+		ProviderRegistry: mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"}),
+	})
+	for path, want := range map[string]int{
+		"/api/v1/health": http.StatusOK,
+		"/api/v1/jobs":   http.StatusNotFound,
+		"/ui/":           http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != want {
+			t.Errorf("GET %s: expected %d, got %d", path, want, w.Code)
+		}
+	}
+}
+
+func TestRouters_Drain(t *testing.T) {
+	registry := mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"})
+	queue := memory.NewQueue(10)
+	newDeps := func() *RouterDeps {
+		return &RouterDeps{
+			Logger:           zap.NewNop(),
+			ProviderRegistry: registry,
+			Queue:            queue,
+			Storage:          mocks.NewMockStorage(),
+			AdminKey:         "admin-secret",
+			Drainer:          memory.NewWorker(queue, registry, mocks.NewMockStorage(), zap.NewNop(), 24, 0, nil, nil, memory.RetryPolicy{}),
+		}
+	}
+	do := func(router http.Handler, method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	api := NewRouter(newDeps())
+	if w := do(api, http.MethodPost, "/api/v1/admin/drain", "admin-secret", `{"strategy":"drop"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected an unknown strategy rejected, got %d", w.Code)
+	}
+	if w := do(api, http.MethodPost, "/api/v1/admin/drain", "admin-secret", `{"strategy":"shutdown","timeout":"1m"}`); w.Code != http.StatusAccepted {
+		t.Fatalf("expected the drain accepted, got %d: %s", w.Code, w.Body.String())
+	}
+	w := do(api, http.MethodPost, "/api/v1/jobs", "", `{"text":"hello"}`)
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "DRAINING") || w.Header().Get("Retry-After") == "" {
+		t.Errorf("expected jobs refused while draining, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do(api, http.MethodGet, "/api/v1/jobs", "", ""); w.Code != http.StatusOK {
+		t.Errorf("expected jobs still listed while draining, got %d", w.Code)
+	}
+
+	worker := NewWorkerRouter(newDeps())
+	if w := do(worker, http.MethodGet, "/api/v1/admin/drain", "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected the worker drain endpoint to need the admin key, got %d", w.Code)
+	}
+	w = do(worker, http.MethodGet, "/api/v1/admin/drain", "admin-secret", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"state":"running"`) {
+		t.Errorf("expected a running worker's drain status, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestNewRouter_JobSearch(t *testing.T) {
+	queue := memory.NewQueueWithOptions(10, memory.Options{Search: true})
+	queue.Enqueue(context.Background(), domain.NewJob("the Q3 report", "voice", "", "", "test-provider", "mp3", nil)) //nolint:errcheck
+	newRouter := func(search domain.JobSearch) http.Handler {
+		return NewRouter(&RouterDeps{
+			Logger:           zap.NewNop(),
+			ProviderRegistry: mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"}),
+			Queue:            queue,
+			Storage:          mocks.NewMockStorage(),
+			JobSearch:        search,
+		})
+	}
+	get := func(router http.Handler) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/jobs/search?q=report", nil))
+		return w
+	}
+
+	if w := get(newRouter(queue)); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"snippet":"the Q3 report"`) {
+		t.Errorf("expected the job found, got %d: %s", w.Code, w.Body.String())
+	}
+	// Without search the path is taken for a job ID
+	if w := get(newRouter(nil)); w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "JOB_NOT_FOUND") {
+		t.Errorf("expected search off without JobSearch, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestNewRouter_Meta(t *testing.T) {
+	router := NewRouter(&RouterDeps{
+		Logger:           zap.NewNop(),
+		ProviderRegistry: mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "test-provider"}),
+		Queue:            memory.NewQueue(10),
+		Storage:          mocks.NewMockStorage(),
+		SyncTimeout:      30 * time.Second,
+		MaxSyncTextLen:   5000,
+		RetentionHours:   24,
+		AsyncFallback:    true,
+		Features:         &Features{SyncTTS: true, AsyncJobs: true},
+	})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/meta", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var meta handlers.MetaResponse
+	if err := json.NewDecoder(w.Body).Decode(&meta); err != nil {
+		t.Fatalf("decode meta: %v", err)
+	}
+	if meta.Build.Version == "" || len(meta.APIVersions) == 0 || meta.Providers[0] != "test-provider" {
+		t.Errorf("unexpected meta %+v", meta)
+	}
+	if !meta.Features["async_fallback"] || meta.Features["admin"] || meta.Features["job_search"] {
+		t.Errorf("expected async fallback on and admin and search off, got %v", meta.Features)
+	}
+	if meta.Limits.MaxSyncTextLength != 5000 || meta.Limits.SyncTimeoutSeconds != 30 || meta.Limits.RetentionHours != 24 {
+		t.Errorf("expected the configured limits, got %+v", meta.Limits)
+	}
+}
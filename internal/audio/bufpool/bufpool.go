@@ -0,0 +1,105 @@
+// Package bufpool recycles the buffers audio is read into, so sustained load
+// doesn't allocate, and then collect, fresh multi-megabyte slices for every
+// synthesis.
+package bufpool
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// maxPooled is the capacity of the largest buffer kept for reuse. Larger ones,
+// from unusually long audio, are left to the garbage collector, so one
+// book-length job doesn't pin its memory for the life of the process.
+const maxPooled = 16 << 20
+
+var pool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// Get returns an empty buffer.
+func Get() *bytes.Buffer {
+	return pool.Get().(*bytes.Buffer)
+}
+
+// Put returns b for reuse. Nothing may use b or its bytes afterwards.
+func Put(b *bytes.Buffer) {
+	if b == nil || b.Cap() > maxPooled {
+		return
+	}
+	b.Reset()
+	pool.Put(b)
+}
+
+// ReadAll reads r to EOF into a pooled buffer, which the caller returns with Put
+// once nothing refers to its bytes.
+func ReadAll(r io.Reader) (*bytes.Buffer, error) {
+	b := Get()
+	if l, ok := r.(interface{ Len() int }); ok {
+		b.Grow(l.Len())
+	}
+	if _, err := b.ReadFrom(r); err != nil {
+		Put(b)
+		return nil, err
+	}
+	return b, nil
+}
+
+// Reader hands out the contents of a pooled buffer and returns the buffer to
+// the pool at EOF or on Close, whichever comes first. Since readers get copies,
+// this is safe whoever ends up reading it; a Reader that is dropped unread just
+// leaves its buffer to the garbage collector.
+type Reader struct {
+	b *bytes.Buffer
+}
+
+// NewReader returns a Reader over b, taking ownership of it.
+func NewReader(b *bytes.Buffer) *Reader {
+	return &Reader{b: b}
+}
+
+// Len returns the number of unread bytes.
+func (r *Reader) Len() int {
+	if r.b == nil {
+		return 0
+	}
+	return r.b.Len()
+}
+
+// Bytes returns the unread bytes without reading them. They are only valid
+// until the Reader is read to EOF or closed.
+func (r *Reader) Bytes() []byte {
+	if r.b == nil {
+		return nil
+	}
+	return r.b.Bytes()
+}
+
+// Read implements io.Reader.
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.b == nil {
+		return 0, io.EOF
+	}
+	n, err := r.b.Read(p)
+	if err == io.EOF {
+		r.Close() //nolint:errcheck
+	}
+	return n, err
+}
+
+// WriteTo implements io.WriterTo, so io.Copy writes the buffer without an
+// intermediate copy.
+func (r *Reader) WriteTo(w io.Writer) (int64, error) {
+	if r.b == nil {
+		return 0, nil
+	}
+	n, err := r.b.WriteTo(w)
+	r.Close() //nolint:errcheck
+	return n, err
+}
+
+// Close returns the buffer to the pool. It is safe to call more than once.
+func (r *Reader) Close() error {
+	Put(r.b)
+	r.b = nil
+	return nil
+}
@@ -0,0 +1,104 @@
+package bufpool
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// body hides the length of its data, like an HTTP response body.
+type body struct {
+	r io.Reader
+}
+
+func (b body) Read(p []byte) (int, error) { return b.r.Read(p) }
+
+func TestReadAll_RoundTrip(t *testing.T) {
+	audio := bytes.Repeat([]byte("audio"), 1000)
+	b, err := ReadAll(body{bytes.NewReader(audio)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewReader(b)
+	if r.Len() != len(audio) {
+		t.Errorf("Len = %d, want %d", r.Len(), len(audio))
+	}
+
+	var out bytes.Buffer
+	if _, err := io.Copy(&out, r); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), audio) {
+		t.Error("audio changed on the way through")
+	}
+	if r.b != nil {
+		t.Error("expected the buffer to be released after the copy")
+	}
+	if n, err := r.Read(make([]byte, 10)); n != 0 || err != io.EOF {
+		t.Errorf("Read after release = %d, %v", n, err)
+	}
+	if err := r.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
+func TestReadAll_Error(t *testing.T) {
+	boom := errors.New("boom")
+	if _, err := ReadAll(io.MultiReader(bytes.NewReader([]byte("partial")), errReader{boom})); !errors.Is(err, boom) {
+		t.Errorf("expected the read error, got %v", err)
+	}
+}
+
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+
+func TestPut_DropsOversizedBuffers(t *testing.T) {
+	b := Get()
+	b.Grow(maxPooled + 1)
+	Put(b) // must not panic or keep the buffer; nothing observable beyond that
+	Put(nil)
+}
+
+// BenchmarkAudioPath follows the audio of an async job: the provider reads the
+// upstream response, the worker reads the provider's result and stores it.
+func BenchmarkAudioPath(b *testing.B) {
+	for _, size := range []int{200 << 10, 4 << 20} {
+		audio := bytes.Repeat([]byte{0x55}, size)
+
+		b.Run(fmt.Sprintf("%dKB/io.ReadAll", size>>10), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(size))
+			for b.Loop() {
+				data, err := io.ReadAll(body{bytes.NewReader(audio)})
+				if err != nil {
+					b.Fatal(err)
+				}
+				stored, err := io.ReadAll(bytes.NewReader(data))
+				if err != nil {
+					b.Fatal(err)
+				}
+				io.Discard.Write(stored) //nolint:errcheck
+			}
+		})
+
+		b.Run(fmt.Sprintf("%dKB/bufpool", size>>10), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(size))
+			for b.Loop() {
+				data, err := ReadAll(body{bytes.NewReader(audio)})
+				if err != nil {
+					b.Fatal(err)
+				}
+				stored, err := ReadAll(NewReader(data))
+				if err != nil {
+					b.Fatal(err)
+				}
+				io.Discard.Write(stored.Bytes()) //nolint:errcheck
+				Put(stored)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+// Package effects applies server-side post-processing to synthesized audio, so that
+// settings such as speed and pitch behave the same regardless of the provider, and
+// results can be padded with silence and faded in/out.
+package effects
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pako-tts/server/internal/audio/transcode"
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// Supported adjustment ranges.
+const (
+	MinSpeed          = 0.5
+	MaxSpeed          = 2.0
+	MaxPitchSemitones = 12.0
+
+	// MaxPaddingMs caps lead-in/lead-out silence; MaxFadeMs caps fade durations.
+	MaxPaddingMs = 10000
+	MaxFadeMs    = 5000
+)
+
+// ffmpegBinary is the name or path of the ffmpeg executable.
+// Tests may override this to exercise the missing-binary error path without mutating PATH.
+var ffmpegBinary = "ffmpeg"
+
+// ErrUnsupportedInput is returned when the audio container can't be processed
+// (e.g. headerless PCM whose sample rate is unknown).
+var ErrUnsupportedInput = errors.New("effects: unsupported audio input")
+
+// Options describes the post-processing applied to a synthesis result.
+// Zero values mean "unchanged".
+type Options struct {
+	// Speed is the tempo factor (1.0 = unchanged). Pitch is preserved.
+	Speed float64
+	// Pitch is the shift in semitones (0 = unchanged). Tempo is preserved.
+	Pitch float64
+	// LeadIn and LeadOut add silence before and after the speech.
+	LeadIn  time.Duration
+	LeadOut time.Duration
+	// FadeIn and FadeOut ramp the speech in and out (applied before padding).
+	FadeIn  time.Duration
+	FadeOut time.Duration
+}
+
+// IsZero reports whether the options leave the audio untouched.
+func (o Options) IsZero() bool {
+	return (o.Speed == 0 || o.Speed == 1) && o.Pitch == 0 &&
+		o.LeadIn == 0 && o.LeadOut == 0 && o.FadeIn == 0 && o.FadeOut == 0
+}
+
+// WithPadding returns a copy of o with the silence and fade settings from p.
+func (o Options) WithPadding(p *domain.PaddingOptions) Options {
+	if p == nil {
+		return o
+	}
+	o.LeadIn = time.Duration(p.LeadInMs) * time.Millisecond
+	o.LeadOut = time.Duration(p.LeadOutMs) * time.Millisecond
+	o.FadeIn = time.Duration(p.FadeInMs) * time.Millisecond
+	o.FadeOut = time.Duration(p.FadeOutMs) * time.Millisecond
+	return o
+}
+
+// nativeSpeed is implemented by providers that can render a speed factor themselves.
+type nativeSpeed interface {
+	SupportsSpeed(speed float64) bool
+}
+
+// nativePitch is implemented by providers that can render a pitch shift themselves.
+type nativePitch interface {
+	SupportsPitch(semitones float64) bool
+}
+
+// Plan splits the requested speed/pitch between the provider and the server.
+// Adjustments the provider renders natively stay in the returned settings; the rest
+// are removed from them and returned as Options to apply after synthesis. When the
+// request opts out with native_only, the settings pass through untouched and no
+// server-side processing is planned.
+func Plan(provider domain.TTSProvider, settings *domain.VoiceSettings) (Options, *domain.VoiceSettings) {
+	var opts Options
+	if settings == nil || (settings.NativeOnly != nil && *settings.NativeOnly) {
+		return opts, settings
+	}
+
+	adjusted := *settings
+	if s := settings.Speed; s != nil && *s != 1 {
+		if np, ok := provider.(nativeSpeed); !ok || !np.SupportsSpeed(*s) {
+			opts.Speed = *s
+			adjusted.Speed = nil
+		}
+	}
+	if p := settings.Pitch; p != nil && *p != 0 {
+		if np, ok := provider.(nativePitch); !ok || !np.SupportsPitch(*p) {
+			opts.Pitch = *p
+			adjusted.Pitch = nil
+		}
+	}
+	return opts, &adjusted
+}
+
+// Apply runs the audio through ffmpeg with the filters described by opts and returns
+// audio in the same format ("mp3" or "wav"). Tempo changes use atempo (WSOLA) and
+// pitch shifts use rubberband, both of which preserve the other dimension; fades and
+// silence padding run last.
+func Apply(ctx context.Context, audio []byte, format string, opts Options) ([]byte, error) {
+	if opts.IsZero() {
+		return audio, nil
+	}
+	return Filter(ctx, audio, format, filters(opts))
+}
+
+// Filter runs the audio through an ffmpeg audio filter chain and returns audio in
+// the same format ("mp3" or "wav").
+func Filter(ctx context.Context, audio []byte, format string, chain []string) ([]byte, error) {
+	args := []string{"-hide_banner", "-loglevel", "error", "-i", "pipe:0", "-af", strings.Join(chain, ",")}
+
+	var sampleRate, channels int
+	switch format {
+	case "mp3":
+		args = append(args, "-f", "mp3", "-b:a", "128k")
+	case "wav":
+		var ok bool
+		_, sampleRate, channels, _, ok = transcode.ParseWAV(audio)
+		if !ok {
+			return nil, ErrUnsupportedInput
+		}
+		// WAV written to a pipe has no final sizes, so emit raw PCM and wrap it ourselves.
+		args = append(args, "-f", "s16le", "-acodec", "pcm_s16le",
+			"-ar", strconv.Itoa(sampleRate), "-ac", strconv.Itoa(channels))
+	default:
+		return nil, ErrUnsupportedInput
+	}
+	args = append(args, "pipe:1")
+
+	cmd := exec.CommandContext(ctx, ffmpegBinary, args...)
+	cmd.Stdin = bytes.NewReader(audio)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w: %s", err, stderr.String())
+	}
+
+	if format == "wav" {
+		return transcode.PCMToWAV(out, sampleRate, channels, 16), nil
+	}
+	return out, nil
+}
+
+// filters builds the ffmpeg audio filter chain for opts.
+func filters(opts Options) []string {
+	var chain []string
+	if opts.Pitch != 0 {
+		ratio := math.Pow(2, opts.Pitch/12)
+		chain = append(chain, "rubberband=pitch="+strconv.FormatFloat(ratio, 'f', 6, 64))
+	}
+	if opts.Speed != 0 && opts.Speed != 1 {
+		chain = append(chain, "atempo="+strconv.FormatFloat(opts.Speed, 'f', -1, 64))
+	}
+	if opts.FadeIn > 0 {
+		chain = append(chain, "afade=t=in:d="+seconds(opts.FadeIn))
+	}
+	if opts.FadeOut > 0 {
+		// The total length isn't known up front, so fade the reversed stream in.
+		chain = append(chain, "areverse", "afade=t=in:d="+seconds(opts.FadeOut), "areverse")
+	}
+	if opts.LeadIn > 0 {
+		chain = append(chain, "adelay=delays="+strconv.FormatInt(opts.LeadIn.Milliseconds(), 10)+":all=1")
+	}
+	if opts.LeadOut > 0 {
+		chain = append(chain, "apad=pad_dur="+seconds(opts.LeadOut))
+	}
+	return chain
+}
+
+// seconds formats d for ffmpeg duration options.
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
@@ -0,0 +1,120 @@
+package effects
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// stubProvider is a minimal domain.TTSProvider without native speed/pitch support.
+type stubProvider struct{ domain.TTSProvider }
+
+// speedProvider renders speeds between 0.7 and 1.2 natively.
+type speedProvider struct{ stubProvider }
+
+func (speedProvider) SupportsSpeed(speed float64) bool { return speed >= 0.7 && speed <= 1.2 }
+
+func ptr(v float64) *float64 { return &v }
+
+func TestPlan(t *testing.T) {
+	yes := true
+	tests := []struct {
+		name      string
+		provider  domain.TTSProvider
+		settings  *domain.VoiceSettings
+		wantOpts  Options
+		wantSpeed *float64
+		wantPitch *float64
+	}{
+		{"nil settings", stubProvider{}, nil, Options{}, nil, nil},
+		{"unit speed stays with provider", stubProvider{}, &domain.VoiceSettings{Speed: ptr(1)}, Options{}, ptr(1), nil},
+		{"no native support", stubProvider{}, &domain.VoiceSettings{Speed: ptr(1.5), Pitch: ptr(2)}, Options{Speed: 1.5, Pitch: 2}, nil, nil},
+		{"native speed in range", speedProvider{}, &domain.VoiceSettings{Speed: ptr(1.1)}, Options{}, ptr(1.1), nil},
+		{"native speed out of range", speedProvider{}, &domain.VoiceSettings{Speed: ptr(1.8)}, Options{Speed: 1.8}, nil, nil},
+		{"native only opt-out", stubProvider{}, &domain.VoiceSettings{Speed: ptr(1.5), NativeOnly: &yes}, Options{}, ptr(1.5), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, settings := Plan(tt.provider, tt.settings)
+			if opts != tt.wantOpts {
+				t.Errorf("expected options %+v, got %+v", tt.wantOpts, opts)
+			}
+			if settings == nil {
+				if tt.settings != nil {
+					t.Fatal("expected settings, got nil")
+				}
+				return
+			}
+			if !equalPtr(settings.Speed, tt.wantSpeed) {
+				t.Errorf("expected provider speed %v, got %v", tt.wantSpeed, settings.Speed)
+			}
+			if !equalPtr(settings.Pitch, tt.wantPitch) {
+				t.Errorf("expected provider pitch %v, got %v", tt.wantPitch, settings.Pitch)
+			}
+		})
+	}
+}
+
+func equalPtr(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func TestFilters(t *testing.T) {
+	got := strings.Join(filters(Options{Speed: 1.25, Pitch: 12}), ",")
+	want := "rubberband=pitch=2.000000,atempo=1.25"
+	if got != want {
+		t.Errorf("expected filter chain %q, got %q", want, got)
+	}
+}
+
+func TestApply_ZeroOptionsIsPassthrough(t *testing.T) {
+	in := []byte("audio")
+	out, err := Apply(context.Background(), in, "mp3", Options{Speed: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(out) != "audio" {
+		t.Errorf("expected unchanged audio, got %q", out)
+	}
+}
+
+func TestApply_HeaderlessWAVIsUnsupported(t *testing.T) {
+	_, err := Apply(context.Background(), make([]byte, 100), "wav", Options{Speed: 1.5})
+	if err != ErrUnsupportedInput {
+		t.Errorf("expected ErrUnsupportedInput, got %v", err)
+	}
+}
+
+// TestApply_MissingBinary exercises the error path when the ffmpeg binary cannot be found.
+// This test must NOT run in parallel because it mutates the package-level ffmpegBinary variable.
+func TestApply_MissingBinary(t *testing.T) {
+	original := ffmpegBinary
+	ffmpegBinary = "/nonexistent/path/to/ffmpeg"
+	defer func() { ffmpegBinary = original }()
+
+	_, err := Apply(context.Background(), []byte("audio"), "mp3", Options{Speed: 1.5})
+	if err == nil {
+		t.Fatal("expected error for missing ffmpeg binary, got nil")
+	}
+	if !strings.Contains(err.Error(), "ffmpeg") {
+		t.Errorf("expected error to mention ffmpeg, got: %v", err)
+	}
+}
+
+func TestFilters_Padding(t *testing.T) {
+	opts := Options{}.WithPadding(&domain.PaddingOptions{LeadInMs: 300, LeadOutMs: 500, FadeInMs: 50, FadeOutMs: 100})
+	got := strings.Join(filters(opts), ",")
+	want := "afade=t=in:d=0.05,areverse,afade=t=in:d=0.1,areverse,adelay=delays=300:all=1,apad=pad_dur=0.5"
+	if got != want {
+		t.Errorf("expected filter chain %q, got %q", want, got)
+	}
+	if opts.IsZero() {
+		t.Error("expected padding options to be non-zero")
+	}
+}
@@ -0,0 +1,73 @@
+// Package quality measures synthesized audio, its duration and levels, so tests
+// can hold pipeline output to expectations without anyone listening to it.
+package quality
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/pako-tts/server/internal/audio/transcode"
+	"github.com/pako-tts/server/internal/audio/validate"
+)
+
+// decodeSampleRate is the rate compressed audio is decoded at for measuring levels.
+const decodeSampleRate = 16000
+
+// ErrUnsupportedInput is returned for audio whose samples can't be read (headerless PCM).
+var ErrUnsupportedInput = errors.New("quality: unsupported audio input")
+
+// Report describes a piece of audio.
+type Report struct {
+	Duration time.Duration
+	// PeakDB is the sample peak and RMSDB the RMS level over the whole audio, in
+	// dBFS; both are -Inf for digital silence.
+	PeakDB float64
+	RMSDB  float64
+}
+
+// Measure checks that audio is valid audio of format ("mp3" or "wav") and
+// measures it. 16-bit WAV is read directly; everything else is decoded with ffmpeg.
+func Measure(ctx context.Context, audio []byte, format string) (Report, error) {
+	duration, err := validate.Check(audio, format)
+	if err != nil {
+		return Report{}, err
+	}
+
+	var pcm []byte
+	if format == "wav" {
+		data, _, _, bits, ok := transcode.ParseWAV(audio)
+		if !ok {
+			return Report{}, ErrUnsupportedInput
+		}
+		if bits == 16 {
+			pcm = data
+		}
+	}
+	if pcm == nil {
+		if pcm, err = transcode.DecodeToPCM(ctx, audio, decodeSampleRate); err != nil {
+			return Report{}, err
+		}
+	}
+
+	peak, rms := Levels(pcm)
+	return Report{Duration: duration, PeakDB: peak, RMSDB: rms}, nil
+}
+
+// Levels returns the sample peak and RMS level, in dBFS, of 16-bit little-endian
+// PCM. Interleaved channels are measured together.
+func Levels(pcm []byte) (peakDB, rmsDB float64) {
+	var peak, sum float64
+	n := len(pcm) / 2
+	for i := range n {
+		v := float64(int16(binary.LittleEndian.Uint16(pcm[2*i:]))) / 32768
+		peak = max(peak, math.Abs(v))
+		sum += v * v
+	}
+	if n == 0 {
+		return math.Inf(-1), math.Inf(-1)
+	}
+	return 20 * math.Log10(peak), 20 * math.Log10(math.Sqrt(sum/float64(n)))
+}
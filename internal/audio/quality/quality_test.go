@@ -0,0 +1,66 @@
+package quality
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/pako-tts/server/internal/audio/transcode"
+	"github.com/pako-tts/server/internal/audio/validate"
+)
+
+// sine returns seconds of a 16-bit sine at amplitude (0-1 of full scale).
+func sine(sampleRate int, seconds, amplitude float64) []byte {
+	n := int(float64(sampleRate) * seconds)
+	out := make([]byte, 2*n)
+	for i := range n {
+		v := amplitude * 32767 * math.Sin(2*math.Pi*440*float64(i)/float64(sampleRate))
+		binary.LittleEndian.PutUint16(out[2*i:], uint16(int16(v)))
+	}
+	return out
+}
+
+func TestMeasure_WAV(t *testing.T) {
+	// Half a second of a half-scale sine, then half a second of silence
+	pcm := append(sine(16000, 0.5, 0.5), make([]byte, 16000)...)
+
+	report, err := Measure(context.Background(), transcode.PCMToWAV(pcm, 16000, 1, 16), "wav")
+	if err != nil {
+		t.Fatalf("Measure: %v", err)
+	}
+	if report.Duration != time.Second {
+		t.Errorf("expected 1s, got %v", report.Duration)
+	}
+	// A half-scale sine peaks at -6 dBFS and has an RMS of -9 dBFS; silence over
+	// half of the audio takes another 3 dB off the RMS.
+	if math.Abs(report.PeakDB-(-6.02)) > 0.1 {
+		t.Errorf("expected a peak of -6 dBFS, got %.2f", report.PeakDB)
+	}
+	if math.Abs(report.RMSDB-(-12.04)) > 0.1 {
+		t.Errorf("expected an RMS of -12 dBFS, got %.2f", report.RMSDB)
+	}
+}
+
+func TestMeasure_Silence(t *testing.T) {
+	report, err := Measure(context.Background(), transcode.PCMToWAV(make([]byte, 3200), 16000, 1, 16), "wav")
+	if err != nil {
+		t.Fatalf("Measure: %v", err)
+	}
+	if !math.IsInf(report.PeakDB, -1) || !math.IsInf(report.RMSDB, -1) {
+		t.Errorf("expected -Inf levels for silence, got peak %v, RMS %v", report.PeakDB, report.RMSDB)
+	}
+}
+
+func TestMeasure_RejectsInvalidAudio(t *testing.T) {
+	_, err := Measure(context.Background(), []byte(`{"error":"quota exceeded"}`), "wav")
+	if !errors.Is(err, validate.ErrInvalid) {
+		t.Errorf("expected validate.ErrInvalid, got %v", err)
+	}
+	_, err = Measure(context.Background(), sine(16000, 0.1, 0.5), "wav")
+	if !errors.Is(err, ErrUnsupportedInput) {
+		t.Errorf("expected ErrUnsupportedInput for headerless PCM, got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+)
+
+// Concat joins audio streams of format ("mp3", "wav" or "mulaw") into one, as
+// produced when a long text is synthesized in chunks. MP3 streams are joined
+// frame to frame, without the tags between them. WAV streams must share their
+// sample format, and are joined into one with a canonical header; headerless PCM
+// and mulaw streams are joined as they are.
+func Concat(parts [][]byte, format string) ([]byte, error) {
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+
+	switch format {
+	case "mp3":
+		var out []byte
+		for i, part := range parts {
+			if i > 0 {
+				part = skipID3v2(part)
+			}
+			if i < len(parts)-1 && len(part) >= 128 && string(part[len(part)-128:len(part)-125]) == "TAG" {
+				part = part[:len(part)-128] // ID3v1 tag
+			}
+			out = append(out, part...)
+		}
+		return out, nil
+
+	case "wav":
+		_, sampleRate, channels, bitsPerSample, header := ParseWAV(parts[0])
+		var pcm []byte
+		for i, part := range parts {
+			data, rate, ch, bits, ok := ParseWAV(part)
+			switch {
+			case ok != header:
+				return nil, fmt.Errorf("part %d and part 0 differ in having a WAV header", i)
+			case !ok:
+				data = part
+			case rate != sampleRate || ch != channels || bits != bitsPerSample:
+				return nil, fmt.Errorf("part %d is %d Hz, %d channels, %d bits; part 0 is %d Hz, %d channels, %d bits",
+					i, rate, ch, bits, sampleRate, channels, bitsPerSample)
+			}
+			pcm = append(pcm, data...)
+		}
+		if !header {
+			return pcm, nil
+		}
+		return PCMToWAV(pcm, sampleRate, channels, bitsPerSample), nil
+
+	case "mulaw":
+		return bytes.Join(parts, nil), nil
+	}
+	return nil, fmt.Errorf("joining %s audio is not supported", format)
+}
+
+// skipID3v2 returns an MP3 stream without its leading ID3v2 tag.
+func skipID3v2(audio []byte) []byte {
+	if len(audio) < 10 || string(audio[0:3]) != "ID3" {
+		return audio
+	}
+	size := 10 + (int(audio[6]&0x7F)<<21 | int(audio[7]&0x7F)<<14 | int(audio[8]&0x7F)<<7 | int(audio[9]&0x7F))
+	if audio[5]&0x10 != 0 {
+		size += 10 // footer
+	}
+	return audio[min(size, len(audio)):]
+}
+
+// joinSampleRate is the rate JoinAny decodes streams Concat can't join to.
+const joinSampleRate = 48000
+
+// JoinAny joins audio streams of format into one. mp3, wav and mulaw streams
+// are joined by Concat; streams of the other formats are decoded to PCM via
+// ffmpeg, joined and encoded again.
+func JoinAny(ctx context.Context, parts [][]byte, format string) ([]byte, error) {
+	if format == "mp3" || format == "wav" || format == "mulaw" || len(parts) == 1 {
+		return Concat(parts, format)
+	}
+	var pcm []byte
+	for i, part := range parts {
+		decoded, err := DecodeToPCM(ctx, part, joinSampleRate)
+		if err != nil {
+			return nil, fmt.Errorf("decode part %d: %w", i, err)
+		}
+		pcm = append(pcm, decoded...)
+	}
+	return Convert(ctx, PCMToWAV(pcm, joinSampleRate, 1, 16), format)
+}
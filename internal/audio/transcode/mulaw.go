@@ -0,0 +1,36 @@
+package transcode
+
+import "encoding/binary"
+
+// MulawSampleRate is the sample rate of "mulaw" audio: 8 kHz mono G.711, as
+// telephony carries it.
+const MulawSampleRate = 8000
+
+// MulawToWAV expands headerless G.711 μ-law audio, as Convert produces for
+// "mulaw", to 16-bit mono WAV.
+func MulawToWAV(mulaw []byte) []byte {
+	pcm := make([]byte, 2*len(mulaw))
+	for i, b := range mulaw {
+		binary.LittleEndian.PutUint16(pcm[2*i:], uint16(decodeMulaw(b)))
+	}
+	return PCMToWAV(pcm, MulawSampleRate, 1, 16)
+}
+
+// decodeMulaw returns the linear 16-bit sample a μ-law byte codes.
+func decodeMulaw(b byte) int16 {
+	b = ^b
+	magnitude := (int16(b&0x0F)<<3 + 0x84) << ((b & 0x70) >> 4)
+	if b&0x80 != 0 {
+		return 0x84 - magnitude
+	}
+	return magnitude - 0x84
+}
+
+// Decodable returns audio of format in a form ffmpeg can read: headerless mulaw
+// is expanded to WAV, and the other formats are returned as they are.
+func Decodable(audio []byte, format string) []byte {
+	if format == "mulaw" {
+		return MulawToWAV(audio)
+	}
+	return audio
+}
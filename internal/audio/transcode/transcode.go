@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"os/exec"
+	"slices"
 	"strconv"
 )
 
@@ -36,3 +37,132 @@ func PCMToMP3(ctx context.Context, pcm []byte, sampleRate, channels int) ([]byte
 	}
 	return out, nil
 }
+
+// Preview cuts the first seconds of an MP3 or WAV stream into a small mono MP3 clip
+// (32 kbps) suitable for instant playback in list views.
+func Preview(ctx context.Context, audio []byte, seconds int) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, ffmpegBinary,
+		"-i", "pipe:0",
+		"-t", strconv.Itoa(seconds),
+		"-ac", "1",
+		"-f", "mp3",
+		"-b:a", "32k",
+		"pipe:1",
+	)
+	cmd.Stdin = bytes.NewReader(audio)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w: %s", err, stderr.String())
+	}
+	return out, nil
+}
+
+// DecodeToPCM decodes an MP3 or WAV stream to mono 16-bit signed little-endian PCM
+// at sampleRate via ffmpeg.
+func DecodeToPCM(ctx context.Context, audio []byte, sampleRate int) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, ffmpegBinary,
+		"-i", "pipe:0",
+		"-ac", "1",
+		"-ar", strconv.Itoa(sampleRate),
+		"-f", "s16le",
+		"pipe:1",
+	)
+	cmd.Stdin = bytes.NewReader(audio)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w: %s", err, stderr.String())
+	}
+	return out, nil
+}
+
+// OutputFormats are the formats jobs and synchronous requests can ask for.
+// Providers produce mp3 and wav; the others are transcoded from wav.
+var OutputFormats = []string{"mp3", "wav", "ogg_opus", "flac", "mulaw"}
+
+// IsOutputFormat reports whether format is one of OutputFormats.
+func IsOutputFormat(format string) bool {
+	return slices.Contains(OutputFormats, format)
+}
+
+// SynthesisFormat returns the format audio for a result in format is synthesized
+// and post-processed in: format itself for mp3 and wav, and wav, which loses
+// nothing before the final encoding, for the formats transcoded from it.
+func SynthesisFormat(format string) string {
+	if format == "mp3" {
+		return format
+	}
+	return "wav"
+}
+
+// encoderArgs are the ffmpeg output options for each format Convert can produce.
+var encoderArgs = map[string][]string{
+	"mp3":      {"-f", "mp3", "-b:a", "128k"},
+	"wav":      {"-f", "wav", "-c:a", "pcm_s16le"},
+	"ogg":      {"-f", "ogg", "-c:a", "libopus", "-b:a", "64k"},
+	"ogg_opus": {"-f", "ogg", "-c:a", "libopus", "-b:a", "64k"},
+	"flac":     {"-f", "flac", "-c:a", "flac"},
+	"mulaw":    {"-f", "mulaw", "-ar", "8000", "-ac", "1"},
+}
+
+// contentTypes maps formats Convert can produce to their MIME types.
+var contentTypes = map[string]string{
+	"mp3":      "audio/mpeg",
+	"wav":      "audio/wav",
+	"ogg":      "audio/ogg",
+	"ogg_opus": "audio/ogg",
+	"flac":     "audio/flac",
+	"mulaw":    "audio/basic",
+}
+
+// CanConvertTo reports whether Convert can produce format.
+func CanConvertTo(format string) bool {
+	_, ok := encoderArgs[format]
+	return ok
+}
+
+// ContentType returns the MIME type of format, or "application/octet-stream".
+func ContentType(format string) string {
+	if ct, ok := contentTypes[format]; ok {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+// Extension returns the file extension of format: "opus" for ogg_opus, and the
+// format's name for the others.
+func Extension(format string) string {
+	if format == "ogg_opus" {
+		return "opus"
+	}
+	return format
+}
+
+// Convert re-encodes an MP3 or WAV stream to format ("mp3", "wav", "flac",
+// "mulaw", which is headerless 8 kHz mono G.711 μ-law, or "ogg" and "ogg_opus",
+// which are both Opus in an Ogg container) via ffmpeg. Pass mulaw audio through
+// Decodable first.
+func Convert(ctx context.Context, audio []byte, format string) ([]byte, error) {
+	args, ok := encoderArgs[format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported target format %q", format)
+	}
+	cmd := exec.CommandContext(ctx, ffmpegBinary, append(append([]string{"-i", "pipe:0"}, args...), "pipe:1")...)
+	cmd.Stdin = bytes.NewReader(audio)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w: %s", err, stderr.String())
+	}
+	return out, nil
+}
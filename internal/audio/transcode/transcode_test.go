@@ -1,6 +1,7 @@
 package transcode
 
 import (
+	"bytes"
 	"context"
 	"encoding/binary"
 	"strings"
@@ -186,3 +187,143 @@ func TestPCMToMP3_MissingBinary(t *testing.T) {
 		t.Errorf("expected error to mention ffmpeg, got: %v", err)
 	}
 }
+
+// TestPreview_MissingBinary exercises the error path when the ffmpeg binary cannot be found.
+// This test must NOT run in parallel because it mutates the package-level ffmpegBinary variable.
+func TestPreview_MissingBinary(t *testing.T) {
+	original := ffmpegBinary
+	ffmpegBinary = "/nonexistent/path/to/ffmpeg"
+	defer func() { ffmpegBinary = original }()
+
+	_, err := Preview(context.Background(), PCMToWAV(oneSec24kHzMono16Bit(), 24000, 1, 16), 10)
+	if err == nil {
+		t.Fatal("expected error for missing ffmpeg binary, got nil")
+	}
+	if !strings.Contains(err.Error(), "ffmpeg") {
+		t.Errorf("expected error to mention ffmpeg, got: %v", err)
+	}
+}
+
+func TestConvert_UnsupportedFormat(t *testing.T) {
+	if CanConvertTo("aac") {
+		t.Fatal("expected aac to be unsupported")
+	}
+	if _, err := Convert(context.Background(), []byte("audio"), "aac"); err == nil {
+		t.Fatal("expected error for unsupported format, got nil")
+	}
+	if got := ContentType("ogg"); got != "audio/ogg" {
+		t.Errorf("ContentType(ogg) = %q, want audio/ogg", got)
+	}
+}
+
+func TestOutputFormats(t *testing.T) {
+	tests := []struct {
+		format, synthesis, contentType, extension string
+	}{
+		{"mp3", "mp3", "audio/mpeg", "mp3"},
+		{"wav", "wav", "audio/wav", "wav"},
+		{"ogg_opus", "wav", "audio/ogg", "opus"},
+		{"flac", "wav", "audio/flac", "flac"},
+		{"mulaw", "wav", "audio/basic", "mulaw"},
+	}
+	for _, tt := range tests {
+		if !IsOutputFormat(tt.format) {
+			t.Errorf("expected %s to be an output format", tt.format)
+		}
+		if got := SynthesisFormat(tt.format); got != tt.synthesis {
+			t.Errorf("SynthesisFormat(%s) = %q, want %q", tt.format, got, tt.synthesis)
+		}
+		if got := ContentType(tt.format); got != tt.contentType {
+			t.Errorf("ContentType(%s) = %q, want %q", tt.format, got, tt.contentType)
+		}
+		if got := Extension(tt.format); got != tt.extension {
+			t.Errorf("Extension(%s) = %q, want %q", tt.format, got, tt.extension)
+		}
+	}
+	if IsOutputFormat("ogg") {
+		t.Error("expected ogg to be a result variant only, not an output format")
+	}
+}
+
+func TestMulawToWAV(t *testing.T) {
+	// Silence, the loudest negative and the loudest positive code
+	pcm, sampleRate, channels, bits, ok := ParseWAV(MulawToWAV([]byte{0xFF, 0x00, 0x80}))
+	if !ok || sampleRate != MulawSampleRate || channels != 1 || bits != 16 {
+		t.Fatalf("ParseWAV = %d Hz, %d channels, %d bits, %v", sampleRate, channels, bits, ok)
+	}
+	for i, want := range []int16{0, -32124, 32124} {
+		if got := int16(binary.LittleEndian.Uint16(pcm[2*i:])); got != want {
+			t.Errorf("sample %d = %d, want %d", i, got, want)
+		}
+	}
+	if mp3 := []byte{0xFF, 0xFB}; !bytes.Equal(Decodable(mp3, "mp3"), mp3) {
+		t.Error("expected Decodable to leave mp3 unchanged")
+	}
+}
+
+func TestParseWAV(t *testing.T) {
+	pcm := []byte{1, 2, 3, 4}
+	got, rate, channels, bits, ok := ParseWAV(PCMToWAV(pcm, 22050, 2, 16))
+	if !ok {
+		t.Fatal("expected a valid WAV")
+	}
+	if rate != 22050 || channels != 2 || bits != 16 {
+		t.Errorf("expected 22050 Hz / 2 ch / 16 bit, got %d Hz / %d ch / %d bit", rate, channels, bits)
+	}
+	if string(got) != string(pcm) {
+		t.Errorf("expected PCM %v, got %v", pcm, got)
+	}
+
+	if _, _, _, _, ok := ParseWAV(pcm); ok {
+		t.Error("expected headerless PCM to be rejected")
+	}
+}
+
+func TestWAVHeader_UnknownLength(t *testing.T) {
+	pcm := []byte{1, 2, 3, 4}
+	got, rate, _, _, ok := ParseWAV(append(WAVHeader(-1, 22050, 1, 16), pcm...))
+	if !ok || rate != 22050 || string(got) != string(pcm) {
+		t.Errorf("expected the PCM up to the end at 22050 Hz, got %v at %d Hz (ok=%v)", got, rate, ok)
+	}
+}
+
+func TestConcat_WAV(t *testing.T) {
+	a := PCMToWAV([]byte{1, 2, 3, 4}, 16000, 1, 16)
+	b := PCMToWAV([]byte{5, 6}, 16000, 1, 16)
+
+	out, err := Concat([][]byte{a, b}, "wav")
+	if err != nil {
+		t.Fatalf("Concat: %v", err)
+	}
+	pcm, rate, channels, bits, ok := ParseWAV(out)
+	if !ok || rate != 16000 || channels != 1 || bits != 16 {
+		t.Fatalf("ParseWAV = %d Hz, %d channels, %d bits, ok %v", rate, channels, bits, ok)
+	}
+	if string(pcm) != "\x01\x02\x03\x04\x05\x06" {
+		t.Errorf("pcm = %v, want the parts' samples in order", pcm)
+	}
+
+	if _, err := Concat([][]byte{a, PCMToWAV([]byte{5, 6}, 24000, 1, 16)}, "wav"); err == nil {
+		t.Error("Concat of different sample rates succeeded")
+	}
+	if _, err := Concat([][]byte{a, {5, 6}}, "wav"); err == nil {
+		t.Error("Concat of WAV and headerless PCM succeeded")
+	}
+}
+
+func TestConcat_MP3(t *testing.T) {
+	frame := []byte{0xFF, 0xFB, 0x90, 0x00}
+	tagged := append([]byte("ID3\x04\x00\x00\x00\x00\x00\x02ab"), frame...)
+
+	out, err := Concat([][]byte{tagged, tagged}, "mp3")
+	if err != nil {
+		t.Fatalf("Concat: %v", err)
+	}
+	if want := string(tagged) + string(frame); string(out) != want {
+		t.Errorf("Concat = %q, want %q", out, want)
+	}
+
+	if _, err := Concat([][]byte{frame, frame}, "ogg"); err == nil {
+		t.Error("Concat of an unsupported format succeeded")
+	}
+}
@@ -5,13 +5,26 @@ import "encoding/binary"
 // PCMToWAV wraps raw PCM data in a RIFF/WAVE container with a canonical 44-byte header.
 // Parameters must match the PCM stream: sampleRate in Hz, channels (1=mono), bitsPerSample (typically 16).
 func PCMToWAV(pcm []byte, sampleRate, channels, bitsPerSample int) []byte {
-	dataSize := uint32(len(pcm))
+	result := make([]byte, 44+len(pcm))
+	copy(result[0:44], WAVHeader(len(pcm), sampleRate, channels, bitsPerSample))
+	copy(result[44:], pcm)
+	return result
+}
+
+// WAVHeader returns the canonical 44-byte RIFF/WAVE header for dataSize bytes of
+// PCM. A negative dataSize, for a stream whose length isn't known yet, writes the
+// placeholder sizes players and ParseWAV read as "until the end".
+func WAVHeader(dataSize, sampleRate, channels, bitsPerSample int) []byte {
+	riffSize, dataChunkSize := uint32(0xFFFFFFFF), uint32(0xFFFFFFFF)
+	if dataSize >= 0 {
+		riffSize, dataChunkSize = uint32(36+dataSize), uint32(dataSize)
+	}
 	byteRate := uint32(sampleRate * channels * bitsPerSample / 8)
 	blockAlign := uint16(channels * bitsPerSample / 8)
 
-	var header [44]byte
+	header := make([]byte, 44)
 	copy(header[0:4], "RIFF")
-	binary.LittleEndian.PutUint32(header[4:], 36+dataSize)
+	binary.LittleEndian.PutUint32(header[4:], riffSize)
 	copy(header[8:12], "WAVE")
 	copy(header[12:16], "fmt ")
 	binary.LittleEndian.PutUint32(header[16:], 16) // Subchunk1Size: always 16 for PCM
@@ -22,10 +35,36 @@ func PCMToWAV(pcm []byte, sampleRate, channels, bitsPerSample int) []byte {
 	binary.LittleEndian.PutUint16(header[32:], blockAlign)
 	binary.LittleEndian.PutUint16(header[34:], uint16(bitsPerSample))
 	copy(header[36:40], "data")
-	binary.LittleEndian.PutUint32(header[40:], dataSize)
+	binary.LittleEndian.PutUint32(header[40:], dataChunkSize)
+	return header
+}
 
-	result := make([]byte, 44+len(pcm))
-	copy(result[0:44], header[:])
-	copy(result[44:], pcm)
-	return result
+// ParseWAV extracts the PCM payload and format of a RIFF/WAVE stream.
+// ok is false when data is not a WAV container (e.g. headerless PCM).
+func ParseWAV(data []byte) (pcm []byte, sampleRate, channels, bitsPerSample int, ok bool) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, 0, 0, 0, false
+	}
+	for pos := 12; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		body := data[pos+8:]
+		switch id {
+		case "fmt ":
+			if len(body) < 16 {
+				return nil, 0, 0, 0, false
+			}
+			channels = int(binary.LittleEndian.Uint16(body[2:4]))
+			sampleRate = int(binary.LittleEndian.Uint32(body[4:8]))
+			bitsPerSample = int(binary.LittleEndian.Uint16(body[14:16]))
+		case "data":
+			if size > len(body) {
+				size = len(body) // streamed WAVs may carry a placeholder size
+			}
+			pcm = body[:size]
+			return pcm, sampleRate, channels, bitsPerSample, sampleRate > 0 && channels > 0
+		}
+		pos += 8 + size + size%2
+	}
+	return nil, 0, 0, 0, false
 }
@@ -0,0 +1,231 @@
+// Package validate checks that provider output is decodable audio of the format
+// asked for, so an error body sent with a 200 or a stream cut short isn't stored
+// as a job's result.
+package validate
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pako-tts/server/internal/audio/transcode"
+)
+
+// ErrInvalid is wrapped by every error Check returns.
+var ErrInvalid = errors.New("invalid audio")
+
+// ErrEmpty is wrapped, with ErrInvalid, by the error Check returns for a
+// zero-byte body.
+var ErrEmpty = errors.New("empty body")
+
+// snippetLen is how much of a text body an error quotes.
+const snippetLen = 200
+
+// Check validates audio of an output format and returns its duration.
+// MP3 must be a chain of at least one complete MPEG audio frame, optionally
+// wrapped in ID3 tags; WAV a RIFF/WAVE stream whose data chunk holds at least
+// one sample frame. Headerless PCM, as some providers return for "wav", can't be
+// walked, so it is only checked not to be empty or text, and its duration is
+// returned as 0. ogg_opus and flac must start with their container's signature
+// ("OggS", "fLaC"), and their duration is returned as 0 too. mulaw has no header
+// to check; its duration follows from its length, one byte per 8 kHz sample.
+// Other formats are only checked not to be empty or text.
+func Check(audio []byte, format string) (time.Duration, error) {
+	if len(audio) == 0 {
+		return 0, fmt.Errorf("%w: %w", ErrInvalid, ErrEmpty)
+	}
+	if text, ok := textBody(audio); ok {
+		return 0, fmt.Errorf("%w: text instead of audio: %q", ErrInvalid, text)
+	}
+	switch format {
+	case "mp3":
+		return checkMP3(audio)
+	case "wav":
+		if len(audio) >= 12 && string(audio[0:4]) == "RIFF" && string(audio[8:12]) == "WAVE" {
+			return checkWAV(audio)
+		}
+	case "ogg_opus":
+		if !bytes.HasPrefix(audio, []byte("OggS")) {
+			return 0, fmt.Errorf("%w: no Ogg page", ErrInvalid)
+		}
+	case "flac":
+		if !bytes.HasPrefix(audio, []byte("fLaC")) {
+			return 0, fmt.Errorf("%w: no FLAC stream marker", ErrInvalid)
+		}
+	case "mulaw":
+		return time.Duration(len(audio)) * time.Second / transcode.MulawSampleRate, nil
+	}
+	return 0, nil
+}
+
+// textBody returns the start of audio when it is a JSON, XML or HTML document,
+// such as an API error.
+func textBody(audio []byte) (string, bool) {
+	trimmed := bytes.TrimLeft(audio, " \t\r\n")
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[' && trimmed[0] != '<') {
+		return "", false
+	}
+	head := trimmed[:min(len(trimmed), snippetLen)]
+	for len(head) > 0 && !utf8.Valid(head) {
+		head = head[:len(head)-1] // a rune cut in half at the end
+	}
+	for _, b := range head {
+		if b < 0x20 && b != '\t' && b != '\r' && b != '\n' {
+			return "", false
+		}
+	}
+	return string(head), len(head) > 0
+}
+
+// MPEG audio versions, as coded in the frame header.
+const (
+	mpeg25 = 0
+	mpeg2  = 2
+	mpeg1  = 3
+)
+
+// bitrates holds the bitrates in kbit/s by bitrate index, for MPEG-1 layers
+// I-III and then MPEG-2/2.5 layer I and layers II-III.
+var bitrates = [5][16]int{
+	{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+	{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+	{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+	{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+}
+
+// sampleRates holds the MPEG-1 sample rates by index; MPEG-2 halves and
+// MPEG-2.5 quarters them.
+var sampleRates = [3]int{44100, 48000, 32000}
+
+// mp3Frame is a parsed MPEG audio frame header.
+type mp3Frame struct {
+	size       int
+	samples    int
+	sampleRate int
+}
+
+// parseFrame parses the MPEG audio frame header at the start of b.
+func parseFrame(b []byte) (mp3Frame, bool) {
+	if len(b) < 4 {
+		return mp3Frame{}, false
+	}
+	h := binary.BigEndian.Uint32(b)
+	version := int(h>>19) & 3
+	layer := 4 - int(h>>17)&3 // 1, 2 or 3; 4 is reserved
+	bitrateIndex := int(h>>12) & 0xF
+	rateIndex := int(h>>10) & 3
+	padding := int(h>>9) & 1
+	if h>>21 != 0x7FF || version == 1 || layer == 4 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+		return mp3Frame{}, false
+	}
+
+	table := layer - 1
+	if version != mpeg1 {
+		table = min(layer, 2) + 2
+	}
+	bitrate := bitrates[table][bitrateIndex] * 1000
+	sampleRate := sampleRates[rateIndex]
+	switch version {
+	case mpeg2:
+		sampleRate /= 2
+	case mpeg25:
+		sampleRate /= 4
+	}
+
+	f := mp3Frame{sampleRate: sampleRate}
+	switch {
+	case layer == 1:
+		f.samples = 384
+		f.size = (12*bitrate/sampleRate + padding) * 4
+	case layer == 3 && version != mpeg1:
+		f.samples = 576
+		f.size = 72*bitrate/sampleRate + padding
+	default:
+		f.samples = 1152
+		f.size = 144*bitrate/sampleRate + padding
+	}
+	return f, true
+}
+
+// checkMP3 walks the frames of an MP3 stream.
+func checkMP3(audio []byte) (time.Duration, error) {
+	pos := 0
+	if len(audio) >= 10 && string(audio[0:3]) == "ID3" {
+		size := int(audio[6]&0x7F)<<21 | int(audio[7]&0x7F)<<14 | int(audio[8]&0x7F)<<7 | int(audio[9]&0x7F)
+		pos = 10 + size
+		if audio[5]&0x10 != 0 {
+			pos += 10 // footer
+		}
+	}
+
+	var frames, samples, sampleRate int
+	var duration time.Duration
+	for pos < len(audio) {
+		rest := audio[pos:]
+		if frames > 0 && (bytes.HasPrefix(rest, []byte("TAG")) || bytes.HasPrefix(rest, []byte("APETAGEX"))) {
+			break // trailing ID3v1 or APE tag
+		}
+		f, ok := parseFrame(rest)
+		if !ok {
+			return 0, fmt.Errorf("%w: no MPEG frame sync at byte %d", ErrInvalid, pos)
+		}
+		if f.size > len(rest) {
+			return 0, fmt.Errorf("%w: truncated, last frame has %d of %d bytes", ErrInvalid, len(rest), f.size)
+		}
+		if f.sampleRate != sampleRate && samples > 0 {
+			duration += time.Duration(samples) * time.Second / time.Duration(sampleRate)
+			samples = 0
+		}
+		frames++
+		samples += f.samples
+		sampleRate = f.sampleRate
+		pos += f.size
+	}
+	if frames == 0 {
+		return 0, fmt.Errorf("%w: no MPEG frames", ErrInvalid)
+	}
+	return duration + time.Duration(samples)*time.Second/time.Duration(sampleRate), nil
+}
+
+// checkWAV walks the chunks of a RIFF/WAVE stream.
+func checkWAV(audio []byte) (time.Duration, error) {
+	var byteRate, blockAlign int
+	for pos := 12; pos+8 <= len(audio); {
+		id := string(audio[pos : pos+4])
+		size := binary.LittleEndian.Uint32(audio[pos+4 : pos+8])
+		body := audio[pos+8:]
+		switch id {
+		case "fmt ":
+			if len(body) < 16 {
+				return 0, fmt.Errorf("%w: truncated fmt chunk", ErrInvalid)
+			}
+			byteRate = int(binary.LittleEndian.Uint32(body[8:12]))
+			blockAlign = int(binary.LittleEndian.Uint16(body[12:14]))
+			if byteRate == 0 || blockAlign == 0 {
+				return 0, fmt.Errorf("%w: fmt chunk without a sample format", ErrInvalid)
+			}
+		case "data":
+			if byteRate == 0 {
+				return 0, fmt.Errorf("%w: data chunk before fmt chunk", ErrInvalid)
+			}
+			n := len(body)
+			// Streamed WAVs carry a placeholder size, which leaves truncation undetectable
+			if size != 0 && size != 0xFFFFFFFF {
+				if int64(size) > int64(n) {
+					return 0, fmt.Errorf("%w: truncated, data chunk has %d of %d bytes", ErrInvalid, n, size)
+				}
+				n = int(size)
+			}
+			if n < blockAlign {
+				return 0, fmt.Errorf("%w: no samples", ErrInvalid)
+			}
+			return time.Duration(n) * time.Second / time.Duration(byteRate), nil
+		}
+		pos += 8 + int(size) + int(size%2)
+	}
+	return 0, fmt.Errorf("%w: no data chunk", ErrInvalid)
+}
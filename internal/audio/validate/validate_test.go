@@ -0,0 +1,76 @@
+package validate
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pako-tts/server/internal/audio/transcode"
+)
+
+// mp3Frames returns n MPEG-2 layer III frames at 32 kbit/s and 22050 Hz, the
+// format ElevenLabs' mp3_22050_32 produces: 104 bytes and 576 samples each.
+func mp3Frames(n int) []byte {
+	frame := make([]byte, 104)
+	copy(frame, []byte{0xFF, 0xF3, 0x40, 0xC4})
+	return bytes.Repeat(frame, n)
+}
+
+func TestCheck_Valid(t *testing.T) {
+	id3 := append([]byte("ID3\x04\x00\x00\x00\x00\x00\x05"), make([]byte, 5)...)
+	tests := []struct {
+		name   string
+		audio  []byte
+		format string
+		want   time.Duration
+	}{
+		{"mp3", mp3Frames(50), "mp3", 50 * 576 * time.Second / 22050},
+		{"mp3 with ID3 tags", append(append(id3, mp3Frames(1)...), append([]byte("TAG"), make([]byte, 125)...)...), "mp3", 576 * time.Second / 22050},
+		{"wav", transcode.PCMToWAV(make([]byte, 48000), 24000, 1, 16), "wav", time.Second},
+		{"headerless pcm", make([]byte, 48000), "wav", 0},
+		{"ogg opus", append([]byte("OggS"), make([]byte, 60)...), "ogg_opus", 0},
+		{"flac", append([]byte("fLaC"), make([]byte, 60)...), "flac", 0},
+		{"mulaw", bytes.Repeat([]byte{0xFF}, 4000), "mulaw", time.Second / 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Check(tt.audio, tt.format)
+			if err != nil {
+				t.Fatalf("Check: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected duration %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCheck_Invalid(t *testing.T) {
+	wav := transcode.PCMToWAV(make([]byte, 48000), 24000, 1, 16)
+	tests := []struct {
+		name   string
+		audio  []byte
+		format string
+		want   string
+	}{
+		{"empty", nil, "mp3", "empty body"},
+		{"error json", []byte(`{"detail":{"status":"quota_exceeded"}}`), "mp3", "quota_exceeded"},
+		{"error json as pcm", []byte(` {"detail":"busy"}`), "wav", "text instead of audio"},
+		{"truncated mp3", mp3Frames(3)[:250], "mp3", "truncated"},
+		{"garbage mp3", append(mp3Frames(2), 0x00, 0x01, 0x02, 0x03), "mp3", "no MPEG frame sync at byte 208"},
+		{"truncated wav", wav[:1000], "wav", "truncated"},
+		{"wav without samples", transcode.PCMToWAV(nil, 24000, 1, 16), "wav", "no samples"},
+		{"mp3 as ogg opus", mp3Frames(2), "ogg_opus", "no Ogg page"},
+		{"wav as flac", wav, "flac", "no FLAC stream marker"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Check(tt.audio, tt.format)
+			if !errors.Is(err, ErrInvalid) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected an invalid audio error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
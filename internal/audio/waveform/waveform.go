@@ -0,0 +1,91 @@
+// Package waveform computes amplitude envelopes (peaks) of audio results so web players
+// can draw waveforms without downloading and decoding the audio themselves.
+package waveform
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+
+	"github.com/pako-tts/server/internal/audio/transcode"
+)
+
+const (
+	// PixelsPerSecond is the horizontal resolution of generated peaks.
+	PixelsPerSecond = 100
+
+	// decodeSampleRate is the rate compressed audio is decoded at; peaks don't need more.
+	decodeSampleRate = 8000
+)
+
+// ErrUnsupportedInput is returned for audio that can't be decoded (headerless PCM).
+var ErrUnsupportedInput = errors.New("waveform: unsupported audio input")
+
+// Data is a single-channel peaks document in the audiowaveform JSON format (version 2),
+// which peaks.js and wavesurfer.js load directly. Data holds min/max pairs per pixel.
+type Data struct {
+	Version         int   `json:"version"`
+	Channels        int   `json:"channels"`
+	SampleRate      int   `json:"sample_rate"`
+	SamplesPerPixel int   `json:"samples_per_pixel"`
+	Bits            int   `json:"bits"`
+	Length          int   `json:"length"`
+	Data            []int `json:"data"`
+}
+
+// Generate computes peaks for a result of any output format. 16-bit WAV and μ-law are
+// read directly; everything else is decoded with ffmpeg.
+func Generate(ctx context.Context, audio []byte, format string) (*Data, error) {
+	if format == "mulaw" {
+		audio, format = transcode.MulawToWAV(audio), "wav"
+	}
+	if format == "wav" {
+		pcm, sampleRate, channels, bits, ok := transcode.ParseWAV(audio)
+		if !ok {
+			return nil, ErrUnsupportedInput
+		}
+		if bits == 16 {
+			return FromPCM(pcm, sampleRate, channels), nil
+		}
+	}
+
+	pcm, err := transcode.DecodeToPCM(ctx, audio, decodeSampleRate)
+	if err != nil {
+		return nil, err
+	}
+	return FromPCM(pcm, decodeSampleRate, 1), nil
+}
+
+// FromPCM computes 8-bit min/max peaks from interleaved 16-bit little-endian PCM.
+// Channels are folded together so the envelope covers all of them.
+func FromPCM(pcm []byte, sampleRate, channels int) *Data {
+	samplesPerPixel := max(1, sampleRate/PixelsPerSecond)
+	frameSize := 2 * channels
+	frames := len(pcm) / frameSize
+
+	data := &Data{
+		Version:         2,
+		Channels:        1,
+		SampleRate:      sampleRate,
+		SamplesPerPixel: samplesPerPixel,
+		Bits:            8,
+		Data:            make([]int, 0, 2*(frames/samplesPerPixel+1)),
+	}
+
+	for start := 0; start < frames; start += samplesPerPixel {
+		end := min(start+samplesPerPixel, frames)
+		lo, hi := int16(0), int16(0)
+		for f := start; f < end; f++ {
+			for c := 0; c < channels; c++ {
+				off := f*frameSize + 2*c
+				v := int16(binary.LittleEndian.Uint16(pcm[off : off+2]))
+				lo = min(lo, v)
+				hi = max(hi, v)
+			}
+		}
+		data.Data = append(data.Data, int(lo>>8), int(hi>>8))
+		data.Length++
+	}
+
+	return data
+}
@@ -0,0 +1,77 @@
+package waveform
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/pako-tts/server/internal/audio/transcode"
+)
+
+// pcm16 encodes samples as 16-bit little-endian PCM.
+func pcm16(samples ...int16) []byte {
+	out := make([]byte, 2*len(samples))
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(out[2*i:], uint16(s))
+	}
+	return out
+}
+
+func TestFromPCM(t *testing.T) {
+	// 200 Hz mono -> 2 samples per pixel
+	data := FromPCM(pcm16(1000, -2000, 32767, -32768, 512), 200, 1)
+
+	if data.SamplesPerPixel != 2 {
+		t.Fatalf("expected 2 samples per pixel, got %d", data.SamplesPerPixel)
+	}
+	if data.Length != 3 {
+		t.Fatalf("expected 3 pixels, got %d", data.Length)
+	}
+	want := []int{-8, 3, -128, 127, 0, 2}
+	for i, v := range want {
+		if data.Data[i] != v {
+			t.Errorf("data[%d]: expected %d, got %d", i, v, data.Data[i])
+		}
+	}
+}
+
+func TestFromPCM_FoldsChannels(t *testing.T) {
+	// 100 Hz stereo -> 1 frame per pixel; left is quiet, right is loud
+	data := FromPCM(pcm16(256, -25600), 100, 2)
+
+	if data.Length != 1 || data.Data[0] != -100 || data.Data[1] != 1 {
+		t.Errorf("expected [-100 1], got %v", data.Data)
+	}
+}
+
+func TestGenerate_WAV(t *testing.T) {
+	wav := transcode.PCMToWAV(make([]byte, 2*24000), 24000, 1, 16)
+
+	data, err := Generate(context.Background(), wav, "wav")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data.Length != PixelsPerSecond {
+		t.Errorf("expected %d pixels for 1 s of audio, got %d", PixelsPerSecond, data.Length)
+	}
+	if data.SampleRate != 24000 {
+		t.Errorf("expected sample rate 24000, got %d", data.SampleRate)
+	}
+}
+
+func TestGenerate_Mulaw(t *testing.T) {
+	data, err := Generate(context.Background(), bytes.Repeat([]byte{0xFF}, 8000), "mulaw")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data.Length != PixelsPerSecond || data.SampleRate != 8000 {
+		t.Errorf("expected %d pixels at 8000 Hz for 1 s of audio, got %d at %d Hz", PixelsPerSecond, data.Length, data.SampleRate)
+	}
+}
+
+func TestGenerate_HeaderlessPCM(t *testing.T) {
+	if _, err := Generate(context.Background(), make([]byte, 100), "wav"); err != ErrUnsupportedInput {
+		t.Errorf("expected ErrUnsupportedInput, got %v", err)
+	}
+}
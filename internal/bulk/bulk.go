@@ -0,0 +1,398 @@
+// Package bulk runs admin actions, such as cancelling or retrying jobs, over
+// every job matching a filter in the background, and reports their progress.
+package bulk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// Actions an operation takes on each job it matched.
+const (
+	// ActionCancel cancels queued and processing jobs.
+	ActionCancel = "cancel"
+	// ActionRetry queues a new job with the parameters of each failed job (see
+	// domain.NewRetryJob).
+	ActionRetry = "retry"
+	// ActionPurge deletes the results of completed jobs, which become expired.
+	ActionPurge = "purge"
+)
+
+// States of an operation.
+const (
+	StateRunning   = "running"
+	StateCompleted = "completed"
+	// StateFailed is an operation that couldn't list its jobs, or was
+	// interrupted by shutdown; the jobs processed until then stay processed.
+	StateFailed = "failed"
+)
+
+const (
+	// listPageSize is how many jobs a page of the matching jobs holds.
+	listPageSize = 500
+	// maxErrors bounds the per-job errors an operation keeps.
+	maxErrors = 20
+	// maxOperations bounds the operations kept; the oldest finished ones go first.
+	maxOperations = 100
+	// busyWait is how long a retry waits before enqueueing again into a full queue.
+	busyWait = time.Second
+)
+
+// actionStatuses lists the statuses of the jobs each action applies to.
+var actionStatuses = map[string][]domain.JobStatus{
+	ActionCancel: {domain.JobStatusQueued, domain.JobStatusProcessing},
+	ActionRetry:  {domain.JobStatusFailed},
+	ActionPurge:  {domain.JobStatusCompleted},
+}
+
+// Statuses returns the statuses of the jobs action applies to, or nil for an
+// unknown action.
+func Statuses(action string) []domain.JobStatus {
+	return actionStatuses[action]
+}
+
+// Filter selects the jobs of an operation. Unset fields match any job.
+type Filter struct {
+	// Status is one of the statuses the action applies to; unset matches them all.
+	Status       domain.JobStatus `json:"status,omitempty"`
+	Tenant       string           `json:"tenant,omitempty"`
+	BatchID      string           `json:"batch_id,omitempty"`
+	Provider     string           `json:"provider,omitempty"`
+	VoiceID      string           `json:"voice_id,omitempty"`
+	OutputFormat string           `json:"output_format,omitempty"`
+	// CreatedAfter and CreatedBefore bound when the jobs were submitted.
+	CreatedAfter  *time.Time `json:"created_after,omitempty"`
+	CreatedBefore *time.Time `json:"created_before,omitempty"`
+	// FinishedAfter matches jobs that finished after it, e.g. the failures of the
+	// last hour.
+	FinishedAfter *time.Time `json:"finished_after,omitempty"`
+}
+
+func (f Filter) jobFilter(status domain.JobStatus) domain.JobFilter {
+	filter := domain.JobFilter{
+		Status:       status,
+		Tenant:       f.Tenant,
+		BatchID:      f.BatchID,
+		Provider:     f.Provider,
+		VoiceID:      f.VoiceID,
+		OutputFormat: f.OutputFormat,
+		Ascending:    true,
+		Limit:        listPageSize,
+	}
+	if f.CreatedAfter != nil {
+		filter.CreatedAfter = *f.CreatedAfter
+	}
+	if f.CreatedBefore != nil {
+		filter.CreatedBefore = *f.CreatedBefore
+	}
+	if f.FinishedAfter != nil {
+		filter.FinishedAfter = *f.FinishedAfter
+	}
+	return filter
+}
+
+// JobError is the error an operation ran into on one job.
+type JobError struct {
+	JobID string `json:"job_id"`
+	Error string `json:"error"`
+}
+
+// Operation reports an action taken on the jobs matching a filter. The jobs are
+// those that matched when the operation started; Total is set once they are
+// known.
+type Operation struct {
+	ID     string `json:"operation_id"`
+	Action string `json:"action"`
+	Filter Filter `json:"filter"`
+	// DryRun only counts the matching jobs.
+	DryRun bool   `json:"dry_run,omitempty"`
+	State  string `json:"state"`
+	Total  int    `json:"total"`
+	// Processed counts the jobs done so far: Succeeded, Skipped because they no
+	// longer were in a status the action applies to, or Failed.
+	Processed          int        `json:"processed"`
+	Succeeded          int        `json:"succeeded"`
+	Skipped            int        `json:"skipped"`
+	Failed             int        `json:"failed"`
+	ProgressPercentage float64    `json:"progress_percentage"`
+	Errors             []JobError `json:"errors,omitempty"`
+	// Error says why a failed operation stopped.
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Runner runs operations in the background until its context is done.
+type Runner struct {
+	ctx      context.Context
+	queue    domain.JobQueue
+	storage  domain.AudioStorage
+	registry domain.ProviderRegistry
+	logger   *zap.Logger
+
+	mu         sync.Mutex
+	operations map[string]*Operation
+	// order holds the IDs of the operations, oldest first.
+	order []string
+}
+
+// NewRunner creates a runner whose operations stop once ctx is done.
+func NewRunner(ctx context.Context, queue domain.JobQueue, storage domain.AudioStorage, registry domain.ProviderRegistry, logger *zap.Logger) *Runner {
+	return &Runner{
+		ctx:        ctx,
+		queue:      queue,
+		storage:    storage,
+		registry:   registry,
+		logger:     logger,
+		operations: make(map[string]*Operation),
+	}
+}
+
+// Start starts action on the jobs matching filter and returns the operation.
+// The action must be known and the filter's status one it applies to.
+func (r *Runner) Start(action string, filter Filter, dryRun bool) (Operation, error) {
+	statuses := Statuses(action)
+	if statuses == nil {
+		return Operation{}, fmt.Errorf("unknown action %q", action)
+	}
+	if filter.Status != "" {
+		found := false
+		for _, s := range statuses {
+			found = found || s == filter.Status
+		}
+		if !found {
+			return Operation{}, fmt.Errorf("action %s doesn't apply to %s jobs", action, filter.Status)
+		}
+		statuses = []domain.JobStatus{filter.Status}
+	}
+
+	op := &Operation{
+		ID:        uuid.New().String(),
+		Action:    action,
+		Filter:    filter,
+		DryRun:    dryRun,
+		State:     StateRunning,
+		CreatedAt: time.Now().UTC(),
+	}
+	r.mu.Lock()
+	r.add(op)
+	snapshot := op.snapshot()
+	r.mu.Unlock()
+
+	go r.run(op, statuses)
+	return snapshot, nil
+}
+
+// Get returns the operation with id.
+func (r *Runner) Get(id string) (Operation, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	op, ok := r.operations[id]
+	if !ok {
+		return Operation{}, false
+	}
+	return op.snapshot(), true
+}
+
+// List returns the operations kept, newest first.
+func (r *Runner) List() []Operation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ops := make([]Operation, 0, len(r.order))
+	for i := len(r.order) - 1; i >= 0; i-- {
+		ops = append(ops, r.operations[r.order[i]].snapshot())
+	}
+	return ops
+}
+
+// add keeps op, dropping the oldest finished operation when there are too many.
+// Callers hold r.mu.
+func (r *Runner) add(op *Operation) {
+	if len(r.order) >= maxOperations {
+		for i, id := range r.order {
+			if r.operations[id].State != StateRunning {
+				delete(r.operations, id)
+				r.order = append(r.order[:i], r.order[i+1:]...)
+				break
+			}
+		}
+	}
+	r.operations[op.ID] = op
+	r.order = append(r.order, op.ID)
+}
+
+// snapshot copies the operation for callers outside the runner. Callers hold
+// r.mu.
+func (op *Operation) snapshot() Operation {
+	s := *op
+	s.Errors = append([]JobError(nil), op.Errors...)
+	if op.Total > 0 {
+		s.ProgressPercentage = float64(op.Processed) * 100 / float64(op.Total)
+	} else if op.State == StateCompleted {
+		s.ProgressPercentage = 100
+	}
+	return s
+}
+
+func (r *Runner) run(op *Operation, statuses []domain.JobStatus) {
+	ids, err := r.match(op.Filter, statuses)
+	if err != nil {
+		r.finish(op, fmt.Errorf("list jobs: %w", err))
+		return
+	}
+	r.mu.Lock()
+	op.Total = len(ids)
+	r.mu.Unlock()
+	if op.DryRun {
+		r.finish(op, nil)
+		return
+	}
+
+	for _, id := range ids {
+		if err := r.ctx.Err(); err != nil {
+			r.finish(op, fmt.Errorf("interrupted: %w", err))
+			return
+		}
+		applied, err := r.apply(op.Action, id)
+
+		r.mu.Lock()
+		op.Processed++
+		switch {
+		case err != nil:
+			op.Failed++
+			if len(op.Errors) < maxErrors {
+				op.Errors = append(op.Errors, JobError{JobID: id, Error: err.Error()})
+			}
+		case applied:
+			op.Succeeded++
+		default:
+			op.Skipped++
+		}
+		r.mu.Unlock()
+	}
+	r.finish(op, nil)
+}
+
+// match returns the IDs of the jobs in one of statuses that pass filter, oldest
+// first.
+func (r *Runner) match(filter Filter, statuses []domain.JobStatus) ([]string, error) {
+	var ids []string
+	for _, status := range statuses {
+		jobFilter := filter.jobFilter(status)
+		for {
+			page, err := r.queue.ListJobs(r.ctx, jobFilter)
+			if err != nil {
+				return nil, err
+			}
+			for _, job := range page.Jobs {
+				ids = append(ids, job.ID)
+			}
+			if page.Next == nil {
+				break
+			}
+			jobFilter.After = page.Next
+		}
+	}
+	return ids, nil
+}
+
+func (r *Runner) finish(op *Operation, err error) {
+	now := time.Now().UTC()
+	r.mu.Lock()
+	op.State = StateCompleted
+	if err != nil {
+		op.State = StateFailed
+		op.Error = err.Error()
+	}
+	op.FinishedAt = &now
+	s := op.snapshot()
+	r.mu.Unlock()
+
+	fields := []zap.Field{
+		zap.String("operation_id", s.ID),
+		zap.String("action", s.Action),
+		zap.Bool("dry_run", s.DryRun),
+		zap.Int("total", s.Total),
+		zap.Int("succeeded", s.Succeeded),
+		zap.Int("skipped", s.Skipped),
+		zap.Int("failed", s.Failed),
+	}
+	if err != nil {
+		r.logger.Error("Bulk operation failed", append(fields, zap.Error(err))...)
+		return
+	}
+	r.logger.Info("Bulk operation completed", fields...)
+}
+
+// apply takes action on the job with id. It reports false, without an error,
+// for a job that is gone or no longer in a status the action applies to.
+func (r *Runner) apply(action, id string) (bool, error) {
+	if action == ActionCancel {
+		_, err := r.queue.Cancel(r.ctx, id)
+		if errors.Is(err, domain.ErrJobNotCancellable) || errors.Is(err, domain.ErrJobNotFound) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	job, err := r.queue.GetJob(r.ctx, id)
+	if errors.Is(err, domain.ErrJobNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	switch action {
+	case ActionRetry:
+		if job.Status != domain.JobStatusFailed {
+			return false, nil
+		}
+		return true, r.retry(job)
+	case ActionPurge:
+		if job.Status != domain.JobStatusCompleted {
+			return false, nil
+		}
+		return true, r.purge(job)
+	}
+	return false, fmt.Errorf("unknown action %q", action)
+}
+
+// retry queues a new job with the failed job's parameters, waiting for room in
+// a full queue.
+func (r *Runner) retry(failed *domain.Job) error {
+	if _, err := r.registry.Get(failed.ProviderName); err != nil {
+		return fmt.Errorf("provider %q not found", failed.ProviderName)
+	}
+	job := domain.NewRetryJob(failed)
+	for {
+		err := r.queue.Enqueue(r.ctx, job)
+		if !errors.Is(err, domain.ErrQueueBusy) {
+			return err
+		}
+		select {
+		case <-r.ctx.Done():
+			return r.ctx.Err()
+		case <-time.After(busyWait):
+		}
+	}
+}
+
+// purge deletes the job's result with its variants and artifacts, and marks the
+// job expired.
+func (r *Runner) purge(job *domain.Job) error {
+	if err := r.storage.Delete(r.ctx, job.ID); err != nil {
+		return fmt.Errorf("delete result: %w", err)
+	}
+	if err := job.SetResultDeleted(); err != nil {
+		return err
+	}
+	return r.queue.UpdateJob(r.ctx, job)
+}
@@ -0,0 +1,154 @@
+package bulk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/api/handlers/mocks"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/queue/memory"
+)
+
+func newTestRunner(t *testing.T) (*Runner, *memory.Queue) {
+	t.Helper()
+	queue := memory.NewQueue(100)
+	registry := mocks.NewMockProviderRegistry(&mocks.MockProvider{NameValue: "cloud"})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return NewRunner(ctx, queue, mocks.NewMockStorage(), registry, zap.NewNop()), queue
+}
+
+func enqueue(t *testing.T, queue *memory.Queue, tenant string, set func(*domain.Job)) *domain.Job {
+	t.Helper()
+	job := domain.NewJob("hello", "voice", "", "", "cloud", "mp3", nil)
+	job.TenantID = tenant
+	if err := queue.Enqueue(context.Background(), job); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if set != nil {
+		set(job)
+		queue.UpdateJob(context.Background(), job) //nolint:errcheck
+	}
+	return job
+}
+
+// wait polls the operation until it finished.
+func wait(t *testing.T, r *Runner, id string) Operation {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		op, ok := r.Get(id)
+		if !ok {
+			t.Fatalf("operation %s not found", id)
+		}
+		if op.State != StateRunning {
+			return op
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("operation %s still running", id)
+	return Operation{}
+}
+
+func TestRunner_Cancel(t *testing.T) {
+	r, queue := newTestRunner(t)
+	ctx := context.Background()
+	acme := []*domain.Job{enqueue(t, queue, "acme", nil), enqueue(t, queue, "acme", nil)}
+	other := enqueue(t, queue, "other", nil)
+	done := enqueue(t, queue, "acme", func(j *domain.Job) { j.SetCompleted("/r.mp3", 1) })
+
+	op, err := r.Start(ActionCancel, Filter{Tenant: "acme", Status: domain.JobStatusQueued}, false)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	op = wait(t, r, op.ID)
+	if op.State != StateCompleted || op.Total != 2 || op.Succeeded != 2 || op.ProgressPercentage != 100 {
+		t.Fatalf("expected the tenant's 2 queued jobs cancelled, got %+v", op)
+	}
+	for _, job := range acme {
+		if got, _ := queue.GetJob(ctx, job.ID); got.Status != domain.JobStatusCancelled {
+			t.Errorf("expected %s cancelled, got %s", job.ID, got.Status)
+		}
+	}
+	for _, job := range []*domain.Job{other, done} {
+		if got, _ := queue.GetJob(ctx, job.ID); got.Status == domain.JobStatusCancelled {
+			t.Errorf("expected %s left alone", job.ID)
+		}
+	}
+
+	if _, err := r.Start(ActionCancel, Filter{Status: domain.JobStatusFailed}, false); err == nil {
+		t.Error("expected cancelling failed jobs rejected")
+	}
+}
+
+func TestRunner_RetryRecentFailures(t *testing.T) {
+	r, queue := newTestRunner(t)
+	ctx := context.Background()
+	recent := enqueue(t, queue, "acme", func(j *domain.Job) { j.SetFailed("provider unavailable") })
+	old := enqueue(t, queue, "acme", func(j *domain.Job) {
+		j.SetFailed("provider unavailable")
+		at := time.Now().Add(-2 * time.Hour)
+		j.CompletedAt = &at
+	})
+	gone := enqueue(t, queue, "acme", func(j *domain.Job) {
+		j.SetFailed("voice rejected")
+		j.ProviderName = "removed"
+	})
+
+	since := time.Now().Add(-time.Hour)
+	op, err := r.Start(ActionRetry, Filter{FinishedAfter: &since}, false)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	op = wait(t, r, op.ID)
+	if op.Total != 2 || op.Succeeded != 1 || op.Failed != 1 {
+		t.Fatalf("expected the last hour's 2 failures processed, got %+v", op)
+	}
+	if len(op.Errors) != 1 || op.Errors[0].JobID != gone.ID {
+		t.Errorf("expected the job of a removed provider reported, got %+v", op.Errors)
+	}
+
+	page, _ := queue.ListJobs(ctx, domain.JobFilter{Status: domain.JobStatusQueued})
+	if len(page.Jobs) != 1 || page.Jobs[0].Events[0].Message != "retried from job "+recent.ID {
+		t.Fatalf("expected one retry of the recent failure, got %+v", page.Jobs)
+	}
+	if got, _ := queue.GetJob(ctx, old.ID); got.Status != domain.JobStatusFailed {
+		t.Errorf("expected the old failure left alone, got %s", got.Status)
+	}
+}
+
+func TestRunner_PurgeDryRun(t *testing.T) {
+	r, queue := newTestRunner(t)
+	ctx := context.Background()
+	completed := func(j *domain.Job) { j.SetCompleted("/r.mp3", 24) }
+	jobs := []*domain.Job{enqueue(t, queue, "acme", completed), enqueue(t, queue, "acme", completed)}
+	enqueue(t, queue, "acme", nil)
+
+	op, _ := r.Start(ActionPurge, Filter{VoiceID: "voice"}, true)
+	if op = wait(t, r, op.ID); op.Total != 2 || op.Processed != 0 {
+		t.Fatalf("expected a dry run to count 2 jobs only, got %+v", op)
+	}
+	for _, job := range jobs {
+		if got, _ := queue.GetJob(ctx, job.ID); got.Status != domain.JobStatusCompleted {
+			t.Fatalf("expected a dry run to leave %s alone, got %s", job.ID, got.Status)
+		}
+	}
+
+	op, _ = r.Start(ActionPurge, Filter{VoiceID: "voice"}, false)
+	if op = wait(t, r, op.ID); op.Succeeded != 2 {
+		t.Fatalf("expected 2 results purged, got %+v", op)
+	}
+	for _, job := range jobs {
+		if got, _ := queue.GetJob(ctx, job.ID); got.Status != domain.JobStatusExpired {
+			t.Errorf("expected %s expired, got %s", job.ID, got.Status)
+		}
+	}
+
+	ops := r.List()
+	if len(ops) != 2 || ops[0].ID != op.ID {
+		t.Errorf("expected the operations newest first, got %+v", ops)
+	}
+}
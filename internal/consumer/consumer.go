@@ -0,0 +1,457 @@
+// Package consumer creates jobs from requests read off a message queue (a Redis
+// stream, a NATS subject or an SQS queue) and publishes each job's result to a
+// reply topic, for integrations that are event-driven rather than HTTP clients.
+//
+// Requests are submitted to the API in-process, as POST /api/v1/jobs, so they
+// are validated, authenticated and deduplicated like any other submission.
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/webhook"
+)
+
+// Reply types besides domain.WebhookEventJobCompleted and
+// domain.WebhookEventJobFailed.
+const (
+	ReplyJobCancelled = "job.cancelled"
+	ReplyJobExpired   = "job.expired"
+	// ReplyJobRejected is published when no job could be created from a request.
+	ReplyJobRejected = "job.rejected"
+)
+
+const (
+	defaultPollInterval = time.Second
+	// minBackoff and maxBackoff bound the wait before reading again after the
+	// broker failed.
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+	// maxBusyWait caps the Retry-After a busy queue is waited for.
+	maxBusyWait = 30 * time.Second
+	// restorePage is how many jobs Restore lists at a time.
+	restorePage = 500
+)
+
+// Message is a job request read from a broker.
+type Message struct {
+	// ID is the broker's ID of the message.
+	ID   string
+	Data []byte
+	// ReplyTo is where the broker says replies go, e.g. a NATS reply subject;
+	// a reply_to in Data wins.
+	ReplyTo string
+	// receipt is what the broker acknowledges the message by, when not its ID.
+	receipt string
+}
+
+// Broker is a message queue the consumer reads requests from and publishes
+// replies to.
+type Broker interface {
+	// Receive waits for the next requests. It returns none when none came in for
+	// a while, so the caller can check whether it should stop.
+	Receive(ctx context.Context) ([]Message, error)
+
+	// Ack tells the broker msg was handled, so it isn't delivered again.
+	Ack(ctx context.Context, msg Message) error
+
+	// Publish sends data to topic: a stream, subject or queue URL.
+	Publish(ctx context.Context, topic string, data []byte) error
+
+	Close() error
+}
+
+// Reply is published to a request's reply topic once its job finished, or when
+// no job could be created from it.
+type Reply struct {
+	// Type is domain.WebhookEventJobCompleted, domain.WebhookEventJobFailed,
+	// ReplyJobCancelled, ReplyJobExpired or ReplyJobRejected.
+	Type          string `json:"type"`
+	CorrelationID string `json:"correlation_id,omitempty"`
+	// MessageID is the broker's ID of the request.
+	MessageID string                `json:"message_id,omitempty"`
+	Job       *webhook.JobEventData `json:"job,omitempty"`
+	// SignedURL downloads the result from storage directly, when storage signs
+	// URLs.
+	SignedURL string           `json:"signed_url,omitempty"`
+	Error     *domain.APIError `json:"error,omitempty"`
+}
+
+// Options configure a Consumer.
+type Options struct {
+	// APIKey is sent with each submission; empty sends none, for servers
+	// without auth.
+	APIKey string
+	// ReplyTopic receives the replies to requests that name no reply topic;
+	// empty publishes none.
+	ReplyTopic string
+	// PublicURL makes the result links of replies absolute.
+	PublicURL string
+	// PollInterval is how often submitted jobs are checked for results; a
+	// second when zero.
+	PollInterval time.Duration
+	// ResultURLs adds a URL signed for SignedURLTTL to the replies of completed
+	// jobs when non-nil.
+	ResultURLs   domain.SignedURLs
+	SignedURLTTL time.Duration
+	// Name is stored with the jobs the consumer owes replies for, so that after
+	// a restart it publishes those still owed. Instances consuming the same
+	// topic need different names, e.g. their host names.
+	Name string
+}
+
+// envelope holds the fields of a request besides those of the job request.
+type envelope struct {
+	ReplyTo       string `json:"reply_to"`
+	CorrelationID string `json:"correlation_id"`
+}
+
+// pending is a request whose job hasn't finished yet.
+type pending struct {
+	messageID     string
+	replyTo       string
+	correlationID string
+}
+
+// Consumer submits the requests a Broker delivers as jobs, and publishes their
+// replies.
+type Consumer struct {
+	broker Broker
+	api    http.Handler
+	jobs   domain.JobQueue
+	logger *zap.Logger
+	opts   Options
+
+	mu      sync.Mutex
+	pending map[string][]pending // by job ID
+}
+
+// New creates a consumer that submits requests from broker to api, the server's
+// router, and watches their jobs in jobs.
+func New(broker Broker, api http.Handler, jobs domain.JobQueue, logger *zap.Logger, opts Options) *Consumer {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultPollInterval
+	}
+	opts.PublicURL = strings.TrimSuffix(opts.PublicURL, "/")
+	return &Consumer{
+		broker:  broker,
+		api:     api,
+		jobs:    jobs,
+		logger:  logger,
+		opts:    opts,
+		pending: make(map[string][]pending),
+	}
+}
+
+// Start reads requests until ctx is done, then closes the broker. Meanwhile it
+// publishes the replies of the jobs submitted as they finish, starting with
+// those an earlier run of the consumer still owed.
+func (c *Consumer) Start(ctx context.Context) {
+	go func() {
+		if err := c.Restore(ctx); err != nil {
+			c.logger.Error("Failed to restore the jobs owed a reply", zap.Error(err))
+		}
+		c.watch(ctx)
+	}()
+	go func() {
+		defer c.broker.Close() //nolint:errcheck
+		backoff := minBackoff
+		for ctx.Err() == nil {
+			msgs, err := c.broker.Receive(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				c.logger.Warn("Failed to read job requests", zap.Duration("retry_in", backoff), zap.Error(err))
+				sleep(ctx, backoff)
+				backoff = min(backoff*2, maxBackoff)
+				continue
+			}
+			backoff = minBackoff
+			for _, msg := range msgs {
+				c.Handle(ctx, msg)
+			}
+		}
+	}()
+}
+
+// Handle submits the request msg carries and acknowledges it, replying straight
+// away when it was rejected. A request that couldn't be submitted before ctx
+// was done isn't acknowledged, so the broker delivers it again.
+func (c *Consumer) Handle(ctx context.Context, msg Message) {
+	// A malformed request is rejected by the API, and answered on the default topic
+	var env envelope
+	json.Unmarshal(msg.Data, &env) //nolint:errcheck
+	replyTo := env.ReplyTo
+	if replyTo == "" {
+		replyTo = msg.ReplyTo
+	}
+	if replyTo == "" {
+		replyTo = c.opts.ReplyTopic
+	}
+	logger := c.logger.With(zap.String("message_id", msg.ID))
+
+	submitCtx := ctx
+	if replyTo != "" {
+		submitCtx = domain.WithJobReply(ctx, &domain.JobReply{
+			Consumer:      c.opts.Name,
+			Topic:         replyTo,
+			CorrelationID: env.CorrelationID,
+			MessageID:     msg.ID,
+		})
+	}
+	jobID, apiErr := c.submit(submitCtx, msg)
+	if ctx.Err() != nil {
+		return
+	}
+	if apiErr != nil {
+		logger.Info("Job request rejected", zap.String("code", apiErr.Code), zap.String("message", apiErr.Message))
+		reply := Reply{Type: ReplyJobRejected, CorrelationID: env.CorrelationID, MessageID: msg.ID, Error: apiErr}
+		if err := c.reply(ctx, replyTo, reply); err != nil {
+			logger.Error("Failed to publish reply", zap.String("reply_to", replyTo), zap.Error(err))
+		}
+	} else {
+		logger.Info("Job submitted from message queue", zap.String("job_id", jobID))
+		if replyTo != "" {
+			c.mu.Lock()
+			c.pending[jobID] = append(c.pending[jobID], pending{messageID: msg.ID, replyTo: replyTo, correlationID: env.CorrelationID})
+			c.mu.Unlock()
+		}
+	}
+
+	if err := c.broker.Ack(ctx, msg); err != nil {
+		logger.Warn("Failed to acknowledge job request", zap.Error(err))
+	}
+}
+
+// submit posts msg to the API as POST /api/v1/jobs, waiting while the queue is
+// busy, draining or the key throttled. The job records the reply ctx carries.
+// It returns the job's ID, or the error the request was rejected with.
+func (c *Consumer) submit(ctx context.Context, msg Message) (string, *domain.APIError) {
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/api/v1/jobs", bytes.NewReader(msg.Data))
+		if err != nil {
+			return "", domain.ErrInternalServer
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Request-ID", msg.ID)
+		if c.opts.APIKey != "" {
+			req.Header.Set("X-API-Key", c.opts.APIKey)
+		}
+		// ip_filter sees submissions come from the server itself
+		req.RemoteAddr = "127.0.0.1"
+
+		resp := &response{header: make(http.Header)}
+		c.api.ServeHTTP(resp, req)
+
+		switch resp.status {
+		case http.StatusOK, http.StatusCreated:
+			var created struct {
+				JobID string `json:"job_id"`
+			}
+			if err := json.Unmarshal(resp.body.Bytes(), &created); err != nil || created.JobID == "" {
+				return "", domain.ErrInternalServer
+			}
+			return created.JobID, nil
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			wait := maxBusyWait
+			if seconds, err := strconv.Atoi(resp.header.Get("Retry-After")); err == nil {
+				wait = min(time.Duration(seconds)*time.Second, maxBusyWait)
+			}
+			c.logger.Debug("Job queue busy; waiting to submit", zap.String("message_id", msg.ID), zap.Duration("wait", wait))
+			if !sleep(ctx, wait) {
+				return "", nil
+			}
+		default:
+			var body domain.ErrorResponse
+			if err := json.Unmarshal(resp.body.Bytes(), &body); err != nil || body.Error == nil {
+				return "", domain.ErrInternalServer
+			}
+			return "", body.Error
+		}
+	}
+}
+
+// Restore watches the jobs an earlier run of the consumer, of the same name,
+// submitted and still owes a reply, e.g. because they finished while the server
+// was restarting.
+func (c *Consumer) Restore(ctx context.Context) error {
+	filter := domain.JobFilter{Ascending: true, Limit: restorePage}
+	restored := 0
+	for {
+		page, err := c.jobs.ListJobs(ctx, filter)
+		if err != nil {
+			return err
+		}
+		c.mu.Lock()
+		for _, job := range page.Jobs {
+			if job.Reply == nil || job.Reply.Consumer != c.opts.Name || len(c.pending[job.ID]) > 0 {
+				continue
+			}
+			c.pending[job.ID] = []pending{{messageID: job.Reply.MessageID, replyTo: job.Reply.Topic, correlationID: job.Reply.CorrelationID}}
+			restored++
+		}
+		c.mu.Unlock()
+		if page.Next == nil {
+			break
+		}
+		filter.After = page.Next
+	}
+	if restored > 0 {
+		c.logger.Info("Restored jobs owed a reply", zap.Int("jobs", restored))
+	}
+	return nil
+}
+
+// watch publishes the replies of pending jobs as they finish, until ctx is done.
+func (c *Consumer) watch(ctx context.Context) {
+	ticker := time.NewTicker(c.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.CheckPending(ctx)
+		}
+	}
+}
+
+// CheckPending publishes the replies of the pending jobs that finished. A reply
+// that couldn't be published is tried again on the next check.
+func (c *Consumer) CheckPending(ctx context.Context) {
+	c.mu.Lock()
+	jobIDs := make([]string, 0, len(c.pending))
+	for jobID := range c.pending {
+		jobIDs = append(jobIDs, jobID)
+	}
+	c.mu.Unlock()
+
+	for _, jobID := range jobIDs {
+		job, err := c.jobs.GetJob(ctx, jobID)
+		if err != nil {
+			var apiErr *domain.APIError
+			if errors.As(err, &apiErr) && apiErr.Code == domain.ErrJobNotFound.Code {
+				c.logger.Warn("Job submitted from message queue is gone; no reply sent", zap.String("job_id", jobID))
+				c.mu.Lock()
+				delete(c.pending, jobID)
+				c.mu.Unlock()
+			}
+			continue
+		}
+		if !job.IsComplete() {
+			continue
+		}
+
+		c.mu.Lock()
+		waiting := c.pending[jobID]
+		c.mu.Unlock()
+		var failed []pending
+		for _, p := range waiting {
+			if err := c.reply(ctx, p.replyTo, c.finished(ctx, job, p)); err != nil {
+				c.logger.Warn("Failed to publish reply", zap.String("job_id", jobID), zap.String("reply_to", p.replyTo), zap.Error(err))
+				failed = append(failed, p)
+			}
+		}
+		c.mu.Lock()
+		if len(failed) > 0 {
+			c.pending[jobID] = failed
+		} else {
+			delete(c.pending, jobID)
+		}
+		c.mu.Unlock()
+
+		// The job no longer owes a reply after a restart
+		if len(failed) == 0 && job.Reply != nil {
+			job.Reply = nil
+			if err := c.jobs.UpdateJob(ctx, job); err != nil {
+				c.logger.Warn("Failed to record reply as published", zap.String("job_id", jobID), zap.Error(err))
+			}
+		}
+	}
+}
+
+// finished returns the reply to p for its finished job.
+func (c *Consumer) finished(ctx context.Context, job *domain.Job, p pending) Reply {
+	data := webhook.NewJobEventData(job)
+	if data.ResultURL != "" {
+		data.ResultURL = c.opts.PublicURL + data.ResultURL
+	}
+	reply := Reply{CorrelationID: p.correlationID, MessageID: p.messageID, Job: &data}
+
+	switch job.Status {
+	case domain.JobStatusCompleted:
+		reply.Type = domain.WebhookEventJobCompleted
+		if c.opts.ResultURLs != nil {
+			signed, err := c.opts.ResultURLs.SignedURL(ctx, job.ID, c.opts.SignedURLTTL, "")
+			if err != nil {
+				c.logger.Warn("Failed to sign result URL", zap.String("job_id", job.ID), zap.Error(err))
+			}
+			reply.SignedURL = signed
+		}
+	case domain.JobStatusFailed:
+		reply.Type = domain.WebhookEventJobFailed
+	case domain.JobStatusCancelled:
+		reply.Type = ReplyJobCancelled
+	default:
+		reply.Type = ReplyJobExpired
+	}
+	return reply
+}
+
+// reply publishes reply to topic; an empty topic publishes nothing.
+func (c *Consumer) reply(ctx context.Context, topic string, reply Reply) error {
+	if topic == "" {
+		return nil
+	}
+	data, err := json.Marshal(reply)
+	if err != nil {
+		return err
+	}
+	return c.broker.Publish(ctx, topic, data)
+}
+
+// response collects the API's answer to a submission.
+type response struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *response) Header() http.Header {
+	return r.header
+}
+
+func (r *response) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *response) Write(p []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(p)
+}
+
+// sleep waits for d, and reports whether ctx is still live.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
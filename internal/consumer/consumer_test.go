@@ -0,0 +1,242 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/api/middleware"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/queue/memory"
+)
+
+// fakeBroker records what the consumer acknowledges and publishes.
+type fakeBroker struct {
+	mu        sync.Mutex
+	acked     []string
+	published map[string][]Reply
+}
+
+func newFakeBroker() *fakeBroker {
+	return &fakeBroker{published: make(map[string][]Reply)}
+}
+
+func (b *fakeBroker) Receive(ctx context.Context) ([]Message, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (b *fakeBroker) Ack(_ context.Context, msg Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.acked = append(b.acked, msg.ID)
+	return nil
+}
+
+func (b *fakeBroker) Publish(_ context.Context, topic string, data []byte) error {
+	var reply Reply
+	if err := json.Unmarshal(data, &reply); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.published[topic] = append(b.published[topic], reply)
+	return nil
+}
+
+func (b *fakeBroker) Close() error {
+	return nil
+}
+
+// fakeAPI creates jobs in queue. Texts steer it: "reject" is refused, and
+// "busy" is answered 503 on its first submission, to be retried after
+// retryAfter.
+type fakeAPI struct {
+	queue      *memory.Queue
+	busy       bool
+	retryAfter string
+	// headers are those of the last submission.
+	headers http.Header
+}
+
+func (a *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.headers = r.Header
+	var req struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Text == "reject" {
+		middleware.WriteError(w, domain.ErrValidation)
+		return
+	}
+	if req.Text == "busy" && !a.busy {
+		a.busy = true
+		w.Header().Set("Retry-After", a.retryAfter)
+		middleware.WriteError(w, domain.ErrQueueBusy)
+		return
+	}
+	job := domain.NewJob(req.Text, "voice", "", "", "elevenlabs", "mp3", nil)
+	job.Reply = domain.JobReplyFromContext(r.Context())
+	a.queue.Enqueue(r.Context(), job) //nolint:errcheck
+	middleware.WriteJSON(w, http.StatusCreated, map[string]string{"job_id": job.ID})
+}
+
+func TestConsumer_Handle(t *testing.T) {
+	ctx := context.Background()
+	queue := memory.NewQueue(10)
+	api := &fakeAPI{queue: queue}
+	broker := newFakeBroker()
+	c := New(broker, api, queue, zap.NewNop(), Options{
+		APIKey:     "secret",
+		ReplyTopic: "replies",
+		PublicURL:  "https://tts.example.com/",
+	})
+
+	c.Handle(ctx, Message{ID: "m-1", Data: []byte(`{"text":"hello","reply_to":"mine","correlation_id":"c-1"}`)})
+	if api.headers.Get("X-API-Key") != "secret" || api.headers.Get("X-Request-ID") != "m-1" {
+		t.Errorf("submitted with headers %v", api.headers)
+	}
+	if len(broker.acked) != 1 || broker.acked[0] != "m-1" {
+		t.Fatalf("acked %v", broker.acked)
+	}
+	if len(c.pending) != 1 {
+		t.Fatalf("expected one pending job, got %d", len(c.pending))
+	}
+
+	// Nothing is published until the job finished
+	c.CheckPending(ctx)
+	if len(broker.published) != 0 {
+		t.Fatalf("published %v before the job finished", broker.published)
+	}
+	var jobID string
+	for id := range c.pending {
+		jobID = id
+	}
+	job, _ := queue.GetJob(ctx, jobID)
+	if job.Reply == nil || job.Reply.Topic != "mine" || job.Reply.CorrelationID != "c-1" || job.Reply.MessageID != "m-1" {
+		t.Errorf("job reply = %+v", job.Reply)
+	}
+	job.Status = domain.JobStatusCompleted
+	queue.UpdateJob(ctx, job) //nolint:errcheck
+
+	c.CheckPending(ctx)
+	replies := broker.published["mine"]
+	if len(replies) != 1 || len(c.pending) != 0 {
+		t.Fatalf("expected one reply on the request's topic, got %v", broker.published)
+	}
+	if job, _ := queue.GetJob(ctx, jobID); job.Reply != nil {
+		t.Errorf("reply still owed after it was published: %+v", job.Reply)
+	}
+	reply := replies[0]
+	if reply.Type != domain.WebhookEventJobCompleted || reply.CorrelationID != "c-1" || reply.MessageID != "m-1" {
+		t.Errorf("reply = %+v", reply)
+	}
+	if reply.Job == nil || reply.Job.ResultURL != "https://tts.example.com/api/v1/jobs/"+jobID+"/result" {
+		t.Errorf("job = %+v", reply.Job)
+	}
+}
+
+func TestConsumer_Handle_Rejected(t *testing.T) {
+	queue := memory.NewQueue(10)
+	broker := newFakeBroker()
+	c := New(broker, &fakeAPI{queue: queue}, queue, zap.NewNop(), Options{ReplyTopic: "replies"})
+
+	c.Handle(context.Background(), Message{ID: "m-1", Data: []byte(`{"text":"reject","correlation_id":"c-1"}`)})
+	replies := broker.published["replies"]
+	if len(replies) != 1 || len(broker.acked) != 1 || len(c.pending) != 0 {
+		t.Fatalf("expected a reply on the default topic, got %v", broker.published)
+	}
+	if replies[0].Type != ReplyJobRejected || replies[0].CorrelationID != "c-1" || replies[0].Error.Code != domain.ErrValidation.Code {
+		t.Errorf("reply = %+v", replies[0])
+	}
+
+	// A request that isn't JSON is rejected too
+	c.Handle(context.Background(), Message{ID: "m-2", Data: []byte("hello")})
+	if len(broker.published["replies"]) != 2 || len(broker.acked) != 2 {
+		t.Errorf("published %v", broker.published)
+	}
+}
+
+func TestConsumer_Handle_WaitsForBusyQueue(t *testing.T) {
+	queue := memory.NewQueue(10)
+	api := &fakeAPI{queue: queue, retryAfter: "0"}
+	broker := newFakeBroker()
+	c := New(broker, api, queue, zap.NewNop(), Options{})
+
+	c.Handle(context.Background(), Message{ID: "m-1", Data: []byte(`{"text":"busy"}`)})
+	if !api.busy || len(broker.acked) != 1 {
+		t.Fatalf("expected the request to be submitted again, acked %v", broker.acked)
+	}
+	// Without a reply topic nothing waits for the job
+	if len(c.pending) != 0 {
+		t.Errorf("pending = %v", c.pending)
+	}
+
+	// A request that is still waiting when the consumer stops isn't acknowledged
+	api.busy, api.retryAfter = false, "60"
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	c.Handle(ctx, Message{ID: "m-2", Data: []byte(`{"text":"busy"}`)})
+	if len(broker.acked) != 1 {
+		t.Errorf("acked %v", broker.acked)
+	}
+}
+
+func TestConsumer_CheckPending_JobGone(t *testing.T) {
+	queue := memory.NewQueue(10)
+	broker := newFakeBroker()
+	c := New(broker, &fakeAPI{queue: queue}, queue, zap.NewNop(), Options{})
+	c.pending["missing"] = []pending{{messageID: "m-1", replyTo: "replies"}}
+
+	c.CheckPending(context.Background())
+	if len(c.pending) != 0 || len(broker.published) != 0 {
+		t.Errorf("pending = %v, published %v", c.pending, broker.published)
+	}
+}
+
+func TestConsumer_Restore(t *testing.T) {
+	ctx := context.Background()
+	queue := memory.NewQueue(10)
+	owed := func(consumer, topic string) *domain.Job {
+		job := domain.NewJob("hello", "voice", "", "", "elevenlabs", "mp3", nil)
+		if topic != "" {
+			job.Reply = &domain.JobReply{Consumer: consumer, Topic: topic, CorrelationID: "c-" + topic, MessageID: "m-" + topic}
+		}
+		queue.Enqueue(ctx, job) //nolint:errcheck
+		return job
+	}
+	finished := owed("node-1", "finished")
+	running := owed("node-1", "running")
+	owed("node-2", "other")
+	owed("", "")
+	finished.Status = domain.JobStatusCompleted
+	queue.UpdateJob(ctx, finished) //nolint:errcheck
+
+	broker := newFakeBroker()
+	c := New(broker, &fakeAPI{queue: queue}, queue, zap.NewNop(), Options{Name: "node-1"})
+	if err := c.Restore(ctx); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if len(c.pending) != 2 || c.pending[finished.ID] == nil || c.pending[running.ID] == nil {
+		t.Fatalf("expected node-1's two jobs to be pending, got %v", c.pending)
+	}
+
+	c.CheckPending(ctx)
+	replies := broker.published["finished"]
+	if len(broker.published) != 1 || len(replies) != 1 || replies[0].CorrelationID != "c-finished" || replies[0].MessageID != "m-finished" {
+		t.Fatalf("published %v", broker.published)
+	}
+	if job, _ := queue.GetJob(ctx, finished.ID); job.Reply != nil {
+		t.Errorf("reply still owed after it was published: %+v", job.Reply)
+	}
+
+	// A published reply isn't restored again
+	c = New(broker, &fakeAPI{queue: queue}, queue, zap.NewNop(), Options{Name: "node-1"})
+	if err := c.Restore(ctx); err != nil || len(c.pending) != 1 || c.pending[running.ID] == nil {
+		t.Errorf("Restore = %v, pending %v", err, c.pending)
+	}
+}
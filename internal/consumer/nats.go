@@ -0,0 +1,135 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// natsWait is how long Receive waits for a message.
+	natsWait = 5 * time.Second
+	// natsTimeout bounds connecting.
+	natsTimeout = 10 * time.Second
+	// natsBatch is how many messages Receive returns at most.
+	natsBatch = 10
+	// natsBuffer is how many messages are held for Receive before the client
+	// drops new ones as a slow consumer.
+	natsBuffer = 64
+)
+
+// NATS reads requests from a NATS subject as a member of a queue group, so each
+// request goes to one instance, and publishes replies to other subjects. Core
+// NATS delivers at most once: requests sent while no instance is subscribed, or
+// read by one that stops before submitting them, are lost. A request's reply
+// subject, as set by a NATS request, is where its reply goes by default. The
+// client reconnects, and subscribes again, after the connection broke.
+type NATS struct {
+	conn *nats.Conn
+	msgs chan *nats.Msg
+	// wait is how long Receive waits for a message, natsWait.
+	wait time.Duration
+}
+
+// NewNATS connects to the NATS server at rawURL, nats://[token@]host[:port], or
+// tls:// for TLS, and subscribes to subject in the queue group group. A nats://
+// URL with a user and password is refused: the password would be sent in clear
+// text.
+func NewNATS(ctx context.Context, rawURL, subject, group string) (*NATS, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("nats: invalid URL: %w", err)
+	}
+	if u.Scheme != "nats" && u.Scheme != "tls" {
+		return nil, fmt.Errorf("nats: URL scheme must be nats or tls, got %q", u.Scheme)
+	}
+	if _, ok := u.User.Password(); ok && u.Scheme != "tls" {
+		return nil, errors.New("nats: user and password authentication needs a tls:// URL")
+	}
+
+	timeout := natsTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = min(timeout, time.Until(deadline))
+	}
+	conn, err := nats.Connect(rawURL,
+		nats.Name("pako-tts"),
+		nats.Timeout(timeout),
+		nats.MaxReconnects(-1),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("nats: %w", err)
+	}
+	n := &NATS{conn: conn, msgs: make(chan *nats.Msg, natsBuffer), wait: natsWait}
+	if group != "" {
+		_, err = conn.ChanQueueSubscribe(subject, group, n.msgs)
+	} else {
+		_, err = conn.ChanSubscribe(subject, n.msgs)
+	}
+	if err == nil {
+		// The subscription is in place once the server answered
+		err = conn.FlushTimeout(timeout)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("nats: subscribe to %s: %w", subject, err)
+	}
+	return n, nil
+}
+
+// Receive implements Broker.
+func (n *NATS) Receive(ctx context.Context) ([]Message, error) {
+	if n.conn.IsClosed() {
+		return nil, errors.New("nats: connection closed")
+	}
+	timer := time.NewTimer(n.wait)
+	defer timer.Stop()
+
+	var msgs []Message
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		return nil, nil
+	case msg := <-n.msgs:
+		msgs = append(msgs, natsMessage(msg))
+	}
+	for len(msgs) < natsBatch {
+		select {
+		case msg := <-n.msgs:
+			msgs = append(msgs, natsMessage(msg))
+		default:
+			return msgs, nil
+		}
+	}
+	return msgs, nil
+}
+
+func natsMessage(msg *nats.Msg) Message {
+	return Message{ID: uuid.NewString(), Data: msg.Data, ReplyTo: msg.Reply}
+}
+
+// Ack implements Broker. Core NATS doesn't redeliver, so there's nothing to
+// acknowledge.
+func (n *NATS) Ack(context.Context, Message) error {
+	return nil
+}
+
+// Publish implements Broker. While the client reconnects, messages are
+// buffered and sent once it's back.
+func (n *NATS) Publish(_ context.Context, topic string, data []byte) error {
+	if err := n.conn.Publish(topic, data); err != nil {
+		return fmt.Errorf("nats: publish to %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Close implements Broker.
+func (n *NATS) Close() error {
+	n.conn.Close()
+	return nil
+}
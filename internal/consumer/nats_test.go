@@ -0,0 +1,81 @@
+package consumer
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+// newNATSServer starts a NATS server on a free port that accepts token, and
+// returns its address.
+func newNATSServer(t *testing.T, token string) string {
+	t.Helper()
+	srv, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, Authorization: token, NoSigs: true, NoLog: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Start()
+	t.Cleanup(srv.Shutdown)
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server didn't start")
+	}
+	return srv.Addr().String()
+}
+
+func TestNATS(t *testing.T) {
+	ctx := context.Background()
+	addr := newNATSServer(t, "token")
+	n, err := NewNATS(ctx, "nats://token@"+addr, "requests", "pako-tts")
+	if err != nil {
+		t.Fatalf("NewNATS: %v", err)
+	}
+	defer n.Close()
+	n.wait = 10 * time.Millisecond
+
+	client, err := nats.Connect("nats://token@" + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	replies, err := client.SubscribeSync("replies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.PublishRequest("requests", "_INBOX.abc", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	var msgs []Message
+	for deadline := time.Now().Add(time.Second); len(msgs) == 0 && time.Now().Before(deadline); {
+		if msgs, err = n.Receive(ctx); err != nil {
+			t.Fatalf("Receive: %v", err)
+		}
+	}
+	if len(msgs) != 1 || string(msgs[0].Data) != "hello" || msgs[0].ReplyTo != "_INBOX.abc" || msgs[0].ID == "" {
+		t.Fatalf("Receive = %+v", msgs)
+	}
+
+	if err := n.Publish(ctx, "replies", []byte(`{"type":"job.completed"}`)); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	reply, err := replies.NextMsg(time.Second)
+	if err != nil || string(reply.Data) != `{"type":"job.completed"}` {
+		t.Errorf("reply = %v, %v", reply, err)
+	}
+}
+
+func TestNATS_InvalidURL(t *testing.T) {
+	addr := newNATSServer(t, "")
+	for _, rawURL := range []string{"http://" + addr, "nats://user:secret@" + addr} {
+		if _, err := NewNATS(context.Background(), rawURL, "requests", ""); err == nil {
+			t.Errorf("%s: expected an error", rawURL)
+		}
+	}
+	if _, err := NewNATS(context.Background(), "nats://user:secret@"+addr, "requests", ""); err == nil || !strings.Contains(err.Error(), "tls://") {
+		t.Errorf("expected a password without TLS refused, got %v", err)
+	}
+}
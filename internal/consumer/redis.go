@@ -0,0 +1,181 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// redisBlock is how long a read waits for new entries.
+	redisBlock = 5 * time.Second
+	// redisTimeout bounds every other command, and a read beyond redisBlock.
+	redisTimeout = 10 * time.Second
+	// redisBatch is how many entries a read returns at most.
+	redisBatch = 10
+	// Entries another consumer of the group read but didn't acknowledge for
+	// claimIdle, e.g. because its instance went away, are claimed every
+	// claimInterval.
+	claimIdle     = 5 * time.Minute
+	claimInterval = time.Minute
+	// redisField is the field of a stream entry holding the request, and of a
+	// reply.
+	redisField = "data"
+)
+
+// Redis reads requests from a Redis stream as a member of a consumer group, and
+// publishes replies to other streams with XADD. Entries are acknowledged once
+// their job was created; those an instance read but didn't acknowledge are read
+// again when it restarts, or claimed by another member after claimIdle. Needs
+// Redis 6.2 or later.
+type Redis struct {
+	client *redis.Client
+	stream string
+	group  string
+	name   string
+	// block is how long a read waits for new entries, redisBlock.
+	block time.Duration
+
+	// recovered is set once the entries this consumer read before a restart
+	// were read again; claimed is when entries were last claimed.
+	recovered bool
+	claimed   time.Time
+}
+
+// NewRedis connects to the Redis server at rawURL, redis://[user:password@]host[:port][/db]
+// or rediss:// for TLS, and joins group on stream as name, creating both if needed.
+func NewRedis(ctx context.Context, rawURL, stream, group, name string) (*Redis, error) {
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("redis: invalid URL: %w", err)
+	}
+	opts.DialTimeout = redisTimeout
+	opts.ReadTimeout = redisTimeout
+	opts.WriteTimeout = redisTimeout
+	r := &Redis{
+		client: redis.NewClient(opts),
+		stream: stream,
+		group:  group,
+		name:   name,
+		block:  redisBlock,
+	}
+	// Reading from the start of a new stream takes requests sent before the first start
+	err = r.client.XGroupCreateMkStream(ctx, stream, group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		r.Close() //nolint:errcheck
+		return nil, fmt.Errorf("redis: create consumer group: %w", err)
+	}
+	return r, nil
+}
+
+// Receive implements Broker. It first reads the entries delivered to this
+// consumer but never acknowledged, then claims those idle elsewhere in the
+// group, then waits for new ones.
+func (r *Redis) Receive(ctx context.Context) ([]Message, error) {
+	if !r.recovered {
+		streams, err := r.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    r.group,
+			Consumer: r.name,
+			Streams:  []string{r.stream, "0"},
+			Count:    redisBatch,
+			Block:    -1,
+		}).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, fmt.Errorf("redis: read pending entries: %w", err)
+		}
+		msgs, err := r.messages(ctx, streamEntries(streams))
+		r.recovered = err == nil && len(msgs) == 0
+		if err != nil || len(msgs) > 0 {
+			return msgs, err
+		}
+	}
+
+	if time.Since(r.claimed) >= claimInterval {
+		entries, _, err := r.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   r.stream,
+			Group:    r.group,
+			Consumer: r.name,
+			MinIdle:  claimIdle,
+			Start:    "0-0",
+			Count:    redisBatch,
+		}).Result()
+		if err != nil {
+			return nil, fmt.Errorf("redis: claim idle entries: %w", err)
+		}
+		msgs, err := r.messages(ctx, entries)
+		if err != nil || len(msgs) > 0 {
+			return msgs, err
+		}
+		r.claimed = time.Now()
+	}
+
+	streams, err := r.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    r.group,
+		Consumer: r.name,
+		Streams:  []string{r.stream, ">"},
+		Count:    redisBatch,
+		Block:    r.block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis: read entries: %w", err)
+	}
+	return r.messages(ctx, streamEntries(streams))
+}
+
+// messages turns stream entries into messages. Entries deleted from the stream
+// since they were read have no fields left, and are acknowledged right away.
+func (r *Redis) messages(ctx context.Context, entries []redis.XMessage) ([]Message, error) {
+	var msgs []Message
+	for _, entry := range entries {
+		if len(entry.Values) == 0 {
+			if err := r.Ack(ctx, Message{ID: entry.ID}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		msg := Message{ID: entry.ID}
+		if value, ok := entry.Values[redisField].(string); ok {
+			msg.Data = []byte(value)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// streamEntries returns the entries of the one stream an XREADGROUP read.
+func streamEntries(streams []redis.XStream) []redis.XMessage {
+	if len(streams) == 0 {
+		return nil
+	}
+	return streams[0].Messages
+}
+
+// Ack implements Broker.
+func (r *Redis) Ack(ctx context.Context, msg Message) error {
+	if err := r.client.XAck(ctx, r.stream, r.group, msg.ID).Err(); err != nil {
+		return fmt.Errorf("redis: XACK: %w", err)
+	}
+	return nil
+}
+
+// Publish implements Broker, adding an entry with data in its data field to the
+// stream topic.
+func (r *Redis) Publish(ctx context.Context, topic string, data []byte) error {
+	err := r.client.XAdd(ctx, &redis.XAddArgs{Stream: topic, Values: []string{redisField, string(data)}}).Err()
+	if err != nil {
+		return fmt.Errorf("redis: XADD: %w", err)
+	}
+	return nil
+}
+
+// Close implements Broker.
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
@@ -0,0 +1,75 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedis(t *testing.T) {
+	ctx := context.Background()
+	m := miniredis.RunT(t)
+	m.RequireAuth("pw")
+
+	// host-1 read an entry before it restarted, and host-2 one that was deleted since
+	setup := redis.NewClient(&redis.Options{Addr: m.Addr(), Password: "pw", DB: 2})
+	defer setup.Close()
+	setup.XGroupCreateMkStream(ctx, "requests", "pako-tts", "0")
+	for _, id := range []string{"1-0", "2-0"} {
+		setup.XAdd(ctx, &redis.XAddArgs{Stream: "requests", ID: id, Values: []string{"data", `{"text":"hi"}`}})
+	}
+	for _, name := range []string{"host-1", "host-2"} {
+		if err := setup.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group: "pako-tts", Consumer: name, Streams: []string{"requests", ">"}, Count: 1, Block: -1,
+		}).Err(); err != nil {
+			t.Fatalf("XREADGROUP: %v", err)
+		}
+	}
+	setup.XDel(ctx, "requests", "2-0")
+	m.FastForward(claimIdle)
+
+	r, err := NewRedis(ctx, "redis://:pw@"+m.Addr()+"/2", "requests", "pako-tts", "host-1")
+	if err != nil {
+		t.Fatalf("NewRedis: %v", err)
+	}
+	defer r.Close()
+	r.block = 10 * time.Millisecond
+
+	// Entries read before a restart come first
+	msgs, err := r.Receive(ctx)
+	if err != nil || len(msgs) != 1 || msgs[0].ID != "1-0" || string(msgs[0].Data) != `{"text":"hi"}` {
+		t.Fatalf("Receive = %+v, %v", msgs, err)
+	}
+	if err := r.Ack(ctx, msgs[0]); err != nil {
+		t.Errorf("Ack: %v", err)
+	}
+
+	// Then the claimed entry that was deleted is acknowledged, and nothing new came in
+	msgs, err = r.Receive(ctx)
+	if err != nil || len(msgs) != 0 {
+		t.Fatalf("Receive = %+v, %v", msgs, err)
+	}
+	if pending := setup.XPending(ctx, "requests", "pako-tts").Val(); pending.Count != 0 {
+		t.Errorf("expected no pending entries, got %+v", pending)
+	}
+
+	if err := r.Publish(ctx, "replies", []byte(`{"type":"job.completed"}`)); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	replies := setup.XRange(ctx, "replies", "-", "+").Val()
+	if len(replies) != 1 || replies[0].Values["data"] != `{"type":"job.completed"}` {
+		t.Errorf("replies = %+v", replies)
+	}
+}
+
+func TestNewRedis_InvalidURL(t *testing.T) {
+	m := miniredis.RunT(t)
+	for _, rawURL := range []string{"http://" + m.Addr(), "redis://" + m.Addr() + "/db"} {
+		if _, err := NewRedis(context.Background(), rawURL, "requests", "pako-tts", "host-1"); err == nil {
+			t.Errorf("%s: expected an error", rawURL)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+const (
+	// sqsWait is how long a receive long-polls for messages, at most 20 seconds.
+	sqsWait = 20
+	// sqsBatch is how many messages a receive returns at most, at most 10.
+	sqsBatch = 10
+)
+
+// SQS reads requests from an Amazon SQS queue and sends replies to other
+// queues, named by their URLs. A message is deleted once its job was created;
+// one that isn't, e.g. because the instance stopped, is received again after the
+// queue's visibility timeout.
+//
+// Credentials are found the way the AWS SDK does: AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN, the shared config files, else the
+// role the server runs as.
+type SQS struct {
+	client   *sqs.Client
+	queueURL string
+}
+
+// NewSQS returns a broker for the queue at queueURL. An empty region is taken
+// from the queue's host, e.g. sqs.eu-west-1.amazonaws.com.
+func NewSQS(ctx context.Context, queueURL, region string) (*SQS, error) {
+	u, err := url.Parse(queueURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("sqs: invalid queue URL %q", queueURL)
+	}
+	if region == "" {
+		region = sqsRegion(u.Hostname())
+	}
+	if region == "" {
+		return nil, fmt.Errorf("sqs: no region in queue URL %q; set consumer.region", queueURL)
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("sqs: %w", err)
+	}
+	return &SQS{client: sqs.NewFromConfig(cfg), queueURL: queueURL}, nil
+}
+
+// sqsRegion returns the region of an SQS endpoint, sqs.<region>.amazonaws.com,
+// or "" for other hosts.
+func sqsRegion(host string) string {
+	parts := strings.Split(host, ".")
+	if len(parts) >= 4 && parts[0] == "sqs" && parts[2] == "amazonaws" {
+		return parts[1]
+	}
+	return ""
+}
+
+// queueEndpoint sends a call to the endpoint of the queue at queueURL: that of
+// its region for a queue of AWS, else its own host, e.g. LocalStack's.
+func queueEndpoint(queueURL string) func(*sqs.Options) {
+	return func(o *sqs.Options) {
+		u, err := url.Parse(queueURL)
+		if err != nil || u.Host == "" {
+			return
+		}
+		if region := sqsRegion(u.Hostname()); region != "" {
+			o.Region = region
+			return
+		}
+		o.BaseEndpoint = aws.String(u.Scheme + "://" + u.Host)
+	}
+}
+
+// Receive implements Broker.
+func (s *SQS) Receive(ctx context.Context) ([]Message, error) {
+	out, err := s.client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(s.queueURL),
+		MaxNumberOfMessages: sqsBatch,
+		WaitTimeSeconds:     sqsWait,
+	}, queueEndpoint(s.queueURL))
+	if err != nil {
+		return nil, fmt.Errorf("sqs: %w", err)
+	}
+	msgs := make([]Message, 0, len(out.Messages))
+	for _, m := range out.Messages {
+		msgs = append(msgs, Message{
+			ID:      aws.ToString(m.MessageId),
+			Data:    []byte(aws.ToString(m.Body)),
+			receipt: aws.ToString(m.ReceiptHandle),
+		})
+	}
+	return msgs, nil
+}
+
+// Ack implements Broker, deleting msg from the queue.
+func (s *SQS) Ack(ctx context.Context, msg Message) error {
+	_, err := s.client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(s.queueURL),
+		ReceiptHandle: aws.String(msg.receipt),
+	}, queueEndpoint(s.queueURL))
+	if err != nil {
+		return fmt.Errorf("sqs: %w", err)
+	}
+	return nil
+}
+
+// Publish implements Broker, sending data to the queue at the URL topic.
+func (s *SQS) Publish(ctx context.Context, topic string, data []byte) error {
+	_, err := s.client.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(topic),
+		MessageBody: aws.String(string(data)),
+	}, queueEndpoint(topic))
+	if err != nil {
+		return fmt.Errorf("sqs: %w", err)
+	}
+	return nil
+}
+
+// Close implements Broker.
+func (s *SQS) Close() error {
+	return nil
+}
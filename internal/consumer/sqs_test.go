@@ -0,0 +1,84 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSQS(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "session")
+	var calls []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auth, "/us-east-1/sqs/aws4_request") ||
+			r.Header.Get("X-Amz-Security-Token") != "session" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var in map[string]any
+		json.NewDecoder(r.Body).Decode(&in) //nolint:errcheck
+		in["action"] = r.Header.Get("X-Amz-Target")
+		calls = append(calls, in)
+		switch {
+		case strings.HasSuffix(in["QueueUrl"].(string), "/missing"):
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"com.amazonaws.sqs#QueueDoesNotExist","message":"The specified queue does not exist."}`)) //nolint:errcheck
+		case in["action"] == "AmazonSQS.ReceiveMessage":
+			w.Write([]byte(`{"Messages":[{"MessageId":"m-1","ReceiptHandle":"r-1","Body":"{\"text\":\"hi\"}"}]}`)) //nolint:errcheck
+		default:
+			w.Write([]byte(`{}`)) //nolint:errcheck
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	if _, err := NewSQS(ctx, srv.URL+"/123/requests", ""); err == nil {
+		t.Fatal("expected an error without a region")
+	}
+	s, err := NewSQS(ctx, srv.URL+"/123/requests", "us-east-1")
+	if err != nil {
+		t.Fatalf("NewSQS: %v", err)
+	}
+
+	msgs, err := s.Receive(ctx)
+	if err != nil || len(msgs) != 1 || msgs[0].ID != "m-1" || string(msgs[0].Data) != `{"text":"hi"}` {
+		t.Fatalf("Receive = %+v, %v", msgs, err)
+	}
+	if calls[0]["WaitTimeSeconds"] != float64(20) || calls[0]["MaxNumberOfMessages"] != float64(10) {
+		t.Errorf("receive request = %v", calls[0])
+	}
+	if err := s.Ack(ctx, msgs[0]); err != nil || calls[1]["ReceiptHandle"] != "r-1" || calls[1]["action"] != "AmazonSQS.DeleteMessage" {
+		t.Errorf("Ack = %v, request %v", err, calls[1])
+	}
+	if err := s.Publish(ctx, srv.URL+"/123/replies", []byte(`{"type":"job.completed"}`)); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if calls[2]["QueueUrl"] != srv.URL+"/123/replies" || calls[2]["MessageBody"] != `{"type":"job.completed"}` {
+		t.Errorf("send request = %v", calls[2])
+	}
+
+	err = s.Publish(ctx, srv.URL+"/123/missing", []byte("{}"))
+	if err == nil || !strings.Contains(err.Error(), "QueueDoesNotExist: The specified queue does not exist.") {
+		t.Errorf("expected the queue to be missing, got %v", err)
+	}
+}
+
+func TestSQSRegion(t *testing.T) {
+	for host, want := range map[string]string{
+		"sqs.eu-west-1.amazonaws.com":     "eu-west-1",
+		"sqs.cn-north-1.amazonaws.com.cn": "cn-north-1",
+		"sqs.amazonaws.com":               "",
+		"localhost":                       "",
+		"sqs.us-east-1.example.com":       "",
+	} {
+		if got := sqsRegion(host); got != want {
+			t.Errorf("sqsRegion(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
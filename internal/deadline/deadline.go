@@ -0,0 +1,52 @@
+// Package deadline carries the time by which a caller needs an answer across
+// HTTP hops, so downstream proxies and services can drop work nobody waits for.
+package deadline
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Header carries a deadline as an RFC 3339 timestamp, e.g.
+// "2026-10-16T12:00:05.250Z".
+const Header = "X-Deadline"
+
+const layout = "2006-01-02T15:04:05.000Z07:00"
+
+// Format formats t as a Header value.
+func Format(t time.Time) string {
+	return t.UTC().Format(layout)
+}
+
+// Parse parses a Header value.
+func Parse(value string) (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// FromRequest returns the deadline r's Header asks for; ok is false without one.
+func FromRequest(r *http.Request) (t time.Time, ok bool, err error) {
+	value := r.Header.Get(Header)
+	if value == "" {
+		return time.Time{}, false, nil
+	}
+	t, err = Parse(value)
+	return t, err == nil, err
+}
+
+// Set sets Header on an outgoing request to the deadline of its context, if it
+// has one.
+func Set(req *http.Request) {
+	if t, ok := req.Context().Deadline(); ok {
+		req.Header.Set(Header, Format(t))
+	}
+}
+
+// WithJob returns ctx bounded by a job's deadline; a nil deadline leaves ctx as
+// it is.
+func WithJob(ctx context.Context, jobDeadline *time.Time) (context.Context, context.CancelFunc) {
+	if jobDeadline == nil {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, *jobDeadline)
+}
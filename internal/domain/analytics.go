@@ -0,0 +1,165 @@
+package domain
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// JobAnalytics aggregates finished jobs, and archived ones, for reporting. Job
+// queues backed by a job store implement it.
+type JobAnalytics interface {
+	Analytics(ctx context.Context, query AnalyticsQuery) (*Analytics, error)
+}
+
+// AnalyticsQuery selects the jobs to aggregate: those submitted in [From, To),
+// optionally of one tenant only.
+type AnalyticsQuery struct {
+	From   time.Time
+	To     time.Time
+	Tenant string
+}
+
+// Matches reports whether a job falls in the query. Only finished jobs count.
+func (q AnalyticsQuery) Matches(job *Job) bool {
+	return job.IsComplete() && q.matches(job.CreatedAt, job.Tenant())
+}
+
+// MatchesArchived reports whether an archived job falls in the query.
+func (q AnalyticsQuery) MatchesArchived(job *ArchivedJob) bool {
+	return q.matches(job.CreatedAt, job.Tenant())
+}
+
+func (q AnalyticsQuery) matches(createdAt time.Time, tenant string) bool {
+	if createdAt.Before(q.From) || !createdAt.Before(q.To) {
+		return false
+	}
+	return q.Tenant == "" || tenant == q.Tenant
+}
+
+// topVoicesLimit is how many voices each analytics bucket lists.
+const topVoicesLimit = 5
+
+// Analytics is the report for an AnalyticsQuery: totals over the whole range and
+// one bucket per UTC day jobs were submitted on, including days without jobs.
+type Analytics struct {
+	From   string            `json:"from"`
+	To     string            `json:"to"`
+	Tenant string            `json:"tenant,omitempty"`
+	Totals AnalyticsBucket   `json:"totals"`
+	Days   []AnalyticsBucket `json:"days"`
+}
+
+// AnalyticsBucket aggregates the finished jobs of a day, or of the whole range.
+// SuccessRate is completed over completed plus failed jobs; cancellations are left
+// out. Jobs whose result has since expired count as completed. AvgLatencySeconds is the mean time from submission to completion of the
+// completed jobs.
+type AnalyticsBucket struct {
+	Date              string       `json:"date,omitempty"`
+	Jobs              int          `json:"jobs"`
+	Completed         int          `json:"completed"`
+	Failed            int          `json:"failed"`
+	Cancelled         int          `json:"cancelled"`
+	SuccessRate       float64      `json:"success_rate"`
+	Characters        int64        `json:"characters"`
+	AudioMinutes      float64      `json:"audio_minutes"`
+	AvgLatencySeconds float64      `json:"avg_latency_seconds"`
+	TopVoices         []VoiceUsage `json:"top_voices"`
+
+	latency time.Duration
+	voices  map[string]int
+}
+
+// VoiceUsage counts the jobs of one voice.
+type VoiceUsage struct {
+	VoiceID string `json:"voice_id"`
+	Jobs    int    `json:"jobs"`
+}
+
+// NewAnalytics returns an empty report for query, with a bucket for every day of
+// its range. Jobs are added with Add; Finish computes the rates and averages.
+func NewAnalytics(query AnalyticsQuery) *Analytics {
+	a := &Analytics{
+		From:   query.From.UTC().Format(time.DateOnly),
+		To:     query.To.UTC().Add(-time.Nanosecond).Format(time.DateOnly),
+		Tenant: query.Tenant,
+		Days:   []AnalyticsBucket{},
+	}
+	for day := truncateDay(query.From); day.Before(query.To); day = day.AddDate(0, 0, 1) {
+		a.Days = append(a.Days, AnalyticsBucket{Date: day.Format(time.DateOnly)})
+	}
+	return a
+}
+
+// Add counts a job, which must match the report's query, in its day and the totals.
+func (a *Analytics) Add(job *Job) {
+	a.AddArchived(NewArchivedJob(job))
+}
+
+// AddArchived counts an archived job like Add; an archived job counts as the job did.
+func (a *Analytics) AddArchived(job *ArchivedJob) {
+	a.Totals.add(job)
+	date := job.CreatedAt.UTC().Format(time.DateOnly)
+	i := sort.Search(len(a.Days), func(i int) bool { return a.Days[i].Date >= date })
+	if i < len(a.Days) && a.Days[i].Date == date {
+		a.Days[i].add(job)
+	}
+}
+
+// Finish computes each bucket's success rate, average latency and top voices.
+func (a *Analytics) Finish() *Analytics {
+	a.Totals.finish()
+	for i := range a.Days {
+		a.Days[i].finish()
+	}
+	return a
+}
+
+func (b *AnalyticsBucket) add(job *ArchivedJob) {
+	b.Jobs++
+	switch job.Status {
+	case JobStatusCompleted, JobStatusExpired:
+		b.Completed++
+		b.AudioMinutes += job.AudioSeconds / 60
+		if job.CompletedAt != nil {
+			b.latency += job.CompletedAt.Sub(job.CreatedAt)
+		}
+	case JobStatusFailed:
+		b.Failed++
+	case JobStatusCancelled:
+		b.Cancelled++
+	}
+	b.Characters += int64(job.Characters)
+	if b.voices == nil {
+		b.voices = make(map[string]int)
+	}
+	b.voices[job.VoiceID]++
+}
+
+func (b *AnalyticsBucket) finish() {
+	if n := b.Completed + b.Failed; n > 0 {
+		b.SuccessRate = float64(b.Completed) / float64(n)
+	}
+	if b.Completed > 0 {
+		b.AvgLatencySeconds = (b.latency / time.Duration(b.Completed)).Seconds()
+	}
+
+	b.TopVoices = make([]VoiceUsage, 0, len(b.voices))
+	for voice, jobs := range b.voices {
+		b.TopVoices = append(b.TopVoices, VoiceUsage{VoiceID: voice, Jobs: jobs})
+	}
+	sort.Slice(b.TopVoices, func(i, j int) bool {
+		if b.TopVoices[i].Jobs != b.TopVoices[j].Jobs {
+			return b.TopVoices[i].Jobs > b.TopVoices[j].Jobs
+		}
+		return b.TopVoices[i].VoiceID < b.TopVoices[j].VoiceID
+	})
+	if len(b.TopVoices) > topVoicesLimit {
+		b.TopVoices = b.TopVoices[:topVoicesLimit]
+	}
+}
+
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
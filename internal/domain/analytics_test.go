@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnalytics(t *testing.T) {
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	query := AnalyticsQuery{From: day, To: day.AddDate(0, 0, 3), Tenant: "acme"}
+
+	finished := func(voice, tenant string, createdAt time.Time, status JobStatus) *Job {
+		job := NewJob("héllo", voice, "", "", "provider", "mp3", nil)
+		job.TenantID = tenant
+		job.CreatedAt = createdAt
+		switch status {
+		case JobStatusCompleted:
+			job.SetCompleted("/tmp/"+job.ID+".mp3", 1)
+			completedAt := createdAt.Add(4 * time.Second)
+			job.CompletedAt = &completedAt
+			job.AudioSeconds = 30
+		case JobStatusFailed:
+			job.SetFailed("boom")
+		case JobStatusCancelled:
+			job.SetCancelled("cancelled while queued")
+		}
+		return job
+	}
+
+	jobs := []*Job{
+		finished("rachel", "acme", day.Add(time.Hour), JobStatusCompleted),
+		finished("rachel", "acme", day.Add(2*time.Hour), JobStatusCompleted),
+		finished("adam", "acme", day.Add(3*time.Hour), JobStatusFailed),
+		finished("adam", "acme", day.AddDate(0, 0, 2), JobStatusCancelled),
+		finished("rachel", "other", day.Add(time.Hour), JobStatusCompleted),
+		finished("rachel", "acme", day.AddDate(0, 0, 3), JobStatusCompleted),
+		NewJob("pending", "rachel", "", "", "provider", "mp3", nil),
+	}
+
+	analytics := NewAnalytics(query)
+	for _, job := range jobs {
+		if query.Matches(job) {
+			analytics.Add(job)
+		}
+	}
+	analytics.Finish()
+
+	if analytics.From != "2026-03-10" || analytics.To != "2026-03-12" || len(analytics.Days) != 3 {
+		t.Fatalf("unexpected range %s..%s with %d days", analytics.From, analytics.To, len(analytics.Days))
+	}
+
+	first := analytics.Days[0]
+	if first.Jobs != 3 || first.Completed != 2 || first.Failed != 1 {
+		t.Errorf("unexpected counts %+v", first)
+	}
+	if first.SuccessRate < 0.66 || first.SuccessRate > 0.67 {
+		t.Errorf("expected a success rate of 2/3, got %f", first.SuccessRate)
+	}
+	if first.Characters != 15 || first.AudioMinutes != 1 || first.AvgLatencySeconds != 4 {
+		t.Errorf("unexpected totals %+v", first)
+	}
+	if len(first.TopVoices) != 2 || first.TopVoices[0] != (VoiceUsage{VoiceID: "rachel", Jobs: 2}) {
+		t.Errorf("unexpected top voices %+v", first.TopVoices)
+	}
+
+	if analytics.Days[1].Jobs != 0 || analytics.Days[2].Cancelled != 1 {
+		t.Errorf("unexpected later days %+v", analytics.Days[1:])
+	}
+	if analytics.Totals.Jobs != 4 || analytics.Totals.Cancelled != 1 || analytics.Totals.SuccessRate != first.SuccessRate {
+		t.Errorf("unexpected totals %+v", analytics.Totals)
+	}
+}
@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"context"
+	"time"
+	"unicode/utf8"
+)
+
+// JobArchive keeps compact records of jobs whose result expired, so the jobs
+// themselves, with their text, can be removed while analytics still count them.
+// Job queues backed by a job store implement it.
+type JobArchive interface {
+	// ArchiveJob replaces a job with its archived record. Archiving a job that is
+	// already archived is a no-op.
+	ArchiveJob(ctx context.Context, job *Job) error
+}
+
+// ArchivedJob is what is kept of a job once it is archived: how it was made and
+// what it produced, without its text, audio or history.
+type ArchivedJob struct {
+	ID              string     `json:"job_id"`
+	Status          JobStatus  `json:"status"`
+	TenantID        string     `json:"tenant_id,omitempty"`
+	VoiceID         string     `json:"voice_id"`
+	ModelID         string     `json:"model_id,omitempty"`
+	LanguageCode    string     `json:"language_code,omitempty"`
+	ProviderName    string     `json:"provider_name"`
+	ResultProvider  string     `json:"result_provider,omitempty"`
+	OutputFormat    string     `json:"output_format"`
+	BatchID         string     `json:"batch_id,omitempty"`
+	Attempts        int        `json:"attempts,omitempty"`
+	Characters      int        `json:"characters"`
+	AudioSeconds    float64    `json:"audio_seconds,omitempty"`
+	ResultSizeBytes int64      `json:"result_size_bytes,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+	ArchivedAt      time.Time  `json:"archived_at"`
+}
+
+// NewArchivedJob returns the archived record of job.
+func NewArchivedJob(job *Job) *ArchivedJob {
+	return &ArchivedJob{
+		ID:              job.ID,
+		Status:          job.Status,
+		TenantID:        job.TenantID,
+		VoiceID:         job.VoiceID,
+		ModelID:         job.ModelID,
+		LanguageCode:    job.LanguageCode,
+		ProviderName:    job.ProviderName,
+		ResultProvider:  job.ResultProvider,
+		OutputFormat:    job.OutputFormat,
+		BatchID:         job.BatchID,
+		Attempts:        job.Attempts,
+		Characters:      utf8.RuneCountInString(job.Text),
+		AudioSeconds:    job.AudioSeconds,
+		ResultSizeBytes: job.ResultSizeBytes,
+		CreatedAt:       job.CreatedAt,
+		CompletedAt:     job.CompletedAt,
+		ExpiresAt:       job.ExpiresAt,
+		ArchivedAt:      time.Now().UTC(),
+	}
+}
+
+// Tenant returns the record's tenant, DefaultTenant when none was recorded.
+func (a *ArchivedJob) Tenant() string {
+	if a.TenantID == "" {
+		return DefaultTenant
+	}
+	return a.TenantID
+}
@@ -3,6 +3,7 @@ package domain
 import (
 	"fmt"
 	"net/http"
+	"slices"
 )
 
 // APIError represents an API error with HTTP status code.
@@ -11,6 +12,10 @@ type APIError struct {
 	Code       string         `json:"code"`
 	Message    string         `json:"message"`
 	Details    map[string]any `json:"details,omitempty"`
+	// Retryable and Hint describe the error in the error catalog: whether the
+	// same request can succeed later, and what a client can do about it.
+	Retryable bool   `json:"-"`
+	Hint      string `json:"-"`
 }
 
 // Error implements the error interface.
@@ -25,6 +30,8 @@ func (e *APIError) WithDetails(details map[string]any) *APIError {
 		Code:       e.Code,
 		Message:    e.Message,
 		Details:    details,
+		Retryable:  e.Retryable,
+		Hint:       e.Hint,
 	}
 }
 
@@ -35,80 +42,319 @@ func (e *APIError) WithMessage(msg string) *APIError {
 		Code:       e.Code,
 		Message:    msg,
 		Details:    e.Details,
+		Retryable:  e.Retryable,
+		Hint:       e.Hint,
 	}
 }
 
+// catalog holds the standard errors below in declaration order.
+var catalog []*APIError
+
+// register adds a standard error to the catalog.
+func register(e *APIError) *APIError {
+	catalog = append(catalog, e)
+	return e
+}
+
+// ErrorCatalog returns every standard error, so clients can look up the codes
+// the API answers with.
+func ErrorCatalog() []*APIError {
+	return slices.Clone(catalog)
+}
+
 // Standard API errors
 var (
 	// ErrJobNotFound indicates the requested job does not exist.
-	ErrJobNotFound = &APIError{
+	ErrJobNotFound = register(&APIError{
 		StatusCode: http.StatusNotFound,
 		Code:       "JOB_NOT_FOUND",
 		Message:    "Job not found",
-	}
+		Hint:       "Check the job ID. Jobs of the in-memory queue are lost when the server restarts, and archived jobs are no longer listed.",
+	})
 
 	// ErrResultExpired indicates the job result has expired.
-	ErrResultExpired = &APIError{
+	ErrResultExpired = register(&APIError{
 		StatusCode: http.StatusGone,
 		Code:       "RESULT_EXPIRED",
 		Message:    "Result has expired. Results are retained for 24 hours.",
-	}
+		Hint:       "POST to details.regenerate_url to synthesize the text again.",
+	})
+
+	// ErrArtifactNotFound indicates a derived file (preview, waveform, ...) is not available for the job.
+	ErrArtifactNotFound = register(&APIError{
+		StatusCode: http.StatusNotFound,
+		Code:       "ARTIFACT_NOT_FOUND",
+		Message:    "Artifact not available for this job",
+		Hint:       "GET /api/v1/jobs/{id}/artifacts lists the artifacts stored for the job.",
+	})
+
+	// ErrBatchNotFound indicates no jobs belong to the requested batch.
+	ErrBatchNotFound = register(&APIError{
+		StatusCode: http.StatusNotFound,
+		Code:       "BATCH_NOT_FOUND",
+		Message:    "Batch not found",
+		Hint:       "Check the batch ID returned when the batch was submitted.",
+	})
+
+	// ErrGroupNotFound indicates no jobs belong to the requested group.
+	ErrGroupNotFound = register(&APIError{
+		StatusCode: http.StatusNotFound,
+		Code:       "GROUP_NOT_FOUND",
+		Message:    "Group not found",
+		Hint:       "Check the group ID returned when the group was submitted.",
+	})
+
+	// ErrGroupNotComplete indicates a group's segments are still being synthesized.
+	ErrGroupNotComplete = register(&APIError{
+		StatusCode: http.StatusTooEarly,
+		Code:       "GROUP_NOT_COMPLETE",
+		Message:    "Group not yet completed",
+		Retryable:  true,
+		Hint:       "Poll GET /api/v1/groups/{id} and fetch the result once every segment has completed.",
+	})
+
+	// ErrGroupFailed indicates a segment of the group failed, was cancelled or
+	// expired, so the group has no complete result.
+	ErrGroupFailed = register(&APIError{
+		StatusCode: http.StatusConflict,
+		Code:       "GROUP_FAILED",
+		Message:    "A segment of the group has no result",
+		Hint:       "details.segments lists the segments without a result. The others still download from /api/v1/jobs/{id}/result; submit the group again for a joined result.",
+	})
+
+	// ErrWebhookNotFound indicates the requested webhook doesn't exist.
+	ErrWebhookNotFound = register(&APIError{
+		StatusCode: http.StatusNotFound,
+		Code:       "WEBHOOK_NOT_FOUND",
+		Message:    "Webhook not found",
+		Hint:       "GET /api/v1/webhooks lists the tenant's webhooks.",
+	})
 
 	// ErrJobNotComplete indicates the job is not yet complete.
-	ErrJobNotComplete = &APIError{
+	ErrJobNotComplete = register(&APIError{
 		StatusCode: http.StatusTooEarly,
 		Code:       "JOB_NOT_COMPLETE",
 		Message:    "Job not yet completed",
-	}
+		Retryable:  true,
+		Hint:       "Poll GET /api/v1/jobs/{id}, or register a callback_url, and fetch the result once the job has completed.",
+	})
+
+	// ErrJobNotCancellable indicates the job already finished and can't be cancelled.
+	ErrJobNotCancellable = register(&APIError{
+		StatusCode: http.StatusConflict,
+		Code:       "JOB_NOT_CANCELLABLE",
+		Message:    "Job has already finished",
+		Hint:       "The job already finished; its status says how.",
+	})
+
+	// ErrJobNotRetryable indicates the job didn't fail, so there is nothing to retry.
+	ErrJobNotRetryable = register(&APIError{
+		StatusCode: http.StatusConflict,
+		Code:       "JOB_NOT_RETRYABLE",
+		Message:    "Only failed jobs can be retried",
+		Hint:       "Cancel a job that is still queued or processing; regenerate a completed one with POST /api/v1/jobs/{id}/regenerate.",
+	})
 
 	// ErrValidation indicates a validation error.
-	ErrValidation = &APIError{
+	ErrValidation = register(&APIError{
 		StatusCode: http.StatusUnprocessableEntity,
 		Code:       "VALIDATION_ERROR",
 		Message:    "Validation failed",
-	}
+		Hint:       "Fix the fields named in details and send the request again.",
+	})
+
+	// ErrInvalidTextSource indicates a job's text source was rejected; details.source_error
+	// holds the per-source error code.
+	ErrInvalidTextSource = register(&APIError{
+		StatusCode: http.StatusUnprocessableEntity,
+		Code:       "INVALID_TEXT_SOURCE",
+		Message:    "Invalid text source",
+		Hint:       "Fix the source named by details.source_error.",
+	})
+
+	// ErrInvalidPipeline indicates a request's pipeline was rejected; details.index
+	// names the offending stage.
+	ErrInvalidPipeline = register(&APIError{
+		StatusCode: http.StatusUnprocessableEntity,
+		Code:       "INVALID_PIPELINE",
+		Message:    "Invalid pipeline",
+		Hint:       "Fix the stage at details.stage_index; GET /api/v1/pipeline/stages lists the stages and their params.",
+	})
 
 	// ErrTextTooLong indicates the text exceeds the sync endpoint limit.
-	ErrTextTooLong = &APIError{
+	ErrTextTooLong = register(&APIError{
 		StatusCode: http.StatusRequestEntityTooLarge,
 		Code:       "TEXT_TOO_LONG",
 		Message:    "Text exceeds 5000 character limit. Use POST /api/v1/jobs for longer texts.",
-	}
+		Hint:       "Submit longer texts to POST /api/v1/jobs.",
+	})
 
 	// ErrProviderNotFound indicates the requested provider doesn't exist.
-	ErrProviderNotFound = &APIError{
+	ErrProviderNotFound = register(&APIError{
 		StatusCode: http.StatusNotFound,
 		Code:       "PROVIDER_NOT_FOUND",
 		Message:    "Provider not found",
-	}
+		Hint:       "GET /api/v1/providers lists the configured providers.",
+	})
 
 	// ErrProviderUnavailable indicates the TTS provider is not available.
-	ErrProviderUnavailable = &APIError{
+	ErrProviderUnavailable = register(&APIError{
 		StatusCode: http.StatusServiceUnavailable,
 		Code:       "PROVIDER_UNAVAILABLE",
 		Message:    "TTS provider unavailable",
-	}
+		Retryable:  true,
+		Hint:       "Retry with backoff, or name another provider.",
+	})
+
+	// ErrQueueBusy indicates the job queue stayed full for the whole enqueue wait.
+	ErrQueueBusy = register(&APIError{
+		StatusCode: http.StatusServiceUnavailable,
+		Code:       "QUEUE_BUSY",
+		Message:    "Job queue is full. Retry shortly.",
+		Retryable:  true,
+		Hint:       "Retry after the delay in the Retry-After header.",
+	})
+
+	// ErrSyncDisabled indicates synchronous synthesis was switched off by an operator;
+	// clients should submit the request as an async job instead.
+	ErrSyncDisabled = register(&APIError{
+		StatusCode: http.StatusServiceUnavailable,
+		Code:       "SYNC_DISABLED",
+		Message:    "Synchronous synthesis is temporarily disabled. Submit the request to POST /api/v1/jobs instead.",
+		Retryable:  true,
+		Hint:       "Submit the request to POST /api/v1/jobs, or retry later.",
+	})
+
+	// ErrDraining indicates the instance's workers are draining and it takes no
+	// new jobs.
+	ErrDraining = register(&APIError{
+		StatusCode: http.StatusServiceUnavailable,
+		Code:       "DRAINING",
+		Message:    "This instance is draining and takes no new jobs",
+		Retryable:  true,
+		Hint:       "Submit the job to another instance, or retry once the instance was replaced.",
+	})
+
+	// ErrDrainInProgress indicates a drain was requested while another drain
+	// was in progress.
+	ErrDrainInProgress = register(&APIError{
+		StatusCode: http.StatusConflict,
+		Code:       "DRAIN_IN_PROGRESS",
+		Message:    "The workers are already draining",
+		Hint:       "GET /api/v1/admin/drain shows the drain in progress; only a scale_down drain can be sped up with shutdown.",
+	})
+
+	// ErrUnauthorized indicates a missing or unknown API key.
+	ErrUnauthorized = register(&APIError{
+		StatusCode: http.StatusUnauthorized,
+		Code:       "UNAUTHORIZED",
+		Message:    "Missing or invalid API key",
+		Hint:       "Send a valid API key in the X-API-Key header.",
+	})
+
+	// ErrIPNotAllowed indicates the client address is rejected by an IP allow/deny list.
+	ErrIPNotAllowed = register(&APIError{
+		StatusCode: http.StatusForbidden,
+		Code:       "IP_NOT_ALLOWED",
+		Message:    "Requests from this IP address are not allowed",
+		Hint:       "Ask the operator to allow the client's address.",
+	})
+
+	// ErrKeyQuarantined indicates the API key was flagged for unusual usage and is
+	// held back until an admin releases it.
+	ErrKeyQuarantined = register(&APIError{
+		StatusCode: http.StatusForbidden,
+		Code:       "KEY_QUARANTINED",
+		Message:    "This API key is quarantined pending review of unusual usage",
+		Hint:       "Contact the operator to have the key reviewed and released.",
+	})
+
+	// ErrKeyThrottled indicates the API key was flagged for unusual usage and may
+	// only submit at a reduced rate until an admin releases it.
+	ErrKeyThrottled = register(&APIError{
+		StatusCode: http.StatusTooManyRequests,
+		Code:       "KEY_THROTTLED",
+		Message:    "This API key is throttled pending review of unusual usage",
+		Retryable:  true,
+		Hint:       "Retry after the delay in the Retry-After header, and contact the operator to have the key reviewed.",
+	})
+
+	// ErrFlagNotFound indicates the API key has no abuse flag to release.
+	ErrFlagNotFound = register(&APIError{
+		StatusCode: http.StatusNotFound,
+		Code:       "FLAG_NOT_FOUND",
+		Message:    "API key is not flagged",
+		Hint:       "GET /api/v1/admin/abuse/flags lists the flagged keys.",
+	})
+
+	// ErrOperationNotFound indicates the bulk operation does not exist, or is no
+	// longer kept.
+	ErrOperationNotFound = register(&APIError{
+		StatusCode: http.StatusNotFound,
+		Code:       "OPERATION_NOT_FOUND",
+		Message:    "Operation not found",
+		Hint:       "GET /api/v1/admin/operations lists the operations kept; they don't survive a restart.",
+	})
 
 	// ErrInternalServer indicates an internal server error.
-	ErrInternalServer = &APIError{
+	ErrInternalServer = register(&APIError{
 		StatusCode: http.StatusInternalServerError,
 		Code:       "INTERNAL_ERROR",
 		Message:    "Internal server error",
-	}
+		Retryable:  true,
+		Hint:       "Retry with backoff; report it to the operator if it persists.",
+	})
+
+	// ErrVoiceNotFound indicates no provider offers the requested voice.
+	ErrVoiceNotFound = register(&APIError{
+		StatusCode: http.StatusNotFound,
+		Code:       "VOICE_NOT_FOUND",
+		Message:    "Voice not found",
+		Hint:       "GET /api/v1/voices lists the available voices.",
+	})
+
+	// ErrPreviewNotFound indicates the voice's provider offers no preview of it.
+	ErrPreviewNotFound = register(&APIError{
+		StatusCode: http.StatusNotFound,
+		Code:       "PREVIEW_NOT_FOUND",
+		Message:    "The voice has no preview",
+		Hint:       "Synthesize a short sample with POST /api/v1/tts instead.",
+	})
 
 	// ErrInvalidVoice indicates an invalid voice ID.
-	ErrInvalidVoice = &APIError{
+	ErrInvalidVoice = register(&APIError{
 		StatusCode: http.StatusUnprocessableEntity,
 		Code:       "INVALID_VOICE",
 		Message:    "Invalid voice_id",
-	}
+		Hint:       "GET /api/v1/voices lists the available voices.",
+	})
 
 	// ErrInvalidFormat indicates an invalid output format.
-	ErrInvalidFormat = &APIError{
+	ErrInvalidFormat = register(&APIError{
 		StatusCode: http.StatusUnprocessableEntity,
 		Code:       "INVALID_FORMAT",
-		Message:    "Invalid output_format. Must be 'mp3' or 'wav'.",
-	}
+		Message:    "Invalid output_format. Must be 'mp3', 'wav', 'ogg_opus', 'flac' or 'mulaw'.",
+		Hint:       "Use one of the formats named in the message.",
+	})
+
+	// ErrNotAcceptable indicates that the Accept header rules out every audio
+	// format the endpoint can serve.
+	ErrNotAcceptable = register(&APIError{
+		StatusCode: http.StatusNotAcceptable,
+		Code:       "NOT_ACCEPTABLE",
+		Message:    "None of the media types in the Accept header can be served.",
+		Hint:       "Accept one of the media types in details.supported, or pass ?format=.",
+	})
+
+	// ErrDeadlineExceeded indicates that the deadline a request or job set passed
+	// before the work could be done.
+	ErrDeadlineExceeded = register(&APIError{
+		StatusCode: http.StatusGatewayTimeout,
+		Code:       "DEADLINE_EXCEEDED",
+		Message:    "The deadline passed before the request could be served.",
+		Retryable:  true,
+		Hint:       "Retry with a later deadline, or submit an async job.",
+	})
 )
 
 // ErrorResponse wraps an API error for JSON response.
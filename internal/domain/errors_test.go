@@ -103,6 +103,8 @@ func TestStandardErrors(t *testing.T) {
 		{"ErrInternalServer", ErrInternalServer, http.StatusInternalServerError, "INTERNAL_ERROR"},
 		{"ErrInvalidVoice", ErrInvalidVoice, http.StatusUnprocessableEntity, "INVALID_VOICE"},
 		{"ErrInvalidFormat", ErrInvalidFormat, http.StatusUnprocessableEntity, "INVALID_FORMAT"},
+		{"ErrNotAcceptable", ErrNotAcceptable, http.StatusNotAcceptable, "NOT_ACCEPTABLE"},
+		{"ErrDeadlineExceeded", ErrDeadlineExceeded, http.StatusGatewayTimeout, "DEADLINE_EXCEEDED"},
 	}
 
 	for _, tt := range tests {
@@ -119,3 +121,32 @@ func TestStandardErrors(t *testing.T) {
 		})
 	}
 }
+
+func TestErrorCatalog(t *testing.T) {
+	catalog := ErrorCatalog()
+	if len(catalog) == 0 {
+		t.Fatal("expected the standard errors in the catalog")
+	}
+
+	codes := make(map[string]bool)
+	for _, e := range catalog {
+		if codes[e.Code] {
+			t.Errorf("code %s is listed twice", e.Code)
+		}
+		codes[e.Code] = true
+		if e.StatusCode == 0 || e.Message == "" || e.Hint == "" {
+			t.Errorf("%s: expected status, message and hint set, got %+v", e.Code, e)
+		}
+	}
+	for _, e := range []*APIError{ErrJobNotFound, ErrQueueBusy, ErrDeadlineExceeded} {
+		if !codes[e.Code] {
+			t.Errorf("expected %s in the catalog", e.Code)
+		}
+	}
+	if !ErrQueueBusy.Retryable || ErrValidation.Retryable {
+		t.Error("expected QUEUE_BUSY retryable and VALIDATION_ERROR not")
+	}
+	if !ErrQueueBusy.WithMessage("busy").Retryable {
+		t.Error("expected WithMessage to keep Retryable")
+	}
+}
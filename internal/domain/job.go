@@ -1,6 +1,9 @@
 package domain
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,27 +17,209 @@ const (
 	JobStatusProcessing JobStatus = "processing"
 	JobStatusCompleted  JobStatus = "completed"
 	JobStatusFailed     JobStatus = "failed"
+	JobStatusCancelled  JobStatus = "cancelled"
+	// JobStatusExpired is a completed job whose result was removed after its
+	// retention period.
+	JobStatusExpired JobStatus = "expired"
 )
 
+// ErrInvalidTransition is returned by the status setters that refuse to move a
+// job from its current status to the requested one.
+var ErrInvalidTransition = errors.New("invalid job status transition")
+
 // Job represents a TTS synthesis request submitted for processing.
 type Job struct {
-	ID                    string         `json:"job_id"`
-	Status                JobStatus      `json:"status"`
-	Text                  string         `json:"text,omitempty"`
-	VoiceID               string         `json:"voice_id"`
-	ModelID               string         `json:"model_id,omitempty"`
-	LanguageCode          string         `json:"language_code,omitempty"`
-	ProviderName          string         `json:"provider_name"`
-	OutputFormat          string         `json:"output_format"`
-	VoiceSettings         *VoiceSettings `json:"voice_settings,omitempty"`
-	CreatedAt             time.Time      `json:"created_at"`
-	StartedAt             *time.Time     `json:"started_at,omitempty"`
-	CompletedAt           *time.Time     `json:"completed_at,omitempty"`
-	ProgressPercentage    float64        `json:"progress_percentage"`
-	EstimatedCompletionAt *time.Time     `json:"estimated_completion_at,omitempty"`
-	ErrorMessage          string         `json:"error_message,omitempty"`
-	ResultPath            string         `json:"result_path,omitempty"`
-	ExpiresAt             *time.Time     `json:"expires_at,omitempty"`
+	ID                    string          `json:"job_id"`
+	Status                JobStatus       `json:"status"`
+	TenantID              string          `json:"tenant_id,omitempty"`
+	Text                  string          `json:"text,omitempty"`
+	VoiceID               string          `json:"voice_id"`
+	ModelID               string          `json:"model_id,omitempty"`
+	LanguageCode          string          `json:"language_code,omitempty"`
+	ProviderName          string          `json:"provider_name"`
+	OutputFormat          string          `json:"output_format"`
+	VoiceSettings         *VoiceSettings  `json:"voice_settings,omitempty"`
+	Padding               *PaddingOptions `json:"padding,omitempty"`
+	CreatedAt             time.Time       `json:"created_at"`
+	StartedAt             *time.Time      `json:"started_at,omitempty"`
+	CompletedAt           *time.Time      `json:"completed_at,omitempty"`
+	ProgressPercentage    float64         `json:"progress_percentage"`
+	EstimatedCompletionAt *time.Time      `json:"estimated_completion_at,omitempty"`
+	ErrorMessage          string          `json:"error_message,omitempty"`
+	ResultPath            string          `json:"result_path,omitempty"`
+	ExpiresAt             *time.Time      `json:"expires_at,omitempty"`
+	Attempts              int             `json:"attempts,omitempty"`
+	MaxAttempts           int             `json:"max_attempts,omitempty"`
+	NextAttemptAt         *time.Time      `json:"next_attempt_at,omitempty"`
+	Artifacts             []string        `json:"artifacts,omitempty"`
+	Events                []JobEvent      `json:"events,omitempty"`
+	// ArtifactExpiresAt holds when each artifact kept for a different period
+	// than the result expires; the others expire with the result.
+	ArtifactExpiresAt map[string]time.Time `json:"artifact_expires_at,omitempty"`
+	// DuplicateOf links a job to an identical one submitted shortly before it.
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+	// Source is where the worker fetches Text from when the job was submitted
+	// without inline text.
+	Source *TextSource `json:"source,omitempty"`
+	// ErrorCode classifies ErrorMessage for failures with a stable code.
+	ErrorCode string `json:"error_code,omitempty"`
+	// Redeliveries counts how often the job was queued again after its consumer
+	// failed to acknowledge it.
+	Redeliveries int `json:"redeliveries,omitempty"`
+	// AudioSeconds is the duration of the result, when known.
+	AudioSeconds float64 `json:"audio_seconds,omitempty"`
+	// ResultSizeBytes is the size of the stored result.
+	ResultSizeBytes int64 `json:"result_size_bytes,omitempty"`
+	// CacheKey marks a cache-warming job: its result is also stored in the speech
+	// cache under this key.
+	CacheKey string `json:"cache_key,omitempty"`
+	// Pipeline lists the text and audio stages run around synthesis, in order.
+	Pipeline []PipelineStage `json:"pipeline,omitempty"`
+	// BatchID groups the jobs submitted together, e.g. by one cache-warm request.
+	BatchID string `json:"batch_id,omitempty"`
+	// Segment is the 1-based position of the job in its group, a batch whose
+	// results are joined in order; 0 for jobs not in a group.
+	Segment int `json:"segment,omitempty"`
+	// ResultProvider is the provider that produced the result: ProviderName, or
+	// one of its fallbacks when ProviderName failed.
+	ResultProvider string `json:"result_provider,omitempty"`
+	// CallbackURL is POSTed the job's completion or failure event once it finishes.
+	CallbackURL string `json:"callback_url,omitempty"`
+	// Deadline is when the job's result stops being useful. Provider calls carry
+	// it on, and a job not synthesized by then fails instead of using quota.
+	Deadline *time.Time `json:"deadline,omitempty"`
+	// SpokenText is the text synthesized when a pipeline stage replaced Text,
+	// e.g. with a summary. Text keeps the original for audit.
+	SpokenText string `json:"spoken_text,omitempty"`
+	// Reply is where the outcome of a job submitted from a message queue is
+	// published. The consumer clears it once the reply was published.
+	Reply *JobReply `json:"reply,omitempty"`
+}
+
+// JobReply is the reply a message queue consumer owes for a job it submitted.
+type JobReply struct {
+	// Consumer names the server instance that publishes the reply, so that
+	// several instances consuming the same topic don't reply twice.
+	Consumer      string `json:"consumer,omitempty"`
+	Topic         string `json:"topic"`
+	CorrelationID string `json:"correlation_id,omitempty"`
+	// MessageID is the broker's ID of the request.
+	MessageID string `json:"message_id,omitempty"`
+}
+
+type jobReplyKey struct{}
+
+// WithJobReply returns a copy of ctx carrying reply, for the job a submission
+// made with it creates.
+func WithJobReply(ctx context.Context, reply *JobReply) context.Context {
+	return context.WithValue(ctx, jobReplyKey{}, reply)
+}
+
+// JobReplyFromContext returns the reply WithJobReply set on ctx, or nil.
+func JobReplyFromContext(ctx context.Context) *JobReply {
+	reply, _ := ctx.Value(jobReplyKey{}).(*JobReply)
+	return reply
+}
+
+// JobErrDeliveryLimit is the error code of a job failed because it was never
+// acknowledged within its allowed deliveries.
+const JobErrDeliveryLimit = "DELIVERY_LIMIT_EXCEEDED"
+
+// JobErrDeadlineExceeded is the error code of a job failed because its deadline
+// passed first.
+const JobErrDeadlineExceeded = "DEADLINE_EXCEEDED"
+
+// JobErrInvalidAudio is the error code of a job failed because its provider
+// kept returning output that isn't decodable audio of the job's format.
+const JobErrInvalidAudio = "INVALID_AUDIO"
+
+// JobErrEmptyAudio is the error code of a job failed because its provider kept
+// returning a zero-byte result.
+const JobErrEmptyAudio = "EMPTY_AUDIO"
+
+// JobErrSilentAudio is the error code of a job failed because its provider kept
+// returning audio without audible sound for text that has words to speak.
+const JobErrSilentAudio = "SILENT_AUDIO"
+
+// JobEvent is an entry in a job's history, such as a scheduling decision.
+type JobEvent struct {
+	At      time.Time `json:"at"`
+	Type    string    `json:"type"`
+	Message string    `json:"message"`
+}
+
+// Job event types.
+const (
+	JobEventQueued   = "queued"
+	JobEventDeferred = "deferred"
+	JobEventDequeued = "dequeued"
+	// JobEventDuplicate marks a job as a repeat of an earlier identical submission.
+	JobEventDuplicate = "duplicate"
+	// JobEventRegenerated marks a job created from an earlier job's parameters.
+	JobEventRegenerated = "regenerated"
+	// JobEventRetried marks a job created from a failed job's parameters.
+	JobEventRetried = "retried"
+	// JobEventRedelivered records that a job was queued again because its consumer
+	// didn't acknowledge it within the visibility timeout.
+	JobEventRedelivered = "redelivered"
+	// JobEventSourceFetched records that the worker fetched the job's text from its source.
+	JobEventSourceFetched = "source_fetched"
+	// JobEventCancelled records that the job was cancelled on request.
+	JobEventCancelled = "cancelled"
+	// JobEventRetrying records that an attempt failed with a transient error and
+	// the job was scheduled to be attempted again.
+	JobEventRetrying = "retrying"
+	// JobEventFailover records that a provider failed the job, or was unavailable,
+	// and the next fallback provider was tried.
+	JobEventFailover = "failover"
+	// JobEventExpired records that the job's result was removed after its
+	// retention period, or deleted on request.
+	JobEventExpired = "expired"
+	// JobEventRewritten records that a pipeline stage, such as summarize, replaced
+	// the job's text with the text that is spoken.
+	JobEventRewritten = "rewritten"
+	// JobEventChunked records that the text was longer than the provider accepts
+	// and was synthesized in chunks.
+	JobEventChunked = "chunked"
+	// JobEventCheckpointed records that processing was interrupted by a worker
+	// drain's deadline and the job was queued again.
+	JobEventCheckpointed = "checkpointed"
+	// JobEventCacheHit records that the job took the audio of an identical earlier
+	// request from the result cache instead of synthesizing it.
+	JobEventCacheHit = "cache_hit"
+	// JobEventSyncFallback records that the job was created from a synchronous
+	// request that ran out of its timeout.
+	JobEventSyncFallback = "sync_fallback"
+	// JobEventRestored records that the job was persisted on shutdown and queued
+	// again when the server restarted.
+	JobEventRestored = "restored"
+	// JobEventDownloaded records the size and throughput of the download of the
+	// job's audio from its provider.
+	JobEventDownloaded = "downloaded"
+)
+
+// DefaultTenant is the tenant of jobs submitted without a tenant identity.
+const DefaultTenant = "default"
+
+// Tenant returns the job's tenant, DefaultTenant when none was recorded.
+func (j *Job) Tenant() string {
+	if j.TenantID == "" {
+		return DefaultTenant
+	}
+	return j.TenantID
+}
+
+// Artifact names stored alongside a job's result.
+const (
+	// ArtifactPreview is a short low-bitrate MP3 clip of the start of the result.
+	ArtifactPreview = "preview.mp3"
+	// ArtifactWaveform is the peaks JSON (amplitude envelope) of the result.
+	ArtifactWaveform = "waveform.json"
+)
+
+// ResultVariant is the artifact name of the job's result transcoded to format.
+func ResultVariant(format string) string {
+	return "result." + format
 }
 
 // NewJob creates a new job with default values.
@@ -54,11 +239,104 @@ func NewJob(text, voiceID, modelID, languageCode, providerName, outputFormat str
 	}
 }
 
-// SetProcessing marks the job as processing.
+// NewRetryJob creates a new queued job with the parameters of a failed job:
+// its text, or its source when it failed before fetching the text, voice,
+// model, language, provider, format, settings, pipeline, callback URL, tenant
+// and cache key. The deadline is kept unless it has passed.
+func NewRetryJob(failed *Job) *Job {
+	job := NewJob(failed.Text, failed.VoiceID, failed.ModelID, failed.LanguageCode,
+		failed.ProviderName, failed.OutputFormat, failed.VoiceSettings)
+	job.Padding = failed.Padding
+	job.Pipeline = failed.Pipeline
+	job.CallbackURL = failed.CallbackURL
+	job.TenantID = failed.TenantID
+	job.CacheKey = failed.CacheKey
+	if failed.Text == "" {
+		job.Source = failed.Source
+	}
+	if failed.Deadline != nil && failed.Deadline.After(time.Now()) {
+		job.Deadline = failed.Deadline
+	}
+	job.AddEvent(JobEventRetried, "retried from job "+failed.ID)
+	return job
+}
+
+// SetProcessing marks the job as processing and counts a new attempt.
 func (j *Job) SetProcessing() {
 	now := time.Now().UTC()
 	j.Status = JobStatusProcessing
 	j.StartedAt = &now
+	j.Attempts++
+	j.NextAttemptAt = nil
+}
+
+// SetRetrying returns the job to the queued state, to be picked up again at retryAt.
+func (j *Job) SetRetrying(retryAt time.Time) {
+	retryAt = retryAt.UTC()
+	j.Status = JobStatusQueued
+	j.ProgressPercentage = 0
+	j.NextAttemptAt = &retryAt
+	j.EstimatedCompletionAt = nil
+}
+
+// AddEvent appends an entry to the job's history.
+func (j *Job) AddEvent(eventType, message string) {
+	j.Events = append(j.Events, JobEvent{At: time.Now().UTC(), Type: eventType, Message: message})
+}
+
+// AddArtifact records that a derived file is available for the job.
+func (j *Job) AddArtifact(name string) {
+	if !j.HasArtifact(name) {
+		j.Artifacts = append(j.Artifacts, name)
+	}
+}
+
+// HasArtifact reports whether the named artifact was stored for the job.
+func (j *Job) HasArtifact(name string) bool {
+	for _, a := range j.Artifacts {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
+
+// RetainArtifact records when the named artifact, stored now, expires under
+// policy. An artifact kept for less time than the result expires with the result
+// at the latest; one kept as long as the result needs no record.
+func (j *Job) RetainArtifact(name string, policy RetentionPolicy) {
+	retention := policy.For(name)
+	if retention == policy.Result {
+		delete(j.ArtifactExpiresAt, name)
+		return
+	}
+	expiresAt := time.Now().UTC().Add(retention)
+	if retention < policy.Result && j.ExpiresAt != nil && j.ExpiresAt.Before(expiresAt) {
+		expiresAt = *j.ExpiresAt
+	}
+	if j.ArtifactExpiresAt == nil {
+		j.ArtifactExpiresAt = make(map[string]time.Time)
+	}
+	j.ArtifactExpiresAt[name] = expiresAt
+}
+
+// ArtifactExpiry returns when the named artifact expires: its own expiry when it
+// is kept for a different period than the result, else the result's.
+func (j *Job) ArtifactExpiry(name string) *time.Time {
+	if expiresAt, ok := j.ArtifactExpiresAt[name]; ok {
+		return &expiresAt
+	}
+	return j.ExpiresAt
+}
+
+// ArtifactAvailable reports whether the named artifact was stored for the job
+// and hasn't expired.
+func (j *Job) ArtifactAvailable(name string) bool {
+	if !j.HasArtifact(name) {
+		return false
+	}
+	expiresAt := j.ArtifactExpiry(name)
+	return expiresAt == nil || time.Now().UTC().Before(*expiresAt)
 }
 
 // SetCompleted marks the job as completed with the result path.
@@ -80,6 +358,112 @@ func (j *Job) SetFailed(errMsg string) {
 	j.ErrorMessage = errMsg
 }
 
+// SetFailedWithCode marks the job as failed with a stable error code and message.
+func (j *Job) SetFailedWithCode(code, errMsg string) {
+	j.SetFailed(errMsg)
+	j.ErrorCode = code
+}
+
+// SetCancelled marks the job as cancelled; message says at which stage. A job
+// that already finished can't be cancelled: ErrInvalidTransition is returned
+// and the job is left as it is.
+func (j *Job) SetCancelled(message string) error {
+	if j.IsComplete() {
+		return j.invalidTransition(JobStatusCancelled)
+	}
+	now := time.Now().UTC()
+	j.Status = JobStatusCancelled
+	j.CompletedAt = &now
+	j.NextAttemptAt = nil
+	j.EstimatedCompletionAt = nil
+	j.AddEvent(JobEventCancelled, message)
+	return nil
+}
+
+// SetExpired marks a completed job as expired once its result was removed.
+// Artifacts kept longer than the result stay listed until they expire too. Only
+// completed jobs expire; for any other ErrInvalidTransition is returned and the
+// job is left as it is.
+func (j *Job) SetExpired() error {
+	var kept []string
+	keptUntil := make(map[string]time.Time)
+	now := time.Now().UTC()
+	for _, name := range j.Artifacts {
+		if expiresAt, ok := j.ArtifactExpiresAt[name]; ok && expiresAt.After(now) {
+			kept = append(kept, name)
+			keptUntil[name] = expiresAt
+		}
+	}
+	if err := j.expire("result removed after its retention period"); err != nil {
+		return err
+	}
+	if len(kept) > 0 {
+		j.Artifacts, j.ArtifactExpiresAt = kept, keptUntil
+	}
+	return nil
+}
+
+// SetResultDeleted marks a completed job expired because its result was deleted
+// on request before its retention period ended. ExpiresAt becomes the time of
+// deletion, so the regenerate grace period counts from then.
+func (j *Job) SetResultDeleted() error {
+	if err := j.expire("result deleted on request"); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	j.ExpiresAt = &now
+	return nil
+}
+
+func (j *Job) expire(message string) error {
+	if j.Status != JobStatusCompleted {
+		return j.invalidTransition(JobStatusExpired)
+	}
+	j.Status = JobStatusExpired
+	j.ResultPath = ""
+	j.Artifacts = nil
+	j.ArtifactExpiresAt = nil
+	j.AddEvent(JobEventExpired, message)
+	return nil
+}
+
+func (j *Job) invalidTransition(to JobStatus) error {
+	return fmt.Errorf("%w: job %s is %s, can't become %s", ErrInvalidTransition, j.ID, j.Status, to)
+}
+
+// Redeliver handles a job whose consumer didn't acknowledge it within
+// visibilityTimeout. Within maxDeliveries (0 = no limit) the job goes back to the
+// queued state and true is returned; after that it fails with JobErrDeliveryLimit.
+func (j *Job) Redeliver(maxDeliveries int, visibilityTimeout time.Duration) bool {
+	deliveries := j.Redeliveries + 1
+	if maxDeliveries > 0 && deliveries >= maxDeliveries {
+		j.SetFailedWithCode(JobErrDeliveryLimit, fmt.Sprintf("job was not acknowledged after %d deliveries", deliveries))
+		return false
+	}
+	j.Redeliveries++
+	j.Status = JobStatusQueued
+	j.ProgressPercentage = 0
+	j.EstimatedCompletionAt = nil
+	j.AddEvent(JobEventRedelivered, fmt.Sprintf("not acknowledged within %s, redelivery %d", visibilityTimeout, j.Redeliveries))
+	return true
+}
+
+// Checkpoint returns a job whose processing was interrupted, e.g. because its
+// worker is shutting down, to the queued state to be taken up again. The
+// interrupted attempt isn't counted, and the text its pipeline stages wrote is
+// kept, so the next attempt speaks the same text without rewriting it.
+func (j *Job) Checkpoint(message string) {
+	j.Status = JobStatusQueued
+	j.Attempts = max(j.Attempts-1, 0)
+	j.ProgressPercentage = 0
+	j.EstimatedCompletionAt = nil
+	j.NextAttemptAt = nil
+	j.CompletedAt = nil
+	j.ErrorCode = ""
+	j.ErrorMessage = ""
+	j.AddEvent(JobEventCheckpointed, message)
+}
+
 // UpdateProgress updates the job's progress percentage and estimated completion.
 func (j *Job) UpdateProgress(percentage float64, estimatedCompletion *time.Time) {
 	j.ProgressPercentage = percentage
@@ -88,13 +472,26 @@ func (j *Job) UpdateProgress(percentage float64, estimatedCompletion *time.Time)
 
 // IsExpired checks if the job result has expired.
 func (j *Job) IsExpired() bool {
+	if j.Status == JobStatusExpired {
+		return true
+	}
 	if j.ExpiresAt == nil {
 		return false
 	}
 	return time.Now().UTC().After(*j.ExpiresAt)
 }
 
-// IsComplete checks if the job has finished (completed or failed).
+// DeadlinePassed reports whether the job has a deadline that is before t.
+func (j *Job) DeadlinePassed(t time.Time) bool {
+	return j.Deadline != nil && j.Deadline.Before(t)
+}
+
+// IsComplete checks if the job has finished (completed, failed, cancelled or
+// expired).
 func (j *Job) IsComplete() bool {
-	return j.Status == JobStatusCompleted || j.Status == JobStatusFailed
+	switch j.Status {
+	case JobStatusCompleted, JobStatusFailed, JobStatusCancelled, JobStatusExpired:
+		return true
+	}
+	return false
 }
@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"errors"
 	"testing"
 	"time"
 )
@@ -129,6 +130,143 @@ func TestJob_SetFailed(t *testing.T) {
 	}
 }
 
+func TestJob_SetCancelled(t *testing.T) {
+	job := NewJob("test", "voice", "", "", "provider", "mp3", nil)
+	job.SetProcessing()
+
+	if err := job.SetCancelled("cancelled while processing"); err != nil {
+		t.Fatalf("SetCancelled: %v", err)
+	}
+	if job.Status != JobStatusCancelled || job.CompletedAt == nil {
+		t.Errorf("Expected a cancelled job with CompletedAt set, got %+v", job)
+	}
+
+	for _, finish := range []func(*Job){
+		func(j *Job) { j.SetCompleted("/tmp/result.mp3", 24) },
+		func(j *Job) { j.SetFailed("synthesis failed") },
+		func(j *Job) { j.SetCancelled("first") }, //nolint:errcheck
+	} {
+		job := NewJob("test", "voice", "", "", "provider", "mp3", nil)
+		finish(job)
+		status := job.Status
+		if err := job.SetCancelled("too late"); !errors.Is(err, ErrInvalidTransition) {
+			t.Errorf("Expected ErrInvalidTransition cancelling a %s job, got %v", status, err)
+		}
+		if job.Status != status {
+			t.Errorf("Expected status %s to be kept, got %s", status, job.Status)
+		}
+	}
+}
+
+func TestJob_SetExpired(t *testing.T) {
+	job := NewJob("test", "voice", "", "", "provider", "mp3", nil)
+	job.SetCompleted("/tmp/result.mp3", 24)
+	job.AddArtifact(ArtifactPreview)
+
+	if err := job.SetExpired(); err != nil {
+		t.Fatalf("SetExpired: %v", err)
+	}
+	if job.Status != JobStatusExpired || job.ResultPath != "" || job.Artifacts != nil {
+		t.Errorf("Expected an expired job without result, got %+v", job)
+	}
+	if !job.IsExpired() || !job.IsComplete() {
+		t.Error("Expected an expired job to be expired and complete")
+	}
+	if err := job.SetExpired(); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected ErrInvalidTransition expiring twice, got %v", err)
+	}
+
+	for _, status := range []JobStatus{JobStatusQueued, JobStatusProcessing, JobStatusFailed, JobStatusCancelled} {
+		job := NewJob("test", "voice", "", "", "provider", "mp3", nil)
+		job.Status = status
+		if err := job.SetExpired(); !errors.Is(err, ErrInvalidTransition) || job.Status != status {
+			t.Errorf("Expected a %s job not to expire, got %v and status %s", status, err, job.Status)
+		}
+	}
+}
+
+func TestJob_RetainArtifact(t *testing.T) {
+	policy := RetentionPolicy{
+		Result: 24 * time.Hour,
+		Artifacts: map[string]time.Duration{
+			ArtifactKindPreview:  30 * 24 * time.Hour,
+			ArtifactKindWaveform: time.Hour,
+		},
+	}
+	job := NewJob("test", "voice", "", "", "provider", "mp3", nil)
+	job.SetCompleted("/tmp/result.mp3", 24)
+	for _, name := range []string{ArtifactPreview, ArtifactWaveform, ResultVariant("wav")} {
+		job.AddArtifact(name)
+		job.RetainArtifact(name, policy)
+	}
+
+	if expiresAt := job.ArtifactExpiry(ArtifactPreview); expiresAt == nil || !expiresAt.After(job.ExpiresAt.Add(28*24*time.Hour)) {
+		t.Errorf("Expected the preview to outlive the result, expiring %v", expiresAt)
+	}
+	if expiresAt := job.ArtifactExpiry(ArtifactWaveform); expiresAt == nil || !expiresAt.Before(*job.ExpiresAt) {
+		t.Errorf("Expected the waveform to expire before the result, expiring %v", expiresAt)
+	}
+	if _, ok := job.ArtifactExpiresAt[ResultVariant("wav")]; ok || job.ArtifactExpiry(ResultVariant("wav")) != job.ExpiresAt {
+		t.Error("Expected the variant to expire with the result")
+	}
+
+	job.ArtifactExpiresAt[ArtifactWaveform] = time.Now().Add(-time.Minute)
+	if job.ArtifactAvailable(ArtifactWaveform) || !job.ArtifactAvailable(ArtifactPreview) {
+		t.Error("Expected only the expired waveform to be unavailable")
+	}
+
+	// Once the result expires, only the preview is left
+	if err := job.SetExpired(); err != nil {
+		t.Fatalf("SetExpired: %v", err)
+	}
+	if len(job.Artifacts) != 1 || !job.ArtifactAvailable(ArtifactPreview) || len(job.ArtifactExpiresAt) != 1 {
+		t.Errorf("Expected the preview to be kept, got %v %v", job.Artifacts, job.ArtifactExpiresAt)
+	}
+}
+
+func TestRetentionPolicy(t *testing.T) {
+	policy := RetentionPolicy{
+		Result:    24 * time.Hour,
+		Artifacts: map[string]time.Duration{ArtifactKindPreview: 48 * time.Hour, ArtifactKindVariant: time.Hour},
+	}
+	tests := []struct {
+		name     string
+		want     time.Duration
+		outlives bool
+	}{
+		{"mp3", 24 * time.Hour, false},
+		{ArtifactPreview, 48 * time.Hour, true},
+		{ArtifactWaveform, 24 * time.Hour, false},
+		{ResultVariant("wav"), time.Hour, false},
+	}
+	for _, tt := range tests {
+		if got := policy.For(tt.name); got != tt.want || policy.Outlives(tt.name) != tt.outlives {
+			t.Errorf("For(%q) = %v, outlives %v", tt.name, got, policy.Outlives(tt.name))
+		}
+	}
+	if policy.Shortest() != time.Hour || policy.Longest() != 48*time.Hour {
+		t.Errorf("Shortest = %v, Longest = %v", policy.Shortest(), policy.Longest())
+	}
+}
+
+func TestJob_SetResultDeleted(t *testing.T) {
+	job := NewJob("test", "voice", "", "", "provider", "mp3", nil)
+	job.SetCompleted("/tmp/result.mp3", 24)
+
+	if err := job.SetResultDeleted(); err != nil {
+		t.Fatalf("SetResultDeleted: %v", err)
+	}
+	if job.Status != JobStatusExpired || job.ResultPath != "" {
+		t.Errorf("Expected an expired job without result, got %+v", job)
+	}
+	if job.ExpiresAt == nil || time.Since(*job.ExpiresAt) > time.Minute {
+		t.Errorf("Expected ExpiresAt moved to the deletion, got %v", job.ExpiresAt)
+	}
+	if err := job.SetResultDeleted(); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected ErrInvalidTransition deleting twice, got %v", err)
+	}
+}
+
 func TestJob_UpdateProgress(t *testing.T) {
 	job := NewJob("test", "voice", "", "", "provider", "mp3", nil)
 	percentage := 50.0
@@ -183,6 +321,8 @@ func TestJob_IsComplete(t *testing.T) {
 		{"processing", JobStatusProcessing, false},
 		{"completed", JobStatusCompleted, true},
 		{"failed", JobStatusFailed, true},
+		{"cancelled", JobStatusCancelled, true},
+		{"expired", JobStatusExpired, true},
 	}
 
 	for _, tt := range tests {
@@ -196,3 +336,33 @@ func TestJob_IsComplete(t *testing.T) {
 		})
 	}
 }
+
+func TestJob_SetRetrying(t *testing.T) {
+	job := NewJob("test", "voice", "", "", "provider", "mp3", nil)
+	job.SetProcessing()
+	job.UpdateProgress(30, nil)
+
+	retryAt := time.Now().Add(5 * time.Second)
+	job.SetRetrying(retryAt)
+
+	if job.Status != JobStatusQueued {
+		t.Errorf("Expected status %s, got %s", JobStatusQueued, job.Status)
+	}
+	if job.Attempts != 1 {
+		t.Errorf("Expected 1 attempt, got %d", job.Attempts)
+	}
+	if job.NextAttemptAt == nil || !job.NextAttemptAt.Equal(retryAt.UTC()) {
+		t.Errorf("Expected NextAttemptAt %v, got %v", retryAt, job.NextAttemptAt)
+	}
+	if job.ProgressPercentage != 0 {
+		t.Errorf("Expected progress reset to 0, got %f", job.ProgressPercentage)
+	}
+
+	job.SetProcessing()
+	if job.Attempts != 2 {
+		t.Errorf("Expected 2 attempts after reprocessing, got %d", job.Attempts)
+	}
+	if job.NextAttemptAt != nil {
+		t.Error("Expected NextAttemptAt to be cleared when processing starts")
+	}
+}
@@ -0,0 +1,10 @@
+package domain
+
+// PaddingOptions adds silence and fades around synthesized audio — typical
+// requirements for IVR prompts and video editing. All values are milliseconds.
+type PaddingOptions struct {
+	LeadInMs  int `json:"lead_in_ms,omitempty"`
+	LeadOutMs int `json:"lead_out_ms,omitempty"`
+	FadeInMs  int `json:"fade_in_ms,omitempty"`
+	FadeOutMs int `json:"fade_out_ms,omitempty"`
+}
@@ -0,0 +1,8 @@
+package domain
+
+// PipelineStage is one step of a request's processing pipeline: a processor
+// registered under Stage, configured with Params.
+type PipelineStage struct {
+	Stage  string         `json:"stage"`
+	Params map[string]any `json:"params,omitempty"`
+}
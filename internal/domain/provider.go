@@ -44,6 +44,43 @@ type SynthesisRequest struct {
 	LanguageCode string // optional; ISO 639-1 (e.g. "en"). Provider/model default when empty.
 	OutputFormat string // "mp3" or "wav"
 	Settings     *VoiceSettings
+	// PreviousRequestIDs are provider request IDs of the preceding chunks of the same text,
+	// used by providers that support request stitching for consistent prosody. Optional.
+	PreviousRequestIDs []string
+	// Progress, when set, is called as the provider downloads the audio, with the
+	// bytes received so far and the size announced by the upstream service, or -1
+	// when none was. Providers without a download to track don't call it.
+	Progress func(received, total int64)
+}
+
+// TrackDownload returns body counting the bytes read from it into r.Progress,
+// which is called once right away with none received. total is the expected
+// size, -1 when unknown. Without Progress, body is returned as is.
+func (r *SynthesisRequest) TrackDownload(body io.Reader, total int64) io.Reader {
+	if r.Progress == nil {
+		return body
+	}
+	if total <= 0 {
+		total = -1
+	}
+	r.Progress(0, total)
+	return &progressReader{body: body, total: total, progress: r.Progress}
+}
+
+type progressReader struct {
+	body     io.Reader
+	received int64
+	total    int64
+	progress func(received, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.body.Read(b)
+	if n > 0 {
+		p.received += int64(n)
+		p.progress(p.received, p.total)
+	}
+	return n, err
 }
 
 // SynthesisResult contains the result of a TTS synthesis operation.
@@ -52,6 +89,23 @@ type SynthesisResult struct {
 	ContentType string
 	Duration    time.Duration
 	SizeBytes   int64
+	// RequestID is the provider-assigned request identifier, when the provider exposes one
+	// (e.g. for ElevenLabs request stitching). Empty otherwise.
+	RequestID string
+}
+
+// StreamingProvider is implemented by providers that can return audio while it
+// is still being synthesized. Providers without it are served by Synthesize.
+type StreamingProvider interface {
+	// SynthesizeStream starts synthesis and returns the audio as it arrives. The
+	// caller must close the stream. Errors reported mid-stream surface from Read.
+	SynthesizeStream(ctx context.Context, req *SynthesisRequest) (*SynthesisStream, error)
+}
+
+// SynthesisStream is audio delivered as the provider produces it.
+type SynthesisStream struct {
+	Audio       io.ReadCloser
+	ContentType string
 }
 
 // ProviderInfo contains metadata about a TTS provider for API responses.
@@ -91,4 +145,74 @@ type ProviderRegistry interface {
 
 	// DefaultName returns the name of the default provider.
 	DefaultName() string
+
+	// Route returns the name of the provider that should serve a request which doesn't
+	// name one, according to the configured routing policy. textLength lets quota-aware
+	// policies skip providers that can't afford the request.
+	Route(ctx context.Context, textLength int) string
+
+	// Observe records the outcome of a synthesis call so routing can track live
+	// latency and error rates per provider.
+	Observe(name string, textLength int, latency time.Duration, err error)
+}
+
+// Which of a provider's upstream API keys is in use.
+const (
+	KeyPrimary   = "primary"
+	KeySecondary = "secondary"
+)
+
+// ProviderKeyStatus reports which upstream API key a provider is using.
+type ProviderKeyStatus struct {
+	Provider     string     `json:"provider"`
+	ActiveKey    string     `json:"active_key"` // "primary" or "secondary"
+	HasSecondary bool       `json:"has_secondary"`
+	FailedOverAt *time.Time `json:"failed_over_at,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+}
+
+// ProviderFallbacks is implemented by registries that know which providers take
+// over a job when its provider fails or is unavailable.
+type ProviderFallbacks interface {
+	// Fallbacks returns the providers to try, in order, after name.
+	Fallbacks(name string) []string
+}
+
+// ProviderDegradation describes a provider in degraded mode.
+type ProviderDegradation struct {
+	Provider string    `json:"provider"`
+	Reason   string    `json:"reason"`
+	Since    time.Time `json:"since"`
+}
+
+// ProviderDegradations is implemented by registries that put providers the
+// upstream keeps rate limiting into a degraded mode until they recover.
+type ProviderDegradations interface {
+	// Degraded returns the providers in degraded mode, by name.
+	Degraded() []ProviderDegradation
+
+	// IsDegraded reports whether name is in degraded mode.
+	IsDegraded(name string) bool
+}
+
+// ProviderTextLimits is implemented by registries that know how much text a
+// provider accepts per request, so longer texts can be synthesized in chunks.
+type ProviderTextLimits interface {
+	// MaxTextLength returns the most characters name accepts per request, or 0
+	// when it has no limit.
+	MaxTextLength(name string) int
+
+	// ParallelChunks returns how many chunks of one text name may synthesize at
+	// once; at least 1.
+	ParallelChunks(name string) int
+}
+
+// ProviderKeyManager swaps provider API keys at runtime (admin API).
+type ProviderKeyManager interface {
+	// SetAPIKeys replaces a provider's primary and secondary keys and switches back to
+	// the primary. An empty secondary removes it.
+	SetAPIKeys(name, primary, secondary string) error
+
+	// KeyStatus lists the key state of every provider that uses an API key.
+	KeyStatus() []ProviderKeyStatus
 }
@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ProviderError is returned by providers when the upstream API rejects a request.
+// It carries the upstream HTTP status and any rate-limit hints parsed from response headers,
+// so callers can react (e.g. reschedule) without parsing error strings.
+type ProviderError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+	// RetryAfter is the upstream-signaled delay before retrying; zero when not signaled.
+	RetryAfter time.Duration
+	// ConcurrencyLimit is the upstream-signaled maximum number of concurrent requests;
+	// zero when not signaled.
+	ConcurrencyLimit int
+}
+
+// Error implements the error interface.
+func (e *ProviderError) Error() string {
+	return e.Message
+}
+
+// IsRateLimited reports whether the upstream rejected the request with 429 Too Many Requests.
+func (e *ProviderError) IsRateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests
+}
+
+// IsTransient reports whether the upstream failure may go away on its own: a
+// request timeout (408), rate limiting (429) or a server error (5xx).
+func (e *ProviderError) IsTransient() bool {
+	return e.StatusCode == http.StatusRequestTimeout || e.IsRateLimited() || e.StatusCode >= 500
+}
+
+// IsKeyRejected reports whether the upstream refused the API key itself: invalid or
+// revoked (401/403) or out of credit (402). Retrying with the same key won't help.
+func (e *ProviderError) IsKeyRejected() bool {
+	switch e.StatusCode {
+	case http.StatusUnauthorized, http.StatusPaymentRequired, http.StatusForbidden:
+		return true
+	}
+	return false
+}
+
+// AsProviderError unwraps err into a *ProviderError if it is one.
+func AsProviderError(err error) (*ProviderError, bool) {
+	var perr *ProviderError
+	if errors.As(err, &perr) {
+		return perr, true
+	}
+	return nil, false
+}
@@ -2,26 +2,46 @@ package domain
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strings"
+	"time"
 )
 
 // JobQueue defines the interface for job queue implementations.
 // This port allows swapping between in-memory and Redis-backed queues.
+//
+// Delivery is at least once: a dequeued job stays leased to its consumer until it
+// is acknowledged with Ack. A job that isn't acknowledged within the queue's
+// visibility timeout, e.g. because its worker crashed, is queued again and
+// redelivered. Saving a processing job with UpdateJob renews its lease.
 type JobQueue interface {
 	// Enqueue adds a job to the queue for processing.
 	Enqueue(ctx context.Context, job *Job) error
 
-	// Dequeue retrieves the next job for processing (blocking).
-	// Returns nil if the queue is closed.
+	// Dequeue retrieves the next job for processing (blocking) and leases it to
+	// the caller. Returns nil if the queue is closed.
 	Dequeue(ctx context.Context) (*Job, error)
 
+	// Ack confirms that a dequeued job was handled, ending its lease so it is not
+	// redelivered. Acknowledging a job without a lease is a no-op.
+	Ack(ctx context.Context, jobID string) error
+
 	// GetJob retrieves a job by ID.
 	GetJob(ctx context.Context, jobID string) (*Job, error)
 
 	// UpdateJob updates a job's status and metadata.
 	UpdateJob(ctx context.Context, job *Job) error
 
-	// ListJobs returns jobs matching the given status.
-	ListJobs(ctx context.Context, status JobStatus) ([]*Job, error)
+	// ListJobs returns a page of the jobs matching filter, sorted by creation time.
+	ListJobs(ctx context.Context, filter JobFilter) (*JobPage, error)
+
+	// Cancel cancels a job. A job waiting in the queue is removed and marked
+	// cancelled at once; for a job a worker has taken, cancellation is requested and
+	// the worker aborts it. Cancelling a cancelled job is a no-op; other finished
+	// jobs return ErrJobNotCancellable.
+	Cancel(ctx context.Context, jobID string) (*Job, error)
 
 	// DeleteJob removes a job from the queue.
 	DeleteJob(ctx context.Context, jobID string) error
@@ -33,6 +53,118 @@ type JobQueue interface {
 	Stats() QueueStats
 }
 
+// JobFilter selects the jobs ListJobs returns and how they are paged.
+type JobFilter struct {
+	// Status, Tenant, BatchID, Provider, VoiceID and OutputFormat narrow the list
+	// when set. Provider matches the provider a job was submitted for, not a
+	// fallback that produced its result.
+	Status       JobStatus
+	Tenant       string
+	BatchID      string
+	Provider     string
+	VoiceID      string
+	OutputFormat string
+	// ExpiresAfter and ExpiresBy narrow the list to jobs whose result expires in
+	// (ExpiresAfter, ExpiresBy] when set; jobs without a result never match.
+	ExpiresAfter time.Time
+	ExpiresBy    time.Time
+	// CreatedAfter and CreatedBefore narrow the list to jobs created in
+	// (CreatedAfter, CreatedBefore) when set.
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// FinishedAfter narrows the list to jobs that finished, however they did,
+	// after it when set.
+	FinishedAfter time.Time
+	// Ascending lists the oldest jobs first; by default the newest come first.
+	Ascending bool
+	// Limit caps the page size; 0 returns every matching job.
+	Limit int
+	// After continues a listing past the last job of the previous page.
+	After *JobCursor
+}
+
+// Matches reports whether job passes the filter's status, tenant, batch,
+// provider, voice, output format, expiry, creation and finish ranges.
+func (f JobFilter) Matches(job *Job) bool {
+	if f.Status != "" && job.Status != f.Status {
+		return false
+	}
+	if f.BatchID != "" && job.BatchID != f.BatchID {
+		return false
+	}
+	if (f.Provider != "" && job.ProviderName != f.Provider) ||
+		(f.VoiceID != "" && job.VoiceID != f.VoiceID) ||
+		(f.OutputFormat != "" && job.OutputFormat != f.OutputFormat) {
+		return false
+	}
+	if !f.ExpiresAfter.IsZero() || !f.ExpiresBy.IsZero() {
+		if job.ExpiresAt == nil ||
+			(!f.ExpiresAfter.IsZero() && !job.ExpiresAt.After(f.ExpiresAfter)) ||
+			(!f.ExpiresBy.IsZero() && job.ExpiresAt.After(f.ExpiresBy)) {
+			return false
+		}
+	}
+	if (!f.CreatedAfter.IsZero() && !job.CreatedAt.After(f.CreatedAfter)) ||
+		(!f.CreatedBefore.IsZero() && !job.CreatedAt.Before(f.CreatedBefore)) {
+		return false
+	}
+	if !f.FinishedAfter.IsZero() && (job.CompletedAt == nil || !job.CompletedAt.After(f.FinishedAfter)) {
+		return false
+	}
+	return f.Tenant == "" || job.Tenant() == f.Tenant
+}
+
+// JobPage is one page of a job listing. Next is set when more jobs follow.
+type JobPage struct {
+	Jobs []*Job
+	Next *JobCursor
+}
+
+// JobCursor is a position in a job listing: the creation time and ID of the last
+// job listed. Jobs are ordered by creation time, then ID.
+type JobCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// CursorAfter returns the position just past job.
+func CursorAfter(job *Job) *JobCursor {
+	return &JobCursor{CreatedAt: job.CreatedAt, ID: job.ID}
+}
+
+// Follows reports whether job comes after the cursor position in a listing
+// sorted oldest first (ascending) or newest first.
+func (c *JobCursor) Follows(job *Job, ascending bool) bool {
+	if job.CreatedAt.Equal(c.CreatedAt) {
+		return job.ID != c.ID && (job.ID > c.ID) == ascending
+	}
+	return job.CreatedAt.After(c.CreatedAt) == ascending
+}
+
+// String encodes the cursor as an opaque token for API clients.
+func (c *JobCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID))
+}
+
+// ParseJobCursor decodes a token made by JobCursor.String.
+func ParseJobCursor(token string) (*JobCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	at, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return nil, errInvalidCursor
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, at)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	return &JobCursor{CreatedAt: createdAt, ID: id}, nil
+}
+
+var errInvalidCursor = errors.New("invalid job cursor")
+
 // QueueStats contains queue statistics for monitoring.
 type QueueStats struct {
 	TotalJobs      int `json:"total_jobs"`
@@ -40,4 +172,140 @@ type QueueStats struct {
 	ProcessingJobs int `json:"processing_jobs"`
 	CompletedJobs  int `json:"completed_jobs"`
 	FailedJobs     int `json:"failed_jobs"`
+	CancelledJobs  int `json:"cancelled_jobs"`
+	// ExpiredJobs completed, and their results were removed after the retention period.
+	ExpiredJobs int `json:"expired_jobs"`
+	// CharsInFlight is the text length of jobs currently processing.
+	CharsInFlight int64 `json:"chars_in_flight"`
+	// UnackedJobs are dequeued jobs whose consumer hasn't acknowledged them yet.
+	UnackedJobs int `json:"unacked_jobs"`
+	// RedeliveredJobs counts jobs queued again after their visibility timeout.
+	RedeliveredJobs int64 `json:"redelivered_jobs"`
+	// OldestQueuedSeconds is the age of the oldest pending job, 0 when none is.
+	OldestQueuedSeconds float64 `json:"oldest_queued_seconds"`
+	// Tenants breaks the pending backlog down per tenant (fair scheduling).
+	Tenants []TenantQueueStats `json:"tenants,omitempty"`
+	// Pools reports each worker pool when workers are pinned to providers.
+	Pools []WorkerPoolStats `json:"pools,omitempty"`
+	// Workers sums the pools, when the instance runs workers.
+	Workers *WorkerUtilization `json:"workers,omitempty"`
+}
+
+// WorkerUtilization reports how many of the workers are busy.
+type WorkerUtilization struct {
+	Total int `json:"total"`
+	Busy  int `json:"busy"`
+	// Utilization is Busy / Total, from 0 to 1.
+	Utilization float64 `json:"utilization"`
+}
+
+// NewWorkerUtilization sums the workers of pools.
+func NewWorkerUtilization(pools []WorkerPoolStats) *WorkerUtilization {
+	u := &WorkerUtilization{}
+	for _, p := range pools {
+		u.Total += p.Workers
+		u.Busy += p.Busy
+	}
+	if u.Total > 0 {
+		u.Utilization = float64(u.Busy) / float64(u.Total)
+	}
+	return u
+}
+
+// TenantQueueStats reports one tenant's backlog and how long its jobs wait, so
+// starvation is visible.
+type TenantQueueStats struct {
+	Tenant        string `json:"tenant"`
+	Queued        int    `json:"queued"`
+	InFlight      int    `json:"in_flight"`
+	CharsInFlight int64  `json:"chars_in_flight"`
+	Dequeued      int64  `json:"dequeued"`
+	// OldestWaitSeconds is the age of the tenant's oldest pending job.
+	OldestWaitSeconds float64 `json:"oldest_wait_seconds"`
+	// MaxWaitSeconds is the longest any of the tenant's jobs waited before dequeue.
+	MaxWaitSeconds float64 `json:"max_wait_seconds"`
+}
+
+// WorkerPoolStats reports one worker pool. Providers is empty for the default pool,
+// which serves every provider not pinned to another pool.
+type WorkerPoolStats struct {
+	Name      string   `json:"name"`
+	Workers   int      `json:"workers"`
+	Busy      int      `json:"busy"`
+	Providers []string `json:"providers,omitempty"`
+	// Queued is the pending backlog the pool's workers can take.
+	Queued    int   `json:"queued"`
+	Completed int64 `json:"completed"`
+	Failed    int64 `json:"failed"`
+}
+
+// WorkerPools reports the worker pools processing the queue.
+type WorkerPools interface {
+	PoolStats() []WorkerPoolStats
+}
+
+// ProviderFilter restricts the jobs a worker pool dequeues by the provider they
+// were submitted for: those of Providers, or with Exclude those of every other
+// provider. A nil filter takes every job.
+type ProviderFilter struct {
+	Providers []string
+	Exclude   bool
+}
+
+// Matches reports whether the filter takes job.
+func (f *ProviderFilter) Matches(job *Job) bool {
+	return f == nil || slices.Contains(f.Providers, job.ProviderName) != f.Exclude
+}
+
+// Drain strategies: how workers stop before their instance goes away.
+const (
+	// DrainScaleDown stops taking new work but finishes every queued job first,
+	// for an instance that is removed for good.
+	DrainScaleDown = "scale_down"
+	// DrainShutdown finishes the jobs in progress only and leaves the rest queued,
+	// for a restart or another instance to take.
+	DrainShutdown = "shutdown"
+)
+
+// Drain states.
+const (
+	DrainStateRunning  = "running"
+	DrainStateDraining = "draining"
+	DrainStateDrained  = "drained"
+)
+
+// DrainStatus reports the progress of a drain.
+type DrainStatus struct {
+	State    string `json:"state"`
+	Strategy string `json:"strategy,omitempty"`
+	// Deadline is when jobs still in progress are checkpointed: interrupted and
+	// queued again. Nil means the drain waits for them.
+	Deadline  *time.Time `json:"deadline,omitempty"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	// DrainedAt is when the last worker stopped.
+	DrainedAt *time.Time `json:"drained_at,omitempty"`
+	// InFlight is the jobs being processed.
+	InFlight int `json:"in_flight"`
+	// Queued is the pending jobs the workers could take, which a scale-down
+	// drain still processes.
+	Queued int `json:"queued"`
+	// Finished is the jobs handled since the drain started.
+	Finished int64 `json:"finished"`
+	// Checkpointed is the jobs interrupted at the deadline and queued again.
+	Checkpointed int64 `json:"checkpointed"`
+}
+
+// Drainer stops workers on request, reporting how far they got.
+type Drainer interface {
+	// Drain starts draining the workers with strategy. After timeout (0 = none)
+	// the jobs still in progress are checkpointed. A scale-down drain in progress
+	// can be sped up by draining again with DrainShutdown; any other second drain
+	// returns ErrDrainInProgress, unless it repeats the first.
+	Drain(strategy string, timeout time.Duration) (DrainStatus, error)
+
+	// DrainStatus reports the drain in progress, or that the workers are running.
+	DrainStatus() DrainStatus
+
+	// Drained returns a channel that is closed once a drain has finished.
+	Drained() <-chan struct{}
 }
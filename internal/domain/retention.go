@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// Artifact kinds, by which the retention of derived files is configured.
+const (
+	ArtifactKindPreview  = "preview"
+	ArtifactKindWaveform = "waveform"
+	// ArtifactKindVariant is the result transcoded to another format.
+	ArtifactKindVariant = "audio_variant"
+)
+
+// ArtifactKind returns the kind of the named artifact, or "" for names that
+// aren't artifacts, such as the audio format of a stored result.
+func ArtifactKind(name string) string {
+	switch {
+	case name == ArtifactPreview:
+		return ArtifactKindPreview
+	case name == ArtifactWaveform:
+		return ArtifactKindWaveform
+	case strings.HasPrefix(name, ResultVariant("")):
+		return ArtifactKindVariant
+	}
+	return ""
+}
+
+// RetentionPolicy is how long stored results are kept: a job's audio for
+// Result, and each kind of artifact for its entry in Artifacts, or as long as
+// the audio when it has none.
+type RetentionPolicy struct {
+	Result    time.Duration
+	Artifacts map[string]time.Duration
+}
+
+// For returns how long the named artifact is kept; for any other name, e.g. the
+// audio format of a stored result, how long the result is kept.
+func (p RetentionPolicy) For(name string) time.Duration {
+	if d, ok := p.Artifacts[ArtifactKind(name)]; ok && d > 0 {
+		return d
+	}
+	return p.Result
+}
+
+// Outlives reports whether the named artifact is kept after the audio it was
+// derived from expired.
+func (p RetentionPolicy) Outlives(name string) bool {
+	return p.For(name) > p.Result
+}
+
+// Shortest returns the shortest time anything is kept.
+func (p RetentionPolicy) Shortest() time.Duration {
+	shortest := p.Result
+	for _, d := range p.Artifacts {
+		if d > 0 {
+			shortest = min(shortest, d)
+		}
+	}
+	return shortest
+}
+
+// Longest returns the longest time anything is kept.
+func (p RetentionPolicy) Longest() time.Duration {
+	longest := p.Result
+	for _, d := range p.Artifacts {
+		longest = max(longest, d)
+	}
+	return longest
+}
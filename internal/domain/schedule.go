@@ -0,0 +1,16 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// ScheduleStore keeps the next run time of recurring tasks, so schedules survive
+// restarts and a run is taken by one instance only. Job stores shared between
+// instances implement it.
+type ScheduleStore interface {
+	// ClaimRun takes the run of task if it is due, moving its next run interval
+	// ahead, and reports whether the caller took it. A task not seen before is
+	// recorded as due interval from now and not claimed.
+	ClaimRun(ctx context.Context, task string, interval time.Duration) (bool, error)
+}
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// JobSearch finds jobs by the words of their text and tags. Job queues that
+// index their jobs implement it.
+type JobSearch interface {
+	// SearchJobs returns the jobs matching every word of the query, best
+	// matches first.
+	SearchJobs(ctx context.Context, query JobSearchQuery) ([]*Job, error)
+}
+
+// JobSearchQuery selects the jobs SearchJobs returns.
+type JobSearchQuery struct {
+	// Query is the words to look for.
+	Query string
+	// Tenant narrows the search to one tenant when set.
+	Tenant string
+	// TextExpiry keeps jobs whose result expired by then from matching on their
+	// text, which is no longer retained; their tags still match.
+	TextExpiry time.Time
+	// Limit caps the number of jobs returned; 0 returns every match.
+	Limit int
+}
+
+// TextRetained reports whether job's text may still match the query.
+func (q JobSearchQuery) TextRetained(job *Job) bool {
+	return job.ExpiresAt == nil || job.ExpiresAt.After(q.TextExpiry)
+}
+
+// Tags returns the metadata the job's tag stages write into its result, such as
+// its title and artist.
+func (j *Job) Tags() []string {
+	var tags []string
+	for _, stage := range j.Pipeline {
+		if stage.Stage != "tag" {
+			continue
+		}
+		for _, name := range []string{"title", "artist", "album", "comment"} {
+			if v, ok := stage.Params[name].(string); ok && v != "" {
+				tags = append(tags, v)
+			}
+		}
+	}
+	return tags
+}
@@ -3,12 +3,14 @@ package domain
 import (
 	"context"
 	"io"
+	"time"
 )
 
 // AudioStorage defines the interface for storing and retrieving audio files.
 // This port allows swapping between filesystem and cloud storage implementations.
 type AudioStorage interface {
-	// Store saves audio data and returns the storage path.
+	// Store saves audio data and returns the storage path. audio may be reused
+	// after Store returns, so implementations must not keep it.
 	Store(ctx context.Context, jobID string, audio []byte, format string) (string, error)
 
 	// Retrieve returns a reader for the stored audio file.
@@ -22,4 +24,110 @@ type AudioStorage interface {
 
 	// GetPath returns the storage path for a job's audio.
 	GetPath(ctx context.Context, jobID string) string
+
+	// StoreArtifact saves a file derived from a job's result (preview clip, waveform, ...)
+	// under the given name, e.g. "preview.mp3". Artifacts share the result's lifetime.
+	// Like Store, it must not keep data.
+	StoreArtifact(ctx context.Context, jobID, name string, data []byte) error
+
+	// RetrieveArtifact returns a reader for a stored artifact.
+	RetrieveArtifact(ctx context.Context, jobID, name string) (io.ReadCloser, error)
+}
+
+// ObjectInfo describes one stored file: a job's audio or one of its artifacts.
+type ObjectInfo struct {
+	// Key names the object within its backend, e.g. "<jobID>.mp3".
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// ObjectStore is implemented by storage backends whose files can be enumerated and
+// copied as-is, which is what moving retained results to another backend needs.
+type ObjectStore interface {
+	// ListObjects returns every stored object.
+	ListObjects(ctx context.Context) ([]ObjectInfo, error)
+
+	// OpenObject returns a reader for the object with the given key.
+	OpenObject(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// PutObject writes an object, keeping info's key and modification time so
+	// retention counts from when the result was first stored.
+	PutObject(ctx context.Context, info ObjectInfo, r io.Reader) error
+}
+
+// SignedURLs is implemented by storage backends that can hand out short-lived
+// URLs to a job's audio, so clients download large results from the backend
+// directly instead of through the server.
+type SignedURLs interface {
+	// CanSignURLs reports whether the backend has credentials to sign URLs with.
+	CanSignURLs() bool
+
+	// SignedURL returns a URL the job's audio can be downloaded from until ttl
+	// has passed, answered with the given Content-Disposition.
+	SignedURL(ctx context.Context, jobID string, ttl time.Duration, disposition string) (string, error)
+}
+
+// KeyRef describes the job a KeyStrategy places objects for.
+type KeyRef struct {
+	JobID string
+	// Tenant is the job's tenant; "" when unknown.
+	Tenant string
+	// StoredAt is when the job's first object was stored.
+	StoredAt time.Time
+	// ContentHash is the hex SHA-256 of the job's audio; "" while only artifacts
+	// are stored.
+	ContentHash string
+}
+
+// KeyStrategy decides where a storage backend keeps a job's objects: the
+// directory, or key prefix, under which its audio and artifacts are stored as
+// <jobID>.<format> and <jobID>.<name>. Backends take one as an option, so the
+// storage layout can change without touching each backend.
+type KeyStrategy interface {
+	// Name is the strategy's name in configuration, e.g. "sharded".
+	Name() string
+
+	// Dir returns the directory, relative to the backend's root and separated by
+	// "/", for the objects of the job ref describes.
+	Dir(ref KeyRef) string
+
+	// Locate returns the directories the objects of jobID may be in, most likely
+	// first, given the backend's top-level directories. It returns false when a
+	// job can't be found from its ID alone, and the backend has to search.
+	Locate(jobID string, tops []string) ([]string, bool)
+}
+
+// DatedKeys is implemented by key strategies whose top-level directories each
+// hold one day of results, so retention cleanup can remove them whole.
+type DatedKeys interface {
+	// Day returns the UTC day the top-level directory top holds.
+	Day(top string) (time.Time, bool)
+}
+
+type tenantKey struct{}
+
+// WithTenant returns a copy of ctx naming the tenant whose objects are stored
+// with it, for key strategies that keep tenants apart.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant WithTenant set on ctx, or "".
+func TenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// SpeechCache holds audio synthesized ahead of time, keyed by a hash of the
+// request it was synthesized from.
+type SpeechCache interface {
+	// Get returns the cached audio for key.
+	Get(ctx context.Context, key string) ([]byte, bool)
+
+	// Has reports whether audio is cached for key.
+	Has(ctx context.Context, key string) bool
+
+	// Put stores audio under key, replacing any earlier entry. It must not keep audio.
+	Put(ctx context.Context, key string, audio []byte) error
 }
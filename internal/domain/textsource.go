@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"context"
+	"errors"
+)
+
+// Text source types.
+const (
+	// TextSourceInline is text given in the request itself.
+	TextSourceInline = "inline"
+	// TextSourceURL is a plain-text document fetched over HTTP(S).
+	TextSourceURL = "url"
+	// TextSourceStored is the text of an earlier job.
+	TextSourceStored = "stored"
+	// TextSourceDocument is one chapter of a Markdown or plain-text document fetched over HTTP(S).
+	TextSourceDocument = "document"
+	// TextSourceTemplate is a Go text/template rendered with request variables.
+	TextSourceTemplate = "template"
+)
+
+// Text source error codes, reported as a failed job's error_code or in the details
+// of a rejected submission.
+const (
+	SourceErrInvalid     = "SOURCE_INVALID"
+	SourceErrUnsupported = "SOURCE_UNSUPPORTED"
+	SourceErrNotFound    = "SOURCE_NOT_FOUND"
+	SourceErrFetchFailed = "SOURCE_FETCH_FAILED"
+	SourceErrTooLarge    = "SOURCE_TOO_LARGE"
+	SourceErrEmpty       = "SOURCE_EMPTY"
+	SourceErrRender      = "SOURCE_RENDER_FAILED"
+)
+
+// TextSource names where a job's text comes from. Only the fields used by its Type
+// are read.
+type TextSource struct {
+	Type string `json:"type"`
+	// Text is the inline text.
+	Text string `json:"text,omitempty"`
+	// URL locates url and document sources.
+	URL string `json:"url,omitempty"`
+	// JobID names the job whose text a stored source reuses.
+	JobID string `json:"job_id,omitempty"`
+	// Chapter selects a document chapter, counting from 1.
+	Chapter int `json:"chapter,omitempty"`
+	// Template and Vars define a template source.
+	Template string            `json:"template,omitempty"`
+	Vars     map[string]string `json:"vars,omitempty"`
+}
+
+// TextFetcher resolves one type of text source.
+type TextFetcher interface {
+	// Type returns the source type the fetcher handles.
+	Type() string
+
+	// Validate checks a source without fetching it.
+	Validate(src *TextSource) error
+
+	// Fetch returns the source's text.
+	Fetch(ctx context.Context, src *TextSource) (string, error)
+}
+
+// TextSourceResolver validates and fetches sources of every registered type.
+type TextSourceResolver interface {
+	Validate(src *TextSource) error
+	Fetch(ctx context.Context, src *TextSource) (string, error)
+}
+
+// TextSourceError is a failure to validate or fetch a text source.
+type TextSourceError struct {
+	Code    string
+	Message string
+	Err     error
+}
+
+// Error implements the error interface.
+func (e *TextSourceError) Error() string {
+	if e.Err != nil {
+		return e.Code + ": " + e.Message + ": " + e.Err.Error()
+	}
+	return e.Code + ": " + e.Message
+}
+
+// Unwrap returns the underlying error.
+func (e *TextSourceError) Unwrap() error {
+	return e.Err
+}
+
+// NewTextSourceError creates a text source error.
+func NewTextSourceError(code, message string, err error) *TextSourceError {
+	return &TextSourceError{Code: code, Message: message, Err: err}
+}
+
+// AsTextSourceError extracts a TextSourceError from err's chain.
+func AsTextSourceError(err error) (*TextSourceError, bool) {
+	var serr *TextSourceError
+	ok := errors.As(err, &serr)
+	return serr, ok
+}
@@ -6,8 +6,18 @@ type VoiceSettings struct {
 	SimilarityBoost    *float64 `json:"similarity_boost,omitempty"`
 	Style              *float64 `json:"style,omitempty"`
 	Speed              *float64 `json:"speed,omitempty"`
+	Pitch              *float64 `json:"pitch,omitempty"` // semitones; 0 = unchanged
 	UseSpeakerBoost    *bool    `json:"use_speaker_boost,omitempty"`
 	StyleInstructions  string   `json:"style_instructions,omitempty"`
+	// NativeOnly disables server-side speed/pitch processing: adjustments the
+	// provider can't render itself are ignored instead of emulated.
+	NativeOnly *bool `json:"native_only,omitempty"`
+}
+
+// SettingRange is the inclusive range a numeric voice setting accepts.
+type SettingRange struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
 }
 
 // Voice represents an available voice option.
@@ -70,12 +80,24 @@ func (v *VoiceSettings) Merge(other *VoiceSettings) *VoiceSettings {
 		result.Speed = v.Speed
 	}
 
+	if other.Pitch != nil {
+		result.Pitch = other.Pitch
+	} else if v != nil {
+		result.Pitch = v.Pitch
+	}
+
 	if other.UseSpeakerBoost != nil {
 		result.UseSpeakerBoost = other.UseSpeakerBoost
 	} else if v != nil {
 		result.UseSpeakerBoost = v.UseSpeakerBoost
 	}
 
+	if other.NativeOnly != nil {
+		result.NativeOnly = other.NativeOnly
+	} else if v != nil {
+		result.NativeOnly = v.NativeOnly
+	}
+
 	// StyleInstructions uses non-empty string (not pointer) — "unset" and "explicitly empty"
 	// are indistinguishable, which is acceptable for a free-text directive.
 	if other.StyleInstructions != "" {
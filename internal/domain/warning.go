@@ -0,0 +1,15 @@
+package domain
+
+// Warning codes for non-fatal issues found in a request.
+const (
+	WarningTextLooksLikeHTML = "TEXT_LOOKS_LIKE_HTML"
+	WarningLanguageMismatch  = "LANGUAGE_MISMATCH"
+	WarningSettingsClamped   = "SETTINGS_CLAMPED"
+)
+
+// Warning reports a non-fatal issue with a request that was still accepted.
+type Warning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"`
+}
@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// Webhook event types a webhook can subscribe to.
+const (
+	// WebhookEventJobCompleted is sent when a job's audio is ready.
+	WebhookEventJobCompleted = "job.completed"
+	// WebhookEventJobFailed is sent when a job fails.
+	WebhookEventJobFailed = "job.failed"
+	// WebhookEventBatchCompleted is sent once every job of a batch has finished.
+	WebhookEventBatchCompleted = "batch.completed"
+	// WebhookEventQuotaWarning is sent to every subscribed webhook when a provider
+	// has used most of its configured character quota.
+	WebhookEventQuotaWarning = "quota.warning"
+	// WebhookEventKeyFlagged is sent to the tenant when its API key is flagged for
+	// unusual usage.
+	WebhookEventKeyFlagged = "key.flagged"
+)
+
+// WebhookEventTest is sent by the test-delivery endpoint, to the tested webhook only.
+const WebhookEventTest = "webhook.test"
+
+// WebhookEventTypes lists the event types a webhook can subscribe to.
+var WebhookEventTypes = []string{
+	WebhookEventJobCompleted,
+	WebhookEventJobFailed,
+	WebhookEventBatchCompleted,
+	WebhookEventQuotaWarning,
+	WebhookEventKeyFlagged,
+}
+
+// Webhook is an endpoint a tenant registered to be notified of events.
+type Webhook struct {
+	ID          string    `json:"id"`
+	Tenant      string    `json:"tenant"`
+	URL         string    `json:"url"`
+	Events      []string  `json:"events"`
+	Description string    `json:"description,omitempty"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Subscribed reports whether the webhook is active and listens for eventType.
+func (w *Webhook) Subscribed(eventType string) bool {
+	return w.Active && slices.Contains(w.Events, eventType)
+}
+
+// WebhookEvent is the body POSTed to webhooks.
+type WebhookEvent struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	// Tenant is the tenant the event concerns; empty for service-wide events.
+	Tenant    string    `json:"tenant,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// WebhookDelivery records one attempt to deliver an event to a webhook.
+type WebhookDelivery struct {
+	ID         string    `json:"id"`
+	WebhookID  string    `json:"webhook_id"`
+	EventID    string    `json:"event_id"`
+	EventType  string    `json:"event_type"`
+	Attempt    int       `json:"attempt"`
+	Success    bool      `json:"success"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// WebhookStore keeps registered webhooks and their recent deliveries.
+type WebhookStore interface {
+	// CreateWebhook stores a new webhook.
+	CreateWebhook(ctx context.Context, hook *Webhook) error
+
+	// GetWebhook returns a webhook; ErrWebhookNotFound if there is none.
+	GetWebhook(ctx context.Context, id string) (*Webhook, error)
+
+	// ListWebhooks returns a tenant's webhooks, or every webhook when tenant is
+	// empty, oldest first.
+	ListWebhooks(ctx context.Context, tenant string) ([]*Webhook, error)
+
+	// UpdateWebhook replaces a stored webhook.
+	UpdateWebhook(ctx context.Context, hook *Webhook) error
+
+	// DeleteWebhook removes a webhook and its deliveries.
+	DeleteWebhook(ctx context.Context, id string) error
+
+	// RecordDelivery appends to a webhook's delivery log.
+	RecordDelivery(ctx context.Context, delivery *WebhookDelivery) error
+
+	// ListDeliveries returns up to limit of a webhook's deliveries, newest first.
+	ListDeliveries(ctx context.Context, webhookID string, limit int) ([]*WebhookDelivery, error)
+}
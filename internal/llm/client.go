@@ -0,0 +1,116 @@
+// Package llm calls a language model behind an OpenAI-compatible chat
+// completions endpoint, such as OpenAI, a vLLM or Ollama server, or a gateway in
+// front of another vendor.
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pako-tts/server/internal/deadline"
+)
+
+// maxResponseBytes caps the size of a completion response read.
+const maxResponseBytes = 4 << 20
+
+// Client sends prompts to a chat completions endpoint.
+type Client struct {
+	endpoint   string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+}
+
+// NewClient creates a client POSTing to endpoint, the full URL of the chat
+// completions API, e.g. "https://api.openai.com/v1/chat/completions". apiKey is
+// sent as a bearer token unless empty.
+func NewClient(endpoint, apiKey, model string, timeout time.Duration) *Client {
+	return &Client{
+		endpoint:   endpoint,
+		apiKey:     apiKey,
+		model:      model,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// Message is one message of a chat completion request.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type completionRequest struct {
+	Model    string    `json:"model,omitempty"`
+	Messages []Message `json:"messages"`
+}
+
+type completionResponse struct {
+	Choices []struct {
+		Message Message `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Complete sends the system instructions and the user's input, and returns the
+// model's reply.
+func (c *Client) Complete(ctx context.Context, system, input string) (string, error) {
+	body, err := json.Marshal(completionRequest{
+		Model: c.model,
+		Messages: []Message{
+			{Role: "system", Content: system},
+			{Role: "user", Content: input},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	deadline.Set(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("llm request failed: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return "", fmt.Errorf("failed to read llm response: %w", err)
+	}
+	var completion completionResponse
+	decodeErr := json.Unmarshal(data, &completion)
+	if resp.StatusCode != http.StatusOK {
+		if decodeErr == nil && completion.Error != nil && completion.Error.Message != "" {
+			return "", fmt.Errorf("llm error (%d): %s", resp.StatusCode, completion.Error.Message)
+		}
+		return "", fmt.Errorf("llm error (%d): %s", resp.StatusCode, strings.TrimSpace(string(data[:min(len(data), 200)])))
+	}
+	if decodeErr != nil {
+		return "", fmt.Errorf("failed to decode llm response: %w", decodeErr)
+	}
+	if len(completion.Choices) == 0 {
+		return "", errors.New("llm response has no choices")
+	}
+	reply := strings.TrimSpace(completion.Choices[0].Message.Content)
+	if reply == "" {
+		return "", errors.New("llm returned an empty reply")
+	}
+	return reply, nil
+}
@@ -0,0 +1,64 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClient_Complete(t *testing.T) {
+	var got completionRequest
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)                                                             //nolint:errcheck
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"  Short version.\n"}}]}`)) //nolint:errcheck
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewClient(server.URL, "sk-test", "small-model", time.Second)
+	reply, err := client.Complete(context.Background(), "Summarize.", "A long text.")
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if reply != "Short version." {
+		t.Errorf("expected the trimmed reply, got %q", reply)
+	}
+	if auth != "Bearer sk-test" {
+		t.Errorf("expected the API key as a bearer token, got %q", auth)
+	}
+	if got.Model != "small-model" || len(got.Messages) != 2 || got.Messages[0].Role != "system" || got.Messages[1].Content != "A long text." {
+		t.Errorf("unexpected request %+v", got)
+	}
+}
+
+func TestClient_CompleteError(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"api error", http.StatusTooManyRequests, `{"error":{"message":"Rate limit reached"}}`, "llm error (429): Rate limit reached"},
+		{"plain error", http.StatusBadGateway, "upstream down", "llm error (502): upstream down"},
+		{"no choices", http.StatusOK, `{"choices":[]}`, "no choices"},
+		{"empty reply", http.StatusOK, `{"choices":[{"message":{"content":" "}}]}`, "empty reply"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body)) //nolint:errcheck
+			}))
+			t.Cleanup(server.Close)
+
+			_, err := NewClient(server.URL, "", "", time.Second).Complete(context.Background(), "Summarize.", "text")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package metrics
+
+// AbuseMetrics counts API keys flagged for unusual usage and the requests held
+// back because of it.
+type AbuseMetrics struct {
+	flagged  *CounterVec
+	rejected *CounterVec
+}
+
+// NewAbuseMetrics registers the abuse metrics on r.
+func NewAbuseMetrics(r *Registry) *AbuseMetrics {
+	return &AbuseMetrics{
+		flagged: r.Counter("pako_tts_abuse_flags_total",
+			"API keys flagged for unusual usage by reason.",
+			"reason"),
+		rejected: r.Counter("pako_tts_abuse_rejected_requests_total",
+			"Requests of flagged API keys rejected by action.",
+			"action"),
+	}
+}
+
+// Flagged counts a key flagged for reason. A nil AbuseMetrics records nothing.
+func (m *AbuseMetrics) Flagged(reason string) {
+	if m == nil {
+		return
+	}
+	m.flagged.Inc(reason)
+}
+
+// Rejected counts a request rejected by action. A nil AbuseMetrics records
+// nothing.
+func (m *AbuseMetrics) Rejected(action string) {
+	if m == nil {
+		return
+	}
+	m.rejected.Inc(action)
+}
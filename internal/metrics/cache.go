@@ -0,0 +1,34 @@
+package metrics
+
+// ResultCacheMetrics counts result cache lookups and the characters hits kept
+// from the provider.
+type ResultCacheMetrics struct {
+	lookups *CounterVec
+	chars   *CounterVec
+}
+
+// NewResultCacheMetrics registers the result cache metrics on r.
+func NewResultCacheMetrics(r *Registry) *ResultCacheMetrics {
+	return &ResultCacheMetrics{
+		lookups: r.Counter("pako_tts_result_cache_lookups_total",
+			"Result cache lookups by source and result: hit or miss.",
+			"source", "result"),
+		chars: r.Counter("pako_tts_result_cache_saved_chars_total",
+			"Characters of requests answered from the result cache instead of the provider.",
+			"source"),
+	}
+}
+
+// Observe records one lookup for a text of chars characters. A nil
+// ResultCacheMetrics records nothing.
+func (m *ResultCacheMetrics) Observe(source string, hit bool, chars int) {
+	if m == nil {
+		return
+	}
+	if !hit {
+		m.lookups.Inc(source, "miss")
+		return
+	}
+	m.lookups.Inc(source, "hit")
+	m.chars.Add(float64(chars), source)
+}
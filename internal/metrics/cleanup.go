@@ -0,0 +1,58 @@
+package metrics
+
+import "time"
+
+// Cleanup phases: results of jobs the job store reports expired, the sweep for
+// files older than the retention period that no job accounts for, and the
+// archival of jobs expired for longer than the archive delay.
+const (
+	CleanupIndex   = "index"
+	CleanupSweep   = "sweep"
+	CleanupArchive = "archive"
+)
+
+var cleanupBuckets = []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300}
+
+// CleanupMetrics records how long result cleanup takes and how much it removes.
+type CleanupMetrics struct {
+	duration *HistogramVec
+	files    *CounterVec
+	bytes    *CounterVec
+	archived *CounterVec
+}
+
+// NewCleanupMetrics registers the cleanup metrics on r.
+func NewCleanupMetrics(r *Registry) *CleanupMetrics {
+	return &CleanupMetrics{
+		duration: r.Histogram("pako_tts_cleanup_duration_seconds",
+			"Duration of result cleanup runs by phase.",
+			cleanupBuckets, "phase"),
+		files: r.Counter("pako_tts_cleanup_deleted_files_total",
+			"Stored files removed by result cleanup by phase.",
+			"phase"),
+		bytes: r.Counter("pako_tts_cleanup_deleted_bytes_total",
+			"Bytes of stored files removed by result cleanup by phase.",
+			"phase"),
+		archived: r.Counter("pako_tts_cleanup_archived_jobs_total",
+			"Expired jobs replaced with their archived record."),
+	}
+}
+
+// Observe records one cleanup phase. A nil CleanupMetrics records nothing.
+func (m *CleanupMetrics) Observe(phase string, d time.Duration, files int, bytes int64) {
+	if m == nil {
+		return
+	}
+	m.duration.Observe(d.Seconds(), phase)
+	m.files.Add(float64(files), phase)
+	m.bytes.Add(float64(bytes), phase)
+}
+
+// Archived counts jobs replaced with their archived record. A nil CleanupMetrics
+// records nothing.
+func (m *CleanupMetrics) Archived(jobs int) {
+	if m == nil {
+		return
+	}
+	m.archived.Add(float64(jobs))
+}
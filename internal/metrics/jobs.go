@@ -0,0 +1,25 @@
+package metrics
+
+import "github.com/pako-tts/server/internal/domain"
+
+// JobMetrics counts the jobs reaching each final status.
+type JobMetrics struct {
+	finished *CounterVec
+}
+
+// NewJobMetrics registers the job metrics on r.
+func NewJobMetrics(r *Registry) *JobMetrics {
+	return &JobMetrics{
+		finished: r.Counter("pako_tts_jobs_finished_total",
+			"Jobs reaching a final status: completed, failed, cancelled or expired.",
+			"status"),
+	}
+}
+
+// Finished records a job reaching status. A nil JobMetrics records nothing.
+func (m *JobMetrics) Finished(status domain.JobStatus) {
+	if m == nil {
+		return
+	}
+	m.finished.Inc(string(status))
+}
@@ -0,0 +1,177 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("test_total", "A test counter.", "kind")
+	c.Inc("b")
+	c.Add(2, "a")
+	c.Inc(`say "hi"`)
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	want := `# HELP test_total A test counter.
+# TYPE test_total counter
+test_total{kind="a"} 2
+test_total{kind="b"} 1
+test_total{kind="say \"hi\""} 1
+`
+	if got := rec.Body.String(); got != want {
+		t.Errorf("unexpected exposition:\n%s\nwant:\n%s", got, want)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+}
+
+func TestTextMetrics_Observe(t *testing.T) {
+	r := NewRegistry()
+	m := NewTextMetrics(r)
+
+	m.Observe(SourceAsync, "Hello there. How are you?", "en-US")
+	m.Observe(SourceAsync, "<p>Hello</p>", "ru")
+	m.Observe(SourceSync, strings.Repeat("word ", 300), "")
+	m.Observe(SourceSync, "Bonjour", "xx")
+
+	if got := m.requests.Value(SourceAsync, "<100", "latin", "none"); got != 1 {
+		t.Errorf("plain async requests = %v, want 1", got)
+	}
+	if got := m.requests.Value(SourceAsync, "<100", "latin", "html"); got != 1 {
+		t.Errorf("html async requests = %v, want 1", got)
+	}
+	if got := m.requests.Value(SourceSync, "1k-5k", "latin", "none"); got != 1 {
+		t.Errorf("long sync requests = %v, want 1", got)
+	}
+	if got := m.chars.Value(SourceSync, "latin"); got != 1500+7 {
+		t.Errorf("sync chars = %v, want 1507", got)
+	}
+	if got := m.languages.Value(SourceAsync, "en", "true"); got != 1 {
+		t.Errorf("en matches = %v, want 1", got)
+	}
+	if got := m.languages.Value(SourceAsync, "ru", "false"); got != 1 {
+		t.Errorf("ru mismatches = %v, want 1", got)
+	}
+	if got := m.languages.Value(SourceSync, "unset", "unknown"); got != 1 {
+		t.Errorf("unset languages = %v, want 1", got)
+	}
+	if got := m.languages.Value(SourceSync, "other", "unknown"); got != 1 {
+		t.Errorf("other languages = %v, want 1", got)
+	}
+	if got := m.sentences.Value(SourceAsync, "2-5"); got != 1 {
+		t.Errorf("2-5 sentence requests = %v, want 1", got)
+	}
+
+	var nilMetrics *TextMetrics
+	nilMetrics.Observe(SourceSync, "ignored", "") // must not panic
+}
+
+func TestJobMetrics_Finished(t *testing.T) {
+	r := NewRegistry()
+	m := NewJobMetrics(r)
+	m.Finished(domain.JobStatusCompleted)
+	m.Finished(domain.JobStatusExpired)
+	m.Finished(domain.JobStatusExpired)
+	(*JobMetrics)(nil).Finished(domain.JobStatusFailed)
+
+	var out strings.Builder
+	if err := r.WriteText(&out); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	for _, want := range []string{
+		`pako_tts_jobs_finished_total{status="completed"} 1`,
+		`pako_tts_jobs_finished_total{status="expired"} 2`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestResultCacheMetrics_Observe(t *testing.T) {
+	r := NewRegistry()
+	m := NewResultCacheMetrics(r)
+	m.Observe(SourceSync, true, 12)
+	m.Observe(SourceSync, false, 30)
+	m.Observe(SourceAsync, true, 5)
+	(*ResultCacheMetrics)(nil).Observe(SourceSync, true, 1)
+
+	var out strings.Builder
+	if err := r.WriteText(&out); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	for _, want := range []string{
+		`pako_tts_result_cache_lookups_total{source="sync",result="hit"} 1`,
+		`pako_tts_result_cache_lookups_total{source="sync",result="miss"} 1`,
+		`pako_tts_result_cache_saved_chars_total{source="sync"} 12`,
+		`pako_tts_result_cache_saved_chars_total{source="async"} 5`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRegistry_WriteTextHistogram(t *testing.T) {
+	r := NewRegistry()
+	h := r.Histogram("test_seconds", "A test histogram.", []float64{0.5, 1}, "kind")
+	h.Observe(0.25, "a")
+	h.Observe(1, "a")
+	h.Observe(3, "a")
+
+	var out strings.Builder
+	if err := r.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP test_seconds A test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{kind="a",le="0.5"} 1
+test_seconds_bucket{kind="a",le="1"} 2
+test_seconds_bucket{kind="a",le="+Inf"} 3
+test_seconds_sum{kind="a"} 4.25
+test_seconds_count{kind="a"} 3
+`
+	if out.String() != want {
+		t.Errorf("unexpected exposition:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestTTFB_Stats(t *testing.T) {
+	r := NewRegistry()
+	m := NewTTFB(r)
+	for i := 1; i <= 150; i++ {
+		m.Observe(SourceSync, "elevenlabs", "slow", time.Duration(i)*time.Second)
+	}
+	for i := 1; i <= 20; i++ {
+		m.Observe(SourceStream, "elevenlabs", "fast", time.Duration(i)*10*time.Millisecond)
+	}
+	m.Observe(SourceSync, "gemini", "other", time.Millisecond)
+
+	stats := m.Stats("elevenlabs")
+	want := []TTFBStats{
+		{Source: SourceStream, VoiceID: "fast", P50: 100 * time.Millisecond, P95: 190 * time.Millisecond, Samples: 20},
+		// Only the latest 100 samples count: 51s to 150s
+		{Source: SourceSync, VoiceID: "slow", P50: 100 * time.Second, P95: 145 * time.Second, Samples: 100},
+	}
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("got %+v, want %+v", stats, want)
+	}
+	if got := m.histogram.Count(SourceSync, "elevenlabs", "slow"); got != 150 {
+		t.Errorf("histogram count = %d, want 150", got)
+	}
+
+	var nilTTFB *TTFB
+	nilTTFB.Observe(SourceSync, "elevenlabs", "v", time.Second)
+	if nilTTFB.Stats("elevenlabs") != nil {
+		t.Error("expected no stats from a nil TTFB")
+	}
+}
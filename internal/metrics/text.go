@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"github.com/pako-tts/server/internal/textinfo"
+)
+
+// Sources of synthesized text.
+const (
+	SourceSync  = "sync"
+	SourceAsync = "async"
+	// SourceStream is synchronous audio streamed as it is synthesized.
+	SourceStream = "stream"
+)
+
+// TextMetrics records what submitted text looks like, so operators can see the
+// workload mix. Every label takes values from a small fixed set.
+type TextMetrics struct {
+	requests  *CounterVec
+	chars     *CounterVec
+	languages *CounterVec
+	sentences *CounterVec
+}
+
+// NewTextMetrics registers the text metrics on r.
+func NewTextMetrics(r *Registry) *TextMetrics {
+	return &TextMetrics{
+		requests: r.Counter("pako_tts_text_requests_total",
+			"Synthesis requests by text length bucket, dominant script and markup.",
+			"source", "length", "script", "markup"),
+		chars: r.Counter("pako_tts_text_chars_total",
+			"Characters submitted for synthesis by dominant script.",
+			"source", "script"),
+		languages: r.Counter("pako_tts_text_language_total",
+			"Synthesis requests by requested language and whether the text's script matches it.",
+			"source", "language", "script_match"),
+		sentences: r.Counter("pako_tts_text_sentences_total",
+			"Synthesis requests by sentence count bucket, the units a sentence-based chunker would split on.",
+			"source", "sentences"),
+	}
+}
+
+// Observe records one accepted request. A nil TextMetrics records nothing.
+func (m *TextMetrics) Observe(source, text, languageCode string) {
+	if m == nil {
+		return
+	}
+	info := textinfo.Analyze(text)
+
+	m.requests.Inc(source, LengthBucket(info.Chars), info.Script, markup(info))
+	m.chars.Add(float64(info.Chars), source, info.Script)
+
+	language, match := "unset", "unknown"
+	if languageCode != "" {
+		language = textinfo.BaseLanguage(languageCode)
+		if language == "" {
+			language = "other"
+		} else if textinfo.ScriptMatchesLanguage(info.Script, languageCode) {
+			match = "true"
+		} else {
+			match = "false"
+		}
+	}
+	m.languages.Inc(source, language, match)
+
+	m.sentences.Inc(source, SentenceBucket(info.Sentences))
+}
+
+// LengthBucket names the length range a text of chars characters falls in.
+func LengthBucket(chars int) string {
+	switch {
+	case chars < 100:
+		return "<100"
+	case chars < 1000:
+		return "100-999"
+	case chars < 5000:
+		return "1k-5k"
+	case chars < 20000:
+		return "5k-20k"
+	default:
+		return "20k+"
+	}
+}
+
+// SentenceBucket names the sentence count range n falls in.
+func SentenceBucket(n int) string {
+	switch {
+	case n <= 1:
+		return "1"
+	case n <= 5:
+		return "2-5"
+	case n <= 20:
+		return "6-20"
+	case n <= 100:
+		return "21-100"
+	default:
+		return "100+"
+	}
+}
+
+func markup(info textinfo.Info) string {
+	switch {
+	case info.SSML:
+		return "ssml"
+	case info.HTML:
+		return "html"
+	default:
+		return "none"
+	}
+}
@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ttfbBuckets are the histogram upper bounds for time to first byte, in seconds.
+var ttfbBuckets = []float64{0.1, 0.25, 0.5, 0.75, 1, 1.5, 2, 3, 5, 10, 30}
+
+// ttfbWindow is how many recent samples per source, provider and voice the
+// estimates are computed from.
+const ttfbWindow = 100
+
+// TTFB records the time from receiving a synchronous request to writing its
+// first audio byte. Besides the exported histogram, it keeps the latest samples
+// of every source, provider and voice, so clients can be told which voice
+// currently answers fastest. It is safe for concurrent use.
+type TTFB struct {
+	histogram *HistogramVec
+
+	mu     sync.Mutex
+	recent map[ttfbKey]*ttfbSamples
+}
+
+type ttfbKey struct {
+	source, provider, voice string
+}
+
+// ttfbSamples is a ring of the latest samples.
+type ttfbSamples struct {
+	values []time.Duration
+	next   int
+}
+
+// TTFBStats summarizes the recent time to first byte of one voice.
+type TTFBStats struct {
+	Source  string
+	VoiceID string
+	P50     time.Duration
+	P95     time.Duration
+	Samples int
+}
+
+// NewTTFB creates a TTFB recorder. The histogram is registered on r; with a nil
+// r only the recent samples are kept.
+func NewTTFB(r *Registry) *TTFB {
+	t := &TTFB{recent: make(map[ttfbKey]*ttfbSamples)}
+	if r != nil {
+		t.histogram = r.Histogram("pako_tts_time_to_first_byte_seconds",
+			"Time from receiving a synchronous request to writing its first audio byte, excluding speech cache hits.",
+			ttfbBuckets, "source", "provider", "voice")
+	}
+	return t
+}
+
+// Observe records one request's time to first byte. A nil TTFB records nothing.
+func (t *TTFB) Observe(source, provider, voiceID string, d time.Duration) {
+	if t == nil {
+		return
+	}
+	if t.histogram != nil {
+		t.histogram.Observe(d.Seconds(), source, provider, voiceID)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := ttfbKey{source, provider, voiceID}
+	s, ok := t.recent[key]
+	if !ok {
+		s = &ttfbSamples{values: make([]time.Duration, 0, ttfbWindow)}
+		t.recent[key] = s
+	}
+	if len(s.values) < ttfbWindow {
+		s.values = append(s.values, d)
+	} else {
+		s.values[s.next] = d
+		s.next = (s.next + 1) % ttfbWindow
+	}
+}
+
+// Stats returns the recent time to first byte of every voice of provider that
+// has samples, fastest median first. A nil TTFB has no stats.
+func (t *TTFB) Stats(provider string) []TTFBStats {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	var stats []TTFBStats
+	for key, s := range t.recent {
+		if key.provider != provider {
+			continue
+		}
+		sorted := append([]time.Duration(nil), s.values...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		stats = append(stats, TTFBStats{
+			Source:  key.source,
+			VoiceID: key.voice,
+			P50:     quantile(sorted, 0.5),
+			P95:     quantile(sorted, 0.95),
+			Samples: len(sorted),
+		})
+	}
+	t.mu.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].P50 != stats[j].P50 {
+			return stats[i].P50 < stats[j].P50
+		}
+		if stats[i].VoiceID != stats[j].VoiceID {
+			return stats[i].VoiceID < stats[j].VoiceID
+		}
+		return stats[i].Source < stats[j].Source
+	})
+	return stats
+}
+
+// quantile returns the nearest-rank q-quantile of sorted, which must not be empty.
+func quantile(sorted []time.Duration, q float64) time.Duration {
+	i := int(math.Ceil(q*float64(len(sorted)))) - 1
+	return sorted[max(0, min(i, len(sorted)-1))]
+}
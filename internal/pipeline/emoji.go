@@ -0,0 +1,197 @@
+package pipeline
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// How the normalize stage treats emojis.
+const (
+	// EmojiKeep leaves emojis for the provider.
+	EmojiKeep = "keep"
+	// EmojiStrip removes emojis.
+	EmojiStrip = "strip"
+	// EmojiDescribe replaces emojis with their names, e.g. "thumbs up".
+	EmojiDescribe = "describe"
+)
+
+// Characters that modify or join emojis rather than stand for one.
+const (
+	zwj               = '\u200d'
+	emojiPresentation = '\ufe0f'
+	keycap            = '\u20e3'
+	skinToneFirst     = '\U0001F3FB'
+	skinToneLast      = '\U0001F3FF'
+	regionalFirst     = '\U0001F1E6'
+	regionalLast      = '\U0001F1FF'
+	blackFlag         = '\U0001F3F4'
+)
+
+// isModifier reports whether r modifies the emoji before it: a variation selector,
+// a skin tone, or a tag of a subdivision flag such as Scotland's.
+func isModifier(r rune) bool {
+	return r >= 0xFE00 && r <= 0xFE0F || r >= skinToneFirst && r <= skinToneLast || r >= 0xE0020 && r <= 0xE007F
+}
+
+// isEmoji reports whether r is drawn as an emoji by default. Characters that are
+// only drawn as emojis when followed by U+FE0F, such as ©, are found by scanEmoji.
+// Of the miscellaneous symbols and dingbats, which include text symbols such as ✓
+// and ★, only the named ones count.
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x2600 && r <= 0x27BF:
+		return emojiNames[string(r)] != ""
+	case r >= 0x1F000 && r <= 0x1FAFF, // pictographs, emoticons, transport, flags
+		r == 0x231A, r == 0x231B, r == 0x2328, r == 0x23CF,
+		r >= 0x23E9 && r <= 0x23F3, r >= 0x23F8 && r <= 0x23FA,
+		r >= 0x2B05 && r <= 0x2B07, r == 0x2B1B, r == 0x2B1C, r == 0x2B50, r == 0x2B55,
+		r == 0x3030, r == 0x303D, r == 0x3297, r == 0x3299:
+		return true
+	}
+	return false
+}
+
+// scanEmoji returns the length in bytes of the emoji at the start of s, including
+// its presentation selector, skin tone, keycap and the emojis joined to it with
+// U+200D, or 0 when s doesn't start with one.
+func scanEmoji(s string) int {
+	r, n := utf8.DecodeRuneInString(s)
+	next, size := utf8.DecodeRuneInString(s[n:])
+	switch {
+	case strings.ContainsRune("0123456789#*", r):
+		// Keycaps: a digit, an optional U+FE0F and U+20E3
+		if next == emojiPresentation {
+			n += size
+			next, size = utf8.DecodeRuneInString(s[n:])
+		}
+		if next != keycap {
+			return 0
+		}
+		return n + size
+	case r >= regionalFirst && r <= regionalLast:
+		// Flags are pairs of regional indicators
+		if next >= regionalFirst && next <= regionalLast {
+			return n + size
+		}
+		return n
+	case !isEmoji(r) && next != emojiPresentation:
+		return 0
+	}
+
+	for {
+		r, size := utf8.DecodeRuneInString(s[n:])
+		switch {
+		case isModifier(r):
+			n += size
+		case r == zwj:
+			joined, _ := utf8.DecodeRuneInString(s[n+size:])
+			if !isEmoji(joined) {
+				return n
+			}
+			n += size + utf8.RuneLen(joined)
+		default:
+			return n
+		}
+	}
+}
+
+// describeEmoji returns the spoken name of an emoji scanned by scanEmoji, or ""
+// when it has none. Emojis joined with U+200D without a name of their own are
+// described part by part, e.g. "woman laptop".
+func describeEmoji(emoji string) string {
+	r, n := utf8.DecodeRuneInString(emoji)
+	switch {
+	case strings.ContainsRune(emoji, keycap):
+		return string(r)
+	case r >= regionalFirst && r <= regionalLast:
+		return "flag"
+	case r == blackFlag && strings.ContainsFunc(emoji[n:], isModifier):
+		return "flag" // subdivision flags
+	}
+
+	key := strings.Map(func(r rune) rune {
+		if isModifier(r) {
+			return -1
+		}
+		return r
+	}, emoji)
+	if name, ok := emojiNames[key]; ok {
+		return name
+	}
+
+	var names []string
+	for part := range strings.SplitSeq(key, string(zwj)) {
+		if name := emojiNames[part]; name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+// emojiNames holds the names of common emojis, after their CLDR short names,
+// keyed by the emoji without presentation selectors and skin tones.
+var emojiNames = map[string]string{
+	// Faces
+	"😀": "grinning face", "😃": "grinning face", "😄": "grinning face", "😁": "beaming face",
+	"😆": "laughing face", "😅": "grinning face with sweat", "😂": "face with tears of joy",
+	"🤣": "rolling on the floor laughing", "🙂": "smiling face", "😊": "smiling face",
+	"😉": "winking face", "😍": "smiling face with heart eyes", "😘": "face blowing a kiss",
+	"😋": "face savoring food", "😎": "smiling face with sunglasses", "🤔": "thinking face",
+	"🤗": "hugging face", "🤩": "star-struck", "🥳": "partying face", "😐": "neutral face",
+	"🙄": "face with rolling eyes", "😏": "smirking face", "😴": "sleeping face",
+	"😢": "crying face", "😭": "loudly crying face", "😡": "angry face", "😠": "angry face",
+	"😱": "face screaming in fear", "😳": "flushed face", "🥺": "pleading face",
+	"😬": "grimacing face", "🤯": "exploding head", "😷": "face with medical mask",
+	"🙃": "upside-down face", "😇": "smiling face with halo", "🤓": "nerd face",
+	"☺": "smiling face", "☹": "frowning face", "🙁": "frowning face", "😞": "disappointed face",
+	"😮": "face with open mouth", "🤐": "zipper-mouth face", "🤷": "person shrugging",
+	"🤦": "person facepalming", "💀": "skull", "💩": "pile of poo", "👻": "ghost", "🤖": "robot",
+
+	// Hands and people
+	"👍": "thumbs up", "👎": "thumbs down", "👌": "OK hand", "✌": "victory hand",
+	"🤞": "crossed fingers", "👏": "clapping hands", "🙌": "raising hands", "🙏": "folded hands",
+	"👋": "waving hand", "✋": "raised hand", "💪": "flexed biceps", "👉": "backhand index pointing right",
+	"👈": "backhand index pointing left", "👆": "backhand index pointing up",
+	"👇": "backhand index pointing down", "☝": "index pointing up", "🤝": "handshake",
+	"✍": "writing hand", "👀": "eyes", "🧠": "brain",
+	"👨": "man", "👩": "woman", "👦": "boy", "👧": "girl", "👶": "baby", "🧑": "person",
+	"👴": "old man", "👵": "old woman",
+	"👨‍👩‍👧": "family", "👨‍👩‍👦": "family", "👨‍👩‍👧‍👦": "family", "👪": "family",
+	"👩‍💻": "woman technologist", "👨‍💻": "man technologist", "🧑‍💻": "technologist",
+
+	// Hearts and symbols
+	"❤": "red heart", "🧡": "orange heart", "💛": "yellow heart", "💚": "green heart",
+	"💙": "blue heart", "💜": "purple heart", "🖤": "black heart", "🤍": "white heart",
+	"💔": "broken heart", "💕": "two hearts", "💖": "sparkling heart", "❤‍🔥": "heart on fire",
+	"✅": "check mark", "✔": "check mark", "☑": "check box with check", "❌": "cross mark",
+	"❎": "cross mark", "❗": "exclamation mark", "❓": "question mark", "⚠": "warning",
+	"⛔": "no entry", "🚫": "prohibited", "💯": "hundred points", "🔥": "fire", "✨": "sparkles",
+	"⭐": "star", "🌟": "glowing star", "💥": "collision", "💤": "zzz", "💡": "light bulb",
+	"🔔": "bell", "📌": "pushpin", "📍": "round pushpin", "🔗": "link", "🔒": "locked",
+	"🔑": "key", "♻": "recycling symbol", "➡": "right arrow", "⬅": "left arrow",
+	"⬆": "up arrow", "⬇": "down arrow", "🆕": "new button", "🆗": "OK button",
+	"©": "copyright", "®": "registered", "™": "trade mark",
+
+	// Celebration and objects
+	"🎉": "party popper", "🎊": "confetti ball", "🎁": "wrapped gift", "🎂": "birthday cake",
+	"🎈": "balloon", "🏆": "trophy", "🥇": "first place medal", "🎯": "bullseye",
+	"🚀": "rocket", "📈": "chart increasing", "📉": "chart decreasing", "📊": "bar chart",
+	"📅": "calendar", "📆": "calendar", "⏰": "alarm clock", "⌛": "hourglass done",
+	"⏳": "hourglass not done", "⌚": "watch", "📱": "mobile phone", "💻": "laptop",
+	"📧": "e-mail", "✉": "envelope", "📞": "telephone receiver", "☎": "telephone",
+	"📦": "package", "🛒": "shopping cart", "💰": "money bag", "💵": "dollar banknote",
+	"💳": "credit card", "📝": "memo", "📚": "books", "📖": "open book", "🔍": "magnifying glass",
+	"🎵": "musical note", "🎶": "musical notes", "🎧": "headphone", "📷": "camera",
+	"🏠": "house", "🏢": "office building", "🏖": "beach with umbrella", "✈": "airplane",
+	"🚗": "car", "🚌": "bus", "🚲": "bicycle", "🚨": "police car light",
+
+	// Nature, food and weather
+	"☀": "sun", "🌞": "sun with face", "🌙": "crescent moon", "☁": "cloud", "🌧": "cloud with rain",
+	"⛈": "cloud with lightning and rain", "❄": "snowflake", "⚡": "high voltage", "🌈": "rainbow",
+	"☔": "umbrella with rain drops", "🌍": "globe showing Europe-Africa",
+	"🌎": "globe showing Americas", "🌏": "globe showing Asia-Australia", "🌸": "cherry blossom",
+	"🌹": "rose", "🌻": "sunflower", "🌳": "deciduous tree", "🍀": "four leaf clover",
+	"🐶": "dog face", "🐱": "cat face", "🐻": "bear", "🦄": "unicorn", "🐝": "honeybee",
+	"🍕": "pizza", "🍔": "hamburger", "🍎": "red apple", "🍰": "shortcake", "☕": "hot beverage",
+	"🍺": "beer mug", "🍷": "wine glass", "🥂": "clinking glasses",
+}
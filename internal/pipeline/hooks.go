@@ -0,0 +1,210 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Rewrite is the name of the stage that sends text to a registered hook.
+const Rewrite = "rewrite"
+
+// Hook rewrites text by calling an external service, e.g. a language model
+// adjusting its tone or a service turning it into SSML.
+type Hook interface {
+	Rewrite(ctx context.Context, text string) (string, error)
+}
+
+// HookOptions are the defaults of a hook, which the params of a stage calling
+// it override.
+type HookOptions struct {
+	// Timeout bounds each call; 0 leaves it to the hook.
+	Timeout time.Duration
+	// CacheTTL is how long the hook's output for a text is reused; 0 disables
+	// caching.
+	CacheTTL time.Duration
+}
+
+// Failure policies of stages calling a hook, set with their on_error param.
+const (
+	// OnErrorFail fails the request or job when the hook fails.
+	OnErrorFail = "fail"
+	// OnErrorSkip passes the text on unchanged when the hook fails.
+	OnErrorSkip = "skip"
+)
+
+// hookParams are the params every stage calling a hook takes.
+var hookParams = []Param{
+	{Name: "timeout_seconds", Type: TypeNumber, Description: "Timeout of the call, up to the hook's own", Min: bound(1), Max: bound(600)},
+	{Name: "cache_seconds", Type: TypeNumber, Description: "How long the output for the same text is reused (0 = no caching)", Min: bound(0), Max: bound(7 * 24 * 3600)},
+	{Name: "on_error", Type: TypeString, Description: `"fail" fails the request when the call fails (default), "skip" speaks the text unchanged`},
+}
+
+type registeredHook struct {
+	hook Hook
+	opts HookOptions
+}
+
+var (
+	hooksMu sync.RWMutex
+	hooks   = make(map[string]registeredHook)
+
+	// onHookError is told about every failed hook call, skipped or not.
+	onHookError func(stage string, err error)
+)
+
+// RegisterHook makes hook available to rewrite stages under name, replacing any
+// registered under the same name. The rewrite stage is listed once a hook is
+// registered.
+func RegisterHook(name string, hook Hook, opts HookOptions) {
+	hooksMu.Lock()
+	hooks[name] = registeredHook{hook: hook, opts: opts}
+	hooksMu.Unlock()
+
+	Register(Processor{
+		Name:        Rewrite,
+		Kind:        KindText,
+		Description: "Sends the text to a configured rewrite hook, e.g. for tone adjustment, script formatting or SSML generation",
+		Params: append([]Param{
+			{Name: "hook", Type: TypeString, Required: true, Description: "Name of the hook"},
+		}, hookParams...),
+		ReplacesText: true,
+		BuildText:    buildRewrite,
+	})
+}
+
+// OnHookError registers fn to be called with the stage and error of every failed
+// hook call, including those the stage's on_error policy skips. It must be set
+// before requests are served.
+func OnHookError(fn func(stage string, err error)) {
+	onHookError = fn
+}
+
+func buildRewrite(params map[string]any) (TextFunc, error) {
+	name, _ := params["hook"].(string)
+	hooksMu.RLock()
+	h, ok := hooks[name]
+	hooksMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown hook %q", name)
+	}
+	return hookStage(Rewrite+" "+name, name, h.hook, params, h.opts)
+}
+
+// hookStage runs hook as a text stage named stage, with the timeout, caching and
+// failure policy its params set, or else defaults. Outputs are cached by scope
+// and input text, so scope must tell apart hooks that rewrite text differently.
+func hookStage(stage, scope string, hook Hook, params map[string]any, defaults HookOptions) (TextFunc, error) {
+	onError, _ := params["on_error"].(string)
+	switch onError {
+	case "":
+		onError = OnErrorFail
+	case OnErrorFail, OnErrorSkip:
+	default:
+		return nil, fmt.Errorf("param %q must be %q or %q", "on_error", OnErrorFail, OnErrorSkip)
+	}
+	timeout := defaults.Timeout
+	if v, ok := params["timeout_seconds"].(float64); ok {
+		timeout = time.Duration(v * float64(time.Second))
+	}
+	ttl := defaults.CacheTTL
+	if v, ok := params["cache_seconds"].(float64); ok {
+		ttl = time.Duration(v * float64(time.Second))
+	}
+
+	return func(ctx context.Context, text string) (string, error) {
+		key := sha256.Sum256([]byte(scope + "\x00" + text))
+		if ttl > 0 {
+			if cached, ok := hookCache.get(key); ok {
+				return cached, nil
+			}
+		}
+
+		callCtx := ctx
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			callCtx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		out, err := hook.Rewrite(callCtx, text)
+		if err != nil {
+			if onHookError != nil {
+				onHookError(stage, err)
+			}
+			// A request that was given up on isn't worth speaking unchanged either
+			if onError == OnErrorSkip && ctx.Err() == nil {
+				return text, nil
+			}
+			return "", fmt.Errorf("%s: %w", stage, err)
+		}
+
+		if ttl > 0 {
+			hookCache.put(key, out, time.Now().Add(ttl))
+		}
+		return out, nil
+	}, nil
+}
+
+// maxCachedRewrites caps the number of hook outputs kept.
+const maxCachedRewrites = 1024
+
+// hookCache keeps hook outputs by scope and input text until they expire.
+var hookCache = &rewriteCache{entries: make(map[[32]byte]cachedRewrite)}
+
+type rewriteCache struct {
+	mu      sync.Mutex
+	entries map[[32]byte]cachedRewrite
+}
+
+type cachedRewrite struct {
+	text    string
+	expires time.Time
+}
+
+func (c *rewriteCache) get(key [32]byte) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return "", false
+	}
+	return entry.text, true
+}
+
+func (c *rewriteCache) put(key [32]byte, text string, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxCachedRewrites {
+		now := time.Now()
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		// Still full: drop an arbitrary entry
+		for k := range c.entries {
+			if len(c.entries) < maxCachedRewrites {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cachedRewrite{text: text, expires: expires}
+}
+
+// CompleterHook returns a hook that sends instructions and the text to a
+// language model, and speaks its reply.
+func CompleterHook(llm Completer, instructions string) Hook {
+	return completerHook{llm: llm, instructions: instructions}
+}
+
+type completerHook struct {
+	llm          Completer
+	instructions string
+}
+
+func (h completerHook) Rewrite(ctx context.Context, text string) (string, error) {
+	return h.llm.Complete(ctx, h.instructions, text)
+}
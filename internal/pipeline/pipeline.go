@@ -0,0 +1,279 @@
+// Package pipeline composes a request's processing from named stages: text stages
+// run before synthesis and audio stages after it. Stages are chosen from the
+// registered processors, and a pipeline is validated against their parameter
+// schemas before the request is accepted.
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// MaxStages caps the length of a pipeline.
+const MaxStages = 16
+
+// Kind says where in a pipeline a processor runs.
+type Kind string
+
+const (
+	// KindText processors rewrite the text before synthesis.
+	KindText Kind = "text"
+	// KindSynthesize is the synthesis step itself; every pipeline has it once.
+	KindSynthesize Kind = "synthesize"
+	// KindAudio processors rewrite the audio after synthesis.
+	KindAudio Kind = "audio"
+)
+
+// Param types.
+const (
+	TypeString = "string"
+	TypeNumber = "number"
+	// TypeMap is an object of string values.
+	TypeMap = "map"
+)
+
+// Synthesize is the name of the synthesis stage.
+const Synthesize = "synthesize"
+
+// TextFunc is a configured text stage.
+type TextFunc func(ctx context.Context, text string) (string, error)
+
+// AudioFunc is a configured audio stage. format is "mp3" or "wav", and the audio
+// it returns must stay in that format.
+type AudioFunc func(ctx context.Context, audio []byte, format string) ([]byte, error)
+
+// Param describes a processor parameter.
+type Param struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Required    bool   `json:"required,omitempty"`
+	Description string `json:"description"`
+	// Min and Max bound number params when set.
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+}
+
+// Processor is a stage that pipelines can name. Text processors set BuildText and
+// audio processors BuildAudio; both receive params already checked against Params.
+type Processor struct {
+	Name        string  `json:"name"`
+	Kind        Kind    `json:"kind"`
+	Description string  `json:"description"`
+	Params      []Param `json:"params"`
+	// ReplacesText marks text stages whose output says something other than their
+	// input, such as a summary, so the text synthesized is kept for audit.
+	ReplacesText bool `json:"replaces_text,omitempty"`
+
+	BuildText  func(params map[string]any) (TextFunc, error)  `json:"-"`
+	BuildAudio func(params map[string]any) (AudioFunc, error) `json:"-"`
+}
+
+// processors holds the registered processors keyed by name.
+var processors = make(map[string]Processor)
+
+// Register makes a processor available to pipelines, replacing any registered
+// under the same name.
+func Register(p Processor) {
+	if p.Params == nil {
+		p.Params = []Param{}
+	}
+	processors[p.Name] = p
+}
+
+// Processors returns the registered processors: text stages, synthesis, then audio
+// stages, each sorted by name.
+func Processors() []Processor {
+	order := map[Kind]int{KindText: 0, KindSynthesize: 1, KindAudio: 2}
+	list := make([]Processor, 0, len(processors))
+	for _, p := range processors {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Kind != list[j].Kind {
+			return order[list[i].Kind] < order[list[j].Kind]
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// Error is a pipeline rejected by Compile.
+type Error struct {
+	// Index is the position of the offending stage, or -1 for the pipeline as a whole.
+	Index   int
+	Stage   string
+	Message string
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if e.Index < 0 {
+		return "pipeline: " + e.Message
+	}
+	return fmt.Sprintf("pipeline stage %d (%s): %s", e.Index, e.Stage, e.Message)
+}
+
+// Pipeline is a compiled, validated pipeline. A nil *Pipeline leaves text and
+// audio unchanged.
+type Pipeline struct {
+	text     []TextFunc
+	audio    []AudioFunc
+	replaces bool
+}
+
+// Compile validates stages and configures their processors. A pipeline holds the
+// synthesize stage exactly once, with text stages before it and audio stages after
+// it. An empty pipeline compiles to nil. Errors are always *Error.
+func Compile(stages []domain.PipelineStage) (*Pipeline, error) {
+	if len(stages) == 0 {
+		return nil, nil
+	}
+	if len(stages) > MaxStages {
+		return nil, &Error{Index: -1, Message: fmt.Sprintf("a pipeline has at most %d stages", MaxStages)}
+	}
+
+	p := &Pipeline{}
+	synthesized := false
+	for i, stage := range stages {
+		proc, ok := processors[stage.Stage]
+		if !ok {
+			return nil, &Error{Index: i, Stage: stage.Stage, Message: "unknown stage"}
+		}
+		if err := checkParams(proc, stage.Params); err != nil {
+			return nil, &Error{Index: i, Stage: stage.Stage, Message: err.Error()}
+		}
+
+		var err error
+		switch proc.Kind {
+		case KindSynthesize:
+			if synthesized {
+				return nil, &Error{Index: i, Stage: stage.Stage, Message: "synthesize may appear only once"}
+			}
+			synthesized = true
+		case KindText:
+			if synthesized {
+				return nil, &Error{Index: i, Stage: stage.Stage, Message: "text stages must come before synthesize"}
+			}
+			var fn TextFunc
+			if fn, err = proc.BuildText(stage.Params); err == nil {
+				p.text = append(p.text, fn)
+				p.replaces = p.replaces || proc.ReplacesText
+			}
+		case KindAudio:
+			if !synthesized {
+				return nil, &Error{Index: i, Stage: stage.Stage, Message: "audio stages must come after synthesize"}
+			}
+			var fn AudioFunc
+			if fn, err = proc.BuildAudio(stage.Params); err == nil {
+				p.audio = append(p.audio, fn)
+			}
+		}
+		if err != nil {
+			return nil, &Error{Index: i, Stage: stage.Stage, Message: err.Error()}
+		}
+	}
+	if !synthesized {
+		return nil, &Error{Index: -1, Message: "the pipeline must include a synthesize stage"}
+	}
+	return p, nil
+}
+
+// Text runs the text stages in order.
+func (p *Pipeline) Text(ctx context.Context, text string) (string, error) {
+	if p == nil {
+		return text, nil
+	}
+	var err error
+	for _, fn := range p.text {
+		if text, err = fn(ctx, text); err != nil {
+			return "", err
+		}
+	}
+	return text, nil
+}
+
+// ReplacesText reports whether the pipeline has a text stage that replaces the
+// text rather than adjusting it.
+func (p *Pipeline) ReplacesText() bool {
+	return p != nil && p.replaces
+}
+
+// HasAudio reports whether the pipeline has audio stages.
+func (p *Pipeline) HasAudio() bool {
+	return p != nil && len(p.audio) > 0
+}
+
+// Audio runs the audio stages in order.
+func (p *Pipeline) Audio(ctx context.Context, audio []byte, format string) ([]byte, error) {
+	if p == nil {
+		return audio, nil
+	}
+	var err error
+	for _, fn := range p.audio {
+		if audio, err = fn(ctx, audio, format); err != nil {
+			return nil, err
+		}
+	}
+	return audio, nil
+}
+
+// checkParams validates params against the processor's schema.
+func checkParams(proc Processor, params map[string]any) error {
+	known := make(map[string]Param, len(proc.Params))
+	for _, param := range proc.Params {
+		known[param.Name] = param
+		if _, ok := params[param.Name]; param.Required && !ok {
+			return fmt.Errorf("param %q is required", param.Name)
+		}
+	}
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		param, ok := known[name]
+		if !ok {
+			return fmt.Errorf("unknown param %q", name)
+		}
+		if err := checkValue(param, params[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkValue(param Param, value any) error {
+	switch param.Type {
+	case TypeString:
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("param %q must be a string", param.Name)
+		}
+	case TypeNumber:
+		n, ok := value.(float64)
+		if !ok {
+			return fmt.Errorf("param %q must be a number", param.Name)
+		}
+		if param.Min != nil && n < *param.Min {
+			return fmt.Errorf("param %q must be at least %g", param.Name, *param.Min)
+		}
+		if param.Max != nil && n > *param.Max {
+			return fmt.Errorf("param %q must be at most %g", param.Name, *param.Max)
+		}
+	case TypeMap:
+		m, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("param %q must be an object", param.Name)
+		}
+		for k, v := range m {
+			if _, ok := v.(string); !ok {
+				return fmt.Errorf("param %q: value of %q must be a string", param.Name, k)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pako-tts/server/internal/audio/transcode"
+	"github.com/pako-tts/server/internal/domain"
+)
+
+func TestCompile(t *testing.T) {
+	synth := domain.PipelineStage{Stage: "synthesize"}
+	tests := []struct {
+		name      string
+		stages    []domain.PipelineStage
+		wantIndex int
+		wantErr   bool
+	}{
+		{"full pipeline", []domain.PipelineStage{
+			{Stage: "normalize"},
+			{Stage: "lexicon", Params: map[string]any{"entries": map[string]any{"SQL": "sequel"}}},
+			synth,
+			{Stage: "trim-silence", Params: map[string]any{"threshold_db": -40.0}},
+			{Stage: "loudness-normalize"},
+			{Stage: "tag", Params: map[string]any{"title": "Intro"}},
+		}, 0, false},
+		{"synthesize only", []domain.PipelineStage{synth}, 0, false},
+		{"no synthesize", []domain.PipelineStage{{Stage: "normalize"}}, -1, true},
+		{"synthesize twice", []domain.PipelineStage{synth, synth}, 1, true},
+		{"unknown stage", []domain.PipelineStage{{Stage: "reverb"}, synth}, 0, true},
+		{"text after synthesize", []domain.PipelineStage{synth, {Stage: "normalize"}}, 1, true},
+		{"audio before synthesize", []domain.PipelineStage{{Stage: "trim-silence"}, synth}, 0, true},
+		{"missing required param", []domain.PipelineStage{{Stage: "lexicon"}, synth}, 0, true},
+		{"unknown param", []domain.PipelineStage{synth, {Stage: "trim-silence", Params: map[string]any{"db": -40.0}}}, 1, true},
+		{"param out of range", []domain.PipelineStage{synth, {Stage: "loudness-normalize", Params: map[string]any{"target_lufs": 3.0}}}, 1, true},
+		{"wrong param type", []domain.PipelineStage{synth, {Stage: "tag", Params: map[string]any{"title": 1.0}}}, 1, true},
+		{"empty tag", []domain.PipelineStage{synth, {Stage: "tag"}}, 1, true},
+		{"unknown emoji mode", []domain.PipelineStage{{Stage: "normalize", Params: map[string]any{"emojis": "spell"}}, synth}, 0, true},
+		{"unknown language", []domain.PipelineStage{{Stage: "normalize", Params: map[string]any{"language": "xx"}}, synth}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.stages)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var perr *Error
+			if !errors.As(err, &perr) {
+				t.Fatalf("expected *Error, got %v", err)
+			}
+			if perr.Index != tt.wantIndex {
+				t.Errorf("expected index %d, got %d (%s)", tt.wantIndex, perr.Index, perr.Message)
+			}
+		})
+	}
+
+	if p, err := Compile(nil); p != nil || err != nil {
+		t.Errorf("expected an empty pipeline to compile to nil, got %v, %v", p, err)
+	}
+}
+
+func TestPipeline_Text(t *testing.T) {
+	p, err := Compile([]domain.PipelineStage{
+		{Stage: "normalize"},
+		{Stage: "lexicon", Params: map[string]any{"entries": map[string]any{"SQL": "sequel", "SQL Server": "sequel server"}}},
+		{Stage: "synthesize"},
+	})
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	got, err := p.Text(context.Background(), "  “SQL Server”\u200b vs\tSQL…\n\n\n\nNoSQL and SQLite  ")
+	if err != nil {
+		t.Fatalf("Text: %v", err)
+	}
+	want := "\"sequel server\" vs sequel...\n\nNoSQL and SQLite"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestNormalize_Emojis(t *testing.T) {
+	tests := []struct {
+		mode string
+		text string
+		want string
+	}{
+		{EmojiKeep, "Great job 🎉👍🏽 see you 👨\u200d👩\u200d👧!", "Great job 🎉👍🏽 see you 👨\u200d👩\u200d👧!"},
+		{EmojiStrip, "Great job 🎉👍🏽 see you 👨\u200d👩\u200d👧!", "Great job see you!"},
+		{EmojiDescribe, "Great job 🎉👍🏽 see you 👨\u200d👩\u200d👧!", "Great job party popper thumbs up see you family!"},
+		{EmojiDescribe, "Ship it🚀now. ❤\ufe0f\u200d🔥 👩🏾\u200d🚒 🇩🇪", "Ship it rocket now. heart on fire woman flag"},
+		{EmojiDescribe, "Press 1\ufe0f\u20e3 or #\u20e3. © 2024, not ©\ufe0f", "Press 1 or #. © 2024, not copyright"},
+		{EmojiStrip, "\u202aBold\u202c soft\u00adhyphen \ue000private\ufffd ✓ done", "Bold softhyphen private ✓ done"},
+	}
+
+	for _, tt := range tests {
+		p, err := Compile([]domain.PipelineStage{
+			{Stage: "normalize", Params: map[string]any{"emojis": tt.mode}},
+			{Stage: "synthesize"},
+		})
+		if err != nil {
+			t.Fatalf("Compile: %v", err)
+		}
+		got, err := p.Text(context.Background(), tt.text)
+		if err != nil {
+			t.Fatalf("Text: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("%s %q: expected %q, got %q", tt.mode, tt.text, tt.want, got)
+		}
+	}
+}
+
+func TestNormalize_Language(t *testing.T) {
+	p, err := Compile([]domain.PipelineStage{
+		{Stage: "normalize", Params: map[string]any{"language": "de-DE"}},
+		{Stage: "synthesize"},
+	})
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	got, err := p.Text(context.Background(), "Am 3. Mai um 9:30 kostet es 12,50 €.")
+	if err != nil {
+		t.Fatalf("Text: %v", err)
+	}
+	if want := "Am dritten Mai um neun Uhr dreißig kostet es zwölf Euro und fünfzig Cent."; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+// fakeCompleter answers every prompt with reply, recording the instructions.
+type fakeCompleter struct {
+	reply  string
+	system string
+	calls  int
+}
+
+func (c *fakeCompleter) Complete(ctx context.Context, system, input string) (string, error) {
+	c.system = system
+	c.calls++
+	return c.reply, nil
+}
+
+func TestSummarize(t *testing.T) {
+	llm := &fakeCompleter{reply: "The council approved the budget."}
+	RegisterSummarize(llm)
+	t.Cleanup(func() { delete(processors, Summarize) })
+
+	p, err := Compile([]domain.PipelineStage{
+		{Stage: Summarize, Params: map[string]any{"style": StyleBrief, "max_words": 40.0, "min_chars": 20.0}},
+		{Stage: "synthesize"},
+	})
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	if !p.ReplacesText() {
+		t.Error("expected a pipeline with summarize to replace its text")
+	}
+
+	got, err := p.Text(context.Background(), "After a four-hour session, the city council approved the budget.")
+	if err != nil || got != llm.reply {
+		t.Fatalf("expected the summary, got %q, %v", got, err)
+	}
+	if !strings.Contains(llm.system, "briefing") || !strings.Contains(llm.system, "at most 40 words") {
+		t.Errorf("unexpected instructions %q", llm.system)
+	}
+
+	// Texts under min_chars are spoken as they are
+	if got, _ := p.Text(context.Background(), "Budget approved."); got != "Budget approved." || llm.calls != 1 {
+		t.Errorf("expected a short text unchanged without a call, got %q after %d calls", got, llm.calls)
+	}
+
+	if _, err := Compile([]domain.PipelineStage{{Stage: Summarize, Params: map[string]any{"style": "poetic"}}, {Stage: "synthesize"}}); err == nil {
+		t.Error("expected an unknown style to be rejected")
+	}
+}
+
+// countingHook uppercases text, or fails with err, counting its calls.
+type countingHook struct {
+	err   error
+	calls int
+}
+
+func (h *countingHook) Rewrite(ctx context.Context, text string) (string, error) {
+	h.calls++
+	if h.err != nil {
+		return "", h.err
+	}
+	return strings.ToUpper(text), nil
+}
+
+func TestRewrite(t *testing.T) {
+	tone := &countingHook{}
+	broken := &countingHook{err: errors.New("service down")}
+	RegisterHook("tone", tone, HookOptions{})
+	RegisterHook("broken", broken, HookOptions{})
+	var failed []string
+	OnHookError(func(stage string, err error) { failed = append(failed, stage) })
+	t.Cleanup(func() {
+		delete(processors, Rewrite)
+		hooks = make(map[string]registeredHook)
+		OnHookError(nil)
+	})
+
+	compile := func(params map[string]any) *Pipeline {
+		t.Helper()
+		p, err := Compile([]domain.PipelineStage{{Stage: Rewrite, Params: params}, {Stage: "synthesize"}})
+		if err != nil {
+			t.Fatalf("Compile: %v", err)
+		}
+		return p
+	}
+
+	cached := compile(map[string]any{"hook": "tone", "cache_seconds": 60.0})
+	for range 2 {
+		if got, err := cached.Text(context.Background(), "cache me"); err != nil || got != "CACHE ME" {
+			t.Fatalf("expected the rewritten text, got %q, %v", got, err)
+		}
+	}
+	if tone.calls != 1 {
+		t.Errorf("expected the second rewrite from the cache, got %d calls", tone.calls)
+	}
+
+	if _, err := compile(map[string]any{"hook": "broken"}).Text(context.Background(), "hello"); err == nil || !strings.Contains(err.Error(), "rewrite broken: service down") {
+		t.Errorf("expected the hook's error to fail the pipeline, got %v", err)
+	}
+	skipping := compile(map[string]any{"hook": "broken", "on_error": OnErrorSkip})
+	if got, err := skipping.Text(context.Background(), "hello"); err != nil || got != "hello" {
+		t.Errorf("expected the text unchanged, got %q, %v", got, err)
+	}
+	if len(failed) != 2 {
+		t.Errorf("expected both failures reported, got %v", failed)
+	}
+
+	for _, params := range []map[string]any{
+		{"hook": "missing"},
+		{"hook": "tone", "on_error": "retry"},
+		{"hook": "tone", "timeout_seconds": 0.0},
+	} {
+		if _, err := Compile([]domain.PipelineStage{{Stage: Rewrite, Params: params}, {Stage: "synthesize"}}); err == nil {
+			t.Errorf("expected params %v to be rejected", params)
+		}
+	}
+}
+
+func TestTags_Write(t *testing.T) {
+	tags := Tags{Title: "Intro", Comment: "Episode 1"}
+
+	mp3 := []byte{0xff, 0xfb, 0x90, 0x00}
+	out, err := tags.Write(mp3, "mp3")
+	if err != nil {
+		t.Fatalf("Write mp3: %v", err)
+	}
+	if !bytes.HasPrefix(out, []byte("ID3\x04")) || !bytes.HasSuffix(out, mp3) || !bytes.Contains(out, []byte("TIT2")) {
+		t.Errorf("expected an ID3v2.4 tag in front of the audio, got %q", out)
+	}
+
+	pcm := []byte{1, 2, 3, 4}
+	out, err = tags.Write(transcode.PCMToWAV(pcm, 16000, 1, 16), "wav")
+	if err != nil {
+		t.Fatalf("Write wav: %v", err)
+	}
+	got, rate, _, _, ok := transcode.ParseWAV(out)
+	if !ok || rate != 16000 || !bytes.Equal(got, pcm) {
+		t.Errorf("expected the tagged WAV to keep its audio, got %v %d %v", got, rate, ok)
+	}
+	if !bytes.Contains(out, []byte("LIST")) || !bytes.Contains(out, []byte("INFOINAM")) || !bytes.Contains(out, []byte("Intro\x00")) {
+		t.Errorf("expected a LIST/INFO chunk, got %q", out)
+	}
+
+	if _, err := tags.Write([]byte("raw pcm"), "wav"); err == nil {
+		t.Error("expected headerless audio to be rejected")
+	}
+}
@@ -0,0 +1,271 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pako-tts/server/internal/audio/effects"
+	"github.com/pako-tts/server/internal/verbalize"
+)
+
+func init() {
+	// Register built-in processors
+	Register(Processor{
+		Name:        "normalize",
+		Kind:        KindText,
+		Description: "Replaces typographic quotes, dashes and ellipses with ASCII, drops invisible and private-use characters, collapses whitespace, keeps, strips or describes emojis, and optionally writes out numbers, dates, times and amounts of money",
+		Params: []Param{
+			{Name: "emojis", Type: TypeString, Description: `"keep" leaves emojis to the provider (default), "strip" removes them, "describe" replaces them with their names, e.g. "thumbs up"`},
+			{Name: "language", Type: TypeString, Description: `Writes out numbers, ordinals, dates, times and amounts of money as read in this language: "en", "de", "fr", "pl" or "es"; unset leaves them to the provider`},
+		},
+		BuildText: buildNormalize,
+	})
+	Register(Processor{
+		Name:        "lexicon",
+		Kind:        KindText,
+		Description: "Replaces whole words with how they should be spoken, e.g. {\"SQL\": \"sequel\"}; matching is case-sensitive",
+		Params: []Param{
+			{Name: "entries", Type: TypeMap, Required: true, Description: "Words mapped to their replacements"},
+		},
+		BuildText: buildLexicon,
+	})
+	Register(Processor{
+		Name:        Synthesize,
+		Kind:        KindSynthesize,
+		Description: "Synthesizes the text with the request's provider, voice and settings",
+	})
+	Register(Processor{
+		Name:        "trim-silence",
+		Kind:        KindAudio,
+		Description: "Removes leading and trailing silence",
+		Params: []Param{
+			{Name: "threshold_db", Type: TypeNumber, Description: "Level below which audio counts as silence (default -50)", Min: bound(-90), Max: bound(-20)},
+		},
+		BuildAudio: buildTrimSilence,
+	})
+	Register(Processor{
+		Name:        "loudness-normalize",
+		Kind:        KindAudio,
+		Description: "Normalizes loudness to a target (EBU R128)",
+		Params: []Param{
+			{Name: "target_lufs", Type: TypeNumber, Description: "Integrated loudness target (default -16)", Min: bound(-70), Max: bound(-5)},
+			{Name: "true_peak", Type: TypeNumber, Description: "Maximum true peak in dBTP (default -1.5)", Min: bound(-9), Max: bound(0)},
+		},
+		BuildAudio: buildLoudnessNormalize,
+	})
+	Register(Processor{
+		Name:        "tag",
+		Kind:        KindAudio,
+		Description: "Writes metadata into the file: ID3v2 for MP3, a RIFF INFO list for WAV",
+		Params: []Param{
+			{Name: "title", Type: TypeString, Description: "Title"},
+			{Name: "artist", Type: TypeString, Description: "Artist"},
+			{Name: "album", Type: TypeString, Description: "Album"},
+			{Name: "comment", Type: TypeString, Description: "Comment"},
+		},
+		BuildAudio: buildTag,
+	})
+}
+
+// bound returns a pointer to a param bound.
+func bound(v float64) *float64 {
+	return &v
+}
+
+// number returns a number param, or def when it is absent.
+func number(params map[string]any, name string, def float64) float64 {
+	if v, ok := params[name].(float64); ok {
+		return v
+	}
+	return def
+}
+
+// normalizeReplacer maps typographic characters to the ASCII a voice reads the same.
+var normalizeReplacer = strings.NewReplacer(
+	"‘", "'", "’", "'", "‚", "'", "‛", "'",
+	"“", `"`, "”", `"`, "„", `"`, "‟", `"`,
+	"–", "-", "—", " - ", "…", "...",
+	"\u00a0", " ", "\u202f", " ",
+)
+
+func buildNormalize(params map[string]any) (TextFunc, error) {
+	emojis, _ := params["emojis"].(string)
+	switch emojis {
+	case "":
+		emojis = EmojiKeep
+	case EmojiKeep, EmojiStrip, EmojiDescribe:
+	default:
+		return nil, fmt.Errorf("param %q must be %q, %q or %q", "emojis", EmojiKeep, EmojiStrip, EmojiDescribe)
+	}
+	language, _ := params["language"].(string)
+	if language != "" && !verbalize.Supported(language) {
+		return nil, fmt.Errorf("param %q must be one of %s", "language", strings.Join(verbalize.Languages(), ", "))
+	}
+
+	return func(ctx context.Context, text string) (string, error) {
+		text = normalizeReplacer.Replace(text)
+
+		var b strings.Builder
+		b.Grow(len(text))
+		space, newlines := false, 0
+		for i := 0; i < len(text); {
+			r, size := utf8.DecodeRuneInString(text[i:])
+			if n := scanEmoji(text[i:]); n > 0 {
+				emoji := text[i : i+n]
+				i += n
+				if emojis == EmojiKeep {
+					b.WriteString(separator(b.Len(), space, newlines))
+					b.WriteString(emoji)
+					space, newlines = false, 0
+					continue
+				}
+				if name := describeEmoji(emoji); emojis == EmojiDescribe && name != "" {
+					b.WriteString(separator(b.Len(), true, newlines))
+					b.WriteString(name)
+					newlines = 0
+				}
+				// Whatever took the emoji's place is a word of its own, but punctuation
+				// after it stays attached to the word before
+				next, _ := utf8.DecodeRuneInString(text[i:])
+				space = b.Len() > 0 && !unicode.IsPunct(next)
+				continue
+			}
+			i += size
+			switch {
+			case r == '\n':
+				newlines++
+				space = false
+				continue
+			case unicode.IsSpace(r):
+				space = true
+				continue
+			case unicode.In(r, unicode.Cc, unicode.Cf, unicode.Co, unicode.Variation_Selector), r == utf8.RuneError:
+				continue // invisible, private-use and undecodable characters
+			}
+			b.WriteString(separator(b.Len(), space, newlines))
+			space, newlines = false, 0
+			b.WriteRune(r)
+		}
+		if language != "" {
+			return verbalize.Verbalize(b.String(), language), nil
+		}
+		return b.String(), nil
+	}, nil
+}
+
+// separator returns the whitespace the normalize stage writes before a word, given
+// the length of the text written so far and the whitespace seen since.
+func separator(written int, space bool, newlines int) string {
+	switch {
+	case written == 0:
+		return ""
+	case newlines > 1:
+		return "\n\n"
+	case newlines == 1:
+		return "\n"
+	case space:
+		return " "
+	}
+	return ""
+}
+
+func buildLexicon(params map[string]any) (TextFunc, error) {
+	entries, _ := params["entries"].(map[string]any)
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("param %q must have at least one entry", "entries")
+	}
+	words := make([]string, 0, len(entries))
+	for word := range entries {
+		if word == "" {
+			return nil, fmt.Errorf("param %q has an empty word", "entries")
+		}
+		words = append(words, word)
+	}
+	// Longest first, so "SQL Server" wins over "SQL"
+	sort.Slice(words, func(i, j int) bool {
+		if len(words[i]) != len(words[j]) {
+			return len(words[i]) > len(words[j])
+		}
+		return words[i] < words[j]
+	})
+
+	return func(ctx context.Context, text string) (string, error) {
+		var b strings.Builder
+		b.Grow(len(text))
+		for i := 0; i < len(text); {
+			if atWordBoundary(text, i) {
+				if word, ok := matchWord(text[i:], words); ok {
+					b.WriteString(entries[word].(string))
+					i += len(word)
+					continue
+				}
+			}
+			_, size := utf8.DecodeRuneInString(text[i:])
+			b.WriteString(text[i : i+size])
+			i += size
+		}
+		return b.String(), nil
+	}, nil
+}
+
+// matchWord returns the first of words that s starts with as a whole word.
+func matchWord(s string, words []string) (string, bool) {
+	for _, word := range words {
+		if strings.HasPrefix(s, word) && atWordBoundary(s, len(word)) {
+			return word, true
+		}
+	}
+	return "", false
+}
+
+// atWordBoundary reports whether a word can start or end at byte offset i of s.
+func atWordBoundary(s string, i int) bool {
+	if i == 0 || i == len(s) {
+		return true
+	}
+	before, _ := utf8.DecodeLastRuneInString(s[:i])
+	after, _ := utf8.DecodeRuneInString(s[i:])
+	return !isWordRune(before) || !isWordRune(after)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+func buildTrimSilence(params map[string]any) (AudioFunc, error) {
+	threshold := strconv.FormatFloat(number(params, "threshold_db", -50), 'f', -1, 64) + "dB"
+	trim := "silenceremove=start_periods=1:start_threshold=" + threshold
+	// Trailing silence is trimmed as the leading silence of the reversed stream
+	chain := []string{trim, "areverse", trim, "areverse"}
+	return func(ctx context.Context, audio []byte, format string) ([]byte, error) {
+		return effects.Filter(ctx, audio, format, chain)
+	}, nil
+}
+
+func buildLoudnessNormalize(params map[string]any) (AudioFunc, error) {
+	filter := "loudnorm=I=" + strconv.FormatFloat(number(params, "target_lufs", -16), 'f', -1, 64) +
+		":TP=" + strconv.FormatFloat(number(params, "true_peak", -1.5), 'f', -1, 64)
+	return func(ctx context.Context, audio []byte, format string) ([]byte, error) {
+		return effects.Filter(ctx, audio, format, []string{filter})
+	}, nil
+}
+
+func buildTag(params map[string]any) (AudioFunc, error) {
+	var tags Tags
+	tags.Title, _ = params["title"].(string)
+	tags.Artist, _ = params["artist"].(string)
+	tags.Album, _ = params["album"].(string)
+	tags.Comment, _ = params["comment"].(string)
+	if tags == (Tags{}) {
+		return nil, errors.New("set at least one of title, artist, album or comment")
+	}
+	return func(ctx context.Context, audio []byte, format string) ([]byte, error) {
+		return tags.Write(audio, format)
+	}, nil
+}
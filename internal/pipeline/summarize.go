@@ -0,0 +1,77 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Summarize is the name of the stage that rewrites text with a language model.
+const Summarize = "summarize"
+
+// Completer sends instructions and an input to a language model and returns its
+// reply.
+type Completer interface {
+	Complete(ctx context.Context, system, input string) (string, error)
+}
+
+// Summary styles.
+const (
+	// StylePlain rewrites the text in plain language, keeping its content.
+	StylePlain = "plain"
+	// StyleBrief shortens the text to a spoken briefing of its key points.
+	StyleBrief = "brief"
+)
+
+var summaryInstructions = map[string]string{
+	StylePlain: "Rewrite the text the user sends in plain language for listeners who find complex text hard to follow: " +
+		"short sentences, common words, no jargon or abbreviations, and every key fact kept.",
+	StyleBrief: "Summarize the text the user sends as a short spoken briefing of its key points, most important first.",
+}
+
+// RegisterSummarize makes the summarize stage available, sending text to llm.
+// It is left unregistered when no language model is configured.
+func RegisterSummarize(llm Completer) {
+	Register(Processor{
+		Name:        Summarize,
+		Kind:        KindText,
+		Description: "Rewrites the text with a language model before it is spoken: in plain language, or as a short briefing",
+		Params: append([]Param{
+			{Name: "style", Type: TypeString, Description: `"plain" keeps the content in simpler words (default), "brief" shortens it to the key points`},
+			{Name: "max_words", Type: TypeNumber, Description: "Longest reply asked for, in words", Min: bound(10), Max: bound(5000)},
+			{Name: "min_chars", Type: TypeNumber, Description: "Texts shorter than this are spoken unchanged (default 0)", Min: bound(0)},
+		}, hookParams...),
+		ReplacesText: true,
+		BuildText: func(params map[string]any) (TextFunc, error) {
+			return buildSummarize(llm, params)
+		},
+	})
+}
+
+func buildSummarize(llm Completer, params map[string]any) (TextFunc, error) {
+	style, _ := params["style"].(string)
+	if style == "" {
+		style = StylePlain
+	}
+	system, ok := summaryInstructions[style]
+	if !ok {
+		return nil, fmt.Errorf("param %q must be %q or %q", "style", StylePlain, StyleBrief)
+	}
+	if maxWords := number(params, "max_words", 0); maxWords > 0 {
+		system += " Use at most " + strconv.Itoa(int(maxWords)) + " words."
+	}
+	system += " The reply is read aloud as it is, so reply with the rewritten text only, without headings, lists or markup."
+	minChars := int(number(params, "min_chars", 0))
+	rewrite, err := hookStage(Summarize, system, CompleterHook(llm, system), params, HookOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context, text string) (string, error) {
+		if utf8.RuneCountInString(text) < minChars {
+			return text, nil
+		}
+		return rewrite(ctx, text)
+	}, nil
+}
@@ -0,0 +1,100 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/pako-tts/server/internal/audio/effects"
+	"github.com/pako-tts/server/internal/audio/transcode"
+)
+
+// Tags is the metadata the tag stage writes. Empty fields are left out.
+type Tags struct {
+	Title   string
+	Artist  string
+	Album   string
+	Comment string
+}
+
+// Write returns the audio with the tags embedded: an ID3v2.4 tag in front of MP3,
+// or a LIST/INFO chunk ahead of the data chunk of WAV.
+func (t Tags) Write(audio []byte, format string) ([]byte, error) {
+	switch format {
+	case "mp3":
+		return append(t.id3(), audio...), nil
+	case "wav":
+		pcm, sampleRate, channels, bits, ok := transcode.ParseWAV(audio)
+		if !ok {
+			return nil, effects.ErrUnsupportedInput
+		}
+		wav := transcode.PCMToWAV(pcm, sampleRate, channels, bits)
+		info := t.riffInfo()
+		// The canonical header ends with the 8-byte data chunk header at offset 36
+		out := make([]byte, 0, len(wav)+len(info))
+		out = append(out, wav[:36]...)
+		out = append(out, info...)
+		out = append(out, wav[36:]...)
+		binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+		return out, nil
+	default:
+		return nil, effects.ErrUnsupportedInput
+	}
+}
+
+// id3 encodes the tags as an ID3v2.4 tag with UTF-8 text frames.
+func (t Tags) id3() []byte {
+	var frames bytes.Buffer
+	frame := func(id string, body []byte) {
+		frames.WriteString(id)
+		frames.Write(synchsafe(len(body)))
+		frames.Write([]byte{0, 0}) // flags
+		frames.Write(body)
+	}
+	text := func(id, value string) {
+		if value != "" {
+			frame(id, append([]byte{3}, value...)) // 3 = UTF-8
+		}
+	}
+	text("TIT2", t.Title)
+	text("TPE1", t.Artist)
+	text("TALB", t.Album)
+	if t.Comment != "" {
+		// Encoding, language, empty description, text
+		frame("COMM", append([]byte{3, 'e', 'n', 'g', 0}, t.Comment...))
+	}
+
+	header := []byte{'I', 'D', '3', 4, 0, 0}
+	header = append(header, synchsafe(frames.Len())...)
+	return append(header, frames.Bytes()...)
+}
+
+// synchsafe encodes n in 4 bytes of 7 bits each, as ID3v2.4 sizes are.
+func synchsafe(n int) []byte {
+	return []byte{byte(n >> 21 & 0x7f), byte(n >> 14 & 0x7f), byte(n >> 7 & 0x7f), byte(n & 0x7f)}
+}
+
+// riffInfo encodes the tags as a LIST chunk of type INFO.
+func (t Tags) riffInfo() []byte {
+	var body bytes.Buffer
+	body.WriteString("INFO")
+	field := func(id, value string) {
+		if value == "" {
+			return
+		}
+		data := append([]byte(value), 0) // NUL-terminated
+		body.WriteString(id)
+		binary.Write(&body, binary.LittleEndian, uint32(len(data))) //nolint:errcheck // bytes.Buffer
+		body.Write(data)
+		if len(data)%2 == 1 {
+			body.WriteByte(0) // chunks are word-aligned
+		}
+	}
+	field("INAM", t.Title)
+	field("IART", t.Artist)
+	field("IPRD", t.Album)
+	field("ICMT", t.Comment)
+
+	chunk := []byte("LIST")
+	chunk = binary.LittleEndian.AppendUint32(chunk, uint32(body.Len()))
+	return append(chunk, body.Bytes()...)
+}
@@ -8,7 +8,13 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/pako-tts/server/internal/deadline"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/provider/keyring"
 )
 
 const (
@@ -17,22 +23,33 @@ const (
 
 // Client is an HTTP client for the ElevenLabs API.
 type Client struct {
-	apiKey     string
+	keys       *keyring.Keyring
 	baseURL    string
 	httpClient *http.Client
 }
 
 // NewClient creates a new ElevenLabs API client.
 func NewClient(apiKey string) *Client {
+	return NewClientWithBaseURL(apiKey, baseURL)
+}
+
+// NewClientWithBaseURL creates a new ElevenLabs API client against a custom base URL
+// (EU/regional endpoints, proxies, or mock servers in tests).
+func NewClientWithBaseURL(apiKey, base string) *Client {
 	return &Client{
-		apiKey:  apiKey,
-		baseURL: baseURL,
+		keys:    keyring.New(apiKey, ""),
+		baseURL: strings.TrimRight(base, "/"),
 		httpClient: &http.Client{
 			Timeout: 120 * time.Second,
 		},
 	}
 }
 
+// key returns the current API key.
+func (c *Client) key() string {
+	return c.keys.Current()
+}
+
 // TTSRequest represents a text-to-speech request to ElevenLabs.
 type TTSRequest struct {
 	Text string `json:"text"`
@@ -43,14 +60,29 @@ type TTSRequest struct {
 	LanguageCode  string            `json:"language_code,omitempty"`
 	OutputFormat  string            `json:"output_format,omitempty"`
 	VoiceSettings *VoiceSettingsReq `json:"voice_settings,omitempty"`
+	// PreviousRequestIDs lists request IDs of the immediately preceding chunks (max 3)
+	// so ElevenLabs keeps prosody consistent across stitched generations.
+	PreviousRequestIDs []string `json:"previous_request_ids,omitempty"`
+}
+
+// TTSResponse is the audio stream returned by a text-to-speech call.
+type TTSResponse struct {
+	Audio       io.ReadCloser
+	ContentType string
+	// RequestID is the ElevenLabs request-id header; pass it as a previous request ID
+	// when synthesizing the following chunk.
+	RequestID string
+	// ContentLength is the announced size of Audio, -1 when unknown.
+	ContentLength int64
 }
 
 // VoiceSettingsReq represents voice settings for ElevenLabs API.
 type VoiceSettingsReq struct {
-	Stability       float64 `json:"stability"`
-	SimilarityBoost float64 `json:"similarity_boost"`
-	Style           float64 `json:"style,omitempty"`
-	UseSpeakerBoost bool    `json:"use_speaker_boost,omitempty"`
+	Stability       float64  `json:"stability"`
+	SimilarityBoost float64  `json:"similarity_boost"`
+	Style           float64  `json:"style,omitempty"`
+	UseSpeakerBoost bool     `json:"use_speaker_boost,omitempty"`
+	Speed           *float64 `json:"speed,omitempty"`
 }
 
 // VoiceResponse represents a voice from the ElevenLabs API.
@@ -83,32 +115,32 @@ type ModelResponse struct {
 }
 
 // TextToSpeech converts text to speech using ElevenLabs API.
-func (c *Client) TextToSpeech(ctx context.Context, voiceID string, req *TTSRequest) (io.ReadCloser, string, error) {
+func (c *Client) TextToSpeech(ctx context.Context, voiceID string, req *TTSRequest) (*TTSResponse, error) {
 	url := fmt.Sprintf("%s/text-to-speech/%s", c.baseURL, voiceID)
 
 	body, err := json.Marshal(req)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("xi-api-key", c.apiKey)
+	httpReq.Header.Set("xi-api-key", c.key())
 	httpReq.Header.Set("Accept", "audio/mpeg")
+	deadline.Set(httpReq)
 
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		defer resp.Body.Close() //nolint:errcheck
-		errBody, _ := io.ReadAll(resp.Body)
-		return nil, "", fmt.Errorf("ElevenLabs API error (status %d): %s", resp.StatusCode, string(errBody))
+		return nil, newAPIError(resp)
 	}
 
 	contentType := resp.Header.Get("Content-Type")
@@ -116,7 +148,13 @@ func (c *Client) TextToSpeech(ctx context.Context, voiceID string, req *TTSReque
 		contentType = "audio/mpeg"
 	}
 
-	return resp.Body, contentType, nil
+	return &TTSResponse{
+		Audio:       resp.Body,
+		ContentType: contentType,
+		RequestID:   resp.Header.Get("request-id"),
+
+		ContentLength: resp.ContentLength,
+	}, nil
 }
 
 // GetVoices retrieves available voices from ElevenLabs API.
@@ -128,7 +166,7 @@ func (c *Client) GetVoices(ctx context.Context) (*VoicesResponse, error) {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	httpReq.Header.Set("xi-api-key", c.apiKey)
+	httpReq.Header.Set("xi-api-key", c.key())
 
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
@@ -137,8 +175,7 @@ func (c *Client) GetVoices(ctx context.Context) (*VoicesResponse, error) {
 	defer resp.Body.Close() //nolint:errcheck
 
 	if resp.StatusCode != http.StatusOK {
-		errBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("ElevenLabs API error (status %d): %s", resp.StatusCode, string(errBody))
+		return nil, newAPIError(resp)
 	}
 
 	var voices VoicesResponse
@@ -158,7 +195,7 @@ func (c *Client) GetModels(ctx context.Context) ([]ModelResponse, error) {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	httpReq.Header.Set("xi-api-key", c.apiKey)
+	httpReq.Header.Set("xi-api-key", c.key())
 
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
@@ -167,8 +204,7 @@ func (c *Client) GetModels(ctx context.Context) ([]ModelResponse, error) {
 	defer resp.Body.Close() //nolint:errcheck
 
 	if resp.StatusCode != http.StatusOK {
-		errBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("ElevenLabs API error (status %d): %s", resp.StatusCode, string(errBody))
+		return nil, newAPIError(resp)
 	}
 
 	var models []ModelResponse
@@ -179,6 +215,44 @@ func (c *Client) GetModels(ctx context.Context) ([]ModelResponse, error) {
 	return models, nil
 }
 
+// SubscriptionResponse is the subset of the ElevenLabs subscription payload used for quota tracking.
+type SubscriptionResponse struct {
+	CharacterCount int64 `json:"character_count"`
+	CharacterLimit int64 `json:"character_limit"`
+	// NextCharacterCountResetUnix is when the billing cycle ends and
+	// CharacterCount starts again from zero.
+	NextCharacterCountResetUnix int64 `json:"next_character_count_reset_unix"`
+}
+
+// GetSubscription retrieves the account's character usage and limit.
+func (c *Client) GetSubscription(ctx context.Context) (*SubscriptionResponse, error) {
+	url := fmt.Sprintf("%s/user/subscription", c.baseURL)
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("xi-api-key", c.key())
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp)
+	}
+
+	var sub SubscriptionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&sub); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &sub, nil
+}
+
 // CheckHealth checks if the ElevenLabs API is available.
 func (c *Client) CheckHealth(ctx context.Context) bool {
 	url := fmt.Sprintf("%s/user", c.baseURL)
@@ -188,7 +262,7 @@ func (c *Client) CheckHealth(ctx context.Context) bool {
 		return false
 	}
 
-	httpReq.Header.Set("xi-api-key", c.apiKey)
+	httpReq.Header.Set("xi-api-key", c.key())
 
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
@@ -198,3 +272,46 @@ func (c *Client) CheckHealth(ctx context.Context) bool {
 
 	return resp.StatusCode == http.StatusOK
 }
+
+// newAPIError builds a domain.ProviderError from a non-200 ElevenLabs response,
+// including any rate-limit hints (Retry-After, maximum-concurrent-requests) the API sent.
+func newAPIError(resp *http.Response) *domain.ProviderError {
+	errBody, _ := io.ReadAll(resp.Body)
+	return &domain.ProviderError{
+		Provider:         providerName,
+		StatusCode:       resp.StatusCode,
+		Message:          fmt.Sprintf("ElevenLabs API error (status %d): %s", resp.StatusCode, string(errBody)),
+		RetryAfter:       parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		ConcurrencyLimit: parsePositiveInt(resp.Header.Get("maximum-concurrent-requests")),
+	}
+}
+
+// parseRetryAfter parses a Retry-After header value, which is either a number of
+// seconds or an HTTP date. Returns zero for missing, malformed, or past values.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs <= 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := at.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// parsePositiveInt parses a header value as a positive integer, returning zero otherwise.
+func parsePositiveInt(value string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n <= 0 {
+		return 0
+	}
+	return n
+}
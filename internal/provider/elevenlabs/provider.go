@@ -5,9 +5,14 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"sync"
 	"sync/atomic"
+	"time"
 
+	"github.com/pako-tts/server/internal/audio/bufpool"
+	"github.com/pako-tts/server/internal/audio/transcode"
 	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/provider/keyring"
 	"github.com/pako-tts/server/pkg/config"
 )
 
@@ -16,6 +21,28 @@ const (
 	providerType     = "ElevenLabsProvider"
 	maxConcurrent    = 4
 	fallbackModelID  = "eleven_multilingual_v2"
+
+	// Native voice_settings.speed range accepted by ElevenLabs.
+	minNativeSpeed = 0.7
+	maxNativeSpeed = 1.2
+
+	// maxStitchedRequests is the ElevenLabs limit on previous_request_ids entries.
+	maxStitchedRequests = 3
+
+	// pcmFormat is the output format requested for "wav": 16-bit mono PCM at
+	// pcmSampleRate, which ElevenLabs sends without a WAV header.
+	pcmFormat     = "pcm_22050"
+	pcmSampleRate = 22050
+
+	// quotaCacheTTL bounds how often the subscription endpoint is polled for quota.
+	quotaCacheTTL = time.Minute
+
+	// quotaFetchTimeout bounds a subscription fetch made in the background.
+	quotaFetchTimeout = 10 * time.Second
+
+	// throttleCooldown is how long an upstream-signaled concurrency limit stays in
+	// effect when the 429 response carried no Retry-After hint.
+	throttleCooldown = 60 * time.Second
 )
 
 // Provider implements the TTSProvider interface for ElevenLabs.
@@ -24,6 +51,19 @@ type Provider struct {
 	activeJobs     int32
 	isDefault      bool
 	defaultModelID string
+
+	// throttleMu guards the temporary concurrency limit signaled by a 429 response.
+	throttleMu    sync.Mutex
+	throttleLimit int
+	throttleUntil time.Time
+
+	// quotaMu guards the cached remaining-character quota. It isn't held while
+	// the subscription is fetched.
+	quotaMu        sync.Mutex
+	quotaRemaining int64
+	quotaKnown     bool
+	quotaResetAt   time.Time
+	quotaCheckedAt time.Time
 }
 
 // NewProvider creates a new ElevenLabs provider.
@@ -46,8 +86,14 @@ func NewProviderFromConfig(cfg config.ProviderConfig, isDefault bool) (*Provider
 		modelID = fallbackModelID
 	}
 
+	client := NewClient(cfg.APIKey)
+	if cfg.BaseURL != "" {
+		client = NewClientWithBaseURL(cfg.APIKey, cfg.BaseURL)
+	}
+	client.keys = keyring.New(cfg.APIKey, cfg.SecondaryAPIKey)
+
 	return &Provider{
-		client:         NewClient(cfg.APIKey),
+		client:         client,
 		isDefault:      isDefault,
 		defaultModelID: modelID,
 	}, nil
@@ -68,6 +114,47 @@ func (p *Provider) Synthesize(ctx context.Context, req *domain.SynthesisRequest)
 	atomic.AddInt32(&p.activeJobs, 1)
 	defer atomic.AddInt32(&p.activeJobs, -1)
 
+	ttsReq := p.ttsRequest(req)
+
+	// Call ElevenLabs API, retrying once on the secondary key if the primary is rejected
+	key := p.client.keys.Current()
+	resp, err := p.client.TextToSpeech(ctx, req.VoiceID, ttsReq)
+	if perr, ok := domain.AsProviderError(err); ok && perr.IsKeyRejected() && p.client.keys.Failover(key, err) {
+		resp, err = p.client.TextToSpeech(ctx, req.VoiceID, ttsReq)
+	}
+	if err != nil {
+		if perr, ok := domain.AsProviderError(err); ok && perr.IsRateLimited() {
+			p.throttle(perr)
+		}
+		return nil, err
+	}
+
+	// Read all audio data
+	audioData, err := bufpool.ReadAll(req.TrackDownload(resp.Audio, resp.ContentLength))
+	resp.Audio.Close() //nolint:errcheck
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := resp.ContentType
+	if ttsReq.OutputFormat == pcmFormat {
+		wav := bufpool.Get()
+		wav.Write(transcode.WAVHeader(audioData.Len(), pcmSampleRate, 1, 16))
+		wav.Write(audioData.Bytes())
+		bufpool.Put(audioData)
+		audioData, contentType = wav, "audio/wav"
+	}
+
+	return &domain.SynthesisResult{
+		Audio:       bufpool.NewReader(audioData),
+		ContentType: contentType,
+		SizeBytes:   int64(audioData.Len()),
+		RequestID:   resp.RequestID,
+	}, nil
+}
+
+// ttsRequest builds the ElevenLabs request for a synthesis request.
+func (p *Provider) ttsRequest(req *domain.SynthesisRequest) *TTSRequest {
 	// Build ElevenLabs request
 	ttsReq := &TTSRequest{
 		Text: req.Text,
@@ -84,10 +171,15 @@ func (p *Provider) Synthesize(ctx context.Context, req *domain.SynthesisRequest)
 	// (omitempty on TTSRequest.LanguageCode keeps it off the wire).
 	ttsReq.LanguageCode = req.LanguageCode
 
+	// Request stitching: ElevenLabs accepts at most 3 previous request IDs; keep the most recent.
+	if n := len(req.PreviousRequestIDs); n > 0 {
+		ttsReq.PreviousRequestIDs = req.PreviousRequestIDs[max(0, n-maxStitchedRequests):]
+	}
+
 	// Set output format
 	switch req.OutputFormat {
 	case "wav":
-		ttsReq.OutputFormat = "pcm_22050"
+		ttsReq.OutputFormat = pcmFormat
 	default:
 		ttsReq.OutputFormat = "mp3_22050_32"
 	}
@@ -99,29 +191,65 @@ func (p *Provider) Synthesize(ctx context.Context, req *domain.SynthesisRequest)
 			SimilarityBoost: getFloatValue(req.Settings.SimilarityBoost, 0.75),
 			Style:           getFloatValue(req.Settings.Style, 0.0),
 			UseSpeakerBoost: getBoolValue(req.Settings.UseSpeakerBoost, true),
+			Speed:           req.Settings.Speed,
 		}
 	}
 
-	// Call ElevenLabs API
-	audioReader, contentType, err := p.client.TextToSpeech(ctx, req.VoiceID, ttsReq)
+	return ttsReq
+}
+
+// SynthesizeStream converts text to speech over the streaming input websocket, so
+// audio can be forwarded before the whole text is synthesized. Request stitching
+// isn't available when streaming.
+func (p *Provider) SynthesizeStream(ctx context.Context, req *domain.SynthesisRequest) (*domain.SynthesisStream, error) {
+	atomic.AddInt32(&p.activeJobs, 1)
+
+	ttsReq := p.ttsRequest(req)
+	key := p.client.keys.Current()
+	resp, err := p.client.StreamTextToSpeech(ctx, req.VoiceID, ttsReq)
+	if perr, ok := domain.AsProviderError(err); ok && perr.IsKeyRejected() && p.client.keys.Failover(key, err) {
+		resp, err = p.client.StreamTextToSpeech(ctx, req.VoiceID, ttsReq)
+	}
 	if err != nil {
+		atomic.AddInt32(&p.activeJobs, -1)
+		if perr, ok := domain.AsProviderError(err); ok && perr.IsRateLimited() {
+			p.throttle(perr)
+		}
 		return nil, err
 	}
 
-	// Read all audio data
-	audioData, err := io.ReadAll(audioReader)
-	audioReader.Close() //nolint:errcheck
-	if err != nil {
-		return nil, err
+	audio, contentType := resp.Audio, resp.ContentType
+	if ttsReq.OutputFormat == pcmFormat {
+		// The length isn't known until the stream ends, so the header says "until the end"
+		header := transcode.WAVHeader(-1, pcmSampleRate, 1, 16)
+		audio = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(header), resp.Audio), resp.Audio}
+		contentType = "audio/wav"
 	}
 
-	return &domain.SynthesisResult{
-		Audio:       bytes.NewReader(audioData),
+	return &domain.SynthesisStream{
+		Audio:       &activeStream{ReadCloser: audio, provider: p},
 		ContentType: contentType,
-		SizeBytes:   int64(len(audioData)),
 	}, nil
 }
 
+// activeStream counts as an active job of its provider until it is closed.
+type activeStream struct {
+	io.ReadCloser
+	provider *Provider
+	closed   atomic.Bool
+}
+
+// Close implements io.Closer.
+func (s *activeStream) Close() error {
+	if !s.closed.Swap(true) {
+		atomic.AddInt32(&s.provider.activeJobs, -1)
+	}
+	return s.ReadCloser.Close()
+}
+
 // ListVoices returns available voices.
 func (p *Provider) ListVoices(ctx context.Context) ([]domain.Voice, error) {
 	resp, err := p.client.GetVoices(ctx)
@@ -186,22 +314,111 @@ func (p *Provider) IsAvailable(ctx context.Context) bool {
 	return p.client.CheckHealth(ctx)
 }
 
-// MaxConcurrent returns the maximum concurrent jobs.
+// MaxConcurrent returns the maximum concurrent jobs. While an upstream-signaled
+// concurrency limit is in effect (after a 429), the lower of the two is returned.
 func (p *Provider) MaxConcurrent() int {
+	p.throttleMu.Lock()
+	defer p.throttleMu.Unlock()
+
+	if p.throttleLimit > 0 && time.Now().Before(p.throttleUntil) && p.throttleLimit < maxConcurrent {
+		return p.throttleLimit
+	}
 	return maxConcurrent
 }
 
+// throttle records the concurrency limit signaled by a 429 response. The limit stays in
+// effect until Retry-After elapses (or throttleCooldown when no hint was sent).
+func (p *Provider) throttle(perr *domain.ProviderError) {
+	if perr.ConcurrencyLimit <= 0 {
+		return
+	}
+	cooldown := perr.RetryAfter
+	if cooldown <= 0 {
+		cooldown = throttleCooldown
+	}
+
+	p.throttleMu.Lock()
+	defer p.throttleMu.Unlock()
+	p.throttleLimit = perr.ConcurrencyLimit
+	p.throttleUntil = time.Now().Add(cooldown)
+}
+
 // ActiveJobs returns the current number of active jobs.
 func (p *Provider) ActiveJobs() int {
 	return int(atomic.LoadInt32(&p.activeJobs))
 }
 
+// RemainingChars returns the account's remaining character quota, cached for quotaCacheTTL.
+// The second result is false when the quota could not be determined.
+func (p *Provider) RemainingChars(ctx context.Context) (int64, bool) {
+	if p.claimQuotaRefresh() {
+		p.refreshQuota(ctx)
+	}
+
+	p.quotaMu.Lock()
+	defer p.quotaMu.Unlock()
+	return p.quotaRemaining, p.quotaKnown
+}
+
+// QuotaResetAt returns when the account's character count is next reset, from the
+// cached subscription, or zero when it isn't known. A stale subscription is fetched
+// again in the background, so the call never waits for the upstream API.
+func (p *Provider) QuotaResetAt() time.Time {
+	if p.claimQuotaRefresh() {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), quotaFetchTimeout)
+			defer cancel()
+			p.refreshQuota(ctx)
+		}()
+	}
+
+	p.quotaMu.Lock()
+	defer p.quotaMu.Unlock()
+	return p.quotaResetAt
+}
+
+// claimQuotaRefresh reports whether the cached subscription is due for a refresh.
+// It marks it checked, so concurrent callers keep using the cached values while
+// the caller that got true fetches it.
+func (p *Provider) claimQuotaRefresh() bool {
+	p.quotaMu.Lock()
+	defer p.quotaMu.Unlock()
+
+	if time.Since(p.quotaCheckedAt) < quotaCacheTTL {
+		return false
+	}
+	p.quotaCheckedAt = time.Now()
+	return true
+}
+
+// refreshQuota fetches the subscription and caches its quota.
+func (p *Provider) refreshQuota(ctx context.Context) {
+	sub, err := p.client.GetSubscription(ctx)
+
+	p.quotaMu.Lock()
+	defer p.quotaMu.Unlock()
+	if err != nil || sub.CharacterLimit <= 0 {
+		p.quotaKnown = false
+		return
+	}
+	p.quotaRemaining = max(0, sub.CharacterLimit-sub.CharacterCount)
+	p.quotaKnown = true
+	if sub.NextCharacterCountResetUnix > 0 {
+		p.quotaResetAt = time.Unix(sub.NextCharacterCountResetUnix, 0)
+	}
+}
+
+// Keyring returns the provider's upstream API keys.
+func (p *Provider) Keyring() *keyring.Keyring {
+	return p.client.keys
+}
+
 // Info returns provider info for API responses.
 func (p *Provider) Info(ctx context.Context) domain.ProviderInfo {
 	return domain.ProviderInfo{
 		Name:          providerName,
 		Type:          providerType,
-		MaxConcurrent: maxConcurrent,
+		MaxConcurrent: p.MaxConcurrent(),
 		IsDefault:     p.isDefault,
 		IsAvailable:   p.IsAvailable(ctx),
 	}
@@ -213,7 +430,22 @@ func (p *Provider) Status(ctx context.Context) domain.ProviderStatus {
 		Name:          providerName,
 		Available:     p.IsAvailable(ctx),
 		ActiveJobs:    p.ActiveJobs(),
-		MaxConcurrent: maxConcurrent,
+		MaxConcurrent: p.MaxConcurrent(),
+	}
+}
+
+// SupportsSpeed reports whether ElevenLabs can render the speed factor natively.
+// Factors outside its range are applied server-side after synthesis.
+func (p *Provider) SupportsSpeed(speed float64) bool {
+	return speed >= minNativeSpeed && speed <= maxNativeSpeed
+}
+
+// VoiceSettingRanges reports the voice_settings ranges the ElevenLabs API accepts.
+func (p *Provider) VoiceSettingRanges() map[string]domain.SettingRange {
+	return map[string]domain.SettingRange{
+		"stability":        {Min: 0, Max: 1},
+		"similarity_boost": {Min: 0, Max: 1},
+		"style":            {Min: 0, Max: 1},
 	}
 }
 
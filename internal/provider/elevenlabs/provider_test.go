@@ -1,6 +1,7 @@
 package elevenlabs
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"io"
@@ -10,7 +11,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pako-tts/server/internal/audio/transcode"
 	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/provider/keyring"
 	"github.com/pako-tts/server/pkg/config"
 )
 
@@ -114,7 +117,7 @@ func newTestClient(t *testing.T, handler http.HandlerFunc) (*Client, *httptest.S
 	t.Helper()
 	srv := httptest.NewServer(handler)
 	c := &Client{
-		apiKey:  "test-key",
+		keys:    keyring.New("test-key", ""),
 		baseURL: srv.URL,
 		httpClient: &http.Client{
 			Timeout: 5 * time.Second,
@@ -346,3 +349,309 @@ func TestProvider_Synthesize_OmitsLanguageCodeWhenEmpty(t *testing.T) {
 		t.Errorf("expected raw body to NOT contain language_code key, got %s", string(capturedRaw))
 	}
 }
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+	}{
+		{"empty", "", 0},
+		{"seconds", "7", 7 * time.Second},
+		{"zero seconds", "0", 0},
+		{"http date", now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second},
+		{"past http date", now.Add(-30 * time.Second).Format(http.TimeFormat), 0},
+		{"garbage", "soon", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.value, now); got != tt.expected {
+				t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestProvider_Synthesize_RateLimitedReturnsProviderError(t *testing.T) {
+	client, srv := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3")
+		w.Header().Set("maximum-concurrent-requests", "2")
+		http.Error(w, `{"detail":{"status":"too_many_concurrent_requests"}}`, http.StatusTooManyRequests)
+	})
+	defer srv.Close()
+
+	p := &Provider{client: client, defaultModelID: "eleven_multilingual_v2"}
+	_, err := p.Synthesize(context.Background(), &domain.SynthesisRequest{Text: "hello", VoiceID: "voice-1"})
+	if err == nil {
+		t.Fatal("expected error for 429 response")
+	}
+
+	perr, ok := domain.AsProviderError(err)
+	if !ok {
+		t.Fatalf("expected *domain.ProviderError, got %T", err)
+	}
+	if !perr.IsRateLimited() {
+		t.Errorf("expected rate-limited error, got status %d", perr.StatusCode)
+	}
+	if perr.RetryAfter != 3*time.Second {
+		t.Errorf("expected RetryAfter 3s, got %v", perr.RetryAfter)
+	}
+	if perr.ConcurrencyLimit != 2 {
+		t.Errorf("expected ConcurrencyLimit 2, got %d", perr.ConcurrencyLimit)
+	}
+	if !strings.Contains(err.Error(), "ElevenLabs API error") {
+		t.Errorf("expected wrapped error message, got %v", err)
+	}
+
+	// The signaled limit temporarily lowers the effective concurrency.
+	if got := p.MaxConcurrent(); got != 2 {
+		t.Errorf("expected throttled MaxConcurrent 2, got %d", got)
+	}
+}
+
+func TestProvider_MaxConcurrent_ThrottleExpires(t *testing.T) {
+	p := NewProvider("test-api-key", true)
+	p.throttle(&domain.ProviderError{StatusCode: http.StatusTooManyRequests, ConcurrencyLimit: 1, RetryAfter: time.Millisecond})
+
+	time.Sleep(5 * time.Millisecond)
+
+	if got := p.MaxConcurrent(); got != maxConcurrent {
+		t.Errorf("expected MaxConcurrent to recover to %d, got %d", maxConcurrent, got)
+	}
+}
+
+func TestNewProviderFromConfig_CustomBaseURL(t *testing.T) {
+	var gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Header().Set("Content-Type", "audio/mpeg")
+		_, _ = w.Write([]byte("fake-audio"))
+	}))
+	defer srv.Close()
+
+	p, err := NewProviderFromConfig(config.ProviderConfig{
+		Name:    "elevenlabs",
+		Type:    "elevenlabs",
+		APIKey:  "test-key",
+		BaseURL: srv.URL + "/v1/",
+	}, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := p.Synthesize(context.Background(), &domain.SynthesisRequest{Text: "hello", VoiceID: "voice-1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotPath != "/v1/text-to-speech/voice-1" {
+		t.Errorf("expected request against custom base URL, got path %q", gotPath)
+	}
+}
+
+func TestProvider_Synthesize_StitchesPreviousRequestIDs(t *testing.T) {
+	var captured TTSRequest
+	client, srv := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &captured)
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Header().Set("request-id", "req-5")
+		_, _ = w.Write([]byte("fake-audio"))
+	})
+	defer srv.Close()
+
+	p := &Provider{client: client, defaultModelID: "eleven_multilingual_v2"}
+	result, err := p.Synthesize(context.Background(), &domain.SynthesisRequest{
+		Text:               "hello",
+		VoiceID:            "voice-1",
+		PreviousRequestIDs: []string{"req-1", "req-2", "req-3", "req-4"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"req-2", "req-3", "req-4"}
+	if strings.Join(captured.PreviousRequestIDs, ",") != strings.Join(want, ",") {
+		t.Errorf("expected previous_request_ids %v, got %v", want, captured.PreviousRequestIDs)
+	}
+	if result.RequestID != "req-5" {
+		t.Errorf("expected RequestID 'req-5', got %q", result.RequestID)
+	}
+}
+
+func TestProvider_Synthesize_ForwardsSpeed(t *testing.T) {
+	var captured TTSRequest
+	client, srv := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &captured)
+		w.Header().Set("Content-Type", "audio/mpeg")
+		_, _ = w.Write([]byte("fake-audio"))
+	})
+	defer srv.Close()
+
+	speed := 1.1
+	p := &Provider{client: client, defaultModelID: "eleven_multilingual_v2"}
+	_, err := p.Synthesize(context.Background(), &domain.SynthesisRequest{
+		Text:     "hello",
+		VoiceID:  "voice-1",
+		Settings: &domain.VoiceSettings{Speed: &speed},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if captured.VoiceSettings == nil || captured.VoiceSettings.Speed == nil || *captured.VoiceSettings.Speed != 1.1 {
+		t.Errorf("expected voice_settings.speed 1.1, got %+v", captured.VoiceSettings)
+	}
+}
+
+func TestProvider_SupportsSpeed(t *testing.T) {
+	p := NewProvider("test-key", true)
+	for speed, want := range map[float64]bool{0.5: false, 0.7: true, 1.2: true, 1.5: false} {
+		if got := p.SupportsSpeed(speed); got != want {
+			t.Errorf("SupportsSpeed(%v) = %v, want %v", speed, got, want)
+		}
+	}
+}
+
+func TestProvider_KeyringHotSwap(t *testing.T) {
+	var gotKey string
+	client, srv := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		gotKey = r.Header.Get("xi-api-key")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[]`))
+	})
+	defer srv.Close()
+
+	p := &Provider{client: client}
+	p.Keyring().SetPrimary("rotated-key")
+
+	if _, err := p.ListModels(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotKey != "rotated-key" {
+		t.Errorf("expected rotated key to be sent, got %q", gotKey)
+	}
+}
+
+func TestProvider_Synthesize_FailsOverToSecondaryKey(t *testing.T) {
+	var calls int
+	client, srv := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("xi-api-key") != "secondary-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"detail":{"status":"quota_exceeded"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "audio/mpeg")
+		_, _ = w.Write([]byte("audio"))
+	})
+	defer srv.Close()
+
+	client.keys = keyring.New("primary-key", "secondary-key")
+	var alerted error
+	client.keys.OnFailover(func(cause error) { alerted = cause })
+
+	p := &Provider{client: client, defaultModelID: fallbackModelID}
+	result, err := p.Synthesize(context.Background(), &domain.SynthesisRequest{Text: "hi", VoiceID: "v", OutputFormat: "mp3"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.SizeBytes != 5 {
+		t.Errorf("expected audio from retry, got %d bytes", result.SizeBytes)
+	}
+	if calls != 2 {
+		t.Errorf("expected one retry, got %d calls", calls)
+	}
+	if alerted == nil {
+		t.Error("expected failover callback")
+	}
+	if status := p.Keyring().Status(); status.ActiveKey != domain.KeySecondary {
+		t.Errorf("expected secondary key active, got %s", status.ActiveKey)
+	}
+}
+
+func TestProvider_Synthesize_WrapsPCMInWAVHeader(t *testing.T) {
+	pcm := []byte{1, 0, 2, 0, 3, 0}
+	client, srv := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req TTSRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.OutputFormat != pcmFormat {
+			t.Errorf("expected output_format %s, got %q", pcmFormat, req.OutputFormat)
+		}
+		w.Header().Set("Content-Type", "audio/pcm")
+		_, _ = w.Write(pcm)
+	})
+	defer srv.Close()
+
+	p := &Provider{client: client, defaultModelID: fallbackModelID}
+	result, err := p.Synthesize(context.Background(), &domain.SynthesisRequest{Text: "hi", VoiceID: "v", OutputFormat: "wav"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	audio, _ := io.ReadAll(result.Audio)
+	got, rate, channels, bits, ok := transcode.ParseWAV(audio)
+	if !ok || rate != pcmSampleRate || channels != 1 || bits != 16 || !bytes.Equal(got, pcm) {
+		t.Errorf("expected the PCM in a 22050 Hz mono 16-bit WAV, got %v (%d Hz, %d ch, %d bit)", got, rate, channels, bits)
+	}
+	if result.ContentType != "audio/wav" || result.SizeBytes != int64(44+len(pcm)) {
+		t.Errorf("expected audio/wav of %d bytes, got %s of %d", 44+len(pcm), result.ContentType, result.SizeBytes)
+	}
+}
+
+func TestProvider_RemainingChars_CachesSubscription(t *testing.T) {
+	resetAt := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	calls := 0
+	client, srv := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/user/subscription" {
+			http.NotFound(w, r)
+			return
+		}
+		calls++
+		_ = json.NewEncoder(w).Encode(SubscriptionResponse{
+			CharacterCount:              400,
+			CharacterLimit:              1000,
+			NextCharacterCountResetUnix: resetAt.Unix(),
+		})
+	})
+	defer srv.Close()
+	p := &Provider{client: client}
+
+	if remaining, known := p.RemainingChars(context.Background()); !known || remaining != 600 {
+		t.Fatalf("RemainingChars = %d, %v; want 600, true", remaining, known)
+	}
+	if got := p.QuotaResetAt(); !got.Equal(resetAt) {
+		t.Errorf("QuotaResetAt = %v, want %v", got, resetAt)
+	}
+	p.RemainingChars(context.Background())
+	if calls != 1 {
+		t.Errorf("expected the subscription to be fetched once, got %d calls", calls)
+	}
+}
+
+func TestProvider_QuotaResetAt_FetchesInBackground(t *testing.T) {
+	release := make(chan struct{})
+	client, srv := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		_ = json.NewEncoder(w).Encode(SubscriptionResponse{CharacterLimit: 1000})
+	})
+	defer srv.Close()
+	defer close(release)
+	p := &Provider{client: client}
+
+	done := make(chan time.Time)
+	go func() { done <- p.QuotaResetAt() }()
+	select {
+	case got := <-done:
+		if !got.IsZero() {
+			t.Errorf("expected no reset time before the subscription is fetched, got %v", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("QuotaResetAt waited for the subscription fetch")
+	}
+	// The fetch in flight doesn't hold the cache either
+	if _, known := p.RemainingChars(context.Background()); known {
+		t.Error("expected the quota to be unknown until the fetch completes")
+	}
+}
@@ -0,0 +1,183 @@
+package elevenlabs
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/coder/websocket"
+
+	"github.com/pako-tts/server/internal/deadline"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/textinfo"
+)
+
+// maxWSMessage bounds one received message; audio messages carry a few seconds
+// of base64 audio.
+const maxWSMessage = 16 << 20
+
+// wsTextMessage is a message sent to the streaming input API. The first carries
+// the voice settings, and an empty text ends the input.
+type wsTextMessage struct {
+	Text                 string            `json:"text"`
+	VoiceSettings        *VoiceSettingsReq `json:"voice_settings,omitempty"`
+	TryTriggerGeneration bool              `json:"try_trigger_generation,omitempty"`
+}
+
+// wsAudioMessage is a message received from the streaming input API.
+type wsAudioMessage struct {
+	Audio   string `json:"audio"`
+	IsFinal bool   `json:"isFinal"`
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+// StreamTextToSpeech converts text to speech over the websocket streaming input
+// API. The text is sent a sentence at a time, so the returned audio can be read
+// while the rest is still being generated. The API has no request stitching:
+// PreviousRequestIDs are ignored.
+func (c *Client) StreamTextToSpeech(ctx context.Context, voiceID string, req *TTSRequest) (*TTSResponse, error) {
+	wsURL, err := c.streamURL(voiceID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	header := http.Header{}
+	header.Set("xi-api-key", c.key())
+	if t, ok := ctx.Deadline(); ok {
+		header.Set(deadline.Header, deadline.Format(t))
+	}
+	ws, resp, err := websocket.Dial(ctx, wsURL, &websocket.DialOptions{HTTPHeader: header})
+	if err != nil {
+		if resp != nil && resp.StatusCode != http.StatusSwitchingProtocols {
+			// A handshake the server refused is an API error like any other
+			return nil, newAPIError(resp)
+		}
+		return nil, fmt.Errorf("failed to open websocket: %w", err)
+	}
+	ws.SetReadLimit(maxWSMessage)
+
+	// Input is written concurrently with reading, so audio for the first sentences
+	// arrives while later ones are still being sent. A failed write means the
+	// connection is gone, which the reader finds out too.
+	go func() {
+		messages := make([]wsTextMessage, 0, 8)
+		messages = append(messages, wsTextMessage{Text: " ", VoiceSettings: req.VoiceSettings})
+		for _, sentence := range textinfo.Sentences(req.Text) {
+			// Each sentence ends with a single space, as the API expects
+			if sentence = strings.TrimSpace(sentence); sentence != "" {
+				messages = append(messages, wsTextMessage{Text: sentence + " ", TryTriggerGeneration: true})
+			}
+		}
+		messages = append(messages, wsTextMessage{Text: ""})
+		for _, m := range messages {
+			payload, _ := json.Marshal(m)
+			if err := ws.Write(ctx, websocket.MessageText, payload); err != nil {
+				return
+			}
+		}
+	}()
+
+	contentType := "audio/mpeg"
+	if !strings.HasPrefix(req.OutputFormat, "mp3") {
+		contentType = "audio/pcm"
+	}
+	return &TTSResponse{
+		Audio:       &wsAudioStream{ctx: ctx, ws: ws},
+		ContentType: contentType,
+	}, nil
+}
+
+// streamURL returns the websocket URL of the streaming input API, derived from
+// the client's base URL.
+func (c *Client) streamURL(voiceID string, req *TTSRequest) (string, error) {
+	u, err := url.Parse(c.baseURL + "/text-to-speech/" + url.PathEscape(voiceID) + "/stream-input")
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	case "http":
+		u.Scheme = "ws"
+	}
+	q := url.Values{}
+	q.Set("model_id", req.ModelID)
+	if req.OutputFormat != "" {
+		q.Set("output_format", req.OutputFormat)
+	}
+	if req.LanguageCode != "" {
+		q.Set("language_code", req.LanguageCode)
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// wsAudioStream reads the audio of a streaming input session. The connection
+// is closed when ctx is done.
+type wsAudioStream struct {
+	ctx     context.Context
+	ws      *websocket.Conn
+	pending []byte
+	done    bool
+}
+
+// Read implements io.Reader. It returns io.EOF after the final message.
+func (s *wsAudioStream) Read(p []byte) (int, error) {
+	for len(s.pending) == 0 {
+		if s.done {
+			return 0, io.EOF
+		}
+		if err := s.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+func (s *wsAudioStream) next() error {
+	_, payload, err := s.ws.Read(s.ctx)
+	if err != nil {
+		var closeErr websocket.CloseError
+		if errors.As(err, &closeErr) {
+			return &domain.ProviderError{
+				Provider: providerName,
+				Message:  "ElevenLabs closed the stream before the audio was complete: " + closeErr.Error(),
+			}
+		}
+		return err
+	}
+
+	var msg wsAudioMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return fmt.Errorf("failed to decode stream message: %w", err)
+	}
+	if msg.Error != "" {
+		return &domain.ProviderError{
+			Provider: providerName,
+			Message:  fmt.Sprintf("ElevenLabs stream error: %s: %s", msg.Error, msg.Message),
+		}
+	}
+	if msg.Audio != "" {
+		audio, err := base64.StdEncoding.DecodeString(msg.Audio)
+		if err != nil {
+			return fmt.Errorf("failed to decode stream audio: %w", err)
+		}
+		s.pending = audio
+	}
+	s.done = msg.IsFinal
+	return nil
+}
+
+// Close implements io.Closer, with the websocket closing handshake.
+func (s *wsAudioStream) Close() error {
+	return s.ws.Close(websocket.StatusNormalClosure, "")
+}
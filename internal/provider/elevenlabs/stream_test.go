@@ -0,0 +1,201 @@
+package elevenlabs
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/coder/websocket"
+
+	"github.com/pako-tts/server/internal/audio/transcode"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/provider/keyring"
+)
+
+// serveWebsocket upgrades the request and hands the server side of the
+// connection to session.
+func serveWebsocket(t *testing.T, w http.ResponseWriter, r *http.Request, session func(ws *websocket.Conn)) {
+	t.Helper()
+	ws, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		t.Errorf("accept: %v", err)
+		return
+	}
+	defer ws.CloseNow() //nolint:errcheck
+	session(ws)
+}
+
+// readInput reads the client's messages up to the closing empty text.
+func readInput(t *testing.T, ws *websocket.Conn) []wsTextMessage {
+	t.Helper()
+	var messages []wsTextMessage
+	for {
+		_, payload, err := ws.Read(context.Background())
+		if err != nil {
+			t.Errorf("read input: %v", err)
+			return messages
+		}
+		var m wsTextMessage
+		json.Unmarshal(payload, &m) //nolint:errcheck
+		messages = append(messages, m)
+		if m.Text == "" {
+			return messages
+		}
+	}
+}
+
+func sendJSON(ws *websocket.Conn, v any) {
+	payload, _ := json.Marshal(v)
+	ws.Write(context.Background(), websocket.MessageText, payload) //nolint:errcheck
+}
+
+func TestProvider_SynthesizeStream(t *testing.T) {
+	var input []wsTextMessage
+	var closeStatus websocket.StatusCode
+	closed := make(chan struct{})
+	client, srv := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/text-to-speech/voice-1/stream-input" || r.URL.Query().Get("model_id") != "eleven_turbo_v2_5" ||
+			r.URL.Query().Get("output_format") != "mp3_22050_32" || r.Header.Get("xi-api-key") != "test-key" {
+			t.Errorf("unexpected handshake %s %v", r.URL, r.Header)
+		}
+		serveWebsocket(t, w, r, func(ws *websocket.Conn) {
+			defer close(closed)
+			input = readInput(t, ws)
+			sendJSON(ws, map[string]any{"audio": base64.StdEncoding.EncodeToString([]byte("first-"))})
+			sendJSON(ws, map[string]any{"audio": base64.StdEncoding.EncodeToString([]byte("second"))})
+			sendJSON(ws, map[string]any{"isFinal": true})
+			_, _, err := ws.Read(context.Background())
+			closeStatus = websocket.CloseStatus(err)
+		})
+	})
+	defer srv.Close()
+
+	p := &Provider{client: client, defaultModelID: fallbackModelID}
+	stability := 0.3
+	stream, err := p.SynthesizeStream(context.Background(), &domain.SynthesisRequest{
+		Text:         "Hello there. How are you?",
+		VoiceID:      "voice-1",
+		ModelID:      "eleven_turbo_v2_5",
+		OutputFormat: "mp3",
+		Settings:     &domain.VoiceSettings{Stability: &stability},
+	})
+	if err != nil {
+		t.Fatalf("SynthesizeStream: %v", err)
+	}
+	if p.ActiveJobs() != 1 {
+		t.Errorf("expected the open stream to count as an active job, got %d", p.ActiveJobs())
+	}
+	audio, err := io.ReadAll(stream.Audio)
+	stream.Audio.Close() //nolint:errcheck
+	if err != nil {
+		t.Fatalf("read stream: %v", err)
+	}
+	if string(audio) != "first-second" || stream.ContentType != "audio/mpeg" {
+		t.Errorf("unexpected audio %q (%s)", audio, stream.ContentType)
+	}
+	if p.ActiveJobs() != 0 {
+		t.Errorf("expected no active jobs after close, got %d", p.ActiveJobs())
+	}
+	<-closed
+	if closeStatus != websocket.StatusNormalClosure {
+		t.Errorf("expected the client to close the websocket normally, got status %d", closeStatus)
+	}
+
+	texts := make([]string, 0, len(input))
+	for _, m := range input {
+		texts = append(texts, m.Text)
+	}
+	if !reflect.DeepEqual(texts, []string{" ", "Hello there. ", "How are you? ", ""}) {
+		t.Errorf("unexpected input messages %q", texts)
+	}
+	if input[0].VoiceSettings == nil || input[0].VoiceSettings.Stability != 0.3 {
+		t.Errorf("expected voice settings in the first message, got %+v", input[0].VoiceSettings)
+	}
+}
+
+func TestProvider_SynthesizeStream_WAV(t *testing.T) {
+	client, srv := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("output_format") != pcmFormat {
+			t.Errorf("expected output_format %s, got %q", pcmFormat, r.URL.Query().Get("output_format"))
+		}
+		serveWebsocket(t, w, r, func(ws *websocket.Conn) {
+			readInput(t, ws)
+			sendJSON(ws, map[string]any{"audio": base64.StdEncoding.EncodeToString([]byte{1, 0, 2, 0})})
+			sendJSON(ws, map[string]any{"isFinal": true})
+		})
+	})
+	defer srv.Close()
+
+	p := &Provider{client: client, defaultModelID: fallbackModelID}
+	stream, err := p.SynthesizeStream(context.Background(), &domain.SynthesisRequest{Text: "Hello.", VoiceID: "v", OutputFormat: "wav"})
+	if err != nil {
+		t.Fatalf("SynthesizeStream: %v", err)
+	}
+	audio, err := io.ReadAll(stream.Audio)
+	stream.Audio.Close() //nolint:errcheck
+	if err != nil {
+		t.Fatalf("read stream: %v", err)
+	}
+	pcm, rate, _, _, ok := transcode.ParseWAV(audio)
+	if !ok || rate != pcmSampleRate || !bytes.Equal(pcm, []byte{1, 0, 2, 0}) || stream.ContentType != "audio/wav" {
+		t.Errorf("expected the PCM behind a WAV header, got %v at %d Hz (%s)", audio, rate, stream.ContentType)
+	}
+	if p.ActiveJobs() != 0 {
+		t.Errorf("expected no active jobs after close, got %d", p.ActiveJobs())
+	}
+}
+
+func TestProvider_SynthesizeStream_ErrorMessage(t *testing.T) {
+	client, srv := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		serveWebsocket(t, w, r, func(ws *websocket.Conn) {
+			readInput(t, ws)
+			sendJSON(ws, map[string]any{"error": "voice_not_found", "message": "A voice with that ID does not exist"})
+		})
+	})
+	defer srv.Close()
+
+	p := &Provider{client: client, defaultModelID: fallbackModelID}
+	stream, err := p.SynthesizeStream(context.Background(), &domain.SynthesisRequest{Text: "hi", VoiceID: "missing", OutputFormat: "mp3"})
+	if err != nil {
+		t.Fatalf("SynthesizeStream: %v", err)
+	}
+	defer stream.Audio.Close() //nolint:errcheck
+	_, err = io.ReadAll(stream.Audio)
+	if _, ok := domain.AsProviderError(err); !ok || !strings.Contains(err.Error(), "voice_not_found") {
+		t.Errorf("expected a provider error naming the cause, got %v", err)
+	}
+}
+
+func TestProvider_SynthesizeStream_FailsOverToSecondaryKey(t *testing.T) {
+	client, srv := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("xi-api-key") != "secondary-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		serveWebsocket(t, w, r, func(ws *websocket.Conn) {
+			readInput(t, ws)
+			sendJSON(ws, map[string]any{"audio": base64.StdEncoding.EncodeToString([]byte("audio")), "isFinal": true})
+		})
+	})
+	defer srv.Close()
+
+	client.keys = keyring.New("primary-key", "secondary-key")
+	p := &Provider{client: client, defaultModelID: fallbackModelID}
+	stream, err := p.SynthesizeStream(context.Background(), &domain.SynthesisRequest{Text: "hi", VoiceID: "v", OutputFormat: "mp3"})
+	if err != nil {
+		t.Fatalf("SynthesizeStream: %v", err)
+	}
+	defer stream.Audio.Close() //nolint:errcheck
+	if audio, _ := io.ReadAll(stream.Audio); string(audio) != "audio" {
+		t.Errorf("expected audio from the secondary key, got %q", audio)
+	}
+	if status := p.Keyring().Status(); status.ActiveKey != domain.KeySecondary {
+		t.Errorf("expected secondary key active, got %s", status.ActiveKey)
+	}
+}
@@ -12,6 +12,10 @@ import (
 	neturl "net/url"
 	"strings"
 	"time"
+
+	"github.com/pako-tts/server/internal/deadline"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/provider/keyring"
 )
 
 const (
@@ -20,7 +24,7 @@ const (
 
 // Client is an HTTP client for the Gemini API.
 type Client struct {
-	apiKey       string
+	keys         *keyring.Keyring
 	baseURL      string
 	httpClient   *http.Client
 	healthClient *http.Client
@@ -34,7 +38,7 @@ func NewClient(apiKey string) *Client {
 // NewClientWithBaseURL creates a new Gemini API client with a custom base URL (used in tests).
 func NewClientWithBaseURL(apiKey, base string) *Client {
 	return &Client{
-		apiKey:  apiKey,
+		keys:    keyring.New(apiKey, ""),
 		baseURL: base,
 		httpClient: &http.Client{
 			Timeout: 120 * time.Second,
@@ -45,6 +49,11 @@ func NewClientWithBaseURL(apiKey, base string) *Client {
 	}
 }
 
+// key returns the current API key.
+func (c *Client) key() string {
+	return c.keys.Current()
+}
+
 // TTSRequest is the JSON body sent to the Gemini generateContent endpoint.
 type TTSRequest struct {
 	Contents         []Content        `json:"contents"`
@@ -138,7 +147,8 @@ func (c *Client) GenerateAudio(ctx context.Context, model, prompt, voiceName str
 	}
 
 	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("x-goog-api-key", c.apiKey)
+	httpReq.Header.Set("x-goog-api-key", c.key())
+	deadline.Set(httpReq)
 
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
@@ -160,7 +170,11 @@ func (c *Client) GenerateAudio(ctx context.Context, model, prompt, voiceName str
 		if len(snippet) > 512 {
 			snippet = snippet[:512]
 		}
-		return nil, fmt.Errorf("gemini API error (status %d): %s", resp.StatusCode, snippet)
+		return nil, &domain.ProviderError{
+			Provider:   providerName,
+			StatusCode: resp.StatusCode,
+			Message:    fmt.Sprintf("gemini API error (status %d): %s", resp.StatusCode, snippet),
+		}
 	}
 
 	var ttsResp TTSResponse
@@ -191,7 +205,7 @@ func (c *Client) CheckHealth(ctx context.Context, model string) bool {
 	if err != nil {
 		return false
 	}
-	httpReq.Header.Set("x-goog-api-key", c.apiKey)
+	httpReq.Header.Set("x-goog-api-key", c.key())
 
 	resp, err := c.healthClient.Do(httpReq)
 	if err != nil {
@@ -8,6 +8,7 @@ import (
 
 	"github.com/pako-tts/server/internal/audio/transcode"
 	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/provider/keyring"
 	"github.com/pako-tts/server/pkg/config"
 )
 
@@ -45,8 +46,11 @@ func NewProviderFromConfig(cfg config.ProviderConfig, isDefault bool) (*Provider
 		modelID = defaultModelID
 	}
 
+	client := NewClient(cfg.APIKey)
+	client.keys = keyring.New(cfg.APIKey, cfg.SecondaryAPIKey)
+
 	return &Provider{
-		client:         NewClient(cfg.APIKey),
+		client:         client,
 		defaultModelID: modelID,
 		defaultStyle:   cfg.DefaultStyle,
 		isDefault:      isDefault,
@@ -84,7 +88,11 @@ func (p *Provider) Synthesize(ctx context.Context, req *domain.SynthesisRequest)
 
 	prompt := p.buildPrompt(req)
 
+	key := p.client.keys.Current()
 	pcm, err := p.client.GenerateAudio(ctx, model, prompt, voiceID)
+	if perr, ok := domain.AsProviderError(err); ok && perr.IsKeyRejected() && p.client.keys.Failover(key, err) {
+		pcm, err = p.client.GenerateAudio(ctx, model, prompt, voiceID)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -156,6 +164,11 @@ func (p *Provider) ActiveJobs() int {
 	return int(atomic.LoadInt32(&p.activeJobs))
 }
 
+// Keyring returns the provider's upstream API keys.
+func (p *Provider) Keyring() *keyring.Keyring {
+	return p.client.keys
+}
+
 // Info returns provider metadata for API responses.
 func (p *Provider) Info(ctx context.Context) domain.ProviderInfo {
 	return domain.ProviderInfo{
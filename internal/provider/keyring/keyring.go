@@ -0,0 +1,115 @@
+// Package keyring holds a provider's primary and secondary upstream API keys and
+// fails over to the secondary when the primary is rejected.
+package keyring
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// FailoverFunc is called once each time a keyring switches to its secondary key.
+type FailoverFunc func(cause error)
+
+// Keyring is safe for concurrent use.
+type Keyring struct {
+	mu             sync.RWMutex
+	primary        string
+	secondary      string
+	usingSecondary bool
+	failedOverAt   time.Time
+	lastError      string
+	onFailover     FailoverFunc
+}
+
+// New creates a keyring that starts on the primary key. secondary may be empty.
+func New(primary, secondary string) *Keyring {
+	return &Keyring{primary: primary, secondary: secondary}
+}
+
+// Current returns the key requests should be sent with.
+func (k *Keyring) Current() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if k.usingSecondary {
+		return k.secondary
+	}
+	return k.primary
+}
+
+// SetPrimary replaces the primary key and switches back to it; the secondary is kept.
+func (k *Keyring) SetPrimary(primary string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.primary = primary
+	k.reset()
+}
+
+// Set replaces both keys and switches back to the primary.
+func (k *Keyring) Set(primary, secondary string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.primary = primary
+	k.secondary = secondary
+	k.reset()
+}
+
+func (k *Keyring) reset() {
+	k.usingSecondary = false
+	k.failedOverAt = time.Time{}
+	k.lastError = ""
+}
+
+// OnFailover registers the callback fired when the keyring switches to its secondary key.
+func (k *Keyring) OnFailover(fn FailoverFunc) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.onFailover = fn
+}
+
+// Failover switches to the secondary key after the upstream rejected rejectedKey.
+// It returns true when the request should be retried with Current(): either this
+// call switched keys, or a concurrent request already did.
+func (k *Keyring) Failover(rejectedKey string, cause error) bool {
+	k.mu.Lock()
+	if k.secondary == "" || rejectedKey != k.primary || rejectedKey == k.secondary {
+		k.mu.Unlock()
+		return false
+	}
+	if k.usingSecondary {
+		k.mu.Unlock()
+		return true
+	}
+
+	k.usingSecondary = true
+	k.failedOverAt = time.Now()
+	if cause != nil {
+		k.lastError = cause.Error()
+	}
+	notify := k.onFailover
+	k.mu.Unlock()
+
+	if notify != nil {
+		notify(cause)
+	}
+	return true
+}
+
+// Status reports which key is active.
+func (k *Keyring) Status() domain.ProviderKeyStatus {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	status := domain.ProviderKeyStatus{
+		ActiveKey:    domain.KeyPrimary,
+		HasSecondary: k.secondary != "",
+		LastError:    k.lastError,
+	}
+	if k.usingSecondary {
+		status.ActiveKey = domain.KeySecondary
+		failedOverAt := k.failedOverAt
+		status.FailedOverAt = &failedOverAt
+	}
+	return status
+}
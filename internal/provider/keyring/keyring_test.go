@@ -0,0 +1,65 @@
+package keyring
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+func TestFailover_SwitchesOnceAndNotifies(t *testing.T) {
+	k := New("p", "s")
+	notified := 0
+	k.OnFailover(func(error) { notified++ })
+
+	if !k.Failover("p", errors.New("401")) {
+		t.Fatal("expected failover to secondary")
+	}
+	if k.Current() != "s" {
+		t.Errorf("expected secondary key, got %q", k.Current())
+	}
+	// A concurrent request that also failed on the primary retries without a second alert.
+	if !k.Failover("p", errors.New("401")) {
+		t.Error("expected retry for request sent with the old primary")
+	}
+	// The secondary itself being rejected has nowhere to go.
+	if k.Failover("s", errors.New("401")) {
+		t.Error("expected no failover once the secondary is rejected")
+	}
+	if notified != 1 {
+		t.Errorf("expected 1 notification, got %d", notified)
+	}
+
+	status := k.Status()
+	if status.ActiveKey != domain.KeySecondary || status.FailedOverAt == nil || status.LastError != "401" {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+func TestFailover_WithoutSecondary(t *testing.T) {
+	k := New("p", "")
+	if k.Failover("p", errors.New("401")) {
+		t.Error("expected no failover without a secondary key")
+	}
+	if k.Current() != "p" {
+		t.Errorf("expected primary key, got %q", k.Current())
+	}
+}
+
+func TestSet_SwitchesBackToPrimary(t *testing.T) {
+	k := New("p", "s")
+	k.Failover("p", errors.New("401"))
+
+	k.Set("p2", "s2")
+	if k.Current() != "p2" {
+		t.Errorf("expected new primary, got %q", k.Current())
+	}
+	if status := k.Status(); status.ActiveKey != domain.KeyPrimary || status.FailedOverAt != nil || !status.HasSecondary {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	k.SetPrimary("p3")
+	if k.Current() != "p3" || !k.Status().HasSecondary {
+		t.Errorf("expected secondary kept after SetPrimary, got %+v", k.Status())
+	}
+}
@@ -0,0 +1,211 @@
+// Package piper implements a TTS provider that runs Piper (https://github.com/rhasspy/piper)
+// locally, so speech can be produced without calling any external API.
+package piper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pako-tts/server/internal/audio/transcode"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/pkg/config"
+)
+
+const (
+	providerType      = "PiperProvider"
+	defaultBinary     = "piper"
+	defaultTimeout    = 60 * time.Second
+	defaultConcurrent = 2
+
+	// Speed factors Piper renders natively through --length_scale.
+	minNativeSpeed = 0.5
+	maxNativeSpeed = 2.0
+)
+
+// Provider implements domain.TTSProvider by running the Piper binary once per
+// request. Piper writes 16-bit mono PCM; WAV is wrapped here and MP3 is encoded
+// with ffmpeg.
+type Provider struct {
+	name          string
+	binary        string
+	voices        []*voice
+	byID          map[string]*voice
+	defaultVoice  *voice
+	timeout       time.Duration
+	maxConcurrent int
+	activeJobs    int32
+	isDefault     bool
+}
+
+// NewProviderFromConfig creates a Piper provider from configuration. It fails
+// when the models can't be read; a missing binary only makes the provider
+// unavailable.
+func NewProviderFromConfig(cfg config.ProviderConfig, isDefault bool) (*Provider, error) {
+	if len(cfg.Models) == 0 {
+		return nil, fmt.Errorf("piper provider requires models")
+	}
+	voices, err := loadVoices(cfg.Models)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Provider{
+		name:          cfg.Name,
+		binary:        cfg.Binary,
+		voices:        voices,
+		byID:          make(map[string]*voice, len(voices)),
+		defaultVoice:  voices[0],
+		timeout:       cfg.Timeout,
+		maxConcurrent: cfg.MaxConcurrent,
+		isDefault:     isDefault,
+	}
+	for _, v := range voices {
+		p.byID[v.id] = v
+	}
+	if p.binary == "" {
+		p.binary = defaultBinary
+	}
+	if p.timeout == 0 {
+		p.timeout = defaultTimeout
+	}
+	if p.maxConcurrent == 0 {
+		p.maxConcurrent = defaultConcurrent
+	}
+	if cfg.ModelID != "" {
+		v, ok := p.byID[cfg.ModelID]
+		if !ok {
+			return nil, fmt.Errorf("piper model_id %q is not among the loaded models", cfg.ModelID)
+		}
+		p.defaultVoice = v
+	}
+	return p, nil
+}
+
+// Name returns the provider name.
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// Type returns the stable provider type identifier (independent of user-configured name).
+func (p *Provider) Type() string {
+	return providerType
+}
+
+// Synthesize runs Piper on the request's text.
+func (p *Provider) Synthesize(ctx context.Context, req *domain.SynthesisRequest) (*domain.SynthesisResult, error) {
+	atomic.AddInt32(&p.activeJobs, 1)
+	defer atomic.AddInt32(&p.activeJobs, -1)
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	v := p.voice(req)
+	args := []string{"--model", v.modelPath, "--output-raw"}
+	if req.Settings != nil && req.Settings.Speed != nil && *req.Settings.Speed > 0 {
+		// length_scale stretches phoneme durations: the inverse of speed
+		args = append(args, "--length_scale", strconv.FormatFloat(1 / *req.Settings.Speed, 'f', 3, 64))
+	}
+
+	cmd := exec.CommandContext(ctx, p.binary, args...)
+	cmd.Stdin = strings.NewReader(req.Text)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	pcm, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("piper: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if len(pcm) == 0 {
+		return nil, fmt.Errorf("piper produced no audio: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	var audio []byte
+	var contentType string
+	switch req.OutputFormat {
+	case "wav":
+		audio = transcode.PCMToWAV(pcm, v.sampleRate, 1, 16)
+		contentType = "audio/wav"
+	default:
+		audio, err = transcode.PCMToMP3(ctx, pcm, v.sampleRate, 1)
+		if err != nil {
+			return nil, err
+		}
+		contentType = "audio/mpeg"
+	}
+
+	return &domain.SynthesisResult{
+		Audio:       bytes.NewReader(audio),
+		ContentType: contentType,
+		Duration:    time.Duration(len(pcm)/2) * time.Second / time.Duration(v.sampleRate),
+		SizeBytes:   int64(len(audio)),
+	}, nil
+}
+
+// voice picks the model for req: model_id, then voice_id, then the default.
+// IDs of other providers' voices (e.g. the global default voice) fall back to
+// the default.
+func (p *Provider) voice(req *domain.SynthesisRequest) *voice {
+	if v, ok := p.byID[req.ModelID]; ok {
+		return v
+	}
+	if v, ok := p.byID[req.VoiceID]; ok {
+		return v
+	}
+	return p.defaultVoice
+}
+
+// ListVoices returns one voice per loaded model.
+func (p *Provider) ListVoices(_ context.Context) ([]domain.Voice, error) {
+	voices := make([]domain.Voice, 0, len(p.voices))
+	for _, v := range p.voices {
+		voices = append(voices, domain.Voice{
+			VoiceID:  v.id,
+			Name:     v.name,
+			Provider: p.name,
+			Language: v.language,
+		})
+	}
+	return voices, nil
+}
+
+// ListModels returns nil: Piper's models are listed as voices, as for selfhosted.
+func (p *Provider) ListModels(_ context.Context) ([]domain.Model, error) {
+	return nil, nil
+}
+
+// IsAvailable reports whether the Piper binary can be found.
+func (p *Provider) IsAvailable(_ context.Context) bool {
+	_, err := exec.LookPath(p.binary)
+	return err == nil
+}
+
+// MaxConcurrent returns the maximum number of concurrent synthesis jobs.
+func (p *Provider) MaxConcurrent() int {
+	return p.maxConcurrent
+}
+
+// ActiveJobs returns the current number of active synthesis jobs.
+func (p *Provider) ActiveJobs() int {
+	return int(atomic.LoadInt32(&p.activeJobs))
+}
+
+// Status returns provider runtime status for health checks.
+func (p *Provider) Status(ctx context.Context) domain.ProviderStatus {
+	return domain.ProviderStatus{
+		Name:          p.name,
+		Available:     p.IsAvailable(ctx),
+		ActiveJobs:    p.ActiveJobs(),
+		MaxConcurrent: p.maxConcurrent,
+	}
+}
+
+// SupportsSpeed reports whether Piper can render the speed factor natively.
+// Factors outside its range are applied server-side after synthesis.
+func (p *Provider) SupportsSpeed(speed float64) bool {
+	return speed >= minNativeSpeed && speed <= maxNativeSpeed
+}
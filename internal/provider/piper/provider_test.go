@@ -0,0 +1,154 @@
+package piper
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pako-tts/server/internal/audio/transcode"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/pkg/config"
+)
+
+// writeModel writes an (empty) model and its config to dir.
+func writeModel(t *testing.T, dir, id, config string) string {
+	t.Helper()
+	path := filepath.Join(dir, id+".onnx")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+".json", []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// fakePiper writes a script standing in for the piper binary. It records its
+// arguments and input in dir and prints four bytes of "PCM".
+func fakePiper(t *testing.T, dir string) string {
+	t.Helper()
+	path := filepath.Join(dir, "piper")
+	script := "#!/bin/sh\necho \"$@\" > " + filepath.Join(dir, "args") + "\ncat > " + filepath.Join(dir, "input") + "\nprintf 'abcd'\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTestProvider(t *testing.T) (*Provider, string) {
+	t.Helper()
+	dir := t.TempDir()
+	models := filepath.Join(dir, "models")
+	if err := os.Mkdir(models, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeModel(t, models, "en_US-lessac-medium", `{"dataset": "lessac", "audio": {"sample_rate": 22050, "quality": "medium"}, "language": {"code": "en_US"}}`)
+	writeModel(t, models, "de_DE-thorsten-low", `{"audio": {"sample_rate": 16000}, "language": {"code": "de_DE"}}`)
+
+	p, err := NewProviderFromConfig(config.ProviderConfig{
+		Name:    "piper",
+		Binary:  fakePiper(t, dir),
+		Models:  []string{models},
+		ModelID: "en_US-lessac-medium",
+	}, false)
+	if err != nil {
+		t.Fatalf("NewProviderFromConfig: %v", err)
+	}
+	return p, dir
+}
+
+func TestProvider_ListVoices(t *testing.T) {
+	p, _ := newTestProvider(t)
+	voices, err := p.ListVoices(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []domain.Voice{
+		{VoiceID: "de_DE-thorsten-low", Name: "de_DE-thorsten-low", Provider: "piper", Language: "de-DE"},
+		{VoiceID: "en_US-lessac-medium", Name: "lessac (medium)", Provider: "piper", Language: "en-US"},
+	}
+	if len(voices) != len(want) || voices[0] != want[0] || voices[1] != want[1] {
+		t.Errorf("got %+v, want %+v", voices, want)
+	}
+	if !p.IsAvailable(context.Background()) {
+		t.Error("expected the provider to be available")
+	}
+}
+
+func TestProvider_SynthesizeWAV(t *testing.T) {
+	p, dir := newTestProvider(t)
+	speed := 1.25
+	result, err := p.Synthesize(context.Background(), &domain.SynthesisRequest{
+		Text:         "Hallo Welt",
+		VoiceID:      "de_DE-thorsten-low",
+		OutputFormat: "wav",
+		Settings:     &domain.VoiceSettings{Speed: &speed},
+	})
+	if err != nil {
+		t.Fatalf("Synthesize: %v", err)
+	}
+	audio, _ := io.ReadAll(result.Audio)
+	pcm, sampleRate, channels, bits, ok := transcode.ParseWAV(audio)
+	if !ok || string(pcm) != "abcd" || sampleRate != 16000 || channels != 1 || bits != 16 {
+		t.Errorf("unexpected WAV: ok=%v pcm=%q rate=%d channels=%d bits=%d", ok, pcm, sampleRate, channels, bits)
+	}
+	if result.ContentType != "audio/wav" {
+		t.Errorf("unexpected content type %q", result.ContentType)
+	}
+
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	if !strings.Contains(string(args), "de_DE-thorsten-low.onnx --output-raw --length_scale 0.800") {
+		t.Errorf("unexpected piper arguments %q", args)
+	}
+	if input, _ := os.ReadFile(filepath.Join(dir, "input")); string(input) != "Hallo Welt" {
+		t.Errorf("unexpected piper input %q", input)
+	}
+}
+
+func TestProvider_SynthesizeFallsBackToDefaultVoice(t *testing.T) {
+	p, dir := newTestProvider(t)
+	_, err := p.Synthesize(context.Background(), &domain.SynthesisRequest{
+		Text:         "Hello",
+		VoiceID:      "pNInz6obpgDQGcFmaJgB", // an ElevenLabs voice
+		OutputFormat: "wav",
+	})
+	if err != nil {
+		t.Fatalf("Synthesize: %v", err)
+	}
+	if args, _ := os.ReadFile(filepath.Join(dir, "args")); !strings.Contains(string(args), "en_US-lessac-medium.onnx") {
+		t.Errorf("expected the default model, got arguments %q", args)
+	}
+}
+
+func TestProvider_MissingBinary(t *testing.T) {
+	p, _ := newTestProvider(t)
+	p.binary = filepath.Join(t.TempDir(), "no-such-piper")
+	if p.IsAvailable(context.Background()) {
+		t.Error("expected the provider to be unavailable")
+	}
+	if _, err := p.Synthesize(context.Background(), &domain.SynthesisRequest{Text: "Hello", OutputFormat: "wav"}); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestNewProviderFromConfig_Errors(t *testing.T) {
+	dir, valid := t.TempDir(), t.TempDir()
+	writeModel(t, dir, "broken", `{"audio": {}}`)
+	writeModel(t, valid, "en_US-lessac-medium", `{"audio": {"sample_rate": 22050}}`)
+
+	tests := map[string]config.ProviderConfig{
+		"no models":        {Name: "piper"},
+		"missing path":     {Name: "piper", Models: []string{filepath.Join(dir, "missing")}},
+		"no sample rate":   {Name: "piper", Models: []string{filepath.Join(dir, "broken.onnx")}},
+		"empty directory":  {Name: "piper", Models: []string{t.TempDir()}},
+		"unknown model_id": {Name: "piper", Models: []string{valid}, ModelID: "other"},
+	}
+	for name, cfg := range tests {
+		if _, err := NewProviderFromConfig(cfg, false); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
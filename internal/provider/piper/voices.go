@@ -0,0 +1,101 @@
+package piper
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// voice is a Piper model. Piper has no voices apart from its models, so each
+// model is listed as a voice, identified by its file name without ".onnx".
+type voice struct {
+	id         string
+	name       string
+	language   string
+	modelPath  string
+	sampleRate int
+}
+
+// modelConfig is the part of a model's .onnx.json that the provider uses.
+type modelConfig struct {
+	Dataset string `json:"dataset"`
+	Audio   struct {
+		SampleRate int    `json:"sample_rate"`
+		Quality    string `json:"quality"`
+	} `json:"audio"`
+	Language struct {
+		Code string `json:"code"`
+	} `json:"language"`
+}
+
+// loadVoices reads the models at paths, each an .onnx file or a directory whose
+// .onnx files are all used. Every model needs its .onnx.json config next to it.
+func loadVoices(paths []string) ([]*voice, error) {
+	var voices []*voice
+	seen := make(map[string]string)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("piper model path: %w", err)
+		}
+
+		models := []string{path}
+		if info.IsDir() {
+			models, err = filepath.Glob(filepath.Join(path, "*.onnx"))
+			if err != nil {
+				return nil, fmt.Errorf("piper model path: %w", err)
+			}
+			sort.Strings(models)
+		}
+
+		for _, model := range models {
+			v, err := loadVoice(model)
+			if err != nil {
+				return nil, err
+			}
+			if other, ok := seen[v.id]; ok {
+				return nil, fmt.Errorf("piper models %s and %s have the same voice id %q", other, model, v.id)
+			}
+			seen[v.id] = model
+			voices = append(voices, v)
+		}
+	}
+	if len(voices) == 0 {
+		return nil, fmt.Errorf("piper provider found no .onnx models in %s", strings.Join(paths, ", "))
+	}
+	return voices, nil
+}
+
+// loadVoice reads one model's config.
+func loadVoice(modelPath string) (*voice, error) {
+	data, err := os.ReadFile(modelPath + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("piper model config: %w", err)
+	}
+	var cfg modelConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("piper model config %s.json: %w", modelPath, err)
+	}
+	if cfg.Audio.SampleRate <= 0 {
+		return nil, fmt.Errorf("piper model config %s.json has no audio.sample_rate", modelPath)
+	}
+
+	id := strings.TrimSuffix(filepath.Base(modelPath), ".onnx")
+	name := id
+	if cfg.Dataset != "" {
+		name = cfg.Dataset
+		if cfg.Audio.Quality != "" {
+			name += " (" + cfg.Audio.Quality + ")"
+		}
+	}
+	return &voice{
+		id:         id,
+		name:       name,
+		language:   strings.ReplaceAll(cfg.Language.Code, "_", "-"),
+		modelPath:  modelPath,
+		sampleRate: cfg.Audio.SampleRate,
+	}, nil
+}
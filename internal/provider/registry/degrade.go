@@ -0,0 +1,152 @@
+package registry
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/pkg/config"
+)
+
+// degradation tracks the rate-limited calls of each provider, and puts a
+// provider rate limited RateLimits times within Window into degraded mode until
+// it goes Recovery without being rate limited.
+type degradation struct {
+	mu  sync.Mutex
+	cfg config.DegradationConfig
+	// limited holds the times of each provider's rate-limited calls within Window.
+	limited  map[string][]time.Time
+	degraded map[string]*degradedProvider
+	now      func() time.Time
+}
+
+type degradedProvider struct {
+	domain.ProviderDegradation
+	lastLimited time.Time
+}
+
+func newDegradation(cfg config.DegradationConfig) *degradation {
+	if cfg.RateLimits <= 0 {
+		return nil
+	}
+	return &degradation{
+		cfg:      cfg,
+		limited:  make(map[string][]time.Time),
+		degraded: make(map[string]*degradedProvider),
+		now:      time.Now,
+	}
+}
+
+// observe records the outcome of a call to name. It reports whether the call
+// put the provider into degraded mode, and why.
+func (d *degradation) observe(name string, err error) (entered bool, reason string) {
+	perr, ok := domain.AsProviderError(err)
+	if !ok || !perr.IsRateLimited() {
+		return false, ""
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	if p, ok := d.degraded[name]; ok {
+		p.lastLimited = now
+		return false, ""
+	}
+	limited := append(d.limited[name], now)
+	for len(limited) > 0 && now.Sub(limited[0]) > d.cfg.Window {
+		limited = limited[1:]
+	}
+	if len(limited) < d.cfg.RateLimits {
+		d.limited[name] = limited
+		return false, ""
+	}
+
+	delete(d.limited, name)
+	reason = fmt.Sprintf("rate limited %d times within %s", len(limited), d.cfg.Window)
+	d.degraded[name] = &degradedProvider{
+		ProviderDegradation: domain.ProviderDegradation{Provider: name, Reason: reason, Since: now},
+		lastLimited:         now,
+	}
+	return true, reason
+}
+
+// recoverLocked takes the providers that went Recovery without being rate limited
+// out of degraded mode and returns their names. The caller holds d.mu.
+func (d *degradation) recoverLocked() []string {
+	var recovered []string
+	now := d.now()
+	for name, p := range d.degraded {
+		if now.Sub(p.lastLimited) >= d.cfg.Recovery {
+			delete(d.degraded, name)
+			recovered = append(recovered, name)
+		}
+	}
+	return recovered
+}
+
+// Degraded returns the providers in degraded mode, by name. Providers are taken
+// out of degraded mode once recovered.
+func (r *Registry) Degraded() []domain.ProviderDegradation {
+	if r.degradation == nil {
+		return nil
+	}
+	d := r.degradation
+	d.mu.Lock()
+	recovered := d.recoverLocked()
+	degraded := make([]domain.ProviderDegradation, 0, len(d.degraded))
+	for _, p := range d.degraded {
+		degraded = append(degraded, p.ProviderDegradation)
+	}
+	d.mu.Unlock()
+
+	r.notifyRecovered(recovered)
+	sort.Slice(degraded, func(i, j int) bool { return degraded[i].Provider < degraded[j].Provider })
+	return degraded
+}
+
+// IsDegraded reports whether name is in degraded mode.
+func (r *Registry) IsDegraded(name string) bool {
+	if r.degradation == nil {
+		return false
+	}
+	d := r.degradation
+	d.mu.Lock()
+	recovered := d.recoverLocked()
+	_, degraded := d.degraded[name]
+	d.mu.Unlock()
+
+	r.notifyRecovered(recovered)
+	return degraded
+}
+
+// OnDegradation registers fn to be called when a provider enters degraded mode,
+// with the reason, and when it leaves it, with an empty reason.
+func (r *Registry) OnDegradation(fn func(provider string, degraded bool, reason string)) {
+	r.onDegradation = fn
+}
+
+func (r *Registry) notifyRecovered(names []string) {
+	if r.onDegradation == nil {
+		return
+	}
+	for _, name := range names {
+		r.onDegradation(name, false, "")
+	}
+}
+
+// preferFallback returns the first fallback of name that isn't degraded when
+// name is degraded and fallbacks are preferred, else name.
+func (r *Registry) preferFallback(name string) string {
+	if r.degradation == nil || !r.degradation.cfg.PreferFallback || !r.IsDegraded(name) {
+		return name
+	}
+	for _, fallback := range r.fallbacks[name] {
+		if _, ok := r.providers[fallback]; ok && !r.IsDegraded(fallback) {
+			return fallback
+		}
+	}
+	return name
+}
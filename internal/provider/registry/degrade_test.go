@@ -0,0 +1,110 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/pkg/config"
+)
+
+func TestDegradation(t *testing.T) {
+	r := newTestRegistry(config.RoutingPolicyPrimary,
+		config.ProviderConfig{Name: "elevenlabs"},
+		config.ProviderConfig{Name: "gemini"},
+		config.ProviderConfig{Name: "piper"},
+	)
+	r.fallbacks = map[string][]string{"elevenlabs": {"gemini", "piper"}}
+	r.degradation = newDegradation(config.DegradationConfig{
+		RateLimits:     3,
+		Window:         time.Minute,
+		Recovery:       2 * time.Minute,
+		Concurrency:    0.5,
+		PreferFallback: true,
+	})
+	now := time.Now()
+	r.degradation.now = func() time.Time { return now }
+
+	type transition struct {
+		provider string
+		degraded bool
+	}
+	var transitions []transition
+	r.OnDegradation(func(provider string, degraded bool, reason string) {
+		transitions = append(transitions, transition{provider, degraded})
+	})
+
+	limited := &domain.ProviderError{StatusCode: http.StatusTooManyRequests, Message: "too many requests"}
+	observe := func(name string, err error) {
+		r.Observe(name, 10, time.Second, err)
+		now = now.Add(10 * time.Second)
+	}
+
+	// Rate limits spread beyond the window, and other errors, don't count
+	observe("elevenlabs", limited)
+	now = now.Add(2 * time.Minute)
+	observe("elevenlabs", limited)
+	observe("elevenlabs", errors.New("boom"))
+	observe("elevenlabs", limited)
+	if r.IsDegraded("elevenlabs") {
+		t.Fatal("expected 2 rate limits within the window not to degrade the provider")
+	}
+
+	observe("elevenlabs", limited)
+	observe("gemini", limited)
+	degraded := r.Degraded()
+	if len(degraded) != 1 || degraded[0].Provider != "elevenlabs" || degraded[0].Reason != "rate limited 3 times within 1m0s" {
+		t.Fatalf("expected elevenlabs degraded, got %+v", degraded)
+	}
+
+	// Requests without a provider go to the first fallback that isn't degraded
+	if got := r.Route(context.Background(), 10); got != "gemini" {
+		t.Errorf("expected the fallback preferred, got %s", got)
+	}
+	for range 2 {
+		observe("gemini", limited)
+	}
+	if got := r.Route(context.Background(), 10); got != "piper" {
+		t.Errorf("expected the next fallback while gemini is degraded too, got %s", got)
+	}
+
+	// A degraded provider recovers once it went the recovery period without a rate limit
+	now = now.Add(90 * time.Second)
+	observe("elevenlabs", limited)
+	now = now.Add(90 * time.Second)
+	if !r.IsDegraded("elevenlabs") {
+		t.Error("expected a rate limit while degraded to postpone recovery")
+	}
+	now = now.Add(time.Minute)
+	if r.IsDegraded("elevenlabs") {
+		t.Error("expected elevenlabs recovered")
+	}
+	if got := r.Route(context.Background(), 10); got != "elevenlabs" {
+		t.Errorf("expected the recovered default routed to again, got %s", got)
+	}
+
+	want := []transition{{"elevenlabs", true}, {"gemini", true}, {"gemini", false}, {"elevenlabs", false}}
+	if len(transitions) != len(want) {
+		t.Fatalf("expected transitions %v, got %v", want, transitions)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Errorf("expected transitions %v, got %v", want, transitions)
+			break
+		}
+	}
+}
+
+func TestDegradation_Off(t *testing.T) {
+	r := newTestRegistry(config.RoutingPolicyPrimary, config.ProviderConfig{Name: "elevenlabs"})
+	r.degradation = newDegradation(config.DegradationConfig{})
+	for range 10 {
+		r.Observe("elevenlabs", 10, time.Second, &domain.ProviderError{StatusCode: http.StatusTooManyRequests})
+	}
+	if r.IsDegraded("elevenlabs") || r.Degraded() != nil {
+		t.Error("expected no degraded mode with rate_limits 0")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/pako-tts/server/internal/domain"
 	"github.com/pako-tts/server/internal/provider/elevenlabs"
 	"github.com/pako-tts/server/internal/provider/gemini"
+	"github.com/pako-tts/server/internal/provider/piper"
 	"github.com/pako-tts/server/internal/provider/selfhosted"
 	"github.com/pako-tts/server/pkg/config"
 )
@@ -21,6 +22,7 @@ func init() {
 	RegisterFactory("elevenlabs", elevenlabsFactory)
 	RegisterFactory("selfhosted", selfhostedFactory)
 	RegisterFactory("gemini", geminiFactory)
+	RegisterFactory("piper", piperFactory)
 }
 
 // RegisterFactory registers a provider factory for a given type.
@@ -48,3 +50,8 @@ func selfhostedFactory(cfg config.ProviderConfig, isDefault bool) (domain.TTSPro
 func geminiFactory(cfg config.ProviderConfig, isDefault bool) (domain.TTSProvider, error) {
 	return gemini.NewProviderFromConfig(cfg, isDefault)
 }
+
+// piperFactory creates a Piper provider from config.
+func piperFactory(cfg config.ProviderConfig, isDefault bool) (domain.TTSProvider, error) {
+	return piper.NewProviderFromConfig(cfg, isDefault)
+}
@@ -7,7 +7,7 @@ import (
 )
 
 func TestGetFactory_KnownProviders(t *testing.T) {
-	for _, name := range []string{"elevenlabs", "selfhosted", "gemini"} {
+	for _, name := range []string{"elevenlabs", "selfhosted", "gemini", "piper"} {
 		f, ok := GetFactory(name)
 		if !ok {
 			t.Errorf("GetFactory(%q) returned false", name)
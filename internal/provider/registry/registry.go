@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/provider/keyring"
 	"github.com/pako-tts/server/pkg/config"
 )
 
@@ -14,10 +15,29 @@ type Registry struct {
 	providers   map[string]domain.TTSProvider
 	defaultName string
 	order       []string // Preserve insertion order for List()
+	routing     *router
+	degradation *degradation // nil when degraded mode is off
+	fallbacks   map[string][]string
+	limits      map[string]textLimits
+
+	onQuotaWarning func(provider string, used, quota int64)
+	onDegradation  func(provider string, degraded bool, reason string)
+}
+
+// textLimits are a provider's per-request text limit and chunk parallelism.
+type textLimits struct {
+	maxLength int
+	parallel  int
 }
 
-// Ensure Registry implements ProviderRegistry.
-var _ domain.ProviderRegistry = (*Registry)(nil)
+// Ensure Registry implements ProviderRegistry and ProviderKeyManager.
+var (
+	_ domain.ProviderRegistry     = (*Registry)(nil)
+	_ domain.ProviderKeyManager   = (*Registry)(nil)
+	_ domain.ProviderFallbacks    = (*Registry)(nil)
+	_ domain.ProviderTextLimits   = (*Registry)(nil)
+	_ domain.ProviderDegradations = (*Registry)(nil)
+)
 
 // NewRegistry creates a new provider registry from configuration.
 func NewRegistry(cfg *config.ProvidersConfig) (*Registry, error) {
@@ -29,6 +49,17 @@ func NewRegistry(cfg *config.ProvidersConfig) (*Registry, error) {
 		providers:   make(map[string]domain.TTSProvider),
 		defaultName: cfg.Default,
 		order:       make([]string, 0, len(cfg.List)),
+		routing:     newRouter(cfg),
+		degradation: newDegradation(cfg.Degradation),
+		fallbacks:   make(map[string][]string),
+		limits:      make(map[string]textLimits),
+	}
+
+	switch r.routing.policy {
+	case config.RoutingPolicyPrimary, config.RoutingPolicyPrimaryWithFailover,
+		config.RoutingPolicyFastest, config.RoutingPolicyCheapest:
+	default:
+		return nil, fmt.Errorf("unknown routing policy: %q", r.routing.policy)
 	}
 
 	// Create providers from config
@@ -46,6 +77,13 @@ func NewRegistry(cfg *config.ProvidersConfig) (*Registry, error) {
 
 		r.providers[providerCfg.Name] = provider
 		r.order = append(r.order, providerCfg.Name)
+		if len(providerCfg.Fallback) > 0 {
+			r.fallbacks[providerCfg.Name] = providerCfg.Fallback
+		}
+		r.limits[providerCfg.Name] = textLimits{
+			maxLength: providerCfg.MaxTextLength,
+			parallel:  max(1, providerCfg.ParallelChunks),
+		}
 	}
 
 	// Verify default provider exists
@@ -65,6 +103,21 @@ func (r *Registry) Get(name string) (domain.TTSProvider, error) {
 	return provider, nil
 }
 
+// Fallbacks returns the configured fallback providers of name, in order.
+func (r *Registry) Fallbacks(name string) []string {
+	return r.fallbacks[name]
+}
+
+// MaxTextLength returns the configured max_text_length of name; 0 when unlimited.
+func (r *Registry) MaxTextLength(name string) int {
+	return r.limits[name].maxLength
+}
+
+// ParallelChunks returns the configured parallel_chunks of name, at least 1.
+func (r *Registry) ParallelChunks(name string) int {
+	return max(1, r.limits[name].parallel)
+}
+
 // Default returns the default provider.
 func (r *Registry) Default() domain.TTSProvider {
 	return r.providers[r.defaultName]
@@ -100,6 +153,67 @@ func (r *Registry) DefaultName() string {
 	return r.defaultName
 }
 
+// SetAPIKey replaces the primary upstream API key of a running provider, e.g. after
+// the secret store returned a rotated key. The secondary key is kept.
+func (r *Registry) SetAPIKey(name, apiKey string) error {
+	keys, err := r.keyring(name)
+	if err != nil {
+		return err
+	}
+	keys.SetPrimary(apiKey)
+	return nil
+}
+
+// SetAPIKeys replaces both upstream API keys of a running provider.
+func (r *Registry) SetAPIKeys(name, primary, secondary string) error {
+	keys, err := r.keyring(name)
+	if err != nil {
+		return err
+	}
+	keys.Set(primary, secondary)
+	return nil
+}
+
+// KeyStatus lists the key state of every provider that uses an API key.
+func (r *Registry) KeyStatus() []domain.ProviderKeyStatus {
+	result := make([]domain.ProviderKeyStatus, 0, len(r.order))
+	for _, name := range r.order {
+		if kp, ok := r.providers[name].(keyedProvider); ok {
+			status := kp.Keyring().Status()
+			status.Provider = name
+			result = append(result, status)
+		}
+	}
+	return result
+}
+
+// OnKeyFailover registers fn to be called whenever a provider switches to its
+// secondary key because the upstream rejected the primary.
+func (r *Registry) OnKeyFailover(fn func(provider string, cause error)) {
+	for _, name := range r.order {
+		if kp, ok := r.providers[name].(keyedProvider); ok {
+			kp.Keyring().OnFailover(func(cause error) { fn(name, cause) })
+		}
+	}
+}
+
+func (r *Registry) keyring(name string) (*keyring.Keyring, error) {
+	provider, ok := r.providers[name]
+	if !ok {
+		return nil, domain.ErrProviderNotFound.WithMessage(fmt.Sprintf("Provider %q not found", name))
+	}
+	kp, ok := provider.(keyedProvider)
+	if !ok {
+		return nil, domain.ErrValidation.WithMessage(fmt.Sprintf("Provider %q does not use an API key", name))
+	}
+	return kp.Keyring(), nil
+}
+
+// keyedProvider is implemented by providers whose upstream API keys can be swapped at runtime.
+type keyedProvider interface {
+	Keyring() *keyring.Keyring
+}
+
 // providerWithType is implemented by providers that expose a stable type identifier
 // independent of their user-configured name (e.g. "ElevenLabsProvider").
 type providerWithType interface {
@@ -0,0 +1,62 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/pkg/config"
+)
+
+func TestRegistry_SetAPIKey(t *testing.T) {
+	r, err := NewRegistry(&config.ProvidersConfig{
+		Default: "el",
+		List: []config.ProviderConfig{
+			{Name: "el", Type: "elevenlabs", APIKey: "old-key"},
+			{Name: "local", Type: "selfhosted", BaseURL: "http://localhost:8000"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+
+	if err := r.SetAPIKey("el", "new-key"); err != nil {
+		t.Errorf("expected key swap to succeed, got %v", err)
+	}
+	if err := r.SetAPIKey("local", "new-key"); err == nil {
+		t.Error("expected error for provider without an API key")
+	}
+	if err := r.SetAPIKey("missing", "new-key"); err == nil {
+		t.Error("expected error for unknown provider")
+	}
+
+	if err := r.SetAPIKeys("el", "primary", "secondary"); err != nil {
+		t.Fatalf("SetAPIKeys: %v", err)
+	}
+	status := r.KeyStatus()
+	if len(status) != 1 {
+		t.Fatalf("expected status for the keyed provider only, got %+v", status)
+	}
+	if status[0].Provider != "el" || status[0].ActiveKey != domain.KeyPrimary || !status[0].HasSecondary {
+		t.Errorf("unexpected status %+v", status[0])
+	}
+}
+
+func TestRegistry_Fallbacks(t *testing.T) {
+	r, err := NewRegistry(&config.ProvidersConfig{
+		Default: "el",
+		List: []config.ProviderConfig{
+			{Name: "el", Type: "elevenlabs", APIKey: "key", Fallback: []string{"local"}},
+			{Name: "local", Type: "selfhosted", BaseURL: "http://localhost:8000"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+
+	if got := r.Fallbacks("el"); len(got) != 1 || got[0] != "local" {
+		t.Errorf("expected el to fall back to local, got %q", got)
+	}
+	if got := r.Fallbacks("local"); got != nil {
+		t.Errorf("expected no fallbacks for local, got %q", got)
+	}
+}
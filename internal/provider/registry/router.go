@@ -0,0 +1,260 @@
+package registry
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/pkg/config"
+)
+
+const (
+	// ewmaAlpha weights the most recent observation in latency/error-rate averages.
+	ewmaAlpha = 0.2
+
+	// unhealthyCooldown is how long a provider whose error rate exceeds the threshold
+	// is skipped before routing probes it again.
+	unhealthyCooldown = 30 * time.Second
+
+	// quotaWarningRatio is the share of a provider's configured character quota
+	// whose use triggers a quota warning.
+	quotaWarningRatio = 0.8
+)
+
+// quotaReporter is implemented by providers that can report their remaining upstream
+// character quota (e.g. from a subscription endpoint).
+type quotaReporter interface {
+	RemainingChars(ctx context.Context) (int64, bool)
+}
+
+// billingCycleReporter is implemented by providers that know when their upstream
+// character count is reset. QuotaResetAt returns zero when it isn't known, and
+// must not wait on the network.
+type billingCycleReporter interface {
+	QuotaResetAt() time.Time
+}
+
+// providerScore holds live routing statistics for one provider.
+type providerScore struct {
+	latency     float64 // EWMA latency in seconds
+	errorRate   float64 // EWMA of failed (1) vs successful (0) calls
+	samples     int
+	charsUsed   int64
+	lastFailure time.Time
+	// cycleEnd is when the billing cycle charsUsed is counted in ends; zero when
+	// the provider reports none.
+	cycleEnd time.Time
+}
+
+// rollCycle starts counting charsUsed from zero once the billing cycle it was
+// counted in has ended, or the provider reports a later cycle end than the one
+// recorded, and records cycleEnd, the end the provider reports now.
+func (s *providerScore) rollCycle(now, cycleEnd time.Time) {
+	if !s.cycleEnd.IsZero() && (!now.Before(s.cycleEnd) || cycleEnd.After(s.cycleEnd)) {
+		s.charsUsed = 0
+		s.cycleEnd = time.Time{}
+	}
+	// A cycle end already past is what was reported before the reset
+	if s.cycleEnd.IsZero() && cycleEnd.After(now) {
+		s.cycleEnd = cycleEnd
+	}
+}
+
+// billingCycleEnd returns when the provider's current billing cycle ends, or zero
+// when it doesn't report one.
+func billingCycleEnd(provider domain.TTSProvider) time.Time {
+	if br, ok := provider.(billingCycleReporter); ok {
+		return br.QuotaResetAt()
+	}
+	return time.Time{}
+}
+
+// router tracks per-provider scores and applies the configured routing policy.
+type router struct {
+	mu           sync.Mutex
+	policy       string
+	maxErrorRate float64
+	scores       map[string]*providerScore
+	costs        map[string]float64
+	quotas       map[string]int64
+}
+
+func newRouter(cfg *config.ProvidersConfig) *router {
+	r := &router{
+		policy:       cfg.Routing.Policy,
+		maxErrorRate: cfg.Routing.MaxErrorRate,
+		scores:       make(map[string]*providerScore),
+		costs:        make(map[string]float64),
+		quotas:       make(map[string]int64),
+	}
+	if r.policy == "" {
+		r.policy = config.RoutingPolicyPrimary
+	}
+	if r.maxErrorRate <= 0 {
+		r.maxErrorRate = 0.5
+	}
+	for _, p := range cfg.List {
+		r.scores[p.Name] = &providerScore{}
+		r.costs[p.Name] = p.CostPer1KChars
+		r.quotas[p.Name] = p.CharQuota
+	}
+	return r
+}
+
+// observe folds one synthesis outcome into the provider's score. cycleEnd is
+// when the provider's billing cycle ends, or zero; character use counts from the
+// start of the cycle. It reports whether the call took the provider's character
+// use past quotaWarningRatio of its configured quota, with the use and the quota.
+func (r *router) observe(name string, textLength int, latency time.Duration, err error, cycleEnd time.Time) (warn bool, used, quota int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	score, ok := r.scores[name]
+	if !ok {
+		return false, 0, 0
+	}
+	score.rollCycle(time.Now(), cycleEnd)
+
+	failed := 0.0
+	if err != nil {
+		failed = 1.0
+		score.lastFailure = time.Now()
+	} else {
+		before := score.charsUsed
+		score.charsUsed += int64(textLength)
+		if quota = r.quotas[name]; quota > 0 {
+			threshold := int64(float64(quota) * quotaWarningRatio)
+			warn = before < threshold && score.charsUsed >= threshold
+		}
+	}
+
+	if score.samples == 0 {
+		score.latency = latency.Seconds()
+		score.errorRate = failed
+	} else {
+		score.latency = ewmaAlpha*latency.Seconds() + (1-ewmaAlpha)*score.latency
+		score.errorRate = ewmaAlpha*failed + (1-ewmaAlpha)*score.errorRate
+	}
+	score.samples++
+	return warn, score.charsUsed, quota
+}
+
+// healthy reports whether routing may send traffic to the provider. A provider over the
+// error-rate threshold is skipped until unhealthyCooldown has passed since its last failure.
+func (r *router) healthy(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	score := r.scores[name]
+	return score.errorRate <= r.maxErrorRate || time.Since(score.lastFailure) > unhealthyCooldown
+}
+
+// hasQuota reports whether the provider can afford textLength more characters, using
+// the live upstream quota when the provider reports one and the configured budget otherwise.
+// The configured budget is spent per billing cycle when the provider reports one.
+func (r *router) hasQuota(ctx context.Context, name string, provider domain.TTSProvider, textLength int) bool {
+	if q := r.quotas[name]; q > 0 {
+		cycleEnd := billingCycleEnd(provider)
+		r.mu.Lock()
+		score := r.scores[name]
+		score.rollCycle(time.Now(), cycleEnd)
+		used := score.charsUsed
+		r.mu.Unlock()
+
+		if used+int64(textLength) > q {
+			return false
+		}
+	}
+	// Queried outside the lock: reporters may call the upstream API.
+	if qr, ok := provider.(quotaReporter); ok {
+		if remaining, known := qr.RemainingChars(ctx); known && remaining < int64(textLength) {
+			return false
+		}
+	}
+	return true
+}
+
+// Route returns the name of the provider that should serve a request which doesn't
+// name one. A degraded provider's fallback is preferred when so configured.
+func (r *Registry) Route(ctx context.Context, textLength int) string {
+	return r.preferFallback(r.route(ctx, textLength))
+}
+
+// route applies the routing policy.
+func (r *Registry) route(ctx context.Context, textLength int) string {
+	if r.routing == nil || r.routing.policy == config.RoutingPolicyPrimary {
+		return r.defaultName
+	}
+
+	// Candidate order: default first, then registration order.
+	ordered := make([]string, 0, len(r.order))
+	ordered = append(ordered, r.defaultName)
+	for _, name := range r.order {
+		if name != r.defaultName {
+			ordered = append(ordered, name)
+		}
+	}
+
+	candidates := make([]string, 0, len(ordered))
+	for _, name := range ordered {
+		if r.routing.healthy(name) && r.routing.hasQuota(ctx, name, r.providers[name], textLength) {
+			candidates = append(candidates, name)
+		}
+	}
+	if len(candidates) == 0 {
+		return r.defaultName
+	}
+
+	r.routing.mu.Lock()
+	defer r.routing.mu.Unlock()
+
+	scores := r.routing.scores
+	switch r.routing.policy {
+	case config.RoutingPolicyFastest:
+		// Unobserved providers have zero latency and are tried first, which seeds their score.
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return scores[candidates[i]].latency < scores[candidates[j]].latency
+		})
+	case config.RoutingPolicyCheapest:
+		costs := r.routing.costs
+		sort.SliceStable(candidates, func(i, j int) bool {
+			ci, cj := costs[candidates[i]], costs[candidates[j]]
+			if ci != cj {
+				return ci < cj
+			}
+			return scores[candidates[i]].latency < scores[candidates[j]].latency
+		})
+	}
+	return candidates[0]
+}
+
+// Observe records the outcome of a synthesis call for routing decisions and
+// degraded mode.
+func (r *Registry) Observe(name string, textLength int, latency time.Duration, err error) {
+	if r.degradation != nil {
+		if entered, reason := r.degradation.observe(name, err); entered && r.onDegradation != nil {
+			r.onDegradation(name, true, reason)
+		}
+	}
+	if r.routing == nil {
+		return
+	}
+	// Use only counts towards a configured quota; the cycle end is read before
+	// taking the router's lock.
+	var cycleEnd time.Time
+	if r.routing.quotas[name] > 0 {
+		cycleEnd = billingCycleEnd(r.providers[name])
+	}
+	if warn, used, quota := r.routing.observe(name, textLength, latency, err, cycleEnd); warn && r.onQuotaWarning != nil {
+		r.onQuotaWarning(name, used, quota)
+	}
+}
+
+// OnQuotaWarning registers fn to be called when a provider's character use
+// crosses 80% of its configured quota, once per billing cycle for providers
+// that report one.
+func (r *Registry) OnQuotaWarning(fn func(provider string, used, quota int64)) {
+	r.onQuotaWarning = fn
+}
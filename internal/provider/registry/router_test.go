@@ -0,0 +1,228 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/pkg/config"
+)
+
+// stubProvider is a minimal domain.TTSProvider for routing tests.
+type stubProvider struct {
+	name      string
+	remaining int64
+	known     bool
+}
+
+func (p *stubProvider) Name() string { return p.name }
+func (p *stubProvider) Synthesize(ctx context.Context, req *domain.SynthesisRequest) (*domain.SynthesisResult, error) {
+	return nil, nil
+}
+func (p *stubProvider) ListVoices(ctx context.Context) ([]domain.Voice, error) { return nil, nil }
+func (p *stubProvider) ListModels(ctx context.Context) ([]domain.Model, error) { return nil, nil }
+func (p *stubProvider) IsAvailable(ctx context.Context) bool                   { return true }
+func (p *stubProvider) MaxConcurrent() int                                     { return 1 }
+func (p *stubProvider) ActiveJobs() int                                        { return 0 }
+func (p *stubProvider) Status(ctx context.Context) domain.ProviderStatus {
+	return domain.ProviderStatus{Name: p.name}
+}
+
+// quotaStubProvider additionally reports a live remaining quota.
+type quotaStubProvider struct {
+	stubProvider
+}
+
+func (p *quotaStubProvider) RemainingChars(ctx context.Context) (int64, bool) {
+	return p.remaining, p.known
+}
+
+// cycleStubProvider additionally reports when its billing cycle ends.
+type cycleStubProvider struct {
+	stubProvider
+	resetAt time.Time
+}
+
+func (p *cycleStubProvider) QuotaResetAt() time.Time { return p.resetAt }
+
+func newTestRegistry(policy string, providers ...config.ProviderConfig) *Registry {
+	cfg := &config.ProvidersConfig{
+		Default: providers[0].Name,
+		List:    providers,
+		Routing: config.RoutingConfig{Policy: policy},
+	}
+	r := &Registry{
+		providers:   make(map[string]domain.TTSProvider),
+		defaultName: cfg.Default,
+		routing:     newRouter(cfg),
+	}
+	for _, p := range providers {
+		r.providers[p.Name] = &stubProvider{name: p.Name}
+		r.order = append(r.order, p.Name)
+	}
+	return r
+}
+
+func TestRoute_PrimaryAlwaysReturnsDefault(t *testing.T) {
+	r := newTestRegistry(config.RoutingPolicyPrimary,
+		config.ProviderConfig{Name: "a"}, config.ProviderConfig{Name: "b"})
+
+	for i := 0; i < 5; i++ {
+		r.Observe("a", 10, time.Second, errors.New("boom"))
+	}
+
+	if got := r.Route(context.Background(), 10); got != "a" {
+		t.Errorf("expected default provider 'a', got %q", got)
+	}
+}
+
+func TestRoute_PrimaryWithFailover(t *testing.T) {
+	r := newTestRegistry(config.RoutingPolicyPrimaryWithFailover,
+		config.ProviderConfig{Name: "a"}, config.ProviderConfig{Name: "b"})
+
+	if got := r.Route(context.Background(), 10); got != "a" {
+		t.Fatalf("expected primary 'a' while healthy, got %q", got)
+	}
+
+	for i := 0; i < 5; i++ {
+		r.Observe("a", 10, time.Second, errors.New("boom"))
+	}
+
+	if got := r.Route(context.Background(), 10); got != "b" {
+		t.Errorf("expected failover to 'b', got %q", got)
+	}
+}
+
+func TestRoute_Fastest(t *testing.T) {
+	r := newTestRegistry(config.RoutingPolicyFastest,
+		config.ProviderConfig{Name: "slow"}, config.ProviderConfig{Name: "fast"})
+
+	r.Observe("slow", 10, 2*time.Second, nil)
+	r.Observe("fast", 10, 200*time.Millisecond, nil)
+
+	if got := r.Route(context.Background(), 10); got != "fast" {
+		t.Errorf("expected 'fast', got %q", got)
+	}
+}
+
+func TestRoute_Cheapest(t *testing.T) {
+	r := newTestRegistry(config.RoutingPolicyCheapest,
+		config.ProviderConfig{Name: "pricey", CostPer1KChars: 0.3},
+		config.ProviderConfig{Name: "cheap", CostPer1KChars: 0.05})
+
+	if got := r.Route(context.Background(), 10); got != "cheap" {
+		t.Errorf("expected 'cheap', got %q", got)
+	}
+}
+
+func TestRoute_SkipsProvidersOverConfiguredQuota(t *testing.T) {
+	r := newTestRegistry(config.RoutingPolicyCheapest,
+		config.ProviderConfig{Name: "pricey", CostPer1KChars: 0.3},
+		config.ProviderConfig{Name: "cheap", CostPer1KChars: 0.05, CharQuota: 100})
+
+	r.Observe("cheap", 90, time.Second, nil)
+
+	if got := r.Route(context.Background(), 20); got != "pricey" {
+		t.Errorf("expected 'pricey' once 'cheap' quota is exhausted, got %q", got)
+	}
+}
+
+func TestRoute_SkipsProvidersWithExhaustedLiveQuota(t *testing.T) {
+	r := newTestRegistry(config.RoutingPolicyPrimaryWithFailover,
+		config.ProviderConfig{Name: "a"}, config.ProviderConfig{Name: "b"})
+	r.providers["a"] = &quotaStubProvider{stubProvider{name: "a", remaining: 5, known: true}}
+
+	if got := r.Route(context.Background(), 10); got != "b" {
+		t.Errorf("expected 'b' when 'a' reports too little quota, got %q", got)
+	}
+}
+
+func TestObserve_WarnsOnceWhenQuotaMostlyUsed(t *testing.T) {
+	r := newTestRegistry(config.RoutingPolicyPrimary,
+		config.ProviderConfig{Name: "a", CharQuota: 100})
+
+	var warnings []int64
+	r.OnQuotaWarning(func(provider string, used, quota int64) {
+		if provider != "a" || quota != 100 {
+			t.Errorf("unexpected warning for %q with quota %d", provider, quota)
+		}
+		warnings = append(warnings, used)
+	})
+
+	r.Observe("a", 50, time.Second, nil)
+	r.Observe("a", 40, time.Second, errors.New("boom"))
+	if len(warnings) != 0 {
+		t.Fatalf("expected no warning below 80%%, got %v", warnings)
+	}
+	r.Observe("a", 35, time.Second, nil)
+	r.Observe("a", 10, time.Second, nil)
+	if len(warnings) != 1 || warnings[0] != 85 {
+		t.Errorf("expected one warning at 85 chars, got %v", warnings)
+	}
+}
+
+func TestRoute_ResetsConfiguredQuotaWithBillingCycle(t *testing.T) {
+	r := newTestRegistry(config.RoutingPolicyCheapest,
+		config.ProviderConfig{Name: "pricey", CostPer1KChars: 0.3},
+		config.ProviderConfig{Name: "cheap", CostPer1KChars: 0.05, CharQuota: 100})
+	cheap := &cycleStubProvider{stubProvider: stubProvider{name: "cheap"}, resetAt: time.Now().Add(time.Hour)}
+	r.providers["cheap"] = cheap
+
+	var warnings int
+	r.OnQuotaWarning(func(string, int64, int64) { warnings++ })
+
+	r.Observe("cheap", 90, time.Second, nil)
+	if got := r.Route(context.Background(), 20); got != "pricey" {
+		t.Fatalf("expected 'pricey' once 'cheap' quota is exhausted, got %q", got)
+	}
+
+	// The upstream account moved on to its next cycle
+	cheap.resetAt = time.Now().Add(30 * 24 * time.Hour)
+	if got := r.Route(context.Background(), 20); got != "cheap" {
+		t.Errorf("expected 'cheap' again in a new billing cycle, got %q", got)
+	}
+	r.Observe("cheap", 90, time.Second, nil)
+	if warnings != 2 {
+		t.Errorf("expected a warning in each cycle, got %d", warnings)
+	}
+}
+
+func TestProviderScore_RollCycle(t *testing.T) {
+	now := time.Now()
+	end := now.Add(time.Hour)
+	s := &providerScore{charsUsed: 50}
+
+	s.rollCycle(now, end)
+	if s.charsUsed != 50 || !s.cycleEnd.Equal(end) {
+		t.Fatalf("expected the first reported cycle end to be recorded, got %d chars until %v", s.charsUsed, s.cycleEnd)
+	}
+	s.rollCycle(now.Add(time.Minute), end)
+	if s.charsUsed != 50 {
+		t.Errorf("expected the use to be kept within the cycle, got %d", s.charsUsed)
+	}
+
+	// Past the end, before the provider reports the next one
+	s.rollCycle(end.Add(time.Second), end)
+	if s.charsUsed != 0 || !s.cycleEnd.IsZero() {
+		t.Errorf("expected the use to be reset at the end of the cycle, got %d chars until %v", s.charsUsed, s.cycleEnd)
+	}
+	s.charsUsed = 10
+	next := end.Add(30 * 24 * time.Hour)
+	s.rollCycle(end.Add(time.Minute), next)
+	if s.charsUsed != 10 || !s.cycleEnd.Equal(next) {
+		t.Errorf("expected the use since the reset to count towards the next cycle, got %d chars until %v", s.charsUsed, s.cycleEnd)
+	}
+}
+
+func TestNewRegistry_UnknownRoutingPolicy(t *testing.T) {
+	_, err := NewRegistry(&config.ProvidersConfig{
+		Default: "local",
+		List:    []config.ProviderConfig{{Name: "local", Type: "selfhosted", BaseURL: "http://localhost"}},
+		Routing: config.RoutingConfig{Policy: "random"},
+	})
+	if err == nil {
+		t.Fatal("expected error for unknown routing policy")
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"io"
 	"net/http"
 	"time"
+
+	"github.com/pako-tts/server/internal/deadline"
 )
 
 // Client is an HTTP client for the self-hosted TTS API.
@@ -32,31 +34,40 @@ func NewClient(baseURL string, ttsEndpoint, voicesEndpoint, healthEndpoint strin
 	}
 }
 
+// TTSResponse is the audio stream of a TTS request.
+type TTSResponse struct {
+	Audio       io.ReadCloser
+	ContentType string
+	// ContentLength is the announced size of Audio, -1 when unknown.
+	ContentLength int64
+}
+
 // TextToSpeech sends a TTS request and returns the audio stream.
-func (c *Client) TextToSpeech(ctx context.Context, req *SynthesisRequest) (io.ReadCloser, string, error) {
+func (c *Client) TextToSpeech(ctx context.Context, req *SynthesisRequest) (*TTSResponse, error) {
 	body, err := json.Marshal(req)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+c.ttsEndpoint, bytes.NewReader(body))
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
+	deadline.Set(httpReq)
 
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
-		return nil, "", fmt.Errorf("request failed: %w", err)
+		return nil, fmt.Errorf("request failed: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		defer resp.Body.Close() //nolint:errcheck
 		var errResp ErrorResponse
 		if decodeErr := json.NewDecoder(resp.Body).Decode(&errResp); decodeErr == nil && errResp.Detail != "" {
-			return nil, "", fmt.Errorf("TTS failed: %s", errResp.Detail)
+			return nil, fmt.Errorf("TTS failed: %s", errResp.Detail)
 		}
-		return nil, "", fmt.Errorf("TTS failed with status %d", resp.StatusCode)
+		return nil, fmt.Errorf("TTS failed with status %d", resp.StatusCode)
 	}
 
 	contentType := resp.Header.Get("Content-Type")
@@ -64,7 +75,7 @@ func (c *Client) TextToSpeech(ctx context.Context, req *SynthesisRequest) (io.Re
 		contentType = "audio/wav"
 	}
 
-	return resp.Body, contentType, nil
+	return &TTSResponse{Audio: resp.Body, ContentType: contentType, ContentLength: resp.ContentLength}, nil
 }
 
 // GetModels retrieves the list of available models (voices).
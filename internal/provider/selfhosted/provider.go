@@ -1,13 +1,12 @@
 package selfhosted
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"io"
 	"sync/atomic"
 	"time"
 
+	"github.com/pako-tts/server/internal/audio/bufpool"
 	"github.com/pako-tts/server/internal/domain"
 	"github.com/pako-tts/server/pkg/config"
 )
@@ -119,22 +118,22 @@ func (p *Provider) Synthesize(ctx context.Context, req *domain.SynthesisRequest)
 	}
 
 	// Call local TTS API
-	audioReader, contentType, err := p.client.TextToSpeech(ctx, ttsReq)
+	resp, err := p.client.TextToSpeech(ctx, ttsReq)
 	if err != nil {
 		return nil, err
 	}
 
 	// Read all audio data
-	audioData, err := io.ReadAll(audioReader)
-	audioReader.Close() //nolint:errcheck
+	audioData, err := bufpool.ReadAll(req.TrackDownload(resp.Audio, resp.ContentLength))
+	resp.Audio.Close() //nolint:errcheck
 	if err != nil {
 		return nil, err
 	}
 
 	return &domain.SynthesisResult{
-		Audio:       bytes.NewReader(audioData),
-		ContentType: contentType,
-		SizeBytes:   int64(len(audioData)),
+		Audio:       bufpool.NewReader(audioData),
+		ContentType: resp.ContentType,
+		SizeBytes:   int64(audioData.Len()),
 	}, nil
 }
 
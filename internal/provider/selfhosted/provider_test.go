@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 
 	"github.com/pako-tts/server/internal/domain"
@@ -152,3 +153,31 @@ func TestProvider_Synthesize_ForwardsLanguageCode(t *testing.T) {
 	}
 }
 
+
+func TestProvider_Synthesize_ReportsDownloadProgress(t *testing.T) {
+	audio := make([]byte, 64*1024)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/wav")
+		w.Header().Set("Content-Length", strconv.Itoa(len(audio)))
+		_, _ = w.Write(audio)
+	}))
+	defer srv.Close()
+
+	p, err := NewProviderFromConfig(config.ProviderConfig{Name: "local", BaseURL: srv.URL}, true)
+	if err != nil {
+		t.Fatalf("NewProviderFromConfig: %v", err)
+	}
+
+	var calls int
+	var received, total int64
+	req := &domain.SynthesisRequest{Text: "hi", Progress: func(r, t int64) {
+		calls++
+		received, total = r, t
+	}}
+	if _, err := p.Synthesize(context.Background(), req); err != nil {
+		t.Fatalf("Synthesize: %v", err)
+	}
+	if calls < 2 || received != int64(len(audio)) || total != int64(len(audio)) {
+		t.Errorf("expected progress up to %d of %d bytes, got %d of %d in %d calls", len(audio), len(audio), received, total, calls)
+	}
+}
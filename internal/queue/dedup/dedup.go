@@ -0,0 +1,120 @@
+// Package dedup detects the same job being submitted repeatedly within a short
+// window, without relying on client idempotency keys.
+package dedup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// Modes for handling a duplicate submission.
+const (
+	// ModeOff disables duplicate detection.
+	ModeOff = "off"
+	// ModeDetect enqueues the duplicate as usual and links it to the earlier job.
+	ModeDetect = "detect"
+	// ModeCoalesce returns the earlier job instead of enqueueing another synthesis.
+	ModeCoalesce = "coalesce"
+)
+
+// Index remembers recent submissions by fingerprint. It is safe for concurrent use.
+type Index struct {
+	mu        sync.Mutex
+	mode      string
+	window    time.Duration
+	entries   map[string]entry
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+type entry struct {
+	jobID string
+	at    time.Time
+}
+
+// New creates an index for mode. It returns nil when detection is off, which
+// callers treat as disabled.
+func New(mode string, window time.Duration) *Index {
+	if mode == "" || mode == ModeOff || window <= 0 {
+		return nil
+	}
+	return &Index{
+		mode:    mode,
+		window:  window,
+		entries: make(map[string]entry),
+		now:     time.Now,
+	}
+}
+
+// Mode returns ModeDetect or ModeCoalesce.
+func (i *Index) Mode() string {
+	return i.mode
+}
+
+// Fingerprint identifies a job by everything that affects its audio and who asked
+// for it: two jobs with the same fingerprint produce the same result.
+func Fingerprint(job *domain.Job) string {
+	b, _ := json.Marshal(struct {
+		Tenant        string                 `json:"t"`
+		Text          string                 `json:"x"`
+		VoiceID       string                 `json:"v"`
+		ModelID       string                 `json:"m"`
+		LanguageCode  string                 `json:"l"`
+		Provider      string                 `json:"p"`
+		OutputFormat  string                 `json:"f"`
+		VoiceSettings *domain.VoiceSettings  `json:"s"`
+		Padding       *domain.PaddingOptions `json:"d"`
+		Source        *domain.TextSource     `json:"src"`
+		Pipeline      []domain.PipelineStage `json:"pl,omitempty"`
+	}{
+		job.TenantID, job.Text, job.VoiceID, job.ModelID, job.LanguageCode,
+		job.ProviderName, job.OutputFormat, job.VoiceSettings, job.Padding, job.Source,
+		job.Pipeline,
+	})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Claim records jobID as the submission for key. If another job claimed key within
+// the window, nothing is recorded and that job's ID is returned with true.
+func (i *Index) Claim(key, jobID string) (string, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := i.now()
+	i.prune(now)
+	if e, ok := i.entries[key]; ok && now.Sub(e.at) < i.window {
+		return e.jobID, true
+	}
+	i.entries[key] = entry{jobID: jobID, at: now}
+	return "", false
+}
+
+// Release forgets key if jobID still holds it, e.g. because the job was never
+// enqueued or failed.
+func (i *Index) Release(key, jobID string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if e, ok := i.entries[key]; ok && e.jobID == jobID {
+		delete(i.entries, key)
+	}
+}
+
+// prune drops expired entries at most once per window. Callers hold i.mu.
+func (i *Index) prune(now time.Time) {
+	if now.Sub(i.lastPrune) < i.window {
+		return
+	}
+	i.lastPrune = now
+	for key, e := range i.entries {
+		if now.Sub(e.at) >= i.window {
+			delete(i.entries, key)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package dedup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+func TestNew_Disabled(t *testing.T) {
+	if New(ModeOff, time.Minute) != nil {
+		t.Error("expected nil index for mode off")
+	}
+	if New(ModeDetect, 0) != nil {
+		t.Error("expected nil index for zero window")
+	}
+}
+
+func TestIndex_Claim(t *testing.T) {
+	idx := New(ModeCoalesce, 10*time.Second)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	idx.now = func() time.Time { return now }
+
+	if _, dup := idx.Claim("k", "job-1"); dup {
+		t.Fatal("first claim reported as duplicate")
+	}
+
+	now = now.Add(5 * time.Second)
+	if id, dup := idx.Claim("k", "job-2"); !dup || id != "job-1" {
+		t.Fatalf("expected duplicate of job-1, got %q, %v", id, dup)
+	}
+
+	// The window runs from the first submission, not the latest duplicate.
+	now = now.Add(6 * time.Second)
+	if _, dup := idx.Claim("k", "job-3"); dup {
+		t.Fatal("claim after the window reported as duplicate")
+	}
+
+	idx.Release("k", "job-1") // stale holder: no effect
+	if id, dup := idx.Claim("k", "job-4"); !dup || id != "job-3" {
+		t.Fatalf("expected duplicate of job-3, got %q, %v", id, dup)
+	}
+	idx.Release("k", "job-3")
+	if _, dup := idx.Claim("k", "job-5"); dup {
+		t.Fatal("claim after release reported as duplicate")
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	a := domain.NewJob("Hello", "voice", "", "", "elevenlabs", "mp3", nil)
+	b := domain.NewJob("Hello", "voice", "", "", "elevenlabs", "mp3", nil)
+	if Fingerprint(a) != Fingerprint(b) {
+		t.Error("identical requests should share a fingerprint")
+	}
+
+	speed := 1.5
+	b.VoiceSettings = &domain.VoiceSettings{Speed: &speed}
+	if Fingerprint(a) == Fingerprint(b) {
+		t.Error("different voice settings should change the fingerprint")
+	}
+
+	b.VoiceSettings = nil
+	b.TenantID = "other"
+	if Fingerprint(a) == Fingerprint(b) {
+		t.Error("different tenants should not share a fingerprint")
+	}
+}
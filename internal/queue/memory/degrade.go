@@ -0,0 +1,119 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// degradeRefresh is how often the estimated completion of the jobs queued for
+// degraded providers is brought up to date.
+const degradeRefresh = 15 * time.Second
+
+// EstimateUpdater is implemented by sources that can set the estimated
+// completion of their queued jobs in place, without racing a worker that takes
+// one of them up.
+type EstimateUpdater interface {
+	// UpdateEstimates sets the estimated completion of each queued job of
+	// provider, oldest first, to what estimate returns, and returns how many
+	// jobs there were.
+	UpdateEstimates(ctx context.Context, provider string, estimate func(*domain.Job) *time.Time) (int, error)
+}
+
+// DegradeRateLimited makes workers use only share, in (0, 1], of the
+// concurrency of providers the registry put into degraded mode (see
+// domain.ProviderDegradations), or of the worker pool for a provider without a
+// limit of its own. While a provider is degraded, the estimated completion of
+// its jobs is stretched to match: a job taken up gets its own estimate divided by
+// share, and, when the source is an EstimateUpdater, its queued jobs get one
+// counting the jobs ahead of them at the reduced concurrency. The estimates of
+// queued jobs are dropped again once the provider recovers. It does nothing
+// unless the registry tracks degraded mode, and must be set before Start.
+func (w *Worker) DegradeRateLimited(share float64) {
+	degradations, ok := w.registry.(domain.ProviderDegradations)
+	if !ok || share <= 0 || share > 1 {
+		return
+	}
+	if w.limits == nil {
+		w.limits = newProviderLimits()
+		w.limits.enforce = false
+	}
+	w.limits.degraded = degradations.IsDegraded
+	w.limits.share = share
+	w.degradations = degradations
+}
+
+// degradedEstimate stretches the estimated duration of a job of provider while
+// the provider is degraded.
+func (w *Worker) degradedEstimate(provider string, estimate time.Duration) time.Duration {
+	if w.degradations == nil || !w.degradations.IsDegraded(provider) {
+		return estimate
+	}
+	return time.Duration(float64(estimate) / w.limits.share)
+}
+
+// watchDegradation keeps the estimated completion of the jobs queued for
+// degraded providers up to date every degradeRefresh until ctx is done, and
+// drops it once a provider recovers.
+func (w *Worker) watchDegradation(ctx context.Context, updater EstimateUpdater) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(degradeRefresh)
+	defer ticker.Stop()
+	degraded := make(map[string]bool)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current := make(map[string]bool)
+		for _, d := range w.degradations.Degraded() {
+			current[d.Provider] = true
+			w.estimateQueued(ctx, updater, d.Provider)
+		}
+		for provider := range degraded {
+			if current[provider] {
+				continue
+			}
+			if _, err := updater.UpdateEstimates(ctx, provider, func(*domain.Job) *time.Time { return nil }); err != nil && ctx.Err() == nil {
+				w.logger.Warn("Failed to clear estimates of queued jobs", zap.String("provider", provider), zap.Error(err))
+			}
+		}
+		degraded = current
+	}
+}
+
+// estimateQueued gives each job queued for the degraded provider an estimated
+// completion counting the jobs ahead of it, run at the provider's reduced
+// concurrency.
+func (w *Worker) estimateQueued(ctx context.Context, updater EstimateUpdater, provider string) {
+	concurrency := w.poolSize
+	if p, err := w.registry.Get(provider); err == nil {
+		concurrency = w.limits.limit(p)
+	}
+	concurrency = max(1, concurrency)
+
+	now := time.Now()
+	var ahead time.Duration
+	n, err := updater.UpdateEstimates(ctx, provider, func(job *domain.Job) *time.Time {
+		ahead += w.estimateDuration(len(job.Text))
+		at := now.Add(ahead / time.Duration(concurrency))
+		return &at
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			w.logger.Warn("Failed to estimate queued jobs", zap.String("provider", provider), zap.Error(err))
+		}
+		return
+	}
+	w.logger.Debug("Estimated queued jobs of degraded provider",
+		zap.String("provider", provider),
+		zap.Int("jobs", n),
+		zap.Int("concurrency", concurrency),
+	)
+}
@@ -0,0 +1,170 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// DequeueStrategy is how workers wait for their next job. Next returns the next
+// job filter takes (any job when filter is nil), waiting until there
+// is one, or nil once the source is closed. A strategy is shared by all the
+// workers of a Worker, so it must be safe for concurrent use.
+type DequeueStrategy interface {
+	Next(ctx context.Context, filter *domain.ProviderFilter) (*domain.Job, error)
+}
+
+// Poller is a job source that can look for a job without waiting, such as a
+// database table, so a polling strategy decides how often to look.
+type Poller interface {
+	// TryDequeueMatching leases the first pending job filter takes, or returns
+	// nil when there is none.
+	TryDequeueMatching(ctx context.Context, filter *domain.ProviderFilter) (*domain.Job, error)
+
+	// Closed reports whether the source was closed and hands out no more jobs.
+	Closed() bool
+}
+
+// BatchPoller is a Poller that can lease several jobs in one round trip.
+type BatchPoller interface {
+	Poller
+
+	// TryDequeueBatch leases up to n pending jobs filter takes, or returns none
+	// when there are none.
+	TryDequeueBatch(ctx context.Context, filter *domain.ProviderFilter, n int) ([]*domain.Job, error)
+}
+
+// Backoff is the wait between polls that found no job: Min after a poll found
+// one, doubling with every empty poll up to Max.
+type Backoff struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// Defaults for Backoff fields left zero.
+const (
+	defaultPollMin = 500 * time.Millisecond
+	defaultPollMax = 5 * time.Second
+)
+
+func (b Backoff) withDefaults() Backoff {
+	if b.Min <= 0 {
+		b.Min = defaultPollMin
+	}
+	if b.Max < b.Min {
+		b.Max = max(defaultPollMax, b.Min)
+	}
+	return b
+}
+
+// Blocking takes jobs with the source's own DequeueMatching. It suits sources
+// that wake waiting workers as soon as a job arrives, like Queue, and is the
+// default strategy of a Worker.
+func Blocking(source JobSource) DequeueStrategy {
+	return blocking{source: source}
+}
+
+type blocking struct {
+	source JobSource
+}
+
+func (s blocking) Next(ctx context.Context, filter *domain.ProviderFilter) (*domain.Job, error) {
+	return s.source.DequeueMatching(ctx, filter)
+}
+
+// Polling looks for a job with source's TryDequeueMatching, waiting between
+// empty polls as backoff says. An idle worker thus polls rarely, and a busy one
+// without delay.
+func Polling(source Poller, backoff Backoff) DequeueStrategy {
+	return &polling{source: source, backoff: backoff.withDefaults()}
+}
+
+type polling struct {
+	source  Poller
+	backoff Backoff
+}
+
+func (s *polling) Next(ctx context.Context, filter *domain.ProviderFilter) (*domain.Job, error) {
+	var job *domain.Job
+	err := s.backoff.poll(ctx, s.source, func() (bool, error) {
+		var err error
+		job, err = s.source.TryDequeueMatching(ctx, filter)
+		return job != nil, err
+	})
+	return job, err
+}
+
+// Batch polls like Polling, but leases up to size jobs per poll. Jobs beyond the
+// one the polling worker needs are kept for the next workers to ask, so fewer
+// round trips serve the same number of jobs. Kept jobs are leased, and are
+// redelivered if no worker takes them within the source's visibility timeout;
+// keep size at or below the number of workers.
+func Batch(source BatchPoller, size int, backoff Backoff) DequeueStrategy {
+	return &batch{source: source, size: max(size, 1), backoff: backoff.withDefaults()}
+}
+
+type batch struct {
+	source  BatchPoller
+	size    int
+	backoff Backoff
+
+	mu      sync.Mutex
+	pending []*domain.Job
+}
+
+func (s *batch) Next(ctx context.Context, filter *domain.ProviderFilter) (*domain.Job, error) {
+	var job *domain.Job
+	err := s.backoff.poll(ctx, s.source, func() (bool, error) {
+		if job = s.take(filter); job != nil {
+			return true, nil
+		}
+		jobs, err := s.source.TryDequeueBatch(ctx, filter, s.size)
+		if err != nil || len(jobs) == 0 {
+			return false, err
+		}
+		job = jobs[0]
+		s.mu.Lock()
+		s.pending = append(s.pending, jobs[1:]...)
+		s.mu.Unlock()
+		return true, nil
+	})
+	return job, err
+}
+
+// take removes and returns the first kept job filter takes.
+func (s *batch) take(filter *domain.ProviderFilter) *domain.Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, job := range s.pending {
+		if filter.Matches(job) {
+			s.pending = append(s.pending[:i], s.pending[i+1:]...)
+			return job
+		}
+	}
+	return nil
+}
+
+// poll calls try until it finds a job or fails, waiting between empty polls. It
+// returns nil without a job once source is closed.
+func (b Backoff) poll(ctx context.Context, source Poller, try func() (bool, error)) error {
+	wait := b.Min
+	for {
+		if source.Closed() {
+			return nil
+		}
+		if found, err := try(); found || err != nil {
+			return err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+		wait = min(wait*2, b.Max)
+	}
+}
@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// fakePoller hands out its pending jobs without waiting and counts the polls.
+type fakePoller struct {
+	mu      sync.Mutex
+	pending []*domain.Job
+	polls   int
+	closed  bool
+}
+
+func (p *fakePoller) TryDequeueMatching(ctx context.Context, filter *domain.ProviderFilter) (*domain.Job, error) {
+	jobs, err := p.TryDequeueBatch(ctx, filter, 1)
+	if len(jobs) == 0 {
+		return nil, err
+	}
+	return jobs[0], err
+}
+
+func (p *fakePoller) TryDequeueBatch(ctx context.Context, filter *domain.ProviderFilter, n int) ([]*domain.Job, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.polls++
+	var taken, rest []*domain.Job
+	for _, job := range p.pending {
+		if len(taken) < n && filter.Matches(job) {
+			taken = append(taken, job)
+		} else {
+			rest = append(rest, job)
+		}
+	}
+	p.pending = rest
+	return taken, nil
+}
+
+func (p *fakePoller) Closed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+func (p *fakePoller) add(jobs ...*domain.Job) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending = append(p.pending, jobs...)
+}
+
+func TestPolling_WaitsForJob(t *testing.T) {
+	source := &fakePoller{}
+	strategy := Polling(source, Backoff{Min: time.Millisecond, Max: 4 * time.Millisecond})
+
+	job := domain.NewJob("hello", "voice", "", "", "provider", "mp3", nil)
+	time.AfterFunc(30*time.Millisecond, func() { source.add(job) })
+
+	got, err := strategy.Next(context.Background(), nil)
+	if err != nil || got != job {
+		t.Fatalf("expected the job once added, got %v, %v", got, err)
+	}
+	// Backing off to 4ms, 30ms take well under 30 polls
+	if source.polls < 3 || source.polls > 20 {
+		t.Errorf("expected backed-off polling, got %d polls", source.polls)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := strategy.Next(ctx, nil); err != context.DeadlineExceeded {
+		t.Errorf("expected the context's error without a job, got %v", err)
+	}
+
+	source.closed = true
+	if got, err := strategy.Next(context.Background(), nil); got != nil || err != nil {
+		t.Errorf("expected nil from a closed source, got %v, %v", got, err)
+	}
+}
+
+func TestBatch_KeepsLeasedJobsForNextWorkers(t *testing.T) {
+	source := &fakePoller{}
+	var jobs []*domain.Job
+	for _, provider := range []string{"piper", "elevenlabs", "elevenlabs", "elevenlabs"} {
+		jobs = append(jobs, domain.NewJob("hello", "voice", "", "", provider, "mp3", nil))
+	}
+	source.add(jobs...)
+	strategy := Batch(source, 3, Backoff{Min: time.Millisecond, Max: time.Millisecond})
+	elevenlabs := &domain.ProviderFilter{Providers: []string{"elevenlabs"}}
+
+	// One poll leases three elevenlabs jobs; the next two are served from them
+	for i := 1; i <= 3; i++ {
+		got, err := strategy.Next(context.Background(), elevenlabs)
+		if err != nil || got != jobs[i] {
+			t.Fatalf("call %d: expected job %d, got %v, %v", i, i, got, err)
+		}
+	}
+	if source.polls != 1 {
+		t.Errorf("expected a single poll for three jobs, got %d", source.polls)
+	}
+
+	// Kept jobs only go to workers that accept them
+	got, err := strategy.Next(context.Background(), nil)
+	if err != nil || got != jobs[0] {
+		t.Errorf("expected the piper job, got %v, %v", got, err)
+	}
+}
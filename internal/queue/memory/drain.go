@@ -0,0 +1,200 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// errDrainDeadline is the cause of a job's context when the deadline of a drain
+// passed while the job was processing.
+var errDrainDeadline = errors.New("drain deadline passed")
+
+// drainPoll bounds how long a worker of a scale-down drain waits for a job
+// before checking whether any are left for it.
+const drainPoll = time.Second
+
+// drainState is how far the workers of a Worker got in stopping.
+type drainState struct {
+	mu             sync.Mutex
+	status         domain.DrainStatus
+	deadlinePassed bool
+	timer          *time.Timer
+
+	// takeCtx is cancelled when a drain starts, waking the workers waiting for a
+	// job; jobsCtx is cancelled with errDrainDeadline at the drain's deadline.
+	takeCtx    context.Context
+	stopTaking context.CancelFunc
+	jobsCtx    context.Context
+	abort      context.CancelCauseFunc
+
+	done         chan struct{}
+	finished     atomic.Int64
+	checkpointed atomic.Int64
+}
+
+func newDrainState() *drainState {
+	return &drainState{status: domain.DrainStatus{State: domain.DrainStateRunning}, done: make(chan struct{})}
+}
+
+// start derives the contexts workers take and process jobs with from ctx.
+func (d *drainState) start(ctx context.Context) {
+	d.takeCtx, d.stopTaking = context.WithCancel(ctx)
+	d.jobsCtx, d.abort = context.WithCancelCause(ctx)
+}
+
+// takeWork returns the context a worker of pool waits for its next job with, or
+// false when the worker should stop: ctx is done, the drain finishes in-progress
+// jobs only, or a scale-down drain has no jobs left for the pool. During a
+// scale-down drain, waiting is bounded by drainPoll so the worker notices when
+// none are left.
+func (d *drainState) takeWork(ctx context.Context, source JobSource, pool *workerPool) (context.Context, context.CancelFunc, bool) {
+	d.mu.Lock()
+	strategy, deadlinePassed := d.status.Strategy, d.deadlinePassed
+	d.mu.Unlock()
+
+	switch {
+	case ctx.Err() != nil:
+		return nil, nil, false
+	case strategy == "":
+		return d.takeCtx, func() {}, true
+	case strategy == domain.DrainScaleDown && !deadlinePassed && source.PendingMatching(pool.filter) > 0:
+		pollCtx, cancel := context.WithTimeout(ctx, drainPoll)
+		return pollCtx, cancel, true
+	}
+	return nil, nil, false
+}
+
+// jobContext returns the context jobs are processed with, which the drain
+// deadline cancels.
+func (d *drainState) jobContext(ctx context.Context) context.Context {
+	if d.jobsCtx == nil {
+		return ctx
+	}
+	return d.jobsCtx
+}
+
+// handled counts a job handled while draining.
+func (d *drainState) handled() {
+	d.mu.Lock()
+	draining := d.status.Strategy != ""
+	d.mu.Unlock()
+	if draining {
+		d.finished.Add(1)
+	}
+}
+
+// expire stops the drain from waiting: workers take no more jobs, and the jobs
+// in progress are interrupted to be checkpointed.
+func (d *drainState) expire() {
+	d.mu.Lock()
+	d.deadlinePassed = true
+	d.mu.Unlock()
+	d.stopTaking()
+	d.abort(errDrainDeadline)
+}
+
+// Drain stops the workers with strategy, checkpointing the jobs still in
+// progress after timeout (0 = none). It implements domain.Drainer.
+func (w *Worker) Drain(strategy string, timeout time.Duration) (domain.DrainStatus, error) {
+	d := w.drain
+	d.mu.Lock()
+	switch {
+	case d.status.Strategy == "":
+		now := time.Now().UTC()
+		d.status.State = domain.DrainStateDraining
+		d.status.StartedAt = &now
+	case d.status.Strategy == strategy || d.status.State == domain.DrainStateDrained:
+		d.mu.Unlock()
+		return w.DrainStatus(), nil
+	case d.status.Strategy == domain.DrainScaleDown && strategy == domain.DrainShutdown && d.status.State == domain.DrainStateDraining:
+	default:
+		d.mu.Unlock()
+		return domain.DrainStatus{}, domain.ErrDrainInProgress
+	}
+	first := d.status.Strategy == ""
+	d.status.Strategy = strategy
+	if timeout > 0 {
+		deadline := time.Now().Add(timeout).UTC()
+		if d.status.Deadline == nil || deadline.Before(*d.status.Deadline) {
+			d.status.Deadline = &deadline
+			if d.timer != nil {
+				d.timer.Stop()
+			}
+			if d.stopTaking != nil {
+				d.timer = time.AfterFunc(timeout, d.expire)
+			}
+		}
+	}
+	d.mu.Unlock()
+
+	w.logger.Info("Draining workers", zap.String("strategy", strategy), zap.Duration("timeout", timeout))
+	if !first {
+		return w.DrainStatus(), nil
+	}
+	if d.stopTaking != nil {
+		d.stopTaking()
+	}
+	go func() {
+		w.wg.Wait()
+		now := time.Now().UTC()
+		d.mu.Lock()
+		d.status.State = domain.DrainStateDrained
+		d.status.DrainedAt = &now
+		if d.timer != nil {
+			d.timer.Stop()
+		}
+		d.mu.Unlock()
+		close(d.done)
+		w.logger.Info("Workers drained",
+			zap.Int64("finished", d.finished.Load()),
+			zap.Int64("checkpointed", d.checkpointed.Load()),
+		)
+	}()
+	return w.DrainStatus(), nil
+}
+
+// DrainStatus reports the drain in progress, or that the workers are running. It
+// implements domain.Drainer.
+func (w *Worker) DrainStatus() domain.DrainStatus {
+	w.drain.mu.Lock()
+	status := w.drain.status
+	w.drain.mu.Unlock()
+
+	for _, p := range w.pools {
+		status.InFlight += int(p.busy.Load())
+		status.Queued += w.queue.PendingMatching(p.filter)
+	}
+	status.Finished = w.drain.finished.Load()
+	status.Checkpointed = w.drain.checkpointed.Load()
+	return status
+}
+
+// Drained returns a channel that is closed once a drain has finished. It
+// implements domain.Drainer.
+func (w *Worker) Drained() <-chan struct{} {
+	return w.drain.done
+}
+
+// checkpoint queues a job interrupted by the drain deadline again, for another
+// worker or instance to take it up.
+func (w *Worker) checkpoint(ctx context.Context, job *domain.Job, logger *zap.Logger) {
+	job.Checkpoint("interrupted by the deadline of a worker drain")
+	if err := w.queue.Ack(ctx, job.ID); err != nil {
+		logger.Warn("Failed to acknowledge job", zap.String("job_id", job.ID), zap.Error(err))
+	}
+	if err := w.queue.Enqueue(ctx, job); err != nil {
+		logger.Error("Failed to queue checkpointed job", zap.String("job_id", job.ID), zap.Error(err))
+		job.SetFailed("Failed to queue the job again after its worker drained: " + err.Error())
+		w.queue.UpdateJob(ctx, job) //nolint:errcheck
+		return
+	}
+	w.drain.checkpointed.Add(1)
+	logger.Info("Job checkpointed", zap.String("job_id", job.ID))
+}
@@ -0,0 +1,101 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+func TestWorker_ScaleDownDrainFinishesQueuedJobs(t *testing.T) {
+	queue := NewQueue(10)
+	worker := NewWorker(queue, &fakeRegistry{provider: newFakeProvider()}, &fakeStorage{}, zap.NewNop(), 24, 0, nil, nil, RetryPolicy{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var jobs []*domain.Job
+	for range 3 {
+		job := domain.NewJob("hello", "voice1", "", "", "fake-provider", "mp3", nil)
+		if err := queue.Enqueue(ctx, job); err != nil {
+			t.Fatalf("failed to enqueue job: %v", err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	worker.Start(ctx, 1)
+	defer worker.Stop()
+	if status, err := worker.Drain(domain.DrainScaleDown, 0); err != nil || status.State != domain.DrainStateDraining {
+		t.Fatalf("expected the drain started, got %+v, %v", status, err)
+	}
+
+	select {
+	case <-worker.Drained():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for the drain, status %+v", worker.DrainStatus())
+	}
+	for _, job := range jobs {
+		if stored, _ := queue.GetJob(ctx, job.ID); stored.Status != domain.JobStatusCompleted {
+			t.Errorf("expected job %s completed, got %s", job.ID, stored.Status)
+		}
+	}
+	if status := worker.DrainStatus(); status.State != domain.DrainStateDrained || status.Queued != 0 || status.DrainedAt == nil {
+		t.Errorf("expected a finished drain with nothing queued, got %+v", status)
+	}
+}
+
+func TestWorker_ShutdownDrainCheckpointsJobsAtDeadline(t *testing.T) {
+	queue := NewQueue(10)
+	provider := &blockingProvider{fakeProvider: *newFakeProvider(), started: make(chan struct{})}
+	worker := NewWorker(queue, &fakeRegistry{provider: provider}, &fakeStorage{}, zap.NewNop(), 24, 0, nil, nil, RetryPolicy{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	worker.Start(ctx, 1)
+	defer worker.Stop()
+
+	inFlight := domain.NewJob("hello", "voice1", "", "", "fake-provider", "mp3", nil)
+	if err := queue.Enqueue(ctx, inFlight); err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+	select {
+	case <-provider.started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for synthesis to start")
+	}
+	queued := domain.NewJob("later", "voice1", "", "", "fake-provider", "mp3", nil)
+	if err := queue.Enqueue(ctx, queued); err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+
+	status, err := worker.Drain(domain.DrainShutdown, 50*time.Millisecond)
+	if err != nil || status.Deadline == nil || status.InFlight != 1 {
+		t.Fatalf("expected the drain started with a job in flight, got %+v, %v", status, err)
+	}
+	if _, err := worker.Drain(domain.DrainScaleDown, 0); !errors.Is(err, domain.ErrDrainInProgress) {
+		t.Errorf("expected a scale-down during a shutdown drain refused, got %v", err)
+	}
+
+	select {
+	case <-worker.Drained():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for the drain, status %+v", worker.DrainStatus())
+	}
+	stored, _ := queue.GetJob(ctx, inFlight.ID)
+	checkpointed := slices.ContainsFunc(stored.Events, func(e domain.JobEvent) bool { return e.Type == domain.JobEventCheckpointed })
+	if stored.Status != domain.JobStatusQueued || stored.Attempts != 0 || !checkpointed {
+		t.Errorf("expected the in-flight job queued again without counting the attempt, got %s, %d attempts, %+v", stored.Status, stored.Attempts, stored.Events)
+	}
+	if stored, _ := queue.GetJob(ctx, queued.ID); stored.Status != domain.JobStatusQueued {
+		t.Errorf("expected the queued job left queued, got %s", stored.Status)
+	}
+	if status := worker.DrainStatus(); status.Checkpointed != 1 || status.Queued != 2 {
+		t.Errorf("expected one job checkpointed and two queued, got %+v", status)
+	}
+}
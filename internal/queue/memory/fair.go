@@ -0,0 +1,297 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// fairQueue holds pending jobs in per-tenant FIFOs. It serves the active tenant that
+// has been served the fewest characters, so tenants share throughput by work rather
+// than job count, and keeps the characters of processing jobs under a budget.
+// It is not safe for concurrent use; Queue guards it with its mutex.
+type fairQueue struct {
+	tenants    map[string]*tenantQueue
+	active     []string // tenants with pending jobs, in activation order
+	pendingLen int
+	running    map[string]runningJob // dequeued jobs by ID
+
+	charsInFlight int64
+	// reserved is the pending job that didn't fit the character budget. Other jobs
+	// only overtake it if they leave room for it, so it can't be starved.
+	reserved *pendingJob
+}
+
+type tenantQueue struct {
+	pending       []*pendingJob
+	inFlight      int
+	charsInFlight int64
+	dequeued      int64
+	served        int64 // characters dequeued, normalised on activation
+	maxWait       time.Duration
+}
+
+type pendingJob struct {
+	job        *domain.Job
+	tenant     string
+	cost       int64
+	enqueuedAt time.Time
+	deferred   bool
+}
+
+type runningJob struct {
+	tenant string
+	cost   int64
+}
+
+// limits bounds what pop may hand out; zero values mean no limit.
+type limits struct {
+	tenantMaxInFlight int
+	maxCharsInFlight  int64
+}
+
+func newFairQueue() fairQueue {
+	return fairQueue{
+		tenants: make(map[string]*tenantQueue),
+		running: make(map[string]runningJob),
+	}
+}
+
+// tenantOf returns the scheduling key of a job.
+func tenantOf(job *domain.Job) string {
+	if job.TenantID == "" {
+		return domain.DefaultTenant
+	}
+	return job.TenantID
+}
+
+// jobCost estimates the work of a job as its character count. A job whose text the
+// worker has yet to fetch from its source counts as 1.
+func jobCost(job *domain.Job) int64 {
+	if n := utf8.RuneCountInString(job.Text); n > 0 {
+		return int64(n)
+	}
+	return 1
+}
+
+// push appends job to its tenant's FIFO.
+func (f *fairQueue) push(job *domain.Job, l limits) {
+	name := tenantOf(job)
+	t, ok := f.tenants[name]
+	if !ok {
+		t = &tenantQueue{}
+		f.tenants[name] = t
+	}
+	if len(t.pending) == 0 {
+		// A tenant that was idle doesn't bank credit for the time it had nothing queued.
+		if minServed, ok := f.minServed(); ok && t.served < minServed {
+			t.served = minServed
+		}
+		f.active = append(f.active, name)
+	}
+
+	cost := jobCost(job)
+	if l.maxCharsInFlight > 0 && cost > l.maxCharsInFlight {
+		// Jobs larger than the budget run alone rather than never.
+		cost = l.maxCharsInFlight
+	}
+	t.pending = append(t.pending, &pendingJob{job: job, tenant: name, cost: cost, enqueuedAt: time.Now()})
+	f.pendingLen++
+	job.AddEvent(domain.JobEventQueued, fmt.Sprintf("queued for tenant %s (%d chars)", name, jobCost(job)))
+}
+
+// pop takes the next job to process, or returns nil when every pending job is held
+// back by the tenant in-flight cap or the character budget. When filter is set, jobs
+// it rejects are left for other callers; the budget reserved for them still holds.
+func (f *fairQueue) pop(l limits, filter *domain.ProviderFilter) *domain.Job {
+	if p := f.reserved; p != nil && filter.Matches(p.job) && f.eligible(p.tenant, l) && f.fits(p.cost, 0, l) {
+		return f.take(p)
+	}
+
+	var reservedCost int64
+	if f.reserved != nil {
+		reservedCost = f.reserved.cost
+	}
+	if l.maxCharsInFlight > 0 && f.charsInFlight > 0 && f.charsInFlight+reservedCost >= l.maxCharsInFlight {
+		return nil // budget exhausted; nothing can fit
+	}
+
+	for _, name := range f.byServed() {
+		if !f.eligible(name, l) {
+			continue
+		}
+		for _, p := range f.tenants[name].pending {
+			if p == f.reserved || !filter.Matches(p.job) {
+				continue
+			}
+			if f.fits(p.cost, reservedCost, l) {
+				return f.take(p)
+			}
+			f.hold(p, l)
+		}
+	}
+	return nil
+}
+
+// pendingMatching counts the pending jobs filter takes (all when filter is nil).
+func (f *fairQueue) pendingMatching(filter *domain.ProviderFilter) int {
+	if filter == nil {
+		return f.pendingLen
+	}
+	n := 0
+	for _, name := range f.active {
+		for _, p := range f.tenants[name].pending {
+			if filter.Matches(p.job) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// eligible reports whether tenant name may start another job.
+func (f *fairQueue) eligible(name string, l limits) bool {
+	return l.tenantMaxInFlight <= 0 || f.tenants[name].inFlight < l.tenantMaxInFlight
+}
+
+// fits reports whether a job of cost can start while holding back reserve chars.
+// With nothing in flight any job fits, since its cost is capped at the budget.
+func (f *fairQueue) fits(cost, reserve int64, l limits) bool {
+	if l.maxCharsInFlight <= 0 || f.charsInFlight == 0 && reserve == 0 {
+		return true
+	}
+	return f.charsInFlight+cost+reserve <= l.maxCharsInFlight
+}
+
+// hold records that p was passed over and reserves the budget for it if nobody
+// holds the reservation yet.
+func (f *fairQueue) hold(p *pendingJob, l limits) {
+	if f.reserved == nil {
+		f.reserved = p
+	}
+	if !p.deferred {
+		p.deferred = true
+		p.job.AddEvent(domain.JobEventDeferred, fmt.Sprintf("waiting for character budget (%d of %d chars in flight)",
+			f.charsInFlight, l.maxCharsInFlight))
+	}
+}
+
+// take removes p from its tenant's FIFO and accounts it as in flight.
+func (f *fairQueue) take(p *pendingJob) *domain.Job {
+	t := f.tenants[p.tenant]
+	f.unlink(p)
+
+	wait := time.Since(p.enqueuedAt)
+	if wait > t.maxWait {
+		t.maxWait = wait
+	}
+	t.inFlight++
+	t.dequeued++
+	t.served += p.cost
+	t.charsInFlight += p.cost
+	f.charsInFlight += p.cost
+	f.running[p.job.ID] = runningJob{tenant: p.tenant, cost: p.cost}
+
+	p.job.AddEvent(domain.JobEventDequeued, fmt.Sprintf("dequeued for tenant %s after %s (%d chars in flight)",
+		p.tenant, wait.Round(time.Millisecond), f.charsInFlight))
+	return p.job
+}
+
+// remove drops the pending job with jobID without running it. Reports whether
+// the job was pending.
+func (f *fairQueue) remove(jobID string) bool {
+	for _, name := range f.active {
+		for _, p := range f.tenants[name].pending {
+			if p.job.ID == jobID {
+				f.unlink(p)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// unlink removes p from its tenant's FIFO and the pending count.
+func (f *fairQueue) unlink(p *pendingJob) {
+	t := f.tenants[p.tenant]
+	for i, q := range t.pending {
+		if q == p {
+			t.pending = append(t.pending[:i], t.pending[i+1:]...)
+			break
+		}
+	}
+	if len(t.pending) == 0 {
+		for i, name := range f.active {
+			if name == p.tenant {
+				f.active = append(f.active[:i], f.active[i+1:]...)
+				break
+			}
+		}
+	}
+	if f.reserved == p {
+		f.reserved = nil
+	}
+	f.pendingLen--
+}
+
+// release frees the in-flight slot of a dequeued job. Reports whether it held one.
+func (f *fairQueue) release(jobID string) bool {
+	r, ok := f.running[jobID]
+	if !ok {
+		return false
+	}
+	delete(f.running, jobID)
+	f.charsInFlight -= r.cost
+	if t := f.tenants[r.tenant]; t != nil {
+		t.inFlight--
+		t.charsInFlight -= r.cost
+	}
+	return true
+}
+
+// byServed returns the active tenants ordered by characters served, oldest
+// activation first on ties.
+func (f *fairQueue) byServed() []string {
+	order := append([]string(nil), f.active...)
+	sort.SliceStable(order, func(i, j int) bool {
+		return f.tenants[order[i]].served < f.tenants[order[j]].served
+	})
+	return order
+}
+
+func (f *fairQueue) minServed() (int64, bool) {
+	if len(f.active) == 0 {
+		return 0, false
+	}
+	minServed := f.tenants[f.active[0]].served
+	for _, name := range f.active[1:] {
+		minServed = min(minServed, f.tenants[name].served)
+	}
+	return minServed, true
+}
+
+// tenantStats reports per-tenant backlog and wait times, sorted by tenant.
+func (f *fairQueue) tenantStats(now time.Time) []domain.TenantQueueStats {
+	stats := make([]domain.TenantQueueStats, 0, len(f.tenants))
+	for name, t := range f.tenants {
+		s := domain.TenantQueueStats{
+			Tenant:         name,
+			Queued:         len(t.pending),
+			InFlight:       t.inFlight,
+			CharsInFlight:  t.charsInFlight,
+			Dequeued:       t.dequeued,
+			MaxWaitSeconds: t.maxWait.Seconds(),
+		}
+		for _, p := range t.pending {
+			if wait := now.Sub(p.enqueuedAt).Seconds(); wait > s.OldestWaitSeconds {
+				s.OldestWaitSeconds = wait
+			}
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Tenant < stats[j].Tenant })
+	return stats
+}
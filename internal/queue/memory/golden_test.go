@@ -0,0 +1,159 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"os"
+	"sync"
+	"testing"
+	"time"
+	"unicode"
+
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/audio/quality"
+	"github.com/pako-tts/server/internal/audio/transcode"
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// goldenCase is an input of the golden corpus in testdata/golden.json and the
+// bounds its audio must stay within.
+type goldenCase struct {
+	Name     string                 `json:"name"`
+	Text     string                 `json:"text"`
+	Format   string                 `json:"format"`
+	Pipeline []domain.PipelineStage `json:"pipeline"`
+	// MaxTextLength makes the provider take at most this many characters per
+	// request, so the text is synthesized in chunks; 0 = no limit.
+	MaxTextLength int     `json:"max_text_length"`
+	MinSeconds    float64 `json:"min_seconds"`
+	MaxSeconds    float64 `json:"max_seconds"`
+	MinRMSDB      float64 `json:"min_rms_db"`
+	MaxRMSDB      float64 `json:"max_rms_db"`
+	MaxPeakDB     float64 `json:"max_peak_db"`
+}
+
+// Timing of toneProvider output.
+const (
+	toneSampleRate = 16000
+	toneAmplitude  = 0.3
+	toneLetter     = 60 * time.Millisecond // per letter or digit
+	tonePause      = 30 * time.Millisecond // per space or punctuation mark
+)
+
+// toneProvider stands in for a voice: it renders a tone per letter or digit and
+// silence per space or punctuation mark, and nothing for other symbols such as
+// emojis. Text lost or mangled on the way to the provider changes the duration
+// and level of the result.
+type toneProvider struct {
+	fakeProvider
+}
+
+func (p *toneProvider) Synthesize(ctx context.Context, req *domain.SynthesisRequest) (*domain.SynthesisResult, error) {
+	var pcm []byte
+	for _, r := range req.Text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			pcm = append(pcm, toneSamples(toneLetter)...)
+		case unicode.IsSpace(r) || unicode.IsPunct(r):
+			pcm = append(pcm, make([]byte, 2*samplesOf(tonePause))...)
+		}
+	}
+	audio := transcode.PCMToWAV(pcm, toneSampleRate, 1, 16)
+	return &domain.SynthesisResult{Audio: bytes.NewReader(audio), ContentType: "audio/wav", SizeBytes: int64(len(audio))}, nil
+}
+
+func samplesOf(d time.Duration) int {
+	return int(d * toneSampleRate / time.Second)
+}
+
+// toneSamples renders d of a 220 Hz sine as 16-bit PCM.
+func toneSamples(d time.Duration) []byte {
+	n := samplesOf(d)
+	out := make([]byte, 2*n)
+	for i := range n {
+		v := toneAmplitude * 32767 * math.Sin(2*math.Pi*220*float64(i)/toneSampleRate)
+		binary.LittleEndian.PutUint16(out[2*i:], uint16(int16(v)))
+	}
+	return out
+}
+
+// recordingStorage keeps the audio stored for each job.
+type recordingStorage struct {
+	fakeStorage
+	mu    sync.Mutex
+	audio map[string][]byte
+}
+
+func (s *recordingStorage) Store(ctx context.Context, jobID string, audio []byte, format string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.audio[jobID] = bytes.Clone(audio) // the worker reuses the buffer after the job
+	return s.fakeStorage.Store(ctx, jobID, audio, format)
+}
+
+// TestGolden runs the golden corpus through the worker against toneProvider and
+// holds each result to its bounds, so changes to text processing, chunking or
+// audio joining that drop text or damage the audio fail here.
+func TestGolden(t *testing.T) {
+	data, err := os.ReadFile("testdata/golden.json")
+	if err != nil {
+		t.Fatalf("read corpus: %v", err)
+	}
+	var corpus []goldenCase
+	if err := json.Unmarshal(data, &corpus); err != nil {
+		t.Fatalf("parse corpus: %v", err)
+	}
+
+	for _, tc := range corpus {
+		t.Run(tc.Name, func(t *testing.T) {
+			if tc.Format == "" {
+				tc.Format = "wav"
+			}
+			queue := &finishedQueue{Queue: NewQueue(10), finished: make(chan *domain.Job, 1)}
+			registry := &limitedRegistry{fakeRegistry: fakeRegistry{provider: &toneProvider{}}, maxLength: tc.MaxTextLength}
+			storage := &recordingStorage{audio: make(map[string][]byte)}
+			worker := NewWorker(queue, registry, storage, zap.NewNop(), 24, 0, nil, nil, RetryPolicy{})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			worker.Start(ctx, 1)
+			defer worker.Stop()
+
+			job := domain.NewJob(tc.Text, "voice1", "", "", "fake-provider", tc.Format, nil)
+			job.Pipeline = tc.Pipeline
+			if err := queue.Enqueue(ctx, job); err != nil {
+				t.Fatalf("failed to enqueue job: %v", err)
+			}
+			var stored *domain.Job
+			select {
+			case stored = <-queue.finished:
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for the job to finish")
+			}
+			if stored.Status != domain.JobStatusCompleted {
+				t.Fatalf("expected the job to complete, got %s: %s", stored.Status, stored.ErrorMessage)
+			}
+
+			storage.mu.Lock()
+			audio := storage.audio[job.ID]
+			storage.mu.Unlock()
+			report, err := quality.Measure(ctx, audio, tc.Format)
+			if err != nil {
+				t.Fatalf("result is not valid %s: %v", tc.Format, err)
+			}
+			if s := report.Duration.Seconds(); s < tc.MinSeconds || s > tc.MaxSeconds {
+				t.Errorf("duration %.3fs outside [%g, %g]", s, tc.MinSeconds, tc.MaxSeconds)
+			}
+			if report.RMSDB < tc.MinRMSDB || report.RMSDB > tc.MaxRMSDB {
+				t.Errorf("RMS %.2f dBFS outside [%g, %g]", report.RMSDB, tc.MinRMSDB, tc.MaxRMSDB)
+			}
+			if report.PeakDB > tc.MaxPeakDB {
+				t.Errorf("peak %.2f dBFS above %g", report.PeakDB, tc.MaxPeakDB)
+			}
+		})
+	}
+}
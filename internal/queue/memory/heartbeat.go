@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// LeaseRenewer is implemented by sources that lease the jobs they hand out.
+// Workers renew the lease of each job they process on every heartbeat, so a
+// provider call that outlasts the visibility timeout isn't taken for a dead
+// worker.
+type LeaseRenewer interface {
+	// RenewLease extends the lease of the dequeued job jobID by the visibility
+	// timeout. A job not leased is left alone.
+	RenewLease(ctx context.Context, jobID string) error
+}
+
+// Reaper is implemented by durable sources shared between instances. A job
+// whose worker died with its instance stays processing until a reaper returns
+// it to the queue.
+type Reaper interface {
+	// Reap queues again, or fails, the jobs whose lease expired, and returns how
+	// many there were.
+	Reap(ctx context.Context) (int, error)
+}
+
+// Heartbeat makes workers renew the lease of each job they process every
+// interval, when the source is a LeaseRenewer, and return the jobs of dead
+// workers to the queue as often, when it is a Reaper. Zero, the default, turns
+// both off; jobs then keep their leases only through progress updates. It
+// must be set before Start.
+func (w *Worker) Heartbeat(interval time.Duration) {
+	w.heartbeat = interval
+}
+
+// beat renews the lease of the job jobID every heartbeat until the returned
+// func is called.
+func (w *Worker) beat(ctx context.Context, jobID string, logger *zap.Logger) (stop func()) {
+	renewer, ok := w.queue.(LeaseRenewer)
+	if !ok || w.heartbeat <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(w.heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := renewer.RenewLease(ctx, jobID); err != nil && ctx.Err() == nil {
+					logger.Warn("Failed to renew job lease", zap.String("job_id", jobID), zap.Error(err))
+				}
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// reap returns the jobs of dead workers to the queue every heartbeat until ctx
+// is done.
+func (w *Worker) reap(ctx context.Context, reaper Reaper) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := reaper.Reap(ctx)
+			switch {
+			case err != nil && ctx.Err() == nil:
+				w.logger.Warn("Failed to reap expired job leases", zap.Error(err))
+			case n > 0:
+				w.logger.Info("Jobs of stopped workers returned to the queue", zap.Int("jobs", n))
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package memory
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pako-tts/server/internal/domain"
+	"go.uber.org/zap"
+)
+
+// slowProvider outlasts the visibility timeout of the tests below.
+type slowProvider struct {
+	fakeProvider
+	delay time.Duration
+	calls atomic.Int32
+}
+
+func (p *slowProvider) Synthesize(ctx context.Context, req *domain.SynthesisRequest) (*domain.SynthesisResult, error) {
+	p.calls.Add(1)
+	select {
+	case <-time.After(p.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return p.fakeProvider.Synthesize(ctx, req)
+}
+
+func TestWorker_HeartbeatKeepsSlowJobLeased(t *testing.T) {
+	queue := &finishedQueue{
+		Queue:    NewQueueWithOptions(10, Options{VisibilityTimeout: 50 * time.Millisecond}),
+		finished: make(chan *domain.Job, 1),
+	}
+	provider := &slowProvider{fakeProvider: *newFakeProvider(), delay: 200 * time.Millisecond}
+	worker := NewWorker(queue, &fakeRegistry{provider: provider}, &fakeStorage{}, zap.NewNop(), 24, 0, nil, nil, RetryPolicy{})
+	worker.Heartbeat(10 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	worker.Start(ctx, 2)
+	defer worker.Stop()
+
+	job := domain.NewJob("hello", "voice1", "", "", "fake-provider", "mp3", nil)
+	if err := queue.Enqueue(ctx, job); err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+
+	select {
+	case finished := <-queue.finished:
+		if finished.Status != domain.JobStatusCompleted {
+			t.Fatalf("expected the job completed, got %s: %s", finished.Status, finished.ErrorMessage)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the job")
+	}
+	if calls := provider.calls.Load(); calls != 1 {
+		t.Errorf("expected the job synthesized once, got %d calls", calls)
+	}
+	if stats := queue.Stats(); stats.RedeliveredJobs != 0 {
+		t.Errorf("expected no redelivery while the worker was alive, got %d", stats.RedeliveredJobs)
+	}
+}
+
+// reapingQueue counts the reaps of the worker.
+type reapingQueue struct {
+	*Queue
+	reaps atomic.Int32
+}
+
+func (q *reapingQueue) Reap(ctx context.Context) (int, error) {
+	q.reaps.Add(1)
+	return 0, nil
+}
+
+func TestWorker_ReapsWithHeartbeat(t *testing.T) {
+	queue := &reapingQueue{Queue: NewQueue(10)}
+	worker := NewWorker(queue, &fakeRegistry{provider: newFakeProvider()}, &fakeStorage{}, zap.NewNop(), 24, 0, nil, nil, RetryPolicy{})
+	worker.Heartbeat(5 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	worker.Start(ctx, 1)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for queue.reaps.Load() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected repeated reaps, got %d", queue.reaps.Load())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	worker.Stop()
+
+	// Without heartbeats nothing is reaped
+	idle := &reapingQueue{Queue: NewQueue(10)}
+	worker = NewWorker(idle, &fakeRegistry{provider: newFakeProvider()}, &fakeStorage{}, zap.NewNop(), 24, 0, nil, nil, RetryPolicy{})
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	worker.Start(ctx, 1)
+	time.Sleep(20 * time.Millisecond)
+	worker.Stop()
+	if reaps := idle.reaps.Load(); reaps != 0 {
+		t.Errorf("expected no reaps without heartbeats, got %d", reaps)
+	}
+}
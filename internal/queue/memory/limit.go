@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// limitRecheck bounds how long a synthesis call waits for a slot before the
+// provider's limit is read again: a provider may raise it without a slot
+// being released, e.g. once an upstream throttle or degraded mode ends.
+const limitRecheck = time.Second
+
+// providerLimits keeps the synthesis calls of a Worker to each provider within
+// the provider's MaxConcurrent, so a provider at its limit holds back its own
+// jobs only while the jobs of other providers keep processing.
+type providerLimits struct {
+	mu sync.Mutex
+	// enforce keeps calls within MaxConcurrent; without it only degraded
+	// providers are limited.
+	enforce bool
+	// degraded, when set, reports the providers in degraded mode, whose limit is
+	// cut to share of it, or of poolSize for a provider without one.
+	degraded func(name string) bool
+	share    float64
+	poolSize int
+	inFlight map[string]int
+	// released is closed, and replaced, whenever a slot is released.
+	released chan struct{}
+}
+
+func newProviderLimits() *providerLimits {
+	return &providerLimits{enforce: true, inFlight: make(map[string]int), released: make(chan struct{})}
+}
+
+// limit returns how many calls to provider may be in flight at once; zero or
+// less is no limit.
+func (l *providerLimits) limit(provider domain.TTSProvider) int {
+	limit := 0
+	if l.enforce {
+		limit = provider.MaxConcurrent()
+	}
+	if l.degraded != nil && l.degraded(provider.Name()) {
+		if limit <= 0 {
+			limit = l.poolSize
+		}
+		limit = max(1, int(float64(limit)*l.share))
+	}
+	return limit
+}
+
+// acquire waits until provider has a free slot and takes it, or returns ctx's
+// error. A MaxConcurrent of zero or less is no limit. The returned func
+// releases the slot; it is nil when an error is returned.
+func (l *providerLimits) acquire(ctx context.Context, provider domain.TTSProvider) (func(), error) {
+	name := provider.Name()
+	for {
+		limit := l.limit(provider)
+		l.mu.Lock()
+		if limit <= 0 || l.inFlight[name] < limit {
+			l.inFlight[name]++
+			l.mu.Unlock()
+			return func() { l.release(name) }, nil
+		}
+		released := l.released
+		l.mu.Unlock()
+
+		timer := time.NewTimer(limitRecheck)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-released:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+func (l *providerLimits) release(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight[name]--
+	close(l.released)
+	l.released = make(chan struct{})
+}
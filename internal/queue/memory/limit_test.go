@@ -0,0 +1,119 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// limitedProvider is a fakeProvider with its own name and concurrency limit.
+type limitedProvider struct {
+	fakeProvider
+	name  string
+	limit atomic.Int32
+}
+
+func newLimitedProvider(name string, limit int32) *limitedProvider {
+	p := &limitedProvider{fakeProvider: *newFakeProvider(), name: name}
+	p.limit.Store(limit)
+	return p
+}
+
+func (p *limitedProvider) Name() string       { return p.name }
+func (p *limitedProvider) MaxConcurrent() int { return int(p.limit.Load()) }
+
+func TestProviderLimits(t *testing.T) {
+	limits := newProviderLimits()
+	cloud, local := newLimitedProvider("cloud", 1), newLimitedProvider("local", 1)
+	ctx := context.Background()
+
+	releaseCloud, err := limits.acquire(ctx, cloud)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	acquired := make(chan func())
+	go func() {
+		release, err := limits.acquire(ctx, cloud)
+		if err != nil {
+			t.Errorf("acquire: %v", err)
+		}
+		acquired <- release
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("expected the second call to wait for the provider's only slot")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// Another provider isn't held up by the one at its limit
+	releaseLocal, err := limits.acquire(ctx, local)
+	if err != nil {
+		t.Fatalf("acquire other provider: %v", err)
+	}
+	releaseLocal()
+
+	releaseCloud()
+	select {
+	case release := <-acquired:
+		release()
+	case <-time.After(time.Second):
+		t.Fatal("expected the waiting call to take the released slot")
+	}
+
+	// A call waiting for a slot gives up with its context
+	releaseCloud, _ = limits.acquire(ctx, cloud)
+	defer releaseCloud()
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := limits.acquire(waitCtx, cloud); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the context's error, got %v", err)
+	}
+
+	// No limit at all
+	unlimited := newLimitedProvider("unlimited", 0)
+	for range 3 {
+		if _, err := limits.acquire(ctx, unlimited); err != nil {
+			t.Fatalf("acquire unlimited: %v", err)
+		}
+	}
+}
+
+func TestProviderLimits_Degraded(t *testing.T) {
+	limits := newProviderLimits()
+	limits.enforce = false
+	limits.poolSize = 6
+	limits.share = 0.5
+	degraded := map[string]bool{"cloud": true, "local": true}
+	limits.degraded = func(name string) bool { return degraded[name] }
+
+	cloud, local := newLimitedProvider("cloud", 4), newLimitedProvider("local", 0)
+	tests := []struct {
+		name     string
+		provider *limitedProvider
+		enforce  bool
+		degraded bool
+		want     int
+	}{
+		{"not enforced", cloud, false, false, 0},
+		{"enforced", cloud, true, false, 4},
+		{"degraded", cloud, true, true, 2},
+		{"degraded without enforcing limits", cloud, false, true, 3},
+		{"degraded without a limit", local, true, true, 3},
+	}
+	for _, tt := range tests {
+		limits.enforce = tt.enforce
+		degraded[tt.provider.name] = tt.degraded
+		if got := limits.limit(tt.provider); got != tt.want {
+			t.Errorf("%s: expected limit %d, got %d", tt.name, tt.want, got)
+		}
+	}
+
+	// A degraded limit never drops below one call
+	limits.share = 0.1
+	if got := limits.limit(cloud); got != 1 {
+		t.Errorf("expected a degraded limit of at least 1, got %d", got)
+	}
+}
@@ -0,0 +1,101 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// persistedJobs is the file Persist writes and Restore reads.
+type persistedJobs struct {
+	Jobs []*domain.Job `json:"jobs"`
+}
+
+// Persist writes the jobs not yet finished, pending or waiting for a retry, to
+// path, so a later Restore can queue them again instead of losing them with the
+// process. Workers must be stopped first. Nothing is written, and an earlier
+// file is removed, when no job is left. Returns the number of jobs written.
+func (q *Queue) Persist(path string) (int, error) {
+	q.mu.RLock()
+	var jobs []*domain.Job
+	for _, job := range q.jobs {
+		if job.Status == domain.JobStatusQueued || job.Status == domain.JobStatusProcessing {
+			jobs = append(jobs, job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.Before(jobs[j].CreatedAt) })
+	data, err := json.Marshal(persistedJobs{Jobs: jobs})
+	q.mu.RUnlock()
+	if err != nil {
+		return 0, fmt.Errorf("encode jobs: %w", err)
+	}
+
+	if len(jobs) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return 0, err
+		}
+		return 0, nil
+	}
+	// Written next to path and renamed, so a crash mid-write leaves no partial file
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close() //nolint:errcheck
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, err
+	}
+	return len(jobs), nil
+}
+
+// Restore queues the jobs Persist wrote to path again, in submission order, and
+// removes the file. A job that was processing is checkpointed first, and one
+// waiting for a retry is taken up at once. Jobs that no longer fit the queue
+// fail instead, so their clients learn of it. A missing file restores nothing.
+// Returns the number of jobs queued.
+func (q *Queue) Restore(ctx context.Context, path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var persisted persistedJobs
+	if err := json.Unmarshal(data, &persisted); err != nil {
+		return 0, fmt.Errorf("decode %s: %w", path, err)
+	}
+
+	restored := 0
+	for _, job := range persisted.Jobs {
+		if job.Status == domain.JobStatusProcessing {
+			job.Checkpoint("interrupted by a shutdown")
+		}
+		job.NextAttemptAt = nil
+		job.AddEvent(domain.JobEventRestored, "queued again after a restart")
+		if err := q.Enqueue(ctx, job); err != nil {
+			job.SetFailed("Failed to queue the job again after a restart: " + err.Error())
+			q.mu.Lock()
+			q.jobs[job.ID] = job
+			q.indexJob(job)
+			q.mu.Unlock()
+			continue
+		}
+		restored++
+	}
+	return restored, os.Remove(path)
+}
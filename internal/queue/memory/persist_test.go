@@ -0,0 +1,99 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+func TestQueue_PersistAndRestore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "queue.json")
+
+	queue := NewQueue(10)
+	first := domain.NewJob("first", "voice1", "", "", "fake-provider", "mp3", nil)
+	second := domain.NewJob("second", "voice1", "", "", "fake-provider", "mp3", nil)
+	second.CreatedAt = first.CreatedAt.Add(time.Second)
+	retrying := domain.NewJob("retrying", "voice1", "", "", "fake-provider", "mp3", nil)
+	retrying.CreatedAt = first.CreatedAt.Add(2 * time.Second)
+	done := domain.NewJob("done", "voice1", "", "", "fake-provider", "mp3", nil)
+	for _, job := range []*domain.Job{first, second, retrying, done} {
+		if err := queue.Enqueue(ctx, job); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+	// A job waiting for a retry is held by the queue without being pending
+	dequeued, _ := queue.Dequeue(ctx)
+	dequeued.SetProcessing()
+	done.SetCompleted("/tmp/done.mp3", 24)
+	for range 2 {
+		queue.Dequeue(ctx) //nolint:errcheck
+	}
+	retrying.SetRetrying(time.Now().Add(time.Hour))
+
+	persisted, err := queue.Persist(path)
+	if err != nil || persisted != 3 {
+		t.Fatalf("expected 3 unfinished jobs persisted, got %d, %v", persisted, err)
+	}
+
+	restarted := NewQueue(10)
+	restored, err := restarted.Restore(ctx, path)
+	if err != nil || restored != 3 {
+		t.Fatalf("expected 3 jobs restored, got %d, %v", restored, err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected the file removed once restored, got %v", err)
+	}
+	for _, want := range []string{first.ID, second.ID, retrying.ID} {
+		job, err := restarted.Dequeue(ctx)
+		if err != nil || job.ID != want {
+			t.Fatalf("expected %s restored in submission order, got %v, %v", want, job, err)
+		}
+		if job.Status != domain.JobStatusQueued || job.NextAttemptAt != nil {
+			t.Errorf("expected %s queued to be attempted at once, got %s at %v", job.ID, job.Status, job.NextAttemptAt)
+		}
+		if !slices.ContainsFunc(job.Events, func(e domain.JobEvent) bool { return e.Type == domain.JobEventRestored }) {
+			t.Errorf("expected a restored event, got %v", job.Events)
+		}
+	}
+	if _, err := restarted.GetJob(ctx, done.ID); !errors.Is(err, domain.ErrJobNotFound) {
+		t.Errorf("expected the finished job left out, got %v", err)
+	}
+
+	// With nothing left to persist no file is written
+	if persisted, err := NewQueue(10).Persist(path); err != nil || persisted != 0 {
+		t.Errorf("expected nothing persisted, got %d, %v", persisted, err)
+	}
+	if restored, err := NewQueue(10).Restore(ctx, path); err != nil || restored != 0 {
+		t.Errorf("expected nothing restored without a file, got %d, %v", restored, err)
+	}
+}
+
+func TestQueue_RestoreFailsJobsThatDontFit(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "queue.json")
+
+	queue := NewQueue(10)
+	for _, text := range []string{"one", "two"} {
+		queue.Enqueue(ctx, domain.NewJob(text, "voice1", "", "", "fake-provider", "mp3", nil)) //nolint:errcheck
+	}
+	if _, err := queue.Persist(path); err != nil {
+		t.Fatalf("persist: %v", err)
+	}
+
+	restarted := NewQueue(1)
+	if restored, err := restarted.Restore(ctx, path); err != nil || restored != 1 {
+		t.Fatalf("expected 1 job restored, got %d, %v", restored, err)
+	}
+	page, _ := restarted.ListJobs(ctx, domain.JobFilter{Status: domain.JobStatusFailed})
+	if len(page.Jobs) != 1 {
+		t.Errorf("expected the job that didn't fit failed, got %d failed jobs", len(page.Jobs))
+	}
+}
@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"maps"
+	"slices"
+	"sort"
+	"sync/atomic"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// DefaultPool is the name of the pool serving providers no pool is pinned to.
+const DefaultPool = "default"
+
+// Pool is a set of workers pinned to some providers, so slow synthesis on one
+// provider never occupies the workers of another.
+type Pool struct {
+	Name      string
+	Workers   int
+	Providers []string
+}
+
+// workerPool is a running Pool with its counters.
+type workerPool struct {
+	Pool
+	filter    *domain.ProviderFilter
+	busy      atomic.Int64
+	completed atomic.Int64
+	failed    atomic.Int64
+}
+
+// buildPools returns the pinned pools followed by the default pool of numWorkers,
+// which takes jobs for every provider not pinned elsewhere.
+func buildPools(numWorkers int, pinned []Pool) []*workerPool {
+	taken := make(map[string]bool)
+	pools := make([]*workerPool, 0, len(pinned)+1)
+	for _, p := range pinned {
+		providers := p.Providers
+		for _, name := range providers {
+			taken[name] = true
+		}
+		pools = append(pools, &workerPool{
+			Pool:   p,
+			filter: &domain.ProviderFilter{Providers: providers},
+		})
+	}
+
+	general := &workerPool{Pool: Pool{Name: DefaultPool, Workers: numWorkers}}
+	if len(taken) > 0 {
+		general.filter = &domain.ProviderFilter{Providers: slices.Sorted(maps.Keys(taken)), Exclude: true}
+	}
+	return append(pools, general)
+}
+
+// record counts the outcome of a job the pool processed. Jobs put back for a retry
+// count as neither.
+func (p *workerPool) record(job *domain.Job) {
+	switch job.Status {
+	case domain.JobStatusCompleted:
+		p.completed.Add(1)
+	case domain.JobStatusFailed:
+		p.failed.Add(1)
+	}
+}
+
+// PoolStats reports each worker pool's size, load and backlog, sorted by name.
+// It implements domain.WorkerPools.
+func (w *Worker) PoolStats() []domain.WorkerPoolStats {
+	stats := make([]domain.WorkerPoolStats, 0, len(w.pools))
+	for _, p := range w.pools {
+		s := domain.WorkerPoolStats{
+			Name:      p.Name,
+			Workers:   p.Workers,
+			Busy:      int(p.busy.Load()),
+			Providers: p.Providers,
+			Queued:    w.queue.PendingMatching(p.filter),
+			Completed: p.completed.Load(),
+			Failed:    p.failed.Load(),
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
@@ -3,56 +3,302 @@ package memory
 
 import (
 	"context"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/search"
 )
 
-// Queue is an in-memory implementation of domain.JobQueue.
+const (
+	// DefaultEnqueueWait is how long Enqueue waits for buffer space before giving up.
+	DefaultEnqueueWait = 200 * time.Millisecond
+
+	// DefaultVisibilityTimeout is how long a dequeued job may go without an update or
+	// acknowledgement before it is redelivered.
+	DefaultVisibilityTimeout = 10 * time.Minute
+
+	// DefaultMaxDeliveries is how often a job is delivered before an unacknowledged
+	// job is failed instead of redelivered.
+	DefaultMaxDeliveries = 3
+)
+
+// Options tunes an in-memory queue.
+type Options struct {
+	// EnqueueWait bounds how long Enqueue waits for buffer space (0 = don't wait)
+	// before returning domain.ErrQueueBusy.
+	EnqueueWait time.Duration
+	// TenantMaxInFlight caps how many jobs of one tenant may be processing at once;
+	// 0 means no cap.
+	TenantMaxInFlight int
+	// MaxCharsInFlight caps the total text length of processing jobs, so a few
+	// book-length jobs can't occupy every worker; 0 means no cap.
+	MaxCharsInFlight int
+	// VisibilityTimeout is how long a dequeued job may go without an update or Ack
+	// before it is queued again; 0 never redelivers.
+	VisibilityTimeout time.Duration
+	// MaxDeliveries fails a job instead of redelivering it once it has been
+	// delivered this often without an Ack; 0 means no limit.
+	MaxDeliveries int
+	// Search indexes the text and tags of the jobs held, for SearchJobs.
+	Search bool
+}
+
+// Queue is an in-memory implementation of domain.JobQueue. Pending jobs are kept in
+// per-tenant sub-queues and tenants are served in proportion to the characters they
+// have had processed, so one tenant's backlog can't starve the others.
 type Queue struct {
-	mu      sync.RWMutex
-	jobs    map[string]*domain.Job
-	pending chan *domain.Job
-	closed  bool
+	mu       sync.RWMutex
+	jobs     map[string]*domain.Job
+	archive  map[string]*domain.ArchivedJob
+	capacity int
+	closed   bool
+	opts     Options
+
+	fairQueue
+
+	// leases holds the visibility deadline of each dequeued, unacknowledged job.
+	leases      map[string]time.Time
+	redelivered int64
+	// cancels holds a channel per dequeued job, closed when its cancellation is requested.
+	cancels map[string]chan struct{}
+
+	// changed is closed and replaced whenever pending jobs or in-flight counts change,
+	// waking blocked Enqueue and Dequeue calls.
+	changed chan struct{}
+
+	// index holds the words of the jobs held when Options.Search is set.
+	index *search.Index
 }
 
 // NewQueue creates a new in-memory job queue.
 func NewQueue(bufferSize int) *Queue {
-	return &Queue{
-		jobs:    make(map[string]*domain.Job),
-		pending: make(chan *domain.Job, bufferSize),
+	return NewQueueWithOptions(bufferSize, Options{
+		EnqueueWait:       DefaultEnqueueWait,
+		VisibilityTimeout: DefaultVisibilityTimeout,
+		MaxDeliveries:     DefaultMaxDeliveries,
+	})
+}
+
+// NewQueueWithOptions creates a queue holding at most bufferSize pending jobs.
+func NewQueueWithOptions(bufferSize int, opts Options) *Queue {
+	q := &Queue{
+		jobs:      make(map[string]*domain.Job),
+		archive:   make(map[string]*domain.ArchivedJob),
+		capacity:  bufferSize,
+		opts:      opts,
+		fairQueue: newFairQueue(),
+		leases:    make(map[string]time.Time),
+		cancels:   make(map[string]chan struct{}),
+		changed:   make(chan struct{}),
+	}
+	if opts.Search {
+		q.index = search.NewIndex()
 	}
+	return q
 }
 
-// Enqueue adds a job to the queue for processing.
+// Enqueue adds a job to the queue for processing. When the buffer is full it waits
+// up to the configured enqueue wait, independent of ctx's own deadline, and then
+// returns domain.ErrQueueBusy; a job that was new to the queue is forgotten again.
 func (q *Queue) Enqueue(ctx context.Context, job *domain.Job) error {
 	q.mu.Lock()
 	if q.closed {
 		q.mu.Unlock()
 		return context.Canceled
 	}
+	_, existed := q.jobs[job.ID]
 	q.jobs[job.ID] = job
-	q.mu.Unlock()
+	q.indexJob(job)
 
-	select {
-	case q.pending <- job:
-		return nil
-	case <-ctx.Done():
-		return ctx.Err()
+	var timer *time.Timer
+	var err error
+	for {
+		if q.pendingLen < q.capacity {
+			q.push(job, q.limits())
+			q.broadcast()
+			q.mu.Unlock()
+			if timer != nil {
+				timer.Stop()
+			}
+			return nil
+		}
+		if q.opts.EnqueueWait <= 0 {
+			err = domain.ErrQueueBusy
+			break
+		}
+		if timer == nil {
+			timer = time.NewTimer(q.opts.EnqueueWait)
+		}
+
+		changed := q.changed
+		q.mu.Unlock()
+		select {
+		case <-changed:
+		case <-timer.C:
+			err = domain.ErrQueueBusy
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		q.mu.Lock()
+
+		if err == nil && q.closed {
+			err = context.Canceled
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	if !existed {
+		delete(q.jobs, job.ID)
+		q.unindexJob(job.ID)
+	}
+	q.mu.Unlock()
+	if timer != nil {
+		timer.Stop()
 	}
+	return err
 }
 
-// Dequeue retrieves the next job for processing.
+// Dequeue retrieves the next job for processing, skipping tenants at their in-flight
+// cap and jobs that don't fit the character budget. Returns nil once the queue is
+// closed and drained.
 func (q *Queue) Dequeue(ctx context.Context) (*domain.Job, error) {
-	select {
-	case job, ok := <-q.pending:
-		if !ok {
+	return q.DequeueMatching(ctx, nil)
+}
+
+// DequeueMatching is Dequeue restricted to the jobs filter takes; the rest stay
+// queued for other callers. A nil filter takes any job. Returns nil once the queue
+// is closed and no matching job is left.
+func (q *Queue) DequeueMatching(ctx context.Context, filter *domain.ProviderFilter) (*domain.Job, error) {
+	q.mu.Lock()
+	for {
+		next := q.redeliverExpired(time.Now())
+		if job := q.pop(q.limits(), filter); job != nil {
+			if q.opts.VisibilityTimeout > 0 {
+				q.leases[job.ID] = time.Now().Add(q.opts.VisibilityTimeout)
+			}
+			q.cancels[job.ID] = make(chan struct{})
+			q.broadcast()
+			q.mu.Unlock()
+			return job, nil
+		}
+		if q.closed && q.pendingMatching(filter) == 0 {
+			q.mu.Unlock()
 			return nil, nil
 		}
-		return job, nil
-	case <-ctx.Done():
-		return nil, ctx.Err()
+
+		changed := q.changed
+		q.mu.Unlock()
+
+		// Wake up for the next lease to expire, as its job may be ours to take.
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if !next.IsZero() {
+			timer = time.NewTimer(time.Until(next))
+			expired = timer.C
+		}
+		var err error
+		select {
+		case <-changed:
+		case <-expired:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return nil, err
+		}
+		q.mu.Lock()
+	}
+}
+
+// Ack ends the lease of a dequeued job so it is not redelivered.
+func (q *Queue) Ack(ctx context.Context, jobID string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.jobs[jobID]; !ok {
+		return domain.ErrJobNotFound
+	}
+	delete(q.leases, jobID)
+	return nil
+}
+
+// RenewLease extends the lease of the dequeued job jobID by the visibility
+// timeout. A job not leased, e.g. already acknowledged, is left alone.
+func (q *Queue) RenewLease(ctx context.Context, jobID string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, leased := q.leases[jobID]; leased {
+		q.leases[jobID] = time.Now().Add(q.opts.VisibilityTimeout)
+	}
+	return nil
+}
+
+// UpdateEstimates sets the estimated completion of each queued job of provider,
+// oldest first, to what estimate returns, and returns how many jobs there were.
+func (q *Queue) UpdateEstimates(ctx context.Context, provider string, estimate func(*domain.Job) *time.Time) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var queued []*domain.Job
+	for _, job := range q.jobs {
+		if job.Status == domain.JobStatusQueued && job.ProviderName == provider {
+			queued = append(queued, job)
+		}
+	}
+	sort.Slice(queued, func(i, j int) bool { return queued[i].CreatedAt.Before(queued[j].CreatedAt) })
+	for _, job := range queued {
+		job.EstimatedCompletionAt = estimate(job)
 	}
+	return len(queued), nil
+}
+
+// redeliverExpired queues again every leased job whose visibility timeout has
+// passed, or fails it once it used up its deliveries. Jobs that already finished
+// only lose their lease. Returns the earliest remaining deadline, or the zero time
+// when nothing is leased. Callers hold q.mu.
+func (q *Queue) redeliverExpired(now time.Time) time.Time {
+	var next time.Time
+	changed := false
+	for id, deadline := range q.leases {
+		if deadline.After(now) {
+			if next.IsZero() || deadline.Before(next) {
+				next = deadline
+			}
+			continue
+		}
+
+		delete(q.leases, id)
+		cancelRequested := q.cancelRequested(id)
+		q.finish(id)
+		changed = true
+		job, ok := q.jobs[id]
+		if !ok || job.IsComplete() {
+			continue
+		}
+		if cancelRequested {
+			job.SetCancelled("cancelled after its worker stopped responding") //nolint:errcheck // not complete, checked above
+			continue
+		}
+
+		if !job.Redeliver(q.opts.MaxDeliveries, q.opts.VisibilityTimeout) {
+			continue
+		}
+		q.redelivered++
+		// The job was admitted before, so it is requeued even when the buffer is full.
+		q.push(job, q.limits())
+	}
+	if changed {
+		q.broadcast()
+	}
+	return next
 }
 
 // GetJob retrieves a job by ID.
@@ -67,7 +313,9 @@ func (q *Queue) GetJob(ctx context.Context, jobID string) (*domain.Job, error) {
 	return job, nil
 }
 
-// UpdateJob updates a job's status and metadata.
+// UpdateJob updates a job's status and metadata. A dequeued job that leaves the
+// processing state frees its tenant's in-flight slot; one still processing has its
+// lease renewed.
 func (q *Queue) UpdateJob(ctx context.Context, job *domain.Job) error {
 	q.mu.Lock()
 	defer q.mu.Unlock()
@@ -76,21 +324,100 @@ func (q *Queue) UpdateJob(ctx context.Context, job *domain.Job) error {
 		return domain.ErrJobNotFound
 	}
 	q.jobs[job.ID] = job
+	q.indexJob(job)
+
+	if _, leased := q.leases[job.ID]; leased && job.Status == domain.JobStatusProcessing {
+		q.leases[job.ID] = time.Now().Add(q.opts.VisibilityTimeout)
+	}
+
+	if job.Status != domain.JobStatusProcessing && q.finish(job.ID) {
+		q.broadcast()
+	}
 	return nil
 }
 
-// ListJobs returns jobs matching the given status.
-func (q *Queue) ListJobs(ctx context.Context, status domain.JobStatus) ([]*domain.Job, error) {
+// Cancel cancels a job. A pending job, or one waiting for a retry, is cancelled
+// at once; for a dequeued job the channel returned by CancelRequested is closed
+// and the worker aborts it.
+func (q *Queue) Cancel(ctx context.Context, jobID string) (*domain.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[jobID]
+	if !ok {
+		return nil, domain.ErrJobNotFound
+	}
+	if job.Status == domain.JobStatusCancelled {
+		return job, nil
+	}
+	if job.IsComplete() {
+		return nil, domain.ErrJobNotCancellable
+	}
+
+	if cancel, running := q.cancels[jobID]; running {
+		if !q.cancelRequested(jobID) {
+			close(cancel)
+		}
+		return job, nil
+	}
+	if q.remove(jobID) {
+		q.broadcast()
+	}
+	job.SetCancelled("cancelled while queued") //nolint:errcheck // not complete, checked above
+	return job, nil
+}
+
+// CancelRequested returns a channel that is closed once cancellation of the
+// dequeued job jobID is requested. For a job not dequeued it is never closed.
+func (q *Queue) CancelRequested(ctx context.Context, jobID string) <-chan struct{} {
 	q.mu.RLock()
 	defer q.mu.RUnlock()
+	return q.cancels[jobID]
+}
+
+// cancelRequested reports whether Cancel was called for the dequeued job jobID.
+// Callers hold q.mu.
+func (q *Queue) cancelRequested(jobID string) bool {
+	select {
+	case <-q.cancels[jobID]:
+		return true
+	default:
+		return false
+	}
+}
+
+// finish forgets a dequeued job and frees its in-flight slot. Reports whether it
+// held one. Callers hold q.mu.
+func (q *Queue) finish(jobID string) bool {
+	delete(q.cancels, jobID)
+	return q.release(jobID)
+}
 
-	var result []*domain.Job
+// ListJobs returns a page of the jobs matching filter, sorted by creation time.
+func (q *Queue) ListJobs(ctx context.Context, filter domain.JobFilter) (*domain.JobPage, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	var matched []*domain.Job
 	for _, job := range q.jobs {
-		if job.Status == status {
-			result = append(result, job)
+		if !filter.Matches(job) {
+			continue
+		}
+		if filter.After != nil && !filter.After.Follows(job, filter.Ascending) {
+			continue
 		}
+		matched = append(matched, job)
 	}
-	return result, nil
+	sort.Slice(matched, func(i, j int) bool {
+		return domain.CursorAfter(matched[i]).Follows(matched[j], filter.Ascending)
+	})
+
+	page := &domain.JobPage{Jobs: matched}
+	if filter.Limit > 0 && len(matched) > filter.Limit {
+		page.Jobs = matched[:filter.Limit]
+		page.Next = domain.CursorAfter(page.Jobs[filter.Limit-1])
+	}
+	return page, nil
 }
 
 // DeleteJob removes a job from the queue.
@@ -99,21 +426,103 @@ func (q *Queue) DeleteJob(ctx context.Context, jobID string) error {
 	defer q.mu.Unlock()
 
 	delete(q.jobs, jobID)
+	delete(q.leases, jobID)
+	q.unindexJob(jobID)
+	if q.finish(jobID) {
+		q.broadcast()
+	}
 	return nil
 }
 
-// Close shuts down the queue gracefully.
+// Close shuts down the queue gracefully. Jobs already pending are still handed out
+// by Dequeue; new jobs are rejected.
 func (q *Queue) Close() error {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
 	if !q.closed {
 		q.closed = true
-		close(q.pending)
+		q.broadcast()
+	}
+	return nil
+}
+
+// ArchiveJob replaces a finished job with its archived record.
+func (q *Queue) ArchiveJob(ctx context.Context, job *domain.Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.archive[job.ID]; !ok {
+		q.archive[job.ID] = domain.NewArchivedJob(job)
 	}
+	delete(q.jobs, job.ID)
+	q.unindexJob(job.ID)
 	return nil
 }
 
+// SearchJobs returns the jobs held whose text and tags contain every word of
+// the query, best matches first and newest first among equal ones. It finds
+// nothing unless the queue was created with Options.Search.
+func (q *Queue) SearchJobs(ctx context.Context, query domain.JobSearchQuery) ([]*domain.Job, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.index == nil {
+		return nil, nil
+	}
+	hits := q.index.Search(query.Query, query.Tenant, func(id string) bool {
+		return query.TextRetained(q.jobs[id])
+	})
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return q.jobs[hits[i].ID].CreatedAt.After(q.jobs[hits[j].ID].CreatedAt)
+	})
+	if query.Limit > 0 && len(hits) > query.Limit {
+		hits = hits[:query.Limit]
+	}
+	jobs := make([]*domain.Job, 0, len(hits))
+	for _, hit := range hits {
+		jobs = append(jobs, q.jobs[hit.ID])
+	}
+	return jobs, nil
+}
+
+// indexJob adds job to the search index, if any. Callers hold q.mu.
+func (q *Queue) indexJob(job *domain.Job) {
+	if q.index != nil {
+		q.index.Put(search.Document{ID: job.ID, Tenant: job.Tenant(), Text: job.Text, Tags: job.Tags()})
+	}
+}
+
+// unindexJob drops a job from the search index, if any. Callers hold q.mu.
+func (q *Queue) unindexJob(jobID string) {
+	if q.index != nil {
+		q.index.Remove(jobID)
+	}
+}
+
+// Analytics aggregates the finished and archived jobs submitted in the query's
+// range that the queue still holds.
+func (q *Queue) Analytics(ctx context.Context, query domain.AnalyticsQuery) (*domain.Analytics, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	analytics := domain.NewAnalytics(query)
+	for _, job := range q.jobs {
+		if query.Matches(job) {
+			analytics.Add(job)
+		}
+	}
+	for _, job := range q.archive {
+		if query.MatchesArchived(job) {
+			analytics.AddArchived(job)
+		}
+	}
+	return analytics.Finish(), nil
+}
+
 // Stats returns current queue statistics.
 func (q *Queue) Stats() domain.QueueStats {
 	q.mu.RLock()
@@ -131,7 +540,38 @@ func (q *Queue) Stats() domain.QueueStats {
 			stats.CompletedJobs++
 		case domain.JobStatusFailed:
 			stats.FailedJobs++
+		case domain.JobStatusCancelled:
+			stats.CancelledJobs++
+		case domain.JobStatusExpired:
+			stats.ExpiredJobs++
 		}
 	}
+	stats.CharsInFlight = q.charsInFlight
+	stats.UnackedJobs = len(q.leases)
+	stats.RedeliveredJobs = q.redelivered
+	stats.Tenants = q.tenantStats(time.Now())
+	for _, t := range stats.Tenants {
+		stats.OldestQueuedSeconds = max(stats.OldestQueuedSeconds, t.OldestWaitSeconds)
+	}
 	return stats
 }
+
+// PendingMatching counts the pending jobs filter takes.
+func (q *Queue) PendingMatching(filter *domain.ProviderFilter) int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.pendingMatching(filter)
+}
+
+func (q *Queue) limits() limits {
+	return limits{
+		tenantMaxInFlight: q.opts.TenantMaxInFlight,
+		maxCharsInFlight:  int64(q.opts.MaxCharsInFlight),
+	}
+}
+
+// broadcast wakes every blocked Enqueue and Dequeue. Callers hold q.mu.
+func (q *Queue) broadcast() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
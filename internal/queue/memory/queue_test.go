@@ -2,6 +2,7 @@ package memory
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 
@@ -17,8 +18,8 @@ func TestNewQueue(t *testing.T) {
 	if queue.jobs == nil {
 		t.Error("Expected jobs map to be initialized")
 	}
-	if queue.pending == nil {
-		t.Error("Expected pending channel to be initialized")
+	if queue.tenants == nil {
+		t.Error("Expected tenant queues to be initialized")
 	}
 }
 
@@ -76,6 +77,47 @@ func TestQueue_Enqueue_ContextCanceled(t *testing.T) {
 	}
 }
 
+func TestQueue_Enqueue_BusyAfterBoundedWait(t *testing.T) {
+	queue := NewQueueWithOptions(1, Options{EnqueueWait: 20 * time.Millisecond})
+	ctx := context.Background()
+
+	job1 := domain.NewJob("test1", "voice", "", "", "provider", "mp3", nil)
+	queue.Enqueue(ctx, job1) //nolint:errcheck
+
+	job2 := domain.NewJob("test2", "voice", "", "", "provider", "mp3", nil)
+	start := time.Now()
+	err := queue.Enqueue(ctx, job2)
+	elapsed := time.Since(start)
+
+	if err != domain.ErrQueueBusy {
+		t.Fatalf("Expected ErrQueueBusy, got %v", err)
+	}
+	if elapsed < 20*time.Millisecond || elapsed > time.Second {
+		t.Errorf("Expected to wait about 20ms, waited %v", elapsed)
+	}
+	if _, err := queue.GetJob(ctx, job2.ID); err == nil {
+		t.Error("Expected rejected job to be forgotten")
+	}
+}
+
+func TestQueue_Enqueue_WaitsForSpace(t *testing.T) {
+	queue := NewQueueWithOptions(1, Options{EnqueueWait: time.Second})
+	ctx := context.Background()
+
+	job1 := domain.NewJob("test1", "voice", "", "", "provider", "mp3", nil)
+	queue.Enqueue(ctx, job1) //nolint:errcheck
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		queue.Dequeue(ctx) //nolint:errcheck
+	}()
+
+	job2 := domain.NewJob("test2", "voice", "", "", "provider", "mp3", nil)
+	if err := queue.Enqueue(ctx, job2); err != nil {
+		t.Fatalf("Expected enqueue to succeed once space frees up, got %v", err)
+	}
+}
+
 func TestQueue_Dequeue(t *testing.T) {
 	queue := NewQueue(10)
 	ctx := context.Background()
@@ -120,6 +162,140 @@ func TestQueue_Dequeue_ContextCanceled(t *testing.T) {
 	}
 }
 
+func TestQueue_DequeueMatching(t *testing.T) {
+	queue := NewQueue(10)
+	ctx := context.Background()
+
+	cloud := domain.NewJob("cloud", "voice", "", "", "elevenlabs", "mp3", nil)
+	local := domain.NewJob("local", "voice", "", "", "piper", "mp3", nil)
+	queue.Enqueue(ctx, cloud) //nolint:errcheck
+	queue.Enqueue(ctx, local) //nolint:errcheck
+
+	onlyPiper := &domain.ProviderFilter{Providers: []string{"piper"}}
+	if n := queue.PendingMatching(onlyPiper); n != 1 {
+		t.Errorf("Expected 1 matching pending job, got %d", n)
+	}
+
+	job, err := queue.DequeueMatching(ctx, onlyPiper)
+	if err != nil {
+		t.Fatalf("Failed to dequeue job: %v", err)
+	}
+	if job.ID != local.ID {
+		t.Errorf("Expected the piper job, got %s", job.ProviderName)
+	}
+
+	// Closed with only non-matching jobs left: nothing more for this caller
+	queue.Close() //nolint:errcheck
+	job, err = queue.DequeueMatching(ctx, onlyPiper)
+	if err != nil || job != nil {
+		t.Errorf("Expected nil job once no matching job is left, got %v, %v", job, err)
+	}
+
+	job, _ = queue.Dequeue(ctx)
+	if job == nil || job.ID != cloud.ID {
+		t.Error("Expected the elevenlabs job to stay queued for other callers")
+	}
+}
+
+func TestQueue_RedeliversUnackedJobs(t *testing.T) {
+	queue := NewQueueWithOptions(10, Options{VisibilityTimeout: 20 * time.Millisecond, MaxDeliveries: 2})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	acked := domain.NewJob("acked", "voice", "", "", "provider", "mp3", nil)
+	lost := domain.NewJob("lost", "voice", "", "", "provider", "mp3", nil)
+	queue.Enqueue(ctx, acked) //nolint:errcheck
+	queue.Enqueue(ctx, lost)  //nolint:errcheck
+
+	for i := 0; i < 2; i++ {
+		job, err := queue.Dequeue(ctx)
+		if err != nil {
+			t.Fatalf("Failed to dequeue job: %v", err)
+		}
+		job.SetProcessing()
+		queue.UpdateJob(ctx, job) //nolint:errcheck
+	}
+	acked.SetCompleted("/tmp/acked.mp3", 1)
+	queue.UpdateJob(ctx, acked) //nolint:errcheck
+	if err := queue.Ack(ctx, acked.ID); err != nil {
+		t.Fatalf("Failed to ack job: %v", err)
+	}
+	if stats := queue.Stats(); stats.UnackedJobs != 1 {
+		t.Errorf("Expected 1 unacked job, got %d", stats.UnackedJobs)
+	}
+
+	// The unacknowledged job comes back once its visibility timeout passes
+	job, err := queue.Dequeue(ctx)
+	if err != nil || job == nil || job.ID != lost.ID {
+		t.Fatalf("Expected the unacked job to be redelivered, got %v, %v", job, err)
+	}
+	if job.Redeliveries != 1 || job.Events[len(job.Events)-3].Type != domain.JobEventRedelivered {
+		t.Errorf("Expected a recorded redelivery, got %d, %+v", job.Redeliveries, job.Events)
+	}
+
+	// Its second delivery is the last one
+	shortCtx, shortCancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer shortCancel()
+	if job, _ := queue.Dequeue(shortCtx); job != nil {
+		t.Fatalf("Expected no third delivery, got %s", job.ID)
+	}
+	if lost.Status != domain.JobStatusFailed || lost.ErrorCode != domain.JobErrDeliveryLimit {
+		t.Errorf("Expected the job to fail with %s, got %s %q", domain.JobErrDeliveryLimit, lost.Status, lost.ErrorCode)
+	}
+	if stats := queue.Stats(); stats.RedeliveredJobs != 1 || stats.UnackedJobs != 0 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestQueue_Cancel(t *testing.T) {
+	queue := NewQueue(10)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	running := domain.NewJob("running", "voice", "", "", "provider", "mp3", nil)
+	waiting := domain.NewJob("waiting", "voice", "", "", "provider", "mp3", nil)
+	queue.Enqueue(ctx, running) //nolint:errcheck
+	queue.Enqueue(ctx, waiting) //nolint:errcheck
+
+	if _, err := queue.Dequeue(ctx); err != nil {
+		t.Fatalf("Failed to dequeue job: %v", err)
+	}
+	running.SetProcessing()
+	queue.UpdateJob(ctx, running) //nolint:errcheck
+
+	// A queued job is cancelled outright and never handed out
+	job, err := queue.Cancel(ctx, waiting.ID)
+	if err != nil || job.Status != domain.JobStatusCancelled {
+		t.Fatalf("Expected the queued job to be cancelled, got %v, %v", job, err)
+	}
+	if stats := queue.Stats(); stats.QueuedJobs != 0 || stats.CancelledJobs != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	// A dequeued job only has cancellation requested
+	requested := queue.CancelRequested(ctx, running.ID)
+	if job, err := queue.Cancel(ctx, running.ID); err != nil || job.Status != domain.JobStatusProcessing {
+		t.Fatalf("Expected the running job to keep its status, got %v, %v", job, err)
+	}
+	select {
+	case <-requested:
+	default:
+		t.Error("Expected the cancellation channel to be closed")
+	}
+	if _, err := queue.Cancel(ctx, running.ID); err != nil {
+		t.Errorf("Expected a repeated cancel to succeed, got %v", err)
+	}
+
+	running.SetCompleted("/tmp/running.mp3", 1)
+	queue.UpdateJob(ctx, running) //nolint:errcheck
+	if _, err := queue.Cancel(ctx, running.ID); err != domain.ErrJobNotCancellable {
+		t.Errorf("Expected ErrJobNotCancellable for a finished job, got %v", err)
+	}
+	if _, err := queue.Cancel(ctx, "missing"); err != domain.ErrJobNotFound {
+		t.Errorf("Expected ErrJobNotFound, got %v", err)
+	}
+}
+
 func TestQueue_GetJob(t *testing.T) {
 	queue := NewQueue(10)
 	ctx := context.Background()
@@ -166,6 +342,43 @@ func TestQueue_UpdateJob(t *testing.T) {
 	}
 }
 
+func TestQueue_UpdateEstimates(t *testing.T) {
+	queue := NewQueue(10)
+	ctx := context.Background()
+
+	first := domain.NewJob("first", "voice", "", "", "cloud", "mp3", nil)
+	second := domain.NewJob("second", "voice", "", "", "cloud", "mp3", nil)
+	second.CreatedAt = first.CreatedAt.Add(time.Second)
+	other := domain.NewJob("other", "voice", "", "", "local", "mp3", nil)
+	for _, job := range []*domain.Job{second, first, other} {
+		queue.Enqueue(ctx, job) //nolint:errcheck
+	}
+
+	at := time.Now()
+	var order []string
+	n, err := queue.UpdateEstimates(ctx, "cloud", func(job *domain.Job) *time.Time {
+		order = append(order, job.Text)
+		at = at.Add(time.Minute)
+		estimate := at
+		return &estimate
+	})
+	if err != nil {
+		t.Fatalf("UpdateEstimates: %v", err)
+	}
+	if n != 2 || len(order) != 2 || order[0] != "first" || order[1] != "second" {
+		t.Fatalf("expected the provider's queued jobs oldest first, got %d %v", n, order)
+	}
+
+	got, _ := queue.GetJob(ctx, second.ID)
+	if got.EstimatedCompletionAt == nil || !got.EstimatedCompletionAt.Equal(at) {
+		t.Errorf("expected the estimate set, got %v", got.EstimatedCompletionAt)
+	}
+	got, _ = queue.GetJob(ctx, other.ID)
+	if got.EstimatedCompletionAt != nil {
+		t.Error("expected the jobs of other providers left alone")
+	}
+}
+
 func TestQueue_UpdateJob_NotFound(t *testing.T) {
 	queue := NewQueue(10)
 	ctx := context.Background()
@@ -202,24 +415,72 @@ func TestQueue_ListJobs(t *testing.T) {
 	queue.UpdateJob(ctx, job3) //nolint:errcheck
 
 	// List queued jobs
-	queuedJobs, err := queue.ListJobs(ctx, domain.JobStatusQueued)
+	queuedJobs, err := queue.ListJobs(ctx, domain.JobFilter{Status: domain.JobStatusQueued})
 	if err != nil {
 		t.Fatalf("Failed to list jobs: %v", err)
 	}
-	if len(queuedJobs) != 1 {
-		t.Errorf("Expected 1 queued job, got %d", len(queuedJobs))
+	if len(queuedJobs.Jobs) != 1 {
+		t.Errorf("Expected 1 queued job, got %d", len(queuedJobs.Jobs))
 	}
 
 	// List processing jobs
-	processingJobs, _ := queue.ListJobs(ctx, domain.JobStatusProcessing)
-	if len(processingJobs) != 1 {
-		t.Errorf("Expected 1 processing job, got %d", len(processingJobs))
+	processingJobs, _ := queue.ListJobs(ctx, domain.JobFilter{Status: domain.JobStatusProcessing})
+	if len(processingJobs.Jobs) != 1 {
+		t.Errorf("Expected 1 processing job, got %d", len(processingJobs.Jobs))
 	}
 
 	// List completed jobs
-	completedJobs, _ := queue.ListJobs(ctx, domain.JobStatusCompleted)
-	if len(completedJobs) != 1 {
-		t.Errorf("Expected 1 completed job, got %d", len(completedJobs))
+	completedJobs, _ := queue.ListJobs(ctx, domain.JobFilter{Status: domain.JobStatusCompleted})
+	if len(completedJobs.Jobs) != 1 {
+		t.Errorf("Expected 1 completed job, got %d", len(completedJobs.Jobs))
+	}
+}
+
+func TestQueue_ListJobs_Pages(t *testing.T) {
+	queue := NewQueue(10)
+	ctx := context.Background()
+
+	created := time.Now().UTC()
+	var jobs []*domain.Job
+	for i := 0; i < 5; i++ {
+		job := domain.NewJob("test", "voice", "", "", "provider", "mp3", nil)
+		// Two jobs share a creation time, so the ID breaks the tie
+		job.CreatedAt = created.Add(time.Duration(min(i, 3)) * time.Second)
+		job.TenantID = "a"
+		queue.Enqueue(ctx, job) //nolint:errcheck
+		jobs = append(jobs, job)
+	}
+	other := domain.NewJob("test", "voice", "", "", "provider", "mp3", nil)
+	other.TenantID = "b"
+	queue.Enqueue(ctx, other) //nolint:errcheck
+
+	for _, ascending := range []bool{true, false} {
+		filter := domain.JobFilter{Tenant: "a", Ascending: ascending, Limit: 2}
+		var listed []*domain.Job
+		for pages := 0; ; pages++ {
+			if pages == 3 {
+				t.Fatal("Expected 3 pages")
+			}
+			page, err := queue.ListJobs(ctx, filter)
+			if err != nil {
+				t.Fatalf("Failed to list jobs: %v", err)
+			}
+			listed = append(listed, page.Jobs...)
+			if page.Next == nil {
+				break
+			}
+			filter.After = page.Next
+		}
+
+		if len(listed) != len(jobs) {
+			t.Fatalf("Expected %d jobs, got %d", len(jobs), len(listed))
+		}
+		for i := 1; i < len(listed); i++ {
+			prev, cur := listed[i-1], listed[i]
+			if !domain.CursorAfter(prev).Follows(cur, ascending) {
+				t.Errorf("ascending=%v: job %d is out of order", ascending, i)
+			}
+		}
 	}
 }
 
@@ -241,6 +502,77 @@ func TestQueue_DeleteJob(t *testing.T) {
 	}
 }
 
+func TestQueue_SearchJobs(t *testing.T) {
+	queue := NewQueueWithOptions(10, Options{Search: true})
+	ctx := context.Background()
+
+	submit := func(text, tenant string, age time.Duration) *domain.Job {
+		job := domain.NewJob(text, "voice", "", "", "provider", "mp3", nil)
+		job.TenantID = tenant
+		job.CreatedAt = job.CreatedAt.Add(-age)
+		queue.Enqueue(ctx, job) //nolint:errcheck
+		return job
+	}
+	older := submit("Narrating the Q3 report", "a", time.Hour)
+	newer := submit("The Q3 report, narrated", "a", 0)
+	submit("Q3 report for another tenant", "b", 0)
+	tagged := submit("Chapter one", "a", 0)
+	tagged.Pipeline = []domain.PipelineStage{{Stage: "tag", Params: map[string]any{"title": "Quarterly Q3"}}}
+	queue.UpdateJob(ctx, tagged) //nolint:errcheck
+
+	search := func(query domain.JobSearchQuery) []*domain.Job {
+		t.Helper()
+		jobs, err := queue.SearchJobs(ctx, query)
+		if err != nil {
+			t.Fatalf("Failed to search jobs: %v", err)
+		}
+		return jobs
+	}
+
+	jobs := search(domain.JobSearchQuery{Query: "q3 report", Tenant: "a"})
+	if len(jobs) != 2 || jobs[0] != newer || jobs[1] != older {
+		t.Fatalf("Expected the newer, then the older report, got %v", jobs)
+	}
+	if jobs := search(domain.JobSearchQuery{Query: "quarterly", Tenant: "a"}); len(jobs) != 1 || jobs[0] != tagged {
+		t.Errorf("Expected the tagged job, got %v", jobs)
+	}
+	if jobs := search(domain.JobSearchQuery{Query: "q3", Tenant: "a", Limit: 1}); len(jobs) != 1 {
+		t.Errorf("Expected 1 job within the limit, got %d", len(jobs))
+	}
+
+	// Jobs whose text is no longer retained match on their tags only
+	expired := time.Now().Add(-time.Minute)
+	older.ExpiresAt, tagged.ExpiresAt = &expired, &expired
+	queue.UpdateJob(ctx, older)  //nolint:errcheck
+	queue.UpdateJob(ctx, tagged) //nolint:errcheck
+	jobs = search(domain.JobSearchQuery{Query: "q3", Tenant: "a", TextExpiry: time.Now()})
+	if len(jobs) != 2 {
+		t.Errorf("Expected the newer report and the tagged job, got %v", jobs)
+	}
+	for _, job := range jobs {
+		if job == older {
+			t.Error("Expected the expired job not to match on its text")
+		}
+	}
+
+	queue.DeleteJob(ctx, newer.ID) //nolint:errcheck
+	queue.ArchiveJob(ctx, tagged)  //nolint:errcheck
+	if jobs := search(domain.JobSearchQuery{Query: "q3", Tenant: "a"}); len(jobs) != 1 || jobs[0] != older {
+		t.Errorf("Expected deleted and archived jobs to be dropped, got %v", jobs)
+	}
+}
+
+func TestQueue_SearchJobs_Disabled(t *testing.T) {
+	queue := NewQueue(10)
+	ctx := context.Background()
+
+	queue.Enqueue(ctx, domain.NewJob("the Q3 report", "voice", "", "", "provider", "mp3", nil)) //nolint:errcheck
+	jobs, err := queue.SearchJobs(ctx, domain.JobSearchQuery{Query: "report"})
+	if err != nil || len(jobs) != 0 {
+		t.Errorf("Expected no jobs without Options.Search, got %v, %v", jobs, err)
+	}
+}
+
 func TestQueue_Close(t *testing.T) {
 	queue := NewQueue(10)
 
@@ -298,3 +630,156 @@ func TestQueue_Stats(t *testing.T) {
 		t.Errorf("Expected FailedJobs 1, got %d", stats.FailedJobs)
 	}
 }
+
+func tenantJob(tenant, text string) *domain.Job {
+	job := domain.NewJob(text, "voice", "", "", "provider", "mp3", nil)
+	job.TenantID = tenant
+	return job
+}
+
+func TestQueue_Dequeue_RoundRobinAcrossTenants(t *testing.T) {
+	queue := NewQueue(10)
+	ctx := context.Background()
+
+	for _, job := range []*domain.Job{
+		tenantJob("a", "a1"), tenantJob("a", "a2"), tenantJob("a", "a3"),
+		tenantJob("b", "b1"),
+		tenantJob("", "d1"),
+	} {
+		if err := queue.Enqueue(ctx, job); err != nil {
+			t.Fatalf("Failed to enqueue: %v", err)
+		}
+	}
+
+	var order []string
+	for i := 0; i < 5; i++ {
+		job, err := queue.Dequeue(ctx)
+		if err != nil {
+			t.Fatalf("Failed to dequeue: %v", err)
+		}
+		order = append(order, job.Text)
+	}
+
+	want := []string{"a1", "b1", "d1", "a2", "a3"}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("Expected order %v, got %v", want, order)
+		}
+	}
+}
+
+func TestQueue_Dequeue_TenantInFlightCap(t *testing.T) {
+	queue := NewQueueWithOptions(10, Options{TenantMaxInFlight: 1})
+	ctx := context.Background()
+
+	a1, a2, b1 := tenantJob("a", "a1"), tenantJob("a", "a2"), tenantJob("b", "b1")
+	for _, job := range []*domain.Job{a1, a2, b1} {
+		queue.Enqueue(ctx, job) //nolint:errcheck
+	}
+
+	first, _ := queue.Dequeue(ctx)
+	first.SetProcessing()
+	queue.UpdateJob(ctx, first) //nolint:errcheck
+	second, _ := queue.Dequeue(ctx)
+	if first != a1 || second != b1 {
+		t.Fatalf("Expected a1 then b1, got %s then %s", first.Text, second.Text)
+	}
+
+	// a2 is held back while a1 is processing.
+	shortCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if job, err := queue.Dequeue(shortCtx); err == nil {
+		t.Fatalf("Expected a2 to wait for a1, got %s", job.Text)
+	}
+
+	first.SetCompleted("/tmp/a1.mp3", 24)
+	queue.UpdateJob(ctx, first) //nolint:errcheck
+
+	third, err := queue.Dequeue(ctx)
+	if err != nil || third != a2 {
+		t.Fatalf("Expected a2 once a1 completed, got %v, %v", third, err)
+	}
+
+	stats := queue.Stats()
+	if len(stats.Tenants) != 2 || stats.Tenants[0].Tenant != "a" || stats.Tenants[0].Dequeued != 2 || stats.Tenants[0].InFlight != 1 {
+		t.Errorf("Unexpected tenant stats %+v", stats.Tenants)
+	}
+}
+
+func TestQueue_Dequeue_WeightsTenantsByCharacters(t *testing.T) {
+	queue := NewQueue(10)
+	ctx := context.Background()
+
+	for _, job := range []*domain.Job{
+		tenantJob("books", strings.Repeat("b", 1000)), tenantJob("books", strings.Repeat("c", 1000)),
+		tenantJob("chat", "hi"), tenantJob("chat", "yo"), tenantJob("chat", "ok"),
+	} {
+		queue.Enqueue(ctx, job) //nolint:errcheck
+	}
+
+	var order []string
+	for i := 0; i < 5; i++ {
+		job, _ := queue.Dequeue(ctx)
+		order = append(order, job.TenantID)
+	}
+
+	// After one 1000-char book, the chat tenant is owed its three short jobs.
+	want := []string{"books", "chat", "chat", "chat", "books"}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("Expected order %v, got %v", want, order)
+		}
+	}
+}
+
+func TestQueue_Dequeue_MaxCharsInFlight(t *testing.T) {
+	queue := NewQueueWithOptions(10, Options{MaxCharsInFlight: 100})
+	ctx := context.Background()
+
+	book1 := tenantJob("", strings.Repeat("a", 80))
+	book2 := tenantJob("", strings.Repeat("b", 80))
+	tiny := tenantJob("", strings.Repeat("c", 10))
+	huge := tenantJob("", strings.Repeat("d", 500))
+	for _, job := range []*domain.Job{book1, book2, tiny, huge} {
+		queue.Enqueue(ctx, job) //nolint:errcheck
+	}
+
+	if job, _ := queue.Dequeue(ctx); job != book1 {
+		t.Fatalf("Expected book1 first, got %s", job.Text[:1])
+	}
+	// book2 doesn't fit next to book1, so the tiny job overtakes it.
+	if job, _ := queue.Dequeue(ctx); job != tiny {
+		t.Fatalf("Expected tiny job to overtake book2, got %s", job.Text[:1])
+	}
+	shortCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if job, err := queue.Dequeue(shortCtx); err == nil {
+		t.Fatalf("Expected budget to be exhausted, got %s", job.Text[:1])
+	}
+
+	book1.SetCompleted("/tmp/a.mp3", 24)
+	queue.UpdateJob(ctx, book1) //nolint:errcheck
+	if job, _ := queue.Dequeue(ctx); job != book2 {
+		t.Fatalf("Expected reserved book2 once book1 finished, got %s", job.Text[:1])
+	}
+	if got := queue.Stats().CharsInFlight; got != 90 {
+		t.Errorf("Expected 90 chars in flight, got %d", got)
+	}
+
+	// A job larger than the whole budget runs once nothing else is in flight.
+	for _, job := range []*domain.Job{tiny, book2} {
+		job.SetCompleted("/tmp/x.mp3", 24)
+		queue.UpdateJob(ctx, job) //nolint:errcheck
+	}
+	if job, _ := queue.Dequeue(ctx); job != huge {
+		t.Fatalf("Expected oversized job to run alone, got %s", job.Text[:1])
+	}
+
+	var types []string
+	for _, event := range book2.Events {
+		types = append(types, event.Type)
+	}
+	if strings.Join(types, ",") != "queued,deferred,dequeued" {
+		t.Errorf("Expected queued,deferred,dequeued events, got %v", types)
+	}
+}
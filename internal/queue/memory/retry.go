@@ -0,0 +1,73 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"time"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// Retry policy defaults, used for the zero fields of a RetryPolicy.
+const (
+	defaultMaxAttempts    = 5
+	defaultRetryBaseDelay = 5 * time.Second
+	defaultRetryMaxDelay  = 5 * time.Minute
+)
+
+// RetryPolicy decides whether and when a job whose synthesis failed with a
+// transient error is attempted again. Zero fields take the defaults.
+type RetryPolicy struct {
+	// MaxAttempts is how often a job is attempted in total; 1 disables retries.
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry. It doubles with every further
+	// retry, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = defaultMaxAttempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = defaultRetryBaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = defaultRetryMaxDelay
+	}
+	return p
+}
+
+// Delay returns the wait before the job is attempted again after its attempt-th
+// attempt failed with err. A Retry-After hint from the provider is used as-is;
+// otherwise the backoff is BaseDelay doubled per earlier retry, capped at
+// MaxDelay, plus up to 20% jitter so jobs that failed together don't all come
+// back at once.
+func (p RetryPolicy) Delay(attempt int, err error) time.Duration {
+	if perr, ok := domain.AsProviderError(err); ok && perr.RetryAfter > 0 {
+		return perr.RetryAfter
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.MaxDelay)
+	return delay + rand.N(delay/5+1)
+}
+
+// isTransient reports whether a failed synthesis is worth retrying: the provider
+// timed out, was rate limited or answered with a server error.
+func isTransient(err error) bool {
+	if perr, ok := domain.AsProviderError(err); ok {
+		return perr.IsTransient()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr) && nerr.Timeout()
+}
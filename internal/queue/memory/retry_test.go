@@ -0,0 +1,45 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}.withDefaults()
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		got := p.Delay(attempt, errors.New("timeout"))
+		if got < want || got > want+want/5 {
+			t.Errorf("attempt %d: delay %s, want %s plus up to 20%%", attempt, got, want)
+		}
+	}
+
+	hinted := &domain.ProviderError{StatusCode: http.StatusTooManyRequests, RetryAfter: 42 * time.Second}
+	if got := p.Delay(1, hinted); got != 42*time.Second {
+		t.Errorf("expected the Retry-After hint, got %s", got)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := map[error]bool{
+		&domain.ProviderError{StatusCode: http.StatusTooManyRequests}: true,
+		&domain.ProviderError{StatusCode: http.StatusBadGateway}:      true,
+		&domain.ProviderError{StatusCode: http.StatusRequestTimeout}:  true,
+		&domain.ProviderError{StatusCode: http.StatusBadRequest}:      false,
+		&domain.ProviderError{StatusCode: http.StatusUnauthorized}:    false,
+		fmt.Errorf("synthesize: %w", context.DeadlineExceeded):        true,
+		context.Canceled:                    false,
+		errors.New("ffmpeg: exit status 1"): false,
+	}
+	for err, want := range tests {
+		if got := isTransient(err); got != want {
+			t.Errorf("isTransient(%v) = %v, want %v", err, got, want)
+		}
+	}
+}
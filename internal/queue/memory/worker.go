@@ -1,54 +1,208 @@
 package memory
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"strings"
 	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"go.uber.org/zap"
 
+	"github.com/pako-tts/server/internal/audio/bufpool"
+	"github.com/pako-tts/server/internal/audio/effects"
+	"github.com/pako-tts/server/internal/audio/quality"
+	"github.com/pako-tts/server/internal/audio/transcode"
+	"github.com/pako-tts/server/internal/audio/validate"
+	"github.com/pako-tts/server/internal/audio/waveform"
+	"github.com/pako-tts/server/internal/deadline"
 	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/metrics"
+	"github.com/pako-tts/server/internal/pipeline"
+	"github.com/pako-tts/server/internal/speechcache"
+	"github.com/pako-tts/server/internal/textinfo"
 )
 
+// errJobCancelled is the cause of a job's context when its cancellation was requested.
+var errJobCancelled = errors.New("job cancelled")
+
+// errDeadlinePassed is returned by synthesize when the job's deadline passed
+// before a provider could produce its audio.
+var errDeadlinePassed = errors.New("job deadline passed")
+
+// errSilentAudio is the error of a provider result without audible sound.
+var errSilentAudio = errors.New("provider returned silent audio")
+
+// JobSource is the queue a Worker takes jobs from. *Queue implements it; durable
+// queues implement it to be processed by the same workers.
+type JobSource interface {
+	domain.JobQueue
+
+	// DequeueMatching is Dequeue restricted to the jobs filter takes (any job
+	// when filter is nil).
+	DequeueMatching(ctx context.Context, filter *domain.ProviderFilter) (*domain.Job, error)
+
+	// PendingMatching counts the pending jobs filter takes.
+	PendingMatching(filter *domain.ProviderFilter) int
+
+	// CancelRequested returns a channel that is closed once cancellation of the
+	// dequeued job jobID is requested. Sources that have to poll for it stop when
+	// ctx is done.
+	CancelRequested(ctx context.Context, jobID string) <-chan struct{}
+}
+
 // Worker processes jobs from the queue.
 type Worker struct {
-	queue          *Queue
+	queue          JobSource
+	dequeue        DequeueStrategy
 	registry       domain.ProviderRegistry
 	storage        domain.AudioStorage
 	logger         *zap.Logger
 	retentionHours int
+	// artifactTTL keeps kinds of artifacts for their own period instead of as
+	// long as the result.
+	artifactTTL    map[string]time.Duration
+	previewSeconds int
+	sources        domain.TextSourceResolver
+	speechCache    domain.SpeechCache
+	resultCache    domain.SpeechCache
+	cacheMetrics   *metrics.ResultCacheMetrics
+	silenceDB      float64
+	limits         *providerLimits
+	degradations   domain.ProviderDegradations
+	heartbeat      time.Duration
+	retry          RetryPolicy
+	onFinished     func(ctx context.Context, job *domain.Job)
+	pools          []*workerPool
+	poolSize       int
+	drain          *drainState
 	wg             sync.WaitGroup
 	cancel         context.CancelFunc
 }
 
-// NewWorker creates a new worker.
+// NewWorker creates a new worker. sources fetches the text of jobs submitted with
+// a text source; when nil, such jobs fail. speechCache receives the results of
+// cache-warming jobs and may be nil. retry governs how jobs failing with
+// transient provider errors are attempted again.
 func NewWorker(
-	queue *Queue,
+	queue JobSource,
 	registry domain.ProviderRegistry,
 	storage domain.AudioStorage,
 	logger *zap.Logger,
 	retentionHours int,
+	previewSeconds int,
+	sources domain.TextSourceResolver,
+	speechCache domain.SpeechCache,
+	retry RetryPolicy,
 ) *Worker {
 	return &Worker{
 		queue:          queue,
+		dequeue:        Blocking(queue),
 		registry:       registry,
 		storage:        storage,
 		logger:         logger,
 		retentionHours: retentionHours,
+		previewSeconds: previewSeconds,
+		sources:        sources,
+		speechCache:    speechCache,
+		retry:          retry.withDefaults(),
+		drain:          newDrainState(),
 	}
 }
 
-// Start starts the worker pool with the given number of workers.
-func (w *Worker) Start(ctx context.Context, numWorkers int) {
+// RetainArtifacts keeps each kind of artifact in retention for its own period
+// instead of as long as the result, recording on each job when its artifacts
+// expire. It must be set before Start.
+func (w *Worker) RetainArtifacts(retention map[string]time.Duration) {
+	w.artifactTTL = retention
+}
+
+// OnFinished registers fn to be called after a job is completed, failed or
+// cancelled. It must be set before Start.
+func (w *Worker) OnFinished(fn func(ctx context.Context, job *domain.Job)) {
+	w.onFinished = fn
+}
+
+// CacheResults keeps the audio of completed jobs in cache, and completes jobs
+// identical to an earlier one with its audio instead of calling the provider.
+// Lookups are counted in m, which may be nil. It must be set before Start.
+func (w *Worker) CacheResults(cache domain.SpeechCache, m *metrics.ResultCacheMetrics) {
+	w.resultCache = cache
+	w.cacheMetrics = m
+}
+
+// DequeueWith sets how workers wait for jobs; Blocking by default. It must be
+// set before Start.
+func (w *Worker) DequeueWith(strategy DequeueStrategy) {
+	w.dequeue = strategy
+}
+
+// DetectSilence treats results whose peak level stays below thresholdDB (in
+// dBFS) as failed provider output when the job's text has something to speak.
+// Zero, the default, turns the check off. It must be set before Start.
+func (w *Worker) DetectSilence(thresholdDB float64) {
+	w.silenceDB = thresholdDB
+}
+
+// LimitProviderConcurrency keeps the synthesis calls to each provider within
+// its MaxConcurrent. Jobs over the limit wait for a slot; jobs for other
+// providers are not held up. It must be set before Start.
+func (w *Worker) LimitProviderConcurrency() {
+	if w.limits == nil {
+		w.limits = newProviderLimits()
+	}
+	w.limits.enforce = true
+}
+
+// Start starts numWorkers general workers plus the workers of each pinned pool. A
+// pinned pool only takes jobs for its providers, and the general workers take jobs
+// for every other provider.
+func (w *Worker) Start(ctx context.Context, numWorkers int, pinned ...Pool) {
 	ctx, w.cancel = context.WithCancel(ctx)
+	w.drain.start(ctx)
+	w.pools = buildPools(numWorkers, pinned)
 
-	for i := 0; i < numWorkers; i++ {
-		w.wg.Add(1)
-		go w.run(ctx, i)
+	for _, pool := range w.pools {
+		w.poolSize += pool.Workers
+	}
+	if w.limits != nil {
+		w.limits.poolSize = w.poolSize
+	}
+
+	workerID := 0
+	for _, pool := range w.pools {
+		for i := 0; i < pool.Workers; i++ {
+			w.wg.Add(1)
+			go w.run(ctx, workerID, pool)
+			workerID++
+		}
+		if len(pinned) > 0 {
+			w.logger.Info("Worker pool started",
+				zap.String("pool", pool.Name),
+				zap.Int("workers", pool.Workers),
+				zap.Strings("providers", pool.Providers),
+			)
+		}
 	}
 
-	w.logger.Info("Worker pool started", zap.Int("workers", numWorkers))
+	if len(pinned) == 0 {
+		w.logger.Info("Worker pool started", zap.Int("workers", numWorkers))
+	}
+
+	if reaper, ok := w.queue.(Reaper); ok && w.heartbeat > 0 {
+		w.wg.Add(1)
+		go w.reap(ctx, reaper)
+	}
+	if updater, ok := w.queue.(EstimateUpdater); ok && w.degradations != nil {
+		w.wg.Add(1)
+		go w.watchDegradation(ctx, updater)
+	}
 }
 
 // Stop stops all workers gracefully.
@@ -60,38 +214,151 @@ func (w *Worker) Stop() {
 	w.logger.Info("Worker pool stopped")
 }
 
-func (w *Worker) run(ctx context.Context, workerID int) {
+func (w *Worker) run(ctx context.Context, workerID int, pool *workerPool) {
 	defer w.wg.Done()
 
-	logger := w.logger.With(zap.Int("worker_id", workerID))
+	logger := w.logger.With(zap.Int("worker_id", workerID), zap.String("pool", pool.Name))
 	logger.Debug("Worker started")
 
 	for {
-		select {
-		case <-ctx.Done():
+		takeCtx, cancel, ok := w.drain.takeWork(ctx, w.queue, pool)
+		if !ok {
 			logger.Debug("Worker stopping")
 			return
-		default:
-			job, err := w.queue.Dequeue(ctx)
-			if err != nil {
-				if ctx.Err() != nil {
-					return
-				}
-				logger.Error("Failed to dequeue job", zap.Error(err))
-				continue
-			}
-			if job == nil {
-				// Queue closed
+		}
+		job, err := w.dequeue.Next(takeCtx, pool.filter)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
 				return
 			}
+			if takeCtx.Err() != nil {
+				// A drain started, or a draining worker found no job in time
+				continue
+			}
+			logger.Error("Failed to dequeue job", zap.Error(err))
+			continue
+		}
+		if job == nil {
+			// Queue closed
+			return
+		}
+
+		pool.busy.Add(1)
+		w.handle(ctx, job, logger)
+		pool.busy.Add(-1)
+		pool.record(job)
+	}
+}
 
-			w.processJob(ctx, job, logger)
+// handle processes job and acknowledges it once it reached an outcome: completed
+// after its audio was stored, failed, cancelled, or put back for a retry, which is
+// requeued at its retry time. Processing is aborted when the job's cancellation is
+// requested, and when a drain's deadline passes, which checkpoints the job. A job left
+// processing, because its status couldn't be saved or processing panicked, stays
+// unacknowledged and is redelivered after the queue's visibility timeout.
+func (w *Worker) handle(ctx context.Context, job *domain.Job, logger *zap.Logger) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("Job processing panicked; leaving it for redelivery",
+				zap.String("job_id", job.ID), zap.Any("panic", r))
 		}
+	}()
+
+	jobCtx, stop := w.watchCancel(w.drain.jobContext(ctx), job.ID)
+	defer stop()
+
+	// The lease is renewed while the job is handled, and left to expire after a panic
+	defer w.beat(ctx, job.ID, logger)()
+
+	w.processJob(jobCtx, job, logger)
+	if errors.Is(context.Cause(jobCtx), errDrainDeadline) &&
+		(job.Status == domain.JobStatusProcessing || job.Status == domain.JobStatusFailed) {
+		w.checkpoint(ctx, job, logger)
+		return
+	}
+	defer w.drain.handled()
+	if job.Status == domain.JobStatusProcessing {
+		return
+	}
+	if err := w.queue.Ack(ctx, job.ID); err != nil {
+		logger.Warn("Failed to acknowledge job", zap.String("job_id", job.ID), zap.Error(err))
+	}
+	if job.Status == domain.JobStatusQueued && job.NextAttemptAt != nil {
+		w.requeueAt(ctx, job, logger)
+	}
+	if job.IsComplete() && w.onFinished != nil {
+		w.onFinished(ctx, job)
+	}
+}
+
+// watchCancel returns a context for processing the job that is cancelled, with
+// errJobCancelled as its cause, when cancellation of the job is requested.
+func (w *Worker) watchCancel(ctx context.Context, jobID string) (context.Context, context.CancelFunc) {
+	jobCtx, cancel := context.WithCancelCause(ctx)
+	requested := w.queue.CancelRequested(jobCtx, jobID)
+	select {
+	case <-requested:
+		cancel(errJobCancelled)
+	default:
+		go func() {
+			select {
+			case <-requested:
+				cancel(errJobCancelled)
+			case <-jobCtx.Done():
+			}
+		}()
+	}
+	return jobCtx, func() { cancel(nil) }
+}
+
+// cancelled reports whether cancellation of the job was requested, and if so
+// marks it cancelled. A job that finished in the meantime is left as it is.
+func (w *Worker) cancelled(ctx context.Context, job *domain.Job, logger *zap.Logger) bool {
+	if !errors.Is(context.Cause(ctx), errJobCancelled) {
+		return false
+	}
+	stage := "cancelled before processing"
+	if job.Status == domain.JobStatusProcessing {
+		stage = "cancelled while processing"
+	}
+	if err := job.SetCancelled(stage); err != nil {
+		return false
+	}
+	w.queue.UpdateJob(context.WithoutCancel(ctx), job) //nolint:errcheck
+	logger.Info("Job cancelled", zap.String("stage", stage))
+	return true
+}
+
+// checkSilence returns errSilentAudio when silence detection is on and audio
+// has no audible sound although job's text has letters or digits to speak.
+// Audio that cannot be measured, e.g. without ffmpeg, is let through.
+func (w *Worker) checkSilence(ctx context.Context, job *domain.Job, audio []byte, format string, logger *zap.Logger) error {
+	if w.silenceDB == 0 || !strings.ContainsFunc(job.Text, func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsNumber(r)
+	}) {
+		return nil
 	}
+	report, err := quality.Measure(ctx, audio, format)
+	if err != nil {
+		logger.Debug("Skipping silence detection", zap.Error(err))
+		return nil
+	}
+	if report.PeakDB < w.silenceDB {
+		return fmt.Errorf("%w: peak %.1f dBFS", errSilentAudio, report.PeakDB)
+	}
+	return nil
 }
 
 func (w *Worker) processJob(ctx context.Context, job *domain.Job, logger *zap.Logger) {
 	logger = logger.With(zap.String("job_id", job.ID))
+	if w.cancelled(ctx, job, logger) {
+		return
+	}
+	if job.DeadlinePassed(time.Now()) {
+		w.failDeadline(ctx, job, logger)
+		return
+	}
 	logger.Info("Processing job", zap.String("provider", job.ProviderName))
 
 	// Get provider from registry
@@ -105,59 +372,213 @@ func (w *Worker) processJob(ctx context.Context, job *domain.Job, logger *zap.Lo
 
 	// Mark as processing
 	job.SetProcessing()
+	if job.MaxAttempts == 0 {
+		job.MaxAttempts = w.retry.MaxAttempts
+	}
 	if err := w.queue.UpdateJob(ctx, job); err != nil {
 		logger.Error("Failed to update job status", zap.Error(err))
 		return
 	}
 
+	// Fetch text the job references instead of carrying inline
+	if job.Text == "" && job.Source != nil && !w.fetchText(ctx, job, logger) {
+		return
+	}
+	if w.cancelled(ctx, job, logger) {
+		return
+	}
+
 	// Estimate completion time based on text length
-	estimatedDuration := w.estimateDuration(len(job.Text))
+	estimatedDuration := w.degradedEstimate(job.ProviderName, w.estimateDuration(len(job.Text)))
 	estimatedCompletion := time.Now().Add(estimatedDuration)
 	job.UpdateProgress(10, &estimatedCompletion)
 	w.queue.UpdateJob(ctx, job) //nolint:errcheck
 
-	// Build synthesis request
-	req := &domain.SynthesisRequest{
-		Text:         job.Text,
-		VoiceID:      job.VoiceID,
-		ModelID:      job.ModelID,
-		LanguageCode: job.LanguageCode,
-		OutputFormat: job.OutputFormat,
-		Settings:     job.VoiceSettings,
+	// A job identical to an earlier one takes its audio, unless it is warming the
+	// speech cache, which may be meant to refresh it
+	if w.resultCache != nil && job.CacheKey == "" {
+		audio, ok := w.resultCache.Get(ctx, speechcache.RequestForJob(job).Key())
+		var duration time.Duration
+		if ok {
+			// A damaged entry is synthesized again rather than served
+			var err error
+			duration, err = validate.Check(audio, job.OutputFormat)
+			ok = err == nil
+		}
+		w.cacheMetrics.Observe(metrics.SourceAsync, ok, utf8.RuneCountInString(job.Text))
+		if ok {
+			job.AudioSeconds = duration.Seconds()
+			job.AddEvent(domain.JobEventCacheHit, "audio of an identical earlier request reused")
+			w.complete(ctx, job, audio, logger)
+			return
+		}
+	}
+
+	// Text stages of the pipeline rewrite what is synthesized
+	stages, err := pipeline.Compile(job.Pipeline)
+	var text string
+	switch {
+	case err != nil:
+	case job.SpokenText != "":
+		// A retry speaks what the first attempt's stages wrote, without asking again
+		text = job.SpokenText
+	default:
+		text, err = stages.Text(ctx, job.Text)
+	}
+	if err != nil {
+		logger.Error("Pipeline failed", zap.Error(err))
+		job.SetFailed(err.Error())
+		w.queue.UpdateJob(ctx, job) //nolint:errcheck
+		return
+	}
+	if stages.ReplacesText() && job.SpokenText == "" {
+		job.SpokenText = text
+		job.AddEvent(domain.JobEventRewritten, fmt.Sprintf("%d characters replaced with %d to speak",
+			utf8.RuneCountInString(job.Text), utf8.RuneCountInString(text)))
 	}
 
 	// Update progress to 30%
 	job.UpdateProgress(30, &estimatedCompletion)
 	w.queue.UpdateJob(ctx, job) //nolint:errcheck
 
-	// Synthesize audio
-	result, err := provider.Synthesize(ctx, req)
+	// Synthesize audio, failing over to the provider's fallbacks
+	result, adjust, transient, err := w.synthesizeText(ctx, job, provider, text, &estimatedCompletion, logger)
+	if w.cancelled(ctx, job, logger) {
+		return
+	}
+	if errors.Is(err, errDeadlinePassed) {
+		w.failDeadline(ctx, job, logger)
+		return
+	}
 	if err != nil {
+		if transient != nil && job.Attempts < job.MaxAttempts {
+			w.scheduleRetry(ctx, job, transient, logger)
+			return
+		}
 		logger.Error("Synthesis failed", zap.Error(err))
 		job.SetFailed(err.Error())
 		w.queue.UpdateJob(ctx, job) //nolint:errcheck
 		return
 	}
 
+	if result.Duration > 0 {
+		job.AudioSeconds = result.Duration.Seconds()
+	}
+
 	// Update progress to 70%
 	job.UpdateProgress(70, &estimatedCompletion)
 	w.queue.UpdateJob(ctx, job) //nolint:errcheck
 
-	// Read audio data
-	audioData, err := io.ReadAll(result.Audio)
+	// Read audio data. The buffer is reused once the job is done with it, so
+	// nothing below may keep audioData.
+	audioBuf, err := bufpool.ReadAll(result.Audio)
 	if err != nil {
+		if w.cancelled(ctx, job, logger) {
+			return
+		}
 		logger.Error("Failed to read audio data", zap.Error(err))
 		job.SetFailed(err.Error())
 		w.queue.UpdateJob(ctx, job) //nolint:errcheck
 		return
 	}
+	defer bufpool.Put(audioBuf)
+	audioData := audioBuf.Bytes()
+
+	// Providers occasionally answer with an error body, or cut the audio short
+	synthFormat := transcode.SynthesisFormat(job.OutputFormat)
+	duration, err := validate.Check(audioData, synthFormat)
+	if err == nil {
+		err = w.checkSilence(ctx, job, audioData, synthFormat, logger)
+	}
+	if err != nil {
+		logger.Warn("Provider returned invalid audio", zap.String("provider", job.ResultProvider), zap.Error(err))
+		if job.Attempts < job.MaxAttempts {
+			w.scheduleRetry(ctx, job, err, logger)
+			return
+		}
+		code := domain.JobErrInvalidAudio
+		switch {
+		case errors.Is(err, validate.ErrEmpty):
+			code = domain.JobErrEmptyAudio
+		case errors.Is(err, errSilentAudio):
+			code = domain.JobErrSilentAudio
+		}
+		job.SetFailedWithCode(code, err.Error())
+		w.queue.UpdateJob(ctx, job) //nolint:errcheck
+		return
+	}
+	if job.AudioSeconds == 0 {
+		job.AudioSeconds = duration.Seconds()
+	}
+
+	if !adjust.IsZero() {
+		processed, err := effects.Apply(ctx, audioData, synthFormat, adjust)
+		switch {
+		case errors.Is(err, effects.ErrUnsupportedInput):
+			logger.Warn("Skipping audio post-processing for unsupported input")
+		case err != nil:
+			if w.cancelled(ctx, job, logger) {
+				return
+			}
+			logger.Error("Audio post-processing failed", zap.Error(err))
+			job.SetFailed(err.Error())
+			w.queue.UpdateJob(ctx, job) //nolint:errcheck
+			return
+		default:
+			audioData = processed
+		}
+	}
+
+	if stages.HasAudio() {
+		processed, err := stages.Audio(ctx, audioData, synthFormat)
+		switch {
+		case errors.Is(err, effects.ErrUnsupportedInput):
+			logger.Warn("Skipping pipeline audio stages for unsupported input")
+		case err != nil:
+			if w.cancelled(ctx, job, logger) {
+				return
+			}
+			logger.Error("Pipeline audio stage failed", zap.Error(err))
+			job.SetFailed(err.Error())
+			w.queue.UpdateJob(ctx, job) //nolint:errcheck
+			return
+		default:
+			audioData = processed
+		}
+	}
+
+	// Formats no provider is asked for are encoded from the processed WAV
+	if synthFormat != job.OutputFormat {
+		encoded, err := transcode.Convert(ctx, audioData, job.OutputFormat)
+		if err != nil {
+			if w.cancelled(ctx, job, logger) {
+				return
+			}
+			logger.Error("Failed to encode audio", zap.String("format", job.OutputFormat), zap.Error(err))
+			job.SetFailed(err.Error())
+			w.queue.UpdateJob(ctx, job) //nolint:errcheck
+			return
+		}
+		audioData = encoded
+	}
+
+	if w.cancelled(ctx, job, logger) {
+		return
+	}
 
 	// Update progress to 90%
 	job.UpdateProgress(90, nil)
 	w.queue.UpdateJob(ctx, job) //nolint:errcheck
 
-	// Store audio
-	resultPath, err := w.storage.Store(ctx, job.ID, audioData, job.OutputFormat)
+	w.storeInResultCache(ctx, job, audioData, logger)
+	w.complete(ctx, job, audioData, logger)
+}
+
+// complete stores audio as the job's result, with its artifacts, and marks the
+// job completed.
+func (w *Worker) complete(ctx context.Context, job *domain.Job, audio []byte, logger *zap.Logger) {
+	ctx = domain.WithTenant(ctx, job.Tenant())
+	resultPath, err := w.storage.Store(ctx, job.ID, audio, job.OutputFormat)
 	if err != nil {
 		logger.Error("Failed to store audio", zap.Error(err))
 		job.SetFailed(err.Error())
@@ -165,8 +586,17 @@ func (w *Worker) processJob(ctx context.Context, job *domain.Job, logger *zap.Lo
 		return
 	}
 
+	w.storePreview(ctx, job, audio, logger)
+	w.storeWaveform(ctx, job, audio, logger)
+	w.storeInSpeechCache(ctx, job, audio, logger)
+
 	// Mark as completed
+	job.ResultSizeBytes = int64(len(audio))
 	job.SetCompleted(resultPath, w.retentionHours)
+	policy := domain.RetentionPolicy{Result: time.Duration(w.retentionHours) * time.Hour, Artifacts: w.artifactTTL}
+	for _, name := range job.Artifacts {
+		job.RetainArtifact(name, policy)
+	}
 	if err := w.queue.UpdateJob(ctx, job); err != nil {
 		logger.Error("Failed to update job status", zap.Error(err))
 		return
@@ -174,10 +604,459 @@ func (w *Worker) processJob(ctx context.Context, job *domain.Job, logger *zap.Lo
 
 	logger.Info("Job completed successfully",
 		zap.String("result_path", resultPath),
-		zap.Int("audio_size", len(audioData)),
+		zap.Int("audio_size", len(audio)),
 	)
 }
 
+// synthesizeText synthesizes text like synthesize, in chunks when it is longer
+// than the job's providers accept per request. Chunks end at sentence boundaries
+// and are synthesized up to the provider's parallel_chunks at once, each moving
+// the job's progress on from 30 towards 70%. Their audio is joined into one
+// result. All chunks come from the same provider, so the result has one voice:
+// when a chunk fails, the whole text fails over to the next fallback. A text
+// that fails on the last one fails, so a retry starts over. Text synthesized in
+// one request moves the progress on as its audio downloads instead.
+func (w *Worker) synthesizeText(ctx context.Context, job *domain.Job, provider domain.TTSProvider, text string, estimatedCompletion *time.Time, logger *zap.Logger) (*domain.SynthesisResult, effects.Options, error, error) {
+	var mu sync.Mutex
+	candidates := w.candidates(job, provider, logger)
+	maxLen, parallel := w.textLimits(job)
+	chunks := textinfo.Split(text, maxLen)
+	if len(chunks) == 1 {
+		download := &downloadTracker{worker: w, ctx: ctx, job: job, estimatedCompletion: estimatedCompletion}
+		result, adjust, transient, err := w.synthesize(ctx, job, candidates, text, nil, download.progress, &mu, logger)
+		if err == nil {
+			download.record()
+		}
+		return result, adjust, transient, err
+	}
+
+	logger.Info("Synthesizing text in chunks", zap.Int("chunks", len(chunks)), zap.Int("max_text_length", maxLen))
+	job.AddEvent(domain.JobEventChunked, fmt.Sprintf("%d characters split into %d chunks of up to %d",
+		utf8.RuneCountInString(text), len(chunks), maxLen))
+	w.queue.UpdateJob(ctx, job) //nolint:errcheck
+
+	var adjust effects.Options
+	var parts [][]byte
+	var durations []time.Duration
+	var transient, err error
+	for i, candidate := range candidates {
+		last := i == len(candidates)-1
+		if !last && !candidate.IsAvailable(ctx) {
+			w.failover(ctx, job, candidate.Name(), "unavailable", candidates[i+1].Name(), logger)
+			continue
+		}
+		var chunkTransient error
+		parts, durations, adjust, chunkTransient, err = w.synthesizeChunks(ctx, job, candidate, chunks, parallel, estimatedCompletion, logger)
+		if err == nil {
+			break
+		}
+		if transient == nil {
+			transient = chunkTransient
+		}
+		if last || ctx.Err() != nil || errors.Is(err, errDeadlinePassed) {
+			return nil, adjust, transient, err
+		}
+		w.failover(ctx, job, candidate.Name(), err.Error(), candidates[i+1].Name(), logger)
+	}
+
+	audio, err := transcode.Concat(parts, transcode.SynthesisFormat(job.OutputFormat))
+	if err != nil {
+		return nil, adjust, nil, err
+	}
+	result := &domain.SynthesisResult{Audio: bytes.NewReader(audio), SizeBytes: int64(len(audio))}
+	for _, d := range durations {
+		if d <= 0 {
+			// Unknown for a chunk, so for the whole; it is measured from the audio
+			result.Duration = 0
+			break
+		}
+		result.Duration += d
+	}
+	return result, adjust, nil, nil
+}
+
+// synthesizeChunks synthesizes every chunk with provider, up to parallel at
+// once, and returns their audio and durations in order. The first chunk that
+// fails stops the others.
+func (w *Worker) synthesizeChunks(ctx context.Context, job *domain.Job, provider domain.TTSProvider, chunks []string, parallel int, estimatedCompletion *time.Time, logger *zap.Logger) (parts [][]byte, durations []time.Duration, adjust effects.Options, transient, failed error) {
+	chunkCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	parts = make([][]byte, len(chunks))
+	durations = make([]time.Duration, len(chunks))
+	candidates := []domain.TTSProvider{provider}
+	var mu sync.Mutex
+	var requestIDs []string
+	done := 0
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(parallel, len(chunks)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				// Chunks synthesized one after another are stitched by providers
+				// that support it, for prosody that carries across chunks
+				var previous []string
+				if parallel == 1 {
+					previous = requestIDs
+				}
+				result, chunkAdjust, chunkTransient, err := w.synthesize(chunkCtx, job, candidates, chunks[i], previous, nil, &mu, logger)
+				var audio []byte
+				if err == nil {
+					audio, err = io.ReadAll(result.Audio)
+				}
+
+				mu.Lock()
+				adjust = chunkAdjust
+				if err != nil {
+					if failed == nil {
+						failed, transient = err, chunkTransient
+						cancel()
+					}
+				} else {
+					parts[i], durations[i] = audio, result.Duration
+					if result.RequestID != "" {
+						requestIDs = append(requestIDs, result.RequestID)
+					}
+					done++
+					job.UpdateProgress(30+40*float64(done)/float64(len(chunks)), estimatedCompletion)
+					w.queue.UpdateJob(ctx, job) //nolint:errcheck
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range chunks {
+		if chunkCtx.Err() != nil {
+			break
+		}
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return parts, durations, adjust, transient, failed
+}
+
+// textLimits returns the longest text the job's provider and its fallbacks all
+// accept per request (0 when unlimited), and how many chunks of a longer text
+// its provider synthesizes at once.
+func (w *Worker) textLimits(job *domain.Job) (maxLen, parallel int) {
+	limits, ok := w.registry.(domain.ProviderTextLimits)
+	if !ok {
+		return 0, 1
+	}
+	names := []string{job.ProviderName}
+	if fallbacks, ok := w.registry.(domain.ProviderFallbacks); ok {
+		names = append(names, fallbacks.Fallbacks(job.ProviderName)...)
+	}
+	for _, name := range names {
+		if n := limits.MaxTextLength(name); n > 0 && (maxLen == 0 || n < maxLen) {
+			maxLen = n
+		}
+	}
+	return maxLen, limits.ParallelChunks(job.ProviderName)
+}
+
+// downloadProgressInterval is the least time between two progress updates of a
+// job whose audio is downloading, so fast downloads don't flood the queue.
+const downloadProgressInterval = 250 * time.Millisecond
+
+// downloadTracker moves a job's progress from 30 towards 70% as its provider
+// downloads the audio, when the upstream service announced its size, and
+// records the download's throughput.
+type downloadTracker struct {
+	worker              *Worker
+	ctx                 context.Context
+	job                 *domain.Job
+	estimatedCompletion *time.Time
+
+	start    time.Time
+	received int64
+	updated  time.Time
+}
+
+func (d *downloadTracker) progress(received, total int64) {
+	now := time.Now()
+	if received == 0 {
+		// A download starts, or a fallback provider's starts over
+		d.start, d.updated = now, now
+	}
+	d.received = received
+	if total <= 0 || now.Sub(d.updated) < downloadProgressInterval {
+		return
+	}
+	d.updated = now
+	d.job.UpdateProgress(30+40*float64(min(received, total))/float64(total), d.estimatedCompletion)
+	d.worker.queue.UpdateJob(d.ctx, d.job) //nolint:errcheck
+}
+
+// record adds the size and throughput of the download, if any, to the job's
+// events.
+func (d *downloadTracker) record() {
+	if d.start.IsZero() {
+		return
+	}
+	elapsed := time.Since(d.start)
+	message := fmt.Sprintf("%d bytes in %s", d.received, elapsed.Round(time.Millisecond))
+	if elapsed > 0 {
+		message += fmt.Sprintf(" (%.1f KiB/s)", float64(d.received)/1024/elapsed.Seconds())
+	}
+	d.job.AddEvent(domain.JobEventDownloaded, message)
+}
+
+// candidates returns provider followed by the job's fallbacks, in the order
+// they are tried.
+func (w *Worker) candidates(job *domain.Job, provider domain.TTSProvider, logger *zap.Logger) []domain.TTSProvider {
+	candidates := []domain.TTSProvider{provider}
+	if fallbacks, ok := w.registry.(domain.ProviderFallbacks); ok {
+		for _, name := range fallbacks.Fallbacks(job.ProviderName) {
+			fallback, err := w.registry.Get(name)
+			if err != nil {
+				logger.Warn("Fallback provider not found", zap.String("provider", name))
+				continue
+			}
+			candidates = append(candidates, fallback)
+		}
+	}
+	return candidates
+}
+
+// synthesize runs text through the first of candidates and, when that fails or
+// the provider reports itself unavailable, through the others in order. Each
+// provider the job moves on from is recorded as a failover event, and
+// job.ResultProvider is set to the one that produced the result. adjust is the
+// post-processing that provider needs. On failure, err is the last provider's
+// error and transient the first transient one, if any, so the job can be retried
+// later. previous are the request IDs of the text's preceding chunks, progress,
+// when set, is told of the download of the audio, and mu guards the job against
+// the other chunks synthesized at once.
+func (w *Worker) synthesize(ctx context.Context, job *domain.Job, candidates []domain.TTSProvider, text string, previous []string, progress func(received, total int64), mu *sync.Mutex, logger *zap.Logger) (result *domain.SynthesisResult, adjust effects.Options, transient, err error) {
+	// Provider calls carry the job's deadline, and none starts once it passed
+	synthCtx, cancel := deadline.WithJob(ctx, job.Deadline)
+	defer cancel()
+
+	for i, candidate := range candidates {
+		if job.DeadlinePassed(time.Now()) {
+			return nil, adjust, nil, errDeadlinePassed
+		}
+		name := candidate.Name()
+		last := i == len(candidates)-1
+		if !last && !candidate.IsAvailable(synthCtx) {
+			mu.Lock()
+			w.failover(ctx, job, name, "unavailable", candidates[i+1].Name(), logger)
+			mu.Unlock()
+			continue
+		}
+
+		// Speed/pitch the provider can't render natively, and padding, are applied after synthesis
+		var settings *domain.VoiceSettings
+		adjust, settings = effects.Plan(candidate, job.VoiceSettings)
+		adjust = adjust.WithPadding(job.Padding)
+
+		release := func() {}
+		if w.limits != nil {
+			if release, err = w.limits.acquire(synthCtx, candidate); err != nil {
+				if ctx.Err() != nil {
+					return nil, adjust, nil, ctx.Err()
+				}
+				return nil, adjust, nil, errDeadlinePassed
+			}
+		}
+		start := time.Now()
+		result, err = candidate.Synthesize(synthCtx, &domain.SynthesisRequest{
+			Text:         text,
+			VoiceID:      job.VoiceID,
+			ModelID:      job.ModelID,
+			LanguageCode: job.LanguageCode,
+			OutputFormat: transcode.SynthesisFormat(job.OutputFormat),
+			Settings:     settings,
+
+			PreviousRequestIDs: previous,
+			Progress:           progress,
+		})
+		release()
+		if ctx.Err() != nil {
+			// An aborted request says nothing about the provider's health.
+			return nil, adjust, nil, ctx.Err()
+		}
+		if err != nil && job.DeadlinePassed(time.Now()) {
+			// Nor does one the job's deadline cut short.
+			return nil, adjust, nil, errDeadlinePassed
+		}
+		w.registry.Observe(name, len(text), time.Since(start), err)
+		if err == nil {
+			mu.Lock()
+			job.ResultProvider = name
+			mu.Unlock()
+			return result, adjust, nil, nil
+		}
+		if transient == nil && isTransient(err) {
+			transient = err
+		}
+		if !last {
+			mu.Lock()
+			w.failover(ctx, job, name, err.Error(), candidates[i+1].Name(), logger)
+			mu.Unlock()
+		}
+	}
+	return nil, adjust, transient, err
+}
+
+// failover records on the job that provider was given up on for reason and next
+// is tried instead.
+func (w *Worker) failover(ctx context.Context, job *domain.Job, provider, reason, next string, logger *zap.Logger) {
+	logger.Warn("Failing over to fallback provider",
+		zap.String("provider", provider), zap.String("reason", reason), zap.String("fallback", next))
+	job.AddEvent(domain.JobEventFailover, fmt.Sprintf("%s: %s; trying %s", provider, reason, next))
+	w.queue.UpdateJob(ctx, job) //nolint:errcheck
+}
+
+// fetchText resolves the job's text source into job.Text. On failure the job is
+// failed with the source's error code and false is returned.
+func (w *Worker) fetchText(ctx context.Context, job *domain.Job, logger *zap.Logger) bool {
+	var err error
+	var text string
+	if w.sources == nil {
+		err = domain.NewTextSourceError(domain.SourceErrUnsupported, "text sources are not enabled", nil)
+	} else {
+		text, err = w.sources.Fetch(ctx, job.Source)
+	}
+	if w.cancelled(ctx, job, logger) {
+		return false
+	}
+	if err != nil {
+		code := domain.SourceErrFetchFailed
+		if serr, ok := domain.AsTextSourceError(err); ok {
+			code = serr.Code
+		}
+		logger.Warn("Failed to fetch job text", zap.String("source", job.Source.Type), zap.String("code", code), zap.Error(err))
+		job.SetFailedWithCode(code, err.Error())
+		w.queue.UpdateJob(ctx, job) //nolint:errcheck
+		return false
+	}
+
+	job.Text = text
+	job.AddEvent(domain.JobEventSourceFetched, fmt.Sprintf("fetched %d chars from %s source",
+		utf8.RuneCountInString(text), job.Source.Type))
+	w.queue.UpdateJob(ctx, job) //nolint:errcheck
+	return true
+}
+
+// storePreview saves a short low-bitrate clip of the result for instant playback.
+// Failures are logged and don't fail the job; the preview is a convenience.
+func (w *Worker) storePreview(ctx context.Context, job *domain.Job, audio []byte, logger *zap.Logger) {
+	if w.previewSeconds <= 0 {
+		return
+	}
+
+	clip, err := transcode.Preview(ctx, transcode.Decodable(audio, job.OutputFormat), w.previewSeconds)
+	if err != nil {
+		logger.Warn("Failed to generate preview clip", zap.Error(err))
+		return
+	}
+	if err := w.storage.StoreArtifact(ctx, job.ID, domain.ArtifactPreview, clip); err != nil {
+		logger.Warn("Failed to store preview clip", zap.Error(err))
+		return
+	}
+	job.AddArtifact(domain.ArtifactPreview)
+}
+
+// storeWaveform saves the peaks JSON of the result for web players.
+// Failures are logged and don't fail the job.
+func (w *Worker) storeWaveform(ctx context.Context, job *domain.Job, audio []byte, logger *zap.Logger) {
+	peaks, err := waveform.Generate(ctx, audio, job.OutputFormat)
+	if err != nil {
+		logger.Warn("Failed to generate waveform", zap.Error(err))
+		return
+	}
+	data, err := json.Marshal(peaks)
+	if err != nil {
+		logger.Warn("Failed to encode waveform", zap.Error(err))
+		return
+	}
+	if err := w.storage.StoreArtifact(ctx, job.ID, domain.ArtifactWaveform, data); err != nil {
+		logger.Warn("Failed to store waveform", zap.Error(err))
+		return
+	}
+	job.AddArtifact(domain.ArtifactWaveform)
+	if peaks.SampleRate > 0 {
+		// The peaks cover the stored audio, so unlike the provider's duration they
+		// include padding and speed changes.
+		job.AudioSeconds = float64(peaks.Length*peaks.SamplesPerPixel) / float64(peaks.SampleRate)
+	}
+}
+
+// storeInSpeechCache keeps the audio of a cache-warming job for the sync endpoint.
+// A failure is logged; the job itself still completes.
+func (w *Worker) storeInSpeechCache(ctx context.Context, job *domain.Job, audio []byte, logger *zap.Logger) {
+	if job.CacheKey == "" || w.speechCache == nil {
+		return
+	}
+	if err := w.speechCache.Put(ctx, job.CacheKey, audio); err != nil {
+		logger.Warn("Failed to store speech cache entry", zap.Error(err))
+	}
+}
+
+// storeInResultCache keeps the audio of a job for identical later requests. A
+// failure is logged; the job itself still completes.
+func (w *Worker) storeInResultCache(ctx context.Context, job *domain.Job, audio []byte, logger *zap.Logger) {
+	if w.resultCache == nil {
+		return
+	}
+	if err := w.resultCache.Put(ctx, speechcache.RequestForJob(job).Key(), audio); err != nil {
+		logger.Warn("Failed to store result cache entry", zap.Error(err))
+	}
+}
+
+// scheduleRetry puts a job whose attempt failed with the transient error cause
+// back in the queued state for the retry policy's delay. handle requeues it at
+// that time.
+func (w *Worker) scheduleRetry(ctx context.Context, job *domain.Job, cause error, logger *zap.Logger) {
+	delay := w.retry.Delay(job.Attempts, cause)
+	if job.DeadlinePassed(time.Now().Add(delay)) {
+		w.failDeadline(ctx, job, logger)
+		return
+	}
+
+	job.SetRetrying(time.Now().Add(delay))
+	job.AddEvent(domain.JobEventRetrying, fmt.Sprintf("attempt %d of %d failed: %s; retrying in %s",
+		job.Attempts, job.MaxAttempts, cause, delay.Round(time.Millisecond)))
+	w.queue.UpdateJob(ctx, job) //nolint:errcheck
+
+	logger.Warn("Synthesis failed with a transient error, retry scheduled",
+		zap.Error(cause),
+		zap.Duration("retry_after", delay),
+		zap.Int("attempts", job.Attempts),
+	)
+}
+
+// failDeadline fails a job whose deadline passed, or would pass before its next
+// attempt.
+func (w *Worker) failDeadline(ctx context.Context, job *domain.Job, logger *zap.Logger) {
+	logger.Warn("Job deadline passed", zap.Time("deadline", *job.Deadline))
+	job.SetFailedWithCode(domain.JobErrDeadlineExceeded, "deadline "+deadline.Format(*job.Deadline)+" passed before the job was synthesized")
+	w.queue.UpdateJob(ctx, job) //nolint:errcheck
+}
+
+// requeueAt returns a job scheduled for a retry to the queue at its retry time,
+// unless the workers stopped or the job was cancelled in the meantime.
+func (w *Worker) requeueAt(ctx context.Context, job *domain.Job, logger *zap.Logger) {
+	time.AfterFunc(time.Until(*job.NextAttemptAt), func() {
+		if ctx.Err() != nil {
+			return
+		}
+		if current, err := w.queue.GetJob(ctx, job.ID); err != nil || current.Status == domain.JobStatusCancelled {
+			return
+		}
+		if err := w.queue.Enqueue(ctx, job); err != nil {
+			logger.Error("Failed to requeue job for retry", zap.String("job_id", job.ID), zap.Error(err))
+			job.SetFailed("Failed to requeue for retry: " + err.Error())
+			w.queue.UpdateJob(ctx, job) //nolint:errcheck
+		}
+	})
+}
+
 // estimateDuration estimates synthesis duration based on text length.
 // Rough estimate: 1000 characters ≈ 5 seconds of synthesis time.
 func (w *Worker) estimateDuration(textLength int) time.Duration {
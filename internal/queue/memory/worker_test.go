@@ -3,16 +3,28 @@ package memory
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
+	"net/http"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"go.uber.org/zap"
 
+	"github.com/pako-tts/server/internal/audio/transcode"
 	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/pipeline"
+	"github.com/pako-tts/server/internal/speechcache"
 )
 
+// testMP3 is a single MPEG-2 layer III frame, the smallest audio that passes
+// validation.
+var testMP3 = append([]byte{0xFF, 0xF3, 0x40, 0xC4}, make([]byte, 100)...)
+
 // fakeProvider is a minimal in-package stub of domain.TTSProvider for worker tests.
 type fakeProvider struct {
 	mu       sync.Mutex
@@ -35,9 +47,9 @@ func (p *fakeProvider) Synthesize(ctx context.Context, req *domain.SynthesisRequ
 	default:
 	}
 	return &domain.SynthesisResult{
-		Audio:       bytes.NewReader([]byte("audio")),
+		Audio:       bytes.NewReader(testMP3),
 		ContentType: "audio/mpeg",
-		SizeBytes:   5,
+		SizeBytes:   int64(len(testMP3)),
 	}, nil
 }
 func (p *fakeProvider) ListVoices(ctx context.Context) ([]domain.Voice, error) { return nil, nil }
@@ -70,6 +82,9 @@ func (r *fakeRegistry) Default() domain.TTSProvider                       { retu
 func (r *fakeRegistry) List() []domain.TTSProvider                        { return []domain.TTSProvider{r.provider} }
 func (r *fakeRegistry) DefaultName() string                               { return r.provider.Name() }
 func (r *fakeRegistry) ListInfo(ctx context.Context) []domain.ProviderInfo { return nil }
+func (r *fakeRegistry) Route(ctx context.Context, textLength int) string   { return r.provider.Name() }
+func (r *fakeRegistry) Observe(name string, textLength int, latency time.Duration, err error) {
+}
 
 // fakeStorage is an in-package stub of domain.AudioStorage.
 type fakeStorage struct{}
@@ -85,6 +100,12 @@ func (s *fakeStorage) Exists(ctx context.Context, jobID string) bool  { return t
 func (s *fakeStorage) GetPath(ctx context.Context, jobID string) string {
 	return "/tmp/" + jobID
 }
+func (s *fakeStorage) StoreArtifact(ctx context.Context, jobID, name string, data []byte) error {
+	return nil
+}
+func (s *fakeStorage) RetrieveArtifact(ctx context.Context, jobID, name string) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(nil)), nil
+}
 
 func TestWorker_PropagatesJobModelIDToSynthesisRequest(t *testing.T) {
 	logger := zap.NewNop()
@@ -93,7 +114,7 @@ func TestWorker_PropagatesJobModelIDToSynthesisRequest(t *testing.T) {
 	registry := &fakeRegistry{provider: provider}
 	storage := &fakeStorage{}
 
-	worker := NewWorker(queue, registry, storage, logger, 24)
+	worker := NewWorker(queue, registry, storage, logger, 24, 0, nil, nil, RetryPolicy{})
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -132,7 +153,7 @@ func TestWorker_PropagatesJobLanguageCodeToSynthesisRequest(t *testing.T) {
 	registry := &fakeRegistry{provider: provider}
 	storage := &fakeStorage{}
 
-	worker := NewWorker(queue, registry, storage, logger, 24)
+	worker := NewWorker(queue, registry, storage, logger, 24, 0, nil, nil, RetryPolicy{})
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -160,3 +181,934 @@ func TestWorker_PropagatesJobLanguageCodeToSynthesisRequest(t *testing.T) {
 		t.Errorf("expected SynthesisRequest.LanguageCode %q, got %q", "es", captured.LanguageCode)
 	}
 }
+
+func TestWorker_SynthesizesDerivedFormatsAsWAV(t *testing.T) {
+	logger := zap.NewNop()
+	queue := NewQueue(10)
+	provider := newFakeProvider()
+	registry := &fakeRegistry{provider: provider}
+	storage := &fakeStorage{}
+
+	worker := NewWorker(queue, registry, storage, logger, 24, 0, nil, nil, RetryPolicy{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	worker.Start(ctx, 1)
+	defer worker.Stop()
+
+	job := domain.NewJob("hello", "voice1", "", "", "fake-provider", "flac", nil)
+	if err := queue.Enqueue(ctx, job); err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+
+	select {
+	case <-provider.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for worker to call Synthesize")
+	}
+
+	// flac is encoded from WAV after synthesis; providers are never asked for it
+	captured := provider.capturedRequest()
+	if captured == nil {
+		t.Fatal("expected provider.Synthesize to be called")
+	}
+	if captured.OutputFormat != "wav" {
+		t.Errorf("expected SynthesisRequest.OutputFormat %q, got %q", "wav", captured.OutputFormat)
+	}
+}
+
+// rateLimitedProvider answers 429 with a Retry-After hint on the first call and succeeds afterwards.
+type rateLimitedProvider struct {
+	fakeProvider
+	calls int32
+}
+
+func (p *rateLimitedProvider) Synthesize(ctx context.Context, req *domain.SynthesisRequest) (*domain.SynthesisResult, error) {
+	if atomic.AddInt32(&p.calls, 1) == 1 {
+		return nil, &domain.ProviderError{
+			Provider:   p.Name(),
+			StatusCode: http.StatusTooManyRequests,
+			Message:    "rate limited",
+			RetryAfter: 20 * time.Millisecond,
+		}
+	}
+	return p.fakeProvider.Synthesize(ctx, req)
+}
+
+func TestWorker_RetriesRateLimitedJobAfterRetryAfter(t *testing.T) {
+	logger := zap.NewNop()
+	queue := NewQueue(10)
+	provider := &rateLimitedProvider{fakeProvider: *newFakeProvider()}
+	registry := &fakeRegistry{provider: provider}
+
+	worker := NewWorker(queue, registry, &fakeStorage{}, logger, 24, 0, nil, nil, RetryPolicy{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	worker.Start(ctx, 1)
+	defer worker.Stop()
+
+	job := domain.NewJob("hello", "voice1", "", "", "fake-provider", "mp3", nil)
+	if err := queue.Enqueue(ctx, job); err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+
+	select {
+	case <-provider.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the retried synthesis")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		stored, _ := queue.GetJob(ctx, job.ID)
+		if stored.Status == domain.JobStatusCompleted {
+			if stored.Attempts != 2 {
+				t.Errorf("expected 2 attempts, got %d", stored.Attempts)
+			}
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("job did not complete after rate-limit retry")
+}
+
+// fakeSources resolves every source to a fixed text, or fails with err.
+type fakeSources struct {
+	text string
+	err  error
+}
+
+func (s *fakeSources) Validate(src *domain.TextSource) error { return nil }
+func (s *fakeSources) Fetch(ctx context.Context, src *domain.TextSource) (string, error) {
+	return s.text, s.err
+}
+
+func TestWorker_FetchesTextFromSource(t *testing.T) {
+	queue := NewQueue(10)
+	provider := newFakeProvider()
+	worker := NewWorker(queue, &fakeRegistry{provider: provider}, &fakeStorage{}, zap.NewNop(), 24, 0,
+		&fakeSources{text: "fetched text"}, nil, RetryPolicy{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	worker.Start(ctx, 1)
+	defer worker.Stop()
+
+	job := domain.NewJob("", "voice1", "", "", "fake-provider", "mp3", nil)
+	job.Source = &domain.TextSource{Type: domain.TextSourceURL, URL: "https://example.com/a.txt"}
+	if err := queue.Enqueue(ctx, job); err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+
+	select {
+	case <-provider.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for worker to call Synthesize")
+	}
+	if got := provider.capturedRequest().Text; got != "fetched text" {
+		t.Errorf("expected fetched text to be synthesized, got %q", got)
+	}
+}
+
+func TestWorker_FailsJobWithSourceErrorCode(t *testing.T) {
+	queue := NewQueue(10)
+	worker := NewWorker(queue, &fakeRegistry{provider: newFakeProvider()}, &fakeStorage{}, zap.NewNop(), 24, 0,
+		&fakeSources{err: domain.NewTextSourceError(domain.SourceErrNotFound, "gone", nil)}, nil, RetryPolicy{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	worker.Start(ctx, 1)
+	defer worker.Stop()
+
+	job := domain.NewJob("", "voice1", "", "", "fake-provider", "mp3", nil)
+	job.Source = &domain.TextSource{Type: domain.TextSourceURL, URL: "https://example.com/a.txt"}
+	if err := queue.Enqueue(ctx, job); err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+
+	deadline := time.After(2 * time.Second)
+	for {
+		got, _ := queue.GetJob(ctx, job.ID)
+		queue.mu.RLock()
+		status, code := got.Status, got.ErrorCode
+		queue.mu.RUnlock()
+		if status == domain.JobStatusFailed {
+			if code != domain.SourceErrNotFound {
+				t.Errorf("expected error code %s, got %q", domain.SourceErrNotFound, code)
+			}
+			return
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("timed out waiting for the job to fail, status %s", status)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestWorker_PinnedPoolsOnlyTakeTheirProviders(t *testing.T) {
+	logger := zap.NewNop()
+	queue := NewQueue(10)
+	provider := newFakeProvider()
+	registry := &fakeRegistry{provider: provider}
+
+	worker := NewWorker(queue, registry, &fakeStorage{}, logger, 24, 0, nil, nil, RetryPolicy{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// No general workers: jobs for unpinned providers wait
+	worker.Start(ctx, 0, Pool{Name: "fake", Workers: 1, Providers: []string{"fake-provider"}})
+	defer worker.Stop()
+
+	pinned := domain.NewJob("pinned", "voice1", "", "", "fake-provider", "mp3", nil)
+	other := domain.NewJob("other", "voice1", "", "", "other-provider", "mp3", nil)
+	for _, job := range []*domain.Job{other, pinned} {
+		if err := queue.Enqueue(ctx, job); err != nil {
+			t.Fatalf("failed to enqueue job: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	var stats []domain.WorkerPoolStats
+	for {
+		stats = worker.PoolStats()
+		if len(stats) == 2 && stats[1].Completed == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the pinned pool, stats %+v", stats)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	general, fake := stats[0], stats[1]
+	if general.Name != DefaultPool || general.Workers != 0 || general.Queued != 1 {
+		t.Errorf("unexpected default pool stats %+v", general)
+	}
+	if fake.Name != "fake" || fake.Workers != 1 || fake.Queued != 0 || fake.Failed != 0 {
+		t.Errorf("unexpected pinned pool stats %+v", fake)
+	}
+	if got, _ := queue.GetJob(ctx, other.ID); got.Status != domain.JobStatusQueued {
+		t.Errorf("expected the unpinned job to stay queued, got %s", got.Status)
+	}
+}
+
+// panickingProvider panics on the first call and succeeds afterwards.
+type panickingProvider struct {
+	fakeProvider
+	calls int32
+}
+
+func (p *panickingProvider) Synthesize(ctx context.Context, req *domain.SynthesisRequest) (*domain.SynthesisResult, error) {
+	if atomic.AddInt32(&p.calls, 1) == 1 {
+		panic("provider crashed")
+	}
+	return p.fakeProvider.Synthesize(ctx, req)
+}
+
+func TestWorker_RedeliversJobAfterCrash(t *testing.T) {
+	logger := zap.NewNop()
+	queue := NewQueueWithOptions(10, Options{VisibilityTimeout: 50 * time.Millisecond, MaxDeliveries: 3})
+	provider := &panickingProvider{fakeProvider: *newFakeProvider()}
+	registry := &fakeRegistry{provider: provider}
+
+	worker := NewWorker(queue, registry, &fakeStorage{}, logger, 24, 0, nil, nil, RetryPolicy{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	worker.Start(ctx, 1)
+	defer worker.Stop()
+
+	job := domain.NewJob("hello", "voice1", "", "", "fake-provider", "mp3", nil)
+	if err := queue.Enqueue(ctx, job); err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		stats := queue.Stats()
+		if stats.CompletedJobs == 1 && stats.UnackedJobs == 0 {
+			if stats.RedeliveredJobs != 1 {
+				t.Errorf("expected 1 redelivery, got %d", stats.RedeliveredJobs)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the crashed job to be redelivered, stats %+v", stats)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// blockingProvider holds every synthesis until its context is cancelled.
+type blockingProvider struct {
+	fakeProvider
+	started chan struct{}
+}
+
+func (p *blockingProvider) Synthesize(ctx context.Context, req *domain.SynthesisRequest) (*domain.SynthesisResult, error) {
+	close(p.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// finishedQueue reports each job the worker stores in a final state.
+type finishedQueue struct {
+	*Queue
+	finished chan *domain.Job
+}
+
+func (q *finishedQueue) UpdateJob(ctx context.Context, job *domain.Job) error {
+	err := q.Queue.UpdateJob(ctx, job)
+	if job.IsComplete() {
+		q.finished <- job
+	}
+	return err
+}
+
+func TestWorker_CancelsJobInFlight(t *testing.T) {
+	logger := zap.NewNop()
+	queue := &finishedQueue{Queue: NewQueue(10), finished: make(chan *domain.Job, 1)}
+	provider := &blockingProvider{fakeProvider: *newFakeProvider(), started: make(chan struct{})}
+	registry := &fakeRegistry{provider: provider}
+
+	worker := NewWorker(queue, registry, &fakeStorage{}, logger, 24, 0, nil, nil, RetryPolicy{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	worker.Start(ctx, 1)
+	defer worker.Stop()
+
+	job := domain.NewJob("hello", "voice1", "", "", "fake-provider", "mp3", nil)
+	if err := queue.Enqueue(ctx, job); err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+
+	select {
+	case <-provider.started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for synthesis to start")
+	}
+	if _, err := queue.Cancel(ctx, job.ID); err != nil {
+		t.Fatalf("failed to cancel job: %v", err)
+	}
+
+	select {
+	case stored := <-queue.finished:
+		last := stored.Events[len(stored.Events)-1]
+		if stored.Status != domain.JobStatusCancelled || last.Message != "cancelled while processing" {
+			t.Errorf("expected the job to be cancelled while processing, got %s %+v", stored.Status, last)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the job to be cancelled")
+	}
+}
+
+func TestWorker_RunsPipelineTextStagesBeforeSynthesis(t *testing.T) {
+	queue := NewQueue(10)
+	provider := newFakeProvider()
+	worker := NewWorker(queue, &fakeRegistry{provider: provider}, &fakeStorage{}, zap.NewNop(), 24, 0, nil, nil, RetryPolicy{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	worker.Start(ctx, 1)
+	defer worker.Stop()
+
+	job := domain.NewJob("Learn  SQL today", "voice1", "", "", "fake-provider", "mp3", nil)
+	job.Pipeline = []domain.PipelineStage{
+		{Stage: "normalize"},
+		{Stage: "lexicon", Params: map[string]any{"entries": map[string]any{"SQL": "sequel"}}},
+		{Stage: "synthesize"},
+	}
+	if err := queue.Enqueue(ctx, job); err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+
+	select {
+	case <-provider.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for worker to call Synthesize")
+	}
+	if captured := provider.capturedRequest(); captured == nil || captured.Text != "Learn sequel today" {
+		t.Errorf("expected the pipeline's text to be synthesized, got %+v", captured)
+	}
+}
+
+// fixedCompleter answers every prompt with its reply.
+type fixedCompleter string
+
+func (c fixedCompleter) Complete(ctx context.Context, system, input string) (string, error) {
+	return string(c), nil
+}
+
+func TestWorker_KeepsOriginalTextOfSummarizedJob(t *testing.T) {
+	pipeline.RegisterSummarize(fixedCompleter("Budget approved."))
+	queue := &finishedQueue{Queue: NewQueue(10), finished: make(chan *domain.Job, 1)}
+	provider := newFakeProvider()
+	worker := NewWorker(queue, &fakeRegistry{provider: provider}, &fakeStorage{}, zap.NewNop(), 24, 0, nil, nil, RetryPolicy{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	worker.Start(ctx, 1)
+	defer worker.Stop()
+
+	original := "After a four-hour session, the city council approved next year's budget."
+	job := domain.NewJob(original, "voice1", "", "", "fake-provider", "mp3", nil)
+	job.Pipeline = []domain.PipelineStage{{Stage: pipeline.Summarize}, {Stage: "synthesize"}}
+	if err := queue.Enqueue(ctx, job); err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+
+	select {
+	case stored := <-queue.finished:
+		if stored.Text != original || stored.SpokenText != "Budget approved." {
+			t.Errorf("expected the original text and the summary spoken, got %q and %q", stored.Text, stored.SpokenText)
+		}
+		if captured := provider.capturedRequest(); captured == nil || captured.Text != "Budget approved." {
+			t.Errorf("expected the summary to be synthesized, got %+v", captured)
+		}
+		rewritten := false
+		for _, event := range stored.Events {
+			rewritten = rewritten || event.Type == domain.JobEventRewritten
+		}
+		if !rewritten {
+			t.Error("expected a rewritten event")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the job to finish")
+	}
+}
+
+// failingProvider fails every synthesis, or reports itself unavailable.
+type failingProvider struct {
+	fakeProvider
+	unavailable bool
+}
+
+func (p *failingProvider) Name() string                         { return "primary" }
+func (p *failingProvider) IsAvailable(ctx context.Context) bool { return !p.unavailable }
+func (p *failingProvider) Synthesize(ctx context.Context, req *domain.SynthesisRequest) (*domain.SynthesisResult, error) {
+	return nil, &domain.ProviderError{Provider: p.Name(), StatusCode: http.StatusBadGateway, Message: "upstream down"}
+}
+
+// fallbackRegistry serves primary, which falls back to the fakeRegistry's provider.
+type fallbackRegistry struct {
+	fakeRegistry
+	primary domain.TTSProvider
+}
+
+func (r *fallbackRegistry) Get(name string) (domain.TTSProvider, error) {
+	if name == r.primary.Name() {
+		return r.primary, nil
+	}
+	return r.fakeRegistry.Get(name)
+}
+
+func (r *fallbackRegistry) Fallbacks(name string) []string {
+	if name == r.primary.Name() {
+		return []string{r.provider.Name()}
+	}
+	return nil
+}
+
+func TestWorker_FailsOverToFallbackProvider(t *testing.T) {
+	for name, primary := range map[string]*failingProvider{
+		"failed":      {},
+		"unavailable": {unavailable: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			queue := &finishedQueue{Queue: NewQueue(10), finished: make(chan *domain.Job, 1)}
+			registry := &fallbackRegistry{fakeRegistry: fakeRegistry{provider: newFakeProvider()}, primary: primary}
+			worker := NewWorker(queue, registry, &fakeStorage{}, zap.NewNop(), 24, 0, nil, nil, RetryPolicy{})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			worker.Start(ctx, 1)
+			defer worker.Stop()
+
+			job := domain.NewJob("hello", "voice1", "", "", "primary", "mp3", nil)
+			if err := queue.Enqueue(ctx, job); err != nil {
+				t.Fatalf("failed to enqueue job: %v", err)
+			}
+
+			select {
+			case stored := <-queue.finished:
+				if stored.Status != domain.JobStatusCompleted || stored.ResultProvider != "fake-provider" {
+					t.Fatalf("expected the fallback to complete the job, got %s by %q: %s",
+						stored.Status, stored.ResultProvider, stored.ErrorMessage)
+				}
+				var failovers []domain.JobEvent
+				for _, event := range stored.Events {
+					if event.Type == domain.JobEventFailover {
+						failovers = append(failovers, event)
+					}
+				}
+				if len(failovers) != 1 || !strings.HasPrefix(failovers[0].Message, "primary: ") ||
+					!strings.HasSuffix(failovers[0].Message, "; trying fake-provider") {
+					t.Errorf("expected one failover event, got %+v", failovers)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("timed out waiting for the job to finish")
+			}
+		})
+	}
+}
+
+// unavailableProvider always answers 503 Service Unavailable.
+type unavailableProvider struct {
+	fakeProvider
+}
+
+func (p *unavailableProvider) Synthesize(ctx context.Context, req *domain.SynthesisRequest) (*domain.SynthesisResult, error) {
+	return nil, &domain.ProviderError{Provider: p.Name(), StatusCode: http.StatusServiceUnavailable, Message: "service unavailable"}
+}
+
+func TestWorker_RetriesTransientFailuresUpToMaxAttempts(t *testing.T) {
+	queue := &finishedQueue{Queue: NewQueue(10), finished: make(chan *domain.Job, 1)}
+	provider := &unavailableProvider{fakeProvider: *newFakeProvider()}
+	worker := NewWorker(queue, &fakeRegistry{provider: provider}, &fakeStorage{}, zap.NewNop(), 24, 0, nil, nil,
+		RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	worker.Start(ctx, 1)
+	defer worker.Stop()
+
+	job := domain.NewJob("hello", "voice1", "", "", "fake-provider", "mp3", nil)
+	if err := queue.Enqueue(ctx, job); err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+
+	select {
+	case stored := <-queue.finished:
+		if stored.Status != domain.JobStatusFailed || stored.Attempts != 3 || stored.MaxAttempts != 3 {
+			t.Errorf("expected the job to fail after 3 of 3 attempts, got %s after %d of %d",
+				stored.Status, stored.Attempts, stored.MaxAttempts)
+		}
+		retries := 0
+		for _, event := range stored.Events {
+			if event.Type == domain.JobEventRetrying {
+				retries++
+			}
+		}
+		if retries != 2 {
+			t.Errorf("expected 2 retry events, got %d", retries)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the job to fail")
+	}
+}
+
+func TestWorker_FailsJobPastItsDeadline(t *testing.T) {
+	queue := &finishedQueue{Queue: NewQueue(10), finished: make(chan *domain.Job, 1)}
+	provider := newFakeProvider()
+	worker := NewWorker(queue, &fakeRegistry{provider: provider}, &fakeStorage{}, zap.NewNop(), 24, 0, nil, nil, RetryPolicy{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	worker.Start(ctx, 1)
+	defer worker.Stop()
+
+	passed := time.Now().Add(-time.Second)
+	job := domain.NewJob("hello", "voice1", "", "", "fake-provider", "mp3", nil)
+	job.Deadline = &passed
+	if err := queue.Enqueue(ctx, job); err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+
+	select {
+	case stored := <-queue.finished:
+		if stored.Status != domain.JobStatusFailed || stored.ErrorCode != domain.JobErrDeadlineExceeded {
+			t.Errorf("expected the job to fail with %s, got %s %q", domain.JobErrDeadlineExceeded, stored.Status, stored.ErrorCode)
+		}
+		if provider.capturedRequest() != nil {
+			t.Error("expected the provider not to be called past the deadline")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the job to fail")
+	}
+}
+
+func TestWorker_FailsJobWhoseRetryWouldMissItsDeadline(t *testing.T) {
+	queue := &finishedQueue{Queue: NewQueue(10), finished: make(chan *domain.Job, 1)}
+	provider := &unavailableProvider{fakeProvider: *newFakeProvider()}
+	worker := NewWorker(queue, &fakeRegistry{provider: provider}, &fakeStorage{}, zap.NewNop(), 24, 0, nil, nil,
+		RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	worker.Start(ctx, 1)
+	defer worker.Stop()
+
+	soon := time.Now().Add(time.Minute)
+	job := domain.NewJob("hello", "voice1", "", "", "fake-provider", "mp3", nil)
+	job.Deadline = &soon
+	if err := queue.Enqueue(ctx, job); err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+
+	select {
+	case stored := <-queue.finished:
+		if stored.ErrorCode != domain.JobErrDeadlineExceeded || stored.Attempts != 1 {
+			t.Errorf("expected the job to fail with %s after 1 attempt, got %q after %d",
+				domain.JobErrDeadlineExceeded, stored.ErrorCode, stored.Attempts)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the job to fail")
+	}
+}
+
+// errorBodyProvider answers its first bad calls with a JSON error body, as
+// ElevenLabs occasionally does with a 200.
+type errorBodyProvider struct {
+	fakeProvider
+	bad atomic.Int32
+}
+
+func (p *errorBodyProvider) Synthesize(ctx context.Context, req *domain.SynthesisRequest) (*domain.SynthesisResult, error) {
+	if p.bad.Add(-1) >= 0 {
+		body := []byte(`{"detail":{"status":"system_busy"}}`)
+		return &domain.SynthesisResult{Audio: bytes.NewReader(body), ContentType: "audio/mpeg", SizeBytes: int64(len(body))}, nil
+	}
+	return p.fakeProvider.Synthesize(ctx, req)
+}
+
+func TestWorker_RetriesInvalidAudio(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		bad        int32
+		wantStatus domain.JobStatus
+		wantCode   string
+	}{
+		{"valid on retry", 1, domain.JobStatusCompleted, ""},
+		{"invalid on every attempt", 3, domain.JobStatusFailed, domain.JobErrInvalidAudio},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := &finishedQueue{Queue: NewQueue(10), finished: make(chan *domain.Job, 1)}
+			provider := &errorBodyProvider{fakeProvider: *newFakeProvider()}
+			provider.bad.Store(tt.bad)
+			worker := NewWorker(queue, &fakeRegistry{provider: provider}, &fakeStorage{}, zap.NewNop(), 24, 0, nil, nil,
+				RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			worker.Start(ctx, 1)
+			defer worker.Stop()
+
+			job := domain.NewJob("hello", "voice1", "", "", "fake-provider", "mp3", nil)
+			if err := queue.Enqueue(ctx, job); err != nil {
+				t.Fatalf("failed to enqueue job: %v", err)
+			}
+
+			select {
+			case stored := <-queue.finished:
+				if stored.Status != tt.wantStatus || stored.ErrorCode != tt.wantCode {
+					t.Errorf("expected %s %q, got %s %q: %s", tt.wantStatus, tt.wantCode, stored.Status, stored.ErrorCode, stored.ErrorMessage)
+				}
+				if stored.Status == domain.JobStatusCompleted && stored.AudioSeconds == 0 {
+					t.Error("expected the duration of the validated audio")
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("timed out waiting for the job to finish")
+			}
+		})
+	}
+}
+
+// bodiesProvider answers with its bodies in turn, then with the last one.
+type bodiesProvider struct {
+	fakeProvider
+	calls  atomic.Int32
+	bodies [][]byte
+}
+
+func (p *bodiesProvider) Synthesize(ctx context.Context, req *domain.SynthesisRequest) (*domain.SynthesisResult, error) {
+	body := p.bodies[min(int(p.calls.Add(1)), len(p.bodies))-1]
+	return &domain.SynthesisResult{Audio: bytes.NewReader(body), ContentType: "audio/wav", SizeBytes: int64(len(body))}, nil
+}
+
+func TestWorker_RetriesEmptyAndSilentAudio(t *testing.T) {
+	silent := transcode.PCMToWAV(make([]byte, 3200), 16000, 1, 16)
+	audible := transcode.PCMToWAV(bytes.Repeat([]byte{0x00, 0x40, 0x00, 0xC0}, 800), 16000, 1, 16)
+	for _, tt := range []struct {
+		name        string
+		text        string
+		thresholdDB float64
+		bodies      [][]byte
+		wantStatus  domain.JobStatus
+		wantCode    string
+	}{
+		{"empty on every attempt", "hello", -60, [][]byte{{}}, domain.JobStatusFailed, domain.JobErrEmptyAudio},
+		{"silent on every attempt", "hello", -60, [][]byte{silent}, domain.JobStatusFailed, domain.JobErrSilentAudio},
+		{"audible on retry", "hello", -60, [][]byte{silent, audible}, domain.JobStatusCompleted, ""},
+		{"nothing to speak", "...", -60, [][]byte{silent}, domain.JobStatusCompleted, ""},
+		{"detection off", "hello", 0, [][]byte{silent}, domain.JobStatusCompleted, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := &finishedQueue{Queue: NewQueue(10), finished: make(chan *domain.Job, 1)}
+			provider := &bodiesProvider{fakeProvider: *newFakeProvider(), bodies: tt.bodies}
+			worker := NewWorker(queue, &fakeRegistry{provider: provider}, &fakeStorage{}, zap.NewNop(), 24, 0, nil, nil,
+				RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
+			worker.DetectSilence(tt.thresholdDB)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			worker.Start(ctx, 1)
+			defer worker.Stop()
+
+			job := domain.NewJob(tt.text, "voice1", "", "", "fake-provider", "wav", nil)
+			if err := queue.Enqueue(ctx, job); err != nil {
+				t.Fatalf("failed to enqueue job: %v", err)
+			}
+
+			select {
+			case stored := <-queue.finished:
+				if stored.Status != tt.wantStatus || stored.ErrorCode != tt.wantCode {
+					t.Errorf("expected %s %q, got %s %q: %s", tt.wantStatus, tt.wantCode, stored.Status, stored.ErrorCode, stored.ErrorMessage)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("timed out waiting for the job to finish")
+			}
+		})
+	}
+}
+
+// downloadingProvider reads its audio through the request's download tracking,
+// as providers fetching it over HTTP do.
+type downloadingProvider struct {
+	fakeProvider
+}
+
+func (p *downloadingProvider) Synthesize(ctx context.Context, req *domain.SynthesisRequest) (*domain.SynthesisResult, error) {
+	audio, err := io.ReadAll(req.TrackDownload(bytes.NewReader(testMP3), int64(len(testMP3))))
+	if err != nil {
+		return nil, err
+	}
+	return &domain.SynthesisResult{Audio: bytes.NewReader(audio), ContentType: "audio/mpeg", SizeBytes: int64(len(audio))}, nil
+}
+
+func TestWorker_RecordsProviderDownload(t *testing.T) {
+	queue := &finishedQueue{Queue: NewQueue(10), finished: make(chan *domain.Job, 1)}
+	provider := &downloadingProvider{fakeProvider: *newFakeProvider()}
+	worker := NewWorker(queue, &fakeRegistry{provider: provider}, &fakeStorage{}, zap.NewNop(), 24, 0, nil, nil, RetryPolicy{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	worker.Start(ctx, 1)
+	defer worker.Stop()
+
+	job := domain.NewJob("hello", "voice1", "", "", "fake-provider", "mp3", nil)
+	if err := queue.Enqueue(ctx, job); err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+
+	select {
+	case stored := <-queue.finished:
+		i := slices.IndexFunc(stored.Events, func(e domain.JobEvent) bool { return e.Type == domain.JobEventDownloaded })
+		if stored.Status != domain.JobStatusCompleted || i < 0 {
+			t.Fatalf("expected a completed job with a download event, got %s with %v", stored.Status, stored.Events)
+		}
+		if want := fmt.Sprintf("%d bytes in ", len(testMP3)); !strings.HasPrefix(stored.Events[i].Message, want) {
+			t.Errorf("expected the download's size and throughput, got %q", stored.Events[i].Message)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the job to finish")
+	}
+}
+
+// chunkingProvider records the requests it gets, answering each with the text as
+// its request ID.
+type chunkingProvider struct {
+	fakeProvider
+	requests []domain.SynthesisRequest
+}
+
+func (p *chunkingProvider) Synthesize(ctx context.Context, req *domain.SynthesisRequest) (*domain.SynthesisResult, error) {
+	p.mu.Lock()
+	p.requests = append(p.requests, *req)
+	p.mu.Unlock()
+	return &domain.SynthesisResult{Audio: bytes.NewReader(testMP3), RequestID: req.Text}, nil
+}
+
+// limitedRegistry limits its provider to maxLength characters per request.
+type limitedRegistry struct {
+	fakeRegistry
+	maxLength int
+}
+
+func (r *limitedRegistry) MaxTextLength(name string) int  { return r.maxLength }
+func (r *limitedRegistry) ParallelChunks(name string) int { return 1 }
+
+func TestWorker_SynthesizesLongTextInChunks(t *testing.T) {
+	queue := &finishedQueue{Queue: NewQueue(10), finished: make(chan *domain.Job, 1)}
+	provider := &chunkingProvider{}
+	registry := &limitedRegistry{fakeRegistry: fakeRegistry{provider: provider}, maxLength: 12}
+	worker := NewWorker(queue, registry, &fakeStorage{}, zap.NewNop(), 24, 0, nil, nil, RetryPolicy{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	worker.Start(ctx, 1)
+	defer worker.Stop()
+
+	job := domain.NewJob("One two. Three four. Five six.", "voice1", "", "", "fake-provider", "mp3", nil)
+	if err := queue.Enqueue(ctx, job); err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+
+	var stored *domain.Job
+	select {
+	case stored = <-queue.finished:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the job to finish")
+	}
+	if stored.Status != domain.JobStatusCompleted {
+		t.Fatalf("expected the job to complete, got %s: %s", stored.Status, stored.ErrorMessage)
+	}
+
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	want := []string{"One two.", "Three four.", "Five six."}
+	if len(provider.requests) != len(want) {
+		t.Fatalf("expected %d chunks, got %d requests", len(want), len(provider.requests))
+	}
+	for i, req := range provider.requests {
+		if req.Text != want[i] {
+			t.Errorf("chunk %d = %q, want %q", i, req.Text, want[i])
+		}
+		// Each chunk is stitched to the ones before it
+		if !slices.Equal(req.PreviousRequestIDs, want[:i]) {
+			t.Errorf("chunk %d previous request IDs = %q, want %q", i, req.PreviousRequestIDs, want[:i])
+		}
+	}
+	if !slices.ContainsFunc(stored.Events, func(e domain.JobEvent) bool { return e.Type == domain.JobEventChunked }) {
+		t.Errorf("expected a chunked event, got %+v", stored.Events)
+	}
+}
+
+// flakyProvider is a chunkingProvider named primary that fails on the chunk failOn.
+type flakyProvider struct {
+	chunkingProvider
+	failOn string
+}
+
+func (p *flakyProvider) Name() string { return "primary" }
+func (p *flakyProvider) Synthesize(ctx context.Context, req *domain.SynthesisRequest) (*domain.SynthesisResult, error) {
+	if req.Text == p.failOn {
+		return nil, &domain.ProviderError{Provider: p.Name(), StatusCode: http.StatusBadGateway, Message: "upstream down"}
+	}
+	return p.chunkingProvider.Synthesize(ctx, req)
+}
+
+// limitedFallbackRegistry is a fallbackRegistry that limits its providers to
+// maxLength characters per request.
+type limitedFallbackRegistry struct {
+	fallbackRegistry
+	maxLength int
+}
+
+func (r *limitedFallbackRegistry) MaxTextLength(name string) int  { return r.maxLength }
+func (r *limitedFallbackRegistry) ParallelChunks(name string) int { return 1 }
+
+func TestWorker_FailsOverChunkedTextAsAWhole(t *testing.T) {
+	queue := &finishedQueue{Queue: NewQueue(10), finished: make(chan *domain.Job, 1)}
+	primary := &flakyProvider{failOn: "Three four."}
+	fallback := &chunkingProvider{}
+	registry := &limitedFallbackRegistry{
+		fallbackRegistry: fallbackRegistry{fakeRegistry: fakeRegistry{provider: fallback}, primary: primary},
+		maxLength:        12,
+	}
+	worker := NewWorker(queue, registry, &fakeStorage{}, zap.NewNop(), 24, 0, nil, nil, RetryPolicy{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	worker.Start(ctx, 1)
+	defer worker.Stop()
+
+	job := domain.NewJob("One two. Three four. Five six.", "voice1", "", "", "primary", "mp3", nil)
+	if err := queue.Enqueue(ctx, job); err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+
+	var stored *domain.Job
+	select {
+	case stored = <-queue.finished:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the job to finish")
+	}
+	if stored.Status != domain.JobStatusCompleted || stored.ResultProvider != "fake-provider" {
+		t.Fatalf("expected the fallback to complete the job, got %s by %q: %s",
+			stored.Status, stored.ResultProvider, stored.ErrorMessage)
+	}
+
+	// The chunk the primary synthesized before it failed is synthesized again,
+	// so every chunk has the fallback's voice
+	fallback.mu.Lock()
+	defer fallback.mu.Unlock()
+	want := []string{"One two.", "Three four.", "Five six."}
+	var texts []string
+	for _, req := range fallback.requests {
+		texts = append(texts, req.Text)
+	}
+	if !slices.Equal(texts, want) {
+		t.Errorf("fallback chunks = %q, want %q", texts, want)
+	}
+	failovers := 0
+	for _, event := range stored.Events {
+		if event.Type == domain.JobEventFailover {
+			failovers++
+		}
+	}
+	if failovers != 1 {
+		t.Errorf("expected one failover event, got %d", failovers)
+	}
+}
+
+func TestWorker_CompletesRepeatedJobFromResultCache(t *testing.T) {
+	cache, err := speechcache.NewExpiring(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatalf("speechcache.NewExpiring: %v", err)
+	}
+	queue := &finishedQueue{Queue: NewQueue(10), finished: make(chan *domain.Job, 2)}
+	provider := &chunkingProvider{}
+	worker := NewWorker(queue, &fakeRegistry{provider: provider}, &fakeStorage{}, zap.NewNop(), 24, 0, nil, nil, RetryPolicy{})
+	worker.CacheResults(cache, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	worker.Start(ctx, 1)
+	defer worker.Stop()
+
+	var finished []*domain.Job
+	for range 2 {
+		job := domain.NewJob("Your order has shipped.", "voice1", "", "", "fake-provider", "mp3", nil)
+		if err := queue.Enqueue(ctx, job); err != nil {
+			t.Fatalf("failed to enqueue job: %v", err)
+		}
+		select {
+		case stored := <-queue.finished:
+			finished = append(finished, stored)
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for the job to finish")
+		}
+	}
+
+	for i, job := range finished {
+		if job.Status != domain.JobStatusCompleted {
+			t.Fatalf("job %d: expected it to complete, got %s: %s", i, job.Status, job.ErrorMessage)
+		}
+	}
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	if len(provider.requests) != 1 {
+		t.Errorf("expected the provider to be called once, got %d", len(provider.requests))
+	}
+	if !slices.ContainsFunc(finished[1].Events, func(e domain.JobEvent) bool { return e.Type == domain.JobEventCacheHit }) {
+		t.Errorf("expected a cache_hit event, got %+v", finished[1].Events)
+	}
+}
@@ -0,0 +1,819 @@
+// Package postgres provides a durable domain.JobQueue backed by a PostgreSQL table.
+// Several server instances can share one table: jobs are dequeued with
+// SELECT ... FOR UPDATE SKIP LOCKED, so each pending job goes to one worker, and a
+// job whose worker dies is redelivered once its lease expires.
+//
+// Unlike the in-memory queue, jobs are served oldest first without per-tenant fair
+// scheduling or a character budget.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// Defaults for Options left zero.
+const (
+	DefaultPollInterval = 500 * time.Millisecond
+)
+
+// Options tunes a Postgres queue.
+type Options struct {
+	// Capacity caps the number of pending jobs; Enqueue returns domain.ErrQueueBusy
+	// beyond it. 0 means no cap.
+	Capacity int
+	// VisibilityTimeout is how long a dequeued job may go without an update or Ack
+	// before it is redelivered; 0 never redelivers.
+	VisibilityTimeout time.Duration
+	// MaxDeliveries fails a job once it was delivered this often without an Ack;
+	// 0 means no limit.
+	MaxDeliveries int
+	// PollInterval is how often a blocked Dequeue looks for new jobs.
+	PollInterval time.Duration
+	// SearchIndex makes Migrate create the full-text index SearchJobs uses.
+	// Without it SearchJobs still works, by scanning the table.
+	SearchIndex bool
+}
+
+// Queue is a PostgreSQL implementation of domain.JobQueue.
+type Queue struct {
+	db     *sql.DB
+	opts   Options
+	closed atomic.Bool
+	// closing is closed by Close to wake blocked Dequeue calls.
+	closing chan struct{}
+}
+
+// New creates a queue on db. Call Migrate before first use.
+func New(db *sql.DB, opts Options) *Queue {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPollInterval
+	}
+	return &Queue{db: db, opts: opts, closing: make(chan struct{})}
+}
+
+// Migrate creates the jobs and schedules tables and indexes if they don't exist.
+func (q *Queue) Migrate(ctx context.Context) error {
+	if _, err := q.db.ExecContext(ctx, schema); err != nil {
+		return fmt.Errorf("create job schema: %w", err)
+	}
+	if q.opts.SearchIndex {
+		if _, err := q.db.ExecContext(ctx, searchIndex); err != nil {
+			return fmt.Errorf("create job search index: %w", err)
+		}
+	}
+	return nil
+}
+
+// Enqueue adds a job, or puts an existing job back in line (e.g. for a retry).
+func (q *Queue) Enqueue(ctx context.Context, job *domain.Job) error {
+	if q.closed.Load() {
+		return context.Canceled
+	}
+	if q.opts.Capacity > 0 {
+		var pending int
+		err := q.db.QueryRowContext(ctx,
+			`SELECT count(*) FROM pako_jobs WHERE status = 'queued' AND lease_expires_at IS NULL`).Scan(&pending)
+		if err != nil {
+			return fmt.Errorf("count pending jobs: %w", err)
+		}
+		if pending >= q.opts.Capacity {
+			return domain.ErrQueueBusy
+		}
+	}
+
+	// created_at keeps microseconds; match it so listing cursors line up with rows
+	job.CreatedAt = job.CreatedAt.Truncate(time.Microsecond)
+	job.AddEvent(domain.JobEventQueued, "queued for tenant "+tenantOf(job))
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("encode job: %w", err)
+	}
+	_, err = q.db.ExecContext(ctx, `
+		INSERT INTO pako_jobs (id, status, tenant_id, provider_name, data, created_at, enqueued_at, next_attempt_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, now(), $7, $8)
+		ON CONFLICT (id) DO UPDATE SET
+			status = EXCLUDED.status, tenant_id = EXCLUDED.tenant_id, provider_name = EXCLUDED.provider_name,
+			data = EXCLUDED.data, enqueued_at = now(), next_attempt_at = EXCLUDED.next_attempt_at,
+			expires_at = EXCLUDED.expires_at, lease_expires_at = NULL, cancel_requested = false`,
+		job.ID, string(job.Status), tenantOf(job), job.ProviderName, data, job.CreatedAt, job.NextAttemptAt, job.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("insert job: %w", err)
+	}
+	return nil
+}
+
+// Dequeue retrieves the oldest pending job, waiting for one, and leases it to the
+// caller. Returns nil once the queue is closed; pending jobs stay in the table.
+func (q *Queue) Dequeue(ctx context.Context) (*domain.Job, error) {
+	return q.DequeueMatching(ctx, nil)
+}
+
+// DequeueMatching is Dequeue restricted to the jobs filter takes; the rest stay
+// queued for other callers. A nil filter takes any job.
+func (q *Queue) DequeueMatching(ctx context.Context, filter *domain.ProviderFilter) (*domain.Job, error) {
+	ticker := time.NewTicker(q.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if q.closed.Load() {
+			return nil, nil
+		}
+		if _, err := q.redeliverExpired(ctx); err != nil {
+			return nil, err
+		}
+		job, err := q.tryDequeue(ctx, filter)
+		if err != nil || job != nil {
+			return job, err
+		}
+
+		select {
+		case <-ticker.C:
+		case <-q.closing:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// TryDequeueMatching leases the first pending job filter takes, or returns nil at
+// once when there is none. Jobs whose lease expired are redelivered first.
+func (q *Queue) TryDequeueMatching(ctx context.Context, filter *domain.ProviderFilter) (*domain.Job, error) {
+	if _, err := q.redeliverExpired(ctx); err != nil {
+		return nil, err
+	}
+	return q.tryDequeue(ctx, filter)
+}
+
+// TryDequeueBatch leases up to n pending jobs filter takes in one transaction, or
+// returns none at once when there are none.
+func (q *Queue) TryDequeueBatch(ctx context.Context, filter *domain.ProviderFilter, n int) ([]*domain.Job, error) {
+	if _, err := q.redeliverExpired(ctx); err != nil {
+		return nil, err
+	}
+	return q.tryDequeueBatch(ctx, filter, n)
+}
+
+// Closed reports whether Close was called.
+func (q *Queue) Closed() bool {
+	return q.closed.Load()
+}
+
+// tryDequeue leases the first matching pending job, or returns nil when there is
+// none.
+func (q *Queue) tryDequeue(ctx context.Context, filter *domain.ProviderFilter) (*domain.Job, error) {
+	jobs, err := q.tryDequeueBatch(ctx, filter, 1)
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+	return jobs[0], nil
+}
+
+// tryDequeueBatch leases up to n matching pending jobs, oldest first. Rows other
+// workers hold locked are skipped. The filter is part of the query, so jobs of
+// other providers never crowd out the ones it takes.
+func (q *Queue) tryDequeueBatch(ctx context.Context, filter *domain.ProviderFilter, n int) ([]*domain.Job, error) {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin dequeue: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after Commit
+
+	condition, args := providerCondition(filter, 2)
+	rows, err := tx.QueryContext(ctx, `
+		SELECT data FROM pako_jobs
+		WHERE status = 'queued' AND lease_expires_at IS NULL
+			AND (next_attempt_at IS NULL OR next_attempt_at <= now())`+condition+`
+		ORDER BY enqueued_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`, append([]any{n}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("select pending job: %w", err)
+	}
+	var jobs []*domain.Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			rows.Close() //nolint:errcheck
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, fmt.Errorf("select pending job: %w", err)
+	}
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+
+	for _, job := range jobs {
+		job.AddEvent(domain.JobEventDequeued, "dequeued for tenant "+tenantOf(job))
+		if err := q.save(ctx, tx, job, q.leaseUntil()); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit dequeue: %w", err)
+	}
+	return jobs, nil
+}
+
+// providerCondition returns the condition, starting with " AND", that limits a
+// query to the jobs filter takes, with its arguments numbered from first. It is
+// empty for a nil filter.
+func providerCondition(filter *domain.ProviderFilter, first int) (string, []any) {
+	switch {
+	case filter == nil:
+		return "", nil
+	case len(filter.Providers) == 0 && filter.Exclude:
+		return "", nil
+	case len(filter.Providers) == 0:
+		return " AND false", nil
+	}
+	placeholders := make([]string, len(filter.Providers))
+	args := make([]any, len(filter.Providers))
+	for i, name := range filter.Providers {
+		placeholders[i] = fmt.Sprintf("$%d", first+i)
+		args[i] = name
+	}
+	op := "IN"
+	if filter.Exclude {
+		op = "NOT IN"
+	}
+	return " AND provider_name " + op + " (" + strings.Join(placeholders, ", ") + ")", args
+}
+
+// Ack ends the lease of a dequeued job so it is not redelivered.
+func (q *Queue) Ack(ctx context.Context, jobID string) error {
+	res, err := q.db.ExecContext(ctx, `UPDATE pako_jobs SET lease_expires_at = NULL WHERE id = $1`, jobID)
+	if err != nil {
+		return fmt.Errorf("ack job: %w", err)
+	}
+	return requireRow(res)
+}
+
+// RenewLease extends the lease of the dequeued job jobID by the visibility
+// timeout. A job not leased, e.g. already acknowledged, is left alone.
+func (q *Queue) RenewLease(ctx context.Context, jobID string) error {
+	if q.opts.VisibilityTimeout <= 0 {
+		return nil
+	}
+	if _, err := q.db.ExecContext(ctx, `
+		UPDATE pako_jobs SET lease_expires_at = $2 WHERE id = $1 AND lease_expires_at IS NOT NULL`,
+		jobID, q.leaseUntil()); err != nil {
+		return fmt.Errorf("renew lease: %w", err)
+	}
+	return nil
+}
+
+// UpdateEstimates sets the estimated completion of each queued job of provider,
+// oldest first, to what estimate returns, and returns how many jobs there were.
+// Jobs another instance is dequeuing at the time are skipped.
+func (q *Queue) UpdateEstimates(ctx context.Context, provider string, estimate func(*domain.Job) *time.Time) (int, error) {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin estimate update: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after Commit
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT data FROM pako_jobs
+		WHERE status = 'queued' AND provider_name = $1
+		ORDER BY created_at
+		FOR UPDATE SKIP LOCKED`, provider)
+	if err != nil {
+		return 0, fmt.Errorf("select queued jobs: %w", err)
+	}
+	var jobs []*domain.Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			rows.Close() //nolint:errcheck
+			return 0, err
+		}
+		jobs = append(jobs, job)
+	}
+	rows.Close() //nolint:errcheck
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("select queued jobs: %w", err)
+	}
+
+	for _, job := range jobs {
+		job.EstimatedCompletionAt = estimate(job)
+		data, err := json.Marshal(job)
+		if err != nil {
+			return 0, fmt.Errorf("encode job: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE pako_jobs SET data = $2 WHERE id = $1`, job.ID, data); err != nil {
+			return 0, fmt.Errorf("update estimate: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit estimate update: %w", err)
+	}
+	return len(jobs), nil
+}
+
+// Reap queues again, or fails, the jobs whose lease expired because the worker
+// holding them stopped, and returns how many there were. Dequeue reaps too, but
+// only while a worker is waiting for a job.
+func (q *Queue) Reap(ctx context.Context) (int, error) {
+	return q.redeliverExpired(ctx)
+}
+
+// redeliverExpired queues again, or fails, every job whose lease has expired,
+// and returns how many there were.
+func (q *Queue) redeliverExpired(ctx context.Context) (int, error) {
+	if q.opts.VisibilityTimeout <= 0 {
+		return 0, nil
+	}
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin redelivery: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after Commit
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT data, cancel_requested FROM pako_jobs
+		WHERE lease_expires_at < now()
+		FOR UPDATE SKIP LOCKED`)
+	if err != nil {
+		return 0, fmt.Errorf("select expired leases: %w", err)
+	}
+	var expired []*domain.Job
+	cancelRequested := make(map[string]bool)
+	for rows.Next() {
+		var data []byte
+		var cancel bool
+		if err := rows.Scan(&data, &cancel); err != nil {
+			rows.Close() //nolint:errcheck
+			return 0, fmt.Errorf("read job: %w", err)
+		}
+		job, err := decodeJob(data)
+		if err != nil {
+			rows.Close() //nolint:errcheck
+			return 0, err
+		}
+		expired = append(expired, job)
+		cancelRequested[job.ID] = cancel
+	}
+	if err := rows.Close(); err != nil {
+		return 0, fmt.Errorf("select expired leases: %w", err)
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	for _, job := range expired {
+		// Finished jobs only lose their lease.
+		switch {
+		case job.IsComplete():
+		case cancelRequested[job.ID]:
+			job.SetCancelled("cancelled after its worker stopped responding") //nolint:errcheck // not complete, checked above
+		case job.Redeliver(q.opts.MaxDeliveries, q.opts.VisibilityTimeout):
+			job.AddEvent(domain.JobEventQueued, "queued for tenant "+tenantOf(job))
+		}
+		if err := q.save(ctx, tx, job, nil); err != nil {
+			return 0, err
+		}
+		if job.Status == domain.JobStatusQueued {
+			if _, err := tx.ExecContext(ctx, `
+				UPDATE pako_jobs SET enqueued_at = now(), redeliveries = redeliveries + 1 WHERE id = $1`,
+				job.ID); err != nil {
+				return 0, fmt.Errorf("requeue job: %w", err)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit redelivery: %w", err)
+	}
+	return len(expired), nil
+}
+
+// GetJob retrieves a job by ID.
+func (q *Queue) GetJob(ctx context.Context, jobID string) (*domain.Job, error) {
+	row := q.db.QueryRowContext(ctx, `SELECT data FROM pako_jobs WHERE id = $1`, jobID)
+	job, err := scanJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrJobNotFound
+	}
+	return job, err
+}
+
+// UpdateJob saves a job's status and metadata. A leased job still processing has
+// its lease renewed.
+func (q *Queue) UpdateJob(ctx context.Context, job *domain.Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("encode job: %w", err)
+	}
+	var renew *time.Time
+	if job.Status == domain.JobStatusProcessing {
+		renew = q.leaseUntil()
+	}
+	res, err := q.db.ExecContext(ctx, `
+		UPDATE pako_jobs SET status = $2, provider_name = $3, data = $4, next_attempt_at = $6, expires_at = $7,
+			lease_expires_at = CASE WHEN lease_expires_at IS NOT NULL AND $5::timestamptz IS NOT NULL
+				THEN $5::timestamptz ELSE lease_expires_at END
+		WHERE id = $1`,
+		job.ID, string(job.Status), job.ProviderName, data, renew, job.NextAttemptAt, job.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("update job: %w", err)
+	}
+	return requireRow(res)
+}
+
+// ListJobs returns a page of the jobs matching filter, sorted by creation time.
+func (q *Queue) ListJobs(ctx context.Context, filter domain.JobFilter) (*domain.JobPage, error) {
+	where := []string{"TRUE"}
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if filter.Status != "" {
+		where = append(where, "status = "+arg(string(filter.Status)))
+	}
+	if filter.Tenant != "" {
+		where = append(where, "tenant_id = "+arg(filter.Tenant))
+	}
+	if filter.BatchID != "" {
+		where = append(where, "data->>'batch_id' = "+arg(filter.BatchID))
+	}
+	if filter.Provider != "" {
+		where = append(where, "provider_name = "+arg(filter.Provider))
+	}
+	if filter.VoiceID != "" {
+		where = append(where, "data->>'voice_id' = "+arg(filter.VoiceID))
+	}
+	if filter.OutputFormat != "" {
+		where = append(where, "data->>'output_format' = "+arg(filter.OutputFormat))
+	}
+	if !filter.ExpiresAfter.IsZero() {
+		where = append(where, "expires_at > "+arg(filter.ExpiresAfter))
+	}
+	if !filter.ExpiresBy.IsZero() {
+		where = append(where, "expires_at <= "+arg(filter.ExpiresBy))
+	}
+	if !filter.CreatedAfter.IsZero() {
+		where = append(where, "created_at > "+arg(filter.CreatedAfter))
+	}
+	if !filter.CreatedBefore.IsZero() {
+		where = append(where, "created_at < "+arg(filter.CreatedBefore))
+	}
+	if !filter.FinishedAfter.IsZero() {
+		where = append(where, "(data->>'completed_at')::timestamptz > "+arg(filter.FinishedAfter))
+	}
+	order, cmp := "DESC", "<"
+	if filter.Ascending {
+		order, cmp = "ASC", ">"
+	}
+	if filter.After != nil {
+		where = append(where, fmt.Sprintf("(created_at, id) %s (%s, %s)", cmp, arg(filter.After.CreatedAt), arg(filter.After.ID)))
+	}
+	query := fmt.Sprintf("SELECT data FROM pako_jobs WHERE %s ORDER BY created_at %s, id %s",
+		strings.Join(where, " AND "), order, order)
+	if filter.Limit > 0 {
+		// One extra row tells whether another page follows
+		query += " LIMIT " + arg(filter.Limit+1)
+	}
+
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list jobs: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	page := &domain.JobPage{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		page.Jobs = append(page.Jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list jobs: %w", err)
+	}
+	if filter.Limit > 0 && len(page.Jobs) > filter.Limit {
+		page.Jobs = page.Jobs[:filter.Limit]
+		page.Next = domain.CursorAfter(page.Jobs[filter.Limit-1])
+	}
+	return page, nil
+}
+
+// Cancel cancels a job. A job waiting in the table is cancelled at once; for a
+// leased job cancel_requested is set, which its worker picks up through
+// CancelRequested.
+func (q *Queue) Cancel(ctx context.Context, jobID string) (*domain.Job, error) {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin cancel: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after Commit
+
+	var data []byte
+	var leased bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT data, lease_expires_at IS NOT NULL FROM pako_jobs WHERE id = $1 FOR UPDATE`, jobID).Scan(&data, &leased)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read job: %w", err)
+	}
+	job, err := decodeJob(data)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status == domain.JobStatusCancelled {
+		return job, nil
+	}
+	if job.IsComplete() {
+		return nil, domain.ErrJobNotCancellable
+	}
+
+	if leased {
+		_, err = tx.ExecContext(ctx, `UPDATE pako_jobs SET cancel_requested = true WHERE id = $1`, jobID)
+		if err != nil {
+			return nil, fmt.Errorf("request cancellation: %w", err)
+		}
+	} else {
+		job.SetCancelled("cancelled while queued") //nolint:errcheck // not complete, checked above
+		if err := q.save(ctx, tx, job, nil); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit cancel: %w", err)
+	}
+	return job, nil
+}
+
+// CancelRequested polls the job's cancel_requested flag every PollInterval until
+// it is set, closing the returned channel, or until ctx is done.
+func (q *Queue) CancelRequested(ctx context.Context, jobID string) <-chan struct{} {
+	requested := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(q.opts.PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			var cancel bool
+			err := q.db.QueryRowContext(ctx,
+				`SELECT cancel_requested FROM pako_jobs WHERE id = $1`, jobID).Scan(&cancel)
+			if err == nil && cancel {
+				close(requested)
+				return
+			}
+		}
+	}()
+	return requested
+}
+
+// DeleteJob removes a job.
+func (q *Queue) DeleteJob(ctx context.Context, jobID string) error {
+	if _, err := q.db.ExecContext(ctx, `DELETE FROM pako_jobs WHERE id = $1`, jobID); err != nil {
+		return fmt.Errorf("delete job: %w", err)
+	}
+	return nil
+}
+
+// Close stops handing out jobs. Pending jobs stay in the table for the next start;
+// the database handle is left to its owner.
+func (q *Queue) Close() error {
+	if q.closed.CompareAndSwap(false, true) {
+		close(q.closing)
+	}
+	return nil
+}
+
+// PendingMatching counts the pending jobs filter takes. It returns 0 when the
+// database can't be read.
+func (q *Queue) PendingMatching(filter *domain.ProviderFilter) int {
+	condition, args := providerCondition(filter, 1)
+	var n int
+	if err := q.db.QueryRowContext(context.Background(),
+		`SELECT count(*) FROM pako_jobs WHERE status = 'queued' AND lease_expires_at IS NULL`+condition, args...).Scan(&n); err != nil {
+		return 0
+	}
+	return n
+}
+
+// ArchiveJob moves a finished job to pako_job_archive as its archived record, in
+// one statement so the job is never lost nor counted twice.
+func (q *Queue) ArchiveJob(ctx context.Context, job *domain.Job) error {
+	record := domain.NewArchivedJob(job)
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("encode archived job: %w", err)
+	}
+	_, err = q.db.ExecContext(ctx, `
+		WITH archived AS (
+			INSERT INTO pako_job_archive (id, tenant_id, data, created_at, archived_at)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (id) DO NOTHING
+		)
+		DELETE FROM pako_jobs WHERE id = $1`,
+		record.ID, record.Tenant(), data, record.CreatedAt, record.ArchivedAt)
+	if err != nil {
+		return fmt.Errorf("archive job: %w", err)
+	}
+	return nil
+}
+
+// Analytics aggregates the finished and archived jobs submitted in the query's range.
+func (q *Queue) Analytics(ctx context.Context, query domain.AnalyticsQuery) (*domain.Analytics, error) {
+	rows, err := q.db.QueryContext(ctx, `
+		SELECT data FROM pako_jobs
+		WHERE created_at >= $1 AND created_at < $2
+			AND status IN ('completed', 'failed', 'cancelled', 'expired')
+			AND ($3 = '' OR tenant_id = $3)`,
+		query.From, query.To, query.Tenant)
+	if err != nil {
+		return nil, fmt.Errorf("query analytics: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	analytics := domain.NewAnalytics(query)
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		analytics.Add(job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query analytics: %w", err)
+	}
+	rows.Close() //nolint:errcheck
+
+	rows, err = q.db.QueryContext(ctx, `
+		SELECT data FROM pako_job_archive
+		WHERE created_at >= $1 AND created_at < $2
+			AND ($3 = '' OR tenant_id = $3)`,
+		query.From, query.To, query.Tenant)
+	if err != nil {
+		return nil, fmt.Errorf("query archive analytics: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("read archived job: %w", err)
+		}
+		var job domain.ArchivedJob
+		if err := json.Unmarshal(data, &job); err != nil {
+			return nil, fmt.Errorf("decode archived job: %w", err)
+		}
+		analytics.AddArchived(&job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query archive analytics: %w", err)
+	}
+	return analytics.Finish(), nil
+}
+
+// Stats returns job counts and per-tenant backlog. Fields that only apply to the
+// in-memory scheduler (characters in flight, maximum waits) are left zero.
+func (q *Queue) Stats() domain.QueueStats {
+	ctx := context.Background()
+	stats := domain.QueueStats{}
+
+	rows, err := q.db.QueryContext(ctx, `
+		SELECT status, count(*), count(lease_expires_at), coalesce(sum(redeliveries), 0)
+		FROM pako_jobs GROUP BY status`)
+	if err != nil {
+		return stats
+	}
+	for rows.Next() {
+		var status string
+		var count, leased int
+		var redelivered int64
+		if err := rows.Scan(&status, &count, &leased, &redelivered); err != nil {
+			continue
+		}
+		stats.TotalJobs += count
+		stats.UnackedJobs += leased
+		stats.RedeliveredJobs += redelivered
+		switch domain.JobStatus(status) {
+		case domain.JobStatusQueued:
+			stats.QueuedJobs = count
+		case domain.JobStatusProcessing:
+			stats.ProcessingJobs = count
+		case domain.JobStatusCompleted:
+			stats.CompletedJobs = count
+		case domain.JobStatusFailed:
+			stats.FailedJobs = count
+		case domain.JobStatusCancelled:
+			stats.CancelledJobs = count
+		case domain.JobStatusExpired:
+			stats.ExpiredJobs = count
+		}
+	}
+	rows.Close() //nolint:errcheck
+
+	rows, err = q.db.QueryContext(ctx, `
+		SELECT tenant_id,
+			count(*) FILTER (WHERE status = 'queued' AND lease_expires_at IS NULL),
+			count(*) FILTER (WHERE status = 'processing'),
+			coalesce(extract(epoch FROM now() - min(enqueued_at)
+				FILTER (WHERE status = 'queued' AND lease_expires_at IS NULL)), 0)
+		FROM pako_jobs
+		WHERE status IN ('queued', 'processing')
+		GROUP BY tenant_id ORDER BY tenant_id`)
+	if err != nil {
+		return stats
+	}
+	defer rows.Close() //nolint:errcheck
+	for rows.Next() {
+		var t domain.TenantQueueStats
+		if err := rows.Scan(&t.Tenant, &t.Queued, &t.InFlight, &t.OldestWaitSeconds); err != nil {
+			continue
+		}
+		stats.Tenants = append(stats.Tenants, t)
+		stats.OldestQueuedSeconds = max(stats.OldestQueuedSeconds, t.OldestWaitSeconds)
+	}
+	return stats
+}
+
+// save writes job back inside tx, setting its lease deadline (nil clears it).
+func (q *Queue) save(ctx context.Context, tx *sql.Tx, job *domain.Job, lease *time.Time) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("encode job: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE pako_jobs SET status = $2, data = $3, lease_expires_at = $4, next_attempt_at = $5, expires_at = $6
+		WHERE id = $1`,
+		job.ID, string(job.Status), data, lease, job.NextAttemptAt, job.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("save job: %w", err)
+	}
+	return nil
+}
+
+// leaseUntil returns the deadline of a lease taken now, or nil without a
+// visibility timeout. A job without a timeout is still marked as leased, with a
+// deadline far enough out that it never expires.
+func (q *Queue) leaseUntil() *time.Time {
+	timeout := q.opts.VisibilityTimeout
+	if timeout <= 0 {
+		timeout = 100 * 365 * 24 * time.Hour
+	}
+	deadline := time.Now().Add(timeout)
+	return &deadline
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanJob(row scanner) (*domain.Job, error) {
+	var data []byte
+	if err := row.Scan(&data); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("read job: %w", err)
+	}
+	return decodeJob(data)
+}
+
+func decodeJob(data []byte) (*domain.Job, error) {
+	var job domain.Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("decode job: %w", err)
+	}
+	return &job, nil
+}
+
+func requireRow(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.ErrJobNotFound
+	}
+	return nil
+}
+
+func tenantOf(job *domain.Job) string {
+	if job.TenantID == "" {
+		return domain.DefaultTenant
+	}
+	return job.TenantID
+}
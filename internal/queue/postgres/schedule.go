@@ -0,0 +1,30 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ClaimRun implements domain.ScheduleStore. Due times are compared with the
+// database clock, so instances with skewed clocks agree on them, and the update
+// only succeeds for the one instance that finds the run still due.
+func (q *Queue) ClaimRun(ctx context.Context, task string, interval time.Duration) (bool, error) {
+	seconds := interval.Seconds()
+	if _, err := q.db.ExecContext(ctx,
+		`INSERT INTO pako_schedules (task, next_run_at) VALUES ($1, now() + $2 * interval '1 second')
+		ON CONFLICT (task) DO NOTHING`, task, seconds); err != nil {
+		return false, fmt.Errorf("record schedule: %w", err)
+	}
+	res, err := q.db.ExecContext(ctx,
+		`UPDATE pako_schedules SET next_run_at = now() + $2 * interval '1 second', last_run_at = now()
+		WHERE task = $1 AND next_run_at <= now()`, task, seconds)
+	if err != nil {
+		return false, fmt.Errorf("claim scheduled run: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("claim scheduled run: %w", err)
+	}
+	return n == 1, nil
+}
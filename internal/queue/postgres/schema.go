@@ -0,0 +1,69 @@
+package postgres
+
+// schema creates the jobs table and its indexes. Each job is stored whole as JSON
+// in data; status, tenant, provider, result expiry and the delivery columns are
+// kept alongside it for filtering and dequeueing. The table is created with the
+// columns of its first release; every column added since is added by an ALTER,
+// so tables created by an older server gain it too, and filled in from data
+// where data holds it. pako_job_archive holds the records jobs are reduced to
+// once archived, and pako_schedules the next run of each recurring task.
+const schema = `
+CREATE TABLE IF NOT EXISTS pako_jobs (
+	id               TEXT PRIMARY KEY,
+	status           TEXT NOT NULL,
+	tenant_id        TEXT NOT NULL DEFAULT '',
+	provider_name    TEXT NOT NULL DEFAULT '',
+	data             JSONB NOT NULL,
+	created_at       TIMESTAMPTZ NOT NULL,
+	enqueued_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+	lease_expires_at TIMESTAMPTZ,
+	redeliveries     INTEGER NOT NULL DEFAULT 0
+);
+
+ALTER TABLE pako_jobs ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMPTZ;
+
+UPDATE pako_jobs SET next_attempt_at = (data->>'next_attempt_at')::timestamptz
+	WHERE next_attempt_at IS NULL AND data ? 'next_attempt_at';
+
+ALTER TABLE pako_jobs ADD COLUMN IF NOT EXISTS cancel_requested BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX IF NOT EXISTS pako_jobs_status_idx ON pako_jobs (status, created_at);
+
+CREATE INDEX IF NOT EXISTS pako_jobs_tenant_created_idx ON pako_jobs (tenant_id, created_at);
+
+CREATE INDEX IF NOT EXISTS pako_jobs_provider_idx ON pako_jobs (provider_name, status, created_at);
+
+CREATE INDEX IF NOT EXISTS pako_jobs_voice_idx ON pako_jobs ((data->>'voice_id'), created_at);
+
+CREATE INDEX IF NOT EXISTS pako_jobs_format_idx ON pako_jobs ((data->>'output_format'), created_at);
+
+CREATE INDEX IF NOT EXISTS pako_jobs_pending_idx ON pako_jobs (enqueued_at)
+	WHERE status = 'queued' AND lease_expires_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS pako_jobs_lease_idx ON pako_jobs (lease_expires_at)
+	WHERE lease_expires_at IS NOT NULL;
+
+ALTER TABLE pako_jobs ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+
+UPDATE pako_jobs SET expires_at = (data->>'expires_at')::timestamptz
+	WHERE expires_at IS NULL AND data ? 'expires_at';
+
+CREATE INDEX IF NOT EXISTS pako_jobs_expires_idx ON pako_jobs (expires_at)
+	WHERE expires_at IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS pako_job_archive (
+	id          TEXT PRIMARY KEY,
+	tenant_id   TEXT NOT NULL DEFAULT '',
+	data        JSONB NOT NULL,
+	created_at  TIMESTAMPTZ NOT NULL,
+	archived_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS pako_job_archive_tenant_created_idx ON pako_job_archive (tenant_id, created_at);
+
+CREATE TABLE IF NOT EXISTS pako_schedules (
+	task        TEXT PRIMARY KEY,
+	next_run_at TIMESTAMPTZ NOT NULL,
+	last_run_at TIMESTAMPTZ
+);
+`
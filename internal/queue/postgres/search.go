@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// Search vectors of a job's text and of its tag stages' params, with the
+// simple configuration so words match as typed in any language.
+const (
+	textVector = `to_tsvector('simple', coalesce(data->>'text', ''))`
+	tagsVector = `to_tsvector('simple', jsonb_path_query_array(data, '$.pipeline[*] ? (@.stage == "tag").params.*')::text)`
+)
+
+// searchIndex indexes the words of jobs' text and tags for SearchJobs; Migrate
+// creates it when Options.SearchIndex is set.
+const searchIndex = `
+CREATE INDEX IF NOT EXISTS pako_jobs_search_idx ON pako_jobs
+	USING GIN ((` + textVector + ` || ` + tagsVector + `));
+`
+
+// SearchJobs returns the jobs whose text and tags contain every word of the
+// query, best matches first. The indexed vector of text and tags narrows the
+// rows down; a job whose text is no longer retained then has to match on its
+// tags alone.
+func (q *Queue) SearchJobs(ctx context.Context, query domain.JobSearchQuery) ([]*domain.Job, error) {
+	retained := fmt.Sprintf(`(CASE WHEN expires_at IS NULL OR expires_at > $2 THEN %s ELSE ''::tsvector END || %s)`,
+		textVector, tagsVector)
+	stmt := fmt.Sprintf(`
+		SELECT data FROM pako_jobs, plainto_tsquery('simple', $1) query
+		WHERE (%s || %s) @@ query AND %s @@ query
+			AND ($3 = '' OR tenant_id = $3)
+		ORDER BY ts_rank(%s, query) DESC, created_at DESC, id`,
+		textVector, tagsVector, retained, retained)
+	args := []any{query.Query, query.TextExpiry, query.Tenant}
+	if query.Limit > 0 {
+		stmt += " LIMIT $4"
+		args = append(args, query.Limit)
+	}
+
+	rows, err := q.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search jobs: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	var jobs []*domain.Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("search jobs: %w", err)
+	}
+	return jobs, nil
+}
@@ -0,0 +1,100 @@
+// Package replay re-submits recorded jobs to a server and compares how the
+// server handles them with how they were handled when recorded, e.g. to
+// validate a new provider against a production workload before making it the
+// default.
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// Entry is one recorded job of a manifest: what was asked for, and how it went.
+// A manifest is a JSON Lines file of entries, as exported by GET
+// /api/v1/admin/jobs/export.
+type Entry struct {
+	JobID         string                 `json:"job_id"`
+	Text          string                 `json:"text"`
+	VoiceID       string                 `json:"voice_id,omitempty"`
+	ModelID       string                 `json:"model_id,omitempty"`
+	LanguageCode  string                 `json:"language_code,omitempty"`
+	Provider      string                 `json:"provider,omitempty"`
+	OutputFormat  string                 `json:"output_format,omitempty"`
+	VoiceSettings *domain.VoiceSettings  `json:"voice_settings,omitempty"`
+	Padding       *domain.PaddingOptions `json:"padding,omitempty"`
+	Pipeline      []domain.PipelineStage `json:"pipeline,omitempty"`
+	// CreatedAt is when the job was submitted; replays paced like the recording
+	// keep the time between submissions.
+	CreatedAt time.Time        `json:"created_at"`
+	Status    domain.JobStatus `json:"status"`
+	// ResultProvider is the provider that produced the recorded result.
+	ResultProvider string `json:"result_provider,omitempty"`
+	// LatencyMs is how long the job took from submission to completion.
+	LatencyMs    int64   `json:"latency_ms,omitempty"`
+	AudioSeconds float64 `json:"audio_seconds,omitempty"`
+	ErrorCode    string  `json:"error_code,omitempty"`
+}
+
+// NewEntry records a finished job. It reports false for jobs that can't be
+// replayed: unfinished ones, and those whose text is no longer kept.
+func NewEntry(job *domain.Job) (Entry, bool) {
+	if job.Text == "" || job.CompletedAt == nil {
+		return Entry{}, false
+	}
+	e := Entry{
+		JobID:          job.ID,
+		Text:           job.Text,
+		VoiceID:        job.VoiceID,
+		ModelID:        job.ModelID,
+		LanguageCode:   job.LanguageCode,
+		Provider:       job.ProviderName,
+		OutputFormat:   job.OutputFormat,
+		VoiceSettings:  job.VoiceSettings,
+		Padding:        job.Padding,
+		Pipeline:       job.Pipeline,
+		CreatedAt:      job.CreatedAt,
+		Status:         job.Status,
+		ResultProvider: job.ResultProvider,
+		LatencyMs:      job.CompletedAt.Sub(job.CreatedAt).Milliseconds(),
+		AudioSeconds:   job.AudioSeconds,
+		ErrorCode:      job.ErrorCode,
+	}
+	return e, true
+}
+
+// completed reports whether the recorded job produced a result, including one
+// that has expired since.
+func (e Entry) completed() bool {
+	return e.Status == domain.JobStatusCompleted || e.Status == domain.JobStatusExpired
+}
+
+// ReadManifest reads the entries of a JSON Lines manifest. Blank lines are
+// skipped; entries without text are rejected.
+func ReadManifest(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		data := scanner.Bytes()
+		if len(data) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("manifest line %d: %w", line, err)
+		}
+		if e.Text == "" {
+			return nil, fmt.Errorf("manifest line %d: entry has no text", line)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	return entries, nil
+}
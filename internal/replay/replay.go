@@ -0,0 +1,541 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+const (
+	defaultPollInterval = 500 * time.Millisecond
+	defaultTimeout      = 10 * time.Minute
+	// busyWait is how long a submission waits before retrying a busy server that
+	// didn't send Retry-After.
+	busyWait = time.Second
+)
+
+// Options controls a replay.
+type Options struct {
+	// Target is the base URL of the server replayed against, e.g.
+	// http://localhost:8080.
+	Target string
+	// APIKey is sent as X-API-Key when set.
+	APIKey string
+	// Provider, VoiceID and ModelID replace those of every entry when set, e.g.
+	// to send the workload to a new provider. Voices are provider specific, so a
+	// new provider usually needs VoiceID too.
+	Provider string
+	VoiceID  string
+	ModelID  string
+	// Concurrency bounds the jobs in flight at once; at least 1.
+	Concurrency int
+	// Speed paces submissions like the recording: 1 keeps the time between
+	// them, 2 halves it. 0 submits as fast as Concurrency allows.
+	Speed float64
+	// PollInterval is how often a job's status is checked; 500ms when 0.
+	PollInterval time.Duration
+	// Timeout bounds each job from submission to completion; 10m when 0. Jobs
+	// that take longer are cancelled on the target.
+	Timeout time.Duration
+	// OutputDir, when set, receives the audio of each completed job as
+	// <recorded job ID>.<format>, to be listened to next to the recorded result.
+	OutputDir string
+	// HTTPClient sends the requests; http.DefaultClient when nil.
+	HTTPClient *http.Client
+	// OnResult, when set, is called after each job with its result.
+	OnResult func(Result)
+}
+
+// Outcome is what happened to one replayed job.
+type Outcome string
+
+// Job outcomes.
+const (
+	OutcomeCompleted Outcome = "completed"
+	// OutcomeFailed is a job the target failed, cancelled or expired.
+	OutcomeFailed Outcome = "failed"
+	// OutcomeRejected is a submission the target refused, e.g. for an unknown voice.
+	OutcomeRejected Outcome = "rejected"
+	OutcomeTimedOut Outcome = "timed_out"
+	// OutcomeError is a job that couldn't be submitted or followed, e.g. because
+	// the target was unreachable.
+	OutcomeError Outcome = "error"
+)
+
+// Result is how one recorded job fared when replayed.
+type Result struct {
+	RecordedJobID string  `json:"recorded_job_id"`
+	JobID         string  `json:"job_id,omitempty"`
+	Outcome       Outcome `json:"outcome"`
+	// RecordedStatus is the status of the recorded job.
+	RecordedStatus domain.JobStatus `json:"recorded_status"`
+	// Provider is the provider that produced the result.
+	Provider             string  `json:"provider,omitempty"`
+	LatencyMs            int64   `json:"latency_ms,omitempty"`
+	RecordedLatencyMs    int64   `json:"recorded_latency_ms,omitempty"`
+	AudioSeconds         float64 `json:"audio_seconds,omitempty"`
+	RecordedAudioSeconds float64 `json:"recorded_audio_seconds,omitempty"`
+	ErrorCode            string  `json:"error_code,omitempty"`
+	Error                string  `json:"error,omitempty"`
+	// OutputPath is where the result was saved, with Options.OutputDir.
+	OutputPath string `json:"output_path,omitempty"`
+}
+
+// Percentiles summarises latencies.
+type Percentiles struct {
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+}
+
+// Report summarises a replay.
+type Report struct {
+	// Total counts the jobs replayed; fewer than the entries when interrupted.
+	Total    int
+	Outcomes map[Outcome]int
+	// RecordedCompleted counts the replayed jobs that completed when recorded.
+	RecordedCompleted int
+	// Compared counts the jobs that completed both when recorded and replayed;
+	// the latencies and DurationRatio are over these.
+	Compared        int
+	Latency         Percentiles
+	RecordedLatency Percentiles
+	// DurationRatio is the median ratio of replayed to recorded audio duration;
+	// far from 1 hints at truncated or padded results.
+	DurationRatio float64
+	// Errors counts the error codes, or messages, of the jobs that didn't complete.
+	Errors  map[string]int
+	Results []Result
+	Elapsed time.Duration
+}
+
+// Run replays entries against the target, oldest first, and reports how they
+// fared. Jobs failing are reported and the rest still replayed; the returned
+// error is for a run stopped early, e.g. by ctx, with the report of the jobs
+// replayed until then.
+func Run(ctx context.Context, entries []Entry, opts Options) (*Report, error) {
+	if opts.Target == "" {
+		return nil, errors.New("replay: a target is required")
+	}
+	opts.Target = strings.TrimSuffix(opts.Target, "/")
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultPollInterval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	if opts.OutputDir != "" {
+		if err := os.MkdirAll(opts.OutputDir, 0755); err != nil {
+			return nil, fmt.Errorf("replay: %w", err)
+		}
+	}
+
+	sorted := append([]Entry(nil), entries...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].CreatedAt.Before(sorted[j].CreatedAt) })
+
+	r := &runner{opts: opts}
+	results := make([]*Result, len(sorted))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for range opts.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				result := r.replay(ctx, sorted[i])
+				mu.Lock()
+				results[i] = &result
+				if opts.OnResult != nil {
+					opts.OnResult(result)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	start := time.Now()
+	var stopped error
+dispatch:
+	for i, e := range sorted {
+		if opts.Speed > 0 {
+			offset := time.Duration(float64(e.CreatedAt.Sub(sorted[0].CreatedAt)) / opts.Speed)
+			select {
+			case <-ctx.Done():
+				stopped = ctx.Err()
+				break dispatch
+			case <-time.After(time.Until(start.Add(offset))):
+			}
+		}
+		select {
+		case <-ctx.Done():
+			stopped = ctx.Err()
+			break dispatch
+		case next <- i:
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	report := summarize(results)
+	report.Elapsed = time.Since(start)
+	if stopped == nil {
+		stopped = ctx.Err()
+	}
+	if stopped != nil {
+		return report, fmt.Errorf("replay interrupted: %w", stopped)
+	}
+	return report, nil
+}
+
+// summarize reports on the results of the jobs replayed; nil ones weren't.
+func summarize(results []*Result) *Report {
+	report := &Report{Outcomes: make(map[Outcome]int), Errors: make(map[string]int)}
+	var latencies, recorded []time.Duration
+	var ratios []float64
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		report.Total++
+		report.Outcomes[result.Outcome]++
+		report.Results = append(report.Results, *result)
+		recordedCompleted := result.RecordedStatus == domain.JobStatusCompleted || result.RecordedStatus == domain.JobStatusExpired
+		if recordedCompleted {
+			report.RecordedCompleted++
+		}
+		if result.Outcome != OutcomeCompleted {
+			key := result.ErrorCode
+			if key == "" {
+				key = result.Error
+			}
+			report.Errors[key]++
+			continue
+		}
+		if !recordedCompleted {
+			continue
+		}
+		report.Compared++
+		latencies = append(latencies, time.Duration(result.LatencyMs)*time.Millisecond)
+		recorded = append(recorded, time.Duration(result.RecordedLatencyMs)*time.Millisecond)
+		if result.AudioSeconds > 0 && result.RecordedAudioSeconds > 0 {
+			ratios = append(ratios, result.AudioSeconds/result.RecordedAudioSeconds)
+		}
+	}
+	report.Latency = percentiles(latencies)
+	report.RecordedLatency = percentiles(recorded)
+	if len(ratios) > 0 {
+		sort.Float64s(ratios)
+		report.DurationRatio = ratios[len(ratios)/2]
+	}
+	return report
+}
+
+func percentiles(d []time.Duration) Percentiles {
+	if len(d) == 0 {
+		return Percentiles{}
+	}
+	sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+	at := func(p float64) time.Duration {
+		return d[max(0, int(math.Ceil(p*float64(len(d))))-1)]
+	}
+	return Percentiles{P50: at(0.5), P90: at(0.9), P99: at(0.99)}
+}
+
+// runner replays single jobs.
+type runner struct {
+	opts Options
+}
+
+// submitRequest is the body of POST /api/v1/jobs.
+type submitRequest struct {
+	Text          string                 `json:"text"`
+	VoiceID       string                 `json:"voice_id,omitempty"`
+	ModelID       string                 `json:"model_id,omitempty"`
+	LanguageCode  string                 `json:"language_code,omitempty"`
+	Provider      string                 `json:"provider,omitempty"`
+	OutputFormat  string                 `json:"output_format,omitempty"`
+	VoiceSettings *domain.VoiceSettings  `json:"voice_settings,omitempty"`
+	Padding       *domain.PaddingOptions `json:"padding,omitempty"`
+	Pipeline      []domain.PipelineStage `json:"pipeline,omitempty"`
+}
+
+// jobStatus is the part of GET /api/v1/jobs/{jobID} a replay reads.
+type jobStatus struct {
+	JobID           string   `json:"job_id"`
+	Status          string   `json:"status"`
+	ResultProvider  *string  `json:"result_provider,omitempty"`
+	DurationSeconds *float64 `json:"duration_seconds,omitempty"`
+	ErrorMessage    *string  `json:"error_message,omitempty"`
+	ErrorCode       *string  `json:"error_code,omitempty"`
+}
+
+// requestError is an error response of the target.
+type requestError struct {
+	status     int
+	code       string
+	message    string
+	retryAfter time.Duration
+}
+
+func (e *requestError) Error() string {
+	if e.code == "" {
+		return fmt.Sprintf("%d %s", e.status, e.message)
+	}
+	return fmt.Sprintf("%d %s: %s", e.status, e.code, e.message)
+}
+
+// busy reports whether the target asked to retry later.
+func (e *requestError) busy() bool {
+	return e.status == http.StatusTooManyRequests || e.status == http.StatusServiceUnavailable
+}
+
+// replay submits e and follows the job until it finishes or times out.
+func (r *runner) replay(ctx context.Context, e Entry) Result {
+	result := Result{RecordedJobID: e.JobID, RecordedStatus: e.Status, RecordedAudioSeconds: e.AudioSeconds}
+	if e.completed() {
+		result.RecordedLatencyMs = e.LatencyMs
+	}
+	jobCtx, cancel := context.WithTimeout(ctx, r.opts.Timeout)
+	defer cancel()
+	submitted := time.Now()
+
+	fail := func(outcome Outcome, err error) Result {
+		result.Outcome = outcome
+		result.Error = err.Error()
+		var reqErr *requestError
+		if errors.As(err, &reqErr) {
+			result.ErrorCode = reqErr.code
+		}
+		if jobCtx.Err() != nil && ctx.Err() == nil {
+			result.Outcome = OutcomeTimedOut
+			result.Error = fmt.Sprintf("no result within %s", r.opts.Timeout)
+			if result.JobID != "" {
+				r.cancel(result.JobID)
+			}
+		}
+		return result
+	}
+
+	jobID, err := r.submit(jobCtx, e)
+	if err != nil {
+		var reqErr *requestError
+		if errors.As(err, &reqErr) {
+			return fail(OutcomeRejected, err)
+		}
+		return fail(OutcomeError, err)
+	}
+	result.JobID = jobID
+
+	status, err := r.wait(jobCtx, jobID)
+	if err != nil {
+		return fail(OutcomeError, err)
+	}
+	result.LatencyMs = time.Since(submitted).Milliseconds()
+	if status.ResultProvider != nil {
+		result.Provider = *status.ResultProvider
+	}
+	if status.Status != string(domain.JobStatusCompleted) {
+		result.Outcome = OutcomeFailed
+		result.Error = status.Status
+		if status.ErrorMessage != nil {
+			result.Error = *status.ErrorMessage
+		}
+		if status.ErrorCode != nil {
+			result.ErrorCode = *status.ErrorCode
+		}
+		return result
+	}
+	result.Outcome = OutcomeCompleted
+	if status.DurationSeconds != nil {
+		result.AudioSeconds = *status.DurationSeconds
+	}
+
+	if r.opts.OutputDir != "" {
+		name := e.JobID
+		if e.OutputFormat != "" {
+			name += "." + e.OutputFormat
+		}
+		path := filepath.Join(r.opts.OutputDir, filepath.Base(name))
+		if err := r.download(jobCtx, jobID, path); err != nil {
+			result.Error = fmt.Sprintf("save result: %v", err)
+		} else {
+			result.OutputPath = path
+		}
+	}
+	return result
+}
+
+// submit creates the job for e, waiting out a busy target, and returns its ID.
+func (r *runner) submit(ctx context.Context, e Entry) (string, error) {
+	req := submitRequest{
+		Text:          e.Text,
+		VoiceID:       e.VoiceID,
+		ModelID:       e.ModelID,
+		LanguageCode:  e.LanguageCode,
+		Provider:      e.Provider,
+		OutputFormat:  e.OutputFormat,
+		VoiceSettings: e.VoiceSettings,
+		Padding:       e.Padding,
+		Pipeline:      e.Pipeline,
+	}
+	if r.opts.Provider != "" {
+		req.Provider = r.opts.Provider
+	}
+	if r.opts.VoiceID != "" {
+		req.VoiceID = r.opts.VoiceID
+	}
+	if r.opts.ModelID != "" {
+		req.ModelID = r.opts.ModelID
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		var created struct {
+			JobID string `json:"job_id"`
+		}
+		err := r.do(ctx, http.MethodPost, "/api/v1/jobs", body, &created)
+		var reqErr *requestError
+		if errors.As(err, &reqErr) && reqErr.busy() {
+			wait := reqErr.retryAfter
+			if wait <= 0 {
+				wait = busyWait
+			}
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(wait):
+			}
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return created.JobID, nil
+	}
+}
+
+// wait polls the job until it finished.
+func (r *runner) wait(ctx context.Context, jobID string) (jobStatus, error) {
+	ticker := time.NewTicker(r.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		var status jobStatus
+		if err := r.do(ctx, http.MethodGet, "/api/v1/jobs/"+url.PathEscape(jobID), nil, &status); err != nil {
+			return status, err
+		}
+		switch domain.JobStatus(status.Status) {
+		case domain.JobStatusCompleted, domain.JobStatusFailed, domain.JobStatusCancelled, domain.JobStatusExpired:
+			return status, nil
+		}
+		select {
+		case <-ctx.Done():
+			return status, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// cancel cancels a job that timed out, so it doesn't keep using the provider.
+func (r *runner) cancel(jobID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	r.do(ctx, http.MethodDelete, "/api/v1/jobs/"+url.PathEscape(jobID), nil, nil) //nolint:errcheck // best effort
+}
+
+// download saves the job's result to path.
+func (r *runner) download(ctx context.Context, jobID, path string) error {
+	resp, err := r.send(ctx, http.MethodGet, "/api/v1/jobs/"+url.PathEscape(jobID)+"/result", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		file.Close() //nolint:errcheck
+		return err
+	}
+	return file.Close()
+}
+
+// do sends a JSON request and decodes the response into out, when set.
+func (r *runner) do(ctx context.Context, method, path string, body []byte, out any) error {
+	resp, err := r.send(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// send sends a request to the target and turns error statuses into a
+// *requestError.
+func (r *runner) send(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, r.opts.Target+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if r.opts.APIKey != "" {
+		req.Header.Set("X-API-Key", r.opts.APIKey)
+	}
+
+	resp, err := r.opts.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	reqErr := &requestError{status: resp.StatusCode, message: http.StatusText(resp.StatusCode)}
+	var errResp domain.ErrorResponse
+	if json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&errResp) == nil && errResp.Error != nil {
+		reqErr.code, reqErr.message = errResp.Error.Code, errResp.Error.Message
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		reqErr.retryAfter = time.Duration(seconds) * time.Second
+	}
+	return nil, reqErr
+}
@@ -0,0 +1,251 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// fakeTarget is a server that completes jobs on their second status poll.
+// Texts steer it: "fail" fails the job, "reject" is refused, "slow" never
+// finishes, and "busy" is answered 503 on its first submission.
+type fakeTarget struct {
+	mu        sync.Mutex
+	jobs      map[string]string
+	polls     map[string]int
+	submitted []map[string]any
+	cancelled []string
+	busy      bool
+}
+
+func newFakeTarget(t *testing.T) (*fakeTarget, *httptest.Server) {
+	t.Helper()
+	f := &fakeTarget{jobs: make(map[string]string), polls: make(map[string]int)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeTarget) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("X-API-Key") != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/v1/jobs/")
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/jobs":
+		var req map[string]any
+		json.NewDecoder(r.Body).Decode(&req) //nolint:errcheck
+		text := req["text"].(string)
+		if text == "reject" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(domain.ErrorResponse{Error: domain.ErrValidation}) //nolint:errcheck
+			return
+		}
+		if text == "busy" && !f.busy {
+			f.busy = true
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		f.submitted = append(f.submitted, req)
+		jobID := fmt.Sprintf("new-%d", len(f.submitted))
+		f.jobs[jobID] = text
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{"job_id": jobID}) //nolint:errcheck
+	case r.Method == http.MethodDelete:
+		f.cancelled = append(f.cancelled, id)
+	case r.Method == http.MethodGet && strings.HasSuffix(id, "/result"):
+		w.Write([]byte("audio of " + f.jobs[strings.TrimSuffix(id, "/result")])) //nolint:errcheck
+	case r.Method == http.MethodGet:
+		f.polls[id]++
+		status := map[string]any{"job_id": id, "status": "processing"}
+		switch text := f.jobs[id]; {
+		case text == "slow" || f.polls[id] < 2:
+		case text == "fail":
+			status["status"] = "failed"
+			status["error_code"] = "PROVIDER_ERROR"
+			status["error_message"] = "upstream broke"
+		default:
+			status["status"] = "completed"
+			status["result_provider"] = "gemini"
+			status["duration_seconds"] = 2.2
+		}
+		json.NewEncoder(w).Encode(status) //nolint:errcheck
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func entry(id, text string, offset time.Duration) Entry {
+	return Entry{
+		JobID:        id,
+		Text:         text,
+		VoiceID:      "rachel",
+		Provider:     "elevenlabs",
+		OutputFormat: "mp3",
+		CreatedAt:    time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).Add(offset),
+		Status:       domain.JobStatusCompleted,
+		LatencyMs:    1000,
+		AudioSeconds: 2,
+	}
+}
+
+func TestRun(t *testing.T) {
+	f, srv := newFakeTarget(t)
+	out := t.TempDir()
+	var seen []string
+	entries := []Entry{
+		entry("rec-2", "fail", time.Second),
+		entry("rec-1", "hello", 0),
+		entry("rec-3", "reject", 2*time.Second),
+	}
+
+	report, err := Run(context.Background(), entries, Options{
+		Target:       srv.URL + "/",
+		APIKey:       "secret",
+		Provider:     "gemini",
+		VoiceID:      "Kore",
+		PollInterval: time.Millisecond,
+		OutputDir:    out,
+		OnResult:     func(r Result) { seen = append(seen, r.RecordedJobID) },
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if report.Total != 3 || len(seen) != 3 || report.RecordedCompleted != 3 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	want := map[Outcome]int{OutcomeCompleted: 1, OutcomeFailed: 1, OutcomeRejected: 1}
+	for outcome, n := range want {
+		if report.Outcomes[outcome] != n {
+			t.Errorf("%s = %d, want %d", outcome, report.Outcomes[outcome], n)
+		}
+	}
+	if report.Errors["PROVIDER_ERROR"] != 1 || report.Errors[domain.ErrValidation.Code] != 1 {
+		t.Errorf("errors = %v", report.Errors)
+	}
+	if report.Compared != 1 || report.RecordedLatency.P50 != time.Second || report.DurationRatio != 1.1 {
+		t.Errorf("comparison = %+v", report)
+	}
+
+	// Results are in recorded order, and overrides replace the recorded values
+	if report.Results[0].RecordedJobID != "rec-1" || report.Results[0].Provider != "gemini" {
+		t.Errorf("first result = %+v", report.Results[0])
+	}
+	if f.submitted[0]["provider"] != "gemini" || f.submitted[0]["voice_id"] != "Kore" || f.submitted[0]["output_format"] != "mp3" {
+		t.Errorf("submitted %v", f.submitted[0])
+	}
+	data, err := os.ReadFile(filepath.Join(out, "rec-1.mp3"))
+	if err != nil || string(data) != "audio of hello" || report.Results[0].OutputPath == "" {
+		t.Errorf("saved result %q, %v", data, err)
+	}
+}
+
+func TestRun_RetriesBusyTarget(t *testing.T) {
+	f, srv := newFakeTarget(t)
+	report, err := Run(context.Background(), []Entry{entry("rec-1", "busy", 0)}, Options{
+		Target:       srv.URL,
+		APIKey:       "secret",
+		PollInterval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if report.Outcomes[OutcomeCompleted] != 1 || !f.busy || len(f.submitted) != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+}
+
+func TestRun_TimesOutAndCancels(t *testing.T) {
+	f, srv := newFakeTarget(t)
+	report, err := Run(context.Background(), []Entry{entry("rec-1", "slow", 0)}, Options{
+		Target:       srv.URL,
+		APIKey:       "secret",
+		PollInterval: time.Millisecond,
+		Timeout:      50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if report.Outcomes[OutcomeTimedOut] != 1 || report.Compared != 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.cancelled) != 1 || f.cancelled[0] != "new-1" {
+		t.Errorf("cancelled %v", f.cancelled)
+	}
+}
+
+func TestRun_Interrupted(t *testing.T) {
+	_, srv := newFakeTarget(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	entries := []Entry{entry("rec-1", "hello", 0), entry("rec-2", "hello", time.Hour)}
+
+	report, err := Run(ctx, entries, Options{
+		Target:       srv.URL,
+		APIKey:       "secret",
+		Speed:        1,
+		PollInterval: time.Millisecond,
+		OnResult:     func(Result) { cancel() },
+	})
+	if err == nil {
+		t.Fatal("expected the run to be interrupted")
+	}
+	if report == nil || report.Total != 1 || report.Outcomes[OutcomeCompleted] != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+}
+
+func TestReadManifest(t *testing.T) {
+	manifest := `{"job_id":"a","text":"hello","status":"completed","latency_ms":900}
+
+{"job_id":"b","text":"world","status":"failed","error_code":"PROVIDER_ERROR"}
+`
+	entries, err := ReadManifest(strings.NewReader(manifest))
+	if err != nil {
+		t.Fatalf("ReadManifest: %v", err)
+	}
+	if len(entries) != 2 || entries[0].LatencyMs != 900 || entries[1].ErrorCode != "PROVIDER_ERROR" {
+		t.Fatalf("entries = %+v", entries)
+	}
+	if !entries[0].completed() || entries[1].completed() {
+		t.Error("only the first entry completed")
+	}
+
+	if _, err := ReadManifest(strings.NewReader(`{"job_id":"a"}`)); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("entry without text: %v", err)
+	}
+	if _, err := ReadManifest(strings.NewReader("{\"text\":\"a\"}\nnot json")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("malformed entry: %v", err)
+	}
+}
+
+func TestNewEntry(t *testing.T) {
+	created := time.Now().Add(-time.Minute)
+	completed := created.Add(1500 * time.Millisecond)
+	job := &domain.Job{ID: "j", Text: "hello", Status: domain.JobStatusCompleted, CreatedAt: created, CompletedAt: &completed}
+
+	e, ok := NewEntry(job)
+	if !ok || e.LatencyMs != 1500 || e.Text != "hello" {
+		t.Fatalf("NewEntry = %+v, %v", e, ok)
+	}
+	job.Text = ""
+	if _, ok := NewEntry(job); ok {
+		t.Error("a job without text can't be replayed")
+	}
+}
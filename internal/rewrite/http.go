@@ -0,0 +1,89 @@
+// Package rewrite calls external HTTP services that rewrite text before it is
+// synthesized, as pipeline hooks.
+package rewrite
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pako-tts/server/internal/deadline"
+)
+
+// maxResponseBytes caps the size of a rewritten text read.
+const maxResponseBytes = 4 << 20
+
+// HTTPHook POSTs text as JSON, {"text": "..."}, to a URL. The service replies
+// with the rewritten text, either as JSON in the same shape or as the plain
+// body of any other content type, e.g. SSML.
+type HTTPHook struct {
+	url        string
+	headers    map[string]string
+	httpClient *http.Client
+}
+
+// NewHTTPHook creates a hook POSTing to url with the given extra headers, such
+// as an Authorization header.
+func NewHTTPHook(url string, headers map[string]string, timeout time.Duration) *HTTPHook {
+	return &HTTPHook{
+		url:        url,
+		headers:    headers,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+type hookBody struct {
+	Text string `json:"text"`
+}
+
+// Rewrite implements pipeline.Hook.
+func (h *HTTPHook) Rewrite(ctx context.Context, text string) (string, error) {
+	body, err := json.Marshal(hookBody{Text: text})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range h.headers {
+		req.Header.Set(name, value)
+	}
+	deadline.Set(req)
+
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("hook request failed: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return "", fmt.Errorf("failed to read hook response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("hook error (%d): %s", resp.StatusCode, strings.TrimSpace(string(data[:min(len(data), 200)])))
+	}
+
+	out := string(data)
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "application/json" {
+		var reply hookBody
+		if err := json.Unmarshal(data, &reply); err != nil {
+			return "", fmt.Errorf("failed to decode hook response: %w", err)
+		}
+		out = reply.Text
+	}
+	out = strings.TrimSpace(out)
+	if out == "" {
+		return "", errors.New("hook returned no text")
+	}
+	return out, nil
+}
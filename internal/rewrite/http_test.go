@@ -0,0 +1,73 @@
+package rewrite
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPHook_Rewrite(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{"json", "application/json; charset=utf-8", `{"text":"Hi there!"}`, "Hi there!"},
+		{"plain body", "application/ssml+xml", "<speak>Hi there!</speak>\n", "<speak>Hi there!</speak>"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var got hookBody
+			var auth string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				auth = r.Header.Get("Authorization")
+				json.NewDecoder(r.Body).Decode(&got) //nolint:errcheck
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Write([]byte(tt.body)) //nolint:errcheck
+			}))
+			t.Cleanup(server.Close)
+
+			hook := NewHTTPHook(server.URL, map[string]string{"Authorization": "Bearer token"}, time.Second)
+			out, err := hook.Rewrite(context.Background(), "Hello.")
+			if err != nil {
+				t.Fatalf("Rewrite: %v", err)
+			}
+			if out != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, out)
+			}
+			if got.Text != "Hello." || auth != "Bearer token" {
+				t.Errorf("expected the text with the configured headers, got %+v and %q", got, auth)
+			}
+		})
+	}
+}
+
+func TestHTTPHook_RewriteError(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"error status", http.StatusServiceUnavailable, "overloaded", "hook error (503): overloaded"},
+		{"empty text", http.StatusOK, `{"text":""}`, "no text"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body)) //nolint:errcheck
+			}))
+			t.Cleanup(server.Close)
+
+			_, err := NewHTTPHook(server.URL, nil, time.Second).Rewrite(context.Background(), "Hello.")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
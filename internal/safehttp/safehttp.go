@@ -0,0 +1,102 @@
+// Package safehttp builds the HTTP clients that request URLs API callers supply:
+// text sources, webhooks and job callbacks. They only connect to public
+// addresses, so a caller can't make the server reach loopback, private-network
+// or cloud metadata services on its behalf.
+package safehttp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// maxRedirects is how many redirects a client follows, as net/http does.
+const maxRedirects = 10
+
+// ErrBlockedAddress is returned, wrapped, for connections to addresses that
+// aren't public.
+var ErrBlockedAddress = errors.New("address is not public")
+
+// Ranges that IsGlobalUnicast and IsPrivate let through but that don't reach the
+// public internet, or that embed an IPv4 address the check wouldn't see.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"),  // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),  // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),    // reserved
+	netip.MustParsePrefix("64:ff9b::/96"),   // NAT64
+	netip.MustParsePrefix("64:ff9b:1::/48"), // local-use NAT64
+	netip.MustParsePrefix("2001::/32"),      // Teredo
+	netip.MustParsePrefix("2002::/16"),      // 6to4
+	netip.MustParsePrefix("100::/64"),       // discard-only
+	netip.MustParsePrefix("2001:db8::/32"),  // documentation
+}
+
+// Public reports whether ip is a public unicast address: not loopback,
+// private, link-local, multicast, unspecified or otherwise reserved.
+func Public(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Control is a net.Dialer Control hook refusing connections to addresses that
+// aren't public. It runs after DNS resolution, for every address dialled, so a
+// host name resolving to a private address is refused as well.
+func Control(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+	}
+	if !Public(ip) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, ip)
+	}
+	return nil
+}
+
+// NewClient returns a client that only connects to public addresses, bounding
+// each request by timeout. Redirects are followed to http and https URLs whose
+// host hostAllowed accepts; nil accepts any host. Proxies from the environment
+// are not used, since the proxy would make the connection the check can't see.
+func NewClient(timeout time.Duration, hostAllowed func(host string) bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   Control,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to %s URL refused", req.URL.Scheme)
+			}
+			if hostAllowed != nil && !hostAllowed(req.URL.Hostname()) {
+				return fmt.Errorf("redirect to host %s refused: not allowed", req.URL.Hostname())
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,75 @@
+package safehttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPublic(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":        true,
+		"2606:4700::1111":      true,
+		"127.0.0.1":            false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false,
+		"100.64.0.1":           false,
+		"0.0.0.0":              false,
+		"255.255.255.255":      false,
+		"224.0.0.1":            false,
+		"::1":                  false,
+		"::":                   false,
+		"fe80::1":              false,
+		"fd00:ec2::254":        false,
+		"::ffff:127.0.0.1":     false,
+		"::ffff:93.184.216.34": true,
+		"64:ff9b::a9fe:a9fe":   false,
+		"2002:7f00:1::":        false,
+	} {
+		if got := Public(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Public(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestNewClient_RefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	client := NewClient(time.Second, nil)
+
+	// localhost is resolved first, then refused
+	for _, url := range []string{srv.URL, strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)} {
+		resp, err := client.Get(url)
+		if err == nil {
+			resp.Body.Close() //nolint:errcheck
+		}
+		if !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("GET %s: expected ErrBlockedAddress, got %v", url, err)
+		}
+	}
+}
+
+func TestNewClient_ChecksRedirects(t *testing.T) {
+	client := NewClient(time.Second, func(host string) bool { return host == "docs.example.com" })
+	via := []*http.Request{httptest.NewRequest(http.MethodGet, "https://docs.example.com/", nil)}
+	for target, ok := range map[string]bool{
+		"https://docs.example.com/moved": true,
+		"https://evil.example.com/":      false,
+		"file:///etc/passwd":             false,
+	} {
+		err := client.CheckRedirect(httptest.NewRequest(http.MethodGet, target, nil), via)
+		if (err == nil) != ok {
+			t.Errorf("redirect to %s: got %v", target, err)
+		}
+	}
+	many := make([]*http.Request, maxRedirects)
+	if err := client.CheckRedirect(httptest.NewRequest(http.MethodGet, "https://docs.example.com/", nil), many); err == nil {
+		t.Error("expected redirects to stop")
+	}
+}
@@ -0,0 +1,112 @@
+// Package scheduler runs recurring background tasks, such as the result cleanup,
+// at fixed intervals. Next run times live in a domain.ScheduleStore: with a store
+// shared between instances, a run is taken by one instance only and a schedule
+// carries over restarts.
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// maxCheckInterval is the longest wait between looking for due tasks.
+const maxCheckInterval = time.Minute
+
+type task struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context) error
+}
+
+// Scheduler runs tasks when their store says they are due.
+type Scheduler struct {
+	store  domain.ScheduleStore
+	logger *zap.Logger
+	tasks  []task
+}
+
+// New creates a scheduler keeping its schedule in store.
+func New(store domain.ScheduleStore, logger *zap.Logger) *Scheduler {
+	return &Scheduler{store: store, logger: logger}
+}
+
+// Every registers run to be called every interval under name, which identifies
+// the task in the store. It must be called before Start.
+func (s *Scheduler) Every(name string, interval time.Duration, run func(ctx context.Context) error) {
+	s.tasks = append(s.tasks, task{name: name, interval: interval, run: run})
+}
+
+// Start looks for due tasks until ctx is done, as often as the shortest interval
+// but at least every minute. Due tasks run one after another.
+func (s *Scheduler) Start(ctx context.Context) {
+	check := maxCheckInterval
+	for _, t := range s.tasks {
+		check = min(check, t.interval)
+	}
+
+	go func() {
+		s.RunDue(ctx)
+		ticker := time.NewTicker(check)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.RunDue(ctx)
+			}
+		}
+	}()
+
+	for _, t := range s.tasks {
+		s.logger.Info("Task scheduled", zap.String("task", t.name), zap.Duration("interval", t.interval))
+	}
+}
+
+// RunDue runs the tasks whose run this instance claims.
+func (s *Scheduler) RunDue(ctx context.Context) {
+	for _, t := range s.tasks {
+		claimed, err := s.store.ClaimRun(ctx, t.name, t.interval)
+		if err != nil {
+			s.logger.Warn("Failed to claim scheduled run", zap.String("task", t.name), zap.Error(err))
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		if err := t.run(ctx); err != nil {
+			s.logger.Error("Scheduled task failed", zap.String("task", t.name), zap.Error(err))
+		}
+	}
+}
+
+// MemoryStore is a domain.ScheduleStore for a single instance. Its schedule is
+// lost on restart.
+type MemoryStore struct {
+	mu      sync.Mutex
+	nextRun map[string]time.Time
+}
+
+// NewMemoryStore creates an empty store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{nextRun: make(map[string]time.Time)}
+}
+
+// ClaimRun implements domain.ScheduleStore.
+func (m *MemoryStore) ClaimRun(ctx context.Context, task string, interval time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	next, ok := m.nextRun[task]
+	if ok && now.Before(next) {
+		return false, nil
+	}
+	m.nextRun[task] = now.Add(interval)
+	return ok, nil
+}
@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestMemoryStore_ClaimRun(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	// A new task is first due an interval later
+	if claimed, _ := store.ClaimRun(ctx, "cleanup", 10*time.Millisecond); claimed {
+		t.Fatal("first ClaimRun claimed a run")
+	}
+	if claimed, _ := store.ClaimRun(ctx, "cleanup", 10*time.Millisecond); claimed {
+		t.Fatal("ClaimRun claimed a run before it was due")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if claimed, _ := store.ClaimRun(ctx, "cleanup", 10*time.Millisecond); !claimed {
+		t.Fatal("ClaimRun didn't claim a due run")
+	}
+	if claimed, _ := store.ClaimRun(ctx, "cleanup", 10*time.Millisecond); claimed {
+		t.Fatal("ClaimRun claimed a run twice")
+	}
+}
+
+func TestScheduler_RunDue_SharedStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	runs := 0
+	run := func(ctx context.Context) error {
+		runs++
+		return nil
+	}
+	a := New(store, zap.NewNop())
+	a.Every("cleanup", 10*time.Millisecond, run)
+	b := New(store, zap.NewNop())
+	b.Every("cleanup", 10*time.Millisecond, run)
+
+	a.RunDue(ctx)
+	b.RunDue(ctx)
+	if runs != 0 {
+		t.Fatalf("runs = %d before the task was due, want 0", runs)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	a.RunDue(ctx)
+	b.RunDue(ctx)
+	if runs != 1 {
+		t.Errorf("runs = %d with two schedulers sharing a store, want 1", runs)
+	}
+}
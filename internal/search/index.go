@@ -0,0 +1,174 @@
+// Package search provides an in-memory full-text index over jobs.
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Document is what the index keeps of a job: its tenant and the words of its
+// text and tags.
+type Document struct {
+	ID     string
+	Tenant string
+	Text   string
+	Tags   []string
+}
+
+// Hit is a document matching a search, with its relevance score.
+type Hit struct {
+	ID    string
+	Score float64
+}
+
+// tagWeight is how much more a word in a document's tags counts than one in its
+// text.
+const tagWeight = 2
+
+type entry struct {
+	tenant string
+	// source is the text and tags the entry was built from, so putting an
+	// unchanged document again is cheap.
+	source [2]string
+	text   map[string]int
+	tags   map[string]int
+}
+
+// Index is an inverted index from words to the documents containing them. It
+// is not safe for concurrent use; callers synchronize access.
+type Index struct {
+	docs     map[string]*entry
+	postings map[string]map[string]struct{}
+}
+
+// NewIndex creates an empty index.
+func NewIndex() *Index {
+	return &Index{
+		docs:     make(map[string]*entry),
+		postings: make(map[string]map[string]struct{}),
+	}
+}
+
+// Tokenize splits s into lowercase words: runs of letters and digits.
+func Tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Put adds doc to the index, replacing an earlier version of it.
+func (x *Index) Put(doc Document) {
+	source := [2]string{doc.Text, strings.Join(doc.Tags, " ")}
+	if e, ok := x.docs[doc.ID]; ok && e.tenant == doc.Tenant && e.source == source {
+		return
+	}
+	x.Remove(doc.ID)
+	e := &entry{
+		tenant: doc.Tenant,
+		source: source,
+		text:   counts(Tokenize(source[0])),
+		tags:   counts(Tokenize(source[1])),
+	}
+	x.docs[doc.ID] = e
+	for _, field := range []map[string]int{e.text, e.tags} {
+		for term := range field {
+			ids := x.postings[term]
+			if ids == nil {
+				ids = make(map[string]struct{})
+				x.postings[term] = ids
+			}
+			ids[doc.ID] = struct{}{}
+		}
+	}
+}
+
+// Remove drops the document id from the index.
+func (x *Index) Remove(id string) {
+	e, ok := x.docs[id]
+	if !ok {
+		return
+	}
+	for _, field := range []map[string]int{e.text, e.tags} {
+		for term := range field {
+			delete(x.postings[term], id)
+			if len(x.postings[term]) == 0 {
+				delete(x.postings, term)
+			}
+		}
+	}
+	delete(x.docs, id)
+}
+
+// Len returns the number of documents indexed.
+func (x *Index) Len() int {
+	return len(x.docs)
+}
+
+// Search returns the documents containing every word of query, of tenant only
+// when it is set, best matches first. A document whose text textOK rejects
+// matches on its tags only. Documents are scored by tf-idf, with words in
+// tags weighted higher.
+func (x *Index) Search(query, tenant string, textOK func(id string) bool) []Hit {
+	terms := unique(Tokenize(query))
+	if len(terms) == 0 {
+		return nil
+	}
+	// Walk the rarest word's documents; the others only narrow them down
+	sort.Slice(terms, func(i, j int) bool {
+		return len(x.postings[terms[i]]) < len(x.postings[terms[j]])
+	})
+
+	var hits []Hit
+	for id := range x.postings[terms[0]] {
+		e := x.docs[id]
+		if tenant != "" && e.tenant != tenant {
+			continue
+		}
+		text := e.text
+		if textOK != nil && !textOK(id) {
+			text = nil
+		}
+		score := 0.0
+		for _, term := range terms {
+			tf := text[term] + tagWeight*e.tags[term]
+			if tf == 0 {
+				score = 0
+				break
+			}
+			idf := math.Log(1 + float64(len(x.docs))/float64(len(x.postings[term])))
+			score += math.Sqrt(float64(tf)) * idf
+		}
+		if score > 0 {
+			hits = append(hits, Hit{ID: id, Score: score})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	return hits
+}
+
+func counts(terms []string) map[string]int {
+	m := make(map[string]int, len(terms))
+	for _, t := range terms {
+		m[t]++
+	}
+	return m
+}
+
+func unique(terms []string) []string {
+	seen := make(map[string]bool, len(terms))
+	out := terms[:0]
+	for _, t := range terms {
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
@@ -0,0 +1,82 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func ids(hits []Hit) []string {
+	out := []string{}
+	for _, h := range hits {
+		out = append(out, h.ID)
+	}
+	return out
+}
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("Narrate the Q3-report, 2024 (draft)… Müller!")
+	want := []string{"narrate", "the", "q3", "report", "2024", "draft", "müller"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize = %q, want %q", got, want)
+	}
+}
+
+func TestIndex_Search(t *testing.T) {
+	x := NewIndex()
+	x.Put(Document{ID: "a", Tenant: "acme", Text: "Welcome to the Q3 report. Revenue grew in Q3."})
+	x.Put(Document{ID: "b", Tenant: "acme", Text: "The weekly report", Tags: []string{"Q3 summary"}})
+	x.Put(Document{ID: "c", Tenant: "acme", Text: "Chapter one"})
+	x.Put(Document{ID: "d", Tenant: "globex", Text: "Our Q3 report"})
+
+	tests := []struct {
+		name   string
+		query  string
+		tenant string
+		textOK func(string) bool
+		want   []string
+	}{
+		{name: "every word must match", query: "q3 report", tenant: "acme", want: []string{"a", "b"}},
+		{name: "case and punctuation ignored", query: "REVENUE!", tenant: "acme", want: []string{"a"}},
+		{name: "all tenants", query: "q3 report", want: []string{"a", "b", "d"}},
+		{name: "other tenant", query: "q3", tenant: "globex", want: []string{"d"}},
+		{name: "no match", query: "q4", tenant: "acme", want: []string{}},
+		{name: "empty query", query: " ,. ", want: []string{}},
+		{
+			name: "expired text", query: "q3", tenant: "acme",
+			textOK: func(id string) bool { return id != "a" && id != "b" },
+			want:   []string{"b"},
+		},
+		{
+			name: "expired text doesn't combine with tags", query: "q3 weekly", tenant: "acme",
+			textOK: func(id string) bool { return id != "b" },
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ids(x.Search(tt.query, tt.tenant, tt.textOK))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndex_PutReplacesAndRemove(t *testing.T) {
+	x := NewIndex()
+	x.Put(Document{ID: "a", Text: "old words"})
+	x.Put(Document{ID: "a", Text: "new words"})
+
+	if got := ids(x.Search("old", "", nil)); len(got) != 0 {
+		t.Errorf("Search(old) = %q after replacing the document", got)
+	}
+	if got := ids(x.Search("new", "", nil)); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("Search(new) = %q, want [a]", got)
+	}
+
+	x.Remove("a")
+	if x.Len() != 0 || len(x.postings) != 0 {
+		t.Errorf("index holds %d documents and %d words after Remove", x.Len(), len(x.postings))
+	}
+	x.Remove("a")
+}
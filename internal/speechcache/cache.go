@@ -0,0 +1,152 @@
+// Package speechcache keeps synthesized audio so requests can be answered without
+// calling the provider. The speech cache holds audio synthesized ahead of time by
+// cache-warming jobs, kept until removed from its directory; the result cache
+// holds the audio of every request for a while, for repeated requests such as
+// templated notifications.
+package speechcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// Request is what a cached entry was synthesized from. Every field that changes
+// the audio is part of its key, and so is the tenant that requested it: a tenant
+// is only answered from its own entries, so it can't learn from a hit what other
+// tenants had synthesized.
+type Request struct {
+	Tenant       string                 `json:"tenant"`
+	Provider     string                 `json:"provider"`
+	Text         string                 `json:"text"`
+	VoiceID      string                 `json:"voice_id"`
+	ModelID      string                 `json:"model_id,omitempty"`
+	LanguageCode string                 `json:"language_code,omitempty"`
+	Format       string                 `json:"format"`
+	Settings     *domain.VoiceSettings  `json:"settings,omitempty"`
+	Padding      *domain.PaddingOptions `json:"padding,omitempty"`
+	Pipeline     []domain.PipelineStage `json:"pipeline,omitempty"`
+}
+
+// RequestForJob describes the synthesis a job performs.
+func RequestForJob(job *domain.Job) Request {
+	return Request{
+		Tenant:       job.Tenant(),
+		Provider:     job.ProviderName,
+		Text:         job.Text,
+		VoiceID:      job.VoiceID,
+		ModelID:      job.ModelID,
+		LanguageCode: job.LanguageCode,
+		Format:       job.OutputFormat,
+		Settings:     job.VoiceSettings,
+		Padding:      job.Padding,
+		Pipeline:     job.Pipeline,
+	}
+}
+
+// Key is the hex SHA-256 of the request.
+func (r Request) Key() string {
+	data, _ := json.Marshal(r) //nolint:errcheck // plain struct, can't fail
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Cache is a filesystem implementation of domain.SpeechCache.
+type Cache struct {
+	dir string
+	// ttl is how long entries are served after they were stored; 0 keeps them.
+	ttl time.Duration
+}
+
+// New creates a cache in dir, creating the directory if needed.
+func New(dir string) (*Cache, error) {
+	return NewExpiring(dir, 0)
+}
+
+// NewExpiring creates a cache in dir whose entries expire ttl after they were
+// stored; 0 keeps them. RemoveExpired deletes expired entries.
+func NewExpiring(dir string, ttl time.Duration) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create speech cache directory: %w", err)
+	}
+	return &Cache{dir: dir, ttl: ttl}, nil
+}
+
+// Get returns the cached audio for key.
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, bool) {
+	if !c.Has(ctx, key) {
+		return nil, false
+	}
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// Has reports whether audio is cached for key.
+func (c *Cache) Has(ctx context.Context, key string) bool {
+	info, err := os.Stat(c.path(key))
+	return err == nil && !c.expired(info)
+}
+
+// RemoveExpired deletes the entries that expired.
+func (c *Cache) RemoveExpired(ctx context.Context) error {
+	if c.ttl <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return fmt.Errorf("failed to list speech cache: %w", err)
+	}
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !strings.HasSuffix(entry.Name(), ".audio") {
+			continue
+		}
+		if info, err := entry.Info(); err == nil && c.expired(info) {
+			os.Remove(filepath.Join(c.dir, entry.Name())) //nolint:errcheck
+		}
+	}
+	return nil
+}
+
+func (c *Cache) expired(info os.FileInfo) bool {
+	return c.ttl > 0 && time.Since(info.ModTime()) > c.ttl
+}
+
+// Put stores audio under key. The file is written aside and renamed into place,
+// so readers never see a partial entry.
+func (c *Cache) Put(ctx context.Context, key string, audio []byte) error {
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create speech cache entry: %w", err)
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck // gone after a successful rename
+
+	if _, err := tmp.Write(audio); err != nil {
+		tmp.Close() //nolint:errcheck
+		return fmt.Errorf("failed to write speech cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write speech cache entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path(key)); err != nil {
+		return fmt.Errorf("failed to store speech cache entry: %w", err)
+	}
+	return nil
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, filepath.Base(key)+".audio")
+}
@@ -0,0 +1,88 @@
+package speechcache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+func TestCache_PutGet(t *testing.T) {
+	cache, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx := context.Background()
+
+	key := Request{Provider: "elevenlabs", Text: "Hello", VoiceID: "voice", Format: "mp3"}.Key()
+	if cache.Has(ctx, key) {
+		t.Fatal("expected an empty cache")
+	}
+	if err := cache.Put(ctx, key, []byte("audio")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	audio, ok := cache.Get(ctx, key)
+	if !ok || string(audio) != "audio" || !cache.Has(ctx, key) {
+		t.Errorf("expected the stored audio, got %q, %v", audio, ok)
+	}
+}
+
+func TestRequest_Key(t *testing.T) {
+	job := domain.NewJob("Hello", "voice", "", "", "elevenlabs", "mp3", nil)
+	base := RequestForJob(job)
+	if base.Key() != (Request{Tenant: domain.DefaultTenant, Provider: "elevenlabs", Text: "Hello", VoiceID: "voice", Format: "mp3"}).Key() {
+		t.Error("expected the job's request to match the equivalent sync request")
+	}
+
+	variants := []Request{base, base, base, base, base}
+	variants[0].Text = "Hello!"
+	variants[1].Format = "wav"
+	variants[2].Settings = &domain.VoiceSettings{}
+	variants[3].Padding = &domain.PaddingOptions{}
+	variants[4].Tenant = "acme"
+	for i, v := range variants {
+		if v.Key() == base.Key() {
+			t.Errorf("variant %d: expected a different key", i)
+		}
+	}
+}
+
+func TestCache_Expiry(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewExpiring(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("NewExpiring: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := cache.Put(ctx, "fresh", []byte("audio")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := cache.Put(ctx, "stale", []byte("audio")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "stale.audio"), old, old); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+
+	if _, ok := cache.Get(ctx, "stale"); ok || cache.Has(ctx, "stale") {
+		t.Error("expected the stale entry to have expired")
+	}
+	if _, ok := cache.Get(ctx, "fresh"); !ok {
+		t.Error("expected the fresh entry")
+	}
+
+	if err := cache.RemoveExpired(ctx); err != nil {
+		t.Fatalf("RemoveExpired: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "stale.audio")); !os.IsNotExist(err) {
+		t.Errorf("expected the stale entry to be removed, got %v", err)
+	}
+	if !cache.Has(ctx, "fresh") {
+		t.Error("expected the fresh entry to be kept")
+	}
+}
@@ -0,0 +1,248 @@
+// Package cleanup removes the stored results of expired jobs, and archives the
+// jobs themselves later on.
+package cleanup
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/metrics"
+)
+
+const (
+	// batchSize is how many expired jobs are listed, and their results removed, at a time.
+	batchSize = 200
+	// parallelism is how many batches are removed at once.
+	parallelism = 4
+)
+
+// Storage is the part of the result storage the cleaner works with.
+type Storage interface {
+	// DeleteResults removes the files of the given jobs, except the artifacts
+	// policy keeps longer than the audio, returning the number of files and bytes
+	// removed.
+	DeleteResults(ctx context.Context, jobIDs []string, policy domain.RetentionPolicy) (int, int64)
+	// CleanupExpired removes files older than policy keeps them, returning the
+	// number of files and bytes removed.
+	CleanupExpired(ctx context.Context, policy domain.RetentionPolicy) (int, int64, error)
+}
+
+// Cleaner removes expired results. The job store serves as the expiry index:
+// each run lists the jobs whose results expired since the previous run, removes
+// their files and marks the jobs expired, so the work follows the number of expired jobs, not the
+// number of stored files. A sweep for files older than the retention period
+// follows, for results no job accounts for, e.g. those of jobs lost when the
+// in-memory queue restarted, and for artifacts kept for a different period than
+// the audio they were derived from.
+type Cleaner struct {
+	jobs         domain.JobQueue
+	storage      Storage
+	retention    domain.RetentionPolicy
+	metrics      *metrics.CleanupMetrics
+	logger       *zap.Logger
+	onExpired    func(ctx context.Context, job *domain.Job)
+	archive      domain.JobArchive
+	archiveAfter time.Duration
+
+	// watermark is the expiry time up to which results have been removed, and
+	// archiveWatermark the one up to which expired jobs have been archived.
+	watermark        time.Time
+	archiveWatermark time.Time
+}
+
+// NewCleaner creates a cleaner. m may be nil.
+func NewCleaner(jobs domain.JobQueue, storage Storage, retentionHours int, m *metrics.CleanupMetrics, logger *zap.Logger) *Cleaner {
+	return &Cleaner{
+		jobs:      jobs,
+		storage:   storage,
+		retention: domain.RetentionPolicy{Result: time.Duration(retentionHours) * time.Hour},
+		metrics:   m,
+		logger:    logger,
+	}
+}
+
+// RetainArtifacts keeps each kind of artifact in retention for its own period
+// instead of as long as the audio. It must be set before Start.
+func (c *Cleaner) RetainArtifacts(retention map[string]time.Duration) {
+	c.retention.Artifacts = retention
+}
+
+// OnExpired registers fn to be called after a job is marked expired. It must be
+// set before Start.
+func (c *Cleaner) OnExpired(fn func(ctx context.Context, job *domain.Job)) {
+	c.onExpired = fn
+}
+
+// ArchiveAfter has expired jobs replaced with their archived record in archive
+// once their result expired after ago. It must be set before Start.
+func (c *Cleaner) ArchiveAfter(archive domain.JobArchive, after time.Duration) {
+	c.archive = archive
+	c.archiveAfter = after
+}
+
+// Run removes the results that expired since the previous run, archives the
+// jobs due for it, then sweeps for expired files. It must not be called
+// concurrently.
+func (c *Cleaner) Run(ctx context.Context) error {
+	if err := c.removeExpiredJobs(ctx); err != nil {
+		return err
+	}
+	if err := c.archiveExpiredJobs(ctx); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	files, bytes, err := c.storage.CleanupExpired(ctx, c.retention)
+	c.metrics.Observe(metrics.CleanupSweep, time.Since(start), files, bytes)
+	return err
+}
+
+// removeExpiredJobs removes the results of the jobs expiring after the
+// watermark and by now, in batches of batchSize spread over parallelism
+// goroutines. The watermark only moves once every batch is done, so a run that
+// fails is repeated in full by the next.
+func (c *Cleaner) removeExpiredJobs(ctx context.Context) error {
+	start := time.Now()
+	filter := domain.JobFilter{
+		ExpiresAfter: c.watermark,
+		ExpiresBy:    start,
+		Ascending:    true,
+		Limit:        batchSize,
+	}
+
+	var files, bytes atomic.Int64
+	batches := make(chan []*domain.Job)
+	var wg sync.WaitGroup
+	for range parallelism {
+		wg.Go(func() {
+			for jobs := range batches {
+				jobIDs := make([]string, len(jobs))
+				for i, job := range jobs {
+					jobIDs[i] = job.ID
+				}
+				n, size := c.storage.DeleteResults(ctx, jobIDs, c.retention)
+				files.Add(int64(n))
+				bytes.Add(size)
+				c.markExpired(ctx, jobs)
+			}
+		})
+	}
+
+	err := c.listExpired(ctx, filter, batches)
+	close(batches)
+	wg.Wait()
+
+	c.metrics.Observe(metrics.CleanupIndex, time.Since(start), int(files.Load()), bytes.Load())
+	if err != nil {
+		return err
+	}
+	c.watermark = start
+
+	if files.Load() > 0 {
+		c.logger.Info("Removed expired job results",
+			zap.Int64("files", files.Load()),
+			zap.Int64("bytes", bytes.Load()),
+			zap.Duration("duration", time.Since(start)),
+		)
+	}
+	return nil
+}
+
+// markExpired marks the completed jobs among jobs expired. Jobs in any other
+// status, e.g. failed after a retry, keep it.
+func (c *Cleaner) markExpired(ctx context.Context, jobs []*domain.Job) {
+	for _, job := range jobs {
+		if err := job.SetExpired(); err != nil {
+			continue
+		}
+		if err := c.jobs.UpdateJob(ctx, job); err != nil {
+			c.logger.Warn("Failed to mark job expired", zap.String("job_id", job.ID), zap.Error(err))
+			continue
+		}
+		if c.onExpired != nil {
+			c.onExpired(ctx, job)
+		}
+	}
+}
+
+// archiveExpiredJobs archives the expired jobs whose result expired after the
+// archive watermark and archiveAfter ago or earlier. Like the results' watermark,
+// the archive watermark only moves once every job is done.
+func (c *Cleaner) archiveExpiredJobs(ctx context.Context) error {
+	if c.archive == nil {
+		return nil
+	}
+	start := time.Now()
+	until := start.Add(-c.archiveAfter)
+	filter := domain.JobFilter{
+		Status:       domain.JobStatusExpired,
+		ExpiresAfter: c.archiveWatermark,
+		ExpiresBy:    until,
+		Ascending:    true,
+		Limit:        batchSize,
+	}
+
+	archived, failed := 0, false
+	batches := make(chan []*domain.Job)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for jobs := range batches {
+			for _, job := range jobs {
+				if err := c.archive.ArchiveJob(ctx, job); err != nil {
+					c.logger.Warn("Failed to archive job", zap.String("job_id", job.ID), zap.Error(err))
+					failed = true
+					continue
+				}
+				archived++
+			}
+		}
+	}()
+
+	err := c.listExpired(ctx, filter, batches)
+	close(batches)
+	<-done
+
+	c.metrics.Observe(metrics.CleanupArchive, time.Since(start), 0, 0)
+	c.metrics.Archived(archived)
+	if err != nil {
+		return err
+	}
+	if !failed {
+		c.archiveWatermark = until
+	}
+
+	if archived > 0 {
+		c.logger.Info("Archived expired jobs",
+			zap.Int("jobs", archived),
+			zap.Duration("duration", time.Since(start)),
+		)
+	}
+	return nil
+}
+
+// listExpired sends the jobs matching filter to batches, one page at a time.
+func (c *Cleaner) listExpired(ctx context.Context, filter domain.JobFilter, batches chan<- []*domain.Job) error {
+	for {
+		page, err := c.jobs.ListJobs(ctx, filter)
+		if err != nil {
+			return err
+		}
+		if len(page.Jobs) > 0 {
+			select {
+			case batches <- page.Jobs:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if page.Next == nil {
+			return nil
+		}
+		filter.After = page.Next
+	}
+}
@@ -0,0 +1,193 @@
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/metrics"
+	"github.com/pako-tts/server/internal/queue/memory"
+	"github.com/pako-tts/server/internal/storage/filesystem"
+)
+
+// completedJob stores a result for a new job and marks the job completed,
+// expiring at expiresAt.
+func completedJob(t *testing.T, queue domain.JobQueue, storage *filesystem.Storage, expiresAt time.Time) *domain.Job {
+	t.Helper()
+	ctx := context.Background()
+
+	job := domain.NewJob("hello", "voice", "", "", "provider", "mp3", nil)
+	if err := queue.Enqueue(ctx, job); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	path, err := storage.Store(ctx, job.ID, []byte("audio"), "mp3")
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	job.SetCompleted(path, 24)
+	job.ExpiresAt = &expiresAt
+	if err := queue.UpdateJob(ctx, job); err != nil {
+		t.Fatalf("UpdateJob: %v", err)
+	}
+	return job
+}
+
+func TestCleaner_RemovesResultsOfExpiredJobs(t *testing.T) {
+	ctx := context.Background()
+	queue := memory.NewQueue(batchSize * 2)
+	storage, err := filesystem.NewStorage(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+
+	// More than a page of expired jobs, so listing continues past the first
+	var expired []*domain.Job
+	for range batchSize + 5 {
+		expired = append(expired, completedJob(t, queue, storage, time.Now().Add(-time.Minute)))
+	}
+	kept := completedJob(t, queue, storage, time.Now().Add(time.Hour))
+
+	registry := metrics.NewRegistry()
+	cleaner := NewCleaner(queue, storage, 24, metrics.NewCleanupMetrics(registry), zap.NewNop())
+	var notified atomic.Int64
+	cleaner.OnExpired(func(ctx context.Context, job *domain.Job) { notified.Add(1) })
+	if err := cleaner.Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	for _, job := range expired {
+		if storage.Exists(ctx, job.ID) {
+			t.Fatalf("Expected the result of expired job %s to be removed", job.ID)
+		}
+		if got, _ := queue.GetJob(ctx, job.ID); got.Status != domain.JobStatusExpired {
+			t.Fatalf("Expected job %s to be marked expired, got %s", job.ID, got.Status)
+		}
+	}
+	if n := notified.Load(); n != int64(len(expired)) {
+		t.Errorf("Expected %d expired notifications, got %d", len(expired), n)
+	}
+	if !storage.Exists(ctx, kept.ID) {
+		t.Error("Expected the result of the unexpired job to be kept")
+	}
+	if got, _ := queue.GetJob(ctx, kept.ID); got.Status != domain.JobStatusCompleted {
+		t.Errorf("Expected the unexpired job to stay completed, got %s", got.Status)
+	}
+
+	var out strings.Builder
+	if err := registry.WriteText(&out); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	for _, want := range []string{
+		fmt.Sprintf(`pako_tts_cleanup_deleted_files_total{phase="index"} %d`, len(expired)),
+		fmt.Sprintf(`pako_tts_cleanup_deleted_bytes_total{phase="index"} %d`, 5*len(expired)),
+		`pako_tts_cleanup_duration_seconds_count{phase="sweep"} 1`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", want, out.String())
+		}
+	}
+
+	// Jobs handled by an earlier run aren't listed again
+	if _, err := storage.Store(ctx, expired[0].ID, []byte("audio"), "mp3"); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if err := cleaner.Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !storage.Exists(ctx, expired[0].ID) {
+		t.Error("Expected the second run to list only jobs expired since the first")
+	}
+}
+
+func TestCleaner_ArchivesJobsAfterTheArchiveDelay(t *testing.T) {
+	ctx := context.Background()
+	queue := memory.NewQueue(10)
+	storage, err := filesystem.NewStorage(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	old := completedJob(t, queue, storage, time.Now().Add(-2*time.Hour))
+	recent := completedJob(t, queue, storage, time.Now().Add(-time.Minute))
+
+	registry := metrics.NewRegistry()
+	cleaner := NewCleaner(queue, storage, 24, metrics.NewCleanupMetrics(registry), zap.NewNop())
+	cleaner.ArchiveAfter(queue, time.Hour)
+	if err := cleaner.Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if _, err := queue.GetJob(ctx, old.ID); err == nil {
+		t.Error("Expected the job expired for longer than the archive delay to be archived")
+	}
+	if got, _ := queue.GetJob(ctx, recent.ID); got == nil || got.Status != domain.JobStatusExpired {
+		t.Errorf("Expected the recently expired job to be kept, got %+v", got)
+	}
+
+	// Analytics still count the archived job
+	day := time.Now().UTC().Truncate(24 * time.Hour)
+	analytics, err := queue.Analytics(ctx, domain.AnalyticsQuery{From: day, To: day.AddDate(0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Analytics: %v", err)
+	}
+	if analytics.Totals.Completed != 2 || analytics.Totals.Characters != 10 {
+		t.Errorf("Expected 2 completed jobs of 10 characters, got %+v", analytics.Totals)
+	}
+
+	var out strings.Builder
+	if err := registry.WriteText(&out); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	if want := "pako_tts_cleanup_archived_jobs_total 1"; !strings.Contains(out.String(), want) {
+		t.Errorf("Expected metrics to contain %q, got:\n%s", want, out.String())
+	}
+}
+
+func TestCleaner_KeepsArtifactsRetainedLonger(t *testing.T) {
+	ctx := context.Background()
+	queue := memory.NewQueue(10)
+	storage, err := filesystem.NewStorage(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	retention := map[string]time.Duration{domain.ArtifactKindPreview: 30 * 24 * time.Hour}
+
+	job := completedJob(t, queue, storage, time.Now().Add(time.Hour))
+	for _, name := range []string{domain.ArtifactPreview, domain.ArtifactWaveform} {
+		if err := storage.StoreArtifact(ctx, job.ID, name, []byte("data")); err != nil {
+			t.Fatalf("StoreArtifact: %v", err)
+		}
+		job.AddArtifact(name)
+		job.RetainArtifact(name, domain.RetentionPolicy{Result: 24 * time.Hour, Artifacts: retention})
+	}
+	expiresAt := time.Now().Add(-time.Minute)
+	job.ExpiresAt = &expiresAt
+	if err := queue.UpdateJob(ctx, job); err != nil {
+		t.Fatalf("UpdateJob: %v", err)
+	}
+
+	cleaner := NewCleaner(queue, storage, 24, nil, zap.NewNop())
+	cleaner.RetainArtifacts(retention)
+	if err := cleaner.Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	got, _ := queue.GetJob(ctx, job.ID)
+	if got.Status != domain.JobStatusExpired || storage.Exists(ctx, job.ID) {
+		t.Fatalf("Expected the result removed and the job expired, got %s", got.Status)
+	}
+	if !got.ArtifactAvailable(domain.ArtifactPreview) || got.HasArtifact(domain.ArtifactWaveform) {
+		t.Errorf("Expected only the preview to be kept, got %v", got.Artifacts)
+	}
+	if _, err := storage.RetrieveArtifact(ctx, job.ID, domain.ArtifactPreview); err != nil {
+		t.Errorf("Expected the preview file to be kept: %v", err)
+	}
+	if _, err := storage.RetrieveArtifact(ctx, job.ID, domain.ArtifactWaveform); err == nil {
+		t.Error("Expected the waveform file to be removed with the result")
+	}
+}
@@ -0,0 +1,234 @@
+package filesystem
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/audio/transcode"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/storage/keys"
+)
+
+// cleanupBatchSize is how many files are removed between index updates. The
+// storage lock is only held for the update, so stores wait at most for one
+// batch's bookkeeping, never for a whole cleanup.
+const cleanupBatchSize = 500
+
+// storedFile is a file found by a cleanup scan, relative to the base path.
+type storedFile struct {
+	path string
+	size int64
+}
+
+// DeleteResults removes the audio and artifacts of the given jobs, except the
+// artifacts policy keeps longer than the audio, returning the number of files
+// and bytes removed. The jobs are dropped from the index under the lock, or
+// kept without audio when artifacts are left; their files are removed after it
+// is released.
+func (s *Storage) DeleteResults(ctx context.Context, jobIDs []string, policy domain.RetentionPolicy) (int, int64) {
+	s.mu.Lock()
+	dirs := make(map[string]string, len(jobIDs))
+	for _, jobID := range jobIDs {
+		if loc, ok := s.lookupLocked(jobID); ok {
+			dirs[jobID] = loc.dir
+			delete(s.index, jobID)
+		}
+	}
+	s.mu.Unlock()
+
+	var found []storedFile
+	kept := make(map[string]string)
+	for jobID, dir := range dirs {
+		paths, _ := filepath.Glob(filepath.Join(s.basePath, dir, jobID+".*"))
+		for _, path := range paths {
+			if _, rest := keys.SplitName(filepath.Base(path)); policy.Outlives(rest) {
+				kept[jobID] = dir
+				continue
+			}
+			if info, err := os.Stat(path); err == nil {
+				found = append(found, storedFile{path: path, size: info.Size()})
+			}
+		}
+	}
+	if len(kept) > 0 {
+		s.mu.Lock()
+		for jobID, dir := range kept {
+			if _, ok := s.index[jobID]; !ok {
+				s.index[jobID] = location{dir: dir}
+			}
+		}
+		s.mu.Unlock()
+	}
+
+	files, bytes := 0, int64(0)
+	for _, f := range found {
+		if err := os.Remove(f.path); err == nil {
+			files++
+			bytes += f.size
+		}
+	}
+	return files, bytes
+}
+
+// CleanupExpired removes files older than policy keeps them, returning the
+// number of files and bytes removed. With a key strategy that keeps one day per
+// top-level directory (domain.DatedKeys), day directories that ended before the
+// longest retention are removed whole, so only the days after it are checked
+// file by file. Other layouts, and files left in the flat layout from before
+// sharding, are checked file by file.
+//
+// The directories are scanned without holding the storage lock, and the files
+// found are removed in batches of cleanupBatchSize, each followed by a short
+// index update under the lock.
+func (s *Storage) CleanupExpired(ctx context.Context, policy domain.RetentionPolicy) (int, int64, error) {
+	now := time.Now()
+	expired := func(name string, modTime time.Time) bool {
+		_, rest := keys.SplitName(name)
+		return modTime.Before(now.Add(-policy.For(rest)))
+	}
+	cutoff := now.Add(-policy.Longest())
+
+	s.mu.RLock()
+	tops := s.sortedTops()
+	s.mu.RUnlock()
+
+	var found []storedFile
+	var wholeDays []string
+	dated, isDated := s.keys.(domain.DatedKeys)
+	for _, top := range tops {
+		if !isDated {
+			found = append(found, s.scanTree(top, expired)...)
+			continue
+		}
+		start, ok := dated.Day(top)
+		if !ok || !start.Before(now.Add(-policy.Shortest())) {
+			continue
+		}
+		if start.Add(24 * time.Hour).After(cutoff) {
+			found = append(found, s.scanTree(top, expired)...)
+			continue
+		}
+		found = append(found, s.scanTree(top, nil)...)
+		wholeDays = append(wholeDays, top)
+	}
+	found = append(found, s.scanDir("", expired)...)
+
+	files, bytes, err := s.removeFiles(ctx, found)
+	if err != nil {
+		return files, bytes, err
+	}
+	for _, day := range wholeDays {
+		s.removeDay(day)
+	}
+
+	if files > 0 {
+		s.logger.Info("Cleanup completed",
+			zap.Int("deleted", files),
+			zap.Int64("bytes", bytes),
+			zap.Int("days_removed", len(wholeDays)),
+			zap.Int("retention_hours", int(policy.Result.Hours())),
+		)
+	}
+	return files, bytes, nil
+}
+
+// expiry reports whether the file with the given name, last modified at
+// modTime, has expired.
+type expiry func(name string, modTime time.Time) bool
+
+// scanTree lists the expired files below dir; with a nil expired, all of them.
+func (s *Storage) scanTree(dir string, expired expiry) []storedFile {
+	found := s.scanDir(dir, expired)
+	entries, _ := os.ReadDir(filepath.Join(s.basePath, dir))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			found = append(found, s.scanTree(filepath.Join(dir, entry.Name()), expired)...)
+		}
+	}
+	return found
+}
+
+// scanDir lists the expired files directly in dir; with a nil expired, all of
+// them.
+func (s *Storage) scanDir(dir string, expired expiry) []storedFile {
+	entries, err := os.ReadDir(filepath.Join(s.basePath, dir))
+	if err != nil {
+		s.logger.Warn("Failed to read storage directory", zap.String("dir", dir), zap.Error(err))
+		return nil
+	}
+
+	var found []storedFile
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || (expired != nil && !expired(entry.Name(), info.ModTime())) {
+			continue
+		}
+		found = append(found, storedFile{path: filepath.Join(dir, entry.Name()), size: info.Size()})
+	}
+	return found
+}
+
+// removeFiles removes files in batches, dropping the jobs whose audio was
+// removed from the index after each batch, and the directories left empty. It
+// stops early when ctx is done.
+func (s *Storage) removeFiles(ctx context.Context, files []storedFile) (int, int64, error) {
+	deleted, bytes := 0, int64(0)
+	for start := 0; start < len(files); start += cleanupBatchSize {
+		if err := ctx.Err(); err != nil {
+			return deleted, bytes, err
+		}
+
+		batch := files[start:min(start+cleanupBatchSize, len(files))]
+		var removed []string
+		for _, f := range batch {
+			if err := os.Remove(filepath.Join(s.basePath, f.path)); err != nil {
+				continue
+			}
+			deleted++
+			bytes += f.size
+			removed = append(removed, f.path)
+		}
+
+		s.mu.Lock()
+		for _, path := range removed {
+			dir := filepath.Dir(path)
+			if dir == "." {
+				dir = ""
+			}
+			if jobID, rest := keys.SplitName(filepath.Base(path)); transcode.IsOutputFormat(rest) && s.index[jobID].dir == dir {
+				delete(s.index, jobID)
+			}
+			if dir != "" {
+				os.Remove(filepath.Join(s.basePath, dir)) //nolint:errcheck // fails while files are left
+			}
+		}
+		s.mu.Unlock()
+	}
+	return deleted, bytes, nil
+}
+
+// removeDay removes a day directory whose files are gone and drops any of its
+// jobs still in the index.
+func (s *Storage) removeDay(day string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.RemoveAll(filepath.Join(s.basePath, day)); err != nil {
+		s.logger.Warn("Failed to delete expired day of audio files", zap.String("day", day), zap.Error(err))
+		return
+	}
+	delete(s.tops, day)
+	for jobID, loc := range s.index {
+		if topOf(loc.dir) == day {
+			delete(s.index, jobID)
+		}
+	}
+	s.logger.Debug("Deleted expired day of audio files", zap.String("day", day))
+}
@@ -0,0 +1,235 @@
+package filesystem
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/audio/transcode"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/storage/keys"
+)
+
+// searchInterval bounds how often a store whose key strategy can't locate jobs
+// from their ID is searched for a job missing from the index.
+const searchInterval = 10 * time.Second
+
+// location is where a job's files are kept.
+type location struct {
+	// dir is the directory relative to the base path; "" is the base path
+	// itself, where results were kept before storage was sharded.
+	dir string
+	// format is the format of the job's audio; "" while only artifacts are stored.
+	format string
+}
+
+// dirLocked returns the directory, relative to the base path, for the objects
+// of the job ref describes, and records its top-level directory. The caller
+// holds s.mu for writing.
+func (s *Storage) dirLocked(ref domain.KeyRef) string {
+	dir := filepath.FromSlash(s.keys.Dir(ref))
+	if top := topOf(dir); top != "" {
+		s.tops[top] = true
+	}
+	return dir
+}
+
+// topOf returns the top-level directory of dir.
+func topOf(dir string) string {
+	top, _, _ := strings.Cut(filepath.ToSlash(dir), "/")
+	return top
+}
+
+// loadTops records the top-level directories already on disk.
+func (s *Storage) loadTops() error {
+	entries, err := os.ReadDir(s.basePath)
+	if err != nil {
+		return fmt.Errorf("failed to read storage directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			s.tops[entry.Name()] = true
+		}
+	}
+	return nil
+}
+
+// sortedTops returns the known top-level directories.
+func (s *Storage) sortedTops() []string {
+	tops := make([]string, 0, len(s.tops))
+	for top := range s.tops {
+		tops = append(tops, top)
+	}
+	sort.Strings(tops)
+	return tops
+}
+
+// find is lookupLocked for callers that don't hold s.mu.
+func (s *Storage) find(jobID string) (location, bool) {
+	s.mu.RLock()
+	loc, ok := s.index[jobID]
+	s.mu.RUnlock()
+	if ok && loc.format != "" {
+		return loc, true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lookupLocked(jobID)
+}
+
+// lookupLocked returns where jobID's files are kept; loc.format is empty when
+// the job has no audio, e.g. when only artifacts kept longer than it are left.
+// Jobs missing from the index, stored before a restart or by another instance
+// sharing the directory, are looked for in the directories the key strategy
+// locates them in, or searched for when it can't, and added to the index. A result still in the pre-sharding flat layout is moved to where
+// the key strategy keeps it first. The caller holds s.mu for writing.
+func (s *Storage) lookupLocked(jobID string) (location, bool) {
+	if loc, ok := s.index[jobID]; ok {
+		if loc.format == "" {
+			loc.format = s.probe(loc.dir, jobID)
+			s.index[jobID] = loc
+		}
+		return loc, true
+	}
+
+	dirs, ok := s.keys.Locate(jobID, s.sortedTops())
+	if !ok {
+		s.searchLocked()
+		if loc, ok := s.index[jobID]; ok {
+			return loc, true
+		}
+	}
+	for _, dir := range dirs {
+		dir = filepath.FromSlash(dir)
+		if format := s.probe(dir, jobID); format != "" {
+			loc := location{dir: dir, format: format}
+			s.index[jobID] = loc
+			return loc, true
+		}
+	}
+	for _, dir := range dirs {
+		dir = filepath.FromSlash(dir)
+		if paths, _ := filepath.Glob(filepath.Join(s.basePath, dir, jobID+".*")); len(paths) > 0 {
+			loc := location{dir: dir}
+			s.index[jobID] = loc
+			return loc, true
+		}
+	}
+
+	if format := s.probe("", jobID); format != "" {
+		loc := s.migrateLocked(jobID, format)
+		s.index[jobID] = loc
+		return loc, true
+	}
+	return location{}, false
+}
+
+// searchLocked adds every job stored below the base path to the index, where
+// its audio is or, without audio, its artifacts, at most once per
+// searchInterval. The caller holds s.mu for writing.
+func (s *Storage) searchLocked() {
+	if time.Since(s.searched) < searchInterval {
+		return
+	}
+	s.searched = time.Now()
+
+	err := filepath.WalkDir(s.basePath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || strings.HasPrefix(entry.Name(), ".") || entry.IsDir() {
+			return nil
+		}
+		jobID, rest := keys.SplitName(entry.Name())
+		dir, err := filepath.Rel(s.basePath, filepath.Dir(path))
+		if err != nil {
+			return nil
+		}
+		if dir == "." {
+			dir = ""
+		}
+		loc, ok := s.index[jobID]
+		switch {
+		case transcode.IsOutputFormat(rest) && (!ok || loc.format == ""):
+			s.index[jobID] = location{dir: dir, format: rest}
+		case !ok:
+			s.index[jobID] = location{dir: dir}
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.Warn("Failed to search storage directory", zap.Error(err))
+	}
+}
+
+// probe returns the format of jobID's audio in dir, or "" when there is none.
+func (s *Storage) probe(dir, jobID string) string {
+	for _, format := range transcode.OutputFormats {
+		if _, err := os.Stat(filepath.Join(s.basePath, dir, jobID+"."+format)); err == nil {
+			return format
+		}
+	}
+	return ""
+}
+
+// migrateLocked moves a result in the flat layout, with its artifacts, to where
+// the key strategy keeps it, as stored when its audio was written. If the audio
+// can't be moved, the result stays where it is and is still served from there.
+func (s *Storage) migrateLocked(jobID, format string) location {
+	legacy := location{format: format}
+	audioPath := filepath.Join(s.basePath, jobID+"."+format)
+	info, err := os.Stat(audioPath)
+	if err != nil {
+		return legacy
+	}
+	audio, err := os.ReadFile(audioPath)
+	if err != nil {
+		return legacy
+	}
+	dir := s.dirLocked(domain.KeyRef{JobID: jobID, StoredAt: info.ModTime(), ContentHash: keys.ContentHash(audio)})
+	if dir == "" {
+		return legacy
+	}
+	if err := os.MkdirAll(filepath.Join(s.basePath, dir), 0755); err != nil {
+		s.logger.Warn("Failed to migrate stored result", zap.String("job_id", jobID), zap.Error(err))
+		return legacy
+	}
+	if err := os.Rename(audioPath, filepath.Join(s.basePath, dir, filepath.Base(audioPath))); err != nil {
+		s.logger.Warn("Failed to migrate stored result", zap.String("job_id", jobID), zap.Error(err))
+		return legacy
+	}
+
+	// Artifacts follow the audio
+	moved, _ := s.moveLocked(jobID, "", dir)
+
+	s.logger.Debug("Migrated stored result into its shard",
+		zap.String("job_id", jobID),
+		zap.String("dir", dir),
+		zap.Int("artifacts", moved),
+	)
+	return location{dir: dir, format: format}
+}
+
+// moveLocked moves jobID's audio and artifacts from the directory from to to,
+// returning how many files were moved. It fails only when to can't be created;
+// files that can't be moved are left behind. The caller holds s.mu for writing.
+func (s *Storage) moveLocked(jobID, from, to string) (int, error) {
+	if err := os.MkdirAll(filepath.Join(s.basePath, to), 0755); err != nil {
+		return 0, err
+	}
+	// This lists from, which for the flat layout is large, but only once per result
+	files, _ := filepath.Glob(filepath.Join(s.basePath, from, jobID+".*"))
+	moved := 0
+	for _, file := range files {
+		if err := os.Rename(file, filepath.Join(s.basePath, to, filepath.Base(file))); err != nil {
+			s.logger.Warn("Failed to move stored file", zap.String("path", file), zap.Error(err))
+			continue
+		}
+		moved++
+	}
+	return moved, nil
+}
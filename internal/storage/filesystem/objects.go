@@ -0,0 +1,124 @@
+package filesystem
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pako-tts/server/internal/audio/transcode"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/storage/keys"
+)
+
+// ListObjects implements domain.ObjectStore. Keys are file names, without the
+// shard directory, so objects keep their keys across backends and layouts.
+func (s *Storage) ListObjects(ctx context.Context) ([]domain.ObjectInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var objects []domain.ObjectInfo
+	err := filepath.WalkDir(s.basePath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Skip directories and PutObject's temporary files
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		objects = append(objects, domain.ObjectInfo{Key: entry.Name(), Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage directory: %w", err)
+	}
+	return objects, nil
+}
+
+// OpenObject implements domain.ObjectStore.
+func (s *Storage) OpenObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	key = filepath.Base(key)
+	jobID, _ := keys.SplitName(key)
+	loc, _ := s.find(jobID)
+
+	file, err := os.Open(filepath.Join(s.basePath, loc.dir, key))
+	if err != nil {
+		return nil, fmt.Errorf("object %s not found: %w", key, err)
+	}
+	return file, nil
+}
+
+// PutObject implements domain.ObjectStore. The object goes into its job's
+// directory, or where the key strategy keeps a job new to this store, as stored
+// at its modification time; the job's audio takes its artifacts along when the
+// strategy places it elsewhere. It is written to a temporary file first, so an
+// interrupted copy never leaves a truncated result behind.
+func (s *Storage) PutObject(ctx context.Context, info domain.ObjectInfo, r io.Reader) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := filepath.Base(info.Key)
+	jobID, rest := keys.SplitName(key)
+
+	tmp, err := os.CreateTemp(s.basePath, ".put-*")
+	if err != nil {
+		return fmt.Errorf("failed to create object: %w", err)
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck // gone after the rename
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), r); err != nil {
+		tmp.Close() //nolint:errcheck
+		return fmt.Errorf("failed to write object %s: %w", info.Key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write object %s: %w", info.Key, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to write object %s: %w", info.Key, err)
+	}
+	if !info.ModTime.IsZero() {
+		if err := os.Chtimes(tmp.Name(), info.ModTime, info.ModTime); err != nil {
+			return fmt.Errorf("failed to set modification time of %s: %w", info.Key, err)
+		}
+	}
+
+	loc, known := s.lookupLocked(jobID)
+	ref := domain.KeyRef{JobID: jobID, Tenant: domain.TenantFromContext(ctx), StoredAt: info.ModTime}
+	if ref.StoredAt.IsZero() {
+		ref.StoredAt = time.Now()
+	}
+	switch {
+	case transcode.IsOutputFormat(rest):
+		ref.ContentHash = hex.EncodeToString(hash.Sum(nil))
+		if dir := s.dirLocked(ref); !known || dir != loc.dir {
+			if known {
+				if _, err := s.moveLocked(jobID, loc.dir, dir); err != nil {
+					return fmt.Errorf("failed to create object %s: %w", info.Key, err)
+				}
+			}
+			loc.dir = dir
+		}
+		loc.format = rest
+	case !known:
+		loc = location{dir: s.dirLocked(ref)}
+	}
+	if err := os.MkdirAll(filepath.Join(s.basePath, loc.dir), 0755); err != nil {
+		return fmt.Errorf("failed to create object %s: %w", info.Key, err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.basePath, loc.dir, key)); err != nil {
+		return fmt.Errorf("failed to write object %s: %w", info.Key, err)
+	}
+	s.index[jobID] = loc
+	return nil
+}
@@ -11,39 +11,80 @@ import (
 	"time"
 
 	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/audio/transcode"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/storage/keys"
 )
 
-// Storage is a filesystem implementation of domain.AudioStorage.
+// Storage is a filesystem implementation of domain.AudioStorage. A job's audio and
+// artifacts are kept together as <jobID>.<format> and <jobID>.<name> in the
+// directory its key strategy picks, by default the shard directory of the day the
+// job was stored (see keys.Sharded), and an index of job locations answers
+// Retrieve and Exists without probing for each format.
 type Storage struct {
 	basePath string
+	keys     domain.KeyStrategy
 	mu       sync.RWMutex
 	logger   *zap.Logger
+	index    map[string]location
+	tops     map[string]bool // top-level directories on disk
+	searched time.Time       // last search of the whole store, see searchLocked
 }
 
-// NewStorage creates a new filesystem storage.
+// NewStorage creates a new filesystem storage with the default sharded layout.
 func NewStorage(basePath string, logger *zap.Logger) (*Storage, error) {
+	return NewStorageWithKeys(basePath, keys.Sharded, logger)
+}
+
+// NewStorageWithKeys creates a new filesystem storage that keeps each job's
+// files where strategy says. Results stored under another strategy are only
+// found again after moving them, e.g. with cmd/migrate.
+func NewStorageWithKeys(basePath string, strategy domain.KeyStrategy, logger *zap.Logger) (*Storage, error) {
 	// Create base directory if it doesn't exist
 	if err := os.MkdirAll(basePath, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create storage directory: %w", err)
 	}
 
-	return &Storage{
+	s := &Storage{
 		basePath: basePath,
+		keys:     strategy,
 		logger:   logger,
-	}, nil
+		index:    make(map[string]location),
+		tops:     make(map[string]bool),
+	}
+	if err := s.loadTops(); err != nil {
+		return nil, err
+	}
+	return s, nil
 }
 
-// Store saves audio data and returns the storage path.
+// Store saves audio data and returns the storage path. The job's tenant is taken
+// from ctx (see domain.WithTenant). Artifacts stored earlier in another directory
+// are moved along with the audio.
 func (s *Storage) Store(ctx context.Context, jobID string, audio []byte, format string) (string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	filename := fmt.Sprintf("%s.%s", jobID, format)
-	filePath := filepath.Join(s.basePath, filename)
+	dir := s.dirLocked(domain.KeyRef{
+		JobID:       jobID,
+		Tenant:      domain.TenantFromContext(ctx),
+		StoredAt:    time.Now(),
+		ContentHash: keys.ContentHash(audio),
+	})
+	if loc, ok := s.index[jobID]; ok && loc.dir != dir {
+		if _, err := s.moveLocked(jobID, loc.dir, dir); err != nil {
+			return "", fmt.Errorf("failed to create storage directory: %w", err)
+		}
+	} else if err := os.MkdirAll(filepath.Join(s.basePath, dir), 0755); err != nil {
+		return "", fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	filePath := filepath.Join(s.basePath, dir, jobID+"."+format)
 
 	if err := os.WriteFile(filePath, audio, 0644); err != nil {
 		return "", fmt.Errorf("failed to write audio file: %w", err)
 	}
+	s.index[jobID] = location{dir: dir, format: format}
 
 	s.logger.Debug("Audio stored",
 		zap.String("job_id", jobID),
@@ -56,135 +97,106 @@ func (s *Storage) Store(ctx context.Context, jobID string, audio []byte, format
 
 // Retrieve returns a reader for the stored audio file.
 func (s *Storage) Retrieve(ctx context.Context, jobID string) (io.ReadCloser, string, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	// Try common formats
-	for _, format := range []string{"mp3", "wav"} {
-		filename := fmt.Sprintf("%s.%s", jobID, format)
-		filePath := filepath.Join(s.basePath, filename)
-
-		file, err := os.Open(filePath)
+	loc, ok := s.find(jobID)
+	if ok && loc.format != "" {
+		file, err := os.Open(filepath.Join(s.basePath, loc.dir, jobID+"."+loc.format))
 		if err == nil {
-			contentType := "audio/mpeg"
-			if format == "wav" {
-				contentType = "audio/wav"
-			}
-			return file, contentType, nil
+			return file, transcode.ContentType(loc.format), nil
 		}
+		// Removed behind the index's back, e.g. by another instance's cleanup
+		s.forget(jobID)
 	}
 
 	return nil, "", fmt.Errorf("audio file not found for job %s", jobID)
 }
 
-// Delete removes the stored audio file.
+// forget drops jobID from the index.
+func (s *Storage) forget(jobID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.index, jobID)
+}
+
+// Delete removes the stored audio file and any artifacts derived from it.
 func (s *Storage) Delete(ctx context.Context, jobID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Try to delete common formats
-	for _, format := range []string{"mp3", "wav"} {
-		filename := fmt.Sprintf("%s.%s", jobID, format)
-		filePath := filepath.Join(s.basePath, filename)
-		os.Remove(filePath) //nolint:errcheck // Ignore errors for non-existent files
+	loc, ok := s.lookupLocked(jobID)
+	if !ok {
+		return nil
 	}
-
-	return nil
-}
-
-// Exists checks if audio exists for the given job.
-func (s *Storage) Exists(ctx context.Context, jobID string) bool {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	for _, format := range []string{"mp3", "wav"} {
-		filename := fmt.Sprintf("%s.%s", jobID, format)
-		filePath := filepath.Join(s.basePath, filename)
-		if _, err := os.Stat(filePath); err == nil {
-			return true
-		}
+	files, _ := filepath.Glob(filepath.Join(s.basePath, loc.dir, jobID+".*"))
+	for _, filePath := range files {
+		os.Remove(filePath) //nolint:errcheck // Ignore errors for files already gone
 	}
+	delete(s.index, jobID)
 
-	return false
-}
-
-// GetPath returns the storage path for a job's audio.
-func (s *Storage) GetPath(ctx context.Context, jobID string) string {
-	for _, format := range []string{"mp3", "wav"} {
-		filename := fmt.Sprintf("%s.%s", jobID, format)
-		filePath := filepath.Join(s.basePath, filename)
-		if _, err := os.Stat(filePath); err == nil {
-			return filePath
-		}
-	}
-	return ""
+	return nil
 }
 
-// CleanupExpired removes audio files older than the retention period.
-func (s *Storage) CleanupExpired(ctx context.Context, retentionHours int) (int, error) {
+// StoreArtifact saves a derived file next to the job's audio as <jobID>.<name>.
+// Like Store, it takes the job's tenant from ctx.
+func (s *Storage) StoreArtifact(ctx context.Context, jobID, name string, data []byte) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	cutoff := time.Now().Add(-time.Duration(retentionHours) * time.Hour)
-	deleted := 0
+	loc, ok := s.lookupLocked(jobID)
+	if !ok {
+		loc = location{dir: s.dirLocked(domain.KeyRef{
+			JobID:    jobID,
+			Tenant:   domain.TenantFromContext(ctx),
+			StoredAt: time.Now(),
+		})}
+		if err := os.MkdirAll(filepath.Join(s.basePath, loc.dir), 0755); err != nil {
+			return fmt.Errorf("failed to create storage directory: %w", err)
+		}
+		s.index[jobID] = loc
+	}
 
-	entries, err := os.ReadDir(s.basePath)
-	if err != nil {
-		return 0, fmt.Errorf("failed to read storage directory: %w", err)
+	filePath := s.artifactPath(loc, jobID, name)
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write artifact: %w", err)
 	}
 
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
+	s.logger.Debug("Artifact stored",
+		zap.String("job_id", jobID),
+		zap.String("path", filePath),
+		zap.Int("size", len(data)),
+	)
 
-		info, err := entry.Info()
-		if err != nil {
-			continue
-		}
+	return nil
+}
 
-		if info.ModTime().Before(cutoff) {
-			filePath := filepath.Join(s.basePath, entry.Name())
-			if err := os.Remove(filePath); err == nil {
-				deleted++
-				s.logger.Debug("Deleted expired audio file",
-					zap.String("path", filePath),
-					zap.Time("modified", info.ModTime()),
-				)
-			}
-		}
+// RetrieveArtifact returns a reader for a stored artifact.
+func (s *Storage) RetrieveArtifact(ctx context.Context, jobID, name string) (io.ReadCloser, error) {
+	loc, ok := s.find(jobID)
+	if !ok {
+		return nil, fmt.Errorf("artifact %s not found for job %s", name, jobID)
 	}
 
-	if deleted > 0 {
-		s.logger.Info("Cleanup completed",
-			zap.Int("deleted", deleted),
-			zap.Int("retention_hours", retentionHours),
-		)
+	file, err := os.Open(s.artifactPath(loc, jobID, name))
+	if err != nil {
+		return nil, fmt.Errorf("artifact %s not found for job %s", name, jobID)
 	}
+	return file, nil
+}
 
-	return deleted, nil
+func (s *Storage) artifactPath(loc location, jobID, name string) string {
+	return filepath.Join(s.basePath, loc.dir, jobID+"."+filepath.Base(name))
 }
 
-// StartCleanupScheduler starts a goroutine that periodically cleans up expired files.
-func (s *Storage) StartCleanupScheduler(ctx context.Context, retentionHours int, interval time.Duration) {
-	go func() {
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				if _, err := s.CleanupExpired(ctx, retentionHours); err != nil {
-					s.logger.Error("Cleanup failed", zap.Error(err))
-				}
-			}
-		}
-	}()
+// Exists checks if audio exists for the given job.
+func (s *Storage) Exists(ctx context.Context, jobID string) bool {
+	loc, ok := s.find(jobID)
+	return ok && loc.format != ""
+}
 
-	s.logger.Info("Cleanup scheduler started",
-		zap.Int("retention_hours", retentionHours),
-		zap.Duration("interval", interval),
-	)
+// GetPath returns the storage path for a job's audio.
+func (s *Storage) GetPath(ctx context.Context, jobID string) string {
+	loc, ok := s.find(jobID)
+	if !ok || loc.format == "" {
+		return ""
+	}
+	return filepath.Join(s.basePath, loc.dir, jobID+"."+loc.format)
 }
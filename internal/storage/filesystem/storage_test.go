@@ -5,10 +5,14 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/storage/keys"
 )
 
 func testLogger() *zap.Logger {
@@ -16,6 +20,11 @@ func testLogger() *zap.Logger {
 	return logger
 }
 
+// shardDir returns the directory the default layout keeps a job stored at t in.
+func shardDir(t time.Time, jobID string) string {
+	return filepath.FromSlash(keys.Sharded.Dir(domain.KeyRef{JobID: jobID, StoredAt: t}))
+}
+
 func TestNewStorage(t *testing.T) {
 	tempDir := t.TempDir()
 	logger := testLogger()
@@ -66,7 +75,7 @@ func TestStorage_Store(t *testing.T) {
 		t.Fatalf("Failed to store audio: %v", err)
 	}
 
-	expectedPath := filepath.Join(tempDir, "test-job-123.mp3")
+	expectedPath := filepath.Join(tempDir, shardDir(time.Now(), jobID), "test-job-123.mp3")
 	if path != expectedPath {
 		t.Errorf("Expected path %s, got %s", expectedPath, path)
 	}
@@ -267,7 +276,7 @@ func TestStorage_CleanupExpired(t *testing.T) {
 	os.Chtimes(oldFile, oldTime, oldTime) //nolint:errcheck
 
 	// Cleanup with 24 hour retention
-	deleted, err := storage.CleanupExpired(ctx, 24)
+	deleted, bytes, err := storage.CleanupExpired(ctx, domain.RetentionPolicy{Result: 24 * time.Hour})
 	if err != nil {
 		t.Fatalf("CleanupExpired failed: %v", err)
 	}
@@ -275,6 +284,9 @@ func TestStorage_CleanupExpired(t *testing.T) {
 	if deleted != 1 {
 		t.Errorf("Expected 1 deleted file, got %d", deleted)
 	}
+	if bytes != 3 {
+		t.Errorf("Expected 3 deleted bytes, got %d", bytes)
+	}
 
 	// Old file should be gone
 	if _, err := os.Stat(oldFile); !os.IsNotExist(err) {
@@ -286,3 +298,277 @@ func TestStorage_CleanupExpired(t *testing.T) {
 		t.Error("New file should still exist")
 	}
 }
+
+func TestStorage_Artifacts(t *testing.T) {
+	tempDir := t.TempDir()
+	storage, _ := NewStorage(tempDir, testLogger())
+	ctx := context.Background()
+
+	if _, err := storage.Store(ctx, "job-1", []byte("audio"), "mp3"); err != nil {
+		t.Fatalf("Failed to store audio: %v", err)
+	}
+	if err := storage.StoreArtifact(ctx, "job-1", "preview.mp3", []byte("clip")); err != nil {
+		t.Fatalf("Failed to store artifact: %v", err)
+	}
+
+	reader, err := storage.RetrieveArtifact(ctx, "job-1", "preview.mp3")
+	if err != nil {
+		t.Fatalf("Failed to retrieve artifact: %v", err)
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close() //nolint:errcheck
+	if string(data) != "clip" {
+		t.Errorf("Expected artifact 'clip', got %q", data)
+	}
+
+	// The main result is still what Retrieve returns
+	audio, _, err := storage.Retrieve(ctx, "job-1")
+	if err != nil {
+		t.Fatalf("Failed to retrieve audio: %v", err)
+	}
+	data, _ = io.ReadAll(audio)
+	audio.Close() //nolint:errcheck
+	if string(data) != "audio" {
+		t.Errorf("Expected audio 'audio', got %q", data)
+	}
+
+	if err := storage.Delete(ctx, "job-1"); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if _, err := storage.RetrieveArtifact(ctx, "job-1", "preview.mp3"); err == nil {
+		t.Error("Expected artifact to be deleted with the job")
+	}
+}
+
+func TestStorage_MigratesFlatLayoutLazily(t *testing.T) {
+	tempDir := t.TempDir()
+	ctx := context.Background()
+
+	// A result and its preview stored before sharding, two days ago
+	stored := time.Now().Add(-48 * time.Hour)
+	for name, data := range map[string]string{"old-job.wav": "audio", "old-job.preview.mp3": "clip"} {
+		path := filepath.Join(tempDir, name)
+		os.WriteFile(path, []byte(data), 0644) //nolint:errcheck
+		os.Chtimes(path, stored, stored)       //nolint:errcheck
+	}
+	storage, _ := NewStorage(tempDir, testLogger())
+
+	reader, contentType, err := storage.Retrieve(ctx, "old-job")
+	if err != nil {
+		t.Fatalf("Failed to retrieve flat result: %v", err)
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close() //nolint:errcheck
+	if string(data) != "audio" || contentType != "audio/wav" {
+		t.Errorf("unexpected result %q (%s)", data, contentType)
+	}
+
+	// Both files moved into the shard of the day the result was stored
+	dir := filepath.Join(tempDir, shardDir(stored, "old-job"))
+	for _, name := range []string{"old-job.wav", "old-job.preview.mp3"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected %s in %s: %v", name, dir, err)
+		}
+		if _, err := os.Stat(filepath.Join(tempDir, name)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be gone from the flat layout", name)
+		}
+	}
+	if path := storage.GetPath(ctx, "old-job"); path != filepath.Join(dir, "old-job.wav") {
+		t.Errorf("unexpected path %s", path)
+	}
+	if _, err := storage.RetrieveArtifact(ctx, "old-job", "preview.mp3"); err != nil {
+		t.Errorf("Failed to retrieve migrated artifact: %v", err)
+	}
+}
+
+func TestStorage_FindsResultsStoredByAnotherInstance(t *testing.T) {
+	tempDir := t.TempDir()
+	ctx := context.Background()
+	reader, _ := NewStorage(tempDir, testLogger())
+	writer, _ := NewStorage(tempDir, testLogger())
+
+	if reader.Exists(ctx, "job-1") {
+		t.Fatal("Job should not exist initially")
+	}
+	if _, err := writer.Store(ctx, "job-1", []byte("audio"), "mp3"); err != nil {
+		t.Fatalf("Failed to store audio: %v", err)
+	}
+	if !reader.Exists(ctx, "job-1") {
+		t.Error("Expected the other instance's result to be found")
+	}
+
+	if err := writer.Delete(ctx, "job-1"); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if _, _, err := reader.Retrieve(ctx, "job-1"); err == nil {
+		t.Error("Expected the deleted result to be gone")
+	}
+	if reader.Exists(ctx, "job-1") {
+		t.Error("Expected the deleted result to be dropped from the index")
+	}
+}
+
+func TestStorage_CleanupExpired_RemovesWholeDays(t *testing.T) {
+	tempDir := t.TempDir()
+	storage, _ := NewStorage(tempDir, testLogger())
+	ctx := context.Background()
+
+	old := time.Now().Add(-72 * time.Hour)
+	for _, name := range []string{"old-job.mp3", "old-job.waveform.json"} {
+		err := storage.PutObject(ctx, domain.ObjectInfo{Key: name, ModTime: old}, strings.NewReader("old"))
+		if err != nil {
+			t.Fatalf("PutObject: %v", err)
+		}
+	}
+	if _, err := storage.Store(ctx, "new-job", []byte("new"), "mp3"); err != nil {
+		t.Fatalf("Failed to store audio: %v", err)
+	}
+
+	deleted, _, err := storage.CleanupExpired(ctx, domain.RetentionPolicy{Result: 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("CleanupExpired failed: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("Expected 2 deleted files, got %d", deleted)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, old.UTC().Format("2006-01-02"))); !os.IsNotExist(err) {
+		t.Error("Expected the expired day to be removed")
+	}
+	if storage.Exists(ctx, "old-job") {
+		t.Error("Expected the expired job to be gone")
+	}
+	if !storage.Exists(ctx, "new-job") {
+		t.Error("Expected the new job to be kept")
+	}
+}
+
+func TestStorage_DeleteResults(t *testing.T) {
+	tempDir := t.TempDir()
+	storage, _ := NewStorage(tempDir, testLogger())
+	ctx := context.Background()
+
+	for _, jobID := range []string{"job-1", "job-2", "job-3"} {
+		if _, err := storage.Store(ctx, jobID, []byte("audio"), "mp3"); err != nil {
+			t.Fatalf("Failed to store audio: %v", err)
+		}
+	}
+	if err := storage.StoreArtifact(ctx, "job-1", "waveform.json", []byte("[]")); err != nil {
+		t.Fatalf("Failed to store artifact: %v", err)
+	}
+
+	files, bytes := storage.DeleteResults(ctx, []string{"job-1", "job-2", "missing"}, domain.RetentionPolicy{Result: 24 * time.Hour})
+	if files != 3 || bytes != 12 {
+		t.Errorf("Expected 3 files and 12 bytes deleted, got %d and %d", files, bytes)
+	}
+	if storage.Exists(ctx, "job-1") || storage.Exists(ctx, "job-2") {
+		t.Error("Expected the deleted results to be gone")
+	}
+	if !storage.Exists(ctx, "job-3") {
+		t.Error("Expected the other result to be kept")
+	}
+}
+
+func TestStorage_ArtifactRetention(t *testing.T) {
+	tempDir := t.TempDir()
+	storage, _ := NewStorageWithKeys(tempDir, keys.Sharded, testLogger())
+	ctx := context.Background()
+	policy := domain.RetentionPolicy{
+		Result: 24 * time.Hour,
+		Artifacts: map[string]time.Duration{
+			domain.ArtifactKindPreview:  30 * 24 * time.Hour,
+			domain.ArtifactKindWaveform: time.Hour,
+		},
+	}
+
+	path, err := storage.Store(ctx, "job-1", []byte("audio"), "mp3")
+	if err != nil {
+		t.Fatalf("Failed to store audio: %v", err)
+	}
+	for _, name := range []string{domain.ArtifactPreview, domain.ArtifactWaveform} {
+		if err := storage.StoreArtifact(ctx, "job-1", name, []byte("data")); err != nil {
+			t.Fatalf("Failed to store artifact: %v", err)
+		}
+	}
+
+	// The sweep removes the waveform once its shorter retention passed
+	old := time.Now().Add(-2 * time.Hour)
+	for _, name := range []string{"job-1.mp3", "job-1." + domain.ArtifactPreview, "job-1." + domain.ArtifactWaveform} {
+		os.Chtimes(filepath.Join(filepath.Dir(path), name), old, old) //nolint:errcheck
+	}
+	if deleted, _, err := storage.CleanupExpired(ctx, policy); err != nil || deleted != 1 {
+		t.Fatalf("Expected only the waveform cleaned up, got %d, %v", deleted, err)
+	}
+	if _, err := storage.RetrieveArtifact(ctx, "job-1", domain.ArtifactWaveform); err == nil {
+		t.Error("Expected the waveform to be gone")
+	}
+
+	// Removing the result at its expiry leaves the preview, which a restarted
+	// instance still finds
+	if files, _ := storage.DeleteResults(ctx, []string{"job-1"}, policy); files != 1 {
+		t.Errorf("Expected only the audio deleted, got %d files", files)
+	}
+	if storage.Exists(ctx, "job-1") {
+		t.Error("Expected the audio to be gone")
+	}
+	restarted, _ := NewStorageWithKeys(tempDir, keys.Sharded, testLogger())
+	reader, err := restarted.RetrieveArtifact(ctx, "job-1", domain.ArtifactPreview)
+	if err != nil {
+		t.Fatalf("Expected the preview to be kept: %v", err)
+	}
+	reader.Close() //nolint:errcheck
+
+	// ... until its own retention passed
+	older := time.Now().Add(-31 * 24 * time.Hour)
+	os.Chtimes(filepath.Join(filepath.Dir(path), "job-1."+domain.ArtifactPreview), older, older) //nolint:errcheck
+	if deleted, _, err := restarted.CleanupExpired(ctx, policy); err != nil || deleted != 1 {
+		t.Errorf("Expected the preview cleaned up, got %d, %v", deleted, err)
+	}
+}
+
+func TestStorage_KeyStrategies(t *testing.T) {
+	for _, strategy := range []domain.KeyStrategy{keys.Flat, keys.Sharded, keys.Tenant, keys.Content} {
+		t.Run(strategy.Name(), func(t *testing.T) {
+			tempDir := t.TempDir()
+			ctx := domain.WithTenant(context.Background(), "acme")
+			storage, _ := NewStorageWithKeys(tempDir, strategy, testLogger())
+
+			// An artifact stored ahead of the audio follows it
+			if err := storage.StoreArtifact(ctx, "job-1", "waveform.json", []byte("[]")); err != nil {
+				t.Fatalf("Failed to store artifact: %v", err)
+			}
+			path, err := storage.Store(ctx, "job-1", []byte("audio"), "mp3")
+			if err != nil {
+				t.Fatalf("Failed to store audio: %v", err)
+			}
+			want := filepath.Join(tempDir, filepath.FromSlash(strategy.Dir(domain.KeyRef{
+				JobID:       "job-1",
+				Tenant:      "acme",
+				StoredAt:    time.Now(),
+				ContentHash: keys.ContentHash([]byte("audio")),
+			})), "job-1.mp3")
+			if path != want {
+				t.Errorf("Expected path %s, got %s", want, path)
+			}
+			if _, err := os.Stat(filepath.Join(filepath.Dir(path), "job-1.waveform.json")); err != nil {
+				t.Errorf("Expected the artifact next to the audio: %v", err)
+			}
+
+			// A restarted instance finds the job again
+			restarted, _ := NewStorageWithKeys(tempDir, strategy, testLogger())
+			if got := restarted.GetPath(ctx, "job-1"); got != path {
+				t.Errorf("Expected %s found after a restart, got %q", path, got)
+			}
+			if _, err := restarted.RetrieveArtifact(ctx, "job-1", "waveform.json"); err != nil {
+				t.Errorf("Failed to retrieve artifact after a restart: %v", err)
+			}
+
+			deleted, _, err := restarted.CleanupExpired(ctx, domain.RetentionPolicy{Result: -time.Hour})
+			if err != nil || deleted != 2 {
+				t.Errorf("Expected both files cleaned up, got %d, %v", deleted, err)
+			}
+			if restarted.Exists(ctx, "job-1") {
+				t.Error("Expected the expired job to be gone")
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package gcs
+
+import (
+	"context"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/audio/transcode"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/storage/keys"
+)
+
+// deleteConcurrency is how many objects are deleted at once; the API deletes
+// one object per request.
+const deleteConcurrency = 8
+
+// DeleteResults removes the audio and artifacts of the given jobs, except the
+// artifacts policy keeps longer than the audio, returning the number of objects
+// and bytes removed.
+func (s *Storage) DeleteResults(ctx context.Context, jobIDs []string, policy domain.RetentionPolicy) (int, int64) {
+	var found []object
+	for _, jobID := range jobIDs {
+		loc, ok := s.find(ctx, jobID)
+		if !ok {
+			continue
+		}
+		objects, _, err := s.client.list(ctx, listQuery{prefix: s.name(loc.dir, jobID+".")})
+		if err != nil {
+			s.logger.Warn("Failed to list results", zap.String("job_id", jobID), zap.Error(err))
+			continue
+		}
+		kept := false
+		for _, obj := range objects {
+			if _, rest := keys.SplitName(obj.Name); policy.Outlives(rest) {
+				kept = true
+				continue
+			}
+			found = append(found, obj)
+		}
+		if kept {
+			s.remember(jobID, location{dir: loc.dir})
+		} else {
+			s.forget(jobID)
+		}
+	}
+
+	removed := s.removeObjects(ctx, found)
+	files, bytes := len(removed), int64(0)
+	for _, obj := range removed {
+		bytes += obj.Size
+	}
+	return files, bytes
+}
+
+// CleanupExpired removes objects older than policy keeps them, returning the
+// number of objects and bytes removed. As in the filesystem backend, with a key
+// strategy that keeps one day per top-level directory (domain.DatedKeys), day
+// directories that ended before the longest retention are removed whole,
+// without looking at their objects' times, and only the days after it are
+// checked object by object. Other layouts, and objects directly below the prefix, are checked
+// object by object. An object's age counts from its custom time, which results
+// copied from another backend keep, else from when it was written.
+func (s *Storage) CleanupExpired(ctx context.Context, policy domain.RetentionPolicy) (int, int64, error) {
+	now := time.Now()
+	expired := func(obj object) bool {
+		_, rest := keys.SplitName(obj.Name)
+		return obj.modTime().Before(now.Add(-policy.For(rest)))
+	}
+	cutoff := now.Add(-policy.Longest())
+
+	if err := s.loadTops(ctx); err != nil {
+		return 0, 0, err
+	}
+	var found []object
+	var wholeDays []string
+	dated, isDated := s.keys.(domain.DatedKeys)
+	if !isDated {
+		objects, _, err := s.client.list(ctx, listQuery{prefix: s.prefix})
+		if err != nil {
+			return 0, 0, err
+		}
+		found = filter(objects, expired)
+	} else {
+		root, _, err := s.client.list(ctx, listQuery{prefix: s.prefix, delimiter: "/"})
+		if err != nil {
+			return 0, 0, err
+		}
+		found = filter(root, expired)
+		for _, top := range s.sortedTops() {
+			start, ok := dated.Day(top)
+			if !ok || !start.Before(now.Add(-policy.Shortest())) {
+				continue
+			}
+			objects, _, err := s.client.list(ctx, listQuery{prefix: s.prefix + top + "/"})
+			if err != nil {
+				return 0, 0, err
+			}
+			if start.Add(24 * time.Hour).After(cutoff) {
+				found = append(found, filter(objects, expired)...)
+				continue
+			}
+			found = append(found, objects...)
+			wholeDays = append(wholeDays, top)
+		}
+	}
+
+	removed := s.removeObjects(ctx, found)
+	files, bytes := len(removed), int64(0)
+	s.mu.Lock()
+	for _, obj := range removed {
+		bytes += obj.Size
+		dir := path.Dir(strings.TrimPrefix(obj.Name, s.prefix))
+		if dir == "." {
+			dir = ""
+		}
+		if jobID, rest := keys.SplitName(obj.Name); transcode.IsOutputFormat(rest) && s.index[jobID].dir == dir {
+			delete(s.index, jobID)
+		}
+	}
+	for _, day := range wholeDays {
+		delete(s.tops, day)
+		for jobID, loc := range s.index {
+			if top, _, _ := strings.Cut(loc.dir, "/"); top == day {
+				delete(s.index, jobID)
+			}
+		}
+	}
+	s.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return files, bytes, err
+	}
+
+	if files > 0 {
+		s.logger.Info("Cleanup completed",
+			zap.Int("deleted", files),
+			zap.Int64("bytes", bytes),
+			zap.Int("days_removed", len(wholeDays)),
+			zap.Int("retention_hours", int(policy.Result.Hours())),
+		)
+	}
+	return files, bytes, nil
+}
+
+// filter returns the objects keep reports true for.
+func filter(objects []object, keep func(object) bool) []object {
+	var found []object
+	for _, obj := range objects {
+		if keep(obj) {
+			found = append(found, obj)
+		}
+	}
+	return found
+}
+
+// removeObjects deletes objects, deleteConcurrency at a time, and returns those
+// deleted. It stops early when ctx is done.
+func (s *Storage) removeObjects(ctx context.Context, objects []object) []object {
+	var mu sync.Mutex
+	var removed []object
+	var wg sync.WaitGroup
+	next := make(chan object)
+	for range min(deleteConcurrency, len(objects)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for obj := range next {
+				if err := s.client.remove(ctx, obj.Name); err != nil {
+					if ctx.Err() != nil {
+						continue
+					}
+					s.logger.Warn("Failed to delete stored object", zap.String("object", obj.Name), zap.Error(err))
+					continue
+				}
+				mu.Lock()
+				removed = append(removed, obj)
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, obj := range objects {
+		if ctx.Err() != nil {
+			break
+		}
+		next <- obj
+	}
+	close(next)
+	wg.Wait()
+	return removed
+}
@@ -0,0 +1,228 @@
+package gcs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// DefaultEndpoint is the Cloud Storage JSON API.
+const DefaultEndpoint = "https://storage.googleapis.com"
+
+// client is the subset of Cloud Storage the backend needs, for one bucket, on
+// top of Google's client library.
+type client struct {
+	bucket string
+	handle *storage.BucketHandle
+	// signer is the service account URLs are signed as; nil when the
+	// credentials have no key to sign with.
+	signer *serviceAccount
+}
+
+// serviceAccount is the identity and key of a service account credentials file.
+type serviceAccount struct {
+	email string
+	key   []byte // PEM
+}
+
+// credentialsFile holds the fields of a credentials file the backend reads
+// itself: its type, and a service account's identity and key.
+type credentialsFile struct {
+	Type        string `json:"type"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+}
+
+// newClient connects to the bucket. STORAGE_EMULATOR_HOST, as set for the
+// Cloud Storage emulators, overrides the endpoint and disables authentication.
+// Otherwise the credentials are those of the file given, else the file named by
+// GOOGLE_APPLICATION_CREDENTIALS, else Google's application default credentials:
+// gcloud's, or the service account of the Google Cloud instance the server runs
+// on. It also returns where the credentials came from, "" for an emulator.
+func newClient(ctx context.Context, cfg Config) (*client, string, error) {
+	c := &client{bucket: cfg.Bucket}
+	opts := []option.ClientOption{storage.WithJSONReads()}
+	source := ""
+	if os.Getenv("STORAGE_EMULATOR_HOST") == "" {
+		source = "application default credentials"
+		opts = append(opts, option.WithScopes(storage.ScopeReadWrite))
+		if cfg.Endpoint != "" {
+			opts = append(opts, option.WithEndpoint(strings.TrimSuffix(cfg.Endpoint, "/")+"/storage/v1/"))
+		}
+		path := cfg.CredentialsFile
+		if path == "" {
+			path = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+		}
+		if path != "" {
+			fileOpt, signer, err := credentialsOption(path)
+			if err != nil {
+				return nil, "", err
+			}
+			opts = append(opts, fileOpt)
+			c.signer = signer
+			source = path
+		}
+	}
+
+	sc, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, "", err
+	}
+	c.handle = sc.Bucket(cfg.Bucket)
+	return c, source, nil
+}
+
+// credentialsOption returns the client option for the credentials file at path,
+// and the service account to sign URLs as when the file holds one.
+func credentialsOption(path string) (option.ClientOption, *serviceAccount, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+	var creds credentialsFile
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, nil, fmt.Errorf("invalid credentials file %s: %w", path, err)
+	}
+	switch creds.Type {
+	case "service_account":
+		var signer *serviceAccount
+		if creds.ClientEmail != "" && creds.PrivateKey != "" {
+			signer = &serviceAccount{email: creds.ClientEmail, key: []byte(creds.PrivateKey)}
+		}
+		return option.WithAuthCredentialsJSON(option.ServiceAccount, data), signer, nil
+	case "authorized_user":
+		return option.WithAuthCredentialsJSON(option.AuthorizedUser, data), nil, nil
+	default:
+		return nil, nil, fmt.Errorf("credentials file %s: unsupported type %q (supported: service_account, authorized_user)", path, creds.Type)
+	}
+}
+
+// object is the metadata of a stored object.
+type object struct {
+	Name        string     `json:"name"`
+	Size        int64      `json:"size,string"`
+	ContentType string     `json:"contentType,omitempty"`
+	Updated     time.Time  `json:"updated"`
+	CustomTime  *time.Time `json:"customTime,omitempty"`
+}
+
+// objectAttrs are the attributes listings fetch, those object holds.
+var objectAttrs = []string{"Name", "Size", "ContentType", "Updated", "CustomTime"}
+
+func newObject(attrs *storage.ObjectAttrs) object {
+	obj := object{Name: attrs.Name, Size: attrs.Size, ContentType: attrs.ContentType, Updated: attrs.Updated}
+	if !attrs.CustomTime.IsZero() {
+		customTime := attrs.CustomTime
+		obj.CustomTime = &customTime
+	}
+	return obj
+}
+
+// modTime is when the object's content was first stored: its custom time,
+// which copies between backends keep, else when it was written.
+func (o object) modTime() time.Time {
+	if o.CustomTime != nil {
+		return *o.CustomTime
+	}
+	return o.Updated
+}
+
+// isNotFound reports whether err says the object doesn't exist.
+func isNotFound(err error) bool {
+	return errors.Is(err, storage.ErrObjectNotExist)
+}
+
+// upload writes obj's metadata and data as one object, replacing any object of
+// the same name. The object is written in a single request, so it appears
+// whole or not at all.
+func (c *client) upload(ctx context.Context, obj object, data []byte) error {
+	w := c.handle.Object(obj.Name).NewWriter(ctx)
+	w.ChunkSize = 0
+	w.ContentType = obj.ContentType
+	if w.ContentType == "" {
+		w.ContentType = "application/octet-stream"
+	}
+	if obj.CustomTime != nil {
+		w.CustomTime = *obj.CustomTime
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Close() //nolint:errcheck // the write error is what matters
+		return fmt.Errorf("upload %s: %w", obj.Name, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("upload %s: %w", obj.Name, err)
+	}
+	return nil
+}
+
+// download returns a reader for the object's data.
+func (c *client) download(ctx context.Context, name string) (io.ReadCloser, error) {
+	r, err := c.handle.Object(name).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("download %s: %w", name, err)
+	}
+	return r, nil
+}
+
+// remove deletes the object; one already gone is not an error.
+func (c *client) remove(ctx context.Context, name string) error {
+	err := c.handle.Object(name).Delete(ctx)
+	if err != nil && !isNotFound(err) {
+		return fmt.Errorf("delete %s: %w", name, err)
+	}
+	return nil
+}
+
+// listQuery narrows a listing.
+type listQuery struct {
+	prefix string
+	// delimiter, when set, lists the objects directly under prefix and the
+	// "directories" below it as prefixes.
+	delimiter string
+	// glob matches object names, e.g. "audio/**/job.*".
+	glob string
+}
+
+// list returns the objects and, with a delimiter, the prefixes matching q.
+func (c *client) list(ctx context.Context, q listQuery) ([]object, []string, error) {
+	query := &storage.Query{Prefix: q.prefix, Delimiter: q.delimiter, MatchGlob: q.glob}
+	if err := query.SetAttrSelection(objectAttrs); err != nil {
+		return nil, nil, err
+	}
+
+	var objects []object
+	var prefixes []string
+	it := c.handle.Objects(ctx, query)
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			return objects, prefixes, nil
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("list %s: %w", q.prefix, err)
+		}
+		if attrs.Prefix != "" {
+			prefixes = append(prefixes, attrs.Prefix)
+			continue
+		}
+		objects = append(objects, newObject(attrs))
+	}
+}
+
+// move copies the object from to to within the bucket, keeping its metadata,
+// and deletes from.
+func (c *client) move(ctx context.Context, from, to string) error {
+	if _, err := c.handle.Object(to).CopierFrom(c.handle.Object(from)).Run(ctx); err != nil {
+		return fmt.Errorf("move %s: %w", from, err)
+	}
+	return c.remove(ctx, from)
+}
@@ -0,0 +1,58 @@
+package gcs
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeCredentials writes a credentials file and returns its path.
+func writeCredentials(t *testing.T, creds map[string]string) string {
+	t.Helper()
+	data, _ := json.Marshal(creds)
+	file := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// newTestKey returns a PEM-encoded RSA key, as service account files hold.
+func newTestKey(t *testing.T) []byte {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestCredentialsOption(t *testing.T) {
+	key := newTestKey(t)
+	serviceAccount := writeCredentials(t, map[string]string{
+		"type":         "service_account",
+		"client_email": "pako@project.iam.gserviceaccount.com",
+		"private_key":  string(key),
+	})
+	if _, signer, err := credentialsOption(serviceAccount); err != nil || signer == nil ||
+		signer.email != "pako@project.iam.gserviceaccount.com" || string(signer.key) != string(key) {
+		t.Errorf("expected a service account able to sign, got %+v, %v", signer, err)
+	}
+
+	user := writeCredentials(t, map[string]string{"type": "authorized_user", "refresh_token": "refresh"})
+	if _, signer, err := credentialsOption(user); err != nil || signer != nil {
+		t.Errorf("expected user credentials unable to sign, got %+v, %v", signer, err)
+	}
+
+	external := writeCredentials(t, map[string]string{"type": "external_account"})
+	if _, _, err := credentialsOption(external); err == nil || !strings.Contains(err.Error(), "external_account") {
+		t.Errorf("expected an unsupported credentials type rejected, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package gcs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"time"
+
+	"github.com/pako-tts/server/internal/audio/transcode"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/storage/keys"
+)
+
+// ListObjects implements domain.ObjectStore. Keys are base names, without the
+// prefix and directory, so objects keep their keys across backends and layouts.
+// Their modification time is the custom time PutObject keeps, else when they
+// were written.
+func (s *Storage) ListObjects(ctx context.Context) ([]domain.ObjectInfo, error) {
+	objects, _, err := s.client.list(ctx, listQuery{prefix: s.prefix})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storage bucket: %w", err)
+	}
+	infos := make([]domain.ObjectInfo, 0, len(objects))
+	for _, obj := range objects {
+		infos = append(infos, domain.ObjectInfo{Key: path.Base(obj.Name), Size: obj.Size, ModTime: obj.modTime()})
+	}
+	return infos, nil
+}
+
+// OpenObject implements domain.ObjectStore.
+func (s *Storage) OpenObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	key = path.Base(key)
+	jobID, _ := keys.SplitName(key)
+	loc, _ := s.find(ctx, jobID)
+
+	body, err := s.client.download(ctx, s.name(loc.dir, key))
+	if err != nil {
+		return nil, fmt.Errorf("object %s not found: %w", key, err)
+	}
+	return body, nil
+}
+
+// PutObject implements domain.ObjectStore. The object goes into its job's
+// directory, or where the key strategy keeps a job new to this store, as stored
+// at its modification time, which is kept as the object's custom time; the
+// job's audio takes its artifacts along when the strategy places it elsewhere.
+// Uploads are atomic, so an interrupted copy never leaves a truncated result
+// behind.
+func (s *Storage) PutObject(ctx context.Context, info domain.ObjectInfo, r io.Reader) error {
+	key := path.Base(info.Key)
+	jobID, rest := keys.SplitName(key)
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read object %s: %w", info.Key, err)
+	}
+
+	// Only the index is consulted: a copy fills an empty bucket, and what it put
+	// earlier is indexed
+	s.mu.Lock()
+	loc, known := s.index[jobID]
+	s.mu.Unlock()
+	ref := domain.KeyRef{JobID: jobID, Tenant: domain.TenantFromContext(ctx), StoredAt: info.ModTime}
+	if ref.StoredAt.IsZero() {
+		ref.StoredAt = time.Now()
+	}
+	contentType := mime.TypeByExtension(path.Ext(key))
+	switch {
+	case transcode.IsOutputFormat(rest):
+		ref.ContentHash = keys.ContentHash(data)
+		if dir := s.dir(ref); !known || dir != loc.dir {
+			if known {
+				if err := s.move(ctx, jobID, loc.dir, dir); err != nil {
+					return fmt.Errorf("failed to create object %s: %w", info.Key, err)
+				}
+			}
+			loc.dir = dir
+		}
+		loc.format = rest
+		contentType = transcode.ContentType(rest)
+	case !known:
+		loc = location{dir: s.dir(ref)}
+	}
+
+	obj := object{Name: s.name(loc.dir, key), ContentType: contentType}
+	if !info.ModTime.IsZero() {
+		modTime := info.ModTime.UTC()
+		obj.CustomTime = &modTime
+	}
+	if err := s.client.upload(ctx, obj, data); err != nil {
+		return fmt.Errorf("failed to write object %s: %w", info.Key, err)
+	}
+	s.remember(jobID, loc)
+	return nil
+}
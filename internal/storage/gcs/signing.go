@@ -0,0 +1,55 @@
+package gcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"cloud.google.com/go/storage"
+
+	"github.com/pako-tts/server/internal/audio/transcode"
+)
+
+// CanSignURLs implements domain.SignedURLs. URLs are signed with the key of a
+// service account credentials file; other credentials can't sign them.
+func (s *Storage) CanSignURLs() bool {
+	return s.client.signer != nil
+}
+
+// SignedURL implements domain.SignedURLs with a V4 signed URL of the job's
+// audio, answered with its content type and disposition.
+func (s *Storage) SignedURL(ctx context.Context, jobID string, ttl time.Duration, disposition string) (string, error) {
+	if s.client.signer == nil {
+		return "", errors.New("gcs: signing URLs needs a service account key")
+	}
+	loc, ok := s.find(ctx, jobID)
+	if !ok || loc.format == "" {
+		return "", fmt.Errorf("audio file not found for job %s", jobID)
+	}
+
+	params := url.Values{"response-content-type": {transcode.ContentType(loc.format)}}
+	if disposition != "" {
+		params.Set("response-content-disposition", disposition)
+	}
+	return s.client.signedURL(s.name(loc.dir, jobID+"."+loc.format), ttl, params)
+}
+
+// signedURL returns a V4 signed URL to GET the object name with until ttl has
+// passed. params, e.g. response-content-disposition, are signed along.
+func (c *client) signedURL(name string, ttl time.Duration, params url.Values) (string, error) {
+	signed, err := c.handle.SignedURL(name, &storage.SignedURLOptions{
+		GoogleAccessID:  c.signer.email,
+		PrivateKey:      c.signer.key,
+		Method:          http.MethodGet,
+		Expires:         time.Now().Add(ttl),
+		Scheme:          storage.SigningSchemeV4,
+		QueryParameters: params,
+	})
+	if err != nil {
+		return "", fmt.Errorf("gcs: sign URL: %w", err)
+	}
+	return signed, nil
+}
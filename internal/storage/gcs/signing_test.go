@@ -0,0 +1,51 @@
+package gcs
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pako-tts/server/internal/storage/keys"
+)
+
+func TestStorage_SignedURL(t *testing.T) {
+	_, srv := newFakeGCS(t)
+	s := newTestStorage(t, srv, keys.Flat)
+	ctx := context.Background()
+	if _, err := s.Store(ctx, "job-1", []byte("audio"), "wav"); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+
+	// The emulator's unauthenticated requests have no key to sign with
+	if s.CanSignURLs() {
+		t.Fatal("expected storage without a service account key unable to sign")
+	}
+	if _, err := s.SignedURL(ctx, "job-1", time.Minute, ""); err == nil {
+		t.Error("expected signing without a key to fail")
+	}
+
+	s.client.signer = &serviceAccount{email: "pako@project.iam.gserviceaccount.com", key: newTestKey(t)}
+	signed, err := s.SignedURL(ctx, "job-1", time.Minute, "inline")
+	if err != nil {
+		t.Fatalf("SignedURL: %v", err)
+	}
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatalf("parse %s: %v", signed, err)
+	}
+	query := u.Query()
+	// The expiry counts from when the URL is signed
+	expires, _ := strconv.Atoi(query.Get("X-Goog-Expires"))
+	if u.Path != "/results/pako/job-1.wav" || query.Get("X-Goog-Algorithm") != "GOOG4-RSA-SHA256" ||
+		!strings.HasPrefix(query.Get("X-Goog-Credential"), "pako@project.iam.gserviceaccount.com/") ||
+		query.Get("X-Goog-Signature") == "" || expires < 59 || expires > 60 ||
+		query.Get("response-content-type") != "audio/wav" || query.Get("response-content-disposition") != "inline" {
+		t.Errorf("unexpected signed URL %s", signed)
+	}
+	if _, err := s.SignedURL(ctx, "job-2", time.Minute, ""); err == nil {
+		t.Error("expected a job without audio to have no URL")
+	}
+}
@@ -0,0 +1,436 @@
+// Package gcs provides an audio storage implementation on Google Cloud Storage.
+package gcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/audio/transcode"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/storage/keys"
+)
+
+// topsInterval bounds how often the bucket's top-level directories are listed
+// again for a job missing from the index, to find those another instance
+// created.
+const topsInterval = 10 * time.Second
+
+// Config selects the bucket and how to reach it.
+type Config struct {
+	Bucket string
+	// Prefix is prepended to every object name, e.g. "pako/results".
+	Prefix string
+	// CredentialsFile is a service account key or application default
+	// credentials file; when empty, credentials are discovered (see newClient).
+	CredentialsFile string
+	// Endpoint overrides DefaultEndpoint. STORAGE_EMULATOR_HOST, as set for the
+	// Cloud Storage emulators, overrides both and disables authentication.
+	Endpoint string
+}
+
+// Storage is a Google Cloud Storage implementation of domain.AudioStorage, with
+// the semantics of the filesystem backend: a job's audio and artifacts are kept
+// together as <prefix>/<dir>/<jobID>.<format> and <jobID>.<name>, in the
+// directory its key strategy picks, and an index of job locations answers
+// Retrieve and Exists without listing the bucket. Jobs stored before a restart
+// or by another instance sharing the bucket are found by listing the
+// directories the key strategy locates them in, or by searching the bucket
+// when it can't.
+type Storage struct {
+	client *client
+	prefix string // "" or ending in "/"
+	keys   domain.KeyStrategy
+	logger *zap.Logger
+
+	mu         sync.Mutex
+	index      map[string]location
+	tops       map[string]bool // top-level directories in the bucket
+	topsListed time.Time
+}
+
+// location is where a job's objects are kept.
+type location struct {
+	// dir is the directory below the prefix.
+	dir string
+	// format is the format of the job's audio; "" while only artifacts are stored.
+	format string
+}
+
+// NewStorage creates a storage keeping each job's objects where strategy says,
+// and checks that the bucket can be listed.
+func NewStorage(ctx context.Context, cfg Config, strategy domain.KeyStrategy, logger *zap.Logger) (*Storage, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("gcs: bucket is required")
+	}
+	c, source, err := newClient(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("gcs: %w", err)
+	}
+	if source == "" {
+		logger.Info("Using Cloud Storage emulator", zap.String("endpoint", os.Getenv("STORAGE_EMULATOR_HOST")))
+	} else {
+		logger.Info("Using Cloud Storage credentials", zap.String("source", source))
+	}
+
+	prefix := strings.Trim(cfg.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	s := &Storage{
+		client: c,
+		prefix: prefix,
+		keys:   strategy,
+		logger: logger,
+		index:  make(map[string]location),
+		tops:   make(map[string]bool),
+	}
+	if err := s.loadTops(ctx); err != nil {
+		return nil, fmt.Errorf("gcs: bucket %s: %w", cfg.Bucket, err)
+	}
+	return s, nil
+}
+
+// URL returns the gs:// URL of the storage's root.
+func (s *Storage) URL() string {
+	return "gs://" + s.client.bucket + "/" + s.prefix
+}
+
+// name returns the object name of file in dir.
+func (s *Storage) name(dir, file string) string {
+	return s.prefix + path.Join(dir, file)
+}
+
+// Store saves audio data and returns the object's gs:// URL. The job's tenant is
+// taken from ctx (see domain.WithTenant). Artifacts stored earlier in another
+// directory are moved along with the audio.
+func (s *Storage) Store(ctx context.Context, jobID string, audio []byte, format string) (string, error) {
+	dir := s.dir(domain.KeyRef{
+		JobID:       jobID,
+		Tenant:      domain.TenantFromContext(ctx),
+		StoredAt:    time.Now(),
+		ContentHash: keys.ContentHash(audio),
+	})
+	s.mu.Lock()
+	loc, known := s.index[jobID]
+	s.mu.Unlock()
+	if known && loc.dir != dir {
+		if err := s.move(ctx, jobID, loc.dir, dir); err != nil {
+			return "", fmt.Errorf("failed to move artifacts: %w", err)
+		}
+	}
+
+	name := s.name(dir, jobID+"."+format)
+	obj := object{Name: name, ContentType: transcode.ContentType(format)}
+	if err := s.client.upload(ctx, obj, audio); err != nil {
+		return "", fmt.Errorf("failed to write audio file: %w", err)
+	}
+	s.remember(jobID, location{dir: dir, format: format})
+
+	s.logger.Debug("Audio stored",
+		zap.String("job_id", jobID),
+		zap.String("object", name),
+		zap.Int("size", len(audio)),
+	)
+
+	return s.url(name), nil
+}
+
+func (s *Storage) url(name string) string {
+	return "gs://" + s.client.bucket + "/" + name
+}
+
+// Retrieve returns a reader for the stored audio file.
+func (s *Storage) Retrieve(ctx context.Context, jobID string) (io.ReadCloser, string, error) {
+	loc, ok := s.find(ctx, jobID)
+	if ok && loc.format != "" {
+		body, err := s.client.download(ctx, s.name(loc.dir, jobID+"."+loc.format))
+		if err == nil {
+			return body, transcode.ContentType(loc.format), nil
+		}
+		if !isNotFound(err) {
+			return nil, "", err
+		}
+		// Removed behind the index's back, e.g. by another instance's cleanup
+		s.forget(jobID)
+	}
+
+	return nil, "", fmt.Errorf("audio file not found for job %s", jobID)
+}
+
+// Delete removes the stored audio file and any artifacts derived from it.
+func (s *Storage) Delete(ctx context.Context, jobID string) error {
+	loc, ok := s.find(ctx, jobID)
+	if !ok {
+		return nil
+	}
+	objects, _, err := s.client.list(ctx, listQuery{prefix: s.name(loc.dir, jobID+".")})
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		if err := s.client.remove(ctx, obj.Name); err != nil {
+			return err
+		}
+	}
+	s.forget(jobID)
+	return nil
+}
+
+// StoreArtifact saves a derived file next to the job's audio as <jobID>.<name>.
+// Like Store, it takes the job's tenant from ctx.
+func (s *Storage) StoreArtifact(ctx context.Context, jobID, name string, data []byte) error {
+	loc, ok := s.find(ctx, jobID)
+	if !ok {
+		loc = location{dir: s.dir(domain.KeyRef{
+			JobID:    jobID,
+			Tenant:   domain.TenantFromContext(ctx),
+			StoredAt: time.Now(),
+		})}
+		s.remember(jobID, loc)
+	}
+
+	obj := object{Name: s.artifactName(loc, jobID, name), ContentType: mime.TypeByExtension(path.Ext(name))}
+	if err := s.client.upload(ctx, obj, data); err != nil {
+		return fmt.Errorf("failed to write artifact: %w", err)
+	}
+
+	s.logger.Debug("Artifact stored",
+		zap.String("job_id", jobID),
+		zap.String("object", obj.Name),
+		zap.Int("size", len(data)),
+	)
+
+	return nil
+}
+
+// RetrieveArtifact returns a reader for a stored artifact.
+func (s *Storage) RetrieveArtifact(ctx context.Context, jobID, name string) (io.ReadCloser, error) {
+	loc, ok := s.find(ctx, jobID)
+	if !ok {
+		return nil, fmt.Errorf("artifact %s not found for job %s", name, jobID)
+	}
+
+	body, err := s.client.download(ctx, s.artifactName(loc, jobID, name))
+	if isNotFound(err) {
+		return nil, fmt.Errorf("artifact %s not found for job %s", name, jobID)
+	}
+	return body, err
+}
+
+func (s *Storage) artifactName(loc location, jobID, name string) string {
+	return s.name(loc.dir, jobID+"."+path.Base(name))
+}
+
+// Exists checks if audio exists for the given job.
+func (s *Storage) Exists(ctx context.Context, jobID string) bool {
+	loc, ok := s.find(ctx, jobID)
+	return ok && loc.format != ""
+}
+
+// GetPath returns the gs:// URL of a job's audio.
+func (s *Storage) GetPath(ctx context.Context, jobID string) string {
+	loc, ok := s.find(ctx, jobID)
+	if !ok || loc.format == "" {
+		return ""
+	}
+	return s.url(s.name(loc.dir, jobID+"."+loc.format))
+}
+
+// dir returns the directory for the objects of the job ref describes, and
+// records its top-level directory.
+func (s *Storage) dir(ref domain.KeyRef) string {
+	dir := s.keys.Dir(ref)
+	if top, _, _ := strings.Cut(dir, "/"); top != "" {
+		s.mu.Lock()
+		s.tops[top] = true
+		s.mu.Unlock()
+	}
+	return dir
+}
+
+func (s *Storage) remember(jobID string, loc location) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.index[jobID] = loc
+}
+
+// forget drops jobID from the index.
+func (s *Storage) forget(jobID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.index, jobID)
+}
+
+// loadTops records the top-level directories in the bucket.
+func (s *Storage) loadTops(ctx context.Context) error {
+	_, prefixes, err := s.client.list(ctx, listQuery{prefix: s.prefix, delimiter: "/"})
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range prefixes {
+		s.tops[strings.TrimSuffix(strings.TrimPrefix(p, s.prefix), "/")] = true
+	}
+	s.topsListed = time.Now()
+	return nil
+}
+
+// sortedTops returns the known top-level directories.
+func (s *Storage) sortedTops() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tops := make([]string, 0, len(s.tops))
+	for top := range s.tops {
+		tops = append(tops, top)
+	}
+	sort.Strings(tops)
+	return tops
+}
+
+// find returns where jobID's objects are kept; loc.format is empty when the job
+// has no audio. Jobs missing from the index are looked for in the directories
+// the key strategy locates them in, listing the bucket's top-level directories
+// again at most once per topsInterval, or searched for when it can't, and
+// added to the index.
+func (s *Storage) find(ctx context.Context, jobID string) (location, bool) {
+	s.mu.Lock()
+	loc, ok := s.index[jobID]
+	s.mu.Unlock()
+	if ok {
+		if loc.format == "" {
+			if loc.format, _ = s.probe(ctx, loc.dir, jobID); loc.format != "" {
+				s.remember(jobID, loc)
+			}
+		}
+		return loc, true
+	}
+
+	dirs, ok := s.keys.Locate(jobID, s.sortedTops())
+	if !ok {
+		return s.search(ctx, jobID)
+	}
+	if loc, ok := s.probeDirs(ctx, jobID, dirs); ok {
+		return loc, true
+	}
+
+	s.mu.Lock()
+	stale := time.Since(s.topsListed) >= topsInterval
+	s.mu.Unlock()
+	if !stale {
+		return location{}, false
+	}
+	if err := s.loadTops(ctx); err != nil {
+		s.logger.Warn("Failed to list storage bucket", zap.Error(err))
+		return location{}, false
+	}
+	retry, _ := s.keys.Locate(jobID, s.sortedTops())
+	var fresh []string
+	for _, dir := range retry {
+		seen := false
+		for _, d := range dirs {
+			seen = seen || d == dir
+		}
+		if !seen {
+			fresh = append(fresh, dir)
+		}
+	}
+	return s.probeDirs(ctx, jobID, fresh)
+}
+
+// probeDirs returns the first of dirs holding jobID's audio, or else its
+// artifacts, and indexes it.
+func (s *Storage) probeDirs(ctx context.Context, jobID string, dirs []string) (location, bool) {
+	var artifacts *location
+	for _, dir := range dirs {
+		format, found := s.probe(ctx, dir, jobID)
+		if format != "" {
+			loc := location{dir: dir, format: format}
+			s.remember(jobID, loc)
+			return loc, true
+		}
+		if found && artifacts == nil {
+			artifacts = &location{dir: dir}
+		}
+	}
+	if artifacts != nil {
+		s.remember(jobID, *artifacts)
+		return *artifacts, true
+	}
+	return location{}, false
+}
+
+// probe returns the format of jobID's audio in dir, or "" when there is none,
+// and whether any of its objects are there.
+func (s *Storage) probe(ctx context.Context, dir, jobID string) (string, bool) {
+	objects, _, err := s.client.list(ctx, listQuery{prefix: s.name(dir, jobID+".")})
+	if err != nil {
+		s.logger.Warn("Failed to list storage bucket", zap.String("dir", dir), zap.Error(err))
+		return "", false
+	}
+	for _, obj := range objects {
+		if _, rest := keys.SplitName(obj.Name); transcode.IsOutputFormat(rest) {
+			return rest, true
+		}
+	}
+	return "", len(objects) > 0
+}
+
+// search looks for jobID's audio, or else its artifacts, anywhere below the
+// prefix, for key strategies that can't locate jobs from their ID.
+func (s *Storage) search(ctx context.Context, jobID string) (location, bool) {
+	objects, _, err := s.client.list(ctx, listQuery{prefix: s.prefix, glob: s.prefix + "**/" + jobID + ".*"})
+	if err != nil {
+		s.logger.Warn("Failed to search storage bucket", zap.String("job_id", jobID), zap.Error(err))
+		return location{}, false
+	}
+	var found *location
+	for _, obj := range objects {
+		id, rest := keys.SplitName(obj.Name)
+		if id != jobID {
+			continue
+		}
+		loc := location{dir: path.Dir(strings.TrimPrefix(obj.Name, s.prefix))}
+		if loc.dir == "." {
+			loc.dir = ""
+		}
+		if transcode.IsOutputFormat(rest) {
+			loc.format = rest
+			found = &loc
+			break
+		}
+		if found == nil {
+			found = &loc
+		}
+	}
+	if found == nil {
+		return location{}, false
+	}
+	s.remember(jobID, *found)
+	return *found, true
+}
+
+// move moves jobID's audio and artifacts from the directory from to to.
+func (s *Storage) move(ctx context.Context, jobID, from, to string) error {
+	objects, _, err := s.client.list(ctx, listQuery{prefix: s.name(from, jobID+".")})
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		if err := s.client.move(ctx, obj.Name, s.name(to, path.Base(obj.Name))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,474 @@
+package gcs
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/storage/keys"
+)
+
+// fakeGCS serves the part of the JSON API the backend uses, for one bucket.
+type fakeGCS struct {
+	t      *testing.T
+	bucket string
+	// pageSize is small so listings take several pages.
+	pageSize int
+	// token, when set, is the bearer token every request must carry.
+	token string
+
+	mu      sync.Mutex
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	meta object
+	data []byte
+}
+
+func newFakeGCS(t *testing.T) (*fakeGCS, *httptest.Server) {
+	t.Helper()
+	f := &fakeGCS{t: t, bucket: "results", pageSize: 2, objects: make(map[string]fakeObject)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+// put stores an object directly, last written at updated.
+func (f *fakeGCS) put(name string, data string, updated time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[name] = fakeObject{meta: object{Name: name, Size: int64(len(data)), Updated: updated}, data: []byte(data)}
+}
+
+func (f *fakeGCS) names() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var names []string
+	for name := range f.objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.token != "" && r.Header.Get("Authorization") != "Bearer "+f.token {
+		http.Error(w, `{"error":{"message":"unauthorized"}}`, http.StatusUnauthorized)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p := r.URL.EscapedPath()
+	bucketPath := "/storage/v1/b/" + f.bucket + "/o"
+	switch {
+	case r.Method == http.MethodPost && p == "/upload/storage/v1/b/"+f.bucket+"/o":
+		f.upload(w, r)
+	case r.Method == http.MethodGet && p == bucketPath:
+		f.list(w, r)
+	case strings.HasPrefix(p, bucketPath+"/"):
+		rest := strings.TrimPrefix(p, bucketPath+"/")
+		src, dst, rewrite := strings.Cut(rest, "/rewriteTo/b/"+f.bucket+"/o/")
+		name, _ := url.PathUnescape(src)
+		obj, ok := f.objects[name]
+		if !ok {
+			http.Error(w, `{"error":{"message":"No such object"}}`, http.StatusNotFound)
+			return
+		}
+		switch {
+		case rewrite && r.Method == http.MethodPost:
+			dstName, _ := url.PathUnescape(dst)
+			obj.meta.Name = dstName
+			f.objects[dstName] = obj
+			json.NewEncoder(w).Encode(map[string]any{"done": true}) //nolint:errcheck
+		case r.Method == http.MethodGet && r.URL.Query().Get("alt") == "media":
+			w.Header().Set("Content-Type", obj.meta.ContentType)
+			w.Write(obj.data) //nolint:errcheck
+		case r.Method == http.MethodDelete:
+			delete(f.objects, name)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	default:
+		f.t.Errorf("unexpected request %s %s", r.Method, p)
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+func (f *fakeGCS) upload(w http.ResponseWriter, r *http.Request) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/related" || r.URL.Query().Get("uploadType") != "multipart" {
+		http.Error(w, "expected a multipart upload", http.StatusBadRequest)
+		return
+	}
+	parts := multipart.NewReader(r.Body, params["boundary"])
+	metaPart, _ := parts.NextPart()
+	var meta object
+	if err := json.NewDecoder(metaPart).Decode(&meta); err != nil {
+		http.Error(w, "bad metadata", http.StatusBadRequest)
+		return
+	}
+	dataPart, _ := parts.NextPart()
+	data, _ := io.ReadAll(dataPart)
+	meta.Size = int64(len(data))
+	meta.Updated = time.Now()
+	f.objects[meta.Name] = fakeObject{meta: meta, data: data}
+
+	// The client checks the checksum of what it uploaded
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli)))
+	json.NewEncoder(w).Encode(struct { //nolint:errcheck
+		object
+		CRC32C string `json:"crc32c"`
+	}{meta, base64.StdEncoding.EncodeToString(sum[:])})
+}
+
+func (f *fakeGCS) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	prefix, delimiter, glob := q.Get("prefix"), q.Get("delimiter"), q.Get("matchGlob")
+
+	var items []object
+	seen := make(map[string]bool)
+	var prefixes []string
+	for _, name := range sortedKeys(f.objects) {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if glob != "" {
+			// Only the "<dir>**/<base pattern>" globs the backend sends
+			dir, pattern, _ := strings.Cut(glob, "**/")
+			if ok, _ := path.Match(pattern, path.Base(name)); !ok || !strings.HasPrefix(name, dir) {
+				continue
+			}
+		}
+		if delimiter != "" {
+			if i := strings.Index(name[len(prefix):], delimiter); i >= 0 {
+				if p := name[:len(prefix)+i+1]; !seen[p] {
+					seen[p] = true
+					prefixes = append(prefixes, p)
+				}
+				continue
+			}
+		}
+		items = append(items, f.objects[name].meta)
+	}
+
+	start, _ := strconv.Atoi(q.Get("pageToken"))
+	end := min(start+f.pageSize, len(items))
+	page := map[string]any{"items": items[start:end]}
+	if start == 0 {
+		page["prefixes"] = prefixes
+	}
+	if end < len(items) {
+		page["nextPageToken"] = strconv.Itoa(end)
+	}
+	json.NewEncoder(w).Encode(page) //nolint:errcheck
+}
+
+func sortedKeys(objects map[string]fakeObject) []string {
+	names := make([]string, 0, len(objects))
+	for name := range objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func newTestStorage(t *testing.T, srv *httptest.Server, strategy domain.KeyStrategy) *Storage {
+	t.Helper()
+	t.Setenv("STORAGE_EMULATOR_HOST", srv.URL)
+	s, err := NewStorage(context.Background(), Config{Bucket: "results", Prefix: "/pako/"}, strategy, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	return s
+}
+
+func readAll(t *testing.T, r io.ReadCloser, err error) string {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	defer r.Close() //nolint:errcheck
+	data, _ := io.ReadAll(r)
+	return string(data)
+}
+
+func TestNewStorage_RequiresBucket(t *testing.T) {
+	if _, err := NewStorage(context.Background(), Config{}, keys.Sharded, zap.NewNop()); err == nil {
+		t.Error("expected an error without a bucket")
+	}
+}
+
+func TestStorage_StoreRetrieveDelete(t *testing.T) {
+	fake, srv := newFakeGCS(t)
+	s := newTestStorage(t, srv, keys.Sharded)
+	ctx := context.Background()
+	dir := keys.Sharded.Dir(domain.KeyRef{JobID: "job-1", StoredAt: time.Now()})
+
+	if err := s.StoreArtifact(ctx, "job-1", "preview.mp3", []byte("preview")); err != nil {
+		t.Fatalf("StoreArtifact: %v", err)
+	}
+	if s.Exists(ctx, "job-1") {
+		t.Error("expected no audio while only artifacts are stored")
+	}
+	url, err := s.Store(ctx, "job-1", []byte("audio"), "mp3")
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if want := "gs://results/pako/" + dir + "/job-1.mp3"; url != want || s.GetPath(ctx, "job-1") != want {
+		t.Errorf("expected %s, got %s and %s", want, url, s.GetPath(ctx, "job-1"))
+	}
+
+	body, contentType, err := s.Retrieve(ctx, "job-1")
+	if got := readAll(t, body, err); got != "audio" || contentType != "audio/mpeg" {
+		t.Errorf("expected the mp3 audio, got %q %s", got, contentType)
+	}
+	artifact, err := s.RetrieveArtifact(ctx, "job-1", "preview.mp3")
+	if got := readAll(t, artifact, err); got != "preview" {
+		t.Errorf("expected the preview, got %q", got)
+	}
+	if _, err := s.RetrieveArtifact(ctx, "job-1", "waveform.json"); err == nil {
+		t.Error("expected a missing artifact to fail")
+	}
+
+	if err := s.Delete(ctx, "job-1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if names := fake.names(); len(names) != 0 {
+		t.Errorf("expected the audio and artifacts deleted, got %v", names)
+	}
+	if s.Exists(ctx, "job-1") {
+		t.Error("expected the job gone")
+	}
+	if _, _, err := s.Retrieve(ctx, "job-1"); err == nil {
+		t.Error("expected retrieving a deleted job to fail")
+	}
+}
+
+func TestStorage_FindsResultsStoredByAnotherInstance(t *testing.T) {
+	for _, strategy := range []domain.KeyStrategy{keys.Sharded, keys.Tenant, keys.Content} {
+		t.Run(strategy.Name(), func(t *testing.T) {
+			_, srv := newFakeGCS(t)
+			ctx := domain.WithTenant(context.Background(), "acme")
+			other := newTestStorage(t, srv, strategy)
+			s := newTestStorage(t, srv, strategy)
+			s.topsListed = time.Time{}
+
+			if _, err := other.Store(ctx, "job-1", []byte("audio"), "wav"); err != nil {
+				t.Fatalf("Store: %v", err)
+			}
+			other.StoreArtifact(ctx, "job-1", "waveform.json", []byte("[]")) //nolint:errcheck
+
+			body, contentType, err := s.Retrieve(ctx, "job-1")
+			if got := readAll(t, body, err); got != "audio" || contentType != "audio/wav" {
+				t.Fatalf("expected the other instance's audio, got %q %s", got, contentType)
+			}
+			artifact, err := s.RetrieveArtifact(ctx, "job-1", "waveform.json")
+			if got := readAll(t, artifact, err); got != "[]" {
+				t.Errorf("expected the other instance's artifact, got %q", got)
+			}
+			if s.Exists(ctx, "job-2") {
+				t.Error("expected an unknown job not found")
+			}
+		})
+	}
+}
+
+func TestStorage_CleanupExpired(t *testing.T) {
+	fake, srv := newFakeGCS(t)
+	s := newTestStorage(t, srv, keys.Sharded)
+	ctx := context.Background()
+
+	now := time.Now()
+	cutoff := now.Add(-48 * time.Hour)
+	old := cutoff.Add(-72 * time.Hour)
+	put := func(jobID string, at time.Time) string {
+		name := "pako/" + keys.Sharded.Dir(domain.KeyRef{JobID: jobID, StoredAt: at}) + "/" + jobID + ".mp3"
+		fake.put(name, "audio", at)
+		return name
+	}
+	// A day that ended before the cutoff goes whole, whatever its objects' times
+	wholeDay := put("old", old)
+	fake.put(path.Dir(wholeDay)+"/old.preview.mp3", "preview", now)
+	put("expired", cutoff.Add(-time.Minute))
+	kept := put("kept", cutoff.Add(time.Minute))
+	recent := put("recent", now)
+	// Objects directly below the prefix are checked one by one
+	fake.put("pako/flat.mp3", "audio", old)
+	fake.put("other/old.mp3", "audio", old)
+	if err := s.loadTops(ctx); err != nil {
+		t.Fatalf("loadTops: %v", err)
+	}
+	if !s.Exists(ctx, "old") {
+		t.Fatal("expected the old job found before cleanup")
+	}
+
+	files, bytes, err := s.CleanupExpired(ctx, domain.RetentionPolicy{Result: 48 * time.Hour})
+	if err != nil {
+		t.Fatalf("CleanupExpired: %v", err)
+	}
+	if files != 4 || bytes != 22 {
+		t.Errorf("expected 4 objects and 22 bytes removed, got %d and %d", files, bytes)
+	}
+	names := fake.names()
+	want := []string{"other/old.mp3", kept, recent}
+	sort.Strings(want)
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Errorf("expected %v left, got %v", want, names)
+	}
+	if s.Exists(ctx, "old") {
+		t.Error("expected the removed job dropped from the index")
+	}
+}
+
+func TestStorage_DeleteResults(t *testing.T) {
+	fake, srv := newFakeGCS(t)
+	s := newTestStorage(t, srv, keys.Sharded)
+	ctx := context.Background()
+	s.Store(ctx, "job-1", []byte("audio"), "mp3")                   //nolint:errcheck
+	s.StoreArtifact(ctx, "job-1", "preview.mp3", []byte("preview")) //nolint:errcheck
+	s.Store(ctx, "job-2", []byte("kept"), "mp3")                    //nolint:errcheck
+
+	files, bytes := s.DeleteResults(ctx, []string{"job-1", "missing"}, domain.RetentionPolicy{Result: 24 * time.Hour})
+	if files != 2 || bytes != 12 {
+		t.Errorf("expected 2 objects and 12 bytes removed, got %d and %d", files, bytes)
+	}
+	if names := fake.names(); len(names) != 1 || path.Base(names[0]) != "job-2.mp3" {
+		t.Errorf("expected only job-2 left, got %v", names)
+	}
+}
+
+func TestStorage_ArtifactRetention(t *testing.T) {
+	fake, srv := newFakeGCS(t)
+	s := newTestStorage(t, srv, keys.Sharded)
+	ctx := context.Background()
+	policy := domain.RetentionPolicy{
+		Result: 24 * time.Hour,
+		Artifacts: map[string]time.Duration{
+			domain.ArtifactKindPreview:  30 * 24 * time.Hour,
+			domain.ArtifactKindWaveform: time.Hour,
+		},
+	}
+	now := time.Now()
+	dir := "pako/" + keys.Sharded.Dir(domain.KeyRef{JobID: "job-1", StoredAt: now}) + "/"
+	fake.put(dir+"job-1.mp3", "audio", now.Add(-2*time.Hour))
+	fake.put(dir+"job-1.preview.mp3", "preview", now.Add(-2*time.Hour))
+	fake.put(dir+"job-1.waveform.json", "[]", now.Add(-2*time.Hour))
+	if err := s.loadTops(ctx); err != nil {
+		t.Fatalf("loadTops: %v", err)
+	}
+
+	// The sweep removes the waveform once its shorter retention passed
+	if files, _, err := s.CleanupExpired(ctx, policy); err != nil || files != 1 {
+		t.Fatalf("expected only the waveform removed, got %d, %v", files, err)
+	}
+
+	// Removing the result at its expiry leaves the preview, which a restarted
+	// instance still finds
+	if files, _ := s.DeleteResults(ctx, []string{"job-1"}, policy); files != 1 {
+		t.Errorf("expected only the audio removed, got %d", files)
+	}
+	if names := fake.names(); len(names) != 1 || path.Base(names[0]) != "job-1.preview.mp3" {
+		t.Errorf("expected only the preview left, got %v", names)
+	}
+	restarted := newTestStorage(t, srv, keys.Sharded)
+	preview, err := restarted.RetrieveArtifact(ctx, "job-1", domain.ArtifactPreview)
+	if got := readAll(t, preview, err); got != "preview" {
+		t.Errorf("expected the preview kept, got %q", got)
+	}
+	if restarted.Exists(ctx, "job-1") {
+		t.Error("expected the audio to be gone")
+	}
+}
+
+func TestStorage_Objects(t *testing.T) {
+	_, srv := newFakeGCS(t)
+	s := newTestStorage(t, srv, keys.Sharded)
+	ctx := context.Background()
+	storedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// Artifacts may arrive before their audio, which takes them along
+	for _, key := range []string{"job-1.preview.mp3", "job-1.mp3"} {
+		info := domain.ObjectInfo{Key: key, ModTime: storedAt}
+		if err := s.PutObject(ctx, info, strings.NewReader("data of "+key)); err != nil {
+			t.Fatalf("PutObject %s: %v", key, err)
+		}
+	}
+
+	objects, err := s.ListObjects(ctx)
+	if err != nil {
+		t.Fatalf("ListObjects: %v", err)
+	}
+	if len(objects) != 2 {
+		t.Fatalf("expected 2 objects, got %+v", objects)
+	}
+	for _, obj := range objects {
+		if !obj.ModTime.Equal(storedAt) {
+			t.Errorf("expected %s to keep its modification time, got %s", obj.Key, obj.ModTime)
+		}
+	}
+	want := "gs://results/pako/" + keys.Sharded.Dir(domain.KeyRef{JobID: "job-1", StoredAt: storedAt}) + "/job-1.mp3"
+	if got := s.GetPath(ctx, "job-1"); got != want {
+		t.Errorf("expected the audio in the day it was stored, %s, got %s", want, got)
+	}
+	body, err := s.OpenObject(ctx, "job-1.preview.mp3")
+	if got := readAll(t, body, err); got != "data of job-1.preview.mp3" {
+		t.Errorf("expected the artifact, got %q", got)
+	}
+}
+
+func TestStorage_Credentials(t *testing.T) {
+	fake, srv := newFakeGCS(t)
+	fake.token = "service-token"
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm() //nolint:errcheck
+		if r.Form.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || r.Form.Get("assertion") == "" {
+			http.Error(w, "bad grant", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "service-token", "token_type": "Bearer", "expires_in": 3600}) //nolint:errcheck
+	}))
+	defer tokenServer.Close()
+	file := writeCredentials(t, map[string]string{
+		"type":         "service_account",
+		"client_email": "pako@project.iam.gserviceaccount.com",
+		"private_key":  string(newTestKey(t)),
+		"token_uri":    tokenServer.URL,
+	})
+
+	t.Setenv("STORAGE_EMULATOR_HOST", "")
+	s, err := NewStorage(context.Background(), Config{Bucket: "results", CredentialsFile: file, Endpoint: srv.URL}, keys.Flat, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	if _, err := s.Store(context.Background(), "job-1", []byte("audio"), "mp3"); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if names := fake.names(); len(names) != 1 || names[0] != "job-1.mp3" {
+		t.Errorf("expected the flat layout without a prefix, got %v", names)
+	}
+	if !s.CanSignURLs() {
+		t.Error("expected a service account key able to sign URLs")
+	}
+}
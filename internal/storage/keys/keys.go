@@ -0,0 +1,159 @@
+// Package keys provides the strategies storage backends use to decide where a
+// job's objects are kept (see domain.KeyStrategy).
+package keys
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// dayLayout names the top-level directories of the sharded layout, one per UTC
+// day.
+const dayLayout = "2006-01-02"
+
+var (
+	// Flat keeps every object at the top of the backend. Simple, but a large
+	// store ends up with one huge directory.
+	Flat domain.KeyStrategy = flat{}
+	// Sharded keeps a job's objects in the directory of the UTC day it was
+	// stored, then two hex digits hashed from the job ID. Cleanup can then drop
+	// whole days, and no directory holds more than about 1/256 of a day.
+	Sharded domain.KeyStrategy = sharded{}
+	// Tenant keeps each tenant's objects under a directory of its own, sharded
+	// by job ID like Sharded, so a tenant's results can be exported or removed
+	// together.
+	Tenant domain.KeyStrategy = tenant{}
+	// Content keeps a job's objects in a directory named after the SHA-256 of
+	// its audio, so identical results land together and a path vouches for its
+	// content. Artifacts stored ahead of their audio wait under jobs/, sharded by
+	// job ID. Jobs can't be found from their ID alone, so backends search for
+	// them.
+	Content domain.KeyStrategy = content{}
+)
+
+// Default is the name of the strategy backends use unless configured otherwise.
+const Default = "sharded"
+
+var strategies = []domain.KeyStrategy{Flat, Sharded, Tenant, Content}
+
+// Names returns the names of the available strategies.
+func Names() []string {
+	names := make([]string, len(strategies))
+	for i, s := range strategies {
+		names[i] = s.Name()
+	}
+	return names
+}
+
+// Parse returns the strategy called name; an empty name is Default.
+func Parse(name string) (domain.KeyStrategy, error) {
+	if name == "" {
+		name = Default
+	}
+	for _, s := range strategies {
+		if s.Name() == name {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key strategy %q (supported: %s)", name, strings.Join(Names(), ", "))
+}
+
+// hashDir returns the two hex digits a job's objects are sharded by.
+func hashDir(jobID string) string {
+	h := fnv.New32a()
+	h.Write([]byte(jobID)) //nolint:errcheck // never fails
+	return fmt.Sprintf("%02x", h.Sum32()&0xff)
+}
+
+type flat struct{}
+
+func (flat) Name() string                             { return "flat" }
+func (flat) Dir(ref domain.KeyRef) string             { return "" }
+func (flat) Locate(string, []string) ([]string, bool) { return []string{""}, true }
+
+type sharded struct{}
+
+func (sharded) Name() string { return "sharded" }
+
+func (sharded) Dir(ref domain.KeyRef) string {
+	return path.Join(ref.StoredAt.UTC().Format(dayLayout), hashDir(ref.JobID))
+}
+
+// Locate looks in the job's shard of each day, newest first, starting with
+// today.
+func (s sharded) Locate(jobID string, tops []string) ([]string, bool) {
+	today := time.Now().UTC().Format(dayLayout)
+	days := []string{today}
+	for _, top := range tops {
+		if _, ok := s.Day(top); ok && top != today {
+			days = append(days, top)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(days)))
+
+	dirs := make([]string, len(days))
+	for i, day := range days {
+		dirs[i] = path.Join(day, hashDir(jobID))
+	}
+	return dirs, true
+}
+
+// Day implements domain.DatedKeys.
+func (sharded) Day(top string) (time.Time, bool) {
+	day, err := time.Parse(dayLayout, top)
+	return day, err == nil
+}
+
+type tenant struct{}
+
+func (tenant) Name() string { return "tenant" }
+
+func (tenant) Dir(ref domain.KeyRef) string {
+	return path.Join(tenantDir(ref.Tenant), hashDir(ref.JobID))
+}
+
+// Locate looks in the job's shard of each tenant.
+func (tenant) Locate(jobID string, tops []string) ([]string, bool) {
+	dirs := make([]string, len(tops))
+	for i, top := range tops {
+		dirs[i] = path.Join(top, hashDir(jobID))
+	}
+	return dirs, true
+}
+
+// tenantDir returns the directory of a tenant's objects: its name, with any
+// character that isn't safe in a path replaced by "_".
+func tenantDir(name string) string {
+	if name == "" {
+		name = domain.DefaultTenant
+	}
+	dir := []byte(name)
+	for i, c := range dir {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '-':
+		case c == '.' && i > 0:
+		default:
+			dir[i] = '_'
+		}
+	}
+	return string(dir)
+}
+
+type content struct{}
+
+func (content) Name() string { return "content" }
+
+func (content) Dir(ref domain.KeyRef) string {
+	if len(ref.ContentHash) < 2 {
+		return path.Join("jobs", hashDir(ref.JobID))
+	}
+	return path.Join(ref.ContentHash[:2], ref.ContentHash)
+}
+
+func (content) Locate(string, []string) ([]string, bool) { return nil, false }
@@ -0,0 +1,82 @@
+package keys
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+func TestParse(t *testing.T) {
+	for _, name := range Names() {
+		strategy, err := Parse(name)
+		if err != nil || strategy.Name() != name {
+			t.Errorf("Parse(%q) = %v, %v", name, strategy, err)
+		}
+	}
+	if _, err := Parse("nested"); err == nil {
+		t.Error("expected an unknown strategy rejected")
+	}
+	if _, err := Parse(Default); err != nil {
+		t.Errorf("expected the default strategy available: %v", err)
+	}
+}
+
+func TestStrategies_Dir(t *testing.T) {
+	stored := time.Date(2026, 3, 14, 23, 30, 0, 0, time.FixedZone("CET", 3600))
+	ref := domain.KeyRef{JobID: "job-1", Tenant: "acme", StoredAt: stored, ContentHash: "abcdef"}
+	shard := hashDir("job-1")
+
+	tests := []struct {
+		strategy domain.KeyStrategy
+		ref      domain.KeyRef
+		want     string
+	}{
+		{Flat, ref, ""},
+		{Sharded, ref, "2026-03-14/" + shard},
+		{Tenant, ref, "acme/" + shard},
+		{Tenant, domain.KeyRef{JobID: "job-1", Tenant: "../etc"}, "_._etc/" + shard},
+		{Tenant, domain.KeyRef{JobID: "job-1"}, domain.DefaultTenant + "/" + shard},
+		{Content, ref, "ab/abcdef"},
+		{Content, domain.KeyRef{JobID: "job-1"}, "jobs/" + shard},
+	}
+	for _, tt := range tests {
+		if got := tt.strategy.Dir(tt.ref); got != tt.want {
+			t.Errorf("%s.Dir(%+v) = %q, want %q", tt.strategy.Name(), tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestStrategies_Locate(t *testing.T) {
+	shard := hashDir("job-1")
+	today := time.Now().UTC().Format(dayLayout)
+
+	dirs, ok := Sharded.Locate("job-1", []string{"2026-01-01", "acme", "2026-02-01"})
+	want := []string{today + "/" + shard, "2026-02-01/" + shard, "2026-01-01/" + shard}
+	if !ok || !slices.Equal(dirs, want) {
+		t.Errorf("sharded: expected days newest first %v, got %v", want, dirs)
+	}
+
+	dirs, ok = Tenant.Locate("job-1", []string{"acme", "default"})
+	if !ok || !slices.Equal(dirs, []string{"acme/" + shard, "default/" + shard}) {
+		t.Errorf("tenant: expected every tenant's shard, got %v", dirs)
+	}
+
+	if _, ok := Content.Locate("job-1", []string{"ab"}); ok {
+		t.Error("content: expected jobs not to be located from their ID")
+	}
+}
+
+func TestSplitName(t *testing.T) {
+	tests := []struct{ name, jobID, rest string }{
+		{"job-1.mp3", "job-1", "mp3"},
+		{"pako/2026-03-14/ab/job-1.preview.mp3", "job-1", "preview.mp3"},
+		{"job-1", "job-1", ""},
+	}
+	for _, tt := range tests {
+		if jobID, rest := SplitName(tt.name); jobID != tt.jobID || rest != tt.rest {
+			t.Errorf("SplitName(%q) = %q, %q", tt.name, jobID, rest)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package keys
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path"
+	"strings"
+)
+
+// ContentHash returns the hex SHA-256 of data, as KeyRef.ContentHash holds it.
+func ContentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// SplitName splits the base name of a stored object into its job ID and the
+// rest: the audio format, or the artifact name.
+func SplitName(name string) (jobID, rest string) {
+	jobID, rest, _ = strings.Cut(path.Base(name), ".")
+	return jobID, rest
+}
@@ -0,0 +1,234 @@
+// Package migrate copies retained job results (audio and artifacts) from one storage
+// backend to another. Progress is recorded as it goes so an interrupted run can be
+// resumed, and each copy can be verified against the source checksum.
+package migrate
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// Options controls a migration run.
+type Options struct {
+	// ProgressPath is a file recording each copied object, so a rerun skips them;
+	// empty copies everything every time.
+	ProgressPath string
+	// Verify re-reads every copied object from the destination and compares its
+	// SHA-256 with the source. With Verify, objects recorded as copied are checked
+	// again and recopied if they differ.
+	Verify bool
+	// DryRun reports what would be copied without writing anything.
+	DryRun bool
+	// OnObject, when set, is called after each object with its outcome.
+	OnObject func(obj domain.ObjectInfo, outcome Outcome, err error)
+}
+
+// Outcome is what happened to one object.
+type Outcome string
+
+// Object outcomes. A dry run reports the objects it would copy as copied.
+const (
+	OutcomeCopied  Outcome = "copied"
+	OutcomeSkipped Outcome = "skipped"
+	OutcomeFailed  Outcome = "failed"
+)
+
+// ErrChecksumMismatch means an object read back from the destination differs
+// from the source.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// Report summarises a migration run.
+type Report struct {
+	Total   int
+	Copied  int
+	Skipped int
+	Bytes   int64
+	// Failures lists the objects that could not be copied or verified.
+	Failures []Failure
+}
+
+// Failure is an object that failed to migrate.
+type Failure struct {
+	Key string
+	Err error
+}
+
+// progressEntry is one line of the progress file.
+type progressEntry struct {
+	Key    string `json:"key"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Run copies every object of src to dst. Failing objects are reported and the
+// rest still copied; the returned error is for failures that stop the run, such
+// as an unreadable source listing or progress file.
+func Run(ctx context.Context, src, dst domain.ObjectStore, opts Options) (*Report, error) {
+	objects, err := src.ListObjects(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list source objects: %w", err)
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+
+	done, err := loadProgress(opts.ProgressPath)
+	if err != nil {
+		return nil, err
+	}
+	var progress *os.File
+	if opts.ProgressPath != "" && !opts.DryRun {
+		progress, err = openProgress(opts.ProgressPath)
+		if err != nil {
+			return nil, err
+		}
+		defer progress.Close() //nolint:errcheck
+	}
+
+	report := &Report{Total: len(objects)}
+	for _, obj := range objects {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		outcome, entry, err := migrateObject(ctx, src, dst, obj, done[obj.Key], opts)
+		switch outcome {
+		case OutcomeCopied:
+			report.Copied++
+			report.Bytes += obj.Size
+			if progress != nil {
+				if err := json.NewEncoder(progress).Encode(entry); err != nil {
+					return report, fmt.Errorf("record progress: %w", err)
+				}
+			}
+		case OutcomeSkipped:
+			report.Skipped++
+		case OutcomeFailed:
+			report.Failures = append(report.Failures, Failure{Key: obj.Key, Err: err})
+		}
+		if opts.OnObject != nil {
+			opts.OnObject(obj, outcome, err)
+		}
+	}
+	return report, nil
+}
+
+// migrateObject copies one object unless the progress file says it already was
+// (and, with Verify, the destination still matches).
+func migrateObject(
+	ctx context.Context,
+	src, dst domain.ObjectStore,
+	obj domain.ObjectInfo,
+	prev *progressEntry,
+	opts Options,
+) (Outcome, progressEntry, error) {
+	entry := progressEntry{Key: obj.Key, Size: obj.Size}
+	if prev != nil && prev.Size == obj.Size {
+		if !opts.Verify {
+			return OutcomeSkipped, entry, nil
+		}
+		if sum, err := checksum(ctx, dst, obj.Key); err == nil && sum == prev.SHA256 {
+			return OutcomeSkipped, entry, nil
+		}
+	}
+	if opts.DryRun {
+		return OutcomeCopied, entry, nil
+	}
+
+	r, err := src.OpenObject(ctx, obj.Key)
+	if err != nil {
+		return OutcomeFailed, entry, err
+	}
+	defer r.Close() //nolint:errcheck
+
+	hash := sha256.New()
+	if err := dst.PutObject(ctx, obj, io.TeeReader(r, hash)); err != nil {
+		return OutcomeFailed, entry, err
+	}
+	entry.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	if opts.Verify {
+		sum, err := checksum(ctx, dst, obj.Key)
+		if err != nil {
+			return OutcomeFailed, entry, fmt.Errorf("verify: %w", err)
+		}
+		if sum != entry.SHA256 {
+			return OutcomeFailed, entry, fmt.Errorf("verify: %w", ErrChecksumMismatch)
+		}
+	}
+	return OutcomeCopied, entry, nil
+}
+
+// checksum returns the hex SHA-256 of an object.
+func checksum(ctx context.Context, store domain.ObjectStore, key string) (string, error) {
+	r, err := store.OpenObject(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close() //nolint:errcheck
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// loadProgress reads the objects recorded by earlier runs. A missing file means
+// nothing was copied yet; a torn last line from an interrupted run is ignored.
+func loadProgress(path string) (map[string]*progressEntry, error) {
+	done := make(map[string]*progressEntry)
+	if path == "" {
+		return done, nil
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open progress file: %w", err)
+	}
+	defer file.Close() //nolint:errcheck
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry progressEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.Key == "" {
+			continue
+		}
+		done[entry.Key] = &entry
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read progress file: %w", err)
+	}
+	return done, nil
+}
+
+// openProgress opens the progress file for appending, first ending a torn last
+// line so the next entry starts on a line of its own.
+func openProgress(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open progress file: %w", err)
+	}
+	info, err := file.Stat()
+	if err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err = file.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			_, err = file.Write([]byte("\n"))
+		}
+	}
+	if err != nil {
+		file.Close() //nolint:errcheck
+		return nil, fmt.Errorf("open progress file: %w", err)
+	}
+	return file, nil
+}
@@ -0,0 +1,127 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/storage/filesystem"
+)
+
+func newStore(t *testing.T) *filesystem.Storage {
+	t.Helper()
+	s, err := filesystem.NewStorage(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestRun_CopiesAndResumes(t *testing.T) {
+	ctx := context.Background()
+	src, dst := newStore(t), newStore(t)
+	if _, err := src.Store(ctx, "job1", []byte("audio one"), "mp3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.StoreArtifact(ctx, "job1", domain.ArtifactPreview, []byte("preview")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.Store(ctx, "job2", []byte("audio two"), "wav"); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(src.GetPath(ctx, "job2"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	progress := filepath.Join(t.TempDir(), "progress")
+	report, err := Run(ctx, src, dst, Options{ProgressPath: progress, Verify: true})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if report.Total != 3 || report.Copied != 3 || len(report.Failures) != 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	r, _, err := dst.Retrieve(ctx, "job2")
+	if err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close() //nolint:errcheck
+	if string(data) != "audio two" {
+		t.Errorf("unexpected copied audio %q", data)
+	}
+	if info, err := os.Stat(dst.GetPath(ctx, "job2")); err != nil || !info.ModTime().Equal(old) {
+		t.Errorf("expected the modification time to be kept, got %v", info.ModTime())
+	}
+	if r, err := dst.RetrieveArtifact(ctx, "job1", domain.ArtifactPreview); err != nil {
+		t.Error("expected the preview artifact to be copied")
+	} else {
+		r.Close() //nolint:errcheck
+	}
+
+	// A rerun skips what is done; a destination copy that went bad is copied again
+	if err := os.WriteFile(dst.GetPath(ctx, "job1"), []byte("corrupt!!"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	report, err = Run(ctx, src, dst, Options{ProgressPath: progress, Verify: true})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if report.Copied != 1 || report.Skipped != 2 {
+		t.Errorf("expected 1 recopied and 2 skipped objects, got %+v", report)
+	}
+}
+
+func TestRun_DryRunWritesNothing(t *testing.T) {
+	ctx := context.Background()
+	src, dst := newStore(t), newStore(t)
+	if _, err := src.Store(ctx, "job1", []byte("audio"), "mp3"); err != nil {
+		t.Fatal(err)
+	}
+	progress := filepath.Join(t.TempDir(), "progress")
+
+	report, err := Run(ctx, src, dst, Options{ProgressPath: progress, DryRun: true})
+	if err != nil || report.Copied != 1 {
+		t.Fatalf("unexpected dry run %+v, %v", report, err)
+	}
+	if dst.Exists(ctx, "job1") {
+		t.Error("dry run copied an object")
+	}
+	if _, err := os.Stat(progress); !errors.Is(err, os.ErrNotExist) {
+		t.Error("dry run wrote a progress file")
+	}
+}
+
+func TestLoadProgress_IgnoresTornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "progress")
+	content := `{"key":"a.mp3","size":1,"sha256":"x"}` + "\n" + `{"key":"b.mp`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	done, err := loadProgress(path)
+	if err != nil || len(done) != 1 || done["a.mp3"] == nil {
+		t.Fatalf("unexpected progress %v, %v", done, err)
+	}
+
+	file, err := openProgress(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"key":"c.mp3","size":1,"sha256":"y"}` + "\n") //nolint:errcheck
+	file.Close()                                                     //nolint:errcheck
+
+	data, _ := os.ReadFile(path)
+	if !strings.HasSuffix(string(data), "\n"+`{"key":"c.mp3","size":1,"sha256":"y"}`+"\n") {
+		t.Errorf("expected the new entry on its own line, got %q", data)
+	}
+}
@@ -0,0 +1,115 @@
+package textinfo
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Character classes Rules.Scripts accepts besides the writing scripts.
+const (
+	ClassDigits      = "digits"
+	ClassPunctuation = "punctuation"
+)
+
+// Rule names reported in a Violation.
+const (
+	RuleDeniedChars = "denied_chars"
+	RuleScripts     = "allowed_scripts"
+	RuleMaxRepeat   = "max_repeat"
+)
+
+// Rules restrict the characters of a text, e.g. to keep a tenant to the
+// languages it serves and to reject gibberish that wastes provider quota. The
+// zero value allows any text.
+type Rules struct {
+	// Scripts lists what the text may be written in: the writing scripts Analyze
+	// reports, "digits" and "punctuation". Whitespace and combining marks are
+	// always allowed. Empty allows every character.
+	Scripts []string
+	// AllowedChars are allowed besides the characters of Scripts.
+	AllowedChars string
+	// DeniedChars are rejected even when Scripts allow them.
+	DeniedChars string
+	// MaxRepeat is the longest run of one character the text may have, whitespace
+	// aside; 0 means no limit.
+	MaxRepeat int
+}
+
+// Violation is the first place a text breaks its Rules.
+type Violation struct {
+	Rule string
+	Char rune
+	// Offset is the position of Char in the text, in characters.
+	Offset int
+	// Limit is the longest run allowed, for RuleMaxRepeat.
+	Limit int
+}
+
+// Message describes the violation for API clients.
+func (v *Violation) Message() string {
+	switch v.Rule {
+	case RuleDeniedChars:
+		return fmt.Sprintf("Character %q is not allowed", v.Char)
+	case RuleScripts:
+		return fmt.Sprintf("Character %q is outside the allowed scripts", v.Char)
+	default:
+		return fmt.Sprintf("Character %q is repeated more than %d times in a row", v.Char, v.Limit)
+	}
+}
+
+// classTables maps the names Rules.Scripts accepts to their character tables.
+var classTables = func() map[string][]*unicode.RangeTable {
+	tables := map[string][]*unicode.RangeTable{
+		ClassDigits:      {unicode.Nd},
+		ClassPunctuation: {unicode.P},
+	}
+	for _, s := range scriptTables {
+		tables[s.name] = append(tables[s.name], s.table)
+	}
+	return tables
+}()
+
+// Validate reports names in Scripts that are neither a known script nor class.
+func (r Rules) Validate() error {
+	for _, name := range r.Scripts {
+		if _, ok := classTables[name]; !ok {
+			return fmt.Errorf("unknown script %q", name)
+		}
+	}
+	if r.MaxRepeat < 0 {
+		return fmt.Errorf("max_repeat must not be negative")
+	}
+	return nil
+}
+
+// Check returns the first violation of the rules in text, nil when it has none.
+func (r Rules) Check(text string) *Violation {
+	var tables []*unicode.RangeTable
+	for _, name := range r.Scripts {
+		tables = append(tables, classTables[name]...)
+	}
+
+	var prev rune
+	run, offset := 0, 0
+	for _, c := range text {
+		if strings.ContainsRune(r.DeniedChars, c) {
+			return &Violation{Rule: RuleDeniedChars, Char: c, Offset: offset}
+		}
+		if len(tables) > 0 && !unicode.IsSpace(c) && !unicode.IsMark(c) &&
+			!strings.ContainsRune(r.AllowedChars, c) && !unicode.IsOneOf(tables, c) {
+			return &Violation{Rule: RuleScripts, Char: c, Offset: offset}
+		}
+
+		if c == prev {
+			run++
+		} else {
+			prev, run = c, 1
+		}
+		if r.MaxRepeat > 0 && run > r.MaxRepeat && !unicode.IsSpace(c) {
+			return &Violation{Rule: RuleMaxRepeat, Char: c, Offset: offset - run + 1, Limit: r.MaxRepeat}
+		}
+		offset++
+	}
+	return nil
+}
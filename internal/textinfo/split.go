@@ -0,0 +1,87 @@
+package textinfo
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Split cuts text into chunks of at most maxLen characters for providers that
+// limit the text per request. Chunks end at sentence boundaries, found as
+// countSentences finds them, or at line breaks. A sentence longer than maxLen is
+// cut between words, and a word longer than maxLen anywhere. Text of at most
+// maxLen characters, or any text when maxLen is 0, is returned whole.
+func Split(text string, maxLen int) []string {
+	if maxLen <= 0 || utf8.RuneCountInString(text) <= maxLen {
+		return []string{text}
+	}
+
+	var chunks []string
+	var current strings.Builder
+	currentLen := 0
+	flush := func() {
+		if chunk := strings.TrimSpace(current.String()); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		current.Reset()
+		currentLen = 0
+	}
+	// add appends a piece of at most maxLen characters, starting a new chunk when
+	// it doesn't fit the current one
+	add := func(piece string) {
+		n := utf8.RuneCountInString(piece)
+		if currentLen+n > maxLen {
+			flush()
+		}
+		current.WriteString(piece)
+		currentLen += n
+	}
+
+	for _, sentence := range Sentences(text) {
+		if utf8.RuneCountInString(sentence) <= maxLen {
+			add(sentence)
+			continue
+		}
+		for _, word := range strings.SplitAfter(sentence, " ") {
+			for utf8.RuneCountInString(word) > maxLen {
+				cut := 0
+				for range maxLen {
+					_, size := utf8.DecodeRuneInString(word[cut:])
+					cut += size
+				}
+				add(word[:cut])
+				word = word[cut:]
+			}
+			add(word)
+		}
+	}
+	flush()
+	return chunks
+}
+
+// Sentences splits text after sentence punctuation followed by whitespace, after
+// full-width punctuation, and after line breaks, so "3.14" stays whole. The
+// pieces keep their whitespace: joined, they are text.
+func Sentences(text string) []string {
+	var sentences []string
+	start := 0
+	for i, r := range text {
+		end := i + utf8.RuneLen(r)
+		switch {
+		case r == '\n':
+		case strings.ContainsRune(".!?。！？", r):
+			next, _ := utf8.DecodeRuneInString(text[end:])
+			if end < len(text) && !unicode.IsSpace(next) && r <= unicode.MaxASCII {
+				continue
+			}
+		default:
+			continue
+		}
+		sentences = append(sentences, text[start:end])
+		start = end
+	}
+	if start < len(text) {
+		sentences = append(sentences, text[start:])
+	}
+	return sentences
+}
@@ -0,0 +1,185 @@
+// Package textinfo inspects request text for characteristics that affect synthesis:
+// writing script, markup and size.
+package textinfo
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Writing scripts reported by Analyze.
+const (
+	ScriptUnknown    = "unknown"
+	ScriptLatin      = "latin"
+	ScriptCyrillic   = "cyrillic"
+	ScriptGreek      = "greek"
+	ScriptArabic     = "arabic"
+	ScriptHebrew     = "hebrew"
+	ScriptDevanagari = "devanagari"
+	ScriptThai       = "thai"
+	ScriptHangul     = "hangul"
+	ScriptKana       = "kana"
+	ScriptHan        = "han"
+)
+
+var scriptTables = []struct {
+	name  string
+	table *unicode.RangeTable
+}{
+	{ScriptLatin, unicode.Latin},
+	{ScriptCyrillic, unicode.Cyrillic},
+	{ScriptGreek, unicode.Greek},
+	{ScriptArabic, unicode.Arabic},
+	{ScriptHebrew, unicode.Hebrew},
+	{ScriptDevanagari, unicode.Devanagari},
+	{ScriptThai, unicode.Thai},
+	{ScriptHangul, unicode.Hangul},
+	{ScriptKana, unicode.Hiragana},
+	{ScriptKana, unicode.Katakana},
+	{ScriptHan, unicode.Han},
+}
+
+// languageScripts maps ISO 639-1 codes to the script their text is written in.
+// Languages missing here are not checked.
+var languageScripts = map[string]string{
+	"en": ScriptLatin, "es": ScriptLatin, "fr": ScriptLatin, "de": ScriptLatin, "it": ScriptLatin,
+	"pt": ScriptLatin, "nl": ScriptLatin, "pl": ScriptLatin, "sv": ScriptLatin, "da": ScriptLatin,
+	"no": ScriptLatin, "fi": ScriptLatin, "cs": ScriptLatin, "sk": ScriptLatin, "ro": ScriptLatin,
+	"hu": ScriptLatin, "hr": ScriptLatin, "tr": ScriptLatin, "id": ScriptLatin, "ms": ScriptLatin,
+	"vi": ScriptLatin, "fil": ScriptLatin,
+	"ru": ScriptCyrillic, "uk": ScriptCyrillic, "bg": ScriptCyrillic,
+	"el": ScriptGreek,
+	"ar": ScriptArabic,
+	"he": ScriptHebrew,
+	"hi": ScriptDevanagari,
+	"th": ScriptThai,
+	"ko": ScriptHangul,
+	"ja": ScriptKana,
+	"zh": ScriptHan,
+}
+
+var (
+	htmlTag    = regexp.MustCompile(`(?i)</?(html|head|body|div|span|p|a|b|i|u|em|strong|br|hr|ul|ol|li|table|tr|td|th|h[1-6]|img|script|style)\b[^>]*>`)
+	htmlEntity = regexp.MustCompile(`&(nbsp|amp|lt|gt|quot|apos|#\d+|#x[0-9a-fA-F]+);`)
+	ssmlRoot   = regexp.MustCompile(`(?i)^\s*(<\?xml[^>]*>\s*)?<speak\b`)
+)
+
+// Info describes a text.
+type Info struct {
+	Chars int
+	// Script is the script most letters are written in, or ScriptUnknown.
+	Script string
+	HTML   bool
+	SSML   bool
+	// Sentences is the number of sentences, at least 1 for non-blank text.
+	Sentences int
+}
+
+// Analyze inspects text.
+func Analyze(text string) Info {
+	return Info{
+		Chars:  utf8.RuneCountInString(text),
+		Script: dominantScript(text),
+		HTML:   LooksLikeHTML(text),
+		SSML:   ssmlRoot.MatchString(text),
+
+		Sentences: countSentences(text),
+	}
+}
+
+// LooksLikeHTML reports whether text contains HTML tags or entities. SSML documents
+// are not HTML even though they share a few tag names.
+func LooksLikeHTML(text string) bool {
+	if ssmlRoot.MatchString(text) {
+		return false
+	}
+	return htmlTag.MatchString(text) || htmlEntity.MatchString(text)
+}
+
+// BaseLanguage returns the lower-cased ISO 639-1 part of languageCode when it is a
+// language this package knows the script of, or "".
+func BaseLanguage(languageCode string) string {
+	code, _, _ := strings.Cut(strings.ToLower(languageCode), "-")
+	if _, ok := languageScripts[code]; !ok {
+		return ""
+	}
+	return code
+}
+
+// LanguageScript returns the script text in the given ISO 639-1 language is written
+// in, or "" when unknown. Region suffixes ("en-US") are ignored.
+func LanguageScript(languageCode string) string {
+	code, _, _ := strings.Cut(strings.ToLower(languageCode), "-")
+	return languageScripts[code]
+}
+
+// ScriptMatchesLanguage reports whether script is plausible for languageCode.
+// Japanese text mixes kana with Han characters, so either is accepted for "ja".
+func ScriptMatchesLanguage(script, languageCode string) bool {
+	want := LanguageScript(languageCode)
+	if want == "" || script == ScriptUnknown {
+		return true
+	}
+	if want == ScriptKana && script == ScriptHan {
+		return true
+	}
+	return script == want
+}
+
+// countSentences counts runs of text ended by sentence punctuation followed by
+// whitespace or the end of the text, so "3.14" stays one sentence. Trailing text
+// without a terminator is a sentence too.
+func countSentences(text string) int {
+	runes := []rune(text)
+	n := 0
+	inSentence := false
+	for i, r := range runes {
+		switch {
+		case strings.ContainsRune(".!?。！？", r):
+			if inSentence && (i+1 == len(runes) || unicode.IsSpace(runes[i+1]) || r > unicode.MaxASCII) {
+				n++
+				inSentence = false
+			}
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			inSentence = true
+		}
+	}
+	if inSentence {
+		n++
+	}
+	return n
+}
+
+func dominantScript(text string) string {
+	counts := make(map[string]int)
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for _, s := range scriptTables {
+			if unicode.Is(s.table, r) {
+				counts[s.name]++
+				break
+			}
+		}
+	}
+
+	best, bestCount := ScriptUnknown, 0
+	for _, s := range scriptTables {
+		if n := counts[s.name]; n > bestCount {
+			best, bestCount = s.name, n
+		}
+	}
+	// Japanese: any kana alongside Han characters means the text is Japanese.
+	if best == ScriptHan && counts[ScriptKana] > 0 {
+		best = ScriptKana
+	}
+	if bestCount*2 < letters {
+		return ScriptUnknown // no majority script
+	}
+	return best
+}
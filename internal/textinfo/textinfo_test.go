@@ -0,0 +1,173 @@
+package textinfo
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestAnalyze_Script(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Hello, world!", ScriptLatin},
+		{"Привет, мир!", ScriptCyrillic},
+		{"こんにちは世界", ScriptKana},
+		{"你好世界", ScriptHan},
+		{"안녕하세요", ScriptHangul},
+		{"12345 !?", ScriptUnknown},
+	}
+	for _, tt := range tests {
+		if got := Analyze(tt.text).Script; got != tt.want {
+			t.Errorf("Analyze(%q).Script = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestLooksLikeHTML(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"Plain text with a < b comparison.", false},
+		{"<p>Hello</p>", true},
+		{"line one<br/>line two", true},
+		{"Fish&nbsp;and chips", true},
+		{`<speak>Hello <break time="1s"/> world</speak>`, false},
+	}
+	for _, tt := range tests {
+		if got := LooksLikeHTML(tt.text); got != tt.want {
+			t.Errorf("LooksLikeHTML(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestScriptMatchesLanguage(t *testing.T) {
+	tests := []struct {
+		script, lang string
+		want         bool
+	}{
+		{ScriptLatin, "en", true},
+		{ScriptCyrillic, "en-US", false},
+		{ScriptHan, "ja", true},
+		{ScriptLatin, "xx", true}, // unknown language isn't checked
+		{ScriptUnknown, "ru", true},
+	}
+	for _, tt := range tests {
+		if got := ScriptMatchesLanguage(tt.script, tt.lang); got != tt.want {
+			t.Errorf("ScriptMatchesLanguage(%q, %q) = %v, want %v", tt.script, tt.lang, got, tt.want)
+		}
+	}
+}
+
+func TestAnalyze_Sentences(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"Hello", 1},
+		{"Hello. World!", 2},
+		{"Pi is 3.14. Really?", 2},
+		{"Wait... what?! ok", 3},
+		{"今日は。明日も。", 2},
+	}
+	for _, tt := range tests {
+		if got := Analyze(tt.text).Sentences; got != tt.want {
+			t.Errorf("Analyze(%q).Sentences = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		text   string
+		maxLen int
+		want   []string
+	}{
+		{"Hello. World!", 0, []string{"Hello. World!"}},
+		{"Hello. World!", 20, []string{"Hello. World!"}},
+		{"Hello there. How are you? Fine.", 20, []string{"Hello there.", "How are you? Fine."}},
+		{"Pi is 3.14 today", 12, []string{"Pi is 3.14", "today"}},
+		{"One\nTwo three", 10, []string{"One", "Two three"}},
+		{"今日は。明日も。", 4, []string{"今日は。", "明日も。"}},
+		{"abcdefghij", 4, []string{"abcd", "efgh", "ij"}},
+	}
+	for _, tt := range tests {
+		got := Split(tt.text, tt.maxLen)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Split(%q, %d) = %q, want %q", tt.text, tt.maxLen, got, tt.want)
+		}
+	}
+}
+
+func TestSentences(t *testing.T) {
+	got := Sentences("Dr. Smith arrived at 3.5 p.m.!  Really?\nYes\n\n今日は。ok")
+	want := []string{"Dr.", " Smith arrived at 3.5 p.m.!", "  Really?", "\n", "Yes\n", "\n", "今日は。", "ok"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Sentences = %q, want %q", got, want)
+	}
+}
+
+func TestSplit_KeepsWithinLimit(t *testing.T) {
+	text := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 50) + strings.Repeat("x", 300)
+	chunks := Split(text, 100)
+	for _, chunk := range chunks {
+		if n := utf8.RuneCountInString(chunk); n > 100 || n == 0 {
+			t.Errorf("chunk of %d characters, want 1-100", n)
+		}
+	}
+	joined := strings.Join(chunks, "")
+	if want := strings.ReplaceAll(text, " ", ""); strings.ReplaceAll(joined, " ", "") != want {
+		t.Error("chunks don't add up to the text")
+	}
+}
+
+func TestRules_Check(t *testing.T) {
+	latinDigits := Rules{Scripts: []string{ScriptLatin, ClassDigits}, AllowedChars: ".,!?'"}
+	tests := []struct {
+		name   string
+		rules  Rules
+		text   string
+		rule   string
+		char   rune
+		offset int
+	}{
+		{"zero value allows anything", Rules{}, "Привет 🎉 aaaaaaaa", "", 0, 0},
+		{"allowed scripts", latinDigits, "Café 42, naïve!\n", "", 0, 0},
+		{"outside the scripts", latinDigits, "Hi Жанна", RuleScripts, 'Ж', 3},
+		{"punctuation not allowed", latinDigits, "Hi (there)", RuleScripts, '(', 3},
+		{"denied wins over scripts", Rules{Scripts: []string{ScriptLatin}, DeniedChars: "x"}, "box", RuleDeniedChars, 'x', 2},
+		{"repeat within the limit", Rules{MaxRepeat: 3}, "Hmmm...", "", 0, 0},
+		{"repeat over the limit", Rules{MaxRepeat: 3}, "no!!!!!", RuleMaxRepeat, '!', 2},
+		{"whitespace runs are not limited", Rules{MaxRepeat: 2}, "a\n\n\n\nb", "", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := tt.rules.Check(tt.text)
+			if tt.rule == "" {
+				if v != nil {
+					t.Fatalf("expected no violation, got %+v", v)
+				}
+				return
+			}
+			if v == nil || v.Rule != tt.rule || v.Char != tt.char || v.Offset != tt.offset {
+				t.Fatalf("expected %s at %d (%q), got %+v", tt.rule, tt.offset, tt.char, v)
+			}
+			if v.Message() == "" {
+				t.Error("expected a message")
+			}
+		})
+	}
+}
+
+func TestRules_Validate(t *testing.T) {
+	if err := (Rules{Scripts: []string{ScriptCyrillic, ClassPunctuation}}).Validate(); err != nil {
+		t.Errorf("expected valid rules, got %v", err)
+	}
+	if err := (Rules{Scripts: []string{"klingon"}}).Validate(); err == nil {
+		t.Error("expected an unknown script rejected")
+	}
+}
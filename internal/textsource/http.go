@@ -0,0 +1,182 @@
+package textsource
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pako-tts/server/internal/deadline"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/safehttp"
+)
+
+// URLFetcher downloads UTF-8 text over HTTP(S).
+type URLFetcher struct {
+	httpClient   *http.Client
+	maxBytes     int64
+	allowedHosts []string
+}
+
+// NewURLFetcher creates a fetcher reading at most maxBytes per document from the
+// given hosts (any host when allowedHosts is empty). It only connects to public
+// addresses, and follows redirects to allowed hosts only.
+func NewURLFetcher(maxBytes int64, timeout time.Duration, allowedHosts []string) *URLFetcher {
+	f := &URLFetcher{
+		maxBytes:     maxBytes,
+		allowedHosts: allowedHosts,
+	}
+	f.httpClient = safehttp.NewClient(timeout, f.hostAllowed)
+	return f
+}
+
+// Type implements domain.TextFetcher.
+func (*URLFetcher) Type() string { return domain.TextSourceURL }
+
+// Validate implements domain.TextFetcher.
+func (f *URLFetcher) Validate(src *domain.TextSource) error {
+	if src.URL == "" {
+		return domain.NewTextSourceError(domain.SourceErrInvalid, src.Type+" source requires url", nil)
+	}
+	u, err := url.Parse(src.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return domain.NewTextSourceError(domain.SourceErrInvalid, "url must be an absolute http or https URL", nil)
+	}
+	if !f.hostAllowed(u.Hostname()) {
+		return domain.NewTextSourceError(domain.SourceErrInvalid, "host "+u.Hostname()+" is not allowed", nil)
+	}
+	return nil
+}
+
+// Fetch implements domain.TextFetcher.
+func (f *URLFetcher) Fetch(ctx context.Context, src *domain.TextSource) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src.URL, nil)
+	if err != nil {
+		return "", domain.NewTextSourceError(domain.SourceErrInvalid, "invalid url", err)
+	}
+	req.Header.Set("Accept", "text/plain, text/markdown, text/*;q=0.9")
+	deadline.Set(req)
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return "", domain.NewTextSourceError(domain.SourceErrFetchFailed, "request failed", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return "", domain.NewTextSourceError(domain.SourceErrNotFound, fmt.Sprintf("%s returned status %d", src.URL, resp.StatusCode), nil)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return "", domain.NewTextSourceError(domain.SourceErrFetchFailed, fmt.Sprintf("%s returned status %d", src.URL, resp.StatusCode), nil)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.maxBytes+1))
+	if err != nil {
+		return "", domain.NewTextSourceError(domain.SourceErrFetchFailed, "failed to read response", err)
+	}
+	if int64(len(body)) > f.maxBytes {
+		return "", domain.NewTextSourceError(domain.SourceErrTooLarge, fmt.Sprintf("document exceeds %d bytes", f.maxBytes), nil)
+	}
+	if !utf8.Valid(body) {
+		return "", domain.NewTextSourceError(domain.SourceErrInvalid, "document is not UTF-8 text", nil)
+	}
+	return string(body), nil
+}
+
+func (f *URLFetcher) hostAllowed(host string) bool {
+	if len(f.allowedHosts) == 0 {
+		return true
+	}
+	host = strings.ToLower(host)
+	for _, allowed := range f.allowedHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || strings.HasPrefix(allowed, ".") && (strings.HasSuffix(host, allowed) || host == allowed[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// DocumentFetcher downloads a document and returns one of its chapters.
+type DocumentFetcher struct {
+	web *URLFetcher
+}
+
+// Type implements domain.TextFetcher.
+func (*DocumentFetcher) Type() string { return domain.TextSourceDocument }
+
+// Validate implements domain.TextFetcher.
+func (f *DocumentFetcher) Validate(src *domain.TextSource) error {
+	if err := f.web.Validate(src); err != nil {
+		return err
+	}
+	if src.Chapter < 1 {
+		return domain.NewTextSourceError(domain.SourceErrInvalid, "document source requires chapter (from 1)", nil)
+	}
+	return nil
+}
+
+// Fetch implements domain.TextFetcher.
+func (f *DocumentFetcher) Fetch(ctx context.Context, src *domain.TextSource) (string, error) {
+	doc, err := f.web.Fetch(ctx, src)
+	if err != nil {
+		return "", err
+	}
+	chapters := Chapters(doc)
+	if src.Chapter > len(chapters) {
+		return "", domain.NewTextSourceError(domain.SourceErrNotFound,
+			fmt.Sprintf("chapter %d requested but the document has %d", src.Chapter, len(chapters)), nil)
+	}
+	return chapters[src.Chapter-1], nil
+}
+
+// Chapters splits a Markdown or plain-text document at its headings ("# Title"
+// through "###### Title"). Each chapter starts with its heading text, without the
+// leading hashes; text before the first heading is a chapter of its own. A
+// document without headings is a single chapter.
+func Chapters(doc string) []string {
+	var chapters []string
+	var title string
+	var body strings.Builder
+	flush := func() {
+		text := strings.TrimSpace(body.String())
+		switch {
+		case title != "" && text != "":
+			chapters = append(chapters, title+"\n\n"+text)
+		case title != "":
+			chapters = append(chapters, title)
+		case text != "":
+			chapters = append(chapters, text)
+		}
+		title = ""
+		body.Reset()
+	}
+
+	for _, line := range strings.Split(doc, "\n") {
+		if t, ok := heading(line); ok {
+			flush()
+			title = t
+			continue
+		}
+		body.WriteString(line)
+		body.WriteString("\n")
+	}
+	flush()
+	return chapters
+}
+
+func heading(line string) (string, bool) {
+	trimmed := strings.TrimRight(line, "\r")
+	level := 0
+	for level < len(trimmed) && trimmed[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || level == len(trimmed) || trimmed[level] != ' ' {
+		return "", false
+	}
+	return strings.TrimSpace(trimmed[level:]), true
+}
@@ -0,0 +1,220 @@
+// Package textsource resolves where a job's text comes from: inline text, a URL, an
+// earlier job's text, a document chapter or a rendered template. Job creation
+// validates a source up front and the worker fetches it just before synthesis.
+package textsource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// Defaults for Options left zero.
+const (
+	DefaultMaxBytes     = 1 << 20
+	DefaultFetchTimeout = 30 * time.Second
+)
+
+// Options configures the built-in fetchers.
+type Options struct {
+	// MaxBytes caps the size of fetched or rendered text.
+	MaxBytes int64
+	// FetchTimeout bounds each HTTP fetch.
+	FetchTimeout time.Duration
+	// AllowedHosts restricts url and document sources to these hosts; an entry
+	// starting with "." also matches its subdomains. Empty allows any host.
+	AllowedHosts []string
+	// Jobs backs stored sources; nil disables them.
+	Jobs domain.JobQueue
+}
+
+// Resolver dispatches sources to the fetcher registered for their type.
+type Resolver struct {
+	fetchers map[string]domain.TextFetcher
+}
+
+// NewResolver creates a resolver for the given fetchers.
+func NewResolver(fetchers ...domain.TextFetcher) *Resolver {
+	r := &Resolver{fetchers: make(map[string]domain.TextFetcher)}
+	for _, f := range fetchers {
+		r.fetchers[f.Type()] = f
+	}
+	return r
+}
+
+// New creates a resolver with every built-in source type.
+func New(opts Options) *Resolver {
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultMaxBytes
+	}
+	if opts.FetchTimeout <= 0 {
+		opts.FetchTimeout = DefaultFetchTimeout
+	}
+	web := NewURLFetcher(opts.MaxBytes, opts.FetchTimeout, opts.AllowedHosts)
+	fetchers := []domain.TextFetcher{
+		InlineFetcher{},
+		web,
+		&DocumentFetcher{web: web},
+		&TemplateFetcher{maxBytes: opts.MaxBytes},
+	}
+	if opts.Jobs != nil {
+		fetchers = append(fetchers, &StoredFetcher{jobs: opts.Jobs})
+	}
+	return NewResolver(fetchers...)
+}
+
+// Validate checks src without fetching it.
+func (r *Resolver) Validate(src *domain.TextSource) error {
+	f, err := r.fetcher(src)
+	if err != nil {
+		return err
+	}
+	return f.Validate(src)
+}
+
+// Fetch returns the text of src. Errors are always *domain.TextSourceError.
+func (r *Resolver) Fetch(ctx context.Context, src *domain.TextSource) (string, error) {
+	f, err := r.fetcher(src)
+	if err != nil {
+		return "", err
+	}
+	if err := f.Validate(src); err != nil {
+		return "", err
+	}
+	text, err := f.Fetch(ctx, src)
+	if err != nil {
+		if _, ok := domain.AsTextSourceError(err); !ok {
+			err = domain.NewTextSourceError(domain.SourceErrFetchFailed, "failed to fetch "+src.Type+" source", err)
+		}
+		return "", err
+	}
+	if strings.TrimSpace(text) == "" {
+		return "", domain.NewTextSourceError(domain.SourceErrEmpty, src.Type+" source has no text", nil)
+	}
+	return text, nil
+}
+
+func (r *Resolver) fetcher(src *domain.TextSource) (domain.TextFetcher, error) {
+	if src == nil || src.Type == "" {
+		return nil, domain.NewTextSourceError(domain.SourceErrInvalid, "source type is required", nil)
+	}
+	f, ok := r.fetchers[src.Type]
+	if !ok {
+		return nil, domain.NewTextSourceError(domain.SourceErrUnsupported,
+			fmt.Sprintf("source type %q is not supported", src.Type), nil)
+	}
+	return f, nil
+}
+
+// InlineFetcher returns text given in the request.
+type InlineFetcher struct{}
+
+// Type implements domain.TextFetcher.
+func (InlineFetcher) Type() string { return domain.TextSourceInline }
+
+// Validate implements domain.TextFetcher.
+func (InlineFetcher) Validate(src *domain.TextSource) error {
+	if src.Text == "" {
+		return domain.NewTextSourceError(domain.SourceErrInvalid, "inline source requires text", nil)
+	}
+	return nil
+}
+
+// Fetch implements domain.TextFetcher.
+func (InlineFetcher) Fetch(_ context.Context, src *domain.TextSource) (string, error) {
+	return src.Text, nil
+}
+
+// StoredFetcher reuses the text of an earlier job.
+type StoredFetcher struct {
+	jobs domain.JobQueue
+}
+
+// Type implements domain.TextFetcher.
+func (*StoredFetcher) Type() string { return domain.TextSourceStored }
+
+// Validate implements domain.TextFetcher.
+func (*StoredFetcher) Validate(src *domain.TextSource) error {
+	if src.JobID == "" {
+		return domain.NewTextSourceError(domain.SourceErrInvalid, "stored source requires job_id", nil)
+	}
+	return nil
+}
+
+// Fetch implements domain.TextFetcher.
+func (f *StoredFetcher) Fetch(ctx context.Context, src *domain.TextSource) (string, error) {
+	job, err := f.jobs.GetJob(ctx, src.JobID)
+	if err != nil {
+		if errors.Is(err, domain.ErrJobNotFound) {
+			return "", domain.NewTextSourceError(domain.SourceErrNotFound, "job "+src.JobID+" not found", nil)
+		}
+		return "", err
+	}
+	if job.Text == "" {
+		return "", domain.NewTextSourceError(domain.SourceErrNotFound, "job "+src.JobID+" has no stored text", nil)
+	}
+	return job.Text, nil
+}
+
+// TemplateFetcher renders a Go text/template with the source's variables. A
+// variable the template uses but the request doesn't set is an error.
+type TemplateFetcher struct {
+	maxBytes int64
+}
+
+// Type implements domain.TextFetcher.
+func (*TemplateFetcher) Type() string { return domain.TextSourceTemplate }
+
+// Validate implements domain.TextFetcher.
+func (*TemplateFetcher) Validate(src *domain.TextSource) error {
+	_, err := parseTemplate(src)
+	return err
+}
+
+// Fetch implements domain.TextFetcher.
+func (f *TemplateFetcher) Fetch(_ context.Context, src *domain.TextSource) (string, error) {
+	tmpl, err := parseTemplate(src)
+	if err != nil {
+		return "", err
+	}
+	out := &limitedBuilder{max: f.maxBytes}
+	if err := tmpl.Execute(out, src.Vars); err != nil {
+		if errors.Is(err, errTooLarge) {
+			return "", domain.NewTextSourceError(domain.SourceErrTooLarge,
+				fmt.Sprintf("rendered template exceeds %d bytes", f.maxBytes), nil)
+		}
+		return "", domain.NewTextSourceError(domain.SourceErrRender, "failed to render template", err)
+	}
+	return out.String(), nil
+}
+
+func parseTemplate(src *domain.TextSource) (*template.Template, error) {
+	if src.Template == "" {
+		return nil, domain.NewTextSourceError(domain.SourceErrInvalid, "template source requires template", nil)
+	}
+	tmpl, err := template.New("source").Option("missingkey=error").Parse(src.Template)
+	if err != nil {
+		return nil, domain.NewTextSourceError(domain.SourceErrInvalid, "invalid template", err)
+	}
+	return tmpl, nil
+}
+
+var errTooLarge = errors.New("output too large")
+
+// limitedBuilder is a strings.Builder that refuses to grow past max bytes.
+type limitedBuilder struct {
+	strings.Builder
+	max int64
+}
+
+func (b *limitedBuilder) Write(p []byte) (int, error) {
+	if int64(b.Len()+len(p)) > b.max {
+		return 0, errTooLarge
+	}
+	return b.Builder.Write(p)
+}
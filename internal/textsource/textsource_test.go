@@ -0,0 +1,151 @@
+package textsource
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/queue/memory"
+)
+
+func sourceCode(t *testing.T, err error) string {
+	t.Helper()
+	if err == nil {
+		return ""
+	}
+	serr, ok := domain.AsTextSourceError(err)
+	if !ok {
+		t.Fatalf("expected *domain.TextSourceError, got %T: %v", err, err)
+	}
+	return serr.Code
+}
+
+func TestResolver_Validate(t *testing.T) {
+	r := New(Options{AllowedHosts: []string{"docs.example.com", ".example.org"}})
+
+	tests := []struct {
+		name string
+		src  *domain.TextSource
+		want string
+	}{
+		{"missing type", &domain.TextSource{}, domain.SourceErrInvalid},
+		{"unknown type", &domain.TextSource{Type: "ftp"}, domain.SourceErrUnsupported},
+		{"stored without queue", &domain.TextSource{Type: domain.TextSourceStored, JobID: "x"}, domain.SourceErrUnsupported},
+		{"inline", &domain.TextSource{Type: domain.TextSourceInline, Text: "hi"}, ""},
+		{"inline without text", &domain.TextSource{Type: domain.TextSourceInline}, domain.SourceErrInvalid},
+		{"url", &domain.TextSource{Type: domain.TextSourceURL, URL: "https://docs.example.com/a.txt"}, ""},
+		{"url subdomain", &domain.TextSource{Type: domain.TextSourceURL, URL: "https://a.example.org/a.txt"}, ""},
+		{"url host not allowed", &domain.TextSource{Type: domain.TextSourceURL, URL: "https://evil.test/a.txt"}, domain.SourceErrInvalid},
+		{"url scheme", &domain.TextSource{Type: domain.TextSourceURL, URL: "file:///etc/passwd"}, domain.SourceErrInvalid},
+		{"document without chapter", &domain.TextSource{Type: domain.TextSourceDocument, URL: "https://docs.example.com/b.md"}, domain.SourceErrInvalid},
+		{"template", &domain.TextSource{Type: domain.TextSourceTemplate, Template: "Hi {{.name}}"}, ""},
+		{"template syntax", &domain.TextSource{Type: domain.TextSourceTemplate, Template: "Hi {{.name"}, domain.SourceErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sourceCode(t, r.Validate(tt.src)); got != tt.want {
+				t.Errorf("Validate() code = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolver_FetchURLAndDocument(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/book.md":
+			_, _ = w.Write([]byte("Preface.\n# One\nFirst chapter.\n## Two\nSecond chapter.\n#hashtag stays\n"))
+		case "/big.txt":
+			_, _ = w.Write([]byte(strings.Repeat("a", 100)))
+		case "/blank.txt":
+			_, _ = w.Write([]byte("  \n"))
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	r := New(Options{MaxBytes: 80, AllowedHosts: []string{u.Hostname()}})
+	ctx := context.Background()
+
+	// The server listens on loopback, which fetches refuse to connect to
+	_, err := r.Fetch(ctx, &domain.TextSource{Type: domain.TextSourceURL, URL: srv.URL + "/book.md"})
+	if got := sourceCode(t, err); got != domain.SourceErrFetchFailed || !strings.Contains(err.Error(), "address is not public") {
+		t.Fatalf("expected the fetch to be refused, got %v", err)
+	}
+	r.fetchers[domain.TextSourceURL].(*URLFetcher).httpClient = srv.Client()
+
+	text, err := r.Fetch(ctx, &domain.TextSource{Type: domain.TextSourceDocument, URL: srv.URL + "/book.md", Chapter: 3})
+	if err != nil {
+		t.Fatalf("Fetch document: %v", err)
+	}
+	if text != "Two\n\nSecond chapter.\n#hashtag stays" {
+		t.Errorf("unexpected chapter %q", text)
+	}
+
+	tests := []struct {
+		src  *domain.TextSource
+		want string
+	}{
+		{&domain.TextSource{Type: domain.TextSourceDocument, URL: srv.URL + "/book.md", Chapter: 4}, domain.SourceErrNotFound},
+		{&domain.TextSource{Type: domain.TextSourceURL, URL: srv.URL + "/missing"}, domain.SourceErrNotFound},
+		{&domain.TextSource{Type: domain.TextSourceURL, URL: srv.URL + "/broken"}, domain.SourceErrFetchFailed},
+		{&domain.TextSource{Type: domain.TextSourceURL, URL: srv.URL + "/big.txt"}, domain.SourceErrTooLarge},
+		{&domain.TextSource{Type: domain.TextSourceURL, URL: srv.URL + "/blank.txt"}, domain.SourceErrEmpty},
+	}
+	for _, tt := range tests {
+		_, err := r.Fetch(ctx, tt.src)
+		if got := sourceCode(t, err); got != tt.want {
+			t.Errorf("Fetch(%s) code = %q, want %q", tt.src.URL, got, tt.want)
+		}
+	}
+}
+
+func TestResolver_FetchStoredAndTemplate(t *testing.T) {
+	queue := memory.NewQueue(10)
+	job := domain.NewJob("Stored text", "voice", "", "", "p", "mp3", nil)
+	if err := queue.Enqueue(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+	r := New(Options{Jobs: queue, MaxBytes: 32})
+	ctx := context.Background()
+
+	if text, err := r.Fetch(ctx, &domain.TextSource{Type: domain.TextSourceStored, JobID: job.ID}); err != nil || text != "Stored text" {
+		t.Errorf("stored = %q, %v", text, err)
+	}
+	_, err := r.Fetch(ctx, &domain.TextSource{Type: domain.TextSourceStored, JobID: "missing"})
+	if got := sourceCode(t, err); got != domain.SourceErrNotFound {
+		t.Errorf("missing stored job code = %q", got)
+	}
+
+	tmpl := &domain.TextSource{Type: domain.TextSourceTemplate, Template: "Hello {{.name}}!", Vars: map[string]string{"name": "Ada"}}
+	if text, err := r.Fetch(ctx, tmpl); err != nil || text != "Hello Ada!" {
+		t.Errorf("template = %q, %v", text, err)
+	}
+	tmpl.Vars = nil
+	_, err = r.Fetch(ctx, tmpl)
+	if got := sourceCode(t, err); got != domain.SourceErrRender {
+		t.Errorf("missing variable code = %q", got)
+	}
+	tmpl.Vars = map[string]string{"name": strings.Repeat("x", 40)}
+	_, err = r.Fetch(ctx, tmpl)
+	if got := sourceCode(t, err); got != domain.SourceErrTooLarge {
+		t.Errorf("oversized render code = %q", got)
+	}
+}
+
+func TestChapters(t *testing.T) {
+	if got := Chapters("no headings here\nat all"); len(got) != 1 {
+		t.Errorf("expected a single chapter, got %q", got)
+	}
+	got := Chapters("# A\n\ntext a\n\n# B\ntext b")
+	if len(got) != 2 || got[0] != "A\n\ntext a" || got[1] != "B\n\ntext b" {
+		t.Errorf("unexpected chapters %q", got)
+	}
+}
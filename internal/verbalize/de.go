@@ -0,0 +1,181 @@
+package verbalize
+
+import (
+	"strings"
+	"time"
+)
+
+var german = &language{
+	minus:           "minus",
+	point:           "Komma",
+	decimal:         ",",
+	groups:          []string{"."},
+	digitFractions:  true,
+	dateSeparators:  ".",
+	months:          deMonths,
+	dayMonthPattern: `(?P<day>\d{1,2})\.\s*%s(?:\s+(?P<year>\d{4}))?`,
+	clockPattern:    clockPattern,
+	and:             "und",
+	currencies: map[string]currency{
+		"USD": {unit{forms: []string{"Dollar", "Dollar"}}, unit{forms: []string{"Cent", "Cent"}}},
+		"EUR": {unit{forms: []string{"Euro", "Euro"}}, unit{forms: []string{"Cent", "Cent"}}},
+		"GBP": {unit{forms: []string{"Pfund", "Pfund"}}, unit{forms: []string{"Penny", "Pence"}}},
+		"PLN": {unit{forms: []string{"Złoty", "Złoty"}}, unit{forms: []string{"Grosz", "Groszy"}}},
+	},
+	cardinal: deCardinal,
+	ordinal:  func(n int64, _ bool) string { return deOrdinal(n) },
+	date:     deDate,
+	clock:    deClock,
+	count:    deCount,
+}
+
+var deMonths = [12]string{
+	"Januar", "Februar", "März", "April", "Mai", "Juni",
+	"Juli", "August", "September", "Oktober", "November", "Dezember",
+}
+
+var deOnes = [...]string{
+	"null", "eins", "zwei", "drei", "vier", "fünf", "sechs", "sieben", "acht", "neun",
+	"zehn", "elf", "zwölf", "dreizehn", "vierzehn", "fünfzehn", "sechzehn", "siebzehn", "achtzehn", "neunzehn",
+}
+
+var deTens = [...]string{"", "", "zwanzig", "dreißig", "vierzig", "fünfzig", "sechzig", "siebzig", "achtzig", "neunzig"}
+
+var deScales = []struct {
+	n         int64
+	one, many string
+}{
+	{1e9, "eine Milliarde", "Milliarden"},
+	{1e6, "eine Million", "Millionen"},
+}
+
+// deCardinal reads n with everything below a million written as one word.
+func deCardinal(n int64) string {
+	if n == 0 {
+		return deOnes[0]
+	}
+	var words []string
+	for _, s := range deScales {
+		switch c := n / s.n; {
+		case c == 1:
+			words = append(words, s.one)
+		case c > 1:
+			words = append(words, deAttributive(deBelowMillion(c))+" "+s.many)
+		}
+		n %= s.n
+	}
+	if n > 0 {
+		words = append(words, deBelowMillion(n))
+	}
+	return strings.Join(words, " ")
+}
+
+func deBelowMillion(n int64) string {
+	var words string
+	if t := n / 1000; t > 0 {
+		words = deAttributive(deBelow1000(t)) + "tausend"
+	}
+	if r := n % 1000; r > 0 {
+		words += deBelow1000(r)
+	}
+	return words
+}
+
+func deBelow1000(n int64) string {
+	var words string
+	if h := n / 100; h > 0 {
+		words = deAttributive(deOnes[h]) + "hundert"
+	}
+	if r := n % 100; r > 0 {
+		words += deBelow100(r)
+	}
+	return words
+}
+
+func deBelow100(n int64) string {
+	switch u := n % 10; {
+	case n < 20:
+		return deOnes[n]
+	case u == 0:
+		return deTens[n/10]
+	default:
+		return deAttributive(deOnes[u]) + "und" + deTens[n/10]
+	}
+}
+
+// deAttributive turns a number ending in "eins" into the form used before a
+// noun or another number, as in "einhundert" or "ein Euro".
+func deAttributive(words string) string {
+	if strings.HasSuffix(words, "eins") {
+		return strings.TrimSuffix(words, "s")
+	}
+	return words
+}
+
+// deOrdinal returns the ordinal ending in -te, as in "der dritte".
+func deOrdinal(n int64) string {
+	words := deCardinal(n)
+	r := n % 100
+	if r == 0 || r >= 20 {
+		return words + "ste"
+	}
+	words = strings.TrimSuffix(words, deOnes[r])
+	switch r {
+	case 1:
+		return words + "erste"
+	case 3:
+		return words + "dritte"
+	case 7:
+		return words + "siebte"
+	case 8:
+		return words + "achte"
+	}
+	return words + deOnes[r] + "te"
+}
+
+// deDative lists words after which a date is read in the dative, as in "am
+// fünfzehnten März".
+var deDative = []string{"am", "vom", "zum", "dem"}
+
+func deDate(year int, month time.Month, day int, before string) string {
+	words := deOrdinal(int64(day)) + "r"
+	if fields := strings.Fields(before); len(fields) > 0 {
+		for _, w := range deDative {
+			if strings.EqualFold(fields[len(fields)-1], w) {
+				words = deOrdinal(int64(day)) + "n"
+			}
+		}
+	}
+	words += " " + deMonths[month-1]
+	if year > 0 {
+		words += " " + deYear(year)
+	}
+	return words
+}
+
+// deYear reads the years 1100-1999 in hundreds, as in "neunzehnhundertfünf".
+func deYear(year int) string {
+	if year < 1100 || year >= 2000 {
+		return deCardinal(int64(year))
+	}
+	words := deBelow100(int64(year/100)) + "hundert"
+	if lo := year % 100; lo > 0 {
+		words += deBelow100(int64(lo))
+	}
+	return words
+}
+
+func deClock(hour, minute int) string {
+	words := deAttributive(deCardinal(int64(hour))) + " Uhr"
+	if minute > 0 {
+		words += " " + deCardinal(int64(minute))
+	}
+	return words
+}
+
+func deCount(n int64, u unit) string {
+	if n == 1 {
+		return deAttributive(deCardinal(n)) + " " + u.forms[0]
+	}
+	return deAttributive(deCardinal(n)) + " " + u.forms[1]
+}
@@ -0,0 +1,149 @@
+package verbalize
+
+import (
+	"strings"
+	"time"
+)
+
+var english = &language{
+	minus:           "minus",
+	point:           "point",
+	decimal:         ".",
+	groups:          []string{","},
+	digitFractions:  true,
+	monthFirst:      true,
+	dateSeparators:  "/",
+	months:          enMonths,
+	dayMonthPattern: `%s\s+(?P<day>\d{1,2})(?:st|nd|rd|th)?(?:,?\s+(?P<year>\d{4}))?`,
+	clockPattern:    clockPattern,
+	ordinalPattern:  `(?P<int>\d+)(?:st|nd|rd|th)`,
+	and:             "and",
+	currencies: map[string]currency{
+		"USD": {unit{forms: []string{"dollar", "dollars"}}, unit{forms: []string{"cent", "cents"}}},
+		"EUR": {unit{forms: []string{"euro", "euros"}}, unit{forms: []string{"cent", "cents"}}},
+		"GBP": {unit{forms: []string{"pound", "pounds"}}, unit{forms: []string{"penny", "pence"}}},
+		"PLN": {unit{forms: []string{"zloty", "zlotys"}}, unit{forms: []string{"grosz", "groszy"}}},
+	},
+	cardinal: enCardinal,
+	ordinal:  func(n int64, _ bool) string { return enOrdinal(n) },
+	date:     enDate,
+	clock:    enClock,
+	count:    enCount,
+}
+
+var enMonths = [12]string{
+	"January", "February", "March", "April", "May", "June",
+	"July", "August", "September", "October", "November", "December",
+}
+
+var enOnes = [...]string{
+	"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine",
+	"ten", "eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen",
+}
+
+var enTens = [...]string{"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety"}
+
+var enScales = []struct {
+	n    int64
+	name string
+}{
+	{1e9, "billion"},
+	{1e6, "million"},
+	{1e3, "thousand"},
+}
+
+// enCardinal reads n in American English, without "and" after hundreds.
+func enCardinal(n int64) string {
+	if n == 0 {
+		return enOnes[0]
+	}
+	var words []string
+	for _, s := range enScales {
+		if n >= s.n {
+			words = append(words, enBelow1000(n/s.n), s.name)
+			n %= s.n
+		}
+	}
+	if n > 0 {
+		words = append(words, enBelow1000(n))
+	}
+	return strings.Join(words, " ")
+}
+
+func enBelow1000(n int64) string {
+	var words []string
+	if n >= 100 {
+		words = append(words, enOnes[n/100], "hundred")
+		n %= 100
+	}
+	switch {
+	case n >= 20 && n%10 > 0:
+		words = append(words, enTens[n/10]+"-"+enOnes[n%10])
+	case n >= 20:
+		words = append(words, enTens[n/10])
+	case n > 0:
+		words = append(words, enOnes[n])
+	}
+	return strings.Join(words, " ")
+}
+
+var enIrregularOrdinals = map[string]string{
+	"one": "first", "two": "second", "three": "third", "five": "fifth",
+	"eight": "eighth", "nine": "ninth", "twelve": "twelfth",
+}
+
+func enOrdinal(n int64) string {
+	words := enCardinal(n)
+	i := strings.LastIndexAny(words, " -") + 1
+	last := words[i:]
+	switch {
+	case enIrregularOrdinals[last] != "":
+		last = enIrregularOrdinals[last]
+	case strings.HasSuffix(last, "y"):
+		last = strings.TrimSuffix(last, "y") + "ieth"
+	default:
+		last += "th"
+	}
+	return words[:i] + last
+}
+
+// enYear reads a year in pairs, as in nineteen oh five, apart from 2000-2009
+// and years below 1100.
+func enYear(year int) string {
+	hi, lo := int64(year/100), int64(year%100)
+	switch {
+	case year < 1100 || year >= 10000 || year >= 2000 && year < 2010:
+		return enCardinal(int64(year))
+	case lo == 0:
+		return enCardinal(hi) + " hundred"
+	case lo < 10:
+		return enCardinal(hi) + " oh " + enCardinal(lo)
+	}
+	return enCardinal(hi) + " " + enCardinal(lo)
+}
+
+func enDate(year int, month time.Month, day int, _ string) string {
+	words := enMonths[month-1] + " " + enOrdinal(int64(day))
+	if year > 0 {
+		words += ", " + enYear(year)
+	}
+	return words
+}
+
+func enClock(hour, minute int) string {
+	words := enCardinal(int64(hour))
+	switch {
+	case minute == 0:
+		return words + " o'clock"
+	case minute < 10:
+		return words + " oh " + enCardinal(int64(minute))
+	}
+	return words + " " + enCardinal(int64(minute))
+}
+
+func enCount(n int64, u unit) string {
+	if n == 1 {
+		return enCardinal(n) + " " + u.forms[0]
+	}
+	return enCardinal(n) + " " + u.forms[1]
+}
@@ -0,0 +1,198 @@
+package verbalize
+
+import (
+	"strings"
+	"time"
+)
+
+var spanish = &language{
+	minus:          "menos",
+	point:          "coma",
+	decimal:        ",",
+	groups:         []string{"."},
+	dateSeparators: "/.-",
+	months:         esMonths,
+	clockPattern:   clockPattern,
+	ordinalPattern: `(?P<int>\d+)\.?(?:(?P<fem>ª)|º)`,
+	and:            "con",
+	currencies: map[string]currency{
+		"USD": {unit{forms: []string{"dólar", "dólares"}}, unit{forms: []string{"centavo", "centavos"}}},
+		"EUR": {unit{forms: []string{"euro", "euros"}}, unit{forms: []string{"céntimo", "céntimos"}}},
+		"GBP": {unit{forms: []string{"libra", "libras"}, feminine: true}, unit{forms: []string{"penique", "peniques"}}},
+		"PLN": {unit{forms: []string{"esloti", "eslotis"}}, unit{forms: []string{"grosz", "groszy"}}},
+	},
+	cardinal: esCardinal,
+	ordinal:  esOrdinal,
+	date:     esDate,
+	clock:    esClock,
+	count:    esCount,
+}
+
+var esMonths = [12]string{
+	"enero", "febrero", "marzo", "abril", "mayo", "junio",
+	"julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre",
+}
+
+var esOnes = [...]string{
+	"cero", "uno", "dos", "tres", "cuatro", "cinco", "seis", "siete", "ocho", "nueve",
+	"diez", "once", "doce", "trece", "catorce", "quince", "dieciséis", "diecisiete", "dieciocho", "diecinueve",
+	"veinte", "veintiuno", "veintidós", "veintitrés", "veinticuatro", "veinticinco", "veintiséis", "veintisiete", "veintiocho", "veintinueve",
+}
+
+var esTens = [...]string{"", "", "", "treinta", "cuarenta", "cincuenta", "sesenta", "setenta", "ochenta", "noventa"}
+
+var esHundreds = [...]string{
+	"", "ciento", "doscientos", "trescientos", "cuatrocientos",
+	"quinientos", "seiscientos", "setecientos", "ochocientos", "novecientos",
+}
+
+// esCardinal reads n with the long scale, so a billion is "mil millones".
+func esCardinal(n int64) string {
+	if n == 0 {
+		return esOnes[0]
+	}
+	var words []string
+	switch m := n / 1e6; {
+	case m == 1:
+		words = append(words, "un millón")
+	case m > 1:
+		words = append(words, esApocope(esBelowMillion(m))+" millones")
+	}
+	if r := n % 1e6; r > 0 {
+		words = append(words, esBelowMillion(r))
+	}
+	return strings.Join(words, " ")
+}
+
+func esBelowMillion(n int64) string {
+	var words []string
+	switch t := n / 1000; {
+	case t == 1:
+		words = append(words, "mil")
+	case t > 1:
+		words = append(words, esApocope(esBelow1000(t))+" mil")
+	}
+	if r := n % 1000; r > 0 {
+		words = append(words, esBelow1000(r))
+	}
+	return strings.Join(words, " ")
+}
+
+func esBelow1000(n int64) string {
+	if n == 100 {
+		return "cien"
+	}
+	var words []string
+	if h := n / 100; h > 0 {
+		words = append(words, esHundreds[h])
+	}
+	switch r := n % 100; {
+	case r >= 30 && r%10 > 0:
+		words = append(words, esTens[r/10]+" y "+esOnes[r%10])
+	case r >= 30:
+		words = append(words, esTens[r/10])
+	case r > 0:
+		words = append(words, esOnes[r])
+	}
+	return strings.Join(words, " ")
+}
+
+// esApocope turns a number ending in uno into the form before a masculine noun
+// or mil, as in "veintiún mil".
+func esApocope(words string) string {
+	switch {
+	case strings.HasSuffix(words, "veintiuno"):
+		return strings.TrimSuffix(words, "veintiuno") + "veintiún"
+	case strings.HasSuffix(words, "uno"):
+		return strings.TrimSuffix(words, "o")
+	}
+	return words
+}
+
+// esFeminine turns a number into the form before a feminine noun, as in
+// "doscientas una".
+func esFeminine(words string) string {
+	words = strings.ReplaceAll(words, "ientos", "ientas")
+	if strings.HasSuffix(words, "uno") {
+		return strings.TrimSuffix(words, "o") + "a"
+	}
+	return words
+}
+
+var esOrdinalOnes = [...]string{
+	"", "primero", "segundo", "tercero", "cuarto", "quinto", "sexto", "séptimo", "octavo", "noveno",
+	"décimo", "undécimo", "duodécimo", "decimotercero", "decimocuarto", "decimoquinto", "decimosexto", "decimoséptimo", "decimoctavo", "decimonoveno",
+}
+
+var esOrdinalTens = [...]string{
+	"", "", "vigésimo", "trigésimo", "cuadragésimo", "quincuagésimo", "sexagésimo", "septuagésimo", "octogésimo", "nonagésimo",
+}
+
+var esOrdinalHundreds = [...]string{
+	"", "centésimo", "ducentésimo", "tricentésimo", "cuadringentésimo",
+	"quingentésimo", "sexcentésimo", "septingentésimo", "octingentésimo", "noningentésimo",
+}
+
+// esOrdinal returns the ordinal of n, which is read as a cardinal from 1001 on,
+// as is usual in Spanish.
+func esOrdinal(n int64, feminine bool) string {
+	if n > 1000 {
+		if feminine {
+			return esFeminine(esCardinal(n))
+		}
+		return esCardinal(n)
+	}
+	var words []string
+	switch h := n / 100; {
+	case n == 1000:
+		words = append(words, "milésimo")
+	case h > 0:
+		words = append(words, esOrdinalHundreds[h])
+	}
+	switch r := n % 100; {
+	case r >= 20 && r%10 > 0:
+		words = append(words, esOrdinalTens[r/10], esOrdinalOnes[r%10])
+	case r >= 20:
+		words = append(words, esOrdinalTens[r/10])
+	case r > 0:
+		words = append(words, esOrdinalOnes[r])
+	}
+	if feminine {
+		for i, w := range words {
+			words[i] = strings.TrimSuffix(w, "o") + "a"
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func esDate(year int, month time.Month, day int, _ string) string {
+	words := esCardinal(int64(day)) + " de " + esMonths[month-1]
+	if year > 0 {
+		words += " de " + esCardinal(int64(year))
+	}
+	return words
+}
+
+func esClock(hour, minute int) string {
+	words := esFeminine(esCardinal(int64(hour)))
+	if minute == 0 {
+		return words + " en punto"
+	}
+	return words + " y " + esCardinal(int64(minute))
+}
+
+func esCount(n int64, u unit) string {
+	words := esApocope(esCardinal(n))
+	if u.feminine {
+		words = esFeminine(esCardinal(n))
+	}
+	form := u.forms[1]
+	if n == 1 {
+		form = u.forms[0]
+	}
+	// Round millions count with de, as in "un millón de euros"
+	if n >= 1e6 && n%1e6 == 0 {
+		return words + " de " + form
+	}
+	return words + " " + form
+}
@@ -0,0 +1,209 @@
+package verbalize
+
+import (
+	"strings"
+	"time"
+)
+
+var french = &language{
+	minus:          "moins",
+	point:          "virgule",
+	decimal:        ",",
+	groups:         []string{" ", "\u00a0", "\u202f"},
+	dateSeparators: "/.",
+	months:         frMonths,
+	clockPattern:   `(?P<hour>\d{1,2})(?P<sep>[:h])(?P<minute>\d{2})?`,
+	ordinalPattern: `(?P<int>\d+)(?:(?P<fem>re)|ème|ième|ieme|eme|er|e)`,
+	and:            "et",
+	currencies: map[string]currency{
+		"USD": {unit{forms: []string{"dollar", "dollars"}}, unit{forms: []string{"cent", "cents"}}},
+		"EUR": {unit{forms: []string{"euro", "euros"}}, unit{forms: []string{"centime", "centimes"}}},
+		"GBP": {unit{forms: []string{"livre", "livres"}, feminine: true}, unit{forms: []string{"penny", "pence"}}},
+		"PLN": {unit{forms: []string{"zloty", "zlotys"}}, unit{forms: []string{"grosz", "groszy"}}},
+	},
+	cardinal: frCardinal,
+	ordinal:  frOrdinal,
+	date:     frDate,
+	clock:    frClock,
+	count:    frCount,
+}
+
+var frMonths = [12]string{
+	"janvier", "février", "mars", "avril", "mai", "juin",
+	"juillet", "août", "septembre", "octobre", "novembre", "décembre",
+}
+
+var frOnes = [...]string{
+	"zéro", "un", "deux", "trois", "quatre", "cinq", "six", "sept", "huit", "neuf",
+	"dix", "onze", "douze", "treize", "quatorze", "quinze", "seize",
+}
+
+var frTens = [...]string{"", "", "vingt", "trente", "quarante", "cinquante", "soixante"}
+
+var frScales = []struct {
+	n         int64
+	one, many string
+}{
+	{1e9, "un milliard", "milliards"},
+	{1e6, "un million", "millions"},
+}
+
+// frCardinal reads n in the traditional spelling, with hyphens below a hundred
+// only.
+func frCardinal(n int64) string {
+	if n == 0 {
+		return frOnes[0]
+	}
+	var words []string
+	for _, s := range frScales {
+		switch c := n / s.n; {
+		case c == 1:
+			words = append(words, s.one)
+		case c > 1:
+			words = append(words, frBelowMillion(c)+" "+s.many)
+		}
+		n %= s.n
+	}
+	if n > 0 {
+		words = append(words, frBelowMillion(n))
+	}
+	return strings.Join(words, " ")
+}
+
+func frBelowMillion(n int64) string {
+	var words []string
+	switch t := n / 1000; {
+	case t == 1:
+		words = append(words, "mille")
+	case t > 1:
+		// Vingt and cent take no s before mille, as in "deux cent mille"
+		words = append(words, frInvariable(frBelow1000(t)), "mille")
+	}
+	if r := n % 1000; r > 0 {
+		words = append(words, frBelow1000(r))
+	}
+	return strings.Join(words, " ")
+}
+
+func frBelow1000(n int64) string {
+	h, r := n/100, n%100
+	if h == 0 {
+		return frBelow100(r)
+	}
+	words := "cent"
+	if h > 1 {
+		words = frOnes[h] + " cent"
+		if r == 0 {
+			words += "s"
+		}
+	}
+	if r > 0 {
+		words += " " + frBelow100(r)
+	}
+	return words
+}
+
+func frBelow100(n int64) string {
+	switch {
+	case n <= 16:
+		return frOnes[n]
+	case n < 20:
+		return "dix-" + frOnes[n-10]
+	case n < 70:
+		switch t, u := frTens[n/10], n%10; u {
+		case 0:
+			return t
+		case 1:
+			return t + " et un"
+		default:
+			return t + "-" + frOnes[u]
+		}
+	case n == 71:
+		return "soixante et onze"
+	case n < 80:
+		return "soixante-" + frBelow100(n-60)
+	case n == 80:
+		return "quatre-vingts"
+	}
+	return "quatre-vingt-" + frBelow100(n-80)
+}
+
+// frInvariable drops the plural s of a number ending in vingts or cents.
+func frInvariable(words string) string {
+	if strings.HasSuffix(words, "vingts") || strings.HasSuffix(words, "cents") {
+		return strings.TrimSuffix(words, "s")
+	}
+	return words
+}
+
+// frFeminine turns a number ending in un into the form before a feminine noun.
+func frFeminine(words string) string {
+	if words == "un" || strings.HasSuffix(words, " un") || strings.HasSuffix(words, "-un") {
+		return words + "e"
+	}
+	return words
+}
+
+func frOrdinal(n int64, feminine bool) string {
+	if n == 1 {
+		if feminine {
+			return "première"
+		}
+		return "premier"
+	}
+	words := frInvariable(frCardinal(n))
+	if strings.HasSuffix(words, "millions") || strings.HasSuffix(words, "milliards") {
+		words = strings.TrimSuffix(words, "s")
+	}
+	switch {
+	case strings.HasSuffix(words, "cinq"):
+		return words + "uième"
+	case strings.HasSuffix(words, "neuf"):
+		return strings.TrimSuffix(words, "f") + "vième"
+	case strings.HasSuffix(words, "e"):
+		return strings.TrimSuffix(words, "e") + "ième"
+	}
+	return words + "ième"
+}
+
+func frDate(year int, month time.Month, day int, _ string) string {
+	words := frCardinal(int64(day))
+	if day == 1 {
+		words = "premier"
+	}
+	words += " " + frMonths[month-1]
+	if year > 0 {
+		words += " " + frCardinal(int64(year))
+	}
+	return words
+}
+
+func frClock(hour, minute int) string {
+	words := frFeminine(frCardinal(int64(hour))) + " heures"
+	if hour < 2 {
+		words = frFeminine(frCardinal(int64(hour))) + " heure"
+	}
+	if minute > 0 {
+		words += " " + frFeminine(frCardinal(int64(minute)))
+	}
+	return words
+}
+
+func frCount(n int64, u unit) string {
+	words := frCardinal(n)
+	if u.feminine {
+		words = frFeminine(words)
+	}
+	form := u.forms[0]
+	if n >= 2 {
+		form = u.forms[1]
+	}
+	// Round millions count with de, as in "un million d'euros"
+	if n >= 1e6 && n%1e6 == 0 {
+		if strings.ContainsRune("aeiouy", rune(form[0])) {
+			return words + " d'" + form
+		}
+		return words + " de " + form
+	}
+	return words + " " + form
+}
@@ -0,0 +1,216 @@
+package verbalize
+
+import (
+	"strings"
+	"time"
+)
+
+var polish = &language{
+	minus:           "minus",
+	point:           "przecinek",
+	decimal:         ",",
+	groups:          []string{" ", "\u00a0", "\u202f"},
+	dateSeparators:  ".",
+	months:          plMonths,
+	dayMonthPattern: `(?P<day>\d{1,2})\s+%s(?:\s+(?P<year>\d{4})(?:\s*r\.|\s+roku)?)?`,
+	clockPattern:    clockPattern,
+	currencies: map[string]currency{
+		"USD": {unit{forms: []string{"dolar", "dolary", "dolarów"}}, unit{forms: []string{"cent", "centy", "centów"}}},
+		"EUR": {unit{forms: []string{"euro", "euro", "euro"}, neuter: true}, unit{forms: []string{"cent", "centy", "centów"}}},
+		"GBP": {unit{forms: []string{"funt", "funty", "funtów"}}, unit{forms: []string{"pens", "pensy", "pensów"}}},
+		"PLN": {unit{forms: []string{"złoty", "złote", "złotych"}}, unit{forms: []string{"grosz", "grosze", "groszy"}}},
+	},
+	cardinal: plCardinal,
+	ordinal: func(n int64, feminine bool) string {
+		if feminine {
+			return plOrdinal(n, plFeminine)
+		}
+		return plOrdinal(n, nil)
+	},
+	date:  plDate,
+	clock: plClock,
+	count: plCount,
+}
+
+// plMonths are the month names in the genitive, as read in dates.
+var plMonths = [12]string{
+	"stycznia", "lutego", "marca", "kwietnia", "maja", "czerwca",
+	"lipca", "sierpnia", "września", "października", "listopada", "grudnia",
+}
+
+var plOnes = [...]string{
+	"zero", "jeden", "dwa", "trzy", "cztery", "pięć", "sześć", "siedem", "osiem", "dziewięć",
+	"dziesięć", "jedenaście", "dwanaście", "trzynaście", "czternaście", "piętnaście", "szesnaście", "siedemnaście", "osiemnaście", "dziewiętnaście",
+}
+
+var plTens = [...]string{
+	"", "", "dwadzieścia", "trzydzieści", "czterdzieści", "pięćdziesiąt", "sześćdziesiąt", "siedemdziesiąt", "osiemdziesiąt", "dziewięćdziesiąt",
+}
+
+var plHundreds = [...]string{
+	"", "sto", "dwieście", "trzysta", "czterysta", "pięćset", "sześćset", "siedemset", "osiemset", "dziewięćset",
+}
+
+var plScales = []struct {
+	n     int64
+	forms [3]string
+}{
+	{1e9, [3]string{"miliard", "miliardy", "miliardów"}},
+	{1e6, [3]string{"milion", "miliony", "milionów"}},
+	{1e3, [3]string{"tysiąc", "tysiące", "tysięcy"}},
+}
+
+// plForm returns which of the three forms of a noun follows n: the one for 1,
+// for numbers ending in 2-4 other than 12-14, or for the rest.
+func plForm(n int64) int {
+	switch {
+	case n == 1:
+		return 0
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+		return 1
+	}
+	return 2
+}
+
+func plCardinal(n int64) string {
+	if n == 0 {
+		return plOnes[0]
+	}
+	var words []string
+	for _, s := range plScales {
+		switch c := n / s.n; {
+		case c == 1:
+			words = append(words, s.forms[0])
+		case c > 1:
+			words = append(words, plBelow1000(c), s.forms[plForm(c)])
+		}
+		n %= s.n
+	}
+	if n > 0 {
+		words = append(words, plBelow1000(n))
+	}
+	return strings.Join(words, " ")
+}
+
+func plBelow1000(n int64) string {
+	var words []string
+	if h := n / 100; h > 0 {
+		words = append(words, plHundreds[h])
+	}
+	switch r := n % 100; {
+	case r >= 20 && r%10 > 0:
+		words = append(words, plTens[r/10], plOnes[r%10])
+	case r >= 20:
+		words = append(words, plTens[r/10])
+	case r > 0:
+		words = append(words, plOnes[r])
+	}
+	return strings.Join(words, " ")
+}
+
+var plOrdinalOnes = [...]string{
+	"", "pierwszy", "drugi", "trzeci", "czwarty", "piąty", "szósty", "siódmy", "ósmy", "dziewiąty",
+	"dziesiąty", "jedenasty", "dwunasty", "trzynasty", "czternasty", "piętnasty", "szesnasty", "siedemnasty", "osiemnasty", "dziewiętnasty",
+}
+
+var plOrdinalTens = [...]string{
+	"", "", "dwudziesty", "trzydziesty", "czterdziesty", "pięćdziesiąty", "sześćdziesiąty", "siedemdziesiąty", "osiemdziesiąty", "dziewięćdziesiąty",
+}
+
+var plOrdinalHundreds = [...]string{
+	"", "setny", "dwusetny", "trzechsetny", "czterechsetny", "pięćsetny", "sześćsetny", "siedemsetny", "osiemsetny", "dziewięćsetny",
+}
+
+// plThousandths are the prefixes of "tysięczny" in the ordinals of 1000-9000.
+var plThousandths = [...]string{"", "", "dwu", "trzy", "cztero", "pięcio", "sześcio", "siedmio", "ośmio", "dziewięcio"}
+
+// plOrdinal returns the ordinal of n, with only the words for its last three
+// digits inflected, as in "dwa tysiące dwudziesty czwarty". form inflects them
+// from the masculine nominative; nil keeps them. Round thousands from 10000 on
+// are read as cardinals.
+func plOrdinal(n int64, form func(string) string) string {
+	if form == nil {
+		form = func(w string) string { return w }
+	}
+	rest := n % 1000
+	if rest == 0 {
+		if n < 10000 {
+			return form(plThousandths[n/1000] + "tysięczny")
+		}
+		return plCardinal(n)
+	}
+	var words []string
+	if head := n - rest; head > 0 {
+		words = append(words, plCardinal(head))
+	}
+	h, r := rest/100, rest%100
+	if r == 0 {
+		return strings.Join(append(words, form(plOrdinalHundreds[h])), " ")
+	}
+	if h > 0 {
+		words = append(words, plHundreds[h])
+	}
+	switch {
+	case r >= 20 && r%10 > 0:
+		words = append(words, form(plOrdinalTens[r/10]), form(plOrdinalOnes[r%10]))
+	case r >= 20:
+		words = append(words, form(plOrdinalTens[r/10]))
+	default:
+		words = append(words, form(plOrdinalOnes[r]))
+	}
+	return strings.Join(words, " ")
+}
+
+// plGenitive inflects a masculine ordinal into the genitive, as in "piątego".
+func plGenitive(w string) string {
+	if strings.HasSuffix(w, "i") {
+		return w + "ego"
+	}
+	return strings.TrimSuffix(w, "y") + "ego"
+}
+
+// plFeminine inflects a masculine ordinal into the feminine, as in "druga".
+func plFeminine(w string) string {
+	switch {
+	case strings.HasSuffix(w, "gi"):
+		return strings.TrimSuffix(w, "i") + "a"
+	case strings.HasSuffix(w, "i"):
+		return w + "a"
+	}
+	return strings.TrimSuffix(w, "y") + "a"
+}
+
+// plDate reads the day and year as genitive ordinals, as in "piętnastego marca
+// dwa tysiące dwudziestego czwartego roku".
+func plDate(year int, month time.Month, day int, _ string) string {
+	words := plOrdinal(int64(day), plGenitive) + " " + plMonths[month-1]
+	if year > 0 {
+		words += " " + plOrdinal(int64(year), plGenitive) + " roku"
+	}
+	return words
+}
+
+// plClock reads the hour as a feminine ordinal, as in "czternasta trzydzieści".
+func plClock(hour, minute int) string {
+	words := plOnes[0]
+	if hour > 0 {
+		words = plOrdinal(int64(hour), plFeminine)
+	}
+	switch {
+	case minute == 0:
+		return words
+	case minute < 10:
+		return words + " " + plOnes[0] + " " + plOnes[minute]
+	}
+	return words + " " + plCardinal(int64(minute))
+}
+
+func plCount(n int64, u unit) string {
+	switch {
+	case n == 1 && u.neuter:
+		return "jedno " + u.forms[0]
+	case n == 1:
+		return "jeden " + u.forms[0]
+	}
+	return plCardinal(n) + " " + u.forms[plForm(n)]
+}
@@ -0,0 +1,419 @@
+// Package verbalize writes out numbers, ordinals, dates, times of day and
+// amounts of money as they are read in a language, e.g. "€12.50" as "twelve
+// euros and fifty cents", because providers normalize them inconsistently across
+// languages.
+package verbalize
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxDigits bounds the numbers read as a whole; longer ones are read digit by
+// digit, as are numbers with leading zeros.
+const maxDigits = 12
+
+// unit is a noun counted in amounts of money, such as "dollar".
+type unit struct {
+	// forms are the noun's forms for the counts the language tells apart: the
+	// singular and plural, or in Polish the forms for 1, for 2-4 and for 5 on.
+	forms []string
+	// feminine and neuter nouns change the number before them in some languages.
+	feminine, neuter bool
+}
+
+// currency is what amounts of one currency are counted in.
+type currency struct {
+	major, minor unit
+}
+
+// currencySymbols maps the symbols and codes amounts are written with to the
+// currencies languages read.
+var currencySymbols = map[string]string{
+	"$": "USD", "USD": "USD",
+	"€": "EUR", "EUR": "EUR",
+	"£": "GBP", "GBP": "GBP",
+	"zł": "PLN", "PLN": "PLN",
+}
+
+// language holds how numbers are written and read in one language.
+type language struct {
+	// minus and point are read for a negative sign and the decimal separator.
+	minus, point string
+	// decimal and groups are the decimal and thousands separators of numbers.
+	decimal string
+	groups  []string
+	// digitFractions reads decimals digit by digit rather than as a number.
+	digitFractions bool
+	// monthFirst orders numeric dates month, day, year rather than day, month,
+	// year; dateSeparators are the characters between them.
+	monthFirst     bool
+	dateSeparators string
+	// months are the month names as read in dates.
+	months [12]string
+	// dayMonthPattern matches dates with a month name, which it takes as %s;
+	// clockPattern matches times of day and ordinalPattern ordinals. Dates with a
+	// month name and ordinals aren't recognized when their pattern is empty.
+	dayMonthPattern, clockPattern, ordinalPattern string
+	// and joins the major and minor units of an amount of money.
+	and        string
+	currencies map[string]currency
+
+	cardinal func(n int64) string
+	ordinal  func(n int64, feminine bool) string
+	// date reads a date; year is 0 when the text has none, and before is the
+	// text before the date, for languages where it changes the case.
+	date  func(year int, month time.Month, day int, before string) string
+	clock func(hour, minute int) string
+	count func(n int64, u unit) string
+
+	all, numbers *matcher
+}
+
+// languages maps the supported languages to how they are read.
+var languages = map[string]*language{
+	"de": german,
+	"en": english,
+	"es": spanish,
+	"fr": french,
+	"pl": polish,
+}
+
+func init() {
+	for _, l := range languages {
+		l.compile()
+	}
+}
+
+// Languages returns the supported language codes, sorted.
+func Languages() []string {
+	codes := make([]string, 0, len(languages))
+	for code := range languages {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Supported reports whether lang, a language tag such as "de" or "en-GB", is
+// read in a supported language. Only its primary subtag counts.
+func Supported(lang string) bool {
+	return lookup(lang) != nil
+}
+
+// Verbalize writes out the numbers, ordinals, dates, times of day and amounts of
+// money in text as they are read in lang. Text in a language that isn't
+// supported is returned unchanged, as are numbers that are part of a word or of
+// a longer token, such as MP3 or a version 1.2.3.
+func Verbalize(text, lang string) string {
+	l := lookup(lang)
+	if l == nil {
+		return text
+	}
+	return l.all.replace(l, text)
+}
+
+// Cardinal returns n in words in lang, or in digits when lang isn't supported.
+func Cardinal(n int64, lang string) string {
+	l := lookup(lang)
+	if l == nil {
+		return strconv.FormatInt(n, 10)
+	}
+	digits := strconv.FormatInt(n, 10)
+	if n < 0 {
+		return l.minus + " " + l.integer(digits[1:])
+	}
+	return l.integer(digits)
+}
+
+// Ordinal returns the ordinal of n in words in lang, in the masculine where the
+// language has genders. Numbers below 1, and every number when lang isn't
+// supported, are returned as by Cardinal.
+func Ordinal(n int64, lang string) string {
+	l := lookup(lang)
+	if l == nil || n < 1 || len(strconv.FormatInt(n, 10)) > maxDigits {
+		return Cardinal(n, lang)
+	}
+	return l.ordinal(n, false)
+}
+
+func lookup(lang string) *language {
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	return languages[strings.ToLower(lang)]
+}
+
+// compile builds the matchers of l from its patterns.
+func (l *language) compile() {
+	groups := make([]string, len(l.groups))
+	for i, g := range l.groups {
+		groups[i] = regexp.QuoteMeta(g)
+	}
+	amount := `(?P<int>\d{1,3}(?:(?:` + strings.Join(groups, "|") + `)\d{3})+|\d+)(?:` +
+		regexp.QuoteMeta(l.decimal) + `(?P<frac>\d+))?`
+
+	sep := `[` + regexp.QuoteMeta(l.dateSeparators) + `]`
+	numeric := `(?P<day>\d{1,2})` + sep + `(?P<month>\d{1,2})` + sep + `(?P<year>\d{4})`
+	if l.monthFirst {
+		numeric = `(?P<month>\d{1,2})` + sep + `(?P<day>\d{1,2})` + sep + `(?P<year>\d{4})`
+	}
+
+	// Earlier rules win over later ones matching at the same place
+	rules := []rule{
+		{pattern: `(?P<sym>[$€£])\s?` + amount, apply: (*language).moneyWords},
+		{pattern: amount + `\s?(?P<sym>€|\$|£|zł|EUR|USD|GBP|PLN)`, apply: (*language).moneyWords},
+		{pattern: `(?P<year>\d{4})-(?P<month>\d{2})-(?P<day>\d{2})`, apply: (*language).dateWords},
+		{pattern: numeric, apply: (*language).dateWords},
+	}
+	if l.dayMonthPattern != "" {
+		pattern := fmt.Sprintf(l.dayMonthPattern, `(?P<monthname>`+strings.Join(l.months[:], "|")+`)`)
+		rules = append(rules, rule{pattern: pattern, apply: (*language).dateWords})
+	}
+	rules = append(rules, rule{pattern: l.clockPattern, apply: (*language).clockWords})
+	if l.ordinalPattern != "" {
+		rules = append(rules, rule{pattern: l.ordinalPattern, apply: (*language).ordinalWords})
+	}
+	number := rule{pattern: `(?P<minus>[-−])?` + amount, apply: (*language).numberWords}
+
+	l.all = newMatcher(append(rules, number))
+	l.numbers = newMatcher([]rule{number})
+}
+
+// clockPattern matches times of day written as 14:30.
+const clockPattern = `(?P<hour>\d{1,2}):(?P<minute>\d{2})`
+
+// rule is one kind of token a language verbalizes.
+type rule struct {
+	pattern string
+	// apply returns the words for a match given its named groups, which are
+	// absent when empty, and the text before it. It reports false when the match
+	// isn't what the rule reads, such as 31 February.
+	apply func(l *language, groups map[string]string, before string) (string, bool)
+	re    *regexp.Regexp
+}
+
+// matcher finds the tokens of several rules in one pass.
+type matcher struct {
+	find  *regexp.Regexp
+	rules []rule
+	// groups are the groups of find the rules are captured in.
+	groups []int
+}
+
+func newMatcher(rules []rule) *matcher {
+	m := &matcher{rules: rules}
+	parts := make([]string, len(rules))
+	group := 1
+	for i := range m.rules {
+		m.rules[i].re = regexp.MustCompile(`^(?:` + rules[i].pattern + `)$`)
+		m.groups = append(m.groups, group)
+		group += 1 + m.rules[i].re.NumSubexp()
+		parts[i] = "(" + rules[i].pattern + ")"
+	}
+	m.find = regexp.MustCompile(strings.Join(parts, "|"))
+	return m
+}
+
+// replace writes out the tokens in text. In tokens a rule rejects, only the
+// numbers standing on their own are read.
+func (m *matcher) replace(l *language, text string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range m.find.FindAllStringSubmatchIndex(text, -1) {
+		start, end := loc[0], loc[1]
+		i := 0
+		for i < len(m.rules)-1 && loc[2*m.groups[i]] < 0 {
+			i++
+		}
+		// A minus right after a word or number is a dash, as in 10-12
+		if r, size := utf8.DecodeRuneInString(text[start:]); (r == '-' || r == '−') && start > 0 {
+			if before, _ := utf8.DecodeLastRuneInString(text[:start]); isWord(before) {
+				start += size
+			}
+		}
+		if !bounded(text, start, end) {
+			continue
+		}
+		match := m.rules[i].re.FindStringSubmatch(text[start:end])
+		if match == nil {
+			continue
+		}
+		groups := make(map[string]string)
+		for j, name := range m.rules[i].re.SubexpNames() {
+			if name != "" && match[j] != "" {
+				groups[name] = match[j]
+			}
+		}
+		words, ok := m.rules[i].apply(l, groups, text[:start])
+		if !ok {
+			words = l.numbers.replace(l, text[start:end])
+		}
+		b.WriteString(text[last:start])
+		b.WriteString(words)
+		last = end
+	}
+	if last == 0 {
+		return text
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// bounded reports whether text[start:end] stands on its own rather than being
+// part of a word or of a longer number.
+func bounded(text string, start, end int) bool {
+	before, size := utf8.DecodeLastRuneInString(text[:start])
+	if isWord(before) || strings.ContainsRune(".,:", before) && endsWithDigit(text[:start-size]) {
+		return false
+	}
+	after, size := utf8.DecodeRuneInString(text[end:])
+	return !isWord(after) && !(strings.ContainsRune(".,:", after) && startsWithDigit(text[end+size:]))
+}
+
+func isWord(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+func endsWithDigit(s string) bool {
+	return s != "" && s[len(s)-1] >= '0' && s[len(s)-1] <= '9'
+}
+
+func startsWithDigit(s string) bool {
+	return s != "" && s[0] >= '0' && s[0] <= '9'
+}
+
+func (l *language) numberWords(groups map[string]string, _ string) (string, bool) {
+	words := l.decimalNumber(groups["int"], groups["frac"])
+	if groups["minus"] != "" {
+		words = l.minus + " " + words
+	}
+	return words, true
+}
+
+func (l *language) moneyWords(groups map[string]string, _ string) (string, bool) {
+	cur := l.currencies[currencySymbols[groups["sym"]]]
+	digits := strings.Map(keepDigits, groups["int"])
+	if len(digits) > maxDigits {
+		return "", false
+	}
+	n, _ := strconv.ParseInt(digits, 10, 64)
+
+	frac := groups["frac"]
+	if len(frac) > 2 {
+		return l.decimalNumber(groups["int"], frac) + " " + cur.major.forms[len(cur.major.forms)-1], true
+	}
+	minor, _ := strconv.Atoi(frac)
+	if len(frac) == 1 {
+		minor *= 10
+	}
+	var parts []string
+	if n > 0 || minor == 0 {
+		parts = append(parts, l.count(n, cur.major))
+	}
+	if minor > 0 {
+		parts = append(parts, l.count(int64(minor), cur.minor))
+	}
+	sep := " "
+	if l.and != "" {
+		sep = " " + l.and + " "
+	}
+	return strings.Join(parts, sep), true
+}
+
+func (l *language) dateWords(groups map[string]string, before string) (string, bool) {
+	year, _ := strconv.Atoi(groups["year"])
+	month, _ := strconv.Atoi(groups["month"])
+	day, _ := strconv.Atoi(groups["day"])
+	if name, ok := groups["monthname"]; ok {
+		month = slices.Index(l.months[:], name) + 1
+	}
+	if month < 1 || month > 12 || day < 1 || day > daysIn(year, time.Month(month)) {
+		return "", false
+	}
+	return l.date(year, time.Month(month), day, before), true
+}
+
+// daysIn returns the number of days of month in year, allowing 29 February when
+// the year isn't known.
+func daysIn(year int, month time.Month) int {
+	if year == 0 {
+		year = 2000
+	}
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func (l *language) clockWords(groups map[string]string, _ string) (string, bool) {
+	hour, _ := strconv.Atoi(groups["hour"])
+	minute, _ := strconv.Atoi(groups["minute"])
+	if hour > 23 || minute > 59 || groups["sep"] == ":" && groups["minute"] == "" {
+		return "", false
+	}
+	return l.clock(hour, minute), true
+}
+
+func (l *language) ordinalWords(groups map[string]string, _ string) (string, bool) {
+	if len(groups["int"]) > maxDigits {
+		return "", false
+	}
+	n, _ := strconv.ParseInt(groups["int"], 10, 64)
+	if n < 1 {
+		return "", false
+	}
+	_, feminine := groups["fem"]
+	return l.ordinal(n, feminine), true
+}
+
+// decimalNumber reads a number written with thousands separators and decimals.
+func (l *language) decimalNumber(integer, frac string) string {
+	words := l.integer(strings.Map(keepDigits, integer))
+	if frac != "" {
+		words += " " + l.point + " " + l.fraction(frac)
+	}
+	return words
+}
+
+// integer reads a whole number given in digits.
+func (l *language) integer(digits string) string {
+	if len(digits) > maxDigits || len(digits) > 1 && digits[0] == '0' {
+		return l.spell(digits)
+	}
+	n, _ := strconv.ParseInt(digits, 10, 64)
+	return l.cardinal(n)
+}
+
+// fraction reads the decimals of a number, keeping leading zeros.
+func (l *language) fraction(digits string) string {
+	if l.digitFractions {
+		return l.spell(digits)
+	}
+	rest := strings.TrimLeft(digits, "0")
+	words := slices.Repeat([]string{l.cardinal(0)}, len(digits)-len(rest))
+	if rest != "" {
+		words = append(words, l.integer(rest))
+	}
+	return strings.Join(words, " ")
+}
+
+// spell reads digits one by one.
+func (l *language) spell(digits string) string {
+	words := make([]string, len(digits))
+	for i := range len(digits) {
+		words[i] = l.cardinal(int64(digits[i] - '0'))
+	}
+	return strings.Join(words, " ")
+}
+
+func keepDigits(r rune) rune {
+	if r >= '0' && r <= '9' {
+		return r
+	}
+	return -1
+}
@@ -0,0 +1,271 @@
+package verbalize
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCardinal(t *testing.T) {
+	tests := []struct {
+		lang string
+		n    int64
+		want string
+	}{
+		{"en", 0, "zero"},
+		{"en", 13, "thirteen"},
+		{"en", 42, "forty-two"},
+		{"en", 100, "one hundred"},
+		{"en", 115, "one hundred fifteen"},
+		{"en", 1001, "one thousand one"},
+		{"en", 21_000_340, "twenty-one million three hundred forty"},
+		{"en", 2_000_000_000, "two billion"},
+		{"en", -7, "minus seven"},
+		{"en", 1_000_000_000_000, "one zero zero zero zero zero zero zero zero zero zero zero zero"},
+
+		{"de", 0, "null"},
+		{"de", 1, "eins"},
+		{"de", 17, "siebzehn"},
+		{"de", 21, "einundzwanzig"},
+		{"de", 30, "dreißig"},
+		{"de", 101, "einhunderteins"},
+		{"de", 1000, "eintausend"},
+		{"de", 1234, "eintausendzweihundertvierunddreißig"},
+		{"de", 101_000, "einhunderteintausend"},
+		{"de", 1_000_000, "eine Million"},
+		{"de", 2_500_001, "zwei Millionen fünfhunderttausendeins"},
+		{"de", 3_000_000_000, "drei Milliarden"},
+		{"de", -12, "minus zwölf"},
+
+		{"fr", 1, "un"},
+		{"fr", 17, "dix-sept"},
+		{"fr", 21, "vingt et un"},
+		{"fr", 22, "vingt-deux"},
+		{"fr", 70, "soixante-dix"},
+		{"fr", 71, "soixante et onze"},
+		{"fr", 77, "soixante-dix-sept"},
+		{"fr", 80, "quatre-vingts"},
+		{"fr", 81, "quatre-vingt-un"},
+		{"fr", 91, "quatre-vingt-onze"},
+		{"fr", 100, "cent"},
+		{"fr", 200, "deux cents"},
+		{"fr", 201, "deux cent un"},
+		{"fr", 1000, "mille"},
+		{"fr", 80_000, "quatre-vingt mille"},
+		{"fr", 200_000, "deux cent mille"},
+		{"fr", 1_000_000, "un million"},
+		{"fr", 80_000_000, "quatre-vingts millions"},
+		{"fr", 2_000_000_000, "deux milliards"},
+		{"fr", -3, "moins trois"},
+
+		{"es", 1, "uno"},
+		{"es", 16, "dieciséis"},
+		{"es", 21, "veintiuno"},
+		{"es", 22, "veintidós"},
+		{"es", 31, "treinta y uno"},
+		{"es", 100, "cien"},
+		{"es", 101, "ciento uno"},
+		{"es", 500, "quinientos"},
+		{"es", 1000, "mil"},
+		{"es", 21_000, "veintiún mil"},
+		{"es", 1_000_000, "un millón"},
+		{"es", 21_000_000, "veintiún millones"},
+		{"es", 1_000_000_000, "mil millones"},
+		{"es", 2_500_000_000, "dos mil quinientos millones"},
+		{"es", -4, "menos cuatro"},
+
+		{"pl", 1, "jeden"},
+		{"pl", 15, "piętnaście"},
+		{"pl", 22, "dwadzieścia dwa"},
+		{"pl", 200, "dwieście"},
+		{"pl", 1000, "tysiąc"},
+		{"pl", 2000, "dwa tysiące"},
+		{"pl", 5000, "pięć tysięcy"},
+		{"pl", 12_000, "dwanaście tysięcy"},
+		{"pl", 22_000, "dwadzieścia dwa tysiące"},
+		{"pl", 1_234, "tysiąc dwieście trzydzieści cztery"},
+		{"pl", 1_000_000, "milion"},
+		{"pl", 5_000_000, "pięć milionów"},
+		{"pl", 3_000_000_000, "trzy miliardy"},
+		{"pl", -1, "minus jeden"},
+
+		{"it", 42, "42"},
+	}
+
+	for _, tt := range tests {
+		if got := Cardinal(tt.n, tt.lang); got != tt.want {
+			t.Errorf("%s %d: expected %q, got %q", tt.lang, tt.n, tt.want, got)
+		}
+	}
+}
+
+func TestOrdinal(t *testing.T) {
+	tests := []struct {
+		lang string
+		n    int64
+		want string
+	}{
+		{"en", 1, "first"},
+		{"en", 2, "second"},
+		{"en", 3, "third"},
+		{"en", 5, "fifth"},
+		{"en", 12, "twelfth"},
+		{"en", 20, "twentieth"},
+		{"en", 21, "twenty-first"},
+		{"en", 100, "one hundredth"},
+		{"en", 1_000_000, "one millionth"},
+
+		{"de", 1, "erste"},
+		{"de", 3, "dritte"},
+		{"de", 7, "siebte"},
+		{"de", 8, "achte"},
+		{"de", 12, "zwölfte"},
+		{"de", 19, "neunzehnte"},
+		{"de", 20, "zwanzigste"},
+		{"de", 21, "einundzwanzigste"},
+		{"de", 101, "einhunderterste"},
+		{"de", 1000, "eintausendste"},
+
+		{"fr", 1, "premier"},
+		{"fr", 2, "deuxième"},
+		{"fr", 4, "quatrième"},
+		{"fr", 5, "cinquième"},
+		{"fr", 9, "neuvième"},
+		{"fr", 11, "onzième"},
+		{"fr", 21, "vingt et unième"},
+		{"fr", 80, "quatre-vingtième"},
+		{"fr", 200, "deux centième"},
+		{"fr", 1000, "millième"},
+
+		{"es", 1, "primero"},
+		{"es", 3, "tercero"},
+		{"es", 10, "décimo"},
+		{"es", 11, "undécimo"},
+		{"es", 18, "decimoctavo"},
+		{"es", 21, "vigésimo primero"},
+		{"es", 100, "centésimo"},
+		{"es", 342, "tricentésimo cuadragésimo segundo"},
+		{"es", 1000, "milésimo"},
+		{"es", 1001, "mil uno"},
+
+		{"pl", 1, "pierwszy"},
+		{"pl", 2, "drugi"},
+		{"pl", 21, "dwudziesty pierwszy"},
+		{"pl", 100, "setny"},
+		{"pl", 123, "sto dwudziesty trzeci"},
+		{"pl", 2000, "dwutysięczny"},
+		{"pl", 2024, "dwa tysiące dwudziesty czwarty"},
+
+		{"en", 0, "zero"},
+		{"it", 3, "3"},
+	}
+
+	for _, tt := range tests {
+		if got := Ordinal(tt.n, tt.lang); got != tt.want {
+			t.Errorf("%s %d: expected %q, got %q", tt.lang, tt.n, tt.want, got)
+		}
+	}
+}
+
+func TestVerbalize(t *testing.T) {
+	tests := []struct {
+		lang string
+		text string
+		want string
+	}{
+		// Numbers
+		{"en", "It has 1,234 pages.", "It has one thousand two hundred thirty-four pages."},
+		{"en", "Pi is 3.14", "Pi is three point one four"},
+		{"en", "It was -5 outside", "It was minus five outside"},
+		{"en", "Pages 10-12", "Pages ten-twelve"},
+		{"en", "Agent 007", "Agent zero zero seven"},
+		{"de", "Es kamen 1.500 Gäste", "Es kamen eintausendfünfhundert Gäste"},
+		{"de", "Es sind 3,75 Meter", "Es sind drei Komma sieben fünf Meter"},
+		{"fr", "Il y a 1 000 000 habitants", "Il y a un million habitants"},
+		{"fr", "Il fait 3,05 degrés", "Il fait trois virgule zéro cinq degrés"},
+		{"es", "Hay 1.000.000 personas", "Hay un millón personas"},
+		{"es", "Mide 2,5 metros", "Mide dos coma cinco metros"},
+		{"pl", "Jest 21 000 osób", "Jest dwadzieścia jeden tysięcy osób"},
+		{"pl", "To 3,14", "To trzy przecinek czternaście"},
+
+		// Ordinals
+		{"en", "the 21st century", "the twenty-first century"},
+		{"en", "the 2nd and 3rd floor", "the second and third floor"},
+		{"fr", "au 2e étage", "au deuxième étage"},
+		{"fr", "le 1er et la 1re fois", "le premier et la première fois"},
+		{"es", "el 3.º piso y la 21ª vez", "el tercero piso y la vigésima primera vez"},
+
+		// Dates
+		{"en", "on 2024-03-15", "on March fifteenth, twenty twenty-four"},
+		{"en", "on 03/15/2024", "on March fifteenth, twenty twenty-four"},
+		{"en", "on March 1st, 1905", "on March first, nineteen oh five"},
+		{"en", "from May 3 to 2006-01-02", "from May third to January second, two thousand six"},
+		{"de", "Stand: 15.03.2024", "Stand: fünfzehnter März zweitausendvierundzwanzig"},
+		{"de", "am 1. Mai 1999", "am ersten Mai neunzehnhundertneunundneunzig"},
+		{"de", "Vom 3. Oktober an", "Vom dritten Oktober an"},
+		{"fr", "le 15/03/2024", "le quinze mars deux mille vingt-quatre"},
+		{"fr", "le 2024-03-01", "le premier mars deux mille vingt-quatre"},
+		{"es", "el 15/03/2024", "el quince de marzo de dos mil veinticuatro"},
+		{"pl", "15.03.2024", "piętnastego marca dwa tysiące dwudziestego czwartego roku"},
+		{"pl", "dnia 3 maja 2000 r.", "dnia trzeciego maja dwutysięcznego roku"},
+		{"pl", "1 stycznia", "pierwszego stycznia"},
+
+		// Times of day
+		{"en", "at 9:05 and 14:30", "at nine oh five and fourteen thirty"},
+		{"en", "at 7:00", "at seven o'clock"},
+		{"de", "um 1:15 oder 21:00", "um ein Uhr fünfzehn oder einundzwanzig Uhr"},
+		{"fr", "à 14h30, 1h ou 21:01", "à quatorze heures trente, une heure ou vingt et une heures une"},
+		{"es", "a las 1:00 o 14:30", "a las una en punto o catorce y treinta"},
+		{"pl", "o 14:30 lub 21:07", "o czternasta trzydzieści lub dwudziesta pierwsza zero siedem"},
+
+		// Money
+		{"en", "It costs $12.50", "It costs twelve dollars and fifty cents"},
+		{"en", "£1 or 0.99 €", "one pound or ninety-nine cents"},
+		{"en", "$2,000", "two thousand dollars"},
+		{"en", "$0.5 a piece", "fifty cents a piece"},
+		{"en", "at $1.005 a share", "at one point zero zero five dollars a share"},
+		{"de", "Preis: 12,50 €", "Preis: zwölf Euro und fünfzig Cent"},
+		{"de", "nur 1 $", "nur ein Dollar"},
+		{"de", "101 EUR", "einhundertein Euro"},
+		{"fr", "21 £ et 1 000 000 €", "vingt et une livres et un million d'euros"},
+		{"fr", "12,50 €", "douze euros et cinquante centimes"},
+		{"es", "21,50 € y 21 £", "veintiún euros con cincuenta céntimos y veintiuna libras"},
+		{"es", "1.000.000 $", "un millón de dólares"},
+		{"pl", "22,50 zł", "dwadzieścia dwa złote pięćdziesiąt groszy"},
+		{"pl", "1 € i 5 $", "jedno euro i pięć dolarów"},
+		{"pl", "12 PLN", "dwanaście złotych"},
+
+		// Left alone
+		{"en", "MP3 and COVID-19", "MP3 and COVID-nineteen"},
+		{"en", "version 1.2.3", "version 1.2.3"},
+		{"en", "at 12:30:45", "at 12:30:45"},
+		{"en", "2024-03-15T10:00", "2024-03-15T10:00"},
+		{"en", "3D printing", "3D printing"},
+		{"en", "no digits", "no digits"},
+
+		// Rejected dates and times stay as written
+		{"en", "at 25:00", "at 25:00"},
+		{"de", "am 31.02.2024", "am 31.02.2024"},
+		{"fr", "vers 14: environ", "vers quatorze: environ"},
+
+		// Language tags
+		{"en-GB", "3 cats", "three cats"},
+		{"PT", "3 gatos", "3 gatos"},
+	}
+
+	for _, tt := range tests {
+		if got := Verbalize(tt.text, tt.lang); got != tt.want {
+			t.Errorf("%s %q: expected %q, got %q", tt.lang, tt.text, tt.want, got)
+		}
+	}
+}
+
+func TestSupported(t *testing.T) {
+	if want := []string{"de", "en", "es", "fr", "pl"}; !slices.Equal(Languages(), want) {
+		t.Errorf("expected languages %v, got %v", want, Languages())
+	}
+	for lang, want := range map[string]bool{"de": true, "de-AT": true, "pl_PL": true, "EN": true, "it": false, "": false} {
+		if got := Supported(lang); got != want {
+			t.Errorf("Supported(%q): expected %v, got %v", lang, want, got)
+		}
+	}
+}
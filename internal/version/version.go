@@ -0,0 +1,45 @@
+// Package version describes the running server's build. Release builds set it
+// at link time:
+//
+//	go build -ldflags "-X github.com/pako-tts/server/internal/version.Version=v1.4.0 \
+//		-X github.com/pako-tts/server/internal/version.Commit=$(git rev-parse HEAD)" ./cmd/server
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Set with -ldflags -X; Commit and Date fall back to the VCS stamp Go embeds in
+// builds from a checkout.
+var (
+	Version = "dev"
+	Commit  = ""
+	Date    = ""
+)
+
+// Info is the build of the running server.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"date,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build of the running server.
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, Date: Date, GoVersion: runtime.Version()}
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, s := range build.Settings {
+		switch {
+		case s.Key == "vcs.revision" && info.Commit == "":
+			info.Commit = s.Value
+		case s.Key == "vcs.time" && info.Date == "":
+			info.Date = s.Value
+		}
+	}
+	return info
+}
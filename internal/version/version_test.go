@@ -0,0 +1,13 @@
+package version
+
+import "testing"
+
+func TestGet(t *testing.T) {
+	defer func(v, c string) { Version, Commit = v, c }(Version, Commit)
+	Version, Commit = "v1.4.0", "abc123"
+
+	info := Get()
+	if info.Version != "v1.4.0" || info.Commit != "abc123" || info.GoVersion == "" {
+		t.Errorf("expected the linked version and commit, got %+v", info)
+	}
+}
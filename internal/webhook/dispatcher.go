@@ -0,0 +1,417 @@
+// Package webhook delivers job, batch and quota events to the webhook endpoints
+// tenants register, and keeps a log of each delivery. It also notifies the
+// callback URL a job was submitted with once the job finishes.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/deadline"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/safehttp"
+)
+
+// Headers sent with every delivery.
+const (
+	EventHeader    = "X-Pako-Event"
+	DeliveryHeader = "X-Pako-Delivery"
+	// SignatureHeader carries the delivery's signature when a secret is
+	// configured: "t=<unix seconds>,v1=<hex HMAC-SHA256>" (see Sign).
+	SignatureHeader = "X-Pako-Signature"
+)
+
+// DefaultTimeout bounds each delivery when no timeout is configured.
+const DefaultTimeout = 10 * time.Second
+
+// batchMemory is how long a batch reported complete is remembered, so it is
+// reported once.
+const batchMemory = 24 * time.Hour
+
+// defaultRetryDelays are the waits before the second and third delivery attempts.
+var defaultRetryDelays = []time.Duration{5 * time.Second, 30 * time.Second}
+
+// Job callbacks are attempted up to callbackAttempts times, waiting
+// defaultCallbackDelay before the first retry and twice as long before each
+// further one: about two and a half minutes in all.
+const (
+	callbackAttempts     = 6
+	defaultCallbackDelay = 5 * time.Second
+)
+
+// JobEventData is the data of job.completed and job.failed events.
+type JobEventData struct {
+	JobID          string     `json:"job_id"`
+	Status         string     `json:"status"`
+	BatchID        string     `json:"batch_id,omitempty"`
+	VoiceID        string     `json:"voice_id"`
+	Provider       string     `json:"provider"`
+	ResultProvider string     `json:"result_provider,omitempty"`
+	OutputFormat   string     `json:"output_format"`
+	ResultURL      string     `json:"result_url,omitempty"`
+	AudioSeconds   float64    `json:"audio_seconds,omitempty"`
+	ErrorCode      string     `json:"error_code,omitempty"`
+	ErrorMessage   string     `json:"error_message,omitempty"`
+	CompletedAt    *time.Time `json:"completed_at,omitempty"`
+}
+
+// BatchEventData is the data of batch.completed events.
+type BatchEventData struct {
+	BatchID   string `json:"batch_id"`
+	Total     int    `json:"total"`
+	Completed int    `json:"completed"`
+	Failed    int    `json:"failed"`
+	Cancelled int    `json:"cancelled"`
+}
+
+// QuotaEventData is the data of quota.warning events.
+type QuotaEventData struct {
+	Provider  string  `json:"provider"`
+	CharsUsed int64   `json:"chars_used"`
+	CharQuota int64   `json:"char_quota"`
+	UsedRatio float64 `json:"used_ratio"`
+}
+
+// KeyFlaggedEventData is the data of key.flagged events.
+type KeyFlaggedEventData struct {
+	Reason string `json:"reason"`
+	Detail string `json:"detail"`
+	Action string `json:"action"`
+}
+
+// Dispatcher publishes events to subscribed webhooks.
+type Dispatcher struct {
+	store        domain.WebhookStore
+	jobs         domain.JobQueue
+	logger       *zap.Logger
+	client       *http.Client
+	allowedHosts []string
+	retryDelays  []time.Duration
+	// secret signs deliveries; empty sends them unsigned.
+	secret        string
+	callbackDelay time.Duration
+
+	mu      sync.Mutex
+	batches map[string]time.Time // batches reported complete
+	wg      sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher. jobs is read to tell when a batch has
+// finished. allowedHosts restricts webhook and callback URLs to these hosts; an
+// entry starting with "." also matches its subdomains. Empty allows any host.
+// Deliveries only connect to public addresses, and follow redirects to allowed
+// hosts only. A non-empty secret signs every delivery.
+func NewDispatcher(store domain.WebhookStore, jobs domain.JobQueue, logger *zap.Logger, timeout time.Duration, allowedHosts []string, secret string) *Dispatcher {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	d := &Dispatcher{
+		store:         store,
+		jobs:          jobs,
+		logger:        logger,
+		allowedHosts:  allowedHosts,
+		retryDelays:   defaultRetryDelays,
+		secret:        secret,
+		callbackDelay: defaultCallbackDelay,
+		batches:       make(map[string]time.Time),
+	}
+	d.client = safehttp.NewClient(timeout, d.hostAllowed)
+	return d
+}
+
+// ValidateURL checks that rawURL can be registered as a webhook endpoint.
+func (d *Dispatcher) ValidateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	if !d.hostAllowed(u.Hostname()) {
+		return fmt.Errorf("host %q is not allowed", u.Hostname())
+	}
+	return nil
+}
+
+func (d *Dispatcher) hostAllowed(host string) bool {
+	if len(d.allowedHosts) == 0 {
+		return true
+	}
+	host = strings.ToLower(host)
+	for _, allowed := range d.allowedHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || strings.HasPrefix(allowed, ".") && (strings.HasSuffix(host, allowed) || host == allowed[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewEvent creates an event of eventType for tenant; an empty tenant makes it
+// service-wide.
+func NewEvent(eventType, tenant string, data any) domain.WebhookEvent {
+	return domain.WebhookEvent{
+		ID:        uuid.New().String(),
+		Type:      eventType,
+		Tenant:    tenant,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+}
+
+// Publish delivers event in the background to every webhook subscribed to its
+// type: the webhooks of the event's tenant, or of every tenant for a service-wide
+// event. A failed delivery is attempted up to twice more.
+func (d *Dispatcher) Publish(ctx context.Context, event domain.WebhookEvent) {
+	hooks, err := d.store.ListWebhooks(ctx, event.Tenant)
+	if err != nil {
+		d.logger.Error("Failed to list webhooks", zap.Error(err), zap.String("event", event.Type))
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	for _, hook := range hooks {
+		if !hook.Subscribed(event.Type) {
+			continue
+		}
+		d.wg.Add(1)
+		go func(hook *domain.Webhook) {
+			defer d.wg.Done()
+			d.deliverWithRetry(ctx, hook, event)
+		}(hook)
+	}
+}
+
+func (d *Dispatcher) deliverWithRetry(ctx context.Context, hook *domain.Webhook, event domain.WebhookEvent) {
+	for attempt := 1; ; attempt++ {
+		if d.Deliver(ctx, hook, event, attempt).Success || attempt > len(d.retryDelays) {
+			return
+		}
+		time.Sleep(d.retryDelays[attempt-1])
+	}
+}
+
+// Deliver POSTs event to the webhook once and records the attempt in its log.
+func (d *Dispatcher) Deliver(ctx context.Context, hook *domain.Webhook, event domain.WebhookEvent, attempt int) *domain.WebhookDelivery {
+	delivery := &domain.WebhookDelivery{
+		ID:        uuid.New().String(),
+		WebhookID: hook.ID,
+		EventID:   event.ID,
+		EventType: event.Type,
+		Attempt:   attempt,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	start := time.Now()
+	status, err := d.post(ctx, hook.URL, delivery.ID, event)
+	delivery.DurationMs = time.Since(start).Milliseconds()
+	delivery.StatusCode = status
+	switch {
+	case err != nil:
+		delivery.Error = err.Error()
+	case status < 200 || status > 299:
+		delivery.Error = fmt.Sprintf("endpoint answered %d", status)
+	default:
+		delivery.Success = true
+	}
+
+	if err := d.store.RecordDelivery(ctx, delivery); err != nil && !errors.Is(err, domain.ErrWebhookNotFound) {
+		d.logger.Warn("Failed to record webhook delivery", zap.Error(err), zap.String("webhook_id", hook.ID))
+	}
+	if !delivery.Success {
+		d.logger.Warn("Webhook delivery failed",
+			zap.String("webhook_id", hook.ID),
+			zap.String("event", event.Type),
+			zap.Int("attempt", attempt),
+			zap.String("error", delivery.Error),
+		)
+	}
+	return delivery
+}
+
+func (d *Dispatcher) post(ctx context.Context, url, deliveryID string, event domain.WebhookEvent) (int, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return 0, err
+	}
+	// The client's timeout is the receiver's deadline
+	ctx, cancel := context.WithTimeout(ctx, d.client.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pako-tts-webhook")
+	req.Header.Set(EventHeader, event.Type)
+	req.Header.Set(DeliveryHeader, deliveryID)
+	deadline.Set(req)
+	if d.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(d.secret, time.Now().Unix(), body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()                                //nolint:errcheck
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) //nolint:errcheck
+	return resp.StatusCode, nil
+}
+
+// Sign returns the signature header value of a delivery of body made at
+// timestamp: the hex HMAC-SHA256, keyed with secret, of "<timestamp>.<body>".
+// Receivers recompute it to check that a delivery came from this server, and
+// compare the timestamp with their clock to reject replays.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + ".")) //nolint:errcheck // never fails
+	mac.Write(body)                                           //nolint:errcheck // never fails
+	return "t=" + strconv.FormatInt(timestamp, 10) + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// JobFinished publishes job.completed or job.failed for a finished job, and
+// batch.completed when it was the last job of its batch to finish. The job's
+// callback URL, if it has one, gets the job event too.
+func (d *Dispatcher) JobFinished(ctx context.Context, job *domain.Job) {
+	var event domain.WebhookEvent
+	switch job.Status {
+	case domain.JobStatusCompleted:
+		event = NewEvent(domain.WebhookEventJobCompleted, job.Tenant(), NewJobEventData(job))
+	case domain.JobStatusFailed:
+		event = NewEvent(domain.WebhookEventJobFailed, job.Tenant(), NewJobEventData(job))
+	}
+	if event.Type != "" {
+		d.Publish(ctx, event)
+		if job.CallbackURL != "" {
+			d.callback(ctx, job, event)
+		}
+	}
+	if job.BatchID != "" && job.IsComplete() {
+		d.batchProgress(ctx, job)
+	}
+}
+
+// callback delivers event to the job's callback URL in the background. A failed
+// attempt is retried with exponential backoff, up to callbackAttempts in all.
+func (d *Dispatcher) callback(ctx context.Context, job *domain.Job, event domain.WebhookEvent) {
+	ctx = context.WithoutCancel(ctx)
+	logger := d.logger.With(zap.String("job_id", job.ID), zap.String("event", event.Type))
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		delay := d.callbackDelay
+		for attempt := 1; ; attempt++ {
+			status, err := d.post(ctx, job.CallbackURL, uuid.New().String(), event)
+			if err == nil && status >= 200 && status <= 299 {
+				return
+			}
+			if err == nil {
+				err = fmt.Errorf("endpoint answered %d", status)
+			}
+			if attempt >= callbackAttempts {
+				logger.Error("Job callback failed; giving up", zap.Int("attempts", attempt), zap.Error(err))
+				return
+			}
+			logger.Warn("Job callback failed", zap.Int("attempt", attempt), zap.Duration("retry_in", delay), zap.Error(err))
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}()
+}
+
+// NewJobEventData returns the data of job.completed and job.failed events for job.
+func NewJobEventData(job *domain.Job) JobEventData {
+	data := JobEventData{
+		JobID:          job.ID,
+		Status:         string(job.Status),
+		BatchID:        job.BatchID,
+		VoiceID:        job.VoiceID,
+		Provider:       job.ProviderName,
+		ResultProvider: job.ResultProvider,
+		OutputFormat:   job.OutputFormat,
+		AudioSeconds:   job.AudioSeconds,
+		ErrorCode:      job.ErrorCode,
+		ErrorMessage:   job.ErrorMessage,
+		CompletedAt:    job.CompletedAt,
+	}
+	if job.Status == domain.JobStatusCompleted {
+		data.ResultURL = "/api/v1/jobs/" + job.ID + "/result"
+	}
+	return data
+}
+
+// batchProgress publishes batch.completed once none of the batch's jobs is
+// queued or processing.
+func (d *Dispatcher) batchProgress(ctx context.Context, job *domain.Job) {
+	page, err := d.jobs.ListJobs(ctx, domain.JobFilter{BatchID: job.BatchID, Tenant: job.Tenant()})
+	if err != nil {
+		d.logger.Warn("Failed to list batch jobs", zap.Error(err), zap.String("batch_id", job.BatchID))
+		return
+	}
+	data := BatchEventData{BatchID: job.BatchID, Total: len(page.Jobs)}
+	for _, j := range page.Jobs {
+		switch j.Status {
+		case domain.JobStatusCompleted, domain.JobStatusExpired:
+			data.Completed++
+		case domain.JobStatusFailed:
+			data.Failed++
+		case domain.JobStatusCancelled:
+			data.Cancelled++
+		default:
+			return
+		}
+	}
+
+	d.mu.Lock()
+	now := time.Now()
+	for id, at := range d.batches {
+		if now.Sub(at) > batchMemory {
+			delete(d.batches, id)
+		}
+	}
+	_, reported := d.batches[job.BatchID]
+	d.batches[job.BatchID] = now
+	d.mu.Unlock()
+	if !reported {
+		d.Publish(ctx, NewEvent(domain.WebhookEventBatchCompleted, job.Tenant(), data))
+	}
+}
+
+// QuotaWarning publishes a service-wide quota.warning for a provider.
+func (d *Dispatcher) QuotaWarning(provider string, used, quota int64) {
+	d.Publish(context.Background(), NewEvent(domain.WebhookEventQuotaWarning, "", QuotaEventData{
+		Provider:  provider,
+		CharsUsed: used,
+		CharQuota: quota,
+		UsedRatio: float64(used) / float64(quota),
+	}))
+}
+
+// KeyFlagged publishes key.flagged to the tenant whose API key was flagged.
+func (d *Dispatcher) KeyFlagged(tenant, reason, detail, action string) {
+	d.Publish(context.Background(), NewEvent(domain.WebhookEventKeyFlagged, tenant, KeyFlaggedEventData{
+		Reason: reason,
+		Detail: detail,
+		Action: action,
+	}))
+}
+
+// Wait blocks until the deliveries in progress have finished.
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
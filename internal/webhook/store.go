@@ -0,0 +1,126 @@
+package webhook
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/pako-tts/server/internal/domain"
+)
+
+// maxDeliveries is how many deliveries the log keeps per webhook.
+const maxDeliveries = 100
+
+// MemoryStore is an in-memory domain.WebhookStore. Webhooks are lost on restart.
+type MemoryStore struct {
+	mu         sync.RWMutex
+	hooks      map[string]*domain.Webhook
+	deliveries map[string][]*domain.WebhookDelivery
+}
+
+// NewMemoryStore creates an empty store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		hooks:      make(map[string]*domain.Webhook),
+		deliveries: make(map[string][]*domain.WebhookDelivery),
+	}
+}
+
+// CreateWebhook implements domain.WebhookStore.
+func (s *MemoryStore) CreateWebhook(ctx context.Context, hook *domain.Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks[hook.ID] = clone(hook)
+	return nil
+}
+
+// GetWebhook implements domain.WebhookStore.
+func (s *MemoryStore) GetWebhook(ctx context.Context, id string) (*domain.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	hook, ok := s.hooks[id]
+	if !ok {
+		return nil, domain.ErrWebhookNotFound
+	}
+	return clone(hook), nil
+}
+
+// ListWebhooks implements domain.WebhookStore.
+func (s *MemoryStore) ListWebhooks(ctx context.Context, tenant string) ([]*domain.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	hooks := make([]*domain.Webhook, 0)
+	for _, hook := range s.hooks {
+		if tenant == "" || hook.Tenant == tenant {
+			hooks = append(hooks, clone(hook))
+		}
+	}
+	sort.Slice(hooks, func(i, j int) bool {
+		if !hooks[i].CreatedAt.Equal(hooks[j].CreatedAt) {
+			return hooks[i].CreatedAt.Before(hooks[j].CreatedAt)
+		}
+		return hooks[i].ID < hooks[j].ID
+	})
+	return hooks, nil
+}
+
+// UpdateWebhook implements domain.WebhookStore.
+func (s *MemoryStore) UpdateWebhook(ctx context.Context, hook *domain.Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.hooks[hook.ID]; !ok {
+		return domain.ErrWebhookNotFound
+	}
+	s.hooks[hook.ID] = clone(hook)
+	return nil
+}
+
+// DeleteWebhook implements domain.WebhookStore.
+func (s *MemoryStore) DeleteWebhook(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.hooks[id]; !ok {
+		return domain.ErrWebhookNotFound
+	}
+	delete(s.hooks, id)
+	delete(s.deliveries, id)
+	return nil
+}
+
+// RecordDelivery implements domain.WebhookStore. Only the latest deliveries of
+// each webhook are kept.
+func (s *MemoryStore) RecordDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.hooks[delivery.WebhookID]; !ok {
+		return domain.ErrWebhookNotFound
+	}
+	log := append(s.deliveries[delivery.WebhookID], delivery)
+	if len(log) > maxDeliveries {
+		log = log[len(log)-maxDeliveries:]
+	}
+	s.deliveries[delivery.WebhookID] = log
+	return nil
+}
+
+// ListDeliveries implements domain.WebhookStore.
+func (s *MemoryStore) ListDeliveries(ctx context.Context, webhookID string, limit int) ([]*domain.WebhookDelivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	log := s.deliveries[webhookID]
+	if limit <= 0 || limit > len(log) {
+		limit = len(log)
+	}
+	out := make([]*domain.WebhookDelivery, 0, limit)
+	for i := len(log) - 1; i >= 0 && len(out) < limit; i-- {
+		d := *log[i]
+		out = append(out, &d)
+	}
+	return out, nil
+}
+
+func clone(hook *domain.Webhook) *domain.Webhook {
+	c := *hook
+	c.Events = append([]string(nil), hook.Events...)
+	return &c
+}
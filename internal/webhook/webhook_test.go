@@ -0,0 +1,272 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pako-tts/server/internal/deadline"
+	"github.com/pako-tts/server/internal/domain"
+	"github.com/pako-tts/server/internal/queue/memory"
+)
+
+// receiver records the events POSTed to it and answers with status.
+type receiver struct {
+	mu     sync.Mutex
+	events []domain.WebhookEvent
+	status int
+	server *httptest.Server
+}
+
+func newReceiver(t *testing.T, status int) *receiver {
+	rcv := &receiver{status: status}
+	rcv.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event domain.WebhookEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("decode event: %v", err)
+		}
+		if r.Header.Get(EventHeader) != event.Type || r.Header.Get(DeliveryHeader) == "" {
+			t.Errorf("unexpected headers %v for event %q", r.Header, event.Type)
+		}
+		rcv.mu.Lock()
+		rcv.events = append(rcv.events, event)
+		rcv.mu.Unlock()
+		w.WriteHeader(rcv.status)
+	}))
+	t.Cleanup(rcv.server.Close)
+	return rcv
+}
+
+func (rcv *receiver) types() []string {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	types := make([]string, 0, len(rcv.events))
+	for _, e := range rcv.events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func newTestDispatcher(store domain.WebhookStore, jobs domain.JobQueue) *Dispatcher {
+	d := NewDispatcher(store, jobs, zap.NewNop(), time.Second, nil, "")
+	d.retryDelays = []time.Duration{0, 0}
+	// Test receivers listen on loopback, which the dispatcher's client refuses
+	d.client = &http.Client{Timeout: time.Second}
+	return d
+}
+
+func register(t *testing.T, store *MemoryStore, tenant, url string, events ...string) *domain.Webhook {
+	t.Helper()
+	hook := &domain.Webhook{ID: tenant + "-" + events[0], Tenant: tenant, URL: url, Events: events, Active: true, CreatedAt: time.Now()}
+	if err := store.CreateWebhook(context.Background(), hook); err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+	return hook
+}
+
+func TestDispatcher_PublishFiltersByTenantAndEvent(t *testing.T) {
+	store := NewMemoryStore()
+	d := newTestDispatcher(store, memory.NewQueue(10))
+	ctx := context.Background()
+
+	completed := newReceiver(t, http.StatusOK)
+	failed := newReceiver(t, http.StatusNoContent)
+	other := newReceiver(t, http.StatusOK)
+	register(t, store, "acme", completed.server.URL, domain.WebhookEventJobCompleted, domain.WebhookEventQuotaWarning)
+	register(t, store, "acme", failed.server.URL, domain.WebhookEventJobFailed)
+	register(t, store, "globex", other.server.URL, domain.WebhookEventJobCompleted)
+
+	d.Publish(ctx, NewEvent(domain.WebhookEventJobCompleted, "acme", nil))
+	d.QuotaWarning("elevenlabs", 800, 1000)
+	d.Wait()
+
+	if got := completed.types(); len(got) != 2 {
+		t.Errorf("expected job.completed and quota.warning, got %v", got)
+	}
+	if got := failed.types(); len(got) != 0 {
+		t.Errorf("expected no events for a job.failed webhook, got %v", got)
+	}
+	if got := other.types(); len(got) != 0 {
+		t.Errorf("expected no events for another tenant, got %v", got)
+	}
+}
+
+func TestDispatcher_RetriesAndLogsFailedDeliveries(t *testing.T) {
+	store := NewMemoryStore()
+	d := newTestDispatcher(store, memory.NewQueue(10))
+	ctx := context.Background()
+
+	rcv := newReceiver(t, http.StatusInternalServerError)
+	hook := register(t, store, "acme", rcv.server.URL, domain.WebhookEventJobFailed)
+
+	d.Publish(ctx, NewEvent(domain.WebhookEventJobFailed, "acme", nil))
+	d.Wait()
+
+	if got := rcv.types(); len(got) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(got))
+	}
+	deliveries, err := store.ListDeliveries(ctx, hook.ID, 2)
+	if err != nil {
+		t.Fatalf("ListDeliveries: %v", err)
+	}
+	if len(deliveries) != 2 || deliveries[0].Attempt != 3 || deliveries[1].Attempt != 2 {
+		t.Fatalf("expected the last two attempts newest first, got %+v", deliveries)
+	}
+	if d := deliveries[0]; d.Success || d.StatusCode != http.StatusInternalServerError || d.Error == "" {
+		t.Errorf("expected a failed delivery, got %+v", d)
+	}
+}
+
+func TestDispatcher_RefusesPrivateAddresses(t *testing.T) {
+	store := NewMemoryStore()
+	d := NewDispatcher(store, memory.NewQueue(10), zap.NewNop(), time.Second, nil, "")
+	d.retryDelays = []time.Duration{0, 0}
+	ctx := context.Background()
+
+	rcv := newReceiver(t, http.StatusOK)
+	hook := register(t, store, "acme", rcv.server.URL, domain.WebhookEventJobFailed)
+	d.Publish(ctx, NewEvent(domain.WebhookEventJobFailed, "acme", nil))
+	d.Wait()
+
+	if got := rcv.types(); len(got) != 0 {
+		t.Fatalf("expected no delivery to reach loopback, got %v", got)
+	}
+	deliveries, _ := store.ListDeliveries(ctx, hook.ID, 1)
+	if len(deliveries) != 1 || !strings.Contains(deliveries[0].Error, "address is not public") {
+		t.Errorf("expected a refused delivery, got %+v", deliveries)
+	}
+}
+
+func TestDispatcher_BatchCompletedOnce(t *testing.T) {
+	store := NewMemoryStore()
+	queue := memory.NewQueue(10)
+	d := newTestDispatcher(store, queue)
+	ctx := context.Background()
+
+	rcv := newReceiver(t, http.StatusOK)
+	register(t, store, "acme", rcv.server.URL, domain.WebhookEventBatchCompleted)
+
+	jobs := make([]*domain.Job, 2)
+	for i := range jobs {
+		jobs[i] = domain.NewJob("Hello", "voice", "", "", "elevenlabs", "mp3", nil)
+		jobs[i].TenantID = "acme"
+		jobs[i].BatchID = "batch-1"
+		if err := queue.Enqueue(ctx, jobs[i]); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+
+	jobs[0].SetCompleted("/audio/0.mp3", 24)
+	d.JobFinished(ctx, jobs[0])
+	d.Wait()
+	if got := rcv.types(); len(got) != 0 {
+		t.Fatalf("expected no event while a batch job is queued, got %v", got)
+	}
+
+	jobs[1].SetFailed("boom")
+	d.JobFinished(ctx, jobs[1])
+	d.JobFinished(ctx, jobs[1])
+	d.Wait()
+	if got := rcv.types(); len(got) != 1 {
+		t.Fatalf("expected one batch.completed event, got %v", got)
+	}
+	data := rcv.events[0].Data.(map[string]any)
+	if data["total"] != 2.0 || data["completed"] != 1.0 || data["failed"] != 1.0 {
+		t.Errorf("unexpected batch data %v", data)
+	}
+}
+
+func TestDispatcher_JobCallbackSignedAndRetried(t *testing.T) {
+	const secret = "s3cret"
+	var mu sync.Mutex
+	var attempts int
+	var event domain.WebhookEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var ts int64
+		fmt.Sscanf(r.Header.Get(SignatureHeader), "t=%d,", &ts) //nolint:errcheck
+		if got := r.Header.Get(SignatureHeader); got != Sign(secret, ts, body) {
+			t.Errorf("signature %q does not verify", got)
+		}
+		if got, err := deadline.Parse(r.Header.Get(deadline.Header)); err != nil || time.Until(got) > time.Second {
+			t.Errorf("expected a deadline within the client timeout, got %q", r.Header.Get(deadline.Header))
+		}
+		json.Unmarshal(body, &event) //nolint:errcheck
+	}))
+	t.Cleanup(server.Close)
+
+	d := NewDispatcher(NewMemoryStore(), memory.NewQueue(10), zap.NewNop(), time.Second, nil, secret)
+	d.callbackDelay = 0
+	d.client = server.Client()
+	d.client.Timeout = time.Second
+	job := domain.NewJob("hi", "v", "", "", "p", "mp3", nil)
+	job.CallbackURL = server.URL
+	job.SetCompleted("/tmp/x.mp3", 24)
+	d.JobFinished(context.Background(), job)
+	d.Wait()
+
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", attempts)
+	}
+	data := event.Data.(map[string]any)
+	if event.Type != domain.WebhookEventJobCompleted || data["job_id"] != job.ID || data["result_url"] != "/api/v1/jobs/"+job.ID+"/result" {
+		t.Errorf("unexpected callback event %+v", event)
+	}
+}
+
+func TestDispatcher_ValidateURL(t *testing.T) {
+	d := NewDispatcher(NewMemoryStore(), nil, zap.NewNop(), 0, []string{"hooks.example.com", ".example.org"}, "")
+	for url, valid := range map[string]bool{
+		"https://hooks.example.com/tts":  true,
+		"http://api.example.org/hook":    true,
+		"https://example.org/hook":       true,
+		"https://evil.example.com/hook":  false,
+		"ftp://hooks.example.com/hook":   false,
+		"/relative/hook":                 false,
+		"https://notexample.org/webhook": false,
+	} {
+		if err := d.ValidateURL(url); (err == nil) != valid {
+			t.Errorf("%s: expected valid=%v, got error %v", url, valid, err)
+		}
+	}
+}
+
+func TestMemoryStore_DeleteRemovesDeliveries(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	hook := register(t, store, "acme", "https://example.com", domain.WebhookEventJobCompleted)
+
+	for i := 0; i < maxDeliveries+5; i++ {
+		store.RecordDelivery(ctx, &domain.WebhookDelivery{WebhookID: hook.ID, Attempt: i}) //nolint:errcheck
+	}
+	deliveries, _ := store.ListDeliveries(ctx, hook.ID, 0)
+	if len(deliveries) != maxDeliveries || deliveries[0].Attempt != maxDeliveries+4 {
+		t.Fatalf("expected the latest %d deliveries, got %d", maxDeliveries, len(deliveries))
+	}
+
+	if err := store.DeleteWebhook(ctx, hook.ID); err != nil {
+		t.Fatalf("DeleteWebhook: %v", err)
+	}
+	if _, err := store.GetWebhook(ctx, hook.ID); err != domain.ErrWebhookNotFound {
+		t.Errorf("expected ErrWebhookNotFound, got %v", err)
+	}
+	if err := store.RecordDelivery(ctx, &domain.WebhookDelivery{WebhookID: hook.ID}); err != domain.ErrWebhookNotFound {
+		t.Errorf("expected ErrWebhookNotFound for a deleted webhook, got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// awsCredentialSlack renews temporary credentials this long before they expire.
+const awsCredentialSlack = 5 * time.Minute
+
+// awsSource reads one AWS Secrets Manager secret. Credentials are found the way
+// the AWS SDK does, from the environment, the shared config files or the role
+// the server runs as, and are renewed before they expire.
+type awsSource struct {
+	client   *secretsmanager.Client
+	secretID string
+}
+
+func newAWSSource(cfg AWSSecretsConfig) (*awsSource, error) {
+	if cfg.Region == "" {
+		return nil, fmt.Errorf("secrets.aws.region (or AWS_REGION) is required")
+	}
+	if cfg.SecretID == "" {
+		return nil, fmt.Errorf("secrets.aws.secret_id is required")
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(),
+		awsconfig.WithRegion(cfg.Region),
+		awsconfig.WithCredentialsCacheOptions(func(o *aws.CredentialsCacheOptions) {
+			o.ExpiryWindow = awsCredentialSlack
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	client := secretsmanager.NewFromConfig(awsCfg, func(o *secretsmanager.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+	})
+	return &awsSource{client: client, secretID: cfg.SecretID}, nil
+}
+
+// FetchSecrets calls GetSecretValue and decodes SecretString as a JSON object.
+func (s *awsSource) FetchSecrets(ctx context.Context) (map[string]string, error) {
+	out, err := s.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(s.secretID)})
+	if err != nil {
+		return nil, fmt.Errorf("secrets manager request failed: %w", err)
+	}
+
+	var data map[string]any
+	if err := json.Unmarshal([]byte(aws.ToString(out.SecretString)), &data); err != nil {
+		return nil, fmt.Errorf("secret %q must hold a JSON object of key/value pairs: %w", s.secretID, err)
+	}
+
+	return stringifySecrets(data), nil
+}
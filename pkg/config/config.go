@@ -3,6 +3,7 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"strings"
 	"time"
@@ -12,33 +13,193 @@ import (
 
 // Config holds all application configuration.
 type Config struct {
-	Server    ServerConfig
-	TTS       TTSConfig
-	Queue     QueueConfig
-	Storage   StorageConfig
-	Logging   LoggingConfig
-	Providers ProvidersConfig
+	Server    ServerConfig    `mapstructure:"server"`
+	TTS       TTSConfig       `mapstructure:"tts"`
+	Queue     QueueConfig     `mapstructure:"queue"`
+	Storage   StorageConfig   `mapstructure:"storage"`
+	Logging   LoggingConfig   `mapstructure:"logging"`
+	Providers ProvidersConfig `mapstructure:"providers"`
+	Auth      AuthConfig      `mapstructure:"auth"`
+	IPFilter  IPFilterConfig  `mapstructure:"ip_filter"`
+	Secrets   SecretsConfig   `mapstructure:"secrets"`
+	// TextSources configures jobs that reference their text instead of carrying it.
+	TextSources TextSourcesConfig `mapstructure:"text_sources"`
+	// Webhooks configures delivery to tenant-registered webhook endpoints.
+	Webhooks WebhooksConfig `mapstructure:"webhooks"`
+	// LLM configures the language model behind the summarize pipeline stage.
+	LLM LLMConfig `mapstructure:"llm"`
+	// RewriteHooks are the services the rewrite pipeline stage can send text to.
+	RewriteHooks []RewriteHookConfig `mapstructure:"rewrite_hooks"`
+	// Features switches whole API surfaces on or off.
+	Features FeaturesConfig `mapstructure:"features"`
+	// Abuse configures flagging of API keys whose usage turns unusual.
+	Abuse AbuseConfig `mapstructure:"abuse"`
+	// Consumer configures job submission from a message queue.
+	Consumer ConsumerConfig `mapstructure:"consumer"`
+
+	// secretSource and secretValues back ${VAR} expansion when a secret store is configured.
+	secretSource SecretSource
+	secretValues map[string]string
+}
+
+// AuthConfig holds API key authentication settings.
+// Authentication is disabled when no keys are configured.
+type AuthConfig struct {
+	APIKeys []APIKeyConfig `mapstructure:"api_keys"`
+	// AdminKey guards the /api/v1/admin endpoints, which are disabled when it is empty.
+	AdminKey string `mapstructure:"admin_key" secret:"true"`
+}
+
+// APIKeyConfig is a static API key with optional per-key IP rules.
+type APIKeyConfig struct {
+	// Name is the tenant of the key's requests; it must be set and unique.
+	Name       string   `mapstructure:"name"`
+	Key        string   `mapstructure:"key" secret:"true"`
+	AllowCIDRs []string `mapstructure:"allow_cidrs"` // empty = any address
+	DenyCIDRs  []string `mapstructure:"deny_cidrs"`
+	// OutputFormat is the output format of the key's requests that name none;
+	// empty means mp3.
+	OutputFormat string `mapstructure:"output_format"`
+	// TextRules restrict the characters of the key's request texts.
+	TextRules TextRulesConfig `mapstructure:"text_rules"`
+}
+
+// TextRulesConfig restricts the characters of a tenant's texts. The zero value
+// allows any text.
+type TextRulesConfig struct {
+	// AllowedScripts lists the scripts (latin, cyrillic, ...) and the classes
+	// digits and punctuation a text may use; empty allows every character.
+	AllowedScripts []string `mapstructure:"allowed_scripts"`
+	AllowedChars   string   `mapstructure:"allowed_chars"`
+	DeniedChars    string   `mapstructure:"denied_chars"`
+	// MaxRepeat is the longest run of one character allowed; 0 = no limit.
+	MaxRepeat int `mapstructure:"max_repeat"`
+}
+
+// IPFilterConfig holds the global CIDR allow/deny lists. Deny entries win; an empty
+// allow list admits every address that isn't denied.
+type IPFilterConfig struct {
+	AllowCIDRs []string `mapstructure:"allow_cidrs"`
+	DenyCIDRs  []string `mapstructure:"deny_cidrs"`
+	// TrustedProxies are the reverse proxies whose X-Forwarded-For entries tell
+	// the client address; without any, it is the connection's peer.
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
 }
 
 // ProvidersConfig holds configuration for all TTS providers.
 type ProvidersConfig struct {
 	Default string           `mapstructure:"default"`
 	List    []ProviderConfig `mapstructure:"list"`
+	Routing RoutingConfig    `mapstructure:"routing"`
+	// Degradation puts providers the upstream keeps rate limiting into a
+	// degraded mode until they recover.
+	Degradation DegradationConfig `mapstructure:"degradation"`
+}
+
+// Routing policies for requests that don't name a provider.
+const (
+	RoutingPolicyPrimary             = "primary"
+	RoutingPolicyPrimaryWithFailover = "primary-with-failover"
+	RoutingPolicyFastest             = "fastest"
+	RoutingPolicyCheapest            = "cheapest"
+)
+
+// Handling of out-of-range voice settings.
+const (
+	SettingsReject = "reject"
+	SettingsClamp  = "clamp"
+)
+
+// Duplicate-submission handling modes.
+const (
+	DedupModeOff      = "off"
+	DedupModeDetect   = "detect"
+	DedupModeCoalesce = "coalesce"
+)
+
+// Server roles.
+const (
+	// RoleAll serves the API and runs the workers in one process.
+	RoleAll = "all"
+	// RoleAPI serves the API and leaves queued jobs to worker nodes.
+	RoleAPI = "api"
+	// RoleWorker runs the workers, serving only health checks and metrics.
+	RoleWorker = "worker"
+)
+
+// Job queue backends.
+const (
+	QueueBackendMemory   = "memory"
+	QueueBackendPostgres = "postgres"
+)
+
+// Result storage backends.
+const (
+	StorageBackendFilesystem = "filesystem"
+	StorageBackendGCS        = "gcs"
+)
+
+// How workers wait for jobs.
+const (
+	// DequeueBlocking uses the backend's own wait: the memory queue wakes workers
+	// at once, the postgres queue polls every poll_interval.
+	DequeueBlocking = "blocking"
+	// DequeuePolling polls with exponential backoff from poll_interval up to
+	// max_poll_interval.
+	DequeuePolling = "polling"
+	// DequeueBatch polls like DequeuePolling, leasing up to batch_size jobs at once.
+	DequeueBatch = "batch"
+)
+
+// RoutingConfig controls how a provider is chosen when a request doesn't name one.
+type RoutingConfig struct {
+	// Policy is one of "primary" (always the default provider), "primary-with-failover",
+	// "fastest", or "cheapest".
+	Policy string `mapstructure:"policy"`
+	// MaxErrorRate is the EWMA error rate above which a provider is skipped by routing.
+	MaxErrorRate float64 `mapstructure:"max_error_rate"`
+}
+
+// DegradationConfig holds the settings of the degraded mode of rate-limited
+// providers.
+type DegradationConfig struct {
+	// RateLimits is how many rate-limited calls within Window put a provider into
+	// degraded mode; 0 disables degraded mode.
+	RateLimits int           `mapstructure:"rate_limits"`
+	Window     time.Duration `mapstructure:"window"`
+	// Recovery is how long a degraded provider must go without being rate limited
+	// to leave degraded mode.
+	Recovery time.Duration `mapstructure:"recovery"`
+	// Concurrency is the share of a degraded provider's concurrency workers keep
+	// using, in (0, 1].
+	Concurrency float64 `mapstructure:"concurrency"`
+	// PreferFallback routes requests that don't name a provider away from a
+	// degraded one to its first fallback that isn't degraded.
+	PreferFallback bool `mapstructure:"prefer_fallback"`
 }
 
 // ProviderConfig holds configuration for a single TTS provider.
 type ProviderConfig struct {
-	Name           string        `mapstructure:"name"`
-	Type           string        `mapstructure:"type"`
-	MaxConcurrent  int           `mapstructure:"max_concurrent"`
-	Timeout        time.Duration `mapstructure:"timeout"`
-	APIKey         string        `mapstructure:"api_key"`          // For elevenlabs
-	ModelID        string        `mapstructure:"model_id"`         // For elevenlabs (default model)
-	BaseURL        string        `mapstructure:"base_url"`         // For selfhosted
-	TTSEndpoint    string        `mapstructure:"tts_endpoint"`     // For selfhosted
-	VoicesEndpoint string        `mapstructure:"voices_endpoint"`  // For selfhosted
-	HealthEndpoint string        `mapstructure:"health_endpoint"`  // For selfhosted
-	DefaultStyle   string        `mapstructure:"default_style"`    // For gemini
+	Name            string        `mapstructure:"name"`
+	Type            string        `mapstructure:"type"`
+	MaxConcurrent   int           `mapstructure:"max_concurrent"`
+	Timeout         time.Duration `mapstructure:"timeout"`
+	APIKey          string        `mapstructure:"api_key" secret:"true"`           // For elevenlabs
+	APIKeyRef       string        `mapstructure:"-"`                               // api_key as written, re-resolved on secret refresh
+	SecondaryAPIKey string        `mapstructure:"secondary_api_key" secret:"true"` // Used after the upstream rejects api_key (elevenlabs, gemini)
+	ModelID         string        `mapstructure:"model_id"`                        // For elevenlabs (default model)
+	BaseURL         string        `mapstructure:"base_url"`                        // For selfhosted (required) and elevenlabs (optional API base override)
+	TTSEndpoint     string        `mapstructure:"tts_endpoint"`                    // For selfhosted
+	VoicesEndpoint  string        `mapstructure:"voices_endpoint"`                 // For selfhosted
+	HealthEndpoint  string        `mapstructure:"health_endpoint"`                 // For selfhosted
+	DefaultStyle    string        `mapstructure:"default_style"`                   // For gemini
+	Binary          string        `mapstructure:"binary"`                          // For piper (executable; default "piper" on PATH)
+	Models          []string      `mapstructure:"models"`                          // For piper (.onnx model files or directories of them)
+	CostPer1KChars  float64       `mapstructure:"cost_per_1k_chars"`               // Used by the "cheapest" routing policy
+	CharQuota       int64         `mapstructure:"char_quota"`                      // Characters this server may send; 0 = unlimited
+	Fallback        []string      `mapstructure:"fallback"`                        // Providers that take over this provider's failed jobs, in order
+	MaxTextLength   int           `mapstructure:"max_text_length"`                 // Characters per request; longer job texts are synthesized in chunks. 0 = no limit
+	ParallelChunks  int           `mapstructure:"parallel_chunks"`                 // Chunks of one job synthesized at once (default 1, which keeps request stitching)
 }
 
 // ServerConfig holds HTTP server configuration.
@@ -46,26 +207,325 @@ type ServerConfig struct {
 	Port         int           `mapstructure:"port"`
 	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
 	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+	// MetricsEnabled serves Prometheus metrics at /metrics.
+	MetricsEnabled bool `mapstructure:"metrics_enabled"`
+	// Role is RoleAll, RoleAPI or RoleWorker; the server's --role flag overrides it.
+	Role string `mapstructure:"role"`
 }
 
 // TTSConfig holds TTS-related configuration.
 type TTSConfig struct {
-	ElevenLabsAPIKey  string        `mapstructure:"elevenlabs_api_key"`
+	ElevenLabsAPIKey  string        `mapstructure:"elevenlabs_api_key" secret:"true"`
 	DefaultVoiceID    string        `mapstructure:"default_voice_id"`
 	MaxSyncTextLength int           `mapstructure:"max_sync_text_length"`
 	SyncTimeout       time.Duration `mapstructure:"sync_timeout"`
+	// AsyncFallback answers POST /tts requests that run out of SyncTimeout with
+	// 202 and an async job doing the same synthesis, instead of failing them.
+	AsyncFallback bool `mapstructure:"async_fallback"`
+	// OutOfRangeSettings is "reject" (422) or "clamp" for voice settings outside the
+	// provider's accepted ranges.
+	OutOfRangeSettings string `mapstructure:"out_of_range_settings"`
+	// VoicesCacheTTL is how long each provider's voice list is reused by the voices
+	// endpoints; 0 asks the provider every time.
+	VoicesCacheTTL time.Duration `mapstructure:"voices_cache_ttl"`
 }
 
 // QueueConfig holds job queue configuration.
 type QueueConfig struct {
-	WorkerCount       int `mapstructure:"worker_count"`
-	MaxConcurrentJobs int `mapstructure:"max_concurrent_jobs"`
+	// Backend stores the jobs: "memory" (lost on restart) or "postgres" (durable,
+	// shareable between instances).
+	Backend           string `mapstructure:"backend"`
+	WorkerCount       int    `mapstructure:"worker_count"`
+	MaxConcurrentJobs int    `mapstructure:"max_concurrent_jobs"`
+	// EnqueueWait bounds how long job submission waits for queue space before the
+	// request is rejected with QUEUE_BUSY; 0 rejects immediately when the queue is full.
+	EnqueueWait time.Duration `mapstructure:"enqueue_wait"`
+	// TenantMaxInFlight caps how many jobs of one tenant are processed at once; 0 = no cap.
+	TenantMaxInFlight int `mapstructure:"tenant_max_in_flight"`
+	// MaxCharsInFlight caps the total text length of jobs processed at once; 0 = no cap.
+	MaxCharsInFlight int `mapstructure:"max_chars_in_flight"`
+	// DedupMode handles identical submissions within DedupWindow: "off", "detect"
+	// (link the new job to the earlier one) or "coalesce" (return the earlier job).
+	DedupMode   string        `mapstructure:"dedup_mode"`
+	DedupWindow time.Duration `mapstructure:"dedup_window"`
+	// VisibilityTimeout is how long a dequeued job may go without progress or an
+	// acknowledgement before it is redelivered; 0 disables redelivery.
+	VisibilityTimeout time.Duration `mapstructure:"visibility_timeout"`
+	// HeartbeatInterval is how often workers renew the leases of the jobs they
+	// process, and reap the jobs of dead workers from the postgres backend; 0
+	// turns heartbeats off. It must be shorter than VisibilityTimeout, and
+	// defaults to a quarter of it.
+	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval"`
+	// MaxDeliveries fails a job that was delivered this often without being
+	// acknowledged; 0 = no limit.
+	MaxDeliveries int `mapstructure:"max_deliveries"`
+	// MaxAttempts is how often a job is attempted while synthesis keeps failing
+	// with transient errors (timeouts, 429, 5xx); 1 disables retries.
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// RetryBaseDelay is the wait before the first retry. It doubles with every
+	// further retry, up to RetryMaxDelay.
+	RetryBaseDelay time.Duration `mapstructure:"retry_base_delay"`
+	RetryMaxDelay  time.Duration `mapstructure:"retry_max_delay"`
+	// SilenceThresholdDB is the peak level, in dBFS, below which a result for
+	// text with words to speak counts as silent and is retried; 0 disables.
+	SilenceThresholdDB float64 `mapstructure:"silence_threshold_db"`
+	// LimitProviderConcurrency keeps the workers' synthesis calls to each
+	// provider within the provider's max concurrency.
+	LimitProviderConcurrency bool `mapstructure:"limit_provider_concurrency"`
+	// Dequeue is how workers wait for jobs: "blocking", "polling" or "batch". The
+	// memory backend only supports "blocking".
+	Dequeue string `mapstructure:"dequeue"`
+	// WorkerPools pins extra workers to providers. WorkerCount workers serve every
+	// provider not listed in a pool.
+	WorkerPools []WorkerPoolConfig `mapstructure:"worker_pools"`
+	// Postgres configures the postgres backend.
+	Postgres PostgresQueueConfig `mapstructure:"postgres"`
+	// DrainTimeout is how long the workers may finish their jobs on SIGTERM
+	// before the rest are checkpointed: queued again for another instance. 0
+	// waits for them.
+	DrainTimeout time.Duration `mapstructure:"drain_timeout"`
+	// PersistPath is the file the memory backend writes its unfinished jobs to on
+	// shutdown, and queues them again from on start; empty loses them.
+	PersistPath string `mapstructure:"persist_path"`
+}
+
+// PostgresQueueConfig holds the connection settings of the postgres queue backend.
+type PostgresQueueConfig struct {
+	DSN string `mapstructure:"dsn" secret:"true"`
+	// Driver is the database/sql driver name; pgx is linked into the server.
+	Driver string `mapstructure:"driver"`
+	// PollInterval is how often idle workers look for new jobs; with the polling
+	// and batch dequeue strategies, the shortest wait between empty polls.
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	// MaxPollInterval caps the wait between empty polls of the polling and batch
+	// dequeue strategies.
+	MaxPollInterval time.Duration `mapstructure:"max_poll_interval"`
+	// BatchSize is how many jobs the batch dequeue strategy leases per poll.
+	BatchSize int `mapstructure:"batch_size"`
+}
+
+// WorkerPoolConfig is a set of workers that only process jobs for its providers.
+type WorkerPoolConfig struct {
+	Name      string   `mapstructure:"name"`
+	Workers   int      `mapstructure:"workers"`
+	Providers []string `mapstructure:"providers"`
 }
 
 // StorageConfig holds storage configuration.
 type StorageConfig struct {
-	AudioStoragePath  string `mapstructure:"audio_storage_path"`
+	// Backend keeps job results: "filesystem", under AudioStoragePath, or "gcs",
+	// in a Google Cloud Storage bucket.
+	Backend          string `mapstructure:"backend"`
+	AudioStoragePath string `mapstructure:"audio_storage_path"`
+	// KeyStrategy is the layout results are kept in under AudioStoragePath, or
+	// the bucket prefix: flat, sharded, tenant or content.
+	KeyStrategy       string `mapstructure:"key_strategy"`
 	JobRetentionHours int    `mapstructure:"job_retention_hours"`
+	// PreviewSeconds is the length of the preview clip stored with each job result; 0 disables previews.
+	PreviewSeconds int `mapstructure:"preview_seconds"`
+	// ArtifactRetention keeps kinds of artifacts for their own period instead of
+	// JobRetentionHours, e.g. previews longer than the audio.
+	ArtifactRetention ArtifactRetentionConfig `mapstructure:"artifact_retention"`
+	// RegenerateGraceHours keeps a job's text this long after its result expires, so
+	// the job can be regenerated without the client resending it.
+	RegenerateGraceHours int `mapstructure:"regenerate_grace_hours"`
+	// ArchiveAfterHours replaces jobs this long after their result expired with an
+	// archived record, without text, that analytics still count; 0 keeps them.
+	ArchiveAfterHours int `mapstructure:"archive_after_hours"`
+	// SpeechCachePath is where warmed sync responses are kept; empty disables the speech cache.
+	SpeechCachePath string `mapstructure:"speech_cache_path"`
+	// ResultCache answers requests identical to an earlier one with its audio,
+	// kept in ResultCachePath for ResultCacheTTL, instead of calling the provider.
+	ResultCache     bool          `mapstructure:"result_cache"`
+	ResultCachePath string        `mapstructure:"result_cache_path"`
+	ResultCacheTTL  time.Duration `mapstructure:"result_cache_ttl"`
+	// RedirectResults answers result downloads with a redirect to a URL signed
+	// for SignedURLTTL, so clients fetch the audio from the bucket instead of
+	// through the server. It needs a backend that can sign URLs.
+	RedirectResults bool          `mapstructure:"redirect_results"`
+	SignedURLTTL    time.Duration `mapstructure:"signed_url_ttl"`
+	// GCS configures the gcs backend.
+	GCS GCSStorageConfig `mapstructure:"gcs"`
+}
+
+// ArtifactRetentionConfig holds how many hours each kind of artifact is kept; 0
+// keeps it as long as the result.
+type ArtifactRetentionConfig struct {
+	PreviewHours  int `mapstructure:"preview_hours"`
+	WaveformHours int `mapstructure:"waveform_hours"`
+	// VariantHours applies to the result transcoded to another format on request.
+	VariantHours int `mapstructure:"variant_hours"`
+}
+
+// GCSStorageConfig holds the bucket settings of the gcs storage backend.
+type GCSStorageConfig struct {
+	Bucket string `mapstructure:"bucket"`
+	// Prefix is prepended to the name of every object, so a bucket can be shared.
+	Prefix string `mapstructure:"prefix"`
+	// CredentialsFile is a service account key or application default credentials
+	// file. When empty, GOOGLE_APPLICATION_CREDENTIALS, gcloud's application
+	// default credentials and the metadata server are tried in turn.
+	CredentialsFile string `mapstructure:"credentials_file"`
+	// Endpoint overrides the Cloud Storage API endpoint, e.g. for a private one.
+	Endpoint string `mapstructure:"endpoint"`
+}
+
+// TextSourcesConfig holds settings for fetching job text from URLs and documents.
+type TextSourcesConfig struct {
+	// AllowedHosts restricts url and document sources to these hosts; an entry
+	// starting with "." also matches subdomains. features.text_sources needs at
+	// least one.
+	AllowedHosts []string `mapstructure:"allowed_hosts"`
+	// MaxBytes caps the size of fetched or rendered text.
+	MaxBytes int64 `mapstructure:"max_bytes"`
+	// FetchTimeout bounds each HTTP fetch.
+	FetchTimeout time.Duration `mapstructure:"fetch_timeout"`
+}
+
+// FeaturesConfig switches whole API surfaces on or off, so an instance can
+// expose only what it is deployed for, e.g. an internal node serving async jobs
+// only. Everything but job search and text sources is on by default. Workers
+// process queued jobs either way.
+type FeaturesConfig struct {
+	// SyncTTS serves POST /tts, /tts/stream, /tts/estimate and /cache/warm.
+	SyncTTS bool `mapstructure:"sync_tts"`
+	// AsyncJobs serves /jobs, /groups, /analytics and /webhooks.
+	AsyncJobs bool `mapstructure:"async_jobs"`
+	// Admin serves /admin, which also needs auth.admin_key.
+	Admin bool `mapstructure:"admin"`
+	// TextSources accepts jobs that name a source (url, document) instead of
+	// carrying their text. It needs text_sources.allowed_hosts.
+	TextSources bool `mapstructure:"text_sources"`
+	// UI serves the browser UI at /ui/.
+	UI bool `mapstructure:"ui"`
+	// JobSearch indexes the text and tags of jobs and serves /jobs/search. It
+	// is off by default, as the index costs memory, or disk with Postgres.
+	JobSearch bool `mapstructure:"job_search"`
+}
+
+// Actions taken on an API key flagged for abuse.
+const (
+	AbuseActionLog        = "log"
+	AbuseActionThrottle   = "throttle"
+	AbuseActionQuarantine = "quarantine"
+)
+
+// AbuseConfig holds the thresholds that flag an API key's usage as unusual,
+// e.g. a leaked key, and what happens to the key until an admin releases it.
+type AbuseConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Window is the period usage is counted over.
+	Window time.Duration `mapstructure:"window"`
+	// BaselineWindows is how many earlier windows a key's usual volume is
+	// averaged over.
+	BaselineWindows int `mapstructure:"baseline_windows"`
+	// VolumeFactor flags a key whose characters in a window exceed its baseline
+	// this many times; 0 disables the check.
+	VolumeFactor float64 `mapstructure:"volume_factor"`
+	// MinVolumeChars is the window volume below which no spike is flagged, so
+	// a quiet key isn't flagged for a few long texts.
+	MinVolumeChars int64 `mapstructure:"min_volume_chars"`
+	// MaxIdenticalRequests flags a key sending one text with one voice more often
+	// in a window; 0 disables the check.
+	MaxIdenticalRequests int `mapstructure:"max_identical_requests"`
+	// MaxVoices flags a key using more distinct voices in a window; 0 disables
+	// the check.
+	MaxVoices int `mapstructure:"max_voices"`
+	// Action is AbuseActionLog, AbuseActionThrottle or AbuseActionQuarantine.
+	Action string `mapstructure:"action"`
+	// ThrottlePerMinute is how many submissions a throttled key may send a minute.
+	ThrottlePerMinute int `mapstructure:"throttle_per_minute"`
+}
+
+// WebhooksConfig holds settings for delivering events to webhooks.
+type WebhooksConfig struct {
+	// AllowedHosts restricts webhook URLs to these hosts; an entry starting with
+	// "." also matches subdomains. Empty allows any host.
+	AllowedHosts []string `mapstructure:"allowed_hosts"`
+	// Timeout bounds each delivery attempt.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// Secret signs webhook and job callback deliveries with HMAC-SHA256; empty
+	// sends them unsigned.
+	Secret string `mapstructure:"secret" secret:"true"`
+}
+
+// Message queues the consumer reads job requests from.
+const (
+	ConsumerBackendRedis = "redis"
+	ConsumerBackendNATS  = "nats"
+	ConsumerBackendSQS   = "sqs"
+)
+
+// ConsumerConfig holds the message queue job requests are read from, and where
+// the results are published.
+type ConsumerConfig struct {
+	// Backend is ConsumerBackendRedis (a stream), ConsumerBackendNATS (a
+	// subject) or ConsumerBackendSQS (a queue); empty disables the consumer.
+	Backend string `mapstructure:"backend"`
+	// URL is the broker, e.g. redis://:password@redis:6379/0 or
+	// nats://token@nats:4222 (tls:// for TLS), or the URL of the SQS queue.
+	URL string `mapstructure:"url" secret:"true"`
+	// Topic is the Redis stream or NATS subject requests are read from.
+	Topic string `mapstructure:"topic"`
+	// Group is the Redis consumer group or NATS queue group instances share, so
+	// each request is taken by one of them.
+	Group string `mapstructure:"group"`
+	// ReplyTopic is the stream, subject or SQS queue URL results are published
+	// to when a request names none; empty publishes none.
+	ReplyTopic string `mapstructure:"reply_topic"`
+	// APIKey is the key jobs are submitted with when auth is enabled; its name
+	// is their tenant.
+	APIKey string `mapstructure:"api_key" secret:"true"`
+	// PublicURL, e.g. https://tts.example.com, makes the result links in
+	// replies absolute.
+	PublicURL string `mapstructure:"public_url"`
+	// PollInterval is how often the jobs submitted are checked for results.
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	// Region is the AWS region of the SQS queue; empty takes it from the URL.
+	Region string `mapstructure:"region"`
+}
+
+// LLMConfig holds settings for calling a language model. The summarize pipeline
+// stage is available only when Endpoint is set.
+type LLMConfig struct {
+	// Endpoint is the URL of an OpenAI-compatible chat completions API.
+	Endpoint string `mapstructure:"endpoint"`
+	// APIKey is sent as a bearer token; empty sends none.
+	APIKey string `mapstructure:"api_key" secret:"true"`
+	// Model is the model requested.
+	Model string `mapstructure:"model"`
+	// Timeout bounds each request.
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// Rewrite hook types.
+const (
+	// RewriteHookLLM sends the hook's instructions and the text to the llm endpoint.
+	RewriteHookLLM = "llm"
+	// RewriteHookHTTP POSTs the text to the hook's URL.
+	RewriteHookHTTP = "http"
+)
+
+// RewriteHookConfig is a named service that rewrites text before synthesis, e.g.
+// to adjust its tone, format it as a script or turn it into SSML. Pipelines call
+// it with a rewrite stage.
+type RewriteHookConfig struct {
+	Name string `mapstructure:"name"`
+	// Type is RewriteHookLLM or RewriteHookHTTP.
+	Type string `mapstructure:"type"`
+	// Instructions are the system prompt of an llm hook.
+	Instructions string `mapstructure:"instructions"`
+	// Model overrides llm.model for an llm hook.
+	Model string `mapstructure:"model"`
+	// URL is the endpoint of an http hook.
+	URL string `mapstructure:"url"`
+	// Headers are sent with every request of an http hook, e.g. for authentication.
+	Headers map[string]string `mapstructure:"headers" secret:"true"`
+	// Timeout bounds each call; a stage may ask for less.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// CacheTTL is how long the output for the same text is reused; 0 disables
+	// caching unless a stage asks for it.
+	CacheTTL time.Duration `mapstructure:"cache_ttl"`
 }
 
 // LoggingConfig holds logging configuration.
@@ -82,15 +542,90 @@ func Load() (*Config, error) {
 	v.SetDefault("server.port", 8080)
 	v.SetDefault("server.read_timeout", "60s")
 	v.SetDefault("server.write_timeout", "60s")
+	v.SetDefault("server.metrics_enabled", true)
+	v.SetDefault("server.role", RoleAll)
 	v.SetDefault("tts.default_voice_id", "pNInz6obpgDQGcFmaJgB")
 	v.SetDefault("tts.max_sync_text_length", 5000)
 	v.SetDefault("tts.sync_timeout", "30s")
+	v.SetDefault("tts.async_fallback", false)
+	v.SetDefault("tts.out_of_range_settings", SettingsReject)
+	v.SetDefault("tts.voices_cache_ttl", "5m")
+	v.SetDefault("queue.backend", QueueBackendMemory)
+	v.SetDefault("queue.postgres.driver", "pgx")
+	v.SetDefault("queue.postgres.poll_interval", "500ms")
+	v.SetDefault("queue.postgres.max_poll_interval", "5s")
+	v.SetDefault("queue.postgres.batch_size", 10)
+	v.SetDefault("queue.dequeue", DequeueBlocking)
 	v.SetDefault("queue.worker_count", 4)
 	v.SetDefault("queue.max_concurrent_jobs", 100)
+	v.SetDefault("queue.enqueue_wait", "200ms")
+	v.SetDefault("queue.dedup_mode", DedupModeOff)
+	v.SetDefault("queue.dedup_window", "30s")
+	v.SetDefault("queue.visibility_timeout", "10m")
+	v.SetDefault("queue.drain_timeout", "25s")
+	v.SetDefault("queue.max_deliveries", 3)
+	v.SetDefault("queue.max_attempts", 5)
+	v.SetDefault("queue.retry_base_delay", "5s")
+	v.SetDefault("queue.retry_max_delay", "5m")
+	v.SetDefault("queue.silence_threshold_db", -60)
+	v.SetDefault("queue.limit_provider_concurrency", true)
+	v.SetDefault("storage.backend", StorageBackendFilesystem)
 	v.SetDefault("storage.audio_storage_path", "./audio_cache")
+	v.SetDefault("storage.key_strategy", "sharded")
 	v.SetDefault("storage.job_retention_hours", 24)
+	v.SetDefault("storage.preview_seconds", 10)
+	v.SetDefault("storage.artifact_retention.preview_hours", 0)
+	v.SetDefault("storage.artifact_retention.waveform_hours", 0)
+	v.SetDefault("storage.artifact_retention.variant_hours", 0)
+	v.SetDefault("storage.regenerate_grace_hours", 24)
+	v.SetDefault("storage.archive_after_hours", 0)
+	v.SetDefault("storage.speech_cache_path", "")
+	v.SetDefault("storage.result_cache", true)
+	v.SetDefault("storage.result_cache_path", "./result_cache")
+	v.SetDefault("storage.result_cache_ttl", "24h")
+	v.SetDefault("storage.redirect_results", false)
+	v.SetDefault("storage.signed_url_ttl", "15m")
+	v.SetDefault("storage.gcs.bucket", "")
+	v.SetDefault("storage.gcs.prefix", "")
+	v.SetDefault("storage.gcs.credentials_file", "")
+	v.SetDefault("storage.gcs.endpoint", "")
+	v.SetDefault("providers.routing.policy", RoutingPolicyPrimary)
+	v.SetDefault("providers.routing.max_error_rate", 0.5)
+	v.SetDefault("providers.degradation.rate_limits", 5)
+	v.SetDefault("providers.degradation.window", "1m")
+	v.SetDefault("providers.degradation.recovery", "2m")
+	v.SetDefault("providers.degradation.concurrency", 0.5)
+	v.SetDefault("providers.degradation.prefer_fallback", false)
+	v.SetDefault("text_sources.max_bytes", 1<<20)
+	v.SetDefault("text_sources.fetch_timeout", "30s")
+	v.SetDefault("webhooks.timeout", "10s")
+	v.SetDefault("llm.timeout", "60s")
+	v.SetDefault("features.sync_tts", true)
+	v.SetDefault("features.async_jobs", true)
+	v.SetDefault("features.admin", true)
+	v.SetDefault("features.text_sources", false)
+	v.SetDefault("features.ui", true)
+	v.SetDefault("features.job_search", false)
+	v.SetDefault("abuse.window", "1h")
+	v.SetDefault("abuse.baseline_windows", 24)
+	v.SetDefault("abuse.volume_factor", 100)
+	v.SetDefault("abuse.min_volume_chars", 100000)
+	v.SetDefault("abuse.max_identical_requests", 1000)
+	v.SetDefault("abuse.max_voices", 50)
+	v.SetDefault("abuse.action", AbuseActionThrottle)
+	v.SetDefault("abuse.throttle_per_minute", 6)
+	v.SetDefault("consumer.backend", "")
+	v.SetDefault("consumer.url", "")
+	v.SetDefault("consumer.topic", "")
+	v.SetDefault("consumer.group", "pako-tts")
+	v.SetDefault("consumer.reply_topic", "")
+	v.SetDefault("consumer.api_key", "")
+	v.SetDefault("consumer.public_url", "")
+	v.SetDefault("consumer.poll_interval", "1s")
+	v.SetDefault("consumer.region", "")
 	v.SetDefault("logging.level", "info")
 	v.SetDefault("logging.format", "json")
+	v.SetDefault("secrets.refresh_interval", "5m")
 
 	// Try to read config file
 	v.SetConfigName("config")
@@ -111,19 +646,19 @@ func Load() (*Config, error) {
 
 	// Also support legacy flat env vars for backwards compatibility
 	legacyEnvMappings := map[string]string{
-		"HTTP_PORT":           "server.port",
-		"HTTP_READ_TIMEOUT":   "server.read_timeout",
-		"HTTP_WRITE_TIMEOUT":  "server.write_timeout",
-		"ELEVENLABS_API_KEY":  "tts.elevenlabs_api_key",
-		"DEFAULT_VOICE_ID":    "tts.default_voice_id",
+		"HTTP_PORT":            "server.port",
+		"HTTP_READ_TIMEOUT":    "server.read_timeout",
+		"HTTP_WRITE_TIMEOUT":   "server.write_timeout",
+		"ELEVENLABS_API_KEY":   "tts.elevenlabs_api_key",
+		"DEFAULT_VOICE_ID":     "tts.default_voice_id",
 		"MAX_SYNC_TEXT_LENGTH": "tts.max_sync_text_length",
-		"SYNC_TIMEOUT":        "tts.sync_timeout",
-		"WORKER_COUNT":        "queue.worker_count",
-		"MAX_CONCURRENT_JOBS": "queue.max_concurrent_jobs",
-		"AUDIO_STORAGE_PATH":  "storage.audio_storage_path",
-		"JOB_RETENTION_HOURS": "storage.job_retention_hours",
-		"LOG_LEVEL":           "logging.level",
-		"LOG_FORMAT":          "logging.format",
+		"SYNC_TIMEOUT":         "tts.sync_timeout",
+		"WORKER_COUNT":         "queue.worker_count",
+		"MAX_CONCURRENT_JOBS":  "queue.max_concurrent_jobs",
+		"AUDIO_STORAGE_PATH":   "storage.audio_storage_path",
+		"JOB_RETENTION_HOURS":  "storage.job_retention_hours",
+		"LOG_LEVEL":            "logging.level",
+		"LOG_FORMAT":           "logging.format",
 	}
 	for envKey, configKey := range legacyEnvMappings {
 		if val := os.Getenv(envKey); val != "" {
@@ -146,44 +681,255 @@ func Load() (*Config, error) {
 	if err != nil {
 		syncTimeout = 30 * time.Second
 	}
+	voicesCacheTTL, err := time.ParseDuration(v.GetString("tts.voices_cache_ttl"))
+	if err != nil {
+		voicesCacheTTL = 5 * time.Minute
+	}
+	resultCacheTTL, err := time.ParseDuration(v.GetString("storage.result_cache_ttl"))
+	if err != nil {
+		resultCacheTTL = 24 * time.Hour
+	}
+	signedURLTTL, err := time.ParseDuration(v.GetString("storage.signed_url_ttl"))
+	if err != nil {
+		signedURLTTL = 15 * time.Minute
+	}
+
+	enqueueWait, err := time.ParseDuration(v.GetString("queue.enqueue_wait"))
+	if err != nil {
+		enqueueWait = 200 * time.Millisecond
+	}
+	dedupWindow, err := time.ParseDuration(v.GetString("queue.dedup_window"))
+	if err != nil {
+		dedupWindow = 30 * time.Second
+	}
+	drainTimeout, err := time.ParseDuration(v.GetString("queue.drain_timeout"))
+	if err != nil {
+		drainTimeout = 25 * time.Second
+	}
+	visibilityTimeout, err := time.ParseDuration(v.GetString("queue.visibility_timeout"))
+	if err != nil {
+		visibilityTimeout = 10 * time.Minute
+	}
+	// Heartbeats default to four per visibility timeout
+	heartbeatInterval, err := time.ParseDuration(v.GetString("queue.heartbeat_interval"))
+	if err != nil {
+		heartbeatInterval = visibilityTimeout / 4
+	}
+	retryBaseDelay, err := time.ParseDuration(v.GetString("queue.retry_base_delay"))
+	if err != nil {
+		retryBaseDelay = 5 * time.Second
+	}
+	retryMaxDelay, err := time.ParseDuration(v.GetString("queue.retry_max_delay"))
+	if err != nil {
+		retryMaxDelay = 5 * time.Minute
+	}
+	pollInterval, err := time.ParseDuration(v.GetString("queue.postgres.poll_interval"))
+	if err != nil {
+		pollInterval = 500 * time.Millisecond
+	}
+	maxPollInterval, err := time.ParseDuration(v.GetString("queue.postgres.max_poll_interval"))
+	if err != nil {
+		maxPollInterval = 5 * time.Second
+	}
+	fetchTimeout, err := time.ParseDuration(v.GetString("text_sources.fetch_timeout"))
+	if err != nil {
+		fetchTimeout = 30 * time.Second
+	}
+	webhookTimeout, err := time.ParseDuration(v.GetString("webhooks.timeout"))
+	if err != nil {
+		webhookTimeout = 10 * time.Second
+	}
+	llmTimeout, err := time.ParseDuration(v.GetString("llm.timeout"))
+	if err != nil {
+		llmTimeout = 60 * time.Second
+	}
+	abuseWindow, err := time.ParseDuration(v.GetString("abuse.window"))
+	if err != nil {
+		abuseWindow = time.Hour
+	}
+	consumerPoll, err := time.ParseDuration(v.GetString("consumer.poll_interval"))
+	if err != nil {
+		consumerPoll = time.Second
+	}
 
 	cfg := &Config{
 		Server: ServerConfig{
 			Port:         v.GetInt("server.port"),
 			ReadTimeout:  readTimeout,
 			WriteTimeout: writeTimeout,
+
+			MetricsEnabled: v.GetBool("server.metrics_enabled"),
+			Role:           v.GetString("server.role"),
 		},
 		TTS: TTSConfig{
-			ElevenLabsAPIKey:  expandEnvVars(v.GetString("tts.elevenlabs_api_key")),
-			DefaultVoiceID:    v.GetString("tts.default_voice_id"),
-			MaxSyncTextLength: v.GetInt("tts.max_sync_text_length"),
-			SyncTimeout:       syncTimeout,
+			ElevenLabsAPIKey:   v.GetString("tts.elevenlabs_api_key"),
+			DefaultVoiceID:     v.GetString("tts.default_voice_id"),
+			MaxSyncTextLength:  v.GetInt("tts.max_sync_text_length"),
+			SyncTimeout:        syncTimeout,
+			AsyncFallback:      v.GetBool("tts.async_fallback"),
+			OutOfRangeSettings: v.GetString("tts.out_of_range_settings"),
+			VoicesCacheTTL:     voicesCacheTTL,
 		},
 		Queue: QueueConfig{
-			WorkerCount:       v.GetInt("queue.worker_count"),
-			MaxConcurrentJobs: v.GetInt("queue.max_concurrent_jobs"),
+			Backend:                  v.GetString("queue.backend"),
+			WorkerCount:              v.GetInt("queue.worker_count"),
+			MaxConcurrentJobs:        v.GetInt("queue.max_concurrent_jobs"),
+			EnqueueWait:              enqueueWait,
+			TenantMaxInFlight:        v.GetInt("queue.tenant_max_in_flight"),
+			MaxCharsInFlight:         v.GetInt("queue.max_chars_in_flight"),
+			DedupMode:                v.GetString("queue.dedup_mode"),
+			DedupWindow:              dedupWindow,
+			VisibilityTimeout:        visibilityTimeout,
+			HeartbeatInterval:        heartbeatInterval,
+			DrainTimeout:             drainTimeout,
+			MaxDeliveries:            v.GetInt("queue.max_deliveries"),
+			MaxAttempts:              v.GetInt("queue.max_attempts"),
+			RetryBaseDelay:           retryBaseDelay,
+			RetryMaxDelay:            retryMaxDelay,
+			SilenceThresholdDB:       v.GetFloat64("queue.silence_threshold_db"),
+			LimitProviderConcurrency: v.GetBool("queue.limit_provider_concurrency"),
+			Dequeue:                  v.GetString("queue.dequeue"),
+			PersistPath:              v.GetString("queue.persist_path"),
+			Postgres: PostgresQueueConfig{
+				DSN:             v.GetString("queue.postgres.dsn"),
+				Driver:          v.GetString("queue.postgres.driver"),
+				PollInterval:    pollInterval,
+				MaxPollInterval: maxPollInterval,
+				BatchSize:       v.GetInt("queue.postgres.batch_size"),
+			},
 		},
 		Storage: StorageConfig{
-			AudioStoragePath:  v.GetString("storage.audio_storage_path"),
-			JobRetentionHours: v.GetInt("storage.job_retention_hours"),
+			Backend:              v.GetString("storage.backend"),
+			AudioStoragePath:     v.GetString("storage.audio_storage_path"),
+			KeyStrategy:          v.GetString("storage.key_strategy"),
+			JobRetentionHours:    v.GetInt("storage.job_retention_hours"),
+			PreviewSeconds:       v.GetInt("storage.preview_seconds"),
+			RegenerateGraceHours: v.GetInt("storage.regenerate_grace_hours"),
+			ArchiveAfterHours:    v.GetInt("storage.archive_after_hours"),
+			SpeechCachePath:      v.GetString("storage.speech_cache_path"),
+			ResultCache:          v.GetBool("storage.result_cache"),
+			ResultCachePath:      v.GetString("storage.result_cache_path"),
+			ResultCacheTTL:       resultCacheTTL,
+			ArtifactRetention: ArtifactRetentionConfig{
+				PreviewHours:  v.GetInt("storage.artifact_retention.preview_hours"),
+				WaveformHours: v.GetInt("storage.artifact_retention.waveform_hours"),
+				VariantHours:  v.GetInt("storage.artifact_retention.variant_hours"),
+			},
+			RedirectResults: v.GetBool("storage.redirect_results"),
+			SignedURLTTL:    signedURLTTL,
+			GCS: GCSStorageConfig{
+				Bucket:          v.GetString("storage.gcs.bucket"),
+				Prefix:          v.GetString("storage.gcs.prefix"),
+				CredentialsFile: v.GetString("storage.gcs.credentials_file"),
+				Endpoint:        v.GetString("storage.gcs.endpoint"),
+			},
 		},
 		Logging: LoggingConfig{
 			Level:  v.GetString("logging.level"),
 			Format: v.GetString("logging.format"),
 		},
+		IPFilter: IPFilterConfig{
+			AllowCIDRs:     v.GetStringSlice("ip_filter.allow_cidrs"),
+			DenyCIDRs:      v.GetStringSlice("ip_filter.deny_cidrs"),
+			TrustedProxies: v.GetStringSlice("ip_filter.trusted_proxies"),
+		},
+		Secrets: loadSecretsConfig(v),
+		TextSources: TextSourcesConfig{
+			AllowedHosts: v.GetStringSlice("text_sources.allowed_hosts"),
+			MaxBytes:     v.GetInt64("text_sources.max_bytes"),
+			FetchTimeout: fetchTimeout,
+		},
+		Webhooks: WebhooksConfig{
+			AllowedHosts: v.GetStringSlice("webhooks.allowed_hosts"),
+			Timeout:      webhookTimeout,
+		},
+		LLM: LLMConfig{
+			Endpoint: v.GetString("llm.endpoint"),
+			Model:    v.GetString("llm.model"),
+			Timeout:  llmTimeout,
+		},
+		Features: FeaturesConfig{
+			SyncTTS:     v.GetBool("features.sync_tts"),
+			AsyncJobs:   v.GetBool("features.async_jobs"),
+			Admin:       v.GetBool("features.admin"),
+			TextSources: v.GetBool("features.text_sources"),
+			UI:          v.GetBool("features.ui"),
+			JobSearch:   v.GetBool("features.job_search"),
+		},
+		Abuse: AbuseConfig{
+			Enabled:              v.GetBool("abuse.enabled"),
+			Window:               abuseWindow,
+			BaselineWindows:      v.GetInt("abuse.baseline_windows"),
+			VolumeFactor:         v.GetFloat64("abuse.volume_factor"),
+			MinVolumeChars:       v.GetInt64("abuse.min_volume_chars"),
+			MaxIdenticalRequests: v.GetInt("abuse.max_identical_requests"),
+			MaxVoices:            v.GetInt("abuse.max_voices"),
+			Action:               v.GetString("abuse.action"),
+			ThrottlePerMinute:    v.GetInt("abuse.throttle_per_minute"),
+		},
+		Consumer: ConsumerConfig{
+			Backend:      v.GetString("consumer.backend"),
+			Topic:        v.GetString("consumer.topic"),
+			Group:        v.GetString("consumer.group"),
+			ReplyTopic:   v.GetString("consumer.reply_topic"),
+			PublicURL:    v.GetString("consumer.public_url"),
+			PollInterval: consumerPoll,
+			Region:       v.GetString("consumer.region"),
+		},
+	}
+
+	// Secrets are read before anything is expanded so ${VAR} references can use them
+	if err := cfg.loadSecrets(); err != nil {
+		return nil, err
 	}
+	cfg.TTS.ElevenLabsAPIKey = cfg.expandVars(cfg.elevenLabsKeyRef(v))
+	cfg.Webhooks.Secret = cfg.expandVars(v.GetString("webhooks.secret"))
+	cfg.LLM.APIKey = cfg.expandVars(v.GetString("llm.api_key"))
+	cfg.Consumer.URL = cfg.expandVars(v.GetString("consumer.url"))
+	cfg.Consumer.APIKey = cfg.expandVars(v.GetString("consumer.api_key"))
 
 	// Load providers configuration
 	if err := loadProvidersConfig(v, cfg); err != nil {
 		return nil, err
 	}
 
+	if err := loadWorkerPools(v, cfg); err != nil {
+		return nil, err
+	}
+
+	if err := loadRewriteHooks(v, cfg); err != nil {
+		return nil, err
+	}
+
+	if err := loadAuthConfig(v, cfg); err != nil {
+		return nil, err
+	}
+
 	return cfg, nil
 }
 
 // loadProvidersConfig loads the providers section from viper.
 func loadProvidersConfig(v *viper.Viper, cfg *Config) error {
 	cfg.Providers.Default = v.GetString("providers.default")
+	cfg.Providers.Routing = RoutingConfig{
+		Policy:       v.GetString("providers.routing.policy"),
+		MaxErrorRate: v.GetFloat64("providers.routing.max_error_rate"),
+	}
+	degradationWindow, err := time.ParseDuration(v.GetString("providers.degradation.window"))
+	if err != nil {
+		degradationWindow = time.Minute
+	}
+	degradationRecovery, err := time.ParseDuration(v.GetString("providers.degradation.recovery"))
+	if err != nil {
+		degradationRecovery = 2 * time.Minute
+	}
+	cfg.Providers.Degradation = DegradationConfig{
+		RateLimits:     v.GetInt("providers.degradation.rate_limits"),
+		Window:         degradationWindow,
+		Recovery:       degradationRecovery,
+		Concurrency:    v.GetFloat64("providers.degradation.concurrency"),
+		PreferFallback: v.GetBool("providers.degradation.prefer_fallback"),
+	}
 
 	// Get the providers list
 	providersRaw := v.Get("providers.list")
@@ -196,6 +942,7 @@ func loadProvidersConfig(v *viper.Viper, cfg *Config) error {
 					Name:          "elevenlabs",
 					Type:          "elevenlabs",
 					APIKey:        cfg.TTS.ElevenLabsAPIKey,
+					APIKeyRef:     cfg.elevenLabsKeyRef(v),
 					MaxConcurrent: 4,
 					Timeout:       30 * time.Second,
 				},
@@ -217,17 +964,30 @@ func loadProvidersConfig(v *viper.Viper, cfg *Config) error {
 		}
 
 		pc := ProviderConfig{
-			Name:           getString(providerMap, "name"),
-			Type:           getString(providerMap, "type"),
-			MaxConcurrent:  getInt(providerMap, "max_concurrent", 4),
-			Timeout:        getDuration(providerMap, "timeout", 30*time.Second),
-			APIKey:         expandEnvVars(getString(providerMap, "api_key")),
-			ModelID:        expandEnvVars(getString(providerMap, "model_id")),
-			BaseURL:        getString(providerMap, "base_url"),
-			TTSEndpoint:    getString(providerMap, "tts_endpoint"),
-			VoicesEndpoint: getString(providerMap, "voices_endpoint"),
-			HealthEndpoint: getString(providerMap, "health_endpoint"),
-			DefaultStyle:   expandEnvVars(getString(providerMap, "default_style")),
+			Name:            getString(providerMap, "name"),
+			Type:            getString(providerMap, "type"),
+			MaxConcurrent:   getInt(providerMap, "max_concurrent", 4),
+			Timeout:         getDuration(providerMap, "timeout", 30*time.Second),
+			APIKey:          cfg.expandVars(getString(providerMap, "api_key")),
+			APIKeyRef:       getString(providerMap, "api_key"),
+			SecondaryAPIKey: cfg.expandVars(getString(providerMap, "secondary_api_key")),
+			ModelID:         cfg.expandVars(getString(providerMap, "model_id")),
+			BaseURL:         getString(providerMap, "base_url"),
+			TTSEndpoint:     getString(providerMap, "tts_endpoint"),
+			VoicesEndpoint:  getString(providerMap, "voices_endpoint"),
+			HealthEndpoint:  getString(providerMap, "health_endpoint"),
+			DefaultStyle:    cfg.expandVars(getString(providerMap, "default_style")),
+			Binary:          cfg.expandVars(getString(providerMap, "binary")),
+			Models:          getStringSlice(providerMap, "models"),
+			CostPer1KChars:  getFloat(providerMap, "cost_per_1k_chars", 0),
+			CharQuota:       int64(getInt(providerMap, "char_quota", 0)),
+			Fallback:        getStringSlice(providerMap, "fallback"),
+			MaxTextLength:   getInt(providerMap, "max_text_length", 0),
+			ParallelChunks:  getInt(providerMap, "parallel_chunks", 1),
+		}
+
+		for i, path := range pc.Models {
+			pc.Models[i] = cfg.expandVars(path)
 		}
 
 		// Set defaults for selfhosted endpoints
@@ -249,9 +1009,143 @@ func loadProvidersConfig(v *viper.Viper, cfg *Config) error {
 	return nil
 }
 
-// expandEnvVars expands ${VAR} syntax in strings.
-func expandEnvVars(s string) string {
-	return os.Expand(s, os.Getenv)
+// loadWorkerPools loads queue.worker_pools from viper.
+func loadWorkerPools(v *viper.Viper, cfg *Config) error {
+	poolsRaw := v.Get("queue.worker_pools")
+	if poolsRaw == nil {
+		return nil
+	}
+
+	poolsList, ok := poolsRaw.([]interface{})
+	if !ok {
+		return fmt.Errorf("queue.worker_pools must be an array")
+	}
+
+	for _, p := range poolsList {
+		poolMap, ok := p.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("each worker pool must be an object")
+		}
+
+		cfg.Queue.WorkerPools = append(cfg.Queue.WorkerPools, WorkerPoolConfig{
+			Name:      getString(poolMap, "name"),
+			Workers:   getInt(poolMap, "workers", 0),
+			Providers: getStringSlice(poolMap, "providers"),
+		})
+	}
+
+	return nil
+}
+
+// loadRewriteHooks loads rewrite_hooks from viper.
+func loadRewriteHooks(v *viper.Viper, cfg *Config) error {
+	hooksRaw := v.Get("rewrite_hooks")
+	if hooksRaw == nil {
+		return nil
+	}
+
+	hooksList, ok := hooksRaw.([]interface{})
+	if !ok {
+		return fmt.Errorf("rewrite_hooks must be an array")
+	}
+
+	for _, h := range hooksList {
+		hookMap, ok := h.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("each rewrite hook must be an object")
+		}
+
+		var headers map[string]string
+		if headersMap, ok := hookMap["headers"].(map[string]interface{}); ok {
+			headers = make(map[string]string, len(headersMap))
+			for name := range headersMap {
+				headers[name] = cfg.expandVars(getString(headersMap, name))
+			}
+		}
+
+		cfg.RewriteHooks = append(cfg.RewriteHooks, RewriteHookConfig{
+			Name:         getString(hookMap, "name"),
+			Type:         getString(hookMap, "type"),
+			Instructions: getString(hookMap, "instructions"),
+			Model:        getString(hookMap, "model"),
+			URL:          cfg.expandVars(getString(hookMap, "url")),
+			Headers:      headers,
+			Timeout:      getDuration(hookMap, "timeout", 30*time.Second),
+			CacheTTL:     getDuration(hookMap, "cache_ttl", 0),
+		})
+	}
+
+	return nil
+}
+
+// loadAuthConfig loads the auth section from viper.
+func loadAuthConfig(v *viper.Viper, cfg *Config) error {
+	cfg.Auth.AdminKey = cfg.expandVars(v.GetString("auth.admin_key"))
+
+	keysRaw := v.Get("auth.api_keys")
+	if keysRaw == nil {
+		return nil
+	}
+
+	keysList, ok := keysRaw.([]interface{})
+	if !ok {
+		return fmt.Errorf("auth.api_keys must be an array")
+	}
+
+	names := make(map[string]bool, len(keysList))
+	for _, k := range keysList {
+		keyMap, ok := k.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("each API key must be an object")
+		}
+
+		// The name is the key's tenant: jobs, webhooks and quotas belong to it
+		name := getString(keyMap, "name")
+		if name == "" {
+			return fmt.Errorf("auth.api_keys: each API key needs a name")
+		}
+		if names[name] {
+			return fmt.Errorf("auth.api_keys: name %q is used by more than one key", name)
+		}
+		names[name] = true
+
+		cfg.Auth.APIKeys = append(cfg.Auth.APIKeys, APIKeyConfig{
+			Name:         name,
+			Key:          cfg.expandVars(getString(keyMap, "key")),
+			AllowCIDRs:   getStringSlice(keyMap, "allow_cidrs"),
+			DenyCIDRs:    getStringSlice(keyMap, "deny_cidrs"),
+			OutputFormat: getString(keyMap, "output_format"),
+			TextRules:    getTextRules(keyMap),
+		})
+	}
+
+	return nil
+}
+
+// getTextRules reads an API key's text_rules block.
+func getTextRules(keyMap map[string]interface{}) TextRulesConfig {
+	m, _ := keyMap["text_rules"].(map[string]interface{})
+	if m == nil {
+		return TextRulesConfig{}
+	}
+	return TextRulesConfig{
+		AllowedScripts: getStringSlice(m, "allowed_scripts"),
+		AllowedChars:   getString(m, "allowed_chars"),
+		DeniedChars:    getString(m, "denied_chars"),
+		MaxRepeat:      getInt(m, "max_repeat", 0),
+	}
+}
+
+// elevenLabsKeyRef returns the unexpanded legacy ElevenLabs key. When the key isn't
+// set anywhere but the secret store holds ELEVENLABS_API_KEY, that secret is used.
+func (c *Config) elevenLabsKeyRef(v *viper.Viper) string {
+	if raw := v.GetString("tts.elevenlabs_api_key"); raw != "" {
+		return raw
+	}
+	if _, ok := c.secretValues["ELEVENLABS_API_KEY"]; ok {
+		return "${ELEVENLABS_API_KEY}"
+	}
+	return ""
 }
 
 // getString safely gets a string from a map.
@@ -264,6 +1158,21 @@ func getString(m map[string]interface{}, key string) string {
 	return ""
 }
 
+// getStringSlice safely gets a list of strings from a map.
+func getStringSlice(m map[string]interface{}, key string) []string {
+	list, ok := m[key].([]interface{})
+	if !ok {
+		return nil
+	}
+	out := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
 // getInt safely gets an int from a map with a default.
 func getInt(m map[string]interface{}, key string, defaultVal int) int {
 	if v, ok := m[key]; ok {
@@ -279,6 +1188,21 @@ func getInt(m map[string]interface{}, key string, defaultVal int) int {
 	return defaultVal
 }
 
+// getFloat safely gets a float from a map with a default.
+func getFloat(m map[string]interface{}, key string, defaultVal float64) float64 {
+	if v, ok := m[key]; ok {
+		switch val := v.(type) {
+		case float64:
+			return val
+		case int:
+			return float64(val)
+		case int64:
+			return float64(val)
+		}
+	}
+	return defaultVal
+}
+
 // getDuration safely gets a duration from a map with a default.
 func getDuration(m map[string]interface{}, key string, defaultVal time.Duration) time.Duration {
 	if v, ok := m[key]; ok {
@@ -297,6 +1221,266 @@ func (c *Config) Validate() error {
 	if err := c.Providers.Validate(); err != nil {
 		return err
 	}
+
+	if err := c.ValidateRole(); err != nil {
+		return err
+	}
+
+	switch c.TTS.OutOfRangeSettings {
+	case "", SettingsReject, SettingsClamp:
+	default:
+		return fmt.Errorf("unknown tts.out_of_range_settings: %q", c.TTS.OutOfRangeSettings)
+	}
+
+	switch c.Queue.DedupMode {
+	case "", DedupModeOff, DedupModeDetect, DedupModeCoalesce:
+	default:
+		return fmt.Errorf("unknown queue.dedup_mode: %q", c.Queue.DedupMode)
+	}
+
+	switch c.Queue.Backend {
+	case "", QueueBackendMemory:
+	case QueueBackendPostgres:
+		if c.Queue.Postgres.DSN == "" {
+			return fmt.Errorf("queue.postgres.dsn is required for the postgres queue backend")
+		}
+	default:
+		return fmt.Errorf("unknown queue.backend: %q", c.Queue.Backend)
+	}
+
+	switch c.Queue.Dequeue {
+	case "", DequeueBlocking:
+	case DequeuePolling, DequeueBatch:
+		if c.Queue.Backend != QueueBackendPostgres {
+			return fmt.Errorf("queue.dequeue %q needs the postgres queue backend", c.Queue.Dequeue)
+		}
+	default:
+		return fmt.Errorf("unknown queue.dequeue: %q", c.Queue.Dequeue)
+	}
+
+	if c.Queue.HeartbeatInterval < 0 {
+		return fmt.Errorf("queue.heartbeat_interval must not be negative")
+	}
+	if c.Queue.VisibilityTimeout > 0 && c.Queue.HeartbeatInterval >= c.Queue.VisibilityTimeout {
+		return fmt.Errorf("queue.heartbeat_interval must be shorter than queue.visibility_timeout")
+	}
+	if c.Queue.DrainTimeout < 0 {
+		return fmt.Errorf("queue.drain_timeout must not be negative")
+	}
+	if c.Queue.MaxAttempts < 0 {
+		return fmt.Errorf("queue.max_attempts must not be negative")
+	}
+	if c.Queue.RetryMaxDelay > 0 && c.Queue.RetryMaxDelay < c.Queue.RetryBaseDelay {
+		return fmt.Errorf("queue.retry_max_delay must not be shorter than queue.retry_base_delay")
+	}
+	if c.Queue.SilenceThresholdDB > 0 {
+		return fmt.Errorf("queue.silence_threshold_db must not be positive")
+	}
+
+	switch c.Storage.Backend {
+	case "", StorageBackendFilesystem:
+	case StorageBackendGCS:
+		if c.Storage.GCS.Bucket == "" {
+			return fmt.Errorf("storage.gcs.bucket is required for the gcs storage backend")
+		}
+	default:
+		return fmt.Errorf("unknown storage.backend: %q", c.Storage.Backend)
+	}
+
+	switch c.Storage.KeyStrategy {
+	case "", "flat", "sharded", "tenant", "content":
+	default:
+		return fmt.Errorf("unknown storage.key_strategy: %q", c.Storage.KeyStrategy)
+	}
+
+	if c.Storage.ArchiveAfterHours < 0 {
+		return fmt.Errorf("storage.archive_after_hours must not be negative")
+	}
+
+	retention := c.Storage.ArtifactRetention
+	if retention.PreviewHours < 0 || retention.WaveformHours < 0 || retention.VariantHours < 0 {
+		return fmt.Errorf("storage.artifact_retention hours must not be negative")
+	}
+
+	if c.Storage.RedirectResults {
+		if c.Storage.Backend != StorageBackendGCS {
+			return fmt.Errorf("storage.redirect_results needs the gcs storage backend")
+		}
+		// The longest a V4 signed URL may be valid
+		if c.Storage.SignedURLTTL < time.Second || c.Storage.SignedURLTTL > 7*24*time.Hour {
+			return fmt.Errorf("storage.signed_url_ttl must be between 1s and 168h")
+		}
+	}
+
+	if c.Storage.ResultCache && (c.Storage.ResultCachePath == "" || c.Storage.ResultCacheTTL <= 0) {
+		return fmt.Errorf("storage.result_cache needs a storage.result_cache_path and a positive storage.result_cache_ttl")
+	}
+
+	if c.LLM.Endpoint != "" {
+		if u, err := url.Parse(c.LLM.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("llm.endpoint must be an absolute http or https URL")
+		}
+	}
+
+	if err := c.validateRewriteHooks(); err != nil {
+		return err
+	}
+
+	if c.Abuse.Enabled {
+		if err := c.Abuse.validate(); err != nil {
+			return err
+		}
+	}
+
+	// Callers choose the URLs sources fetch, so they may only point at hosts the
+	// operator trusts
+	if c.Features.TextSources && len(c.TextSources.AllowedHosts) == 0 {
+		return fmt.Errorf("features.text_sources needs text_sources.allowed_hosts")
+	}
+
+	if c.Consumer.Backend != "" {
+		if err := c.Consumer.validate(); err != nil {
+			return err
+		}
+		if !c.Features.AsyncJobs {
+			return fmt.Errorf("consumer needs features.async_jobs")
+		}
+	}
+
+	return c.Queue.validateWorkerPools(c.Providers.List)
+}
+
+// ValidateRole checks that server.role is known, and that split roles share
+// their jobs through a queue backend other processes can reach.
+func (c *Config) ValidateRole() error {
+	switch c.Server.Role {
+	case "", RoleAll:
+	case RoleAPI, RoleWorker:
+		if c.Queue.Backend != QueueBackendPostgres {
+			return fmt.Errorf("server.role %q needs the postgres queue backend, which API and worker nodes share", c.Server.Role)
+		}
+	default:
+		return fmt.Errorf("unknown server.role: %q", c.Server.Role)
+	}
+	return nil
+}
+
+// validateRewriteHooks checks that hooks are named uniquely and have what their
+// type needs.
+func (c *Config) validateRewriteHooks() error {
+	names := make(map[string]bool)
+	for _, hook := range c.RewriteHooks {
+		if hook.Name == "" {
+			return fmt.Errorf("rewrite hook name cannot be empty")
+		}
+		if names[hook.Name] {
+			return fmt.Errorf("duplicate rewrite hook name: %q", hook.Name)
+		}
+		names[hook.Name] = true
+		if hook.Timeout <= 0 {
+			return fmt.Errorf("rewrite hook %q: timeout must be positive", hook.Name)
+		}
+		switch hook.Type {
+		case RewriteHookLLM:
+			if c.LLM.Endpoint == "" {
+				return fmt.Errorf("rewrite hook %q: llm hooks need llm.endpoint", hook.Name)
+			}
+			if hook.Instructions == "" {
+				return fmt.Errorf("rewrite hook %q: instructions are required", hook.Name)
+			}
+		case RewriteHookHTTP:
+			if u, err := url.Parse(hook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("rewrite hook %q: url must be an absolute http or https URL", hook.Name)
+			}
+		default:
+			return fmt.Errorf("rewrite hook %q: unknown type %q", hook.Name, hook.Type)
+		}
+	}
+	return nil
+}
+
+// validate checks that the detector has a window to count in and a known action.
+func (a *AbuseConfig) validate() error {
+	if a.Window <= 0 {
+		return fmt.Errorf("abuse.window must be positive")
+	}
+	if a.VolumeFactor > 0 && a.BaselineWindows <= 0 {
+		return fmt.Errorf("abuse.baseline_windows must be positive to detect volume spikes")
+	}
+	switch a.Action {
+	case AbuseActionLog, AbuseActionQuarantine:
+	case AbuseActionThrottle:
+		if a.ThrottlePerMinute <= 0 {
+			return fmt.Errorf("abuse.throttle_per_minute must be positive")
+		}
+	default:
+		return fmt.Errorf("unknown abuse.action: %q", a.Action)
+	}
+	return nil
+}
+
+// validate checks that the consumer has a known broker and a topic to read.
+func (c *ConsumerConfig) validate() error {
+	switch c.Backend {
+	case ConsumerBackendRedis, ConsumerBackendNATS:
+		if c.Topic == "" {
+			return fmt.Errorf("consumer.topic is required for the %s consumer", c.Backend)
+		}
+	case ConsumerBackendSQS:
+	default:
+		return fmt.Errorf("unknown consumer.backend: %q", c.Backend)
+	}
+	if c.URL == "" {
+		return fmt.Errorf("consumer.url is required")
+	}
+	if c.PollInterval <= 0 {
+		return fmt.Errorf("consumer.poll_interval must be positive")
+	}
+	if c.PublicURL != "" {
+		if u, err := url.Parse(c.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("consumer.public_url must be an absolute http or https URL")
+		}
+	}
+	return nil
+}
+
+// validateWorkerPools checks that pools are named uniquely, have workers and pin
+// configured providers, each to at most one pool.
+func (q *QueueConfig) validateWorkerPools(providers []ProviderConfig) error {
+	known := make(map[string]bool, len(providers))
+	for _, p := range providers {
+		known[p.Name] = true
+	}
+
+	names := make(map[string]bool)
+	pinned := make(map[string]string)
+	for _, pool := range q.WorkerPools {
+		if pool.Name == "" {
+			return fmt.Errorf("worker pool name cannot be empty")
+		}
+		if pool.Name == "default" {
+			return fmt.Errorf("worker pool name %q is reserved for the general workers", pool.Name)
+		}
+		if names[pool.Name] {
+			return fmt.Errorf("duplicate worker pool name: %q", pool.Name)
+		}
+		names[pool.Name] = true
+		if pool.Workers <= 0 {
+			return fmt.Errorf("worker pool %q must have at least one worker", pool.Name)
+		}
+		if len(pool.Providers) == 0 {
+			return fmt.Errorf("worker pool %q must list at least one provider", pool.Name)
+		}
+		for _, provider := range pool.Providers {
+			if !known[provider] {
+				return fmt.Errorf("worker pool %q: provider %q not found in providers list", pool.Name, provider)
+			}
+			if other, ok := pinned[provider]; ok {
+				return fmt.Errorf("provider %q is pinned to worker pools %q and %q", provider, other, pool.Name)
+			}
+			pinned[provider] = pool.Name
+		}
+	}
 	return nil
 }
 
@@ -320,6 +1504,28 @@ func (p *ProvidersConfig) Validate() error {
 			return fmt.Errorf("duplicate provider name: %q", provider.Name)
 		}
 		names[provider.Name] = true
+		if provider.MaxTextLength < 0 {
+			return fmt.Errorf("provider %q max_text_length must be non-negative", provider.Name)
+		}
+		if provider.ParallelChunks < 0 {
+			return fmt.Errorf("provider %q parallel_chunks must be non-negative", provider.Name)
+		}
+	}
+
+	// Fallbacks must name other configured providers, each once
+	for _, provider := range p.List {
+		seen := make(map[string]bool, len(provider.Fallback))
+		for _, name := range provider.Fallback {
+			switch {
+			case name == provider.Name:
+				return fmt.Errorf("provider %q cannot be its own fallback", provider.Name)
+			case !names[name]:
+				return fmt.Errorf("fallback provider %q of %q not found in providers list", name, provider.Name)
+			case seen[name]:
+				return fmt.Errorf("duplicate fallback provider %q of %q", name, provider.Name)
+			}
+			seen[name] = true
+		}
 	}
 
 	// Default provider must exist in the list
@@ -330,5 +1536,20 @@ func (p *ProvidersConfig) Validate() error {
 		return fmt.Errorf("default provider %q not found in providers list", p.Default)
 	}
 
+	switch p.Routing.Policy {
+	case "", RoutingPolicyPrimary, RoutingPolicyPrimaryWithFailover, RoutingPolicyFastest, RoutingPolicyCheapest:
+	default:
+		return fmt.Errorf("unknown routing policy: %q", p.Routing.Policy)
+	}
+
+	if d := p.Degradation; d.RateLimits > 0 {
+		if d.Window <= 0 || d.Recovery <= 0 {
+			return fmt.Errorf("providers.degradation needs a positive window and recovery")
+		}
+		if d.Concurrency <= 0 || d.Concurrency > 1 {
+			return fmt.Errorf("providers.degradation.concurrency must be in (0, 1]")
+		}
+	}
+
 	return nil
 }
@@ -3,7 +3,9 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoadProvidersConfig_ReadsModelID(t *testing.T) {
@@ -106,3 +108,696 @@ providers:
 		t.Errorf("expected DefaultStyle '' (omitted in yaml), got %q", got)
 	}
 }
+
+func TestLoadProvidersConfig_ReadsRouting(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	yaml := `
+providers:
+  default: "elevenlabs"
+  routing:
+    policy: "cheapest"
+    max_error_rate: 0.25
+  list:
+    - name: "elevenlabs"
+      type: "elevenlabs"
+      api_key: "test-key"
+      cost_per_1k_chars: 0.3
+      char_quota: 100000
+`
+	if err := os.WriteFile(cfgPath, []byte(yaml), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chdir(cwd)
+	})
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if got := cfg.Providers.Routing.Policy; got != RoutingPolicyCheapest {
+		t.Errorf("expected routing policy %q, got %q", RoutingPolicyCheapest, got)
+	}
+	if got := cfg.Providers.Routing.MaxErrorRate; got != 0.25 {
+		t.Errorf("expected max_error_rate 0.25, got %v", got)
+	}
+	if got := cfg.Providers.List[0].CostPer1KChars; got != 0.3 {
+		t.Errorf("expected cost_per_1k_chars 0.3, got %v", got)
+	}
+	if got := cfg.Providers.List[0].CharQuota; got != 100000 {
+		t.Errorf("expected char_quota 100000, got %d", got)
+	}
+}
+
+func TestLoad_ReadsAuthAndIPFilter(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	yaml := `
+auth:
+  admin_key: "${TEST_PAKO_ADMIN_KEY}"
+  api_keys:
+    - name: "backend"
+      key: "${TEST_PAKO_API_KEY}"
+      allow_cidrs: ["10.0.0.0/8"]
+      deny_cidrs: ["10.9.0.0/16"]
+      output_format: "wav"
+      text_rules:
+        allowed_scripts: ["latin", "digits"]
+        denied_chars: "<>"
+        max_repeat: 5
+ip_filter:
+  allow_cidrs: ["10.0.0.0/8", "192.168.0.0/16"]
+  trusted_proxies: ["10.0.0.2"]
+`
+	if err := os.WriteFile(cfgPath, []byte(yaml), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	t.Setenv("TEST_PAKO_API_KEY", "secret")
+	t.Setenv("TEST_PAKO_ADMIN_KEY", "admin-secret")
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chdir(cwd)
+	})
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if cfg.Auth.AdminKey != "admin-secret" {
+		t.Errorf("expected admin key to be expanded, got %q", cfg.Auth.AdminKey)
+	}
+	if len(cfg.Auth.APIKeys) != 1 {
+		t.Fatalf("expected 1 API key, got %d", len(cfg.Auth.APIKeys))
+	}
+	key := cfg.Auth.APIKeys[0]
+	if key.Name != "backend" || key.Key != "secret" {
+		t.Errorf("expected backend/secret, got %s/%s", key.Name, key.Key)
+	}
+	if len(key.AllowCIDRs) != 1 || key.AllowCIDRs[0] != "10.0.0.0/8" {
+		t.Errorf("unexpected allow_cidrs %v", key.AllowCIDRs)
+	}
+	if len(key.DenyCIDRs) != 1 || key.DenyCIDRs[0] != "10.9.0.0/16" {
+		t.Errorf("unexpected deny_cidrs %v", key.DenyCIDRs)
+	}
+	if key.OutputFormat != "wav" {
+		t.Errorf("expected output_format wav, got %q", key.OutputFormat)
+	}
+	if rules := key.TextRules; len(rules.AllowedScripts) != 2 || rules.DeniedChars != "<>" || rules.MaxRepeat != 5 {
+		t.Errorf("unexpected text_rules %+v", rules)
+	}
+	if len(cfg.IPFilter.AllowCIDRs) != 2 {
+		t.Errorf("expected 2 global allow entries, got %v", cfg.IPFilter.AllowCIDRs)
+	}
+	if len(cfg.IPFilter.TrustedProxies) != 1 || cfg.IPFilter.TrustedProxies[0] != "10.0.0.2" {
+		t.Errorf("unexpected trusted_proxies %v", cfg.IPFilter.TrustedProxies)
+	}
+}
+
+func TestLoad_RejectsUnnamedAndDuplicateAPIKeys(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chdir(cwd)
+	})
+
+	for name, tt := range map[string]struct {
+		keys string
+		want string
+	}{
+		"unnamed": {`
+    - key: "k1"
+`, "needs a name"},
+		"duplicate": {`
+    - name: "backend"
+      key: "k1"
+    - name: "backend"
+      key: "k2"
+`, `"backend" is used by more than one key`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("auth:\n  api_keys:"+tt.keys), 0o600); err != nil {
+				t.Fatalf("write config: %v", err)
+			}
+			if err := os.Chdir(dir); err != nil {
+				t.Fatalf("chdir: %v", err)
+			}
+
+			if _, err := Load(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestLoad_ReadsWorkerPools(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	yaml := `
+providers:
+  default: "elevenlabs"
+  list:
+    - name: "elevenlabs"
+      type: "elevenlabs"
+      api_key: "test-key"
+    - name: "piper"
+      type: "selfhosted"
+      base_url: "http://localhost:5000"
+queue:
+  worker_count: 2
+  worker_pools:
+    - name: "local"
+      workers: 3
+      providers: ["piper"]
+`
+	if err := os.WriteFile(cfgPath, []byte(yaml), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chdir(cwd)
+	})
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.Queue.WorkerPools) != 1 {
+		t.Fatalf("expected 1 worker pool, got %d", len(cfg.Queue.WorkerPools))
+	}
+	pool := cfg.Queue.WorkerPools[0]
+	if pool.Name != "local" || pool.Workers != 3 || len(pool.Providers) != 1 || pool.Providers[0] != "piper" {
+		t.Errorf("unexpected worker pool %+v", pool)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	invalid := []WorkerPoolConfig{
+		{Name: "default", Workers: 1, Providers: []string{"piper"}},
+		{Name: "local", Workers: 0, Providers: []string{"piper"}},
+		{Name: "local", Workers: 1},
+		{Name: "local", Workers: 1, Providers: []string{"missing"}},
+	}
+	for _, p := range invalid {
+		cfg.Queue.WorkerPools = []WorkerPoolConfig{p}
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("expected %+v to be rejected", p)
+		}
+	}
+	cfg.Queue.WorkerPools = []WorkerPoolConfig{
+		{Name: "a", Workers: 1, Providers: []string{"piper"}},
+		{Name: "b", Workers: 1, Providers: []string{"piper"}},
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected a provider pinned to two pools to be rejected")
+	}
+}
+
+func TestLoad_ReadsRewriteHooks(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	yaml := `
+providers:
+  default: "elevenlabs"
+  list:
+    - name: "elevenlabs"
+      type: "elevenlabs"
+      api_key: "test-key"
+llm:
+  endpoint: "http://localhost:11434/v1/chat/completions"
+rewrite_hooks:
+  - name: "friendly"
+    type: "llm"
+    instructions: "Rewrite the text in a warm, friendly tone."
+    cache_ttl: "10m"
+  - name: "ssml"
+    type: "http"
+    url: "https://ssml.example.com/convert"
+    headers:
+      Authorization: "Bearer ${TEST_PAKO_HOOK_TOKEN}"
+    timeout: "5s"
+`
+	if err := os.WriteFile(cfgPath, []byte(yaml), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	t.Setenv("TEST_PAKO_HOOK_TOKEN", "hook-secret")
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chdir(cwd)
+	})
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.RewriteHooks) != 2 {
+		t.Fatalf("expected 2 rewrite hooks, got %d", len(cfg.RewriteHooks))
+	}
+	if friendly := cfg.RewriteHooks[0]; friendly.Type != RewriteHookLLM || friendly.Timeout != 30*time.Second || friendly.CacheTTL != 10*time.Minute {
+		t.Errorf("unexpected llm hook %+v", friendly)
+	}
+	// viper lowercases map keys, which HTTP header names don't mind
+	if ssml := cfg.RewriteHooks[1]; ssml.Timeout != 5*time.Second || ssml.Headers["authorization"] != "Bearer hook-secret" {
+		t.Errorf("unexpected http hook %+v", ssml)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	invalid := []RewriteHookConfig{
+		{Type: RewriteHookHTTP, URL: "https://ssml.example.com", Timeout: time.Second},
+		{Name: "ssml", Type: "grpc", Timeout: time.Second},
+		{Name: "ssml", Type: RewriteHookHTTP, URL: "ssml.example.com", Timeout: time.Second},
+		{Name: "friendly", Type: RewriteHookLLM, Timeout: time.Second},
+	}
+	for _, h := range invalid {
+		cfg.RewriteHooks = []RewriteHookConfig{h}
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("expected %+v to be rejected", h)
+		}
+	}
+	cfg.RewriteHooks = []RewriteHookConfig{
+		{Name: "ssml", Type: RewriteHookHTTP, URL: "https://ssml.example.com", Timeout: time.Second},
+		{Name: "ssml", Type: RewriteHookHTTP, URL: "https://ssml.example.com", Timeout: time.Second},
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected duplicate hook names to be rejected")
+	}
+}
+
+func TestConfig_Redacted(t *testing.T) {
+	cfg := &Config{
+		Queue: QueueConfig{WorkerCount: 2, EnqueueWait: 200 * time.Millisecond},
+		Providers: ProvidersConfig{
+			Default: "elevenlabs",
+			List: []ProviderConfig{
+				{Name: "elevenlabs", Type: "elevenlabs", APIKey: "sk-live", APIKeyRef: "${ELEVENLABS_API_KEY}"},
+			},
+		},
+		Auth: AuthConfig{APIKeys: []APIKeyConfig{{Name: "backend", Key: "secret"}}},
+	}
+
+	view := cfg.Redacted()
+
+	queue := view["queue"].(map[string]any)
+	if queue["worker_count"] != 2 || queue["enqueue_wait"] != "200ms" {
+		t.Errorf("unexpected queue view %v", queue)
+	}
+	provider := view["providers"].(map[string]any)["list"].([]any)[0].(map[string]any)
+	if provider["api_key"] != RedactedValue || provider["name"] != "elevenlabs" {
+		t.Errorf("unexpected provider view %v", provider)
+	}
+	if _, ok := provider["APIKeyRef"]; ok {
+		t.Error("expected untagged fields to be left out")
+	}
+	if provider["secondary_api_key"] != "" {
+		t.Errorf("expected an unset secret to stay empty, got %v", provider["secondary_api_key"])
+	}
+	auth := view["auth"].(map[string]any)
+	if key := auth["api_keys"].([]any)[0].(map[string]any); key["key"] != RedactedValue {
+		t.Errorf("expected API key to be redacted, got %v", key["key"])
+	}
+}
+
+func TestValidate_QueueBackend(t *testing.T) {
+	cfg := &Config{
+		Providers: ProvidersConfig{
+			Default: "elevenlabs",
+			List:    []ProviderConfig{{Name: "elevenlabs", Type: "elevenlabs", APIKey: "test-key"}},
+		},
+	}
+	for _, backend := range []string{"", QueueBackendMemory} {
+		cfg.Queue.Backend = backend
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("backend %q: %v", backend, err)
+		}
+	}
+
+	cfg.Queue.Backend = QueueBackendPostgres
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected the postgres backend without a DSN to be rejected")
+	}
+	cfg.Queue.Postgres.DSN = "postgres://pako@localhost/pako"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("postgres backend: %v", err)
+	}
+
+	cfg.Queue.Backend = "redis"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected an unknown backend to be rejected")
+	}
+}
+
+func TestValidate_StorageBackend(t *testing.T) {
+	cfg := &Config{
+		Providers: ProvidersConfig{
+			Default: "elevenlabs",
+			List:    []ProviderConfig{{Name: "elevenlabs", Type: "elevenlabs", APIKey: "test-key"}},
+		},
+	}
+	for _, backend := range []string{"", StorageBackendFilesystem} {
+		cfg.Storage.Backend = backend
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("backend %q: %v", backend, err)
+		}
+	}
+
+	cfg.Storage.Backend = StorageBackendGCS
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected the gcs backend without a bucket to be rejected")
+	}
+	cfg.Storage.GCS.Bucket = "pako-results"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("gcs backend: %v", err)
+	}
+
+	cfg.Storage.RedirectResults = true
+	cfg.Storage.SignedURLTTL = 15 * time.Minute
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("gcs backend with result redirects: %v", err)
+	}
+	cfg.Storage.SignedURLTTL = 8 * 24 * time.Hour
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected a signed URL TTL over 7 days to be rejected")
+	}
+	cfg.Storage.SignedURLTTL = 15 * time.Minute
+	cfg.Storage.Backend = StorageBackendFilesystem
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected result redirects from the filesystem backend to be rejected")
+	}
+	cfg.Storage.RedirectResults = false
+
+	cfg.Storage.Backend = "s3"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected an unknown backend to be rejected")
+	}
+}
+
+func TestLoad_ArtifactRetention(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("STORAGE_ARTIFACT_RETENTION_PREVIEW_HOURS", "720")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	retention := cfg.Storage.ArtifactRetention
+	if retention.PreviewHours != 720 || retention.WaveformHours != 0 || retention.VariantHours != 0 {
+		t.Errorf("unexpected artifact retention %+v", retention)
+	}
+
+	cfg.Storage.ArtifactRetention.WaveformHours = -1
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected a negative artifact retention to be rejected")
+	}
+}
+
+func TestValidate_QueueDequeue(t *testing.T) {
+	cfg := &Config{
+		Providers: ProvidersConfig{
+			Default: "elevenlabs",
+			List:    []ProviderConfig{{Name: "elevenlabs", Type: "elevenlabs", APIKey: "test-key"}},
+		},
+	}
+	for dequeue, valid := range map[string]bool{
+		"":              true,
+		DequeueBlocking: true,
+		DequeuePolling:  false,
+		DequeueBatch:    false,
+		"push":          false,
+	} {
+		cfg.Queue.Dequeue = dequeue
+		if err := cfg.Validate(); (err == nil) != valid {
+			t.Errorf("memory backend, dequeue %q: expected valid=%v, got %v", dequeue, valid, err)
+		}
+	}
+
+	cfg.Queue.Backend = QueueBackendPostgres
+	cfg.Queue.Postgres.DSN = "postgres://pako@localhost/pako"
+	for _, dequeue := range []string{DequeueBlocking, DequeuePolling, DequeueBatch} {
+		cfg.Queue.Dequeue = dequeue
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("postgres backend, dequeue %q: %v", dequeue, err)
+		}
+	}
+}
+
+func TestValidate_ServerRole(t *testing.T) {
+	cfg := &Config{
+		Providers: ProvidersConfig{
+			Default: "elevenlabs",
+			List:    []ProviderConfig{{Name: "elevenlabs", Type: "elevenlabs", APIKey: "test-key"}},
+		},
+	}
+	for role, valid := range map[string]bool{
+		"":         true,
+		RoleAll:    true,
+		RoleAPI:    false,
+		RoleWorker: false,
+		"gateway":  false,
+	} {
+		cfg.Server.Role = role
+		if err := cfg.Validate(); (err == nil) != valid {
+			t.Errorf("memory backend, role %q: expected valid=%v, got %v", role, valid, err)
+		}
+	}
+
+	cfg.Queue.Backend = QueueBackendPostgres
+	cfg.Queue.Postgres.DSN = "postgres://pako@localhost/pako"
+	for _, role := range []string{RoleAll, RoleAPI, RoleWorker} {
+		cfg.Server.Role = role
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("postgres backend, role %q: %v", role, err)
+		}
+	}
+}
+
+func TestValidate_LLMEndpoint(t *testing.T) {
+	cfg := &Config{
+		Providers: ProvidersConfig{
+			Default: "elevenlabs",
+			List:    []ProviderConfig{{Name: "elevenlabs", Type: "elevenlabs", APIKey: "test-key"}},
+		},
+	}
+	for endpoint, valid := range map[string]bool{
+		"": true,
+		"https://api.openai.com/v1/chat/completions": true,
+		"http://localhost:11434/v1/chat/completions": true,
+		"api.openai.com/v1/chat/completions":         false,
+		"ftp://models.example.com":                   false,
+	} {
+		cfg.LLM.Endpoint = endpoint
+		if err := cfg.Validate(); (err == nil) != valid {
+			t.Errorf("llm.endpoint %q: expected valid=%v, got %v", endpoint, valid, err)
+		}
+	}
+}
+
+func TestValidate_ResultCache(t *testing.T) {
+	cfg := &Config{
+		Providers: ProvidersConfig{
+			Default: "elevenlabs",
+			List:    []ProviderConfig{{Name: "elevenlabs", Type: "elevenlabs", APIKey: "test-key"}},
+		},
+	}
+	for _, tt := range []struct {
+		storage StorageConfig
+		valid   bool
+	}{
+		{StorageConfig{}, true},
+		{StorageConfig{ResultCache: true, ResultCachePath: "./result_cache", ResultCacheTTL: time.Hour}, true},
+		{StorageConfig{ResultCache: true, ResultCacheTTL: time.Hour}, false},
+		{StorageConfig{ResultCache: true, ResultCachePath: "./result_cache"}, false},
+	} {
+		cfg.Storage = tt.storage
+		if err := cfg.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%+v: expected valid=%v, got %v", tt.storage, tt.valid, err)
+		}
+	}
+}
+
+func TestValidate_Abuse(t *testing.T) {
+	cfg := &Config{
+		Providers: ProvidersConfig{
+			Default: "elevenlabs",
+			List:    []ProviderConfig{{Name: "elevenlabs", Type: "elevenlabs", APIKey: "test-key"}},
+		},
+	}
+	for _, tt := range []struct {
+		abuse AbuseConfig
+		valid bool
+	}{
+		{AbuseConfig{Action: "ban"}, true}, // not checked while disabled
+		{AbuseConfig{Enabled: true, Window: time.Hour, Action: AbuseActionQuarantine}, true},
+		{AbuseConfig{Enabled: true, Window: time.Hour, Action: AbuseActionThrottle, ThrottlePerMinute: 6}, true},
+		{AbuseConfig{Enabled: true, Window: time.Hour, Action: AbuseActionThrottle}, false},
+		{AbuseConfig{Enabled: true, Window: time.Hour, Action: "ban"}, false},
+		{AbuseConfig{Enabled: true, Action: AbuseActionLog}, false},
+		{AbuseConfig{Enabled: true, Window: time.Hour, VolumeFactor: 100, Action: AbuseActionLog}, false},
+	} {
+		cfg.Abuse = tt.abuse
+		if err := cfg.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%+v: expected valid=%v, got %v", tt.abuse, tt.valid, err)
+		}
+	}
+}
+
+func TestValidate_TextSources(t *testing.T) {
+	cfg := &Config{
+		Providers: ProvidersConfig{
+			Default: "elevenlabs",
+			List:    []ProviderConfig{{Name: "elevenlabs", Type: "elevenlabs", APIKey: "test-key"}},
+		},
+		Features: FeaturesConfig{TextSources: true},
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected an error without allowed hosts")
+	}
+	cfg.TextSources.AllowedHosts = []string{"docs.example.com"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
+func TestValidate_Consumer(t *testing.T) {
+	cfg := &Config{
+		Providers: ProvidersConfig{
+			Default: "elevenlabs",
+			List:    []ProviderConfig{{Name: "elevenlabs", Type: "elevenlabs", APIKey: "test-key"}},
+		},
+		Features: FeaturesConfig{AsyncJobs: true},
+	}
+	redis := ConsumerConfig{Backend: ConsumerBackendRedis, URL: "redis://redis:6379", Topic: "tts", PollInterval: time.Second}
+	for _, tt := range []struct {
+		consumer ConsumerConfig
+		valid    bool
+	}{
+		{ConsumerConfig{}, true},
+		{redis, true},
+		{ConsumerConfig{Backend: ConsumerBackendSQS, URL: "https://sqs.eu-west-1.amazonaws.com/123/tts", PollInterval: time.Second}, true},
+		{ConsumerConfig{Backend: ConsumerBackendNATS, URL: "nats://nats:4222", PollInterval: time.Second}, false},
+		{ConsumerConfig{Backend: "kafka", URL: "kafka:9092", Topic: "tts", PollInterval: time.Second}, false},
+		{ConsumerConfig{Backend: ConsumerBackendRedis, Topic: "tts", PollInterval: time.Second}, false},
+		{ConsumerConfig{Backend: ConsumerBackendRedis, URL: "redis://redis:6379", Topic: "tts"}, false},
+		{ConsumerConfig{Backend: ConsumerBackendRedis, URL: "redis://redis:6379", Topic: "tts", PollInterval: time.Second, PublicURL: "tts.example.com"}, false},
+	} {
+		cfg.Consumer = tt.consumer
+		if err := cfg.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%+v: expected valid=%v, got %v", tt.consumer, tt.valid, err)
+		}
+	}
+
+	// Requests become async jobs
+	cfg.Consumer = redis
+	cfg.Features.AsyncJobs = false
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected an error without async jobs")
+	}
+}
+
+func TestValidate_ProviderFallbacks(t *testing.T) {
+	tests := map[string]struct {
+		fallback []string
+		valid    bool
+	}{
+		"chain":     {fallback: []string{"gemini", "piper"}, valid: true},
+		"self":      {fallback: []string{"elevenlabs"}},
+		"unknown":   {fallback: []string{"openai"}},
+		"duplicate": {fallback: []string{"piper", "piper"}},
+	}
+	for name, tt := range tests {
+		cfg := &Config{
+			Providers: ProvidersConfig{
+				Default: "elevenlabs",
+				List: []ProviderConfig{
+					{Name: "elevenlabs", Type: "elevenlabs", APIKey: "test-key", Fallback: tt.fallback},
+					{Name: "gemini", Type: "gemini", APIKey: "test-key"},
+					{Name: "piper", Type: "piper", Models: []string{"/models/piper"}},
+				},
+			},
+		}
+		if err := cfg.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: valid = %v, got error %v", name, tt.valid, err)
+		}
+	}
+}
+
+func TestLoadProvidersConfig_ReadsPiperModels(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	yaml := `
+providers:
+  default: "piper"
+  list:
+    - name: "piper"
+      type: "piper"
+      binary: "/opt/piper/piper"
+      models:
+        - "${PIPER_MODEL_DIR}"
+        - "/models/extra/en_GB-alan-low.onnx"
+      model_id: "en_US-lessac-medium"
+`
+	if err := os.WriteFile(cfgPath, []byte(yaml), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	t.Setenv("PIPER_MODEL_DIR", "/models/piper")
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chdir(cwd)
+	})
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	p := cfg.Providers.List[0]
+	if p.Binary != "/opt/piper/piper" {
+		t.Errorf("expected Binary '/opt/piper/piper', got %q", p.Binary)
+	}
+	if len(p.Models) != 2 || p.Models[0] != "/models/piper" || p.Models[1] != "/models/extra/en_GB-alan-low.onnx" {
+		t.Errorf("unexpected Models %q", p.Models)
+	}
+}
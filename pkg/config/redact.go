@@ -0,0 +1,51 @@
+package config
+
+import (
+	"reflect"
+	"time"
+)
+
+// RedactedValue replaces every configured secret in a Redacted view.
+const RedactedValue = "[redacted]"
+
+// Redacted returns the effective configuration as nested maps keyed like the config
+// file, with secrets (fields tagged `secret:"true"`) replaced by RedactedValue.
+// Unset secrets stay empty, so the view still shows which ones are missing.
+func (c *Config) Redacted() map[string]any {
+	out, _ := redact(reflect.ValueOf(*c)).(map[string]any)
+	return out
+}
+
+func redact(v reflect.Value) any {
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		out := make(map[string]any)
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := field.Tag.Get("mapstructure")
+			if !field.IsExported() || name == "" || name == "-" {
+				continue
+			}
+			value := v.Field(i)
+			if field.Tag.Get("secret") == "true" && !value.IsZero() {
+				out[name] = RedactedValue
+				continue
+			}
+			out[name] = redact(value)
+		}
+		return out
+	case reflect.Slice:
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i] = redact(v.Index(i))
+		}
+		return out
+	default:
+		return v.Interface()
+	}
+}
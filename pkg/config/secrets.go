@@ -0,0 +1,188 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Secret store backends.
+const (
+	SecretsBackendVault = "vault"
+	SecretsBackendAWS   = "aws"
+)
+
+// secretFetchTimeout bounds a single read from the secret store.
+const secretFetchTimeout = 15 * time.Second
+
+// SecretsConfig selects an external secret store. When a backend is set, ${NAME}
+// references in api_key (and other expanded) fields resolve against the store
+// first and fall back to environment variables.
+type SecretsConfig struct {
+	Backend string `mapstructure:"backend"` // "", "vault", or "aws"
+	// RefreshInterval is how often secrets are re-read so rotated provider keys take
+	// effect without a restart; 0 reads them once at startup.
+	RefreshInterval time.Duration    `mapstructure:"refresh_interval"`
+	Vault           VaultConfig      `mapstructure:"vault"`
+	AWS             AWSSecretsConfig `mapstructure:"aws"`
+}
+
+// VaultConfig points at a HashiCorp Vault KV v2 secret, and says how to log in.
+type VaultConfig struct {
+	Address   string `mapstructure:"address"`             // defaults to VAULT_ADDR
+	Token     string `mapstructure:"token" secret:"true"` // defaults to VAULT_TOKEN
+	Namespace string `mapstructure:"namespace"`           // Vault Enterprise namespace
+	Mount     string `mapstructure:"mount"`               // KV v2 mount, default "secret"
+	Path      string `mapstructure:"path"`                // secret path within the mount
+	// AuthMethod is VaultAuthToken (the default), VaultAuthAppRole or
+	// VaultAuthKubernetes.
+	AuthMethod string `mapstructure:"auth_method"`
+	AuthMount  string `mapstructure:"auth_mount"` // auth method mount, default its name
+	// RoleID and SecretID log in with AppRole.
+	RoleID   string `mapstructure:"role_id"`
+	SecretID string `mapstructure:"secret_id" secret:"true"`
+	// Role logs in with the pod's service account token, at KubernetesTokenPath.
+	Role                string `mapstructure:"role"`
+	KubernetesTokenPath string `mapstructure:"kubernetes_token_path"`
+}
+
+// AWSSecretsConfig points at an AWS Secrets Manager secret holding a JSON object.
+// Credentials are found the way the AWS SDK does: AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN, the shared config files, else the
+// role the server runs as.
+type AWSSecretsConfig struct {
+	Region   string `mapstructure:"region"`    // defaults to AWS_REGION
+	SecretID string `mapstructure:"secret_id"` // secret name or ARN
+	Endpoint string `mapstructure:"endpoint"`  // optional override (VPC endpoint, LocalStack)
+}
+
+// SecretSource reads a flat set of named secrets from an external store.
+type SecretSource interface {
+	FetchSecrets(ctx context.Context) (map[string]string, error)
+}
+
+// NewSecretSource creates the secret source selected by cfg.Backend.
+func NewSecretSource(cfg SecretsConfig) (SecretSource, error) {
+	switch cfg.Backend {
+	case SecretsBackendVault:
+		return newVaultSource(cfg.Vault)
+	case SecretsBackendAWS:
+		return newAWSSource(cfg.AWS)
+	default:
+		return nil, fmt.Errorf("unknown secrets backend: %q", cfg.Backend)
+	}
+}
+
+// loadSecretsConfig reads the secrets section from viper.
+func loadSecretsConfig(v *viper.Viper) SecretsConfig {
+	refresh, err := time.ParseDuration(v.GetString("secrets.refresh_interval"))
+	if err != nil {
+		refresh = 0
+	}
+
+	cfg := SecretsConfig{
+		Backend:         v.GetString("secrets.backend"),
+		RefreshInterval: refresh,
+		Vault: VaultConfig{
+			Address:             v.GetString("secrets.vault.address"),
+			Token:               v.GetString("secrets.vault.token"),
+			Namespace:           v.GetString("secrets.vault.namespace"),
+			Mount:               v.GetString("secrets.vault.mount"),
+			Path:                v.GetString("secrets.vault.path"),
+			AuthMethod:          v.GetString("secrets.vault.auth_method"),
+			AuthMount:           v.GetString("secrets.vault.auth_mount"),
+			RoleID:              v.GetString("secrets.vault.role_id"),
+			SecretID:            v.GetString("secrets.vault.secret_id"),
+			Role:                v.GetString("secrets.vault.role"),
+			KubernetesTokenPath: v.GetString("secrets.vault.kubernetes_token_path"),
+		},
+		AWS: AWSSecretsConfig{
+			Region:   v.GetString("secrets.aws.region"),
+			SecretID: v.GetString("secrets.aws.secret_id"),
+			Endpoint: v.GetString("secrets.aws.endpoint"),
+		},
+	}
+
+	// Fall back to the variables the Vault and AWS CLIs already use.
+	if cfg.Vault.Address == "" {
+		cfg.Vault.Address = os.Getenv("VAULT_ADDR")
+	}
+	if cfg.Vault.Token == "" {
+		cfg.Vault.Token = os.Getenv("VAULT_TOKEN")
+	}
+	if cfg.Vault.Namespace == "" {
+		cfg.Vault.Namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+	if cfg.AWS.Region == "" {
+		cfg.AWS.Region = os.Getenv("AWS_REGION")
+	}
+
+	return cfg
+}
+
+// loadSecrets connects to the configured secret store and reads it once.
+func (c *Config) loadSecrets() error {
+	if c.Secrets.Backend == "" {
+		return nil
+	}
+
+	source, err := NewSecretSource(c.Secrets)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), secretFetchTimeout)
+	defer cancel()
+
+	values, err := source.FetchSecrets(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load secrets from %s: %w", c.Secrets.Backend, err)
+	}
+
+	c.secretSource = source
+	c.secretValues = values
+	return nil
+}
+
+// RefreshSecrets re-reads the secret store and re-resolves provider API keys.
+// It returns the new key of every provider whose key changed, keyed by provider
+// name. A key whose secret disappeared is kept rather than cleared.
+func (c *Config) RefreshSecrets(ctx context.Context) (map[string]string, error) {
+	if c.secretSource == nil {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, secretFetchTimeout)
+	defer cancel()
+
+	values, err := c.secretSource.FetchSecrets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.secretValues = values
+
+	changed := make(map[string]string)
+	for i := range c.Providers.List {
+		p := &c.Providers.List[i]
+		key := c.expandVars(p.APIKeyRef)
+		if key != "" && key != p.APIKey {
+			p.APIKey = key
+			changed[p.Name] = key
+		}
+	}
+	return changed, nil
+}
+
+// expandVars expands ${VAR} syntax, preferring values from the secret store over
+// environment variables.
+func (c *Config) expandVars(s string) string {
+	return os.Expand(s, func(name string) string {
+		if val, ok := c.secretValues[name]; ok {
+			return val
+		}
+		return os.Getenv(name)
+	})
+}
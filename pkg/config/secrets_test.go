@@ -0,0 +1,353 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestVaultSource_FetchSecrets(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/auth/token/lookup-self":
+			// A root token doesn't expire
+			_, _ = w.Write([]byte(`{"data":{"ttl":0,"renewable":false}}`))
+		case "/v1/kv/data/pako-tts":
+			_, _ = w.Write([]byte(`{"data":{"data":{"ELEVENLABS_API_KEY":"el-key","PORT":8080},"metadata":{"version":3}}}`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	src, err := newVaultSource(VaultConfig{Address: srv.URL, Token: "root", Mount: "kv", Path: "pako-tts"})
+	if err != nil {
+		t.Fatalf("newVaultSource: %v", err)
+	}
+
+	values, err := src.FetchSecrets(context.Background())
+	if err != nil {
+		t.Fatalf("FetchSecrets: %v", err)
+	}
+	if values["ELEVENLABS_API_KEY"] != "el-key" || values["PORT"] != "8080" {
+		t.Errorf("unexpected values %v", values)
+	}
+
+	src.token = "wrong"
+	if _, err := src.FetchSecrets(context.Background()); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("expected 403 error, got %v", err)
+	}
+}
+
+// fakeVault serves a KV v2 secret to the tokens it issued and not revoked, and
+// counts the requests to each path.
+type fakeVault struct {
+	mu     sync.Mutex
+	tokens map[string]bool
+	issued int
+	calls  map[string]int
+	// ttl and renewable describe the tokens issued.
+	ttl       int
+	renewable bool
+	// login checks the body of a login request.
+	login func(body map[string]string) bool
+}
+
+func newFakeVault(t *testing.T, v *fakeVault) *httptest.Server {
+	v.tokens = map[string]bool{"root": true}
+	v.calls = make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		v.calls[r.URL.Path]++
+		if strings.HasSuffix(r.URL.Path, "/login") {
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if v.login == nil || !v.login(body) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			v.issued++
+			token := fmt.Sprintf("t-%d", v.issued)
+			v.tokens[token] = true
+			_ = json.NewEncoder(w).Encode(map[string]any{"auth": map[string]any{
+				"client_token": token, "lease_duration": v.ttl, "renewable": v.renewable,
+			}})
+			return
+		}
+		if !v.tokens[r.Header.Get("X-Vault-Token")] {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/auth/token/lookup-self":
+			_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"ttl": v.ttl, "renewable": v.renewable}})
+		case "/v1/auth/token/renew-self":
+			_ = json.NewEncoder(w).Encode(map[string]any{"auth": map[string]any{
+				"client_token": r.Header.Get("X-Vault-Token"), "lease_duration": v.ttl, "renewable": v.renewable,
+			}})
+		case "/v1/secret/data/pako-tts":
+			_, _ = w.Write([]byte(`{"data":{"data":{"KEY":"value"}}}`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestVaultSource_RenewsToken(t *testing.T) {
+	vault := &fakeVault{ttl: 3600, renewable: true}
+	srv := newFakeVault(t, vault)
+	src, err := newVaultSource(VaultConfig{Address: srv.URL, Token: "root", Path: "pako-tts"})
+	if err != nil {
+		t.Fatalf("newVaultSource: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := src.FetchSecrets(ctx); err != nil {
+		t.Fatalf("FetchSecrets: %v", err)
+	}
+	if _, err := src.FetchSecrets(ctx); err != nil || vault.calls["/v1/auth/token/lookup-self"] != 1 || vault.calls["/v1/auth/token/renew-self"] != 0 {
+		t.Fatalf("expected one lookup and no renewal yet, got %v, %v", vault.calls, err)
+	}
+
+	// Past half its TTL, the token is renewed before reading
+	src.expiry = time.Now().Add(time.Minute)
+	if _, err := src.FetchSecrets(ctx); err != nil || vault.calls["/v1/auth/token/renew-self"] != 1 {
+		t.Fatalf("expected a renewal, got %v, %v", vault.calls, err)
+	}
+	if time.Until(src.expiry) < 59*time.Minute {
+		t.Errorf("expiry not extended: %v", src.expiry)
+	}
+
+	// A token that can't be renewed any more is used until it expires
+	vault.renewable = false
+	src.renewable = false
+	src.expiry = time.Now().Add(-time.Second)
+	if _, err := src.FetchSecrets(ctx); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("expected the token to have expired, got %v", err)
+	}
+}
+
+func TestVaultSource_AppRole(t *testing.T) {
+	vault := &fakeVault{ttl: 3600, login: func(body map[string]string) bool {
+		return body["role_id"] == "role" && body["secret_id"] == "s3cret"
+	}}
+	srv := newFakeVault(t, vault)
+	if _, err := newVaultSource(VaultConfig{Address: srv.URL, Path: "pako-tts", AuthMethod: VaultAuthAppRole, RoleID: "role"}); err == nil {
+		t.Error("expected an error without a secret ID")
+	}
+	src, err := newVaultSource(VaultConfig{Address: srv.URL, Path: "pako-tts", AuthMethod: VaultAuthAppRole, RoleID: "role", SecretID: "s3cret"})
+	if err != nil {
+		t.Fatalf("newVaultSource: %v", err)
+	}
+	ctx := context.Background()
+	values, err := src.FetchSecrets(ctx)
+	if err != nil || values["KEY"] != "value" || src.token != "t-1" {
+		t.Fatalf("FetchSecrets = %v, %v with token %q", values, err, src.token)
+	}
+
+	// A token that can't be renewed is replaced by logging in again
+	src.expiry = time.Now().Add(time.Minute)
+	if _, err := src.FetchSecrets(ctx); err != nil || src.token != "t-2" {
+		t.Fatalf("FetchSecrets = %v with token %q", err, src.token)
+	}
+
+	// So is one that was revoked
+	delete(vault.tokens, "t-2")
+	if _, err := src.FetchSecrets(ctx); err != nil || src.token != "t-3" {
+		t.Errorf("FetchSecrets = %v with token %q", err, src.token)
+	}
+	if vault.calls["/v1/auth/approle/login"] != 3 {
+		t.Errorf("logins = %d", vault.calls["/v1/auth/approle/login"])
+	}
+}
+
+func TestVaultSource_Kubernetes(t *testing.T) {
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenPath, []byte("pod-jwt\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	vault := &fakeVault{ttl: 3600, login: func(body map[string]string) bool {
+		return body["role"] == "pako-tts" && body["jwt"] == "pod-jwt"
+	}}
+	srv := newFakeVault(t, vault)
+	src, err := newVaultSource(VaultConfig{
+		Address: srv.URL, Path: "pako-tts",
+		AuthMethod: VaultAuthKubernetes, AuthMount: "k8s-prod", Role: "pako-tts", KubernetesTokenPath: tokenPath,
+	})
+	if err != nil {
+		t.Fatalf("newVaultSource: %v", err)
+	}
+	if values, err := src.FetchSecrets(context.Background()); err != nil || values["KEY"] != "value" {
+		t.Fatalf("FetchSecrets = %v, %v", values, err)
+	}
+	if vault.calls["/v1/auth/k8s-prod/login"] != 1 {
+		t.Errorf("calls = %v", vault.calls)
+	}
+}
+
+func TestAWSSource_FetchSecrets(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" {
+			t.Errorf("unexpected target %q", r.Header.Get("X-Amz-Target"))
+		}
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auth, "/eu-west-1/secretsmanager/aws4_request") {
+			t.Errorf("unexpected Authorization %q", auth)
+		}
+		if r.Header.Get("X-Amz-Security-Token") != "session" {
+			t.Errorf("missing session token")
+		}
+
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["SecretId"] != "prod/pako-tts" {
+			t.Errorf("unexpected SecretId %q", body["SecretId"])
+		}
+		_, _ = w.Write([]byte(`{"Name":"prod/pako-tts","SecretString":"{\"ELEVENLABS_API_KEY\":\"el-key\"}"}`))
+	}))
+	defer srv.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "session")
+
+	src, err := newAWSSource(AWSSecretsConfig{Region: "eu-west-1", SecretID: "prod/pako-tts", Endpoint: srv.URL})
+	if err != nil {
+		t.Fatalf("newAWSSource: %v", err)
+	}
+
+	values, err := src.FetchSecrets(context.Background())
+	if err != nil {
+		t.Fatalf("FetchSecrets: %v", err)
+	}
+	if values["ELEVENLABS_API_KEY"] != "el-key" {
+		t.Errorf("unexpected values %v", values)
+	}
+}
+
+func TestAWSSource_RenewsRoleCredentials(t *testing.T) {
+	// The task role's credentials expire within the renewal margin, so each
+	// read fetches new ones
+	var issued atomic.Int32
+	credsSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := issued.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"AccessKeyId":     "ASIA",
+			"SecretAccessKey": "secret",
+			"Token":           fmt.Sprintf("session-%d", n),
+			"Expiration":      time.Now().Add(time.Minute).UTC().Format(time.RFC3339),
+		})
+	}))
+	defer credsSrv.Close()
+	var tokens []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens = append(tokens, r.Header.Get("X-Amz-Security-Token"))
+		_, _ = w.Write([]byte(`{"SecretString":"{}"}`))
+	}))
+	defer srv.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", "")
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "")
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", credsSrv.URL)
+
+	src, err := newAWSSource(AWSSecretsConfig{Region: "eu-west-1", SecretID: "prod/pako-tts", Endpoint: srv.URL})
+	if err != nil {
+		t.Fatalf("newAWSSource: %v", err)
+	}
+	for range 2 {
+		if _, err := src.FetchSecrets(context.Background()); err != nil {
+			t.Fatalf("FetchSecrets: %v", err)
+		}
+	}
+	if len(tokens) != 2 || tokens[0] != "session-1" || tokens[1] != "session-2" {
+		t.Errorf("expected renewed session tokens, got %v", tokens)
+	}
+}
+
+func TestLoad_ResolvesAndRefreshesSecrets(t *testing.T) {
+	var key atomic.Value
+	key.Store("first-key")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{"data": map[string]any{"ELEVENLABS_API_KEY": key.Load()}},
+		})
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	yaml := `
+secrets:
+  backend: "vault"
+  vault:
+    address: "` + srv.URL + `"
+    token: "root"
+    path: "pako-tts"
+providers:
+  default: "elevenlabs"
+  list:
+    - name: "elevenlabs"
+      type: "elevenlabs"
+      api_key: "${ELEVENLABS_API_KEY}"
+`
+	if err := os.WriteFile(cfgPath, []byte(yaml), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	t.Setenv("ELEVENLABS_API_KEY", "")
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chdir(cwd)
+	})
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := cfg.Providers.List[0].APIKey; got != "first-key" {
+		t.Fatalf("expected key from vault, got %q", got)
+	}
+	if cfg.Secrets.RefreshInterval != 5*time.Minute {
+		t.Errorf("expected default refresh interval 5m, got %v", cfg.Secrets.RefreshInterval)
+	}
+
+	changed, err := cfg.RefreshSecrets(context.Background())
+	if err != nil {
+		t.Fatalf("RefreshSecrets: %v", err)
+	}
+	if len(changed) != 0 {
+		t.Errorf("expected no changes, got %v", changed)
+	}
+
+	key.Store("rotated-key")
+	changed, err = cfg.RefreshSecrets(context.Background())
+	if err != nil {
+		t.Fatalf("RefreshSecrets: %v", err)
+	}
+	if changed["elevenlabs"] != "rotated-key" || cfg.Providers.List[0].APIKey != "rotated-key" {
+		t.Errorf("expected rotated key, got %v", changed)
+	}
+}
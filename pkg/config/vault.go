@@ -0,0 +1,297 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Vault auth methods.
+const (
+	VaultAuthToken      = "token"
+	VaultAuthAppRole    = "approle"
+	VaultAuthKubernetes = "kubernetes"
+)
+
+// defaultKubernetesTokenPath is where Kubernetes mounts the pod's service
+// account token.
+const defaultKubernetesTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// errVaultForbidden is returned for a read refused with 403, e.g. because the
+// token expired.
+var errVaultForbidden = errors.New("permission denied")
+
+// vaultSource reads one KV v2 secret over Vault's HTTP API. Its token is
+// renewed once half its TTL passed; a token that can't be renewed any more is
+// replaced by logging in again, with the AppRole and Kubernetes auth methods.
+type vaultSource struct {
+	address    string
+	url        string
+	namespace  string
+	httpClient *http.Client
+	// login authenticates for a new token; nil when the token was given.
+	login func(ctx context.Context) (*vaultAuth, error)
+
+	mu        sync.Mutex
+	token     string
+	checked   bool
+	ttl       time.Duration
+	expiry    time.Time // zero for a token that doesn't expire
+	renewable bool
+}
+
+// vaultAuth is a token Vault issued, as login and renew-self return it.
+type vaultAuth struct {
+	ClientToken   string `json:"client_token"`
+	LeaseDuration int    `json:"lease_duration"`
+	Renewable     bool   `json:"renewable"`
+}
+
+func newVaultSource(cfg VaultConfig) (*vaultSource, error) {
+	if cfg.Address == "" {
+		return nil, fmt.Errorf("secrets.vault.address (or VAULT_ADDR) is required")
+	}
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("secrets.vault.path is required")
+	}
+
+	mount := strings.Trim(cfg.Mount, "/")
+	if mount == "" {
+		mount = "secret"
+	}
+	address := strings.TrimRight(cfg.Address, "/")
+	s := &vaultSource{
+		address:    address,
+		url:        address + "/v1/" + mount + "/data/" + strings.Trim(cfg.Path, "/"),
+		namespace:  cfg.Namespace,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	method := cfg.AuthMethod
+	if method == "" {
+		method = VaultAuthToken
+	}
+	authMount := strings.Trim(cfg.AuthMount, "/")
+	if authMount == "" {
+		authMount = method
+	}
+	switch method {
+	case VaultAuthToken:
+		if cfg.Token == "" {
+			return nil, fmt.Errorf("secrets.vault.token (or VAULT_TOKEN) is required")
+		}
+		s.token = cfg.Token
+	case VaultAuthAppRole:
+		if cfg.RoleID == "" || cfg.SecretID == "" {
+			return nil, fmt.Errorf("secrets.vault.role_id and secrets.vault.secret_id are required for approle auth")
+		}
+		s.login = func(ctx context.Context) (*vaultAuth, error) {
+			return s.logIn(ctx, authMount, map[string]string{"role_id": cfg.RoleID, "secret_id": cfg.SecretID})
+		}
+	case VaultAuthKubernetes:
+		if cfg.Role == "" {
+			return nil, fmt.Errorf("secrets.vault.role is required for kubernetes auth")
+		}
+		tokenPath := cfg.KubernetesTokenPath
+		if tokenPath == "" {
+			tokenPath = defaultKubernetesTokenPath
+		}
+		s.login = func(ctx context.Context) (*vaultAuth, error) {
+			// Kubernetes rotates the projected token, so it's read for every login
+			jwt, err := os.ReadFile(tokenPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read service account token: %w", err)
+			}
+			return s.logIn(ctx, authMount, map[string]string{"role": cfg.Role, "jwt": strings.TrimSpace(string(jwt))})
+		}
+	default:
+		return nil, fmt.Errorf("unknown secrets.vault.auth_method: %q", method)
+	}
+	return s, nil
+}
+
+// vaultKVResponse is the body of a KV v2 read.
+type vaultKVResponse struct {
+	Data struct {
+		Data map[string]any `json:"data"`
+	} `json:"data"`
+}
+
+// FetchSecrets reads the latest version of the secret, renewing the token or
+// logging in again first when it's due.
+func (s *vaultSource) FetchSecrets(ctx context.Context) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.ensureToken(ctx); err != nil {
+		return nil, err
+	}
+	kv, err := s.read(ctx)
+	if errors.Is(err, errVaultForbidden) && s.login != nil {
+		// The token was revoked or expired early
+		if err := s.authenticate(ctx); err != nil {
+			return nil, err
+		}
+		kv, err = s.read(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return stringifySecrets(kv.Data.Data), nil
+}
+
+// read reads the secret with the current token.
+func (s *vaultSource) read(ctx context.Context) (*vaultKVResponse, error) {
+	var kv vaultKVResponse
+	if err := s.call(ctx, http.MethodGet, s.url, nil, &kv); err != nil {
+		return nil, err
+	}
+	return &kv, nil
+}
+
+// ensureToken makes sure the token is valid for a while longer. A token that
+// was given is looked up once to learn its TTL. Past half its TTL, a token is
+// renewed; one that can't be renewed is replaced by logging in again, or, when
+// it was given, used until it expires.
+func (s *vaultSource) ensureToken(ctx context.Context) error {
+	if s.token == "" {
+		return s.authenticate(ctx)
+	}
+	if !s.checked {
+		var lookup struct {
+			Data struct {
+				TTL       int  `json:"ttl"`
+				Renewable bool `json:"renewable"`
+			} `json:"data"`
+		}
+		if err := s.call(ctx, http.MethodGet, s.address+"/v1/auth/token/lookup-self", nil, &lookup); err != nil {
+			return fmt.Errorf("failed to look up vault token: %w", err)
+		}
+		s.setTTL(lookup.Data.TTL, lookup.Data.Renewable)
+		s.checked = true
+	}
+	if s.expiry.IsZero() || time.Until(s.expiry) > s.ttl/2 {
+		return nil
+	}
+	if s.renewable {
+		var renewed struct {
+			Auth vaultAuth `json:"auth"`
+		}
+		err := s.call(ctx, http.MethodPost, s.address+"/v1/auth/token/renew-self", struct{}{}, &renewed)
+		// Renewal may be capped by the token's max TTL, so the token still needs
+		// replacing eventually
+		if err == nil && renewed.Auth.LeaseDuration > 0 {
+			s.setTTL(renewed.Auth.LeaseDuration, renewed.Auth.Renewable)
+			return nil
+		}
+	}
+	if s.login != nil {
+		return s.authenticate(ctx)
+	}
+	if time.Now().After(s.expiry) {
+		return fmt.Errorf("vault token expired and can't be renewed")
+	}
+	return nil
+}
+
+// authenticate logs in for a new token.
+func (s *vaultSource) authenticate(ctx context.Context) error {
+	s.token = ""
+	auth, err := s.login(ctx)
+	if err != nil {
+		return fmt.Errorf("vault login failed: %w", err)
+	}
+	s.token = auth.ClientToken
+	s.checked = true
+	s.setTTL(auth.LeaseDuration, auth.Renewable)
+	return nil
+}
+
+// logIn posts credentials to the login endpoint of the auth method mounted at
+// mount.
+func (s *vaultSource) logIn(ctx context.Context, mount string, credentials map[string]string) (*vaultAuth, error) {
+	var out struct {
+		Auth *vaultAuth `json:"auth"`
+	}
+	if err := s.call(ctx, http.MethodPost, s.address+"/v1/auth/"+mount+"/login", credentials, &out); err != nil {
+		return nil, err
+	}
+	if out.Auth == nil || out.Auth.ClientToken == "" {
+		return nil, fmt.Errorf("no token in login response")
+	}
+	return out.Auth, nil
+}
+
+func (s *vaultSource) setTTL(seconds int, renewable bool) {
+	s.ttl = time.Duration(seconds) * time.Second
+	s.renewable = renewable
+	s.expiry = time.Time{}
+	if seconds > 0 {
+		s.expiry = time.Now().Add(s.ttl)
+	}
+}
+
+// call sends a request to Vault with the current token, encoding in as the JSON
+// body when non-nil, and decodes the answer into out.
+func (s *vaultSource) call(ctx context.Context, method, url string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return fmt.Errorf("failed to create vault request: %w", err)
+	}
+	if s.token != "" {
+		req.Header.Set("X-Vault-Token", s.token)
+	}
+	if s.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", s.namespace)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("vault returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+		if resp.StatusCode == http.StatusForbidden {
+			err = fmt.Errorf("%w: %w", errVaultForbidden, err)
+		}
+		return err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode vault response: %w", err)
+	}
+	return nil
+}
+
+// stringifySecrets flattens a decoded JSON object into string values.
+func stringifySecrets(data map[string]any) map[string]string {
+	out := make(map[string]string, len(data))
+	for k, v := range data {
+		switch val := v.(type) {
+		case string:
+			out[k] = val
+		case nil:
+		default:
+			out[k] = fmt.Sprint(val)
+		}
+	}
+	return out
+}